@@ -0,0 +1,81 @@
+package main
+
+import (
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/zhishengyuan/searchgram-engine/config"
+	"github.com/zhishengyuan/searchgram-engine/middleware"
+)
+
+// apiKeys returns the full-access API keys configured in auth: api_key or
+// api_key_hash, then the named keys
+func apiKeys(auth config.AuthConfig) []middleware.APIKey {
+	keys := make([]middleware.APIKey, 0, len(auth.Keys)+1)
+	switch {
+	case auth.APIKeyHash != "":
+		keys = append(keys, middleware.APIKey{Hash: strings.ToLower(auth.APIKeyHash), Role: auth.APIKeyRole})
+	case auth.APIKey != "":
+		keys = append(keys, middleware.APIKey{Hash: middleware.HashAPIKey(auth.APIKey), Role: auth.APIKeyRole})
+	}
+	for _, key := range auth.Keys {
+		hash := key.KeyHash
+		if hash == "" {
+			hash = middleware.HashAPIKey(key.Key)
+		}
+		role := key.Role
+		if role == "" {
+			role = auth.APIKeyRole
+		}
+		keys = append(keys, middleware.APIKey{Name: key.Name, Hash: strings.ToLower(hash), Role: role})
+	}
+	return keys
+}
+
+// scopedKeys returns the chat-scoped API keys configured in auth.api_keys
+func scopedKeys(auth config.AuthConfig) []middleware.ScopedKey {
+	keys := make([]middleware.ScopedKey, 0, len(auth.APIKeys))
+	for _, key := range auth.APIKeys {
+		hash := key.KeyHash
+		if hash == "" {
+			hash = middleware.HashAPIKey(key.Key)
+		}
+		keys = append(keys, middleware.ScopedKey{
+			Name:    key.Name,
+			Hash:    strings.ToLower(hash),
+			ChatIDs: key.ChatIDs,
+			Role:    key.Role,
+		})
+	}
+	return keys
+}
+
+// loadAPIKeys returns a loader reading the API keys from the configuration
+// file, which must pass validation. Other settings of the file only take
+// effect on restart.
+func loadAPIKeys(configPath string) func() ([]middleware.APIKey, []middleware.ScopedKey, error) {
+	return func() ([]middleware.APIKey, []middleware.ScopedKey, error) {
+		cfg, err := config.Reload(configPath)
+		if err != nil {
+			return nil, nil, err
+		}
+		return apiKeys(cfg.Auth), scopedKeys(cfg.Auth), nil
+	}
+}
+
+// reloadAPIKeysOnSIGHUP reloads the API keys whenever the process receives
+// SIGHUP, e.g. from `kill -HUP` after editing the configuration
+func reloadAPIKeysOnSIGHUP(keys *middleware.KeyRing) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for range hup {
+			if _, err := keys.Reload(); err != nil {
+				log.WithError(err).Error("Failed to reload API keys, keeping the current ones")
+			}
+		}
+	}()
+}
@@ -0,0 +1,400 @@
+package archive
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/zhishengyuan/searchgram-engine/engines"
+	"github.com/zhishengyuan/searchgram-engine/models"
+	"github.com/zhishengyuan/searchgram-engine/s3"
+)
+
+// Spool files below Config.SpoolDir: messages are appended to spoolFile,
+// which is renamed to a batch file when it is due for upload
+const (
+	spoolFile   = "spool.ndjson"
+	batchPrefix = "batch-"
+	batchSuffix = ".ndjson"
+)
+
+// batchTimeLayout names batch files and archive objects; they sort by time
+const batchTimeLayout = "20060102T150405.000Z"
+
+// Config holds archive configuration
+type Config struct {
+	Interval time.Duration // How often spooled messages are uploaded
+	Prefix   string        // Key prefix of archive objects
+	SpoolDir string        // Where messages wait for the next upload
+}
+
+// Archiver copies newly indexed messages to an S3-compatible bucket for cold
+// storage. Messages are appended to a spool file as they are written and
+// uploaded as one gzip-compressed NDJSON object per interval, keyed by
+// date, so archives outlive the search backend and can be imported into
+// any engine. Spooled messages survive restarts; a failed upload is retried
+// on the next interval.
+type Archiver struct {
+	bucket *s3.Client
+	cfg    Config
+
+	mu           sync.Mutex
+	spool        *os.File
+	writer       *bufio.Writer
+	spooled      int64            // Messages in the spool file
+	batches      map[string]int64 // Batch file -> messages, waiting for upload
+	archived     int64
+	uploads      int64
+	lastUploadAt time.Time
+	lastObject   string
+	lastError    string
+
+	uploadMu sync.Mutex // Held while rotating and uploading
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// New creates an archiver uploading to bucket. Messages spooled before a
+// restart are picked up and uploaded with the next interval.
+func New(bucket *s3.Client, cfg Config) (*Archiver, error) {
+	if cfg.Interval <= 0 {
+		cfg.Interval = time.Hour
+	}
+	cfg.Prefix = strings.Trim(cfg.Prefix, "/")
+
+	if err := os.MkdirAll(cfg.SpoolDir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create archive spool: %w", err)
+	}
+
+	a := &Archiver{
+		bucket:  bucket,
+		cfg:     cfg,
+		batches: make(map[string]int64),
+	}
+
+	entries, err := os.ReadDir(cfg.SpoolDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read archive spool: %w", err)
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		if strings.HasPrefix(name, batchPrefix) && strings.HasSuffix(name, batchSuffix) {
+			lines, err := countLines(filepath.Join(cfg.SpoolDir, name))
+			if err != nil {
+				return nil, err
+			}
+			a.batches[name] = lines
+		}
+	}
+
+	path := filepath.Join(cfg.SpoolDir, spoolFile)
+	if a.spooled, err = countLines(path); err != nil {
+		return nil, err
+	}
+	if err := a.openSpool(); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// Wrap returns engine with its indexed messages recorded for archiving
+func (a *Archiver) Wrap(engine engines.SearchEngine) engines.SearchEngine {
+	return &Engine{SearchEngine: engine, archiver: a}
+}
+
+// Stats returns archive counters
+func (a *Archiver) Stats() *models.ArchiveStats {
+	if a == nil {
+		return nil
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	stats := &models.ArchiveStats{
+		Bucket:     a.bucket.Bucket(),
+		Prefix:     a.cfg.Prefix,
+		Pending:    a.spooled,
+		Archived:   a.archived,
+		Uploads:    a.uploads,
+		LastObject: a.lastObject,
+		LastError:  a.lastError,
+	}
+	for _, lines := range a.batches {
+		stats.Pending += lines
+	}
+	if !a.lastUploadAt.IsZero() {
+		stats.LastUploadAt = a.lastUploadAt.Unix()
+	}
+	return stats
+}
+
+// Start begins uploading spooled messages every interval
+func (a *Archiver) Start() {
+	a.stop = make(chan struct{})
+	a.done = make(chan struct{})
+
+	go a.run()
+
+	log.WithFields(log.Fields{
+		"bucket":   a.bucket.Bucket(),
+		"prefix":   a.cfg.Prefix,
+		"interval": a.cfg.Interval.String(),
+		"pending":  a.Stats().Pending,
+	}).Info("Message archiving enabled")
+}
+
+// Stop stops the background worker and uploads what is spooled until ctx
+// expires. Messages left over stay spooled for the next start.
+func (a *Archiver) Stop(ctx context.Context) {
+	if a == nil || a.stop == nil {
+		return
+	}
+	close(a.stop)
+	<-a.done
+
+	if err := a.Upload(ctx); err != nil {
+		log.WithError(err).Warn("Archive upload at shutdown failed, messages stay spooled")
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if err := a.writer.Flush(); err != nil {
+		log.WithError(err).Error("Failed to write archive spool")
+	}
+	a.spool.Close()
+}
+
+// run uploads spooled messages every interval
+func (a *Archiver) run() {
+	defer close(a.done)
+
+	// An upload in progress is interrupted by Stop, which uploads again
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		<-a.stop
+		cancel()
+	}()
+
+	ticker := time.NewTicker(a.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := a.Upload(ctx); err != nil && ctx.Err() == nil {
+				log.WithError(err).Warn("Archive upload failed, retrying next interval")
+			}
+		case <-a.stop:
+			return
+		}
+	}
+}
+
+// record appends indexed messages to the spool
+func (a *Archiver) record(messages []models.Message) {
+	if len(messages) == 0 {
+		return
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	enc := json.NewEncoder(a.writer)
+	for i := range messages {
+		if err := enc.Encode(&messages[i]); err != nil {
+			a.spoolFailed(err)
+			return
+		}
+		a.spooled++
+	}
+	if err := a.writer.Flush(); err != nil {
+		a.spoolFailed(err)
+	}
+}
+
+// spoolFailed records a spool write failure (caller holds lock)
+func (a *Archiver) spoolFailed(err error) {
+	a.lastError = fmt.Sprintf("failed to write archive spool: %v", err)
+	log.WithError(err).Error("Failed to write archive spool, messages are not archived")
+}
+
+// Upload turns the spool into a batch and uploads every batch waiting, oldest
+// first, as one object each
+func (a *Archiver) Upload(ctx context.Context) error {
+	a.uploadMu.Lock()
+	defer a.uploadMu.Unlock()
+
+	if err := a.rotate(); err != nil {
+		return err
+	}
+
+	a.mu.Lock()
+	names := make([]string, 0, len(a.batches))
+	for name := range a.batches {
+		names = append(names, name)
+	}
+	a.mu.Unlock()
+	sort.Strings(names)
+
+	for _, name := range names {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := a.uploadBatch(ctx, name); err != nil {
+			a.mu.Lock()
+			a.lastError = err.Error()
+			a.mu.Unlock()
+			return err
+		}
+	}
+	return nil
+}
+
+// rotate renames a non-empty spool to a batch file and starts a new spool
+func (a *Archiver) rotate() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.spooled == 0 {
+		return nil
+	}
+	if err := a.writer.Flush(); err != nil {
+		return fmt.Errorf("failed to write archive spool: %w", err)
+	}
+	if err := a.spool.Close(); err != nil {
+		return fmt.Errorf("failed to close archive spool: %w", err)
+	}
+
+	name := batchPrefix + time.Now().UTC().Format(batchTimeLayout) + batchSuffix
+	if err := os.Rename(filepath.Join(a.cfg.SpoolDir, spoolFile), filepath.Join(a.cfg.SpoolDir, name)); err != nil {
+		// Keep appending to the old spool
+		if reopenErr := a.openSpool(); reopenErr != nil {
+			return reopenErr
+		}
+		return fmt.Errorf("failed to rotate archive spool: %w", err)
+	}
+	a.batches[name] = a.spooled
+	a.spooled = 0
+	return a.openSpool()
+}
+
+// openSpool opens the spool file for appending (caller holds lock)
+func (a *Archiver) openSpool() error {
+	f, err := os.OpenFile(filepath.Join(a.cfg.SpoolDir, spoolFile), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to open archive spool: %w", err)
+	}
+	a.spool = f
+	a.writer = bufio.NewWriter(f)
+	return nil
+}
+
+// uploadBatch compresses one batch file, uploads it and removes it
+func (a *Archiver) uploadBatch(ctx context.Context, name string) error {
+	path := filepath.Join(a.cfg.SpoolDir, name)
+	gzPath := path + ".gz"
+	defer os.Remove(gzPath)
+
+	if err := compress(path, gzPath); err != nil {
+		return err
+	}
+
+	key := a.objectKey(name)
+	if err := a.bucket.PutFile(ctx, key, gzPath); err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil {
+		return fmt.Errorf("failed to remove uploaded batch: %w", err)
+	}
+
+	a.mu.Lock()
+	lines := a.batches[name]
+	delete(a.batches, name)
+	a.archived += lines
+	a.uploads++
+	a.lastUploadAt = time.Now()
+	a.lastObject = key
+	a.lastError = ""
+	a.mu.Unlock()
+
+	log.WithFields(log.Fields{
+		"object":   key,
+		"messages": lines,
+	}).Info("Messages archived")
+	return nil
+}
+
+// objectKey returns the key of a batch: prefix/yyyy/mm/dd/messages-<time>.ndjson.gz
+func (a *Archiver) objectKey(name string) string {
+	stamp := strings.TrimSuffix(strings.TrimPrefix(name, batchPrefix), batchSuffix)
+	key := "messages-" + stamp + ".ndjson.gz"
+	if t, err := time.Parse(batchTimeLayout, stamp); err == nil {
+		key = t.Format("2006/01/02/") + key
+	}
+	if a.cfg.Prefix != "" {
+		key = a.cfg.Prefix + "/" + key
+	}
+	return key
+}
+
+// compress writes a gzip-compressed copy of src to dst
+func compress(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	gz := gzip.NewWriter(out)
+	if _, err := io.Copy(gz, in); err != nil {
+		out.Close()
+		return fmt.Errorf("failed to compress %s: %w", src, err)
+	}
+	if err := gz.Close(); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// countLines counts the messages in a spool or batch file (0 if missing)
+func countLines(path string) (int64, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	var lines int64
+	buf := make([]byte, 64<<10)
+	for {
+		n, err := f.Read(buf)
+		lines += int64(bytes.Count(buf[:n], []byte{'\n'}))
+		if err == io.EOF {
+			return lines, nil
+		}
+		if err != nil {
+			return 0, fmt.Errorf("failed to read %s: %w", path, err)
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package archive
+
+import (
+	"context"
+	"strings"
+
+	"github.com/zhishengyuan/searchgram-engine/engines"
+	"github.com/zhishengyuan/searchgram-engine/models"
+)
+
+// Engine records the messages the wrapped engine indexed successfully, so
+// every path that indexes (API, ingest queue, consumers, backfills) reaches
+// the archive. Edits are archived as the whole edited message; deletes are
+// not archived. Everything else passes through.
+type Engine struct {
+	engines.SearchEngine
+	archiver *Archiver
+}
+
+// Upsert indexes a message
+func (e *Engine) Upsert(ctx context.Context, message *models.Message) error {
+	if err := e.SearchEngine.Upsert(ctx, message); err != nil {
+		return err
+	}
+	e.archiver.record([]models.Message{*message})
+	return nil
+}
+
+// UpsertBatch indexes messages; those the engine refused are not archived
+func (e *Engine) UpsertBatch(ctx context.Context, messages []models.Message) (int, []string, error) {
+	indexed, failures, err := e.SearchEngine.UpsertBatch(ctx, messages)
+	if err != nil {
+		return indexed, failures, err
+	}
+
+	written := messages
+	if len(failures) > 0 {
+		written = make([]models.Message, 0, len(messages))
+		for _, message := range messages {
+			if !failed(failures, message.ID) {
+				written = append(written, message)
+			}
+		}
+	}
+	e.archiver.record(written)
+	return indexed, failures, nil
+}
+
+// UpdateMessage edits a message
+func (e *Engine) UpdateMessage(ctx context.Context, id string, fn func(message *models.Message)) (*models.Message, error) {
+	message, err := e.SearchEngine.UpdateMessage(ctx, id, fn)
+	if err == nil && message != nil {
+		e.archiver.record([]models.Message{*message})
+	}
+	return message, err
+}
+
+// failed reports whether any failure names the document
+// ("Document <id> failed ...")
+func failed(failures []string, id string) bool {
+	for _, failure := range failures {
+		if strings.Contains(failure, "Document "+id+" ") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,203 @@
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Entry is one audit record
+type Entry struct {
+	Time   time.Time              `json:"time"`
+	Action string                 `json:"action"`           // What happened, e.g. "auth.ban"
+	Actor  string                 `json:"actor,omitempty"`  // Authenticated caller, when known
+	IP     string                 `json:"ip,omitempty"`     // Client address
+	Detail map[string]interface{} `json:"detail,omitempty"` // Action-specific fields
+}
+
+// record is an entry as written: chained to the previous one by hash, with
+// Detail kept as encoded so the hash can be recomputed from the file
+type record struct {
+	Time     time.Time       `json:"time"`
+	Action   string          `json:"action"`
+	Actor    string          `json:"actor,omitempty"`
+	IP       string          `json:"ip,omitempty"`
+	Detail   json.RawMessage `json:"detail,omitempty"`
+	Seq      int64           `json:"seq,omitempty"`       // Position in the chain, from 1
+	PrevHash string          `json:"prev_hash,omitempty"` // Hash of the previous record
+	Hash     string          `json:"hash,omitempty"`      // SHA-256 of the record without Hash
+}
+
+// digest returns the hash of the record with its Hash left out. PrevHash is
+// hashed too, so each hash covers the whole trail up to the record.
+func (r record) digest() string {
+	r.Hash = ""
+	data, _ := json.Marshal(r)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// Log appends audit entries to a JSON Lines file. Entries are never
+// rewritten, and each carries the hash of the one before it, so removing
+// or editing an entry breaks the chain from there on (see Verify). With an
+// index attached the entries are also copied to an append-only index
+// outside the file. A nil Log records nothing.
+type Log struct {
+	path string
+
+	mu   sync.Mutex
+	file *os.File
+	seq  int64  // Seq of the last record
+	hash string // Hash of the last record
+
+	mirror *mirror // Copy in an append-only index (nil = file only)
+}
+
+// Open opens or creates the audit file at path for appending, continuing
+// the hash chain of the entries already in it
+func Open(path string) (*Log, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create directory for %s: %w", path, err)
+	}
+
+	l := &Log{path: path}
+	partial := false
+	err := l.scan(func(_ []byte, rec *record) error {
+		partial = rec == nil
+		if rec != nil && rec.Hash != "" {
+			l.seq = rec.Seq
+			l.hash = rec.Hash
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit log %s: %w", path, err)
+	}
+	l.file = file
+
+	// A line cut short by a crash is left as is, so Verify reports it; the
+	// next entry starts on a line of its own
+	if partial {
+		if _, err := file.Write([]byte("\n")); err != nil {
+			file.Close()
+			return nil, fmt.Errorf("failed to write audit log %s: %w", path, err)
+		}
+	}
+
+	return l, nil
+}
+
+// Record appends an entry, stamping it with the current time if unset.
+// Write failures are logged rather than returned so auditing never fails
+// the operation being audited.
+func (l *Log) Record(entry Entry) {
+	if l == nil {
+		return
+	}
+	if entry.Time.IsZero() {
+		entry.Time = time.Now().UTC()
+	}
+
+	rec := record{
+		Time:   entry.Time,
+		Action: entry.Action,
+		Actor:  entry.Actor,
+		IP:     entry.IP,
+	}
+	if len(entry.Detail) > 0 {
+		detail, err := json.Marshal(entry.Detail)
+		if err != nil {
+			log.WithError(err).WithField("action", entry.Action).Error("Failed to encode audit entry")
+			return
+		}
+		rec.Detail = detail
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	rec.Seq = l.seq + 1
+	rec.PrevHash = l.hash
+	rec.Hash = rec.digest()
+
+	line, err := json.Marshal(rec)
+	if err != nil {
+		log.WithError(err).WithField("action", entry.Action).Error("Failed to encode audit entry")
+		return
+	}
+	if _, err := l.file.Write(append(line, '\n')); err != nil {
+		log.WithError(err).WithField("action", entry.Action).Error("Failed to write audit entry")
+		return
+	}
+	l.seq = rec.Seq
+	l.hash = rec.Hash
+
+	l.mirror.add(rec.Seq, line)
+}
+
+// Close stops copying to the index, after a last attempt to catch up, and
+// closes the audit file
+func (l *Log) Close() error {
+	if l == nil {
+		return nil
+	}
+
+	l.mirror.stop()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.file.Close()
+}
+
+// scan passes every line of the file to fn in order, with the record it
+// holds or nil when it cannot be decoded, e.g. because a crash cut it
+// short. Entries written before hash chaining have no Seq or Hash.
+func (l *Log) scan(fn func(line []byte, rec *record) error) error {
+	file, err := os.Open(l.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to open audit log %s: %w", l.path, err)
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	for {
+		line, readErr := reader.ReadBytes('\n')
+		if readErr != nil && readErr != io.EOF {
+			return fmt.Errorf("failed to read audit log %s: %w", l.path, readErr)
+		}
+
+		if line = bytes.TrimSpace(line); len(line) > 0 {
+			var rec record
+			decoded := &rec
+			if readErr == io.EOF || json.Unmarshal(line, &rec) != nil {
+				decoded = nil // A line without its newline was cut short
+			}
+			if err := fn(line, decoded); err != nil {
+				return err
+			}
+		}
+		if readErr == io.EOF {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,203 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// retryInterval is how often copying to the index is retried after a failure
+	retryInterval = 30 * time.Second
+
+	// maxPending bounds the entries buffered for the index; beyond it the
+	// copy catches up from the file instead
+	maxPending = 10000
+
+	// stopTimeout bounds the last attempt to catch up when closing
+	stopTimeout = 5 * time.Second
+)
+
+// Index is an append-only store the trail is copied to, so altering it
+// takes write access to both the file and the index. Entries are keyed by
+// their Seq and never overwritten.
+type Index interface {
+	// AppendAudit stores an encoded entry; an entry already stored under
+	// seq is left as it is
+	AppendAudit(ctx context.Context, seq int64, entry json.RawMessage) error
+
+	// LastAuditSeq returns the highest Seq stored (0 = none)
+	LastAuditSeq(ctx context.Context) (int64, error)
+
+	// ScanAudit passes the stored entries to fn in pages, ordered by Seq
+	ScanAudit(ctx context.Context, fn func(entries []json.RawMessage) error) error
+}
+
+// pendingEntry is an entry written to the file and not yet to the index
+type pendingEntry struct {
+	seq  int64
+	line json.RawMessage
+}
+
+// mirror copies records to the index in the background. New records are
+// buffered; after a failure, at startup and when the buffer overflows it
+// catches up by copying the records the index is missing from the file.
+type mirror struct {
+	log   *Log
+	index Index
+
+	mu      sync.Mutex
+	pending []pendingEntry
+	behind  bool  // Catch up from the file before copying pending records
+	indexed int64 // Highest Seq known to be in the index
+
+	wake    chan struct{}
+	done    chan struct{}
+	stopped chan struct{}
+}
+
+// SetIndex starts copying the trail to index, beginning with the entries
+// it does not hold yet
+func (l *Log) SetIndex(index Index) {
+	if l == nil {
+		return
+	}
+
+	m := &mirror{
+		log:     l,
+		index:   index,
+		behind:  true,
+		wake:    make(chan struct{}, 1),
+		done:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+	l.mu.Lock()
+	l.mirror = m
+	l.mu.Unlock()
+
+	go m.run()
+	m.signal()
+}
+
+// add buffers a record written to the file (caller holds the log lock)
+func (m *mirror) add(seq int64, line []byte) {
+	if m == nil {
+		return
+	}
+
+	m.mu.Lock()
+	if len(m.pending) >= maxPending {
+		m.pending = nil
+		m.behind = true
+	} else if !m.behind {
+		m.pending = append(m.pending, pendingEntry{seq: seq, line: line})
+	}
+	m.mu.Unlock()
+
+	m.signal()
+}
+
+// signal wakes the copy loop without blocking
+func (m *mirror) signal() {
+	select {
+	case m.wake <- struct{}{}:
+	default:
+	}
+}
+
+// run copies records until stopped, retrying failures periodically
+func (m *mirror) run() {
+	defer close(m.stopped)
+
+	ticker := time.NewTicker(retryInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-m.done:
+			ctx, cancel := context.WithTimeout(context.Background(), stopTimeout)
+			m.flush(ctx)
+			cancel()
+			return
+		case <-m.wake:
+		case <-ticker.C:
+		}
+		m.flush(context.Background())
+	}
+}
+
+// stop ends the copy loop after a last attempt to catch up
+func (m *mirror) stop() {
+	if m == nil {
+		return
+	}
+	close(m.done)
+	<-m.stopped
+}
+
+// flush copies the buffered records, or catches up from the file when
+// behind. A failure leaves the mirror behind so the next attempt catches up.
+func (m *mirror) flush(ctx context.Context) {
+	m.mu.Lock()
+	pending, behind := m.pending, m.behind
+	m.pending, m.behind = nil, false
+	m.mu.Unlock()
+
+	var err error
+	if behind {
+		err = m.catchUp(ctx)
+	} else {
+		err = m.append(ctx, pending)
+	}
+	if err != nil {
+		log.WithError(err).Warn("Failed to copy audit entries to the index, retrying")
+		m.mu.Lock()
+		m.pending = nil
+		m.behind = true
+		m.mu.Unlock()
+	}
+}
+
+// catchUp copies the records in the file the index is missing
+func (m *mirror) catchUp(ctx context.Context) error {
+	last, err := m.index.LastAuditSeq(ctx)
+	if err != nil {
+		return err
+	}
+	m.indexed = last
+
+	var batch []pendingEntry
+	err = m.log.scan(func(line []byte, rec *record) error {
+		if rec == nil || rec.Seq <= m.indexed {
+			return nil
+		}
+		batch = append(batch, pendingEntry{seq: rec.Seq, line: line})
+		if len(batch) < maxPending {
+			return nil
+		}
+		err := m.append(ctx, batch)
+		batch = nil
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	return m.append(ctx, batch)
+}
+
+// append copies records in order, skipping those already in the index
+func (m *mirror) append(ctx context.Context, entries []pendingEntry) error {
+	for _, entry := range entries {
+		if entry.seq <= m.indexed {
+			continue
+		}
+		if err := m.index.AppendAudit(ctx, entry.seq, entry.line); err != nil {
+			return err
+		}
+		m.indexed = entry.seq
+	}
+	return nil
+}
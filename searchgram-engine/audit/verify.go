@@ -0,0 +1,143 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/zhishengyuan/searchgram-engine/models"
+)
+
+// errBroken stops a scan at the first break in the chain
+var errBroken = errors.New("audit chain broken")
+
+// chain checks records follow each other by Seq and hash
+type chain struct {
+	seq  int64
+	hash string
+}
+
+// next checks rec continues the chain, returning why not otherwise
+func (c *chain) next(rec *record) string {
+	switch {
+	case rec.Seq != c.seq+1:
+		return fmt.Sprintf("expected seq %d, found %d", c.seq+1, rec.Seq)
+	case rec.PrevHash != c.hash:
+		return "prev_hash does not match the previous entry"
+	case rec.digest() != rec.Hash:
+		return "hash does not match the entry"
+	}
+	c.seq = rec.Seq
+	c.hash = rec.Hash
+	return ""
+}
+
+// Verify recomputes the hash chain of the audit file, reporting the first
+// entry that was altered, removed or inserted. With an index attached, the
+// index must hold the same chain, which catches a file rewritten with a
+// consistent chain or cut short. Entries recorded while verifying are not
+// checked.
+func (l *Log) Verify(ctx context.Context) (*models.AuditVerification, error) {
+	l.mu.Lock()
+	limit, m := l.seq, l.mirror
+	l.mu.Unlock()
+
+	result := &models.AuditVerification{Valid: true}
+	var hashes map[int64]string
+	if m != nil {
+		hashes = make(map[int64]string)
+	}
+
+	var file chain
+	fail := func(reason string) error {
+		result.Valid = false
+		result.BrokenAt = file.seq + 1
+		result.Reason = reason
+		return errBroken
+	}
+	line := 0
+	err := l.scan(func(_ []byte, rec *record) error {
+		line++
+		switch {
+		case rec == nil:
+			return fail(fmt.Sprintf("line %d cannot be decoded", line))
+		case rec.Hash == "":
+			if file.seq > 0 {
+				return fail(fmt.Sprintf("line %d has no hash", line))
+			}
+			result.Unchained++
+			return nil
+		case rec.Seq > limit:
+			return nil
+		}
+
+		if reason := file.next(rec); reason != "" {
+			return fail(reason)
+		}
+		result.Entries++
+		if hashes != nil {
+			hashes[rec.Seq] = rec.Hash
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errBroken) {
+		return nil, err
+	}
+	result.LastSeq = file.seq
+	result.LastHash = file.hash
+
+	if m != nil {
+		index, err := l.verifyIndex(ctx, m.index, hashes, result.Valid, limit)
+		if err != nil {
+			return nil, err
+		}
+		if result.Valid && index.Valid {
+			index.Missing = result.LastSeq - index.Entries
+		}
+		result.Index = index
+	}
+	return result, nil
+}
+
+// verifyIndex checks the index holds the chain of the file up to limit.
+// Past a break in the file only the index's own chain can be checked.
+func (l *Log) verifyIndex(ctx context.Context, index Index, hashes map[int64]string, fileValid bool, limit int64) (*models.AuditIndexVerification, error) {
+	result := &models.AuditIndexVerification{Valid: true}
+
+	var stored chain
+	fail := func(reason string) error {
+		result.Valid = false
+		result.BrokenAt = stored.seq + 1
+		result.Reason = reason
+		return errBroken
+	}
+	err := index.ScanAudit(ctx, func(entries []json.RawMessage) error {
+		for _, entry := range entries {
+			var rec record
+			if err := json.Unmarshal(entry, &rec); err != nil {
+				return fail(fmt.Sprintf("entry after seq %d cannot be decoded", stored.seq))
+			}
+			if rec.Seq > limit {
+				return errBroken
+			}
+
+			if reason := stored.next(&rec); reason != "" {
+				return fail(reason)
+			}
+			hash, ok := hashes[rec.Seq]
+			if !ok && fileValid {
+				return fail(fmt.Sprintf("entry %d is in the index but not in the file", rec.Seq))
+			}
+			if ok && hash != rec.Hash {
+				return fail(fmt.Sprintf("entry %d differs from the file", rec.Seq))
+			}
+			result.Entries++
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errBroken) {
+		return nil, fmt.Errorf("failed to read audit index: %w", err)
+	}
+	return result, nil
+}
@@ -0,0 +1,352 @@
+package authguard
+
+import (
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+	"github.com/zhishengyuan/searchgram-engine/audit"
+	"github.com/zhishengyuan/searchgram-engine/i18n"
+	"github.com/zhishengyuan/searchgram-engine/models"
+)
+
+const (
+	// maxTracked bounds memory under scans from many addresses; beyond it,
+	// expired records are swept on every new failure
+	maxTracked = 100000
+
+	// maxFailureAudits bounds the failures written to the audit log per
+	// minute; each source's first failure per window is written, the rest
+	// are only counted
+	maxFailureAudits = 60
+)
+
+// Config holds brute-force protection thresholds
+type Config struct {
+	FreeFailures   int           // Failures per window before responses are delayed
+	Window         time.Duration // Failures older than this are forgotten
+	BaseDelay      time.Duration // Delay after the first failure beyond FreeFailures, doubled per further failure
+	MaxDelay       time.Duration // Upper bound for the delay
+	BanAfter       int           // Failures per window that trigger a ban (0 = never ban)
+	BanDuration    time.Duration // Length of a first ban, doubled for each repeat ban
+	MaxBanDuration time.Duration // Upper bound for a ban
+
+	// TrustProxyHeaders takes the client address from trusted proxies'
+	// headers; otherwise the connection's address is used, since any
+	// client can send X-Forwarded-For
+	TrustProxyHeaders bool
+}
+
+// record tracks the failures of one source
+type record struct {
+	failures    int
+	windowStart time.Time
+	bans        int
+	bannedUntil time.Time
+	audited     bool // The failure opening the window was audited
+}
+
+// Guard slows down and temporarily bans sources of repeated authentication
+// failures. Sources are client addresses (IPv6 grouped by /64, since one
+// host usually owns the whole prefix); presented credentials are never
+// tracked, as a ban decided before authentication would let anyone lock
+// out a key. A nil Guard allows everything.
+type Guard struct {
+	cfg   Config
+	audit *audit.Log
+
+	mu      sync.Mutex
+	sources map[string]*record
+
+	failures int64
+	delayed  int64
+	rejected int64
+	bans     int64
+
+	auditMinute time.Time // Start of the minute failure audits are counted in
+	audits      int       // Failure audits written this minute
+	suppressed  int       // Failure audits skipped since the last one written
+}
+
+// New creates a guard that records bans and failures in auditLog
+func New(cfg Config, auditLog *audit.Log) *Guard {
+	if cfg.Window <= 0 {
+		cfg.Window = 15 * time.Minute
+	}
+	if cfg.BanDuration <= 0 {
+		cfg.BanDuration = 15 * time.Minute
+	}
+	if cfg.MaxBanDuration < cfg.BanDuration {
+		cfg.MaxBanDuration = cfg.BanDuration
+	}
+	if cfg.MaxDelay < cfg.BaseDelay {
+		cfg.MaxDelay = cfg.BaseDelay
+	}
+	return &Guard{
+		cfg:     cfg,
+		audit:   auditLog,
+		sources: make(map[string]*record),
+	}
+}
+
+// Middleware guards the authentication middleware that follows it. Banned
+// sources get 429 without reaching authentication; sources with recent
+// failures are delayed first; every 401 from the rest of the chain counts
+// as a failure and any other response clears the source's failures.
+func (g *Guard) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if g == nil {
+			c.Next()
+			return
+		}
+
+		source := g.requestSource(c)
+
+		retryAfter, delay := g.check(source)
+		if retryAfter > 0 {
+			seconds := int(retryAfter.Seconds())
+			if seconds < 1 {
+				seconds = 1
+			}
+			c.Header("Retry-After", strconv.Itoa(seconds))
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{
+				"error":   "Too Many Requests",
+				"message": i18n.Tc(c, "Too many failed auth attempts, retry in %s", retryAfter.Round(time.Second)),
+			})
+			return
+		}
+
+		if delay > 0 {
+			timer := time.NewTimer(delay)
+			select {
+			case <-timer.C:
+			case <-c.Request.Context().Done():
+				timer.Stop()
+				c.Abort()
+				return
+			}
+		}
+
+		c.Next()
+
+		if c.Writer.Status() == http.StatusUnauthorized {
+			g.fail(c, source)
+		} else {
+			g.succeed(source)
+		}
+	}
+}
+
+// Stats returns the guard counters
+func (g *Guard) Stats() *models.AuthGuardStats {
+	if g == nil {
+		return nil
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	now := time.Now()
+	stats := &models.AuthGuardStats{
+		Failures: g.failures,
+		Delayed:  g.delayed,
+		Rejected: g.rejected,
+		Bans:     g.bans,
+	}
+	for _, r := range g.sources {
+		if now.Before(r.bannedUntil) {
+			stats.ActiveBans++
+		}
+		if r.failures > 0 && now.Sub(r.windowStart) < g.cfg.Window {
+			stats.Tracked++
+		}
+	}
+	return stats
+}
+
+// check returns how long the source stays banned, or else the delay it
+// owes for its failures
+func (g *Guard) check(source string) (retryAfter, delay time.Duration) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	r, ok := g.sources[source]
+	if !ok {
+		return 0, 0
+	}
+	now := time.Now()
+	retryAfter = r.bannedUntil.Sub(now)
+	delay = g.delayFor(r, now)
+
+	if retryAfter > 0 {
+		g.rejected++
+		return retryAfter, 0
+	}
+	if delay > 0 {
+		g.delayed++
+	}
+	return 0, delay
+}
+
+// delayFor returns the delay owed by a source (caller holds lock)
+func (g *Guard) delayFor(r *record, now time.Time) time.Duration {
+	if g.cfg.BaseDelay <= 0 || now.Sub(r.windowStart) >= g.cfg.Window {
+		return 0
+	}
+	excess := r.failures - g.cfg.FreeFailures
+	if excess <= 0 {
+		return 0
+	}
+
+	delay := g.cfg.BaseDelay
+	for i := 1; i < excess && delay < g.cfg.MaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > g.cfg.MaxDelay {
+		delay = g.cfg.MaxDelay
+	}
+	return delay
+}
+
+// fail counts a failed authentication against the source and bans it once
+// it reached the limit
+func (g *Guard) fail(c *gin.Context, source string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	now := time.Now()
+	g.failures++
+	if len(g.sources) >= maxTracked {
+		g.sweep(now)
+	}
+
+	r, ok := g.sources[source]
+	if !ok {
+		r = &record{}
+		g.sources[source] = r
+	}
+	if now.Sub(r.windowStart) >= g.cfg.Window {
+		r.failures = 0
+		r.windowStart = now
+		r.audited = false
+	}
+	r.failures++
+
+	if !r.audited {
+		r.audited = true
+		g.auditFailure(c, source, now)
+	}
+
+	if g.cfg.BanAfter <= 0 || r.failures < g.cfg.BanAfter {
+		return
+	}
+
+	duration := g.cfg.BanDuration
+	for i := 0; i < r.bans && duration < g.cfg.MaxBanDuration; i++ {
+		duration *= 2
+	}
+	if duration > g.cfg.MaxBanDuration {
+		duration = g.cfg.MaxBanDuration
+	}
+	r.bans++
+	r.bannedUntil = now.Add(duration)
+	r.failures = 0
+	r.windowStart = now
+	r.audited = false
+	g.bans++
+
+	log.WithFields(log.Fields{
+		"source":   source,
+		"duration": duration.String(),
+		"bans":     r.bans,
+	}).Warn("Banned source after repeated authentication failures")
+
+	g.audit.Record(audit.Entry{
+		Action: "auth.ban",
+		IP:     g.clientIP(c),
+		Detail: map[string]interface{}{
+			"source":     source,
+			"duration_s": int(duration.Seconds()),
+			"ban_count":  r.bans,
+		},
+	})
+}
+
+// auditFailure writes a failure to the audit log unless this minute's
+// allowance is used up; the next entry written reports how many were
+// skipped (caller holds lock)
+func (g *Guard) auditFailure(c *gin.Context, source string, now time.Time) {
+	if now.Sub(g.auditMinute) >= time.Minute {
+		g.auditMinute = now
+		g.audits = 0
+	}
+	if g.audits >= maxFailureAudits {
+		g.suppressed++
+		return
+	}
+	g.audits++
+
+	detail := map[string]interface{}{
+		"source": source,
+		"method": c.Request.Method,
+		"path":   c.Request.URL.Path,
+	}
+	if g.suppressed > 0 {
+		detail["suppressed"] = g.suppressed
+		g.suppressed = 0
+	}
+	g.audit.Record(audit.Entry{
+		Action: "auth.failure",
+		IP:     g.clientIP(c),
+		Detail: detail,
+	})
+}
+
+// succeed forgets the failures of sources that authenticated; ban history
+// is kept so a source that is banned again gets a longer ban
+func (g *Guard) succeed(source string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if r, ok := g.sources[source]; ok {
+		r.failures = 0
+	}
+}
+
+// sweep drops records whose failures and bans have expired (caller holds lock)
+func (g *Guard) sweep(now time.Time) {
+	for source, r := range g.sources {
+		if now.Sub(r.windowStart) >= g.cfg.Window && now.After(r.bannedUntil) {
+			delete(g.sources, source)
+		}
+	}
+}
+
+// requestSource identifies where a request comes from by its client
+// address
+func (g *Guard) requestSource(c *gin.Context) string {
+	return "ip:" + addressGroup(g.clientIP(c))
+}
+
+// clientIP returns the address of the connection, or the client address
+// reported by trusted proxies when they are configured
+func (g *Guard) clientIP(c *gin.Context) string {
+	if g.cfg.TrustProxyHeaders {
+		return c.ClientIP()
+	}
+	return c.RemoteIP()
+}
+
+// addressGroup returns an IPv4 address as is and an IPv6 address as its /64
+func addressGroup(ip string) string {
+	parsed := net.ParseIP(ip)
+	if parsed == nil || parsed.To4() != nil {
+		return ip
+	}
+	network := net.IPNet{IP: parsed.Mask(net.CIDRMask(64, 128)), Mask: net.CIDRMask(64, 128)}
+	return network.String()
+}
@@ -0,0 +1,384 @@
+package backup
+
+import (
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/zhishengyuan/searchgram-engine/engines"
+	"github.com/zhishengyuan/searchgram-engine/models"
+	"github.com/zhishengyuan/searchgram-engine/storage"
+)
+
+// DefaultSegmentSize is the number of messages per segment file
+const DefaultSegmentSize = 100000
+
+const (
+	manifestFile = "manifest.json"
+	stateDir     = "state"
+)
+
+// stateFiles are the saved searches kept in the state storage: search
+// profiles and keyword subscriptions, plus the tags of Saved Messages
+var stateFiles = []string{"profiles.json", "subscriptions.json", "saved_tags.json"}
+
+// Options configures Create
+type Options struct {
+	Engine      string               // Engine name recorded in the manifest
+	Index       string               // Index name recorded in the manifest
+	State       storage.Backend      // Where the saved searches are read from
+	SegmentSize int                  // Messages per segment (0 = DefaultSegmentSize)
+	Progress    func(messages int64) // Optional, called after each page
+}
+
+// Create writes a logical backup of every stored message, soft-deleted ones
+// included, to dir, which must not exist yet. Messages are read through a
+// single point-in-time scan, so the backup is consistent even while writes
+// continue. The backup is assembled in dir.partial and only renamed to dir
+// once complete.
+func Create(ctx context.Context, engine engines.SearchEngine, dir string, opts Options) (*models.BackupManifest, error) {
+	if _, err := os.Stat(dir); err == nil {
+		return nil, fmt.Errorf("backup %s already exists", dir)
+	}
+	if opts.SegmentSize <= 0 {
+		opts.SegmentSize = DefaultSegmentSize
+	}
+
+	partial := dir + ".partial"
+	if err := os.RemoveAll(partial); err != nil {
+		return nil, fmt.Errorf("failed to remove incomplete backup: %w", err)
+	}
+	if err := os.MkdirAll(partial, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create backup directory: %w", err)
+	}
+
+	manifest, err := create(ctx, engine, partial, opts)
+	if err != nil {
+		os.RemoveAll(partial)
+		return nil, err
+	}
+	if err := os.Rename(partial, dir); err != nil {
+		return nil, fmt.Errorf("failed to finish backup: %w", err)
+	}
+
+	log.WithFields(log.Fields{
+		"path":     dir,
+		"messages": manifest.Messages,
+		"segments": len(manifest.Segments),
+		"chats":    manifest.Chats.Lines,
+		"users":    manifest.Users.Lines,
+	}).Info("Backup created")
+
+	return manifest, nil
+}
+
+// create fills dir with segments, registries, state and the manifest
+func create(ctx context.Context, engine engines.SearchEngine, dir string, opts Options) (*models.BackupManifest, error) {
+	manifest := &models.BackupManifest{
+		Format:    models.BackupFormat,
+		Version:   models.BackupVersion,
+		CreatedAt: time.Now().Unix(),
+		Engine:    opts.Engine,
+		Index:     opts.Index,
+		Segments:  []models.BackupSegment{},
+		State:     []string{},
+	}
+	registry := newRegistry()
+
+	var segment *segmentWriter
+	finish := func() error {
+		if segment == nil {
+			return nil
+		}
+		written, err := segment.Close()
+		segment = nil
+		if err != nil {
+			return err
+		}
+		manifest.Segments = append(manifest.Segments, written)
+		return nil
+	}
+
+	err := engine.ScanMessages(ctx, func(messages []models.Message) error {
+		for i := range messages {
+			if segment != nil && segment.lines >= int64(opts.SegmentSize) {
+				if err := finish(); err != nil {
+					return err
+				}
+			}
+			if segment == nil {
+				var err error
+				name := fmt.Sprintf("messages-%06d.ndjson.gz", len(manifest.Segments)+1)
+				if segment, err = newSegmentWriter(dir, name); err != nil {
+					return err
+				}
+			}
+			if err := segment.Write(&messages[i]); err != nil {
+				return err
+			}
+			registry.add(&messages[i])
+			manifest.Messages++
+		}
+
+		if opts.Progress != nil {
+			opts.Progress(manifest.Messages)
+		}
+		return ctx.Err()
+	})
+	if err == nil {
+		err = finish()
+	}
+	if err != nil {
+		if segment != nil {
+			segment.Close()
+		}
+		return nil, err
+	}
+
+	if manifest.Chats, err = writeRecords(dir, "chats.ndjson.gz", registry.chatList()); err != nil {
+		return nil, err
+	}
+	if manifest.Users, err = writeRecords(dir, "users.ndjson.gz", registry.userList()); err != nil {
+		return nil, err
+	}
+
+	for _, name := range stateFiles {
+		copied, err := exportState(opts.State, name, filepath.Join(dir, stateDir, name))
+		if err != nil {
+			return nil, err
+		}
+		if copied {
+			manifest.State = append(manifest.State, name)
+		}
+	}
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode manifest: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, manifestFile), data, 0o644); err != nil {
+		return nil, fmt.Errorf("failed to write manifest: %w", err)
+	}
+	return manifest, nil
+}
+
+// ReadManifest reads and checks the manifest of a backup
+func ReadManifest(dir string) (*models.BackupManifest, error) {
+	data, err := os.ReadFile(filepath.Join(dir, manifestFile))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read backup manifest: %w", err)
+	}
+
+	var manifest models.BackupManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("failed to decode backup manifest: %w", err)
+	}
+	if manifest.Format != models.BackupFormat {
+		return nil, fmt.Errorf("%s is not a SearchGram backup", dir)
+	}
+	if manifest.Version > models.BackupVersion {
+		return nil, fmt.Errorf("backup version %d is newer than supported version %d", manifest.Version, models.BackupVersion)
+	}
+	return &manifest, nil
+}
+
+// Verify checks every file of a backup against its manifest checksum
+func Verify(dir string, manifest *models.BackupManifest) error {
+	files := append([]models.BackupSegment{}, manifest.Segments...)
+	files = append(files, manifest.Chats, manifest.Users)
+
+	for _, segment := range files {
+		f, err := os.Open(filepath.Join(dir, segment.File))
+		if err != nil {
+			return fmt.Errorf("failed to open %s: %w", segment.File, err)
+		}
+		sum := sha256.New()
+		_, err = io.Copy(sum, f)
+		f.Close()
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", segment.File, err)
+		}
+		if hex.EncodeToString(sum.Sum(nil)) != segment.SHA256 {
+			return fmt.Errorf("%s does not match its checksum", segment.File)
+		}
+	}
+	return nil
+}
+
+// segmentWriter writes records as gzip-compressed NDJSON and checksums the
+// compressed bytes
+type segmentWriter struct {
+	name  string
+	file  *os.File
+	sum   hash.Hash
+	gzip  *gzip.Writer
+	enc   *json.Encoder
+	lines int64
+}
+
+// newSegmentWriter creates name in dir
+func newSegmentWriter(dir, name string) (*segmentWriter, error) {
+	f, err := os.Create(filepath.Join(dir, name))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %s: %w", name, err)
+	}
+
+	w := &segmentWriter{name: name, file: f, sum: sha256.New()}
+	w.gzip = gzip.NewWriter(io.MultiWriter(f, w.sum))
+	w.enc = json.NewEncoder(w.gzip)
+	w.enc.SetEscapeHTML(false)
+	return w, nil
+}
+
+// Write appends one record as a line
+func (w *segmentWriter) Write(record interface{}) error {
+	if err := w.enc.Encode(record); err != nil {
+		return fmt.Errorf("failed to write %s: %w", w.name, err)
+	}
+	w.lines++
+	return nil
+}
+
+// Close flushes the file to disk and describes it
+func (w *segmentWriter) Close() (models.BackupSegment, error) {
+	err := w.gzip.Close()
+	if err == nil {
+		err = w.file.Sync()
+	}
+	if closeErr := w.file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return models.BackupSegment{}, fmt.Errorf("failed to write %s: %w", w.name, err)
+	}
+
+	return models.BackupSegment{
+		File:   w.name,
+		Lines:  w.lines,
+		SHA256: hex.EncodeToString(w.sum.Sum(nil)),
+	}, nil
+}
+
+// writeRecords writes a whole registry as one segment
+func writeRecords(dir, name string, records []interface{}) (models.BackupSegment, error) {
+	w, err := newSegmentWriter(dir, name)
+	if err != nil {
+		return models.BackupSegment{}, err
+	}
+	for _, record := range records {
+		if err := w.Write(record); err != nil {
+			w.Close()
+			return models.BackupSegment{}, err
+		}
+	}
+	return w.Close()
+}
+
+// exportState copies the state document name to dst, reporting false if
+// it was never stored
+func exportState(state storage.Backend, name, dst string) (bool, error) {
+	data, err := state.Get(name)
+	if errors.Is(err, storage.ErrNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to read %s: %w", state.Location(name), err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		return false, fmt.Errorf("failed to create directory for %s: %w", dst, err)
+	}
+	if err := os.WriteFile(dst, data, 0o644); err != nil {
+		return false, fmt.Errorf("failed to write %s: %w", dst, err)
+	}
+	return true, nil
+}
+
+// registry collects the chats and users seen in a backup
+type registry struct {
+	chats map[int64]*models.ChatSummary
+	users map[int64]*models.UserSummary
+}
+
+func newRegistry() *registry {
+	return &registry{
+		chats: make(map[int64]*models.ChatSummary),
+		users: make(map[int64]*models.UserSummary),
+	}
+}
+
+// add records a message; names are taken from the latest message
+func (r *registry) add(message *models.Message) {
+	chat, ok := r.chats[message.ChatID]
+	if !ok {
+		chat = &models.ChatSummary{ChatID: message.ChatID}
+		r.chats[message.ChatID] = chat
+	}
+	if message.Timestamp >= chat.LastMessageAt {
+		chat.LastMessageAt = message.Timestamp
+		chat.ChatType = message.ChatType
+		chat.ChatTitle = message.ChatTitle
+		chat.ChatUsername = message.ChatUsername
+	}
+	if !message.IsDeleted {
+		chat.MessageCount++
+	}
+
+	if message.SenderType != "user" || message.SenderID == 0 {
+		return
+	}
+	user, ok := r.users[message.SenderID]
+	if !ok {
+		user = &models.UserSummary{UserID: message.SenderID}
+		r.users[message.SenderID] = user
+	}
+	if message.Timestamp >= user.LastMessageAt {
+		user.LastMessageAt = message.Timestamp
+		user.SenderName = message.SenderName
+		user.Username = message.SenderUsername
+	}
+	if !message.IsDeleted {
+		user.MessageCount++
+	}
+}
+
+// chatList returns the chats ordered by ID
+func (r *registry) chatList() []interface{} {
+	ids := make([]int64, 0, len(r.chats))
+	for id := range r.chats {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	list := make([]interface{}, len(ids))
+	for i, id := range ids {
+		list[i] = r.chats[id]
+	}
+	return list
+}
+
+// userList returns the users ordered by ID
+func (r *registry) userList() []interface{} {
+	ids := make([]int64, 0, len(r.users))
+	for id := range r.users {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	list := make([]interface{}, len(ids))
+	for i, id := range ids {
+		list[i] = r.users[id]
+	}
+	return list
+}
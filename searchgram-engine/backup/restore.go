@@ -0,0 +1,178 @@
+package backup
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/zhishengyuan/searchgram-engine/engines"
+	"github.com/zhishengyuan/searchgram-engine/models"
+	"github.com/zhishengyuan/searchgram-engine/storage"
+)
+
+// DefaultBatchSize is the number of messages per bulk write when restoring
+const DefaultBatchSize = 1000
+
+// maxRestoreFailures limits the failures a restore reports
+const maxRestoreFailures = 100
+
+// ErrNotEmpty is returned when restoring into an index that holds messages
+// or over existing state files without RestoreOptions.Replace
+var ErrNotEmpty = errors.New("restore target is not empty")
+
+// RestoreOptions configures Restore
+type RestoreOptions struct {
+	State          storage.Backend                      // Where saved searches are written
+	BatchSize      int                                  // Messages per bulk write (0 = DefaultBatchSize)
+	Replace        bool                                 // Recreate a non-empty index and overwrite state files
+	DropEmbeddings bool                                 // Leave out embeddings, e.g. when the target has semantic search off
+	ApplyState     func(name string, data []byte) error // Optional, stores a state file instead of writing it to State
+	Progress       func(restored int64)                 // Optional, called after each batch
+}
+
+// Restore verifies a backup and imports it: the index is recreated with the
+// engine's current mappings when Replace is set, then every message is
+// indexed as stored, without running the ingest pipeline again. Saved
+// searches are written to the state storage and are picked up on the next
+// start, so the engine serving the API should be stopped meanwhile.
+func Restore(ctx context.Context, engine engines.SearchEngine, dir string, opts RestoreOptions) (*models.RestoreResult, error) {
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = DefaultBatchSize
+	}
+
+	manifest, err := ReadManifest(dir)
+	if err != nil {
+		return nil, err
+	}
+	if err := Verify(dir, manifest); err != nil {
+		return nil, err
+	}
+
+	stats, err := engine.Stats(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count messages in the target: %w", err)
+	}
+	if !opts.Replace {
+		if stats.TotalDocuments > 0 {
+			return nil, fmt.Errorf("%w: the index holds %d messages", ErrNotEmpty, stats.TotalDocuments)
+		}
+		for _, name := range manifest.State {
+			if opts.ApplyState == nil {
+				if _, err := opts.State.Get(name); err == nil {
+					return nil, fmt.Errorf("%w: %s exists", ErrNotEmpty, opts.State.Location(name))
+				}
+			}
+		}
+	}
+
+	if opts.Replace {
+		if err := engine.RecreateIndex(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	result := &models.RestoreResult{}
+	for _, segment := range manifest.Segments {
+		if err := restoreSegment(ctx, engine, filepath.Join(dir, segment.File), opts, result); err != nil {
+			return result, fmt.Errorf("failed to restore %s: %w", segment.File, err)
+		}
+	}
+
+	for _, name := range manifest.State {
+		path := filepath.Join(dir, stateDir, name)
+		data, err := os.ReadFile(path)
+		if err == nil {
+			if opts.ApplyState != nil {
+				err = opts.ApplyState(name, data)
+			} else {
+				err = opts.State.Put(name, data)
+			}
+		}
+		if err != nil {
+			return result, fmt.Errorf("failed to restore %s: %w", name, err)
+		}
+		result.State = append(result.State, name)
+	}
+
+	log.WithFields(log.Fields{
+		"path":     dir,
+		"restored": result.RestoredCount,
+		"failed":   result.FailedCount,
+		"state":    result.State,
+	}).Info("Backup restored")
+
+	return result, nil
+}
+
+// restoreSegment indexes the messages of one segment in batches
+func restoreSegment(ctx context.Context, engine engines.SearchEngine, path string, opts RestoreOptions, result *models.RestoreResult) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	gz, err := gzip.NewReader(bufio.NewReader(f))
+	if err != nil {
+		return err
+	}
+	defer gz.Close()
+
+	dec := json.NewDecoder(gz)
+	batch := make([]models.Message, 0, opts.BatchSize)
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		indexed, failures, err := engine.UpsertBatch(ctx, batch)
+		if err != nil {
+			return err
+		}
+		result.RestoredCount += int64(indexed)
+		result.FailedCount += int64(len(batch) - indexed)
+		for _, failure := range failures {
+			if len(result.Failures) < maxRestoreFailures {
+				result.Failures = append(result.Failures, failure)
+			}
+		}
+		batch = batch[:0]
+
+		if opts.Progress != nil {
+			opts.Progress(result.RestoredCount)
+		}
+		return nil
+	}
+
+	for {
+		var message models.Message
+		err := dec.Decode(&message)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("failed to decode message: %w", err)
+		}
+		if opts.DropEmbeddings {
+			message.Embedding = nil
+		}
+
+		batch = append(batch, message)
+		if len(batch) >= opts.BatchSize {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+	}
+	return flush()
+}
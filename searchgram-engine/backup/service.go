@@ -0,0 +1,242 @@
+package backup
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/zhishengyuan/searchgram-engine/engines"
+	"github.com/zhishengyuan/searchgram-engine/models"
+	"github.com/zhishengyuan/searchgram-engine/s3"
+	"github.com/zhishengyuan/searchgram-engine/storage"
+)
+
+// nameTimeLayout names logical backups like snapshots: index and UTC time
+const nameTimeLayout = "20060102-150405"
+
+// ServiceConfig holds the settings of backups started via the API
+type ServiceConfig struct {
+	Type        string          // models.BackupTypeNDJSON or models.BackupTypeSnapshot
+	Engine      string          // Engine name recorded in manifests
+	Index       string          // Index name recorded in manifests and backup names
+	Path        string          // ndjson: where backups are written (staging area with S3)
+	SegmentSize int             // ndjson: messages per segment
+	Repository  string          // snapshot: snapshot repository
+	S3Prefix    string          // ndjson with S3: key prefix of backups
+	State       storage.Backend // Where saved searches are read from
+
+	DropEmbeddings bool // Leave out embeddings on restore, e.g. with semantic search off
+}
+
+// Service backs up and restores the index through the configured target:
+// logical backups in a directory or bucket, or engine-native snapshots
+type Service struct {
+	engine engines.SearchEngine
+	cfg    ServiceConfig
+	bucket *s3.Client // nil = logical backups stay in cfg.Path
+}
+
+// NewService creates a backup service; bucket may be nil
+func NewService(engine engines.SearchEngine, cfg ServiceConfig, bucket *s3.Client) *Service {
+	cfg.S3Prefix = strings.Trim(cfg.S3Prefix, "/")
+	return &Service{engine: engine, cfg: cfg, bucket: bucket}
+}
+
+// Type returns the configured backup type
+func (s *Service) Type() string {
+	return s.cfg.Type
+}
+
+// Backup creates a backup named after the index and the current time
+func (s *Service) Backup(ctx context.Context, progress func(*models.BackupProgress)) (*models.BackupResult, error) {
+	if s.cfg.Type == models.BackupTypeSnapshot {
+		progress(&models.BackupProgress{Phase: "snapshotting"})
+		snapshot, err := s.engine.Snapshot(ctx, s.cfg.Repository, 0)
+		if err != nil {
+			return nil, err
+		}
+		return &models.BackupResult{
+			Type:     models.BackupTypeSnapshot,
+			Name:     snapshot.Snapshot,
+			Location: s.cfg.Repository,
+			Snapshot: snapshot,
+		}, nil
+	}
+
+	name := strings.ToLower(s.cfg.Index) + "-" + time.Now().UTC().Format(nameTimeLayout)
+	dir := filepath.Join(s.cfg.Path, name)
+
+	manifest, err := Create(ctx, s.engine, dir, Options{
+		Engine:      s.cfg.Engine,
+		Index:       s.cfg.Index,
+		State:       s.cfg.State,
+		SegmentSize: s.cfg.SegmentSize,
+		Progress: func(messages int64) {
+			progress(&models.BackupProgress{Phase: "exporting", Messages: messages})
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	result := &models.BackupResult{
+		Type:     models.BackupTypeNDJSON,
+		Name:     name,
+		Location: dir,
+		Manifest: manifest,
+	}
+	if s.bucket == nil {
+		return result, nil
+	}
+
+	// The local copy only stages the upload
+	defer os.RemoveAll(dir)
+	if err := s.upload(ctx, dir, s.key(name), manifest.Messages, progress); err != nil {
+		return nil, err
+	}
+	result.Location = fmt.Sprintf("s3://%s/%s/", s.bucket.Bucket(), s.key(name))
+	return result, nil
+}
+
+// Restore restores the named backup. Logical backups are imported through
+// the engine and their saved searches passed to applyState; snapshots
+// replace the index with the engine's own restore.
+func (s *Service) Restore(ctx context.Context, name string, replace bool, applyState func(name string, data []byte) error, progress func(*models.BackupProgress)) (*models.RestoreResult, error) {
+	if !ValidName(name) {
+		return nil, fmt.Errorf("invalid backup name %q", name)
+	}
+
+	if s.cfg.Type == models.BackupTypeSnapshot {
+		progress(&models.BackupProgress{Phase: "restoring"})
+		if err := s.engine.RestoreSnapshot(ctx, s.cfg.Repository, name); err != nil {
+			return nil, err
+		}
+		return &models.RestoreResult{Snapshot: name}, nil
+	}
+
+	dir := filepath.Join(s.cfg.Path, name)
+	if s.bucket != nil {
+		dir = filepath.Join(s.cfg.Path, name+".download")
+		defer os.RemoveAll(dir)
+		if err := s.download(ctx, s.key(name), dir, progress); err != nil {
+			return nil, err
+		}
+	}
+
+	return Restore(ctx, s.engine, dir, RestoreOptions{
+		Replace:        replace,
+		DropEmbeddings: s.cfg.DropEmbeddings,
+		ApplyState:     applyState,
+		Progress: func(restored int64) {
+			progress(&models.BackupProgress{Phase: "restoring", Messages: restored})
+		},
+	})
+}
+
+// ValidName reports whether name can name a backup: no path separators or
+// parent references
+func ValidName(name string) bool {
+	return name != "" && name != "." && name != ".." && !strings.ContainsAny(name, `/\`)
+}
+
+// key returns the object key prefix of a backup in the bucket
+func (s *Service) key(name string) string {
+	if s.cfg.S3Prefix == "" {
+		return name
+	}
+	return s.cfg.S3Prefix + "/" + name
+}
+
+// upload copies every file of a backup directory below prefix
+func (s *Service) upload(ctx context.Context, dir, prefix string, messages int64, progress func(*models.BackupProgress)) error {
+	var files []string
+	err := filepath.Walk(dir, func(file string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() {
+			files = append(files, file)
+		}
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to list backup files: %w", err)
+	}
+
+	// The manifest goes last, so an interrupted upload has none
+	for i, file := range files {
+		if filepath.Base(file) == manifestFile {
+			files = append(append(files[:i:i], files[i+1:]...), file)
+			break
+		}
+	}
+
+	for i, file := range files {
+		rel, err := filepath.Rel(dir, file)
+		if err != nil {
+			return err
+		}
+		if err := s.bucket.PutFile(ctx, prefix+"/"+filepath.ToSlash(rel), file); err != nil {
+			return err
+		}
+		progress(&models.BackupProgress{Phase: "uploading", Messages: messages, Files: i + 1})
+	}
+
+	log.WithFields(log.Fields{
+		"bucket": s.bucket.Bucket(),
+		"prefix": prefix,
+		"files":  len(files),
+	}).Info("Backup uploaded")
+	return nil
+}
+
+// download copies every object below prefix into dir
+func (s *Service) download(ctx context.Context, prefix, dir string, progress func(*models.BackupProgress)) error {
+	objects, _, err := s.bucket.List(ctx, prefix+"/", "")
+	if err != nil {
+		return err
+	}
+	if len(objects) == 0 {
+		return fmt.Errorf("backup %s not found in bucket %s", path.Base(prefix), s.bucket.Bucket())
+	}
+
+	if err := os.RemoveAll(dir); err != nil {
+		return err
+	}
+	for i, object := range objects {
+		rel := strings.TrimPrefix(object.Key, prefix+"/")
+		if rel == "" || strings.Contains(rel, "..") {
+			continue
+		}
+		if err := s.fetch(ctx, object.Key, filepath.Join(dir, filepath.FromSlash(rel))); err != nil {
+			return err
+		}
+		progress(&models.BackupProgress{Phase: "downloading", Files: i + 1})
+	}
+	return nil
+}
+
+// fetch writes one object to a local file
+func (s *Service) fetch(ctx context.Context, key, file string) error {
+	body, err := s.bucket.Get(ctx, key)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	if err := os.MkdirAll(filepath.Dir(file), 0o755); err != nil {
+		return err
+	}
+	f, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, body); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to download %s: %w", key, err)
+	}
+	return f.Close()
+}
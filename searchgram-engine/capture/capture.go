@@ -0,0 +1,300 @@
+package capture
+
+import (
+	"fmt"
+	"hash/fnv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	log "github.com/sirupsen/logrus"
+	"github.com/zhishengyuan/searchgram-engine/models"
+	"github.com/zhishengyuan/searchgram-engine/storage"
+)
+
+// DefaultPolicy captures everything and keeps it forever
+var DefaultPolicy = models.CapturePolicy{
+	Capture:    true,
+	SampleRate: 1,
+}
+
+// ErrRuleNotFound is returned when a rule ID does not exist
+var ErrRuleNotFound = fmt.Errorf("capture rule not found")
+
+// Store holds the capture rule set and persists it on change
+type Store struct {
+	mu    sync.RWMutex
+	rules models.CaptureRuleSet
+	file  *storage.Document
+}
+
+// NewStore loads the rule set from file, falling back to the default policy
+func NewStore(file *storage.Document) (*Store, error) {
+	s := &Store{
+		rules: models.CaptureRuleSet{
+			Version: 1,
+			Default: DefaultPolicy,
+			Rules:   []models.CaptureRule{},
+		},
+		file: file,
+	}
+
+	if err := file.Load(&s.rules); err != nil {
+		return nil, err
+	}
+	if s.rules.Rules == nil {
+		s.rules.Rules = []models.CaptureRule{}
+	}
+
+	log.WithFields(log.Fields{
+		"path":    file.Path(),
+		"rules":   len(s.rules.Rules),
+		"version": s.rules.Version,
+	}).Info("Capture rules loaded")
+
+	return s, nil
+}
+
+// Get returns a copy of the current rule set
+func (s *Store) Get() models.CaptureRuleSet {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return copyRuleSet(s.rules)
+}
+
+// Replace validates and stores a complete rule set
+func (s *Store) Replace(set models.CaptureRuleSet) (models.CaptureRuleSet, error) {
+	if set.Rules == nil {
+		set.Rules = []models.CaptureRule{}
+	}
+	for i := range set.Rules {
+		if set.Rules[i].ID == "" {
+			set.Rules[i].ID = uuid.New().String()
+		}
+	}
+	if err := Validate(set); err != nil {
+		return models.CaptureRuleSet{}, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	set.Version = s.rules.Version
+	return s.commit(set)
+}
+
+// AddRule appends a rule to the rule set
+func (s *Store) AddRule(rule models.CaptureRule) (models.CaptureRule, error) {
+	if rule.ID == "" {
+		rule.ID = uuid.New().String()
+	}
+	if err := validateRule(rule); err != nil {
+		return models.CaptureRule{}, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, existing := range s.rules.Rules {
+		if existing.ID == rule.ID {
+			return models.CaptureRule{}, fmt.Errorf("capture rule %s already exists", rule.ID)
+		}
+	}
+
+	set := copyRuleSet(s.rules)
+	set.Rules = append(set.Rules, rule)
+	if _, err := s.commit(set); err != nil {
+		return models.CaptureRule{}, err
+	}
+	return rule, nil
+}
+
+// DeleteRule removes a rule by ID
+func (s *Store) DeleteRule(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	set := copyRuleSet(s.rules)
+	for i, rule := range set.Rules {
+		if rule.ID == id {
+			set.Rules = append(set.Rules[:i], set.Rules[i+1:]...)
+			_, err := s.commit(set)
+			return err
+		}
+	}
+	return ErrRuleNotFound
+}
+
+// commit bumps the version, persists and swaps in the rule set (caller holds lock)
+func (s *Store) commit(set models.CaptureRuleSet) (models.CaptureRuleSet, error) {
+	set.Version++
+	set.UpdatedAt = time.Now().Unix()
+
+	if err := s.file.Save(set); err != nil {
+		return models.CaptureRuleSet{}, err
+	}
+	s.rules = set
+
+	log.WithFields(log.Fields{
+		"rules":   len(set.Rules),
+		"version": set.Version,
+	}).Info("Capture rules updated")
+
+	return copyRuleSet(set), nil
+}
+
+// Evaluate resolves the policy that applies to a chat
+func (s *Store) Evaluate(chatID int64, chatType string) models.CaptureDecision {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	decision := models.CaptureDecision{
+		ChatID:   chatID,
+		ChatType: chatType,
+		Policy:   s.rules.Default,
+		Version:  s.rules.Version,
+	}
+
+	for _, rule := range s.rules.Rules {
+		if matches(rule, chatID, chatType) {
+			decision.RuleID = rule.ID
+			decision.Policy = rule.Policy
+			break
+		}
+	}
+
+	return decision
+}
+
+// Allows reports whether a message should be indexed under the current rules
+func (s *Store) Allows(message *models.Message) bool {
+	chatID := message.ChatID
+	if chatID == 0 {
+		chatID = message.Chat.ID
+	}
+	chatType := message.ChatType
+	if chatType == "" {
+		chatType = message.Chat.Type
+	}
+
+	policy := s.Evaluate(chatID, chatType).Policy
+	if !policy.Capture {
+		return false
+	}
+
+	if len(policy.ContentTypes) > 0 && message.ContentType != "" {
+		allowed := false
+		for _, ct := range policy.ContentTypes {
+			if strings.EqualFold(ct, message.ContentType) {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return false
+		}
+	}
+
+	return sampled(chatID, message.MessageID, policy.SampleRate)
+}
+
+// sampled deterministically selects a message for indexing so that
+// every client and retry makes the same decision
+func sampled(chatID, messageID int64, rate float64) bool {
+	// Unset (0) and full rates index everything; use capture=false to drop a chat
+	if rate <= 0 || rate >= 1 {
+		return true
+	}
+
+	h := fnv.New64a()
+	fmt.Fprintf(h, "%d-%d", chatID, messageID)
+	return float64(h.Sum64()%10000) < rate*10000
+}
+
+// matches reports whether a rule's selectors match the chat
+func matches(rule models.CaptureRule, chatID int64, chatType string) bool {
+	if len(rule.ChatIDs) > 0 {
+		found := false
+		for _, id := range rule.ChatIDs {
+			if id == chatID {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	if len(rule.ChatTypes) > 0 {
+		found := false
+		for _, t := range rule.ChatTypes {
+			if strings.EqualFold(t, chatType) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Validate checks a rule set for invalid policies and selectors
+func Validate(set models.CaptureRuleSet) error {
+	if err := validatePolicy(set.Default); err != nil {
+		return fmt.Errorf("default policy: %w", err)
+	}
+
+	seen := make(map[string]bool)
+	for _, rule := range set.Rules {
+		if seen[rule.ID] {
+			return fmt.Errorf("duplicate capture rule ID: %s", rule.ID)
+		}
+		seen[rule.ID] = true
+
+		if err := validateRule(rule); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateRule checks a single rule
+func validateRule(rule models.CaptureRule) error {
+	if len(rule.ChatIDs) == 0 && len(rule.ChatTypes) == 0 {
+		return fmt.Errorf("rule %s: chat_ids or chat_types is required", rule.ID)
+	}
+	if err := validatePolicy(rule.Policy); err != nil {
+		return fmt.Errorf("rule %s: %w", rule.ID, err)
+	}
+	return nil
+}
+
+// validatePolicy checks policy bounds
+func validatePolicy(policy models.CapturePolicy) error {
+	if policy.SampleRate < 0 || policy.SampleRate > 1 {
+		return fmt.Errorf("sample_rate must be between 0 and 1")
+	}
+	if policy.RetentionDays < 0 {
+		return fmt.Errorf("retention_days must not be negative")
+	}
+	return nil
+}
+
+// copyRuleSet returns a deep copy so callers can't mutate stored state
+func copyRuleSet(set models.CaptureRuleSet) models.CaptureRuleSet {
+	out := set
+	out.Default.ContentTypes = append([]string(nil), set.Default.ContentTypes...)
+	out.Rules = make([]models.CaptureRule, len(set.Rules))
+	for i, rule := range set.Rules {
+		out.Rules[i] = rule
+		out.Rules[i].ChatIDs = append([]int64(nil), rule.ChatIDs...)
+		out.Rules[i].ChatTypes = append([]string(nil), rule.ChatTypes...)
+		out.Rules[i].Policy.ContentTypes = append([]string(nil), rule.Policy.ContentTypes...)
+	}
+	return out
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os/signal"
+	"syscall"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/zhishengyuan/searchgram-engine/backup"
+	"github.com/zhishengyuan/searchgram-engine/config"
+	"github.com/zhishengyuan/searchgram-engine/engines"
+)
+
+// progressInterval throttles progress logs of long-running commands
+const progressInterval = 10 * time.Second
+
+// runCommand runs a maintenance command given on the command line instead
+// of serving the API:
+//
+//	searchgram-engine backup [-segment-size N] <dir>
+//	searchgram-engine restore [-replace] [-batch-size N] <dir>
+func runCommand(cfg *config.Config, args []string) error {
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	// Saved searches are backed up from and restored to the state storage
+	state, err := stateBackend(cfg)
+	if err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "backup":
+		flags := flag.NewFlagSet("backup", flag.ContinueOnError)
+		segmentSize := flags.Int("segment-size", backup.DefaultSegmentSize, "messages per segment file")
+		if err := flags.Parse(args[1:]); err != nil {
+			return err
+		}
+		if flags.NArg() != 1 {
+			return fmt.Errorf("usage: backup [-segment-size N] <dir>")
+		}
+
+		engine, err := connectEngine(cfg)
+		if err != nil {
+			return err
+		}
+		defer engine.Close()
+
+		manifest, err := backup.Create(ctx, engine, flags.Arg(0), backup.Options{
+			Engine:      cfg.SearchEngine.Type,
+			Index:       cfg.Elasticsearch.Index,
+			State:       state,
+			SegmentSize: *segmentSize,
+			Progress:    logProgress("Backing up"),
+		})
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Backed up %d messages in %d segments, %d chats, %d users and %d state files to %s\n",
+			manifest.Messages, len(manifest.Segments), manifest.Chats.Lines, manifest.Users.Lines, len(manifest.State), flags.Arg(0))
+		return nil
+
+	case "restore":
+		flags := flag.NewFlagSet("restore", flag.ContinueOnError)
+		replace := flags.Bool("replace", false, "recreate a non-empty index and overwrite saved searches")
+		batchSize := flags.Int("batch-size", backup.DefaultBatchSize, "messages per bulk write")
+		if err := flags.Parse(args[1:]); err != nil {
+			return err
+		}
+		if flags.NArg() != 1 {
+			return fmt.Errorf("usage: restore [-replace] [-batch-size N] <dir>")
+		}
+
+		engine, err := connectEngine(cfg)
+		if err != nil {
+			return err
+		}
+		defer engine.Close()
+
+		result, err := backup.Restore(ctx, engine, flags.Arg(0), backup.RestoreOptions{
+			State:          state,
+			BatchSize:      *batchSize,
+			Replace:        *replace,
+			DropEmbeddings: !cfg.Embeddings.Enabled,
+			Progress:       logProgress("Restoring"),
+		})
+		if err != nil {
+			return err
+		}
+		for _, failure := range result.Failures {
+			log.Warn(failure)
+		}
+		fmt.Printf("Restored %d messages (%d failed) and %d state files from %s\n",
+			result.RestoredCount, result.FailedCount, len(result.State), flags.Arg(0))
+		return nil
+
+	default:
+		return fmt.Errorf("unknown command %q (supported: backup, restore)", args[0])
+	}
+}
+
+// connectEngine connects to the configured search engine once
+func connectEngine(cfg *config.Config) (engines.SearchEngine, error) {
+	switch cfg.SearchEngine.Type {
+	case "elasticsearch":
+		es, err := engines.NewElasticsearch(
+			cfg.Elasticsearch.Host,
+			cfg.Elasticsearch.Username,
+			cfg.Elasticsearch.Password,
+			cfg.Elasticsearch.Index,
+			cfg.Elasticsearch.Shards,
+			cfg.Elasticsearch.Replicas,
+			engines.PartitionConfig{
+				Period:    cfg.Elasticsearch.Partition,
+				ILMPolicy: cfg.Elasticsearch.ILMPolicy,
+			},
+			engines.AnalysisConfig{
+				ChineseFolding: cfg.Elasticsearch.ChineseFolding,
+			},
+		)
+		if err == nil && cfg.Embeddings.Enabled {
+			err = es.EnableEmbeddings(cfg.Embeddings.Dimensions)
+		}
+		if err == nil {
+			err = enableEncryption(cfg, es)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to initialize Elasticsearch: %w", err)
+		}
+		return es, nil
+	default:
+		return nil, fmt.Errorf("unsupported search engine type: %s", cfg.SearchEngine.Type)
+	}
+}
+
+// logProgress logs a running message count at most every progressInterval
+func logProgress(action string) func(messages int64) {
+	var last time.Time
+	return func(messages int64) {
+		if time.Since(last) < progressInterval {
+			return
+		}
+		last = time.Now()
+		log.WithField("messages", messages).Info(action)
+	}
+}
@@ -1,12 +1,23 @@
 package config
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
+	"regexp"
+	"slices"
 	"strings"
 	"time"
 
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/viper"
+	"github.com/zhishengyuan/searchgram-engine/enrich"
+	"github.com/zhishengyuan/searchgram-engine/fieldcrypt"
+	"github.com/zhishengyuan/searchgram-engine/i18n"
+	"github.com/zhishengyuan/searchgram-engine/middleware"
+	"github.com/zhishengyuan/searchgram-engine/models"
+	"github.com/zhishengyuan/searchgram-engine/scheduler"
+	"github.com/zhishengyuan/searchgram-engine/translit"
 )
 
 // Config holds all configuration for the search service
@@ -16,15 +27,89 @@ type Config struct {
 	Elasticsearch ElasticsearchConfig `mapstructure:"elasticsearch" json:"elasticsearch"`
 	Auth          AuthConfig          `mapstructure:"auth" json:"auth"`
 	Logging       LoggingConfig       `mapstructure:"logging" json:"logging"`
+	Tracing       TracingConfig       `mapstructure:"tracing" json:"tracing"`
+	Health        HealthConfig        `mapstructure:"health" json:"health"`
 	Cache         CacheConfig         `mapstructure:"cache" json:"cache"`
+	Usage         UsageConfig         `mapstructure:"usage" json:"usage"`
+	Storage       StorageConfig       `mapstructure:"storage" json:"storage"`
+	Capture       CaptureConfig       `mapstructure:"capture" json:"capture"`
+	Subscriptions SubscriptionsConfig `mapstructure:"subscriptions" json:"subscriptions"`
+	Time          TimeConfig          `mapstructure:"time" json:"time"`
+	Ingest        IngestConfig        `mapstructure:"ingest" json:"ingest"`
+	Timeouts      TimeoutsConfig      `mapstructure:"timeouts" json:"timeouts"`
+	Concurrency   ConcurrencyConfig   `mapstructure:"concurrency" json:"concurrency"`
+	RateLimit     RateLimitConfig     `mapstructure:"rate_limit" json:"rate_limit"`
+	Degradation   DegradationConfig   `mapstructure:"degradation" json:"degradation"`
+	I18n          I18nConfig          `mapstructure:"i18n" json:"i18n"`
+	Response      ResponseConfig      `mapstructure:"response" json:"response"`
+	Guardrails    GuardrailsConfig    `mapstructure:"guardrails" json:"guardrails"`
+	RecycleBin    RecycleBinConfig    `mapstructure:"recycle_bin" json:"recycle_bin"`
+	SoftDelete    SoftDeleteConfig    `mapstructure:"soft_delete" json:"soft_delete"`
+	Profiles      ProfilesConfig      `mapstructure:"profiles" json:"profiles"`
+	Transcription TranscriptionConfig `mapstructure:"transcription" json:"transcription"`
+	OCR           OCRConfig           `mapstructure:"ocr" json:"ocr"`
+	Media         MediaConfig         `mapstructure:"media" json:"media"`
+	Routes        RoutesConfig        `mapstructure:"routes" json:"routes"`
+	Admin         AdminConfig         `mapstructure:"admin" json:"admin"`
+	Embeddings    EmbeddingsConfig    `mapstructure:"embeddings" json:"embeddings"`
+	Rerank        RerankConfig        `mapstructure:"rerank" json:"rerank"`
+	AuthGuard     AuthGuardConfig     `mapstructure:"auth_guard" json:"auth_guard"`
+	Audit         AuditConfig         `mapstructure:"audit" json:"audit"`
+	Translation   TranslationConfig   `mapstructure:"translation" json:"translation"`
+	Sessions      SessionsConfig      `mapstructure:"sessions" json:"sessions"`
+	SignedURLs    SignedURLsConfig    `mapstructure:"signed_urls" json:"signed_urls"`
+	Spam          SpamConfig          `mapstructure:"spam" json:"spam"`
+	Redaction     RedactionConfig     `mapstructure:"redaction" json:"redaction"`
+	Encryption    EncryptionConfig    `mapstructure:"encryption" json:"encryption"`
+	Extensions    ExtensionsConfig    `mapstructure:"extensions" json:"extensions"`
+	Retention     RetentionConfig     `mapstructure:"retention" json:"retention"`
+	Replication   ReplicationConfig   `mapstructure:"replication" json:"replication"`
+	Scheduler     SchedulerConfig     `mapstructure:"scheduler" json:"scheduler"`
+	Backup        BackupConfig        `mapstructure:"backup" json:"backup"`
+	Archive       ArchiveConfig       `mapstructure:"archive" json:"archive"`
+	TimeTravel    TimeTravelConfig    `mapstructure:"time_travel" json:"time_travel"`
+	LegalHold     LegalHoldConfig     `mapstructure:"legal_hold" json:"legal_hold"`
+	PublicStats   PublicStatsConfig   `mapstructure:"public_stats" json:"public_stats"`
+	Tenants       TenantsConfig       `mapstructure:"tenants" json:"tenants"`
+	Dictionary    DictionaryConfig    `mapstructure:"dictionary" json:"dictionary"`
+	Expansion     ExpansionConfig     `mapstructure:"expansion" json:"expansion"`
+	SavedMessages SavedMessagesConfig `mapstructure:"saved_messages" json:"saved_messages"`
+	Events        EventsConfig        `mapstructure:"events" json:"events"`
+	IPFilter      IPFilterConfig      `mapstructure:"ip_filter" json:"ip_filter"`
 }
 
 // ServerConfig holds HTTP server configuration
 type ServerConfig struct {
-	Host         string        `mapstructure:"host" json:"host"`
-	Port         int           `mapstructure:"port" json:"port"`
-	ReadTimeout  time.Duration `mapstructure:"read_timeout" json:"read_timeout"`
-	WriteTimeout time.Duration `mapstructure:"write_timeout" json:"write_timeout"`
+	Host              string        `mapstructure:"host" json:"host"`
+	Port              int           `mapstructure:"port" json:"port"`
+	ReadTimeout       time.Duration `mapstructure:"read_timeout" json:"read_timeout"`               // Routes without their own deadline
+	WriteTimeout      time.Duration `mapstructure:"write_timeout" json:"write_timeout"`             // Routes without their own deadline
+	ReadHeaderTimeout time.Duration `mapstructure:"read_header_timeout" json:"read_header_timeout"` // Time allowed to send request headers
+	IdleTimeout       time.Duration `mapstructure:"idle_timeout" json:"idle_timeout"`               // Keep-alive connections are closed after this
+	EarlyLivez        bool          `mapstructure:"early_livez" json:"early_livez"`                 // Serve /livez while the engine connects
+
+	TrustedProxies  []string `mapstructure:"trusted_proxies" json:"trusted_proxies"`     // Proxies whose X-Forwarded-For is believed (empty = all, or none with ip_filter)
+	RemoteIPHeaders []string `mapstructure:"remote_ip_headers" json:"remote_ip_headers"` // Headers trusted proxies put the client address in, first match wins
+
+	TLS ServerTLSConfig `mapstructure:"tls" json:"tls"` // Serve HTTPS instead of h2c
+}
+
+// IPFilterConfig holds the client addresses allowed to make requests
+type IPFilterConfig struct {
+	Enabled bool     `mapstructure:"enabled" json:"enabled"`
+	Allow   []string `mapstructure:"allow" json:"allow"` // CIDR prefixes or addresses; only these are admitted (empty = all not denied)
+	Deny    []string `mapstructure:"deny" json:"deny"`   // CIDR prefixes or addresses refused even if allowed
+}
+
+// ServerTLSConfig holds the certificates for serving HTTPS, optionally
+// requiring clients to present a certificate (mutual TLS)
+type ServerTLSConfig struct {
+	Enabled           bool   `mapstructure:"enabled" json:"enabled"`
+	CertFile          string `mapstructure:"cert_file" json:"cert_file"`                     // PEM server certificate, chain included
+	KeyFile           string `mapstructure:"key_file" json:"key_file"`                       // PEM private key of the certificate
+	ClientCAFile      string `mapstructure:"client_ca_file" json:"client_ca_file"`           // PEM CAs client certificates are verified against
+	RequireClientCert bool   `mapstructure:"require_client_cert" json:"require_client_cert"` // Refuse clients without a certificate from client_ca_file
+	MinVersion        string `mapstructure:"min_version" json:"min_version"`                 // 1.2 or 1.3
 }
 
 // SearchEngineConfig holds search engine type configuration
@@ -40,13 +125,34 @@ type ElasticsearchConfig struct {
 	Index    string `mapstructure:"index" json:"index"`
 	Shards   int    `mapstructure:"shards" json:"shards"`
 	Replicas int    `mapstructure:"replicas" json:"replicas"`
+
+	// Time partitioning of messages; applies to fresh indices and reindexing
+	Partition string `mapstructure:"partition" json:"partition"`   // "month" for one index per month, "" for a single index
+	ILMPolicy string `mapstructure:"ilm_policy" json:"ilm_policy"` // Lifecycle policy attached to new partitions ("" = none)
+
+	// Fold Traditional and Simplified Chinese together: "t2s", "s2t" or ""
+	// (needs the STConvert plugin; applies to fresh indices and reindexing)
+	ChineseFolding string `mapstructure:"chinese_folding" json:"chinese_folding"`
+
+	// Startup connection retry
+	StartupMaxWait time.Duration `mapstructure:"startup_max_wait" json:"startup_max_wait"` // Keep retrying this long (0 = single attempt)
+	StartupBackoff time.Duration `mapstructure:"startup_backoff" json:"startup_backoff"`   // Initial delay, doubled per attempt up to 30s
 }
 
 // AuthConfig holds authentication configuration
 type AuthConfig struct {
 	// Legacy API key auth (deprecated)
-	Enabled bool   `mapstructure:"enabled" json:"enabled"`
-	APIKey  string `mapstructure:"api_key" json:"api_key"`
+	Enabled    bool   `mapstructure:"enabled" json:"enabled"`
+	APIKey     string `mapstructure:"api_key" json:"api_key"`
+	APIKeyHash string `mapstructure:"api_key_hash" json:"api_key_hash"` // Hex SHA-256 of the API key, in place of api_key
+	APIKeyRole string `mapstructure:"api_key_role" json:"api_key_role"` // Credential role of the API key: reader, writer or admin
+
+	// Further named API keys with full access, e.g. one per service so each
+	// can be rotated on its own; reloaded on SIGHUP or POST /api/v1/auth/keys/reload
+	Keys []AuthKeyConfig `mapstructure:"keys" json:"keys"`
+
+	// Further API keys limited to the messages of some chats
+	APIKeys []APIKeyConfig `mapstructure:"api_keys" json:"api_keys"`
 
 	// JWT auth (recommended)
 	UseJWT           bool        `mapstructure:"use_jwt" json:"use_jwt"`
@@ -56,13 +162,62 @@ type AuthConfig struct {
 	PrivateKeyPath   string      `mapstructure:"private_key_path" json:"private_key_path"`
 	PublicKeyInline  interface{} `mapstructure:"public_key_inline" json:"public_key_inline"`
 	PrivateKeyInline interface{} `mapstructure:"private_key_inline" json:"private_key_inline"`
-	TokenTTL         int         `mapstructure:"token_ttl" json:"token_ttl"` // seconds
+	TokenTTL         int         `mapstructure:"token_ttl" json:"token_ttl"`             // seconds
+	AllowedIssuers   []string    `mapstructure:"allowed_issuers" json:"allowed_issuers"` // Issuers whose tokens are accepted
+
+	// Sharing keys between services via JWKS instead of PEM files
+	ServeJWKS   bool          `mapstructure:"serve_jwks" json:"serve_jwks"`     // Serve our public keys at /.well-known/jwks.json
+	JWKSURL     string        `mapstructure:"jwks_url" json:"jwks_url"`         // Also trust the keys of this remote JWKS
+	JWKSRefresh time.Duration `mapstructure:"jwks_refresh" json:"jwks_refresh"` // How long fetched keys are cached
+
+	// Minting service tokens via POST /api/v1/auth/token (needs the private key)
+	TokenEndpoint bool `mapstructure:"token_endpoint" json:"token_endpoint"`
+	MaxTokenTTL   int  `mapstructure:"max_token_ttl" json:"max_token_ttl"` // seconds
+}
+
+// AuthKeyConfig is a named API key with full access, given either as the
+// key itself or as its hash
+type AuthKeyConfig struct {
+	Name    string `mapstructure:"name" json:"name"`         // Identifies the key in logs, usage and the audit log
+	Key     string `mapstructure:"key" json:"key"`           // The key itself
+	KeyHash string `mapstructure:"key_hash" json:"key_hash"` // Hex SHA-256 of the key, in place of key
+	Role    string `mapstructure:"role" json:"role"`         // Credential role: reader, writer or admin (empty = api_key_role)
+}
+
+// APIKeyConfig is an API key that may only search and delete the messages
+// of the listed chats
+type APIKeyConfig struct {
+	Name    string  `mapstructure:"name" json:"name"`         // Identifies the key in logs, usage and the audit log
+	Key     string  `mapstructure:"key" json:"key"`           // The key itself
+	KeyHash string  `mapstructure:"key_hash" json:"key_hash"` // Hex SHA-256 of the key, in place of key
+	ChatIDs []int64 `mapstructure:"chat_ids" json:"chat_ids"` // Chats whose messages the key may access
+	Role    string  `mapstructure:"role" json:"role"`         // Credential role: reader, writer or admin (empty = admin)
 }
 
 // LoggingConfig holds logging configuration
 type LoggingConfig struct {
 	Level  string `mapstructure:"level" json:"level"`
 	Format string `mapstructure:"format" json:"format"` // json or text
+
+	SlowRequest       time.Duration `mapstructure:"slow_request" json:"slow_request"`               // Requests taking longer are logged as warnings; 0 disables
+	RequestSampleRate float64       `mapstructure:"request_sample_rate" json:"request_sample_rate"` // Share of successful, fast requests logged (0-1)
+}
+
+// TracingConfig holds the OpenTelemetry collector request traces are
+// exported to
+type TracingConfig struct {
+	Enabled     bool              `mapstructure:"enabled" json:"enabled"`
+	Endpoint    string            `mapstructure:"endpoint" json:"endpoint"`         // OTLP/HTTP traces URL of Jaeger, Tempo or a collector
+	Headers     map[string]string `mapstructure:"headers" json:"headers"`           // Sent with every export, e.g. an API key of a hosted backend
+	ServiceName string            `mapstructure:"service_name" json:"service_name"` // service.name of the spans
+	SampleRatio float64           `mapstructure:"sample_ratio" json:"sample_ratio"` // Share of new traces recorded; traces of sampled callers are always recorded
+	Timeout     time.Duration     `mapstructure:"timeout" json:"timeout"`           // Deadline per export
+}
+
+// HealthConfig holds the readiness probe's limits
+type HealthConfig struct {
+	Timeout        time.Duration `mapstructure:"timeout" json:"timeout"`                 // How long the engine may take to answer a ping
+	QueueThreshold float64       `mapstructure:"queue_threshold" json:"queue_threshold"` // Share of the ingest queue filled before the instance is not ready (0-1)
 }
 
 // CacheConfig holds caching configuration
@@ -71,8 +226,588 @@ type CacheConfig struct {
 	TTL     time.Duration `mapstructure:"ttl" json:"ttl"`
 }
 
+// UsageConfig holds usage/billing export configuration
+type UsageConfig struct {
+	Enabled  bool          `mapstructure:"enabled" json:"enabled"`
+	Interval time.Duration `mapstructure:"interval" json:"interval"` // Export period
+	Sink     string        `mapstructure:"sink" json:"sink"`         // webhook or csv
+	URL      string        `mapstructure:"url" json:"url"`           // Destination for exported records
+	Timeout  time.Duration `mapstructure:"timeout" json:"timeout"`   // Delivery timeout
+}
+
+// StorageConfig holds configuration for the engine's own persistent state
+type StorageConfig struct {
+	DataDir string `mapstructure:"data_dir" json:"data_dir"` // Directory for state files, logs and spools
+	Backend string `mapstructure:"backend" json:"backend"`   // Where state documents are kept: file (in data_dir) or elasticsearch
+	Index   string `mapstructure:"index" json:"index"`       // elasticsearch: index holding the state documents
+}
+
+// CaptureConfig holds capture rules configuration
+type CaptureConfig struct {
+	Enabled bool `mapstructure:"enabled" json:"enabled"` // Serve the capture rules API
+	Enforce bool `mapstructure:"enforce" json:"enforce"` // Drop upserts excluded by the rules
+}
+
+// SubscriptionsConfig holds keyword subscription configuration
+type SubscriptionsConfig struct {
+	Enabled    bool   `mapstructure:"enabled" json:"enabled"`
+	QueueSize  int    `mapstructure:"queue_size" json:"queue_size"`     // Maximum buffered notification events
+	MaxPerUser int    `mapstructure:"max_per_user" json:"max_per_user"` // Subscriptions allowed per Telegram user
+	WebhookURL string `mapstructure:"webhook_url" json:"webhook_url"`   // Optional push delivery of events
+}
+
+// TimeConfig holds timezone configuration for derived time fields
+type TimeConfig struct {
+	Timezone string `mapstructure:"timezone" json:"timezone"` // IANA name, e.g. "Asia/Shanghai"
+}
+
+// Location returns the configured timezone
+func (t *TimeConfig) Location() (*time.Location, error) {
+	return time.LoadLocation(t.Timezone)
+}
+
+// IngestConfig holds ingestion configuration
+type IngestConfig struct {
+	MaxBatchSize int `mapstructure:"max_batch_size" json:"max_batch_size"` // Messages per bulk request for NDJSON streams
+
+	// Write-behind queue for single upserts
+	Async         bool          `mapstructure:"async" json:"async"`                   // Acknowledge upserts before indexing
+	QueueSize     int           `mapstructure:"queue_size" json:"queue_size"`         // Maximum buffered messages
+	FlushSize     int           `mapstructure:"flush_size" json:"flush_size"`         // Flush after this many messages
+	FlushInterval time.Duration `mapstructure:"flush_interval" json:"flush_interval"` // Flush at least this often
+
+	Kafka KafkaConfig `mapstructure:"kafka" json:"kafka"`
+	NATS  NATSConfig  `mapstructure:"nats" json:"nats"`
+	Redis RedisConfig `mapstructure:"redis" json:"redis"`
+
+	Telegram   TelegramConfig   `mapstructure:"telegram" json:"telegram"`
+	BotWebhook BotWebhookConfig `mapstructure:"bot_webhook" json:"bot_webhook"`
+}
+
+// KafkaConfig holds Kafka consumer ingestion configuration
+type KafkaConfig struct {
+	Enabled      bool          `mapstructure:"enabled" json:"enabled"`
+	Brokers      []string      `mapstructure:"brokers" json:"brokers"`
+	Topic        string        `mapstructure:"topic" json:"topic"`
+	GroupID      string        `mapstructure:"group_id" json:"group_id"`
+	BatchSize    int           `mapstructure:"batch_size" json:"batch_size"`       // Records per bulk request
+	BatchTimeout time.Duration `mapstructure:"batch_timeout" json:"batch_timeout"` // Flush partial batches after this long
+}
+
+// NATSConfig holds NATS JetStream ingestion configuration
+type NATSConfig struct {
+	Enabled      bool          `mapstructure:"enabled" json:"enabled"`
+	URL          string        `mapstructure:"url" json:"url"`
+	Stream       string        `mapstructure:"stream" json:"stream"`
+	Subject      string        `mapstructure:"subject" json:"subject"` // Optional filter within the stream
+	Durable      string        `mapstructure:"durable" json:"durable"` // Durable consumer name
+	BatchSize    int           `mapstructure:"batch_size" json:"batch_size"`
+	BatchTimeout time.Duration `mapstructure:"batch_timeout" json:"batch_timeout"`
+	AckWait      time.Duration `mapstructure:"ack_wait" json:"ack_wait"` // Redelivery timeout
+}
+
+// RedisConfig holds Redis Streams ingestion configuration
+type RedisConfig struct {
+	Enabled      bool          `mapstructure:"enabled" json:"enabled"`
+	Addr         string        `mapstructure:"addr" json:"addr"`
+	Password     string        `mapstructure:"password" json:"password"`
+	DB           int           `mapstructure:"db" json:"db"`
+	Stream       string        `mapstructure:"stream" json:"stream"`
+	Group        string        `mapstructure:"group" json:"group"`
+	Consumer     string        `mapstructure:"consumer" json:"consumer"` // Defaults to the hostname
+	Field        string        `mapstructure:"field" json:"field"`       // Entry field holding the message JSON
+	BatchSize    int           `mapstructure:"batch_size" json:"batch_size"`
+	BatchTimeout time.Duration `mapstructure:"batch_timeout" json:"batch_timeout"`
+	ClaimIdle    time.Duration `mapstructure:"claim_idle" json:"claim_idle"` // Take over entries other consumers left pending (0 disables)
+}
+
+// TelegramConfig holds the built-in MTProto ingestion client configuration
+type TelegramConfig struct {
+	Enabled     bool    `mapstructure:"enabled" json:"enabled"`
+	AppID       int     `mapstructure:"app_id" json:"app_id"` // From https://my.telegram.org
+	AppHash     string  `mapstructure:"app_hash" json:"app_hash"`
+	Phone       string  `mapstructure:"phone" json:"phone"`
+	Password    string  `mapstructure:"password" json:"password"`         // Two-step verification password
+	SessionFile string  `mapstructure:"session_file" json:"session_file"` // Defaults to telegram.session in the data directory
+	Chats       []int64 `mapstructure:"chats" json:"chats"`               // Chat IDs to index (empty = all chats)
+
+	BackfillDelay time.Duration `mapstructure:"backfill_delay" json:"backfill_delay"` // Pause between history pages
+}
+
+// BotWebhookConfig holds the Telegram Bot API webhook receiver configuration
+type BotWebhookConfig struct {
+	Enabled     bool   `mapstructure:"enabled" json:"enabled"`
+	SecretToken string `mapstructure:"secret_token" json:"secret_token"` // secret_token passed to setWebhook
+}
+
+// TimeoutsConfig holds per-route handler deadlines (0 disables a deadline)
+type TimeoutsConfig struct {
+	Default time.Duration `mapstructure:"default" json:"default"` // Routes without a specific class
+	Search  time.Duration `mapstructure:"search" json:"search"`   // Search and message lookup
+	Ingest  time.Duration `mapstructure:"ingest" json:"ingest"`   // Upserts, batch streams and edits
+	Admin   time.Duration `mapstructure:"admin" json:"admin"`     // Deletes and maintenance
+}
+
+// ConcurrencyConfig holds limits on concurrently running expensive operations
+// (0 disables a limit)
+type ConcurrencyConfig struct {
+	Search        int           `mapstructure:"search" json:"search"`                   // Searches, context, top messages and user stats
+	DeleteByQuery int           `mapstructure:"delete_by_query" json:"delete_by_query"` // Delete-by-query, chat and user deletes
+	QueueTimeout  time.Duration `mapstructure:"queue_timeout" json:"queue_timeout"`     // How long excess requests wait before 429
+}
+
+// RateLimitConfig holds token-bucket request rate limits; a request must
+// find a token in every bucket it draws from
+type RateLimitConfig struct {
+	Enabled bool            `mapstructure:"enabled" json:"enabled"`
+	Global  RateLimitBucket `mapstructure:"global" json:"global"`   // Shared by all requests
+	PerKey  RateLimitBucket `mapstructure:"per_key" json:"per_key"` // One per API key, JWT subject or tenant
+	PerIP   RateLimitBucket `mapstructure:"per_ip" json:"per_ip"`   // One per client address
+}
+
+// RateLimitBucket sizes a token bucket (a rate of 0 disables it)
+type RateLimitBucket struct {
+	Rate  float64 `mapstructure:"rate" json:"rate"`   // Requests per second on average
+	Burst int     `mapstructure:"burst" json:"burst"` // Requests at once (0 = rate rounded up)
+}
+
+// DegradationConfig holds load-based search degradation settings
+type DegradationConfig struct {
+	Enabled          bool          `mapstructure:"enabled" json:"enabled"`
+	LatencyThreshold time.Duration `mapstructure:"latency_threshold" json:"latency_threshold"` // Average search latency that triggers degradation
+	MaxInFlight      int           `mapstructure:"max_in_flight" json:"max_in_flight"`         // Concurrent searches that trigger degradation (0 disables)
+	PageSize         int           `mapstructure:"page_size" json:"page_size"`                 // Page size cap while degraded
+	Hold             time.Duration `mapstructure:"hold" json:"hold"`                           // Minimum time to stay degraded
+}
+
+// I18nConfig holds localization settings for user-facing messages
+type I18nConfig struct {
+	DefaultLanguage string `mapstructure:"default_language" json:"default_language"` // Used when Accept-Language names no supported language
+}
+
+// Language returns the supported language matching DefaultLanguage
+func (i *I18nConfig) Language() string {
+	return i18n.Match(i.DefaultLanguage)
+}
+
+// ResponseConfig holds response field naming settings for clients of other forks
+type ResponseConfig struct {
+	FieldCase       string            `mapstructure:"field_case" json:"field_case"`               // snake (default) or camel
+	IssuerFieldCase map[string]string `mapstructure:"issuer_field_case" json:"issuer_field_case"` // Per JWT issuer override
+	Aliases         map[string]string `mapstructure:"aliases" json:"aliases"`                     // snake_case field -> emitted name
+}
+
+// GuardrailsConfig holds limits that protect against accidental mass deletes
+type GuardrailsConfig struct {
+	DeleteThreshold int64 `mapstructure:"delete_threshold" json:"delete_threshold"` // Deletes affecting more messages need force (0 disables)
+	ClearEnabled    bool  `mapstructure:"clear_enabled" json:"clear_enabled"`       // Serve DELETE /clear (with two-step confirmation)
+}
+
+// RecycleBinConfig holds configuration for keeping deleted messages restorable
+type RecycleBinConfig struct {
+	Enabled       bool          `mapstructure:"enabled" json:"enabled"`
+	TTL           time.Duration `mapstructure:"ttl" json:"ttl"`                       // How long deleted messages stay restorable
+	PurgeInterval time.Duration `mapstructure:"purge_interval" json:"purge_interval"` // How often expired messages are purged
+}
+
+// SoftDeleteConfig holds configuration for marking deleted messages instead
+// of removing them
+type SoftDeleteConfig struct {
+	Enabled bool `mapstructure:"enabled" json:"enabled"` // Delete-by-query marks matches with deleted_at instead of removing them
+}
+
+// ProfilesConfig holds search profile configuration
+type ProfilesConfig struct {
+	Enabled bool `mapstructure:"enabled" json:"enabled"` // Merge per-caller defaults into searches and serve /api/v1/profile
+}
+
+// TranscriptionConfig holds configuration for transcribing voice and video notes
+type TranscriptionConfig struct {
+	Enabled     bool          `mapstructure:"enabled" json:"enabled"`
+	URL         string        `mapstructure:"url" json:"url"`                     // OpenAI-compatible /v1/audio/transcriptions endpoint
+	APIKey      string        `mapstructure:"api_key" json:"api_key"`             // Optional bearer token for the backend
+	Model       string        `mapstructure:"model" json:"model"`                 // Model name sent with each request
+	Language    string        `mapstructure:"language" json:"language"`           // ISO-639-1 hint, empty to auto-detect
+	MediaTypes  []string      `mapstructure:"media_types" json:"media_types"`     // Media types to transcribe
+	MaxFileSize int64         `mapstructure:"max_file_size" json:"max_file_size"` // Larger files are not transcribed (bytes)
+	Timeout     time.Duration `mapstructure:"timeout" json:"timeout"`             // Download and transcription deadline per message
+}
+
+// OCRConfig holds configuration for reading text from images
+type OCRConfig struct {
+	Enabled     bool              `mapstructure:"enabled" json:"enabled"`
+	URL         string            `mapstructure:"url" json:"url"`                     // Endpoint receiving the image as multipart form data
+	APIKey      string            `mapstructure:"api_key" json:"api_key"`             // Optional bearer token for the service
+	FileField   string            `mapstructure:"file_field" json:"file_field"`       // Form field carrying the image
+	Fields      map[string]string `mapstructure:"fields" json:"fields"`               // Extra form fields, e.g. language options
+	TextField   string            `mapstructure:"text_field" json:"text_field"`       // Dotted path of the text in the JSON response
+	MediaTypes  []string          `mapstructure:"media_types" json:"media_types"`     // Media types to read (image documents are always read)
+	MaxFileSize int64             `mapstructure:"max_file_size" json:"max_file_size"` // Larger images are not read (bytes)
+	Timeout     time.Duration     `mapstructure:"timeout" json:"timeout"`             // Download and OCR deadline per message
+}
+
+// MediaConfig holds where transcription and OCR may download media from,
+// and the background workers running them
+type MediaConfig struct {
+	AllowedHosts []string `mapstructure:"allowed_hosts" json:"allowed_hosts"` // Hosts media_url may point to over HTTPS; "*.example.com" matches subdomains
+	Workers      int      `mapstructure:"workers" json:"workers"`             // Messages transcribed or read in parallel
+	QueueSize    int      `mapstructure:"queue_size" json:"queue_size"`       // Messages waiting for their media to be read; more are left unread
+}
+
+// LegalHoldConfig holds configuration for legal holds that keep chats from
+// being deleted
+type LegalHoldConfig struct {
+	Enabled bool `mapstructure:"enabled" json:"enabled"`
+}
+
+// PublicStatsConfig holds configuration for the unauthenticated stats of
+// published chats
+type PublicStatsConfig struct {
+	Enabled  bool          `mapstructure:"enabled" json:"enabled"`
+	Chats    []int64       `mapstructure:"chats" json:"chats"`         // Chats whose stats are published
+	MinUsers int           `mapstructure:"min_users" json:"min_users"` // Counts covering fewer distinct users are withheld
+	Epsilon  float64       `mapstructure:"epsilon" json:"epsilon"`     // Privacy budget per published count; smaller adds more noise (0 = none)
+	MaxDays  int           `mapstructure:"max_days" json:"max_days"`   // Longest period that can be requested
+	Secret   string        `mapstructure:"secret" json:"secret"`       // Seeds the noise (at least 32 characters)
+	CacheTTL time.Duration `mapstructure:"cache_ttl" json:"cache_ttl"` // How long results are served before being recomputed
+}
+
+// DictionaryConfig holds configuration for the dictionary of domain terms
+// matched as a whole
+type DictionaryConfig struct {
+	Enabled bool   `mapstructure:"enabled" json:"enabled"`
+	Path    string `mapstructure:"path" json:"path"` // Text file with one term per line
+}
+
+// SavedMessagesConfig holds configuration for treating users' Saved
+// Messages as a tagged personal knowledge base
+type SavedMessagesConfig struct {
+	Enabled bool `mapstructure:"enabled" json:"enabled"`
+	MaxTags int  `mapstructure:"max_tags" json:"max_tags"` // Tags a saved message may carry
+}
+
+// ExpansionConfig selects the input slips undone in search keywords
+type ExpansionConfig struct {
+	KeyboardLayouts []string `mapstructure:"keyboard_layouts" json:"keyboard_layouts"` // Cyrillic layouts (ru, uk) whose slips with QWERTY are undone
+	Transliteration []string `mapstructure:"transliteration" json:"transliteration"`   // Languages (ru, uk) searched in both Latin and Cyrillic spelling
+}
+
+// TenantsConfig holds configuration for tenants whose messages are kept in
+// indices of their own
+type TenantsConfig struct {
+	Enabled bool `mapstructure:"enabled" json:"enabled"`
+}
+
+// TimeTravelConfig holds configuration for searching snapshots of the index
+// as they were taken
+type TimeTravelConfig struct {
+	Enabled    bool   `mapstructure:"enabled" json:"enabled"`
+	Repository string `mapstructure:"repository" json:"repository"` // Registered Elasticsearch snapshot repository
+}
+
+// ArchiveConfig holds configuration for archiving indexed messages to an
+// S3-compatible bucket
+type ArchiveConfig struct {
+	Enabled  bool          `mapstructure:"enabled" json:"enabled"`
+	Interval time.Duration `mapstructure:"interval" json:"interval"` // How often spooled messages are uploaded
+	S3       S3Config      `mapstructure:"s3" json:"s3"`             // Bucket settings; s3.enabled is implied
+}
+
+// BackupConfig holds configuration for backups started via the API
+type BackupConfig struct {
+	Enabled     bool     `mapstructure:"enabled" json:"enabled"`
+	Type        string   `mapstructure:"type" json:"type"`                 // ndjson (logical, any engine) or snapshot (Elasticsearch)
+	Path        string   `mapstructure:"path" json:"path"`                 // ndjson: where backups are written (staging area with S3)
+	SegmentSize int      `mapstructure:"segment_size" json:"segment_size"` // ndjson: messages per segment file
+	Repository  string   `mapstructure:"repository" json:"repository"`     // snapshot: registered Elasticsearch snapshot repository
+	S3          S3Config `mapstructure:"s3" json:"s3"`                     // ndjson: keep backups in a bucket instead of path
+}
+
+// S3Config holds the settings of an S3-compatible bucket (AWS S3, MinIO, ...)
+type S3Config struct {
+	Enabled   bool          `mapstructure:"enabled" json:"enabled"`
+	Endpoint  string        `mapstructure:"endpoint" json:"endpoint"` // e.g. https://s3.amazonaws.com or http://minio:9000
+	Region    string        `mapstructure:"region" json:"region"`     // Signing region
+	Bucket    string        `mapstructure:"bucket" json:"bucket"`
+	Prefix    string        `mapstructure:"prefix" json:"prefix"` // Key prefix, e.g. searchgram/backups
+	AccessKey string        `mapstructure:"access_key" json:"access_key"`
+	SecretKey string        `mapstructure:"secret_key" json:"secret_key"`
+	PathStyle bool          `mapstructure:"path_style" json:"path_style"` // Address the bucket as endpoint/bucket (MinIO)
+	Timeout   time.Duration `mapstructure:"timeout" json:"timeout"`       // Deadline per request
+}
+
+// SchedulerConfig holds configuration for recurring maintenance tasks
+type SchedulerConfig struct {
+	Enabled bool                  `mapstructure:"enabled" json:"enabled"`
+	Tasks   []ScheduledTaskConfig `mapstructure:"tasks" json:"tasks"`
+}
+
+// ScheduledTaskConfig runs Task whenever Schedule matches
+type ScheduledTaskConfig struct {
+	Name        string               `mapstructure:"name" json:"name"`                 // Unique name (defaults to the task)
+	Task        string               `mapstructure:"task" json:"task"`                 // dedup, retention_purge, forcemerge, snapshot or export
+	Schedule    string               `mapstructure:"schedule" json:"schedule"`         // Cron expression in time.timezone, e.g. "0 3 * * *"
+	DryRun      bool                 `mapstructure:"dry_run" json:"dry_run"`           // dedup: only report duplicates
+	MaxSegments int                  `mapstructure:"max_segments" json:"max_segments"` // forcemerge: segments per shard (0 = Elasticsearch's choice)
+	Repository  string               `mapstructure:"repository" json:"repository"`     // snapshot: registered snapshot repository
+	Keep        int                  `mapstructure:"keep" json:"keep"`                 // snapshot: newest snapshots to keep (0 = all)
+	ChatIDs     []int64              `mapstructure:"chat_ids" json:"chat_ids"`         // export: chats to export (empty = all)
+	Days        int                  `mapstructure:"days" json:"days"`                 // export: only messages of the last days (0 = all)
+	Delivery    ExportDeliveryConfig `mapstructure:"delivery" json:"delivery"`         // export: where the file goes
+}
+
+// ExportDeliveryConfig says where a scheduled export is delivered
+type ExportDeliveryConfig struct {
+	Type   string   `mapstructure:"type" json:"type"`     // dir, s3 or webhook
+	Path   string   `mapstructure:"path" json:"path"`     // dir: directory exports are written to
+	S3     S3Config `mapstructure:"s3" json:"s3"`         // s3: bucket settings; s3.enabled is implied
+	URL    string   `mapstructure:"url" json:"url"`       // webhook: endpoint exports are POSTed to
+	Secret string   `mapstructure:"secret" json:"secret"` // webhook: HMAC-SHA256 key signing the body (empty = unsigned)
+}
+
+// ScheduledName returns the task's name, which defaults to its task
+func (t ScheduledTaskConfig) ScheduledName() string {
+	if t.Name != "" {
+		return t.Name
+	}
+	return t.Task
+}
+
+// ReplicationConfig holds configuration for replicating writes to a secondary engine
+type ReplicationConfig struct {
+	Enabled       bool                `mapstructure:"enabled" json:"enabled"`
+	Elasticsearch ElasticsearchConfig `mapstructure:"elasticsearch" json:"elasticsearch"` // Secondary cluster; startup settings are unused
+	QueueSize     int                 `mapstructure:"queue_size" json:"queue_size"`       // Buffered writes; overflowing ones need a resync
+	BatchSize     int                 `mapstructure:"batch_size" json:"batch_size"`       // Maximum messages per bulk write
+	MaxBackoff    time.Duration       `mapstructure:"max_backoff" json:"max_backoff"`     // Longest pause between retries
+}
+
+// EventsConfig holds configuration for delivering document lifecycle events
+// to an external sink
+type EventsConfig struct {
+	Enabled     bool                `mapstructure:"enabled" json:"enabled"`
+	Sink        string              `mapstructure:"sink" json:"sink"`   // webhook, nats or kafka
+	Types       []string            `mapstructure:"types" json:"types"` // Event types to emit (empty = all)
+	Webhook     EventsWebhookConfig `mapstructure:"webhook" json:"webhook"`
+	NATS        EventsNATSConfig    `mapstructure:"nats" json:"nats"`
+	Kafka       EventsKafkaConfig   `mapstructure:"kafka" json:"kafka"`
+	QueueSize   int                 `mapstructure:"queue_size" json:"queue_size"`     // Buffered events; overflowing ones are dead-lettered
+	BatchSize   int                 `mapstructure:"batch_size" json:"batch_size"`     // Maximum events per delivery
+	MaxAttempts int                 `mapstructure:"max_attempts" json:"max_attempts"` // Deliveries of an event before it is dead-lettered
+	MaxBackoff  time.Duration       `mapstructure:"max_backoff" json:"max_backoff"`   // Longest pause between retries
+}
+
+// EventsWebhookConfig holds the webhook lifecycle events are POSTed to
+type EventsWebhookConfig struct {
+	URL     string        `mapstructure:"url" json:"url"`
+	Secret  string        `mapstructure:"secret" json:"secret"`   // Signs bodies in X-SearchGram-Signature (empty = unsigned)
+	Timeout time.Duration `mapstructure:"timeout" json:"timeout"` // Deadline per delivery
+}
+
+// EventsNATSConfig holds the JetStream subject lifecycle events are published to
+type EventsNATSConfig struct {
+	URL     string `mapstructure:"url" json:"url"`
+	Subject string `mapstructure:"subject" json:"subject"` // Must be captured by a stream
+}
+
+// EventsKafkaConfig holds the Kafka topic lifecycle events are written to
+type EventsKafkaConfig struct {
+	Brokers []string `mapstructure:"brokers" json:"brokers"`
+	Topic   string   `mapstructure:"topic" json:"topic"`
+}
+
+// RetentionConfig holds configuration for purging messages past their retention
+type RetentionConfig struct {
+	Enabled       bool                  `mapstructure:"enabled" json:"enabled"`
+	DefaultDays   int                   `mapstructure:"default_days" json:"default_days"`     // Days to keep messages of chats without a policy (0 = forever)
+	Chats         []RetentionChatConfig `mapstructure:"chats" json:"chats"`                   // Per-chat policies; API changes override them
+	PurgeInterval time.Duration         `mapstructure:"purge_interval" json:"purge_interval"` // How often expired messages are purged (0 = only when scheduled or requested)
+}
+
+// RetentionChatConfig keeps the messages of ChatID for Days days (0 = forever)
+type RetentionChatConfig struct {
+	ChatID int64 `mapstructure:"chat_id" json:"chat_id"`
+	Days   int   `mapstructure:"days" json:"days"`
+}
+
+// ExtensionsConfig holds configuration for compiled-in and plugin extensions
+type ExtensionsConfig struct {
+	PluginsDir string                            `mapstructure:"plugins_dir" json:"plugins_dir"` // Directory of Go plugins (*.so) to load (empty = none)
+	Disabled   []string                          `mapstructure:"disabled" json:"disabled"`       // Extensions not to initialize
+	Settings   map[string]map[string]interface{} `mapstructure:"settings" json:"settings"`       // Extension name -> its settings
+}
+
+// RedactionConfig holds configuration for masking personal data at ingest
+type RedactionConfig struct {
+	Enabled  bool     `mapstructure:"enabled" json:"enabled"`
+	Types    []string `mapstructure:"types" json:"types"`         // Built-in patterns: email, phone, credit_card
+	Patterns []string `mapstructure:"patterns" json:"patterns"`   // Custom regular expressions to mask
+	MaskChar string   `mapstructure:"mask_char" json:"mask_char"` // Replaces every masked character
+}
+
+// EncryptionConfig holds the keys message text is encrypted with before
+// indexing, for clusters hosted by a third party
+type EncryptionConfig struct {
+	Enabled      bool              `mapstructure:"enabled" json:"enabled"`
+	Key          string            `mapstructure:"key" json:"key"`                     // Base64 32-byte key encrypting new messages
+	KeyID        string            `mapstructure:"key_id" json:"key_id"`               // Stored with each message, so the key can be rotated
+	PreviousKeys map[string]string `mapstructure:"previous_keys" json:"previous_keys"` // Key ID -> base64 key still decrypting older messages
+	BlindKey     string            `mapstructure:"blind_key" json:"blind_key"`         // Base64 32-byte key of the searchable tokens (empty = derived from key)
+}
+
+// Sealer returns the settings of the text sealer
+func (e *EncryptionConfig) Sealer() fieldcrypt.Config {
+	return fieldcrypt.Config{
+		Key:          e.Key,
+		KeyID:        e.KeyID,
+		PreviousKeys: e.PreviousKeys,
+		BlindKey:     e.BlindKey,
+	}
+}
+
+// SpamConfig holds configuration for spam classification at ingest
+type SpamConfig struct {
+	Enabled    bool             `mapstructure:"enabled" json:"enabled"`
+	Threshold  float64          `mapstructure:"threshold" json:"threshold"`     // Score (0-1] from which a message is spam
+	Rules      []SpamRuleConfig `mapstructure:"rules" json:"rules"`             // Regular expressions whose weights add up to a score
+	URL        string           `mapstructure:"url" json:"url"`                 // Optional external classifier receiving {"text": ...}
+	APIKey     string           `mapstructure:"api_key" json:"api_key"`         // Optional bearer token for the classifier
+	ScoreField string           `mapstructure:"score_field" json:"score_field"` // Dotted path of the 0-1 score in the JSON response
+	Timeout    time.Duration    `mapstructure:"timeout" json:"timeout"`         // Deadline for scoring one message
+}
+
+// SpamRuleConfig adds Weight to the spam score of messages matching Pattern
+type SpamRuleConfig struct {
+	Pattern string  `mapstructure:"pattern" json:"pattern"`
+	Weight  float64 `mapstructure:"weight" json:"weight"`
+}
+
+// RoutesConfig selects which route groups are served and who may use them
+type RoutesConfig struct {
+	Read  bool                `mapstructure:"read" json:"read"`   // Serve search, fetch, listing and stats routes
+	Write bool                `mapstructure:"write" json:"write"` // Serve ingest, edit and caller settings routes
+	Roles map[string][]string `mapstructure:"roles" json:"roles"` // JWT issuer -> route groups (read, write, admin) it may use; empty = all
+	Auth  map[string][]string `mapstructure:"auth" json:"auth"`   // Route group -> credentials (jwt, api_key) it accepts; empty = any
+
+	Operations map[string][]string `mapstructure:"operations" json:"operations"` // JWT issuer or API key name -> the only routes it may call ("POST /upsert", "/export/*")
+}
+
+// AdminConfig holds configuration for administrative routes
+type AdminConfig struct {
+	Enabled bool `mapstructure:"enabled" json:"enabled"` // Serve bulk delete, clear, restore and maintenance routes
+}
+
+// AuthGuardConfig holds brute-force protection settings for API authentication
+type AuthGuardConfig struct {
+	Enabled        bool          `mapstructure:"enabled" json:"enabled"`
+	FreeFailures   int           `mapstructure:"free_failures" json:"free_failures"`       // Failures per window before responses are delayed
+	Window         time.Duration `mapstructure:"window" json:"window"`                     // Failures older than this are forgotten
+	BaseDelay      time.Duration `mapstructure:"base_delay" json:"base_delay"`             // First delay, doubled per further failure
+	MaxDelay       time.Duration `mapstructure:"max_delay" json:"max_delay"`               // Upper bound for the delay
+	BanAfter       int           `mapstructure:"ban_after" json:"ban_after"`               // Failures per window that trigger a ban (0 = never ban)
+	BanDuration    time.Duration `mapstructure:"ban_duration" json:"ban_duration"`         // First ban, doubled for each repeat ban
+	MaxBanDuration time.Duration `mapstructure:"max_ban_duration" json:"max_ban_duration"` // Upper bound for a ban
+}
+
+// TranslationConfig holds configuration for search query translation
+type TranslationConfig struct {
+	Enabled   bool          `mapstructure:"enabled" json:"enabled"`
+	URL       string        `mapstructure:"url" json:"url"`               // LibreTranslate-compatible /translate endpoint
+	APIKey    string        `mapstructure:"api_key" json:"api_key"`       // Optional api_key for the backend
+	Targets   []string      `mapstructure:"targets" json:"targets"`       // Languages keywords are translated into (ISO 639-1)
+	Timeout   time.Duration `mapstructure:"timeout" json:"timeout"`       // Deadline for translating one query
+	CacheSize int           `mapstructure:"cache_size" json:"cache_size"` // Translations kept in memory (0 disables caching)
+}
+
+// SessionsConfig holds configuration for web dashboard cookie sessions
+type SessionsConfig struct {
+	Enabled      bool          `mapstructure:"enabled" json:"enabled"`
+	TTL          time.Duration `mapstructure:"ttl" json:"ttl"`                     // Session lifetime, not extended by use
+	CookieName   string        `mapstructure:"cookie_name" json:"cookie_name"`     // Name of the HttpOnly session cookie
+	SecureCookie bool          `mapstructure:"secure_cookie" json:"secure_cookie"` // Only send the cookie over HTTPS
+}
+
+// SignedURLsConfig holds configuration for signed download URLs
+type SignedURLsConfig struct {
+	Enabled    bool          `mapstructure:"enabled" json:"enabled"`
+	Secret     string        `mapstructure:"secret" json:"secret"`           // HMAC key (at least 32 characters); changing it revokes all URLs
+	BaseURL    string        `mapstructure:"base_url" json:"base_url"`       // Public address prepended to signed URLs, e.g. https://search.example.com
+	DefaultTTL time.Duration `mapstructure:"default_ttl" json:"default_ttl"` // Lifetime when the caller does not pick one
+	MaxTTL     time.Duration `mapstructure:"max_ttl" json:"max_ttl"`         // Upper bound for a URL's lifetime
+	Routes     []string      `mapstructure:"routes" json:"routes"`           // GET routes below /api/v1 that may be signed ("/usage", "/files/*")
+}
+
+// AuditConfig holds configuration for the audit log
+type AuditConfig struct {
+	Enabled bool `mapstructure:"enabled" json:"enabled"` // Append security events to <data_dir>/audit.log
+	Index   bool `mapstructure:"index" json:"index"`     // Also copy them to the append-only <index>-audit index
+}
+
+// EmbeddingsConfig holds configuration for message embeddings and semantic search
+type EmbeddingsConfig struct {
+	Enabled    bool          `mapstructure:"enabled" json:"enabled"`
+	URL        string        `mapstructure:"url" json:"url"`               // OpenAI-compatible /v1/embeddings endpoint
+	APIKey     string        `mapstructure:"api_key" json:"api_key"`       // Optional bearer token for the backend
+	Model      string        `mapstructure:"model" json:"model"`           // Model name sent with each request
+	Dimensions int           `mapstructure:"dimensions" json:"dimensions"` // Vector length of the model; fixed once mapped
+	Timeout    time.Duration `mapstructure:"timeout" json:"timeout"`       // Deadline per embedding request
+
+	LexicalWeight  float64 `mapstructure:"lexical_weight" json:"lexical_weight"`   // Default weight of the keyword ranking in hybrid fusion
+	SemanticWeight float64 `mapstructure:"semantic_weight" json:"semantic_weight"` // Default weight of the vector ranking in hybrid fusion
+}
+
+// RerankConfig holds configuration for cross-encoder reranking of hybrid search results
+type RerankConfig struct {
+	Enabled bool          `mapstructure:"enabled" json:"enabled"`
+	URL     string        `mapstructure:"url" json:"url"`         // Cohere/Jina-compatible /v1/rerank endpoint
+	APIKey  string        `mapstructure:"api_key" json:"api_key"` // Optional bearer token for the backend
+	Model   string        `mapstructure:"model" json:"model"`     // Model name sent with each request
+	TopK    int           `mapstructure:"top_k" json:"top_k"`     // Fused results reranked by default (0 = only on request)
+	Timeout time.Duration `mapstructure:"timeout" json:"timeout"` // Deadline per rerank request
+}
+
 // Load loads configuration from file and environment
 func Load(configPath string) (*Config, error) {
+	cfg, err := read(configPath, false)
+	if err != nil {
+		return nil, err
+	}
+
+	// Validate configuration
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid configuration: %w", err)
+	}
+
+	// Configure logging
+	configureLogging(&cfg.Logging)
+
+	log.WithFields(log.Fields{
+		"host":   cfg.Server.Host,
+		"port":   cfg.Server.Port,
+		"engine": cfg.SearchEngine.Type,
+	}).Info("Configuration loaded")
+
+	return cfg, nil
+}
+
+// Reload reads the configuration file again for the settings that change
+// without a restart, such as API keys. Unlike Load it fails when the file
+// cannot be read instead of falling back to defaults, and leaves logging
+// as configured.
+func Reload(configPath string) (*Config, error) {
+	cfg, err := read(configPath, true)
+	if err != nil {
+		return nil, err
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid configuration: %w", err)
+	}
+	return cfg, nil
+}
+
+// read reads configuration from file and environment; a file that cannot
+// be read is an error when strict, and leaves the defaults otherwise
+func read(configPath string, strict bool) (*Config, error) {
 	v := viper.New()
 
 	// Set defaults
@@ -90,6 +825,9 @@ func Load(configPath string) (*Config, error) {
 		}
 
 		if err := v.ReadInConfig(); err != nil {
+			if strict {
+				return nil, fmt.Errorf("failed to read config file: %w", err)
+			}
 			log.WithError(err).Warn("Failed to read config file, using defaults")
 		} else {
 			log.WithField("file", configPath).Info("Loaded configuration file")
@@ -122,8 +860,15 @@ func Load(configPath string) (*Config, error) {
 				cfg.Auth.PublicKeyInline = authCfg.PublicKeyInline
 				cfg.Auth.PrivateKeyInline = authCfg.PrivateKeyInline
 				cfg.Auth.TokenTTL = authCfg.TokenTTL
+				cfg.Auth.AllowedIssuers = authCfg.AllowedIssuers
+				cfg.Auth.ServeJWKS = authCfg.ServeJWKS
+				cfg.Auth.JWKSURL = authCfg.JWKSURL
+				cfg.Auth.JWKSRefresh = authCfg.JWKSRefresh
 			}
 		}
+		if len(cfg.Auth.AllowedIssuers) == 0 {
+			cfg.Auth.AllowedIssuers = defaultAllowedIssuers
+		}
 
 		// Override server config from http section if present (for consistency)
 		if v.IsSet("http.listen") {
@@ -142,23 +887,12 @@ func Load(configPath string) (*Config, error) {
 		}
 	}
 
-	// Validate configuration
-	if err := cfg.Validate(); err != nil {
-		return nil, fmt.Errorf("invalid configuration: %w", err)
-	}
-
-	// Configure logging
-	configureLogging(&cfg.Logging)
-
-	log.WithFields(log.Fields{
-		"host":   cfg.Server.Host,
-		"port":   cfg.Server.Port,
-		"engine": cfg.SearchEngine.Type,
-	}).Info("Configuration loaded")
-
 	return &cfg, nil
 }
 
+// defaultAllowedIssuers are the SearchGram services whose JWTs are accepted
+var defaultAllowedIssuers = []string{"bot", "userbot", "search"}
+
 // setDefaults sets default configuration values
 func setDefaults(v *viper.Viper) {
 	// Server defaults
@@ -166,6 +900,21 @@ func setDefaults(v *viper.Viper) {
 	v.SetDefault("server.port", 8080)
 	v.SetDefault("server.read_timeout", 30*time.Second)
 	v.SetDefault("server.write_timeout", 30*time.Second)
+	v.SetDefault("server.read_header_timeout", 10*time.Second)
+	v.SetDefault("server.idle_timeout", 2*time.Minute)
+	v.SetDefault("server.early_livez", false)
+	v.SetDefault("server.remote_ip_headers", []string{"X-Forwarded-For", "X-Real-IP"})
+
+	// IP filter defaults
+	v.SetDefault("ip_filter.enabled", false)
+	v.SetDefault("ip_filter.allow", []string{})
+	v.SetDefault("ip_filter.deny", []string{})
+	v.SetDefault("server.tls.enabled", false)
+	v.SetDefault("server.tls.cert_file", "")
+	v.SetDefault("server.tls.key_file", "")
+	v.SetDefault("server.tls.client_ca_file", "")
+	v.SetDefault("server.tls.require_client_cert", false)
+	v.SetDefault("server.tls.min_version", "1.2")
 
 	// Search engine defaults
 	v.SetDefault("search_engine.type", "elasticsearch")
@@ -177,24 +926,383 @@ func setDefaults(v *viper.Viper) {
 	v.SetDefault("elasticsearch.index", "telegram")
 	v.SetDefault("elasticsearch.shards", 3)
 	v.SetDefault("elasticsearch.replicas", 1)
+	v.SetDefault("elasticsearch.partition", "")
+	v.SetDefault("elasticsearch.ilm_policy", "")
+	v.SetDefault("elasticsearch.chinese_folding", "")
+	v.SetDefault("elasticsearch.startup_max_wait", 2*time.Minute)
+	v.SetDefault("elasticsearch.startup_backoff", time.Second)
 
 	// Auth defaults
 	v.SetDefault("auth.enabled", false)
 	v.SetDefault("auth.api_key", "")
+	v.SetDefault("auth.api_key_hash", "")
+	v.SetDefault("auth.keys", []map[string]interface{}{})
+	v.SetDefault("auth.api_key_role", middleware.CredentialAdmin)
+	v.SetDefault("auth.api_keys", []map[string]interface{}{})
 	v.SetDefault("auth.use_jwt", true)
 	v.SetDefault("auth.issuer", "search")
 	v.SetDefault("auth.audience", "internal")
 	v.SetDefault("auth.public_key_path", "keys/public.key")
 	v.SetDefault("auth.private_key_path", "keys/private.key")
+	v.SetDefault("auth.allowed_issuers", defaultAllowedIssuers)
+	v.SetDefault("auth.serve_jwks", true)
+	v.SetDefault("auth.jwks_url", "")
+	v.SetDefault("auth.jwks_refresh", 10*time.Minute)
+	v.SetDefault("auth.token_endpoint", false)
+	v.SetDefault("auth.max_token_ttl", 86400)
 	v.SetDefault("auth.token_ttl", 300)
 
 	// Logging defaults
 	v.SetDefault("logging.level", "info")
 	v.SetDefault("logging.format", "json")
+	v.SetDefault("logging.slow_request", time.Duration(0))
+	v.SetDefault("logging.request_sample_rate", 1.0)
+
+	// Tracing defaults
+	v.SetDefault("tracing.enabled", false)
+	v.SetDefault("tracing.endpoint", "http://localhost:4318/v1/traces")
+	v.SetDefault("tracing.service_name", "searchgram-engine")
+	v.SetDefault("tracing.sample_ratio", 1.0)
+	v.SetDefault("tracing.timeout", 10*time.Second)
+
+	// Health check defaults
+	v.SetDefault("health.timeout", 5*time.Second)
+	v.SetDefault("health.queue_threshold", 0.9)
 
 	// Cache defaults
 	v.SetDefault("cache.enabled", false)
 	v.SetDefault("cache.ttl", 300*time.Second)
+
+	// Usage export defaults
+	v.SetDefault("usage.enabled", false)
+	v.SetDefault("usage.interval", time.Hour)
+	v.SetDefault("usage.sink", "webhook")
+	v.SetDefault("usage.url", "")
+	v.SetDefault("usage.timeout", 10*time.Second)
+
+	// Storage defaults
+	v.SetDefault("storage.data_dir", "data")
+	v.SetDefault("storage.backend", "file")
+	v.SetDefault("storage.index", "searchgram-state")
+
+	// Capture rules defaults
+	v.SetDefault("capture.enabled", true)
+	v.SetDefault("capture.enforce", false)
+
+	// Keyword subscription defaults
+	v.SetDefault("subscriptions.enabled", false)
+	v.SetDefault("subscriptions.queue_size", 10000)
+	v.SetDefault("subscriptions.max_per_user", 50)
+	v.SetDefault("subscriptions.webhook_url", "")
+
+	// Time defaults
+	v.SetDefault("time.timezone", "UTC")
+
+	// Route timeout defaults
+	v.SetDefault("timeouts.default", 30*time.Second)
+	v.SetDefault("timeouts.search", 15*time.Second)
+	v.SetDefault("timeouts.ingest", 5*time.Minute)
+	v.SetDefault("timeouts.admin", 5*time.Minute)
+
+	// Concurrency defaults
+	v.SetDefault("concurrency.search", 32)
+	v.SetDefault("concurrency.delete_by_query", 2)
+	v.SetDefault("concurrency.queue_timeout", 2*time.Second)
+
+	// Rate limit defaults
+	v.SetDefault("rate_limit.enabled", false)
+	v.SetDefault("rate_limit.global.rate", 0)
+	v.SetDefault("rate_limit.global.burst", 0)
+	v.SetDefault("rate_limit.per_key.rate", 10)
+	v.SetDefault("rate_limit.per_key.burst", 20)
+	v.SetDefault("rate_limit.per_ip.rate", 0)
+	v.SetDefault("rate_limit.per_ip.burst", 0)
+
+	// Degradation defaults
+	v.SetDefault("degradation.enabled", true)
+	v.SetDefault("degradation.latency_threshold", 2*time.Second)
+	v.SetDefault("degradation.max_in_flight", 24)
+	v.SetDefault("degradation.page_size", 20)
+	v.SetDefault("degradation.hold", 30*time.Second)
+
+	// I18n defaults
+	v.SetDefault("i18n.default_language", i18n.English)
+
+	// Response defaults
+	v.SetDefault("response.field_case", "snake")
+
+	// Guardrail defaults
+	v.SetDefault("guardrails.delete_threshold", 10000)
+	v.SetDefault("guardrails.clear_enabled", true)
+
+	// Recycle bin defaults
+	v.SetDefault("recycle_bin.enabled", false)
+	v.SetDefault("recycle_bin.ttl", 7*24*time.Hour)
+	v.SetDefault("recycle_bin.purge_interval", time.Hour)
+
+	// Soft delete defaults
+	v.SetDefault("soft_delete.enabled", false)
+
+	// Search profile defaults
+	v.SetDefault("profiles.enabled", false)
+
+	// Transcription defaults
+	v.SetDefault("transcription.enabled", false)
+	v.SetDefault("transcription.url", "")
+	v.SetDefault("transcription.api_key", "")
+	v.SetDefault("transcription.model", "whisper-1")
+	v.SetDefault("transcription.language", "")
+	v.SetDefault("transcription.media_types", []string{"voice", "video_note"})
+	v.SetDefault("transcription.max_file_size", 25<<20)
+	v.SetDefault("transcription.timeout", time.Minute)
+
+	// Route group defaults; admin routes are denied unless enabled
+	v.SetDefault("routes.read", true)
+	v.SetDefault("routes.write", true)
+	v.SetDefault("admin.enabled", false)
+
+	// Brute-force protection defaults
+	v.SetDefault("auth_guard.enabled", true)
+	v.SetDefault("auth_guard.free_failures", 5)
+	v.SetDefault("auth_guard.window", 15*time.Minute)
+	v.SetDefault("auth_guard.base_delay", 250*time.Millisecond)
+	v.SetDefault("auth_guard.max_delay", 5*time.Second)
+	v.SetDefault("auth_guard.ban_after", 20)
+	v.SetDefault("auth_guard.ban_duration", 15*time.Minute)
+	v.SetDefault("auth_guard.max_ban_duration", 24*time.Hour)
+
+	// Audit defaults
+	v.SetDefault("audit.enabled", true)
+	v.SetDefault("audit.index", false)
+
+	// Query translation defaults
+	v.SetDefault("translation.enabled", false)
+	v.SetDefault("translation.url", "")
+	v.SetDefault("translation.api_key", "")
+	v.SetDefault("translation.targets", []string{"zh", "en"})
+	v.SetDefault("translation.timeout", 3*time.Second)
+	v.SetDefault("translation.cache_size", 10000)
+
+	// Dashboard session defaults
+	v.SetDefault("sessions.enabled", false)
+	v.SetDefault("sessions.ttl", 2*time.Hour)
+	v.SetDefault("sessions.cookie_name", "sg_session")
+	v.SetDefault("sessions.secure_cookie", true)
+
+	// Signed URL defaults
+	v.SetDefault("signed_urls.enabled", false)
+	v.SetDefault("signed_urls.secret", "")
+	v.SetDefault("signed_urls.base_url", "")
+	v.SetDefault("signed_urls.default_ttl", time.Hour)
+	v.SetDefault("signed_urls.max_ttl", 24*time.Hour)
+	v.SetDefault("signed_urls.routes", []string{"/usage"})
+
+	// Extension defaults
+	v.SetDefault("extensions.plugins_dir", "")
+	v.SetDefault("extensions.disabled", []string{})
+	v.SetDefault("extensions.settings", map[string]interface{}{})
+
+	// Redaction defaults
+	v.SetDefault("redaction.enabled", false)
+	v.SetDefault("redaction.types", []string{"email", "phone", "credit_card"})
+	v.SetDefault("redaction.patterns", []string{})
+	v.SetDefault("redaction.mask_char", "*")
+
+	// Encryption defaults
+	v.SetDefault("encryption.enabled", false)
+	v.SetDefault("encryption.key_id", "default")
+
+	// Spam classification defaults; the rules catch common airdrop and
+	// crypto giveaway promotions
+	v.SetDefault("spam.enabled", false)
+	v.SetDefault("spam.threshold", 0.5)
+	v.SetDefault("spam.rules", []map[string]interface{}{
+		{"pattern": `(?i)\bair\s?drops?\b`, "weight": 0.5},
+		{"pattern": `(?i)\bfree\s+(usdt|btc|eth|ton|crypto|tokens?)\b`, "weight": 0.5},
+		{"pattern": `(?i)\b(claim|grab)\s+(your|free)\b`, "weight": 0.3},
+		{"pattern": `(?i)\b(guaranteed|daily)\s+(profits?|returns?)\b`, "weight": 0.4},
+		{"pattern": `空投|免费领取|稳赚不赔|日赚`, "weight": 0.5},
+		{"pattern": `\b0x[0-9a-fA-F]{40}\b`, "weight": 0.2},
+	})
+	v.SetDefault("spam.url", "")
+	v.SetDefault("spam.api_key", "")
+	v.SetDefault("spam.score_field", "score")
+	v.SetDefault("spam.timeout", 5*time.Second)
+
+	// Retention defaults
+	v.SetDefault("retention.enabled", false)
+	v.SetDefault("retention.default_days", 0)
+	v.SetDefault("retention.chats", []map[string]interface{}{})
+	v.SetDefault("retention.purge_interval", 24*time.Hour)
+
+	// Replication defaults
+	v.SetDefault("replication.enabled", false)
+	v.SetDefault("replication.elasticsearch.host", "")
+	v.SetDefault("replication.elasticsearch.username", "")
+	v.SetDefault("replication.elasticsearch.password", "")
+	v.SetDefault("replication.elasticsearch.index", "telegram")
+	v.SetDefault("replication.elasticsearch.shards", 3)
+	v.SetDefault("replication.elasticsearch.replicas", 1)
+	v.SetDefault("replication.elasticsearch.partition", "")
+	v.SetDefault("replication.elasticsearch.ilm_policy", "")
+	v.SetDefault("replication.elasticsearch.chinese_folding", "")
+	v.SetDefault("replication.queue_size", 100000)
+	v.SetDefault("replication.batch_size", 500)
+	v.SetDefault("replication.max_backoff", time.Minute)
+
+	// Lifecycle events defaults
+	v.SetDefault("events.enabled", false)
+	v.SetDefault("events.sink", "webhook")
+	v.SetDefault("events.types", []string{})
+	v.SetDefault("events.webhook.url", "")
+	v.SetDefault("events.webhook.secret", "")
+	v.SetDefault("events.webhook.timeout", 10*time.Second)
+	v.SetDefault("events.nats.url", "nats://localhost:4222")
+	v.SetDefault("events.nats.subject", "searchgram.events")
+	v.SetDefault("events.kafka.brokers", []string{"localhost:9092"})
+	v.SetDefault("events.kafka.topic", "searchgram-events")
+	v.SetDefault("events.queue_size", 10000)
+	v.SetDefault("events.batch_size", 100)
+	v.SetDefault("events.max_attempts", 10)
+	v.SetDefault("events.max_backoff", time.Minute)
+
+	// Scheduler defaults
+	v.SetDefault("scheduler.enabled", false)
+	v.SetDefault("scheduler.tasks", []map[string]interface{}{})
+
+	// Backup defaults
+	v.SetDefault("backup.enabled", false)
+	v.SetDefault("backup.type", "ndjson")
+	v.SetDefault("backup.path", "data/backups")
+	v.SetDefault("backup.segment_size", 100000)
+	v.SetDefault("backup.repository", "")
+	v.SetDefault("backup.s3.enabled", false)
+	v.SetDefault("backup.s3.endpoint", "")
+	v.SetDefault("backup.s3.bucket", "")
+	v.SetDefault("backup.s3.access_key", "")
+	v.SetDefault("backup.s3.secret_key", "")
+	v.SetDefault("backup.s3.region", "us-east-1")
+	v.SetDefault("backup.s3.prefix", "searchgram/backups")
+	v.SetDefault("backup.s3.path_style", false)
+	v.SetDefault("backup.s3.timeout", 5*time.Minute)
+
+	// Archive defaults
+	v.SetDefault("archive.enabled", false)
+	v.SetDefault("archive.interval", time.Hour)
+	v.SetDefault("archive.s3.endpoint", "")
+	v.SetDefault("archive.s3.region", "us-east-1")
+	v.SetDefault("archive.s3.bucket", "")
+	v.SetDefault("archive.s3.prefix", "searchgram/archive")
+	v.SetDefault("archive.s3.access_key", "")
+	v.SetDefault("archive.s3.secret_key", "")
+	v.SetDefault("archive.s3.path_style", false)
+	v.SetDefault("archive.s3.timeout", 5*time.Minute)
+
+	// Time travel defaults
+	v.SetDefault("time_travel.enabled", false)
+	v.SetDefault("time_travel.repository", "")
+
+	// Legal hold defaults
+	v.SetDefault("legal_hold.enabled", false)
+
+	// Public stats defaults
+	v.SetDefault("public_stats.enabled", false)
+	v.SetDefault("public_stats.chats", []int64{})
+	v.SetDefault("public_stats.min_users", 5)
+	v.SetDefault("public_stats.epsilon", 1.0)
+	v.SetDefault("public_stats.max_days", 90)
+	v.SetDefault("public_stats.secret", "")
+	v.SetDefault("public_stats.cache_ttl", 5*time.Minute)
+
+	// Tenant defaults
+	v.SetDefault("tenants.enabled", false)
+
+	// Dictionary defaults
+	v.SetDefault("dictionary.enabled", false)
+	v.SetDefault("dictionary.path", "dictionary.txt")
+
+	// Keyword expansion defaults
+	v.SetDefault("expansion.keyboard_layouts", []string{})
+	v.SetDefault("expansion.transliteration", []string{})
+
+	// Saved Messages defaults
+	v.SetDefault("saved_messages.enabled", false)
+	v.SetDefault("saved_messages.max_tags", 20)
+
+	// Embedding defaults
+	v.SetDefault("embeddings.enabled", false)
+	v.SetDefault("embeddings.url", "")
+	v.SetDefault("embeddings.api_key", "")
+	v.SetDefault("embeddings.model", "bge-m3")
+	v.SetDefault("embeddings.dimensions", 1024)
+	v.SetDefault("embeddings.timeout", 10*time.Second)
+	v.SetDefault("embeddings.lexical_weight", 1.0)
+	v.SetDefault("embeddings.semantic_weight", 1.0)
+
+	// Rerank defaults
+	v.SetDefault("rerank.enabled", false)
+	v.SetDefault("rerank.url", "")
+	v.SetDefault("rerank.api_key", "")
+	v.SetDefault("rerank.model", "bge-reranker-v2-m3")
+	v.SetDefault("rerank.top_k", 50)
+	v.SetDefault("rerank.timeout", 10*time.Second)
+
+	// OCR defaults
+	v.SetDefault("ocr.enabled", false)
+	v.SetDefault("ocr.url", "")
+	v.SetDefault("ocr.api_key", "")
+	v.SetDefault("ocr.file_field", "file")
+	v.SetDefault("ocr.text_field", "text")
+	v.SetDefault("ocr.media_types", []string{"photo"})
+	v.SetDefault("ocr.max_file_size", 10<<20)
+	v.SetDefault("ocr.timeout", 30*time.Second)
+
+	// Media enrichment defaults
+	v.SetDefault("media.allowed_hosts", []string{})
+	v.SetDefault("media.workers", 2)
+	v.SetDefault("media.queue_size", 1000)
+
+	// Ingest defaults
+	v.SetDefault("ingest.max_batch_size", 1000)
+	v.SetDefault("ingest.async", false)
+	v.SetDefault("ingest.queue_size", 50000)
+	v.SetDefault("ingest.flush_size", 500)
+	v.SetDefault("ingest.flush_interval", 500*time.Millisecond)
+	v.SetDefault("ingest.kafka.enabled", false)
+	v.SetDefault("ingest.kafka.brokers", []string{"kafka:9092"})
+	v.SetDefault("ingest.kafka.topic", "searchgram.messages")
+	v.SetDefault("ingest.kafka.group_id", "searchgram-engine")
+	v.SetDefault("ingest.kafka.batch_size", 500)
+	v.SetDefault("ingest.kafka.batch_timeout", time.Second)
+	v.SetDefault("ingest.nats.enabled", false)
+	v.SetDefault("ingest.nats.url", "nats://nats:4222")
+	v.SetDefault("ingest.nats.stream", "SEARCHGRAM")
+	v.SetDefault("ingest.nats.subject", "")
+	v.SetDefault("ingest.nats.durable", "searchgram-engine")
+	v.SetDefault("ingest.nats.batch_size", 500)
+	v.SetDefault("ingest.nats.batch_timeout", time.Second)
+	v.SetDefault("ingest.nats.ack_wait", time.Minute)
+	v.SetDefault("ingest.redis.enabled", false)
+	v.SetDefault("ingest.redis.addr", "redis:6379")
+	v.SetDefault("ingest.redis.password", "")
+	v.SetDefault("ingest.redis.db", 0)
+	v.SetDefault("ingest.redis.stream", "searchgram:messages")
+	v.SetDefault("ingest.redis.group", "searchgram-engine")
+	v.SetDefault("ingest.redis.consumer", "")
+	v.SetDefault("ingest.redis.field", "message")
+	v.SetDefault("ingest.redis.batch_size", 500)
+	v.SetDefault("ingest.redis.batch_timeout", time.Second)
+	v.SetDefault("ingest.redis.claim_idle", 5*time.Minute)
+	v.SetDefault("ingest.telegram.enabled", false)
+	v.SetDefault("ingest.telegram.app_id", 0)
+	v.SetDefault("ingest.telegram.app_hash", "")
+	v.SetDefault("ingest.telegram.phone", "")
+	v.SetDefault("ingest.telegram.password", "")
+	v.SetDefault("ingest.telegram.session_file", "")
+	v.SetDefault("ingest.telegram.chats", []int64{})
+	v.SetDefault("ingest.telegram.backfill_delay", time.Second)
+	v.SetDefault("ingest.bot_webhook.enabled", false)
+	v.SetDefault("ingest.bot_webhook.secret_token", "")
 }
 
 // Validate validates the configuration
@@ -223,13 +1331,717 @@ func (c *Config) Validate() error {
 		if c.Elasticsearch.Index == "" {
 			return fmt.Errorf("elasticsearch index is required")
 		}
+		if c.Elasticsearch.Partition != "" && c.Elasticsearch.Partition != "month" {
+			return fmt.Errorf("invalid elasticsearch partition %q (must be month or empty)", c.Elasticsearch.Partition)
+		}
+		if c.Elasticsearch.ILMPolicy != "" && c.Elasticsearch.Partition == "" {
+			return fmt.Errorf("elasticsearch ilm_policy requires partition to be set")
+		}
+		if !validChineseFolding(c.Elasticsearch.ChineseFolding) {
+			return fmt.Errorf("invalid elasticsearch chinese_folding %q (must be t2s, s2t or empty)", c.Elasticsearch.ChineseFolding)
+		}
+	}
+
+	// Validate storage config
+	switch c.Storage.Backend {
+	case "file":
+	case "elasticsearch":
+		if c.Elasticsearch.Host == "" {
+			return fmt.Errorf("elasticsearch host is required for the elasticsearch storage backend")
+		}
+		if c.Storage.Index == "" {
+			return fmt.Errorf("storage index is required for the elasticsearch storage backend")
+		}
+		if c.Storage.Index == c.Elasticsearch.Index {
+			return fmt.Errorf("storage index must differ from the elasticsearch index")
+		}
+	default:
+		return fmt.Errorf("unsupported storage backend %q (supported: file, elasticsearch)", c.Storage.Backend)
+	}
+
+	// Validate auth config
+	if c.Auth.Enabled && c.Auth.APIKey == "" && c.Auth.APIKeyHash == "" && len(c.Auth.Keys) == 0 {
+		return fmt.Errorf("API key is required when auth is enabled")
+	}
+	if c.Auth.APIKey != "" && c.Auth.APIKeyHash != "" {
+		return fmt.Errorf("auth api_key and api_key_hash are mutually exclusive")
+	}
+	if c.Auth.APIKeyHash != "" && !validKeyHash(c.Auth.APIKeyHash) {
+		return fmt.Errorf("auth api_key_hash must be a hex SHA-256 (64 characters)")
+	}
+	if c.Auth.APIKeyRole != "" && !middleware.ValidCredentialRole(c.Auth.APIKeyRole) {
+		return fmt.Errorf("invalid auth api_key_role: %s (must be reader, writer or admin)", c.Auth.APIKeyRole)
+	}
+	if len(c.Auth.Keys) > 0 {
+		if !c.Auth.Enabled {
+			return fmt.Errorf("auth keys require auth to be enabled")
+		}
+		names := make(map[string]bool, len(c.Auth.Keys))
+		for _, key := range c.Auth.Keys {
+			if key.Name == "" {
+				return fmt.Errorf("auth keys entries require a name")
+			}
+			if names[key.Name] {
+				return fmt.Errorf("auth key %s is configured more than once", key.Name)
+			}
+			names[key.Name] = true
+			if (key.Key == "") == (key.KeyHash == "") {
+				return fmt.Errorf("auth key %s requires either key or key_hash", key.Name)
+			}
+			if key.Key != "" && len(key.Key) < 16 {
+				return fmt.Errorf("auth key %s must be at least 16 characters", key.Name)
+			}
+			if key.Key != "" && key.Key == c.Auth.APIKey {
+				return fmt.Errorf("auth key %s reuses another key", key.Name)
+			}
+			if key.KeyHash != "" && !validKeyHash(key.KeyHash) {
+				return fmt.Errorf("auth key %s: key_hash must be a hex SHA-256 (64 characters)", key.Name)
+			}
+			if key.Role != "" && !middleware.ValidCredentialRole(key.Role) {
+				return fmt.Errorf("invalid role %s for auth key %s (must be reader, writer or admin)", key.Role, key.Name)
+			}
+		}
+	}
+	if len(c.Auth.APIKeys) > 0 {
+		if !c.Auth.Enabled && !c.Auth.UseJWT {
+			return fmt.Errorf("auth api_keys require auth to be enabled")
+		}
+		names := make(map[string]bool, len(c.Auth.APIKeys))
+		hashes := make(map[string]bool, len(c.Auth.Keys)+len(c.Auth.APIKeys)+1)
+		if hash := keyHash(c.Auth.APIKey, c.Auth.APIKeyHash); hash != "" {
+			hashes[hash] = true
+		}
+		for _, key := range c.Auth.Keys {
+			hashes[keyHash(key.Key, key.KeyHash)] = true
+		}
+		for _, key := range c.Auth.APIKeys {
+			if key.Name == "" {
+				return fmt.Errorf("auth api_keys entries require a name")
+			}
+			if names[key.Name] {
+				return fmt.Errorf("auth api_key %s is configured more than once", key.Name)
+			}
+			names[key.Name] = true
+			if (key.Key == "") == (key.KeyHash == "") {
+				return fmt.Errorf("auth api_key %s requires either key or key_hash", key.Name)
+			}
+			if key.Key != "" && len(key.Key) < 16 {
+				return fmt.Errorf("auth api_key %s must be at least 16 characters", key.Name)
+			}
+			if key.KeyHash != "" && !validKeyHash(key.KeyHash) {
+				return fmt.Errorf("auth api_key %s: key_hash must be a hex SHA-256 (64 characters)", key.Name)
+			}
+			hash := keyHash(key.Key, key.KeyHash)
+			if hashes[hash] {
+				return fmt.Errorf("auth api_key %s reuses another key", key.Name)
+			}
+			hashes[hash] = true
+			if len(key.ChatIDs) == 0 {
+				return fmt.Errorf("auth api_key %s requires chat_ids", key.Name)
+			}
+			if key.Role != "" && !middleware.ValidCredentialRole(key.Role) {
+				return fmt.Errorf("invalid role %s for auth api_key %s (must be reader, writer or admin)", key.Role, key.Name)
+			}
+		}
+	}
+
+	// Validate usage export config
+	if c.Usage.Enabled {
+		if c.Usage.Sink != "webhook" && c.Usage.Sink != "csv" {
+			return fmt.Errorf("invalid usage sink: %s (must be webhook or csv)", c.Usage.Sink)
+		}
+		if c.Usage.Interval <= 0 {
+			return fmt.Errorf("usage interval must be positive")
+		}
+	}
+
+	// Validate ingest config
+	if c.Ingest.MaxBatchSize < 1 {
+		return fmt.Errorf("ingest max_batch_size must be positive")
+	}
+	if c.Ingest.Async {
+		if c.Ingest.QueueSize < 1 || c.Ingest.FlushSize < 1 {
+			return fmt.Errorf("ingest queue_size and flush_size must be positive")
+		}
+		if c.Ingest.FlushInterval <= 0 {
+			return fmt.Errorf("ingest flush_interval must be positive")
+		}
+	}
+
+	if c.Ingest.Kafka.Enabled {
+		if len(c.Ingest.Kafka.Brokers) == 0 || c.Ingest.Kafka.Topic == "" || c.Ingest.Kafka.GroupID == "" {
+			return fmt.Errorf("kafka brokers, topic and group_id are required when kafka ingestion is enabled")
+		}
+	}
+	if c.Ingest.NATS.Enabled {
+		if c.Ingest.NATS.URL == "" || c.Ingest.NATS.Stream == "" || c.Ingest.NATS.Durable == "" {
+			return fmt.Errorf("nats url, stream and durable are required when nats ingestion is enabled")
+		}
+	}
+	if c.Ingest.Redis.Enabled {
+		if c.Ingest.Redis.Addr == "" || c.Ingest.Redis.Stream == "" || c.Ingest.Redis.Group == "" {
+			return fmt.Errorf("redis addr, stream and group are required when redis ingestion is enabled")
+		}
+	}
+	if c.Ingest.Telegram.Enabled {
+		if c.Ingest.Telegram.AppID == 0 || c.Ingest.Telegram.AppHash == "" || c.Ingest.Telegram.Phone == "" {
+			return fmt.Errorf("telegram app_id, app_hash and phone are required when telegram ingestion is enabled")
+		}
+	}
+	if c.Ingest.BotWebhook.Enabled && c.Ingest.BotWebhook.SecretToken == "" {
+		return fmt.Errorf("bot_webhook secret_token is required when the telegram webhook is enabled")
+	}
+
+	// Validate server timeouts
+	if c.Server.ReadTimeout < 0 || c.Server.WriteTimeout < 0 || c.Server.ReadHeaderTimeout < 0 || c.Server.IdleTimeout < 0 {
+		return fmt.Errorf("server timeouts must not be negative")
+	}
+
+	// Validate server TLS
+	if c.Server.TLS.Enabled {
+		if c.Server.TLS.CertFile == "" || c.Server.TLS.KeyFile == "" {
+			return fmt.Errorf("server tls cert_file and key_file are required when TLS is enabled")
+		}
+		if c.Server.TLS.RequireClientCert && c.Server.TLS.ClientCAFile == "" {
+			return fmt.Errorf("server tls require_client_cert requires client_ca_file")
+		}
+		switch c.Server.TLS.MinVersion {
+		case "", "1.2", "1.3":
+		default:
+			return fmt.Errorf("invalid server tls min_version %q (supported: 1.2, 1.3)", c.Server.TLS.MinVersion)
+		}
+	}
+
+	// Validate IP filter
+	if c.IPFilter.Enabled {
+		if len(c.IPFilter.Allow) == 0 && len(c.IPFilter.Deny) == 0 {
+			return fmt.Errorf("ip_filter needs allow or deny entries when enabled")
+		}
+		if _, err := middleware.ParsePrefixes(c.IPFilter.Allow); err != nil {
+			return fmt.Errorf("invalid ip_filter allow entry: %w", err)
+		}
+		if _, err := middleware.ParsePrefixes(c.IPFilter.Deny); err != nil {
+			return fmt.Errorf("invalid ip_filter deny entry: %w", err)
+		}
+	}
+
+	// Validate request logging
+	if c.Logging.SlowRequest < 0 {
+		return fmt.Errorf("logging slow_request must not be negative")
+	}
+	if c.Logging.RequestSampleRate < 0 || c.Logging.RequestSampleRate > 1 {
+		return fmt.Errorf("logging request_sample_rate must be between 0 and 1")
+	}
+
+	if c.Tracing.Enabled {
+		if c.Tracing.Endpoint == "" {
+			return fmt.Errorf("tracing endpoint is required when enabled")
+		}
+		if c.Tracing.ServiceName == "" {
+			return fmt.Errorf("tracing service_name is required when enabled")
+		}
+		if c.Tracing.SampleRatio < 0 || c.Tracing.SampleRatio > 1 {
+			return fmt.Errorf("tracing sample_ratio must be between 0 and 1")
+		}
+		if c.Tracing.Timeout <= 0 {
+			return fmt.Errorf("tracing timeout must be positive")
+		}
+	}
+
+	// Validate health checks
+	if c.Health.Timeout <= 0 {
+		return fmt.Errorf("health timeout must be positive")
+	}
+	if c.Health.QueueThreshold <= 0 || c.Health.QueueThreshold > 1 {
+		return fmt.Errorf("health queue_threshold must be greater than 0 and at most 1")
+	}
+
+	// Validate route timeouts
+	if c.Timeouts.Default < 0 || c.Timeouts.Search < 0 || c.Timeouts.Ingest < 0 || c.Timeouts.Admin < 0 {
+		return fmt.Errorf("route timeouts must not be negative")
+	}
+
+	// Validate concurrency limits
+	if c.Concurrency.Search < 0 || c.Concurrency.DeleteByQuery < 0 || c.Concurrency.QueueTimeout < 0 {
+		return fmt.Errorf("concurrency limits must not be negative")
+	}
+
+	// Validate rate limits
+	if c.RateLimit.Enabled {
+		buckets := []RateLimitBucket{c.RateLimit.Global, c.RateLimit.PerKey, c.RateLimit.PerIP}
+		limited := false
+		for _, bucket := range buckets {
+			if bucket.Rate < 0 || bucket.Burst < 0 {
+				return fmt.Errorf("rate limits must not be negative")
+			}
+			limited = limited || bucket.Rate > 0
+		}
+		if !limited {
+			return fmt.Errorf("rate_limit needs a rate for global, per_key or per_ip when enabled")
+		}
+	}
+
+	// Validate degradation
+	if c.Degradation.Enabled {
+		if c.Degradation.LatencyThreshold < 0 || c.Degradation.MaxInFlight < 0 || c.Degradation.Hold < 0 {
+			return fmt.Errorf("degradation thresholds must not be negative")
+		}
+		if c.Degradation.PageSize < 1 {
+			return fmt.Errorf("degradation page_size must be at least 1")
+		}
+	}
+
+	// Validate brute-force protection
+	if c.AuthGuard.Enabled {
+		if c.AuthGuard.FreeFailures < 0 || c.AuthGuard.BanAfter < 0 ||
+			c.AuthGuard.BaseDelay < 0 || c.AuthGuard.MaxDelay < 0 {
+			return fmt.Errorf("auth_guard thresholds and delays must not be negative")
+		}
+		if c.AuthGuard.Window <= 0 || c.AuthGuard.BanDuration <= 0 {
+			return fmt.Errorf("auth_guard window and ban_duration must be positive")
+		}
+	}
+
+	// Validate default language
+	if c.I18n.Language() == "" {
+		return fmt.Errorf("unsupported i18n default_language %q (supported: %s, %s)", c.I18n.DefaultLanguage, i18n.English, i18n.Chinese)
+	}
+
+	// Validate guardrails
+	if c.Guardrails.DeleteThreshold < 0 {
+		return fmt.Errorf("guardrails delete_threshold must not be negative")
+	}
+
+	// Validate recycle bin
+	if c.RecycleBin.Enabled {
+		if c.RecycleBin.TTL <= 0 {
+			return fmt.Errorf("recycle_bin ttl must be positive")
+		}
+		if c.RecycleBin.PurgeInterval <= 0 {
+			return fmt.Errorf("recycle_bin purge_interval must be positive")
+		}
+		if c.SoftDelete.Enabled {
+			return fmt.Errorf("recycle_bin and soft_delete are mutually exclusive")
+		}
+	}
+
+	if c.Transcription.Enabled {
+		if c.Transcription.URL == "" {
+			return fmt.Errorf("transcription url is required when transcription is enabled")
+		}
+		if c.Transcription.Timeout <= 0 {
+			return fmt.Errorf("transcription timeout must be positive")
+		}
+	}
+
+	if c.OCR.Enabled {
+		if c.OCR.URL == "" {
+			return fmt.Errorf("ocr url is required when ocr is enabled")
+		}
+		if c.OCR.Timeout <= 0 {
+			return fmt.Errorf("ocr timeout must be positive")
+		}
+	}
+
+	if c.Transcription.Enabled || c.OCR.Enabled {
+		if len(c.Media.AllowedHosts) == 0 {
+			return fmt.Errorf("media allowed_hosts is required when transcription or ocr is enabled")
+		}
+		for _, host := range c.Media.AllowedHosts {
+			if host == "" || strings.ContainsAny(host, "/:") || strings.Contains(strings.TrimPrefix(host, "*."), "*") {
+				return fmt.Errorf("invalid media allowed host %q: expected a host name such as files.example.com or *.example.com", host)
+			}
+		}
+		if c.Media.Workers <= 0 {
+			return fmt.Errorf("media workers must be positive")
+		}
+		if c.Media.QueueSize <= 0 {
+			return fmt.Errorf("media queue_size must be positive")
+		}
+	}
+
+	if c.Redaction.Enabled {
+		// Masks must keep the UTF-16 length of the text for entity offsets
+		mask := []rune(c.Redaction.MaskChar)
+		if len(mask) != 1 || mask[0] > 0xFFFF {
+			return fmt.Errorf("redaction mask_char must be a single character")
+		}
+		if len(c.Redaction.Types) == 0 && len(c.Redaction.Patterns) == 0 {
+			return fmt.Errorf("redaction requires types or patterns")
+		}
+		if _, err := enrich.NewRedactor(c.Redaction.Types, c.Redaction.Patterns, c.Redaction.MaskChar); err != nil {
+			return err
+		}
+	}
+
+	if c.Encryption.Enabled {
+		if c.SearchEngine.Type != "elasticsearch" {
+			return fmt.Errorf("encryption requires the elasticsearch search engine")
+		}
+		if _, err := fieldcrypt.New(c.Encryption.Sealer()); err != nil {
+			return fmt.Errorf("invalid encryption settings: %w", err)
+		}
+		// Embeddings are indexed vectors of the text, and transcripts and OCR
+		// text would hand media content to the enrichment services
+		if c.Embeddings.Enabled {
+			return fmt.Errorf("encryption cannot be combined with embeddings")
+		}
+		if c.Transcription.Enabled || c.OCR.Enabled {
+			return fmt.Errorf("encryption cannot be combined with transcription or ocr")
+		}
+	}
+
+	if c.Spam.Enabled {
+		if c.Spam.Threshold <= 0 || c.Spam.Threshold > 1 {
+			return fmt.Errorf("spam threshold must be greater than 0 and at most 1")
+		}
+		if len(c.Spam.Rules) == 0 && c.Spam.URL == "" {
+			return fmt.Errorf("spam requires rules or a classifier url")
+		}
+		for _, rule := range c.Spam.Rules {
+			if _, err := regexp.Compile(rule.Pattern); err != nil {
+				return fmt.Errorf("invalid spam rule %q: %w", rule.Pattern, err)
+			}
+		}
+		if c.Spam.URL != "" && c.Spam.Timeout <= 0 {
+			return fmt.Errorf("spam timeout must be positive")
+		}
+	}
+
+	if c.Retention.Enabled {
+		if c.Retention.DefaultDays < 0 {
+			return fmt.Errorf("retention default_days must not be negative")
+		}
+		if c.Retention.PurgeInterval < 0 {
+			return fmt.Errorf("retention purge_interval must not be negative")
+		}
+		seen := make(map[int64]bool, len(c.Retention.Chats))
+		for _, chat := range c.Retention.Chats {
+			if chat.Days < 0 {
+				return fmt.Errorf("retention days of chat %d must not be negative", chat.ChatID)
+			}
+			if seen[chat.ChatID] {
+				return fmt.Errorf("retention chat %d is configured more than once", chat.ChatID)
+			}
+			seen[chat.ChatID] = true
+		}
+	}
+
+	if c.Scheduler.Enabled {
+		names := make(map[string]bool, len(c.Scheduler.Tasks))
+		for _, task := range c.Scheduler.Tasks {
+			name := task.ScheduledName()
+			if names[name] {
+				return fmt.Errorf("scheduled task %s is defined more than once", name)
+			}
+			names[name] = true
+
+			if _, err := scheduler.Parse(task.Schedule); err != nil {
+				return fmt.Errorf("scheduled task %s: %w", name, err)
+			}
+			switch task.Task {
+			case scheduler.TaskDedup:
+			case scheduler.TaskRetentionPurge:
+				if !c.Retention.Enabled {
+					return fmt.Errorf("scheduled task %s requires retention to be enabled", name)
+				}
+			case scheduler.TaskForceMerge:
+				if task.MaxSegments < 0 {
+					return fmt.Errorf("scheduled task %s: max_segments must not be negative", name)
+				}
+			case scheduler.TaskSnapshot:
+				if task.Repository == "" {
+					return fmt.Errorf("scheduled task %s: repository is required", name)
+				}
+				if task.Keep < 0 {
+					return fmt.Errorf("scheduled task %s: keep must not be negative", name)
+				}
+			case scheduler.TaskExport:
+				if task.Days < 0 {
+					return fmt.Errorf("scheduled task %s: days must not be negative", name)
+				}
+				delivery := task.Delivery
+				switch delivery.Type {
+				case "dir":
+					if delivery.Path == "" {
+						return fmt.Errorf("scheduled task %s: delivery path is required for dir delivery", name)
+					}
+				case "s3":
+					if delivery.S3.Endpoint == "" || delivery.S3.Bucket == "" {
+						return fmt.Errorf("scheduled task %s: delivery s3 endpoint and bucket are required for s3 delivery", name)
+					}
+				case "webhook":
+					if !strings.HasPrefix(delivery.URL, "http://") && !strings.HasPrefix(delivery.URL, "https://") {
+						return fmt.Errorf("scheduled task %s: invalid delivery url %q: must be an http(s) URL", name, delivery.URL)
+					}
+				default:
+					return fmt.Errorf("scheduled task %s: unsupported delivery type %q (supported: dir, s3, webhook)", name, delivery.Type)
+				}
+			default:
+				return fmt.Errorf("scheduled task %s: unsupported task %q (supported: %s)", name, task.Task, strings.Join(scheduler.Tasks(), ", "))
+			}
+		}
+	}
+
+	if c.TimeTravel.Enabled && c.TimeTravel.Repository == "" {
+		return fmt.Errorf("time_travel repository is required when time travel is enabled")
+	}
+
+	if c.PublicStats.Enabled {
+		if c.PublicStats.MinUsers < 1 {
+			return fmt.Errorf("public_stats min_users must be at least 1")
+		}
+		if c.PublicStats.Epsilon < 0 {
+			return fmt.Errorf("public_stats epsilon must not be negative")
+		}
+		if c.PublicStats.MaxDays < 1 {
+			return fmt.Errorf("public_stats max_days must be at least 1")
+		}
+		if len(c.PublicStats.Secret) < 32 {
+			return fmt.Errorf("public_stats secret must be at least 32 characters")
+		}
+	}
+
+	if c.Dictionary.Enabled && c.Dictionary.Path == "" {
+		return fmt.Errorf("dictionary path is required when the dictionary is enabled")
+	}
+	for _, lang := range c.Expansion.KeyboardLayouts {
+		if !translit.ValidLanguage(lang) {
+			return fmt.Errorf("invalid expansion keyboard layout %q (must be ru or uk)", lang)
+		}
+	}
+	for _, lang := range c.Expansion.Transliteration {
+		if !translit.ValidLanguage(lang) {
+			return fmt.Errorf("invalid expansion transliteration language %q (must be ru or uk)", lang)
+		}
+	}
+
+	if c.SavedMessages.Enabled && c.SavedMessages.MaxTags < 1 {
+		return fmt.Errorf("saved_messages max_tags must be at least 1")
+	}
+
+	if c.Tenants.Enabled {
+		if c.SearchEngine.Type != "elasticsearch" {
+			return fmt.Errorf("tenants require the elasticsearch search engine")
+		}
+		if !c.Auth.Enabled && !c.Auth.UseJWT {
+			return fmt.Errorf("tenants require auth to be enabled")
+		}
+	}
+
+	if c.Archive.Enabled {
+		if c.Archive.Interval <= 0 {
+			return fmt.Errorf("archive interval must be positive")
+		}
+		if c.Archive.S3.Endpoint == "" || c.Archive.S3.Bucket == "" {
+			return fmt.Errorf("archive s3 endpoint and bucket are required when archiving is enabled")
+		}
+	}
+
+	if c.Backup.Enabled {
+		switch c.Backup.Type {
+		case "ndjson":
+			if c.Backup.Path == "" {
+				return fmt.Errorf("backup path is required for ndjson backups")
+			}
+			if c.Backup.SegmentSize < 1 {
+				return fmt.Errorf("backup segment_size must be positive")
+			}
+			if c.Backup.S3.Enabled && (c.Backup.S3.Endpoint == "" || c.Backup.S3.Bucket == "") {
+				return fmt.Errorf("backup s3 endpoint and bucket are required when s3 is enabled")
+			}
+		case "snapshot":
+			if c.Backup.Repository == "" {
+				return fmt.Errorf("backup repository is required for snapshot backups")
+			}
+		default:
+			return fmt.Errorf("unsupported backup type %q (supported: ndjson, snapshot)", c.Backup.Type)
+		}
+	}
+
+	if c.Replication.Enabled {
+		if c.Replication.Elasticsearch.Host == "" || c.Replication.Elasticsearch.Index == "" {
+			return fmt.Errorf("replication elasticsearch host and index are required when replication is enabled")
+		}
+		if c.Replication.Elasticsearch.Host == c.Elasticsearch.Host && c.Replication.Elasticsearch.Index == c.Elasticsearch.Index {
+			return fmt.Errorf("replication cannot target the primary index")
+		}
+		if c.Replication.Elasticsearch.Partition != "" && c.Replication.Elasticsearch.Partition != "month" {
+			return fmt.Errorf("invalid replication elasticsearch partition %q (must be month or empty)", c.Replication.Elasticsearch.Partition)
+		}
+		if c.Replication.Elasticsearch.ILMPolicy != "" && c.Replication.Elasticsearch.Partition == "" {
+			return fmt.Errorf("replication elasticsearch ilm_policy requires partition to be set")
+		}
+		if !validChineseFolding(c.Replication.Elasticsearch.ChineseFolding) {
+			return fmt.Errorf("invalid replication elasticsearch chinese_folding %q (must be t2s, s2t or empty)", c.Replication.Elasticsearch.ChineseFolding)
+		}
+		if c.Replication.QueueSize <= 0 || c.Replication.BatchSize <= 0 {
+			return fmt.Errorf("replication queue_size and batch_size must be positive")
+		}
+		if c.Replication.MaxBackoff <= 0 {
+			return fmt.Errorf("replication max_backoff must be positive")
+		}
+	}
+
+	if c.Events.Enabled {
+		switch c.Events.Sink {
+		case "webhook":
+			if !strings.HasPrefix(c.Events.Webhook.URL, "http://") && !strings.HasPrefix(c.Events.Webhook.URL, "https://") {
+				return fmt.Errorf("invalid events webhook url %q: must be an http(s) URL", c.Events.Webhook.URL)
+			}
+		case "nats":
+			if c.Events.NATS.URL == "" || c.Events.NATS.Subject == "" {
+				return fmt.Errorf("events nats url and subject are required for the nats sink")
+			}
+		case "kafka":
+			if len(c.Events.Kafka.Brokers) == 0 || c.Events.Kafka.Topic == "" {
+				return fmt.Errorf("events kafka brokers and topic are required for the kafka sink")
+			}
+		default:
+			return fmt.Errorf("unsupported events sink %q (supported: webhook, nats, kafka)", c.Events.Sink)
+		}
+		for _, eventType := range c.Events.Types {
+			if !slices.Contains(models.EventTypes(), eventType) {
+				return fmt.Errorf("unknown event type %q (supported: %s)", eventType, strings.Join(models.EventTypes(), ", "))
+			}
+		}
+		if c.Events.QueueSize <= 0 || c.Events.BatchSize <= 0 || c.Events.MaxAttempts <= 0 {
+			return fmt.Errorf("events queue_size, batch_size and max_attempts must be positive")
+		}
+		if c.Events.MaxBackoff <= 0 {
+			return fmt.Errorf("events max_backoff must be positive")
+		}
+	}
+
+	if c.Embeddings.Enabled {
+		if c.Embeddings.URL == "" {
+			return fmt.Errorf("embeddings url is required when embeddings are enabled")
+		}
+		if c.Embeddings.Dimensions < 1 || c.Embeddings.Dimensions > 4096 {
+			return fmt.Errorf("embeddings dimensions must be between 1 and 4096")
+		}
+		if c.Embeddings.Timeout <= 0 {
+			return fmt.Errorf("embeddings timeout must be positive")
+		}
+		if c.Embeddings.LexicalWeight < 0 || c.Embeddings.LexicalWeight > 10 ||
+			c.Embeddings.SemanticWeight < 0 || c.Embeddings.SemanticWeight > 10 {
+			return fmt.Errorf("embeddings lexical_weight and semantic_weight must be between 0 and 10")
+		}
+		if c.Embeddings.LexicalWeight == 0 && c.Embeddings.SemanticWeight == 0 {
+			return fmt.Errorf("embeddings lexical_weight and semantic_weight cannot both be 0")
+		}
+	}
+
+	if c.Translation.Enabled {
+		if c.Translation.URL == "" {
+			return fmt.Errorf("translation url is required when translation is enabled")
+		}
+		if len(c.Translation.Targets) == 0 {
+			return fmt.Errorf("translation targets must not be empty")
+		}
+		if c.Translation.Timeout <= 0 {
+			return fmt.Errorf("translation timeout must be positive")
+		}
+	}
+
+	if c.Sessions.Enabled {
+		if !c.Auth.UseJWT && !c.Auth.Enabled {
+			return fmt.Errorf("sessions require auth to be enabled")
+		}
+		if c.Sessions.TTL <= 0 {
+			return fmt.Errorf("sessions ttl must be positive")
+		}
+		if c.Sessions.CookieName == "" {
+			return fmt.Errorf("sessions cookie_name is required when sessions are enabled")
+		}
+	}
+
+	if c.SignedURLs.Enabled {
+		if !c.Auth.UseJWT && !c.Auth.Enabled {
+			return fmt.Errorf("signed_urls require auth to be enabled")
+		}
+		if len(c.SignedURLs.Secret) < 32 {
+			return fmt.Errorf("signed_urls secret must be at least 32 characters")
+		}
+		if c.SignedURLs.DefaultTTL <= 0 || c.SignedURLs.MaxTTL < c.SignedURLs.DefaultTTL {
+			return fmt.Errorf("signed_urls default_ttl must be positive and not exceed max_ttl")
+		}
+		for _, route := range c.SignedURLs.Routes {
+			if !strings.HasPrefix(route, "/") {
+				return fmt.Errorf("invalid signed_urls route %q: must start with /", route)
+			}
+		}
+	}
+
+	if c.Rerank.Enabled {
+		if !c.Embeddings.Enabled {
+			return fmt.Errorf("rerank requires embeddings to be enabled")
+		}
+		if c.Rerank.URL == "" {
+			return fmt.Errorf("rerank url is required when rerank is enabled")
+		}
+		if c.Rerank.TopK < 0 || c.Rerank.TopK > 200 {
+			return fmt.Errorf("rerank top_k must be between 0 and 200")
+		}
+		if c.Rerank.Timeout <= 0 {
+			return fmt.Errorf("rerank timeout must be positive")
+		}
+	}
+
+	for issuer, groups := range c.Routes.Roles {
+		for _, group := range groups {
+			if group != "read" && group != "write" && group != "admin" {
+				return fmt.Errorf("invalid route group %q for issuer %s, must be read, write or admin", group, issuer)
+			}
+		}
+	}
+
+	for group, methods := range c.Routes.Auth {
+		if group != "read" && group != "write" && group != "admin" {
+			return fmt.Errorf("invalid route group %q in routes auth, must be read, write or admin", group)
+		}
+		if len(methods) == 0 {
+			return fmt.Errorf("routes auth for group %s must not be empty", group)
+		}
+		for _, method := range methods {
+			if !middleware.ValidAuthMethod(method) {
+				return fmt.Errorf("invalid credential %q for route group %s, must be jwt or api_key", method, group)
+			}
+			if method == middleware.AuthMethodJWT && !c.Auth.UseJWT {
+				return fmt.Errorf("route group %s accepts jwt but JWT auth is disabled", group)
+			}
+			if method == middleware.AuthMethodAPIKey && !c.Auth.Enabled && len(c.Auth.APIKeys) == 0 && !c.Tenants.Enabled {
+				return fmt.Errorf("route group %s accepts api_key but no API keys are configured", group)
+			}
+		}
+	}
+
+	for issuer, entries := range c.Routes.Operations {
+		if len(entries) == 0 {
+			return fmt.Errorf("operations for %s must not be empty", issuer)
+		}
+		for _, entry := range entries {
+			if _, _, ok := middleware.ParseOperation(entry); !ok {
+				return fmt.Errorf("invalid operation %q for %s, must be a route such as \"POST /upsert\" or \"/export/*\"", entry, issuer)
+			}
+		}
+	}
+
+	// Validate response field naming
+	for issuer, fieldCase := range c.Response.IssuerFieldCase {
+		if fieldCase != "snake" && fieldCase != "camel" {
+			return fmt.Errorf("invalid response field_case %q for issuer %s: must be snake or camel", fieldCase, issuer)
+		}
+	}
+	if c.Response.FieldCase != "snake" && c.Response.FieldCase != "camel" {
+		return fmt.Errorf("invalid response field_case %q: must be snake or camel", c.Response.FieldCase)
+	}
+
+	// Validate timezone
+	if _, err := c.Time.Location(); err != nil {
+		return fmt.Errorf("invalid timezone %q: %w", c.Time.Timezone, err)
 	}
 
-	// Validate auth config
-	if c.Auth.Enabled && c.Auth.APIKey == "" {
-		return fmt.Errorf("API key is required when auth is enabled")
-	}
-
 	// Validate JWT config
 	if c.Auth.UseJWT {
 		if c.Auth.Issuer == "" {
@@ -238,15 +2050,59 @@ func (c *Config) Validate() error {
 		if c.Auth.Audience == "" {
 			return fmt.Errorf("JWT audience is required when JWT auth is enabled")
 		}
-		// Either path-based OR inline keys are acceptable
-		if c.Auth.PublicKeyPath == "" && c.Auth.PublicKeyInline == nil {
-			return fmt.Errorf("JWT public key (path or inline) is required when JWT auth is enabled")
+		// Either path-based OR inline keys are acceptable, or a remote JWKS
+		if c.Auth.PublicKeyPath == "" && c.Auth.PublicKeyInline == nil && c.Auth.JWKSURL == "" {
+			return fmt.Errorf("JWT public key (path or inline) or jwks_url is required when JWT auth is enabled")
+		}
+		if c.Auth.JWKSURL != "" && !strings.HasPrefix(c.Auth.JWKSURL, "http://") && !strings.HasPrefix(c.Auth.JWKSURL, "https://") {
+			return fmt.Errorf("invalid JWT jwks_url %q: must be an http(s) URL", c.Auth.JWKSURL)
+		}
+		if len(c.Auth.AllowedIssuers) == 0 {
+			return fmt.Errorf("JWT allowed_issuers must not be empty when JWT auth is enabled")
+		}
+	}
+	if c.Auth.TokenEndpoint {
+		if !c.Auth.UseJWT {
+			return fmt.Errorf("auth token_endpoint requires JWT auth to be enabled")
+		}
+		if c.Auth.PrivateKeyPath == "" && c.Auth.PrivateKeyInline == nil {
+			return fmt.Errorf("auth token_endpoint requires a JWT private key (path or inline)")
+		}
+		if c.Auth.MaxTokenTTL <= 0 {
+			return fmt.Errorf("auth max_token_ttl must be positive")
 		}
 	}
 
 	return nil
 }
 
+// validKeyHash reports whether hash is a hex SHA-256
+func validKeyHash(hash string) bool {
+	decoded, err := hex.DecodeString(hash)
+	return err == nil && len(decoded) == sha256.Size
+}
+
+// keyHash returns the hex SHA-256 a key is compared by: hash when given,
+// else that of key, empty for neither
+func keyHash(key, hash string) string {
+	if hash != "" {
+		return strings.ToLower(hash)
+	}
+	if key != "" {
+		return middleware.HashAPIKey(key)
+	}
+	return ""
+}
+
+// validChineseFolding reports whether mode is a supported Chinese folding
+func validChineseFolding(mode string) bool {
+	switch mode {
+	case "", "t2s", "s2t":
+		return true
+	}
+	return false
+}
+
 // configureLogging configures the logging system
 func configureLogging(cfg *LoggingConfig) {
 	// Set log level
@@ -0,0 +1,107 @@
+package degrade
+
+import (
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// latencyWeight is the weight of each new observation in the moving average
+const latencyWeight = 0.2
+
+// Config holds load detection thresholds
+type Config struct {
+	LatencyThreshold time.Duration // Degrade when average search latency exceeds this
+	MaxInFlight      int           // Degrade when this many searches run at once (0 disables)
+	Hold             time.Duration // Minimum time to stay degraded once triggered
+}
+
+// Governor detects search overload from latency and in-flight pressure. While
+// degraded, callers shed expensive work (large pages, exact totals) so every
+// request gets a cheaper answer instead of all of them timing out.
+// A nil Governor never degrades.
+type Governor struct {
+	cfg Config
+
+	mu       sync.Mutex
+	average  time.Duration
+	inFlight int
+	until    time.Time // Degraded until at least this time
+	degraded bool
+}
+
+// NewGovernor creates a load governor
+func NewGovernor(cfg Config) *Governor {
+	if cfg.Hold <= 0 {
+		cfg.Hold = 30 * time.Second
+	}
+	return &Governor{cfg: cfg}
+}
+
+// Begin marks a search as started and reports whether it should run degraded.
+// Every call must be paired with End.
+func (g *Governor) Begin() bool {
+	if g == nil {
+		return false
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.inFlight++
+	if g.cfg.MaxInFlight > 0 && g.inFlight > g.cfg.MaxInFlight {
+		g.trigger("in_flight")
+	}
+	return g.evaluate()
+}
+
+// End records the latency of a finished search
+func (g *Governor) End(latency time.Duration) {
+	if g == nil {
+		return
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.inFlight--
+	if g.average == 0 {
+		g.average = latency
+	} else {
+		g.average += time.Duration(latencyWeight * float64(latency-g.average))
+	}
+	if g.cfg.LatencyThreshold > 0 && g.average > g.cfg.LatencyThreshold {
+		g.trigger("latency")
+	}
+}
+
+// trigger enters (or extends) degraded mode (caller holds lock)
+func (g *Governor) trigger(reason string) {
+	g.until = time.Now().Add(g.cfg.Hold)
+	if g.degraded {
+		return
+	}
+	g.degraded = true
+
+	log.WithFields(log.Fields{
+		"reason":     reason,
+		"average_ms": g.average.Milliseconds(),
+		"in_flight":  g.inFlight,
+	}).Warn("Search load elevated, degrading responses")
+}
+
+// evaluate leaves degraded mode once the hold expired and latency recovered
+// below half the threshold (caller holds lock)
+func (g *Governor) evaluate() bool {
+	if !g.degraded || time.Now().Before(g.until) {
+		return g.degraded
+	}
+	if g.cfg.LatencyThreshold > 0 && g.average > g.cfg.LatencyThreshold/2 {
+		return true
+	}
+
+	g.degraded = false
+	log.WithField("average_ms", g.average.Milliseconds()).Info("Search load recovered, serving full responses")
+	return false
+}
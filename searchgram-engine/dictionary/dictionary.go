@@ -0,0 +1,167 @@
+package dictionary
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Dictionary holds domain terms, such as product names, usernames and
+// project jargon, that are matched as a whole instead of as unrelated
+// bigrams. Terms are read from a text file with one term per line; empty
+// lines and lines starting with # are ignored. Matching ignores case.
+type Dictionary struct {
+	path string
+
+	mu       sync.RWMutex
+	terms    []string          // Lowercased terms, sorted
+	byRune   map[rune][]string // Terms by their first rune, longest first
+	loadedAt time.Time
+}
+
+// Load reads the terms from path
+func Load(path string) (*Dictionary, error) {
+	d := &Dictionary{path: path}
+	if _, err := d.Reload(); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// Reload reads the terms from the file again and returns how many there
+// are. On failure the previous terms stay in use.
+func (d *Dictionary) Reload() (int, error) {
+	file, err := os.Open(d.path)
+	if err != nil {
+		return 0, fmt.Errorf("failed to open dictionary: %w", err)
+	}
+	defer file.Close()
+
+	seen := make(map[string]bool)
+	var terms []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		term := strings.ToLower(strings.TrimSpace(scanner.Text()))
+		if term == "" || strings.HasPrefix(term, "#") || seen[term] {
+			continue
+		}
+		seen[term] = true
+		terms = append(terms, term)
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, fmt.Errorf("failed to read dictionary: %w", err)
+	}
+	sort.Strings(terms)
+
+	byRune := make(map[rune][]string)
+	for _, term := range terms {
+		first, _ := utf8.DecodeRuneInString(term)
+		byRune[first] = append(byRune[first], term)
+	}
+	for _, candidates := range byRune {
+		sort.SliceStable(candidates, func(i, j int) bool {
+			return len(candidates[i]) > len(candidates[j])
+		})
+	}
+
+	d.mu.Lock()
+	d.terms = terms
+	d.byRune = byRune
+	d.loadedAt = time.Now()
+	d.mu.Unlock()
+
+	log.WithFields(log.Fields{
+		"path":  d.path,
+		"terms": len(terms),
+	}).Info("Dictionary loaded")
+
+	return len(terms), nil
+}
+
+// Terms returns all terms, sorted
+func (d *Dictionary) Terms() []string {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return append([]string(nil), d.terms...)
+}
+
+// LoadedAt returns when the terms were last read
+func (d *Dictionary) LoadedAt() time.Time {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.loadedAt
+}
+
+// Match returns the distinct terms text contains, in order of appearance.
+// Where terms overlap, the longest one starting first wins, so "鸿蒙系统"
+// yields only "鸿蒙系统" even if "鸿蒙" is a term as well.
+func (d *Dictionary) Match(text string) []string {
+	if text == "" {
+		return nil
+	}
+	text = strings.ToLower(text)
+
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	if len(d.byRune) == 0 {
+		return nil
+	}
+
+	var found []string
+	seen := make(map[string]bool)
+	for i := 0; i < len(text); {
+		r, size := utf8.DecodeRuneInString(text[i:])
+		matched := ""
+		if i == 0 || !wordRune(lastRune(text[:i])) || !wordRune(r) {
+			for _, term := range d.byRune[r] {
+				if strings.HasPrefix(text[i:], term) && boundaryAfter(text[i+len(term):], term) {
+					matched = term
+					break
+				}
+			}
+		}
+		if matched == "" {
+			i += size
+			continue
+		}
+		if !seen[matched] {
+			seen[matched] = true
+			found = append(found, matched)
+		}
+		i += len(matched)
+	}
+	return found
+}
+
+// wordRune reports whether r is a letter or digit written with spaces
+// between words, so terms must not start or end inside such a word. CJK
+// text has no spaces and matches anywhere.
+func wordRune(r rune) bool {
+	if unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul) {
+		return false
+	}
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// lastRune returns the last rune of s
+func lastRune(s string) rune {
+	r, _ := utf8.DecodeLastRuneInString(s)
+	return r
+}
+
+// boundaryAfter reports whether a term may end before rest
+func boundaryAfter(rest, term string) bool {
+	if rest == "" {
+		return true
+	}
+	next, _ := utf8.DecodeRuneInString(rest)
+	return !wordRune(lastRune(term)) || !wordRune(next)
+}
@@ -4,7 +4,10 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"sort"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/olivere/elastic/v7"
@@ -16,6 +19,23 @@ const (
 	defaultIndex = "telegram"
 	defaultShards = 3
 	defaultReplicas = 1
+
+	// maxUpdateRetries bounds read-modify-write attempts on version conflicts
+	maxUpdateRetries = 3
+
+	// Dedup scans dedupPageSize groups per page; groups up to
+	// dedupInlineGroupSize documents are listed and bulk-deleted in
+	// multi-searches of dedupMultiSearchSize, larger ones via delete-by-query
+	dedupPageSize        = 1000
+	dedupInlineGroupSize = 100
+	dedupMultiSearchSize = 100
+
+	// degradedTotalHits bounds total hit counting for searches under load
+	degradedTotalHits = 10000
+
+	// Dry-run reports list at most this many chats and sample groups
+	dedupReportChats   = 100
+	dedupReportSamples = 20
 )
 
 // ElasticsearchEngine implements SearchEngine for Elasticsearch
@@ -23,11 +43,34 @@ type ElasticsearchEngine struct {
 	client    *elastic.Client
 	host      string
 	index     string
+	shards    int
+	replicas  int
 	startTime time.Time
+
+	trashMu    sync.Mutex
+	trashReady bool // Recycle bin index exists with current mappings
+
+	auditMu    sync.Mutex
+	auditReady bool // Audit index exists
+
+	partition     PartitionConfig
+	partitioned   atomic.Bool // The alias points to monthly partitions, so writes are routed by message time
+	partitionMu   sync.Mutex
+	partitions    map[string]bool // Partitions known to exist
+	lifecycleHeld atomic.Bool     // Partitions get no lifecycle policy, e.g. during a legal hold
+
+	analysis AnalysisConfig // Optional analysis steps of new indices
+
+	reranker      Reranker        // Optional second stage for hybrid semantic search
+	dictionary    TermMatcher     // Domain terms matched as a whole (nil = none)
+	expander      KeywordExpander // Other spellings searched for keywords (nil = none)
+	embeddingDims int             // Dimensions of the mapped embedding field (0 = unmapped)
+	sealer        TextSealer      // Encrypts message text before indexing (nil = stored as is)
 }
 
-// NewElasticsearch creates a new Elasticsearch search engine
-func NewElasticsearch(host, username, password, index string, shards, replicas int) (*ElasticsearchEngine, error) {
+// NewElasticsearch creates a new Elasticsearch search engine. extra client
+// options, such as an instrumented HTTP client, are applied last.
+func NewElasticsearch(host, username, password, index string, shards, replicas int, partition PartitionConfig, analysis AnalysisConfig, extra ...elastic.ClientOptionFunc) (*ElasticsearchEngine, error) {
 	if index == "" {
 		index = defaultIndex
 	}
@@ -49,6 +92,7 @@ func NewElasticsearch(host, username, password, index string, shards, replicas i
 	if username != "" && password != "" {
 		options = append(options, elastic.SetBasicAuth(username, password))
 	}
+	options = append(options, extra...)
 
 	client, err := elastic.NewClient(options...)
 	if err != nil {
@@ -56,10 +100,15 @@ func NewElasticsearch(host, username, password, index string, shards, replicas i
 	}
 
 	engine := &ElasticsearchEngine{
-		client:    client,
-		host:      host,
-		index:     index,
-		startTime: time.Now(),
+		client:     client,
+		host:       host,
+		index:      index,
+		shards:     shards,
+		replicas:   replicas,
+		startTime:  time.Now(),
+		partition:  partition,
+		partitions: make(map[string]bool),
+		analysis:   analysis,
 	}
 
 	// Initialize index with proper mappings
@@ -87,223 +136,403 @@ func (e *ElasticsearchEngine) initializeIndex(shards, replicas int) error {
 
 	if exists {
 		log.WithField("index", e.index).Info("Index already exists")
+		if err := e.refreshPartitioned(ctx); err != nil {
+			return err
+		}
+		if e.partition.Period != "" && !e.partitioned.Load() {
+			log.WithField("index", e.index).Warn("Messages stay in one index until a reindex moves them into monthly partitions")
+		}
+		e.checkFolding(ctx)
+		return e.updateMapping(ctx)
+	}
+
+	// Monthly partitions start out with the current month's
+	if e.partition.Period == PartitionMonth {
+		if err := e.ensurePartition(ctx, e.partitionIndex(time.Now()), true); err != nil {
+			return err
+		}
+		e.partitioned.Store(true)
+		return nil
+	}
+
+	// Create a first version with CJK-optimized settings behind the index
+	// name as an alias, so reindexing and rolling back can switch versions
+	version := e.versionIndex(time.Now())
+	if err := e.createIndex(ctx, version, e.index, nil); err != nil {
+		return err
+	}
+
+	log.WithFields(log.Fields{
+		"index": version,
+		"alias": e.index,
+	}).Info("Created index with CJK optimization")
+	return nil
+}
+
+// updateMapping adds fields introduced since the index was created.
+// Elasticsearch only accepts additive changes, so conflicts are logged and skipped.
+func (e *ElasticsearchEngine) updateMapping(ctx context.Context) error {
+	_, err := e.client.PutMapping().
+		Index(e.index).
+		BodyJson(map[string]interface{}{
+			"properties": indexProperties(),
+		}).
+		Do(ctx)
+	if err != nil {
+		log.WithError(err).WithField("index", e.index).Warn("Failed to update index mapping, new fields may be dynamically mapped")
 		return nil
 	}
 
-	// Create index with CJK-optimized settings
-	indexSettings := map[string]interface{}{
-		"settings": map[string]interface{}{
-			"number_of_shards":   shards,
-			"number_of_replicas": replicas,
-			"analysis": map[string]interface{}{
-				"analyzer": map[string]interface{}{
-					"cjk_analyzer": map[string]interface{}{
-						"type":      "custom",
-						"tokenizer": "standard",
-						"filter":    []string{"cjk_width", "lowercase", "cjk_bigram"},
-					},
-					"exact_analyzer": map[string]interface{}{
-						"type":      "custom",
-						"tokenizer": "keyword",
-						"filter":    []string{"lowercase"},
-					},
+	log.WithField("index", e.index).Info("Index mapping up to date")
+	return nil
+}
+
+// indexAnalysis returns the CJK-optimized analyzers used by message fields,
+// folding Chinese scripts first when configured
+func (e *ElasticsearchEngine) indexAnalysis() map[string]interface{} {
+	cjkAnalyzer := map[string]interface{}{
+		"type":      "custom",
+		"tokenizer": "standard",
+		"filter":    []string{"cjk_width", "lowercase", "cjk_bigram"},
+	}
+	exactAnalyzer := map[string]interface{}{
+		"type":      "custom",
+		"tokenizer": "keyword",
+		"filter":    []string{"lowercase"},
+	}
+	analysis := map[string]interface{}{
+		"analyzer": map[string]interface{}{
+			"cjk_analyzer":   cjkAnalyzer,
+			"exact_analyzer": exactAnalyzer,
+		},
+	}
+
+	if charFilters := e.analysis.charFilters(); charFilters != nil {
+		analysis["char_filter"] = charFilters
+		cjkAnalyzer["char_filter"] = []string{foldingCharFilter}
+		exactAnalyzer["char_filter"] = []string{foldingCharFilter}
+	}
+	return analysis
+}
+
+// indexProperties returns the field mappings for message documents
+func indexProperties() map[string]interface{} {
+	return map[string]interface{}{
+		// Core identifiers
+		"id": map[string]interface{}{
+			"type": "keyword",
+		},
+		"message_id": map[string]interface{}{
+			"type": "long",
+		},
+		"chat_id": map[string]interface{}{
+			"type": "long",
+		},
+		"timestamp": map[string]interface{}{
+			"type": "long",
+		},
+		"date": map[string]interface{}{
+			"type": "long",
+		},
+
+		// Chat information (searchable)
+		"chat_type": map[string]interface{}{
+			"type": "keyword",
+		},
+		"chat_title": map[string]interface{}{
+			"type":     "text",
+			"analyzer": "cjk_analyzer",
+		},
+		"chat_username": map[string]interface{}{
+			"type": "keyword",
+		},
+
+		// Sender information (normalized)
+		"sender_type": map[string]interface{}{
+			"type": "keyword",
+		},
+		"sender_id": map[string]interface{}{
+			"type": "long",
+		},
+		"sender_name": map[string]interface{}{
+			"type":     "text",
+			"analyzer": "cjk_analyzer",
+		},
+		"sender_username": map[string]interface{}{
+			"type": "keyword",
+		},
+		"sender_first_name": map[string]interface{}{
+			"type":     "text",
+			"analyzer": "cjk_analyzer",
+		},
+		"sender_last_name": map[string]interface{}{
+			"type":     "text",
+			"analyzer": "cjk_analyzer",
+		},
+		"sender_chat_title": map[string]interface{}{
+			"type":     "text",
+			"analyzer": "cjk_analyzer",
+		},
+
+		// Forward information
+		"is_forwarded": map[string]interface{}{
+			"type": "boolean",
+		},
+		"forward_from_type": map[string]interface{}{
+			"type": "keyword",
+		},
+		"forward_from_id": map[string]interface{}{
+			"type": "long",
+		},
+		"forward_from_name": map[string]interface{}{
+			"type":     "text",
+			"analyzer": "cjk_analyzer",
+		},
+		"forward_timestamp": map[string]interface{}{
+			"type": "long",
+		},
+
+		// Reply and thread information
+		"reply_to_message_id": map[string]interface{}{
+			"type": "long",
+		},
+		"thread_id": map[string]interface{}{
+			"type": "long",
+		},
+
+		// Content information
+		"content_type": map[string]interface{}{
+			"type": "keyword",
+		},
+		"text": map[string]interface{}{
+			"type":     "text",
+			"analyzer": "cjk_analyzer",
+			"fields": map[string]interface{}{
+				"exact": map[string]interface{}{
+					"type":     "text",
+					"analyzer": "exact_analyzer",
 				},
 			},
 		},
-		"mappings": map[string]interface{}{
+		"caption": map[string]interface{}{
+			"type":     "text",
+			"analyzer": "cjk_analyzer",
+		},
+		"text_sealed": map[string]interface{}{
+			"type":    "object",
+			"enabled": false, // Encrypted text, only stored
+		},
+		"text_blind": map[string]interface{}{
+			"type": "keyword", // Keyed hashes of the terms of encrypted text
+		},
+		"sticker_emoji": map[string]interface{}{
+			"type": "keyword",
+		},
+		"sticker_set_name": map[string]interface{}{
+			"type": "keyword",
+		},
+
+		// Media metadata
+		"media_type": map[string]interface{}{
+			"type": "keyword",
+		},
+		"file_name": map[string]interface{}{
+			"type":     "text",
+			"analyzer": "cjk_analyzer",
+			"fields": map[string]interface{}{
+				"keyword": map[string]interface{}{
+					"type":         "keyword",
+					"ignore_above": 256,
+				},
+			},
+		},
+		"mime_type": map[string]interface{}{
+			"type": "keyword",
+		},
+		"media_url": map[string]interface{}{
+			"type":  "keyword",
+			"index": false,
+		},
+		"transcript": map[string]interface{}{
+			"type":     "text",
+			"analyzer": "cjk_analyzer",
+		},
+		"ocr_text": map[string]interface{}{
+			"type":     "text",
+			"analyzer": "cjk_analyzer",
+		},
+		"file_size": map[string]interface{}{
+			"type": "long",
+		},
+
+		// Location
+		"location": map[string]interface{}{
+			"type": "geo_point",
+		},
+
+		// Poll
+		"is_poll": map[string]interface{}{
+			"type": "boolean",
+		},
+		"poll_question": map[string]interface{}{
+			"type":     "text",
+			"analyzer": "cjk_analyzer",
+		},
+		"poll_options": map[string]interface{}{
+			"type":     "text",
+			"analyzer": "cjk_analyzer",
+		},
+
+		// Derived text statistics
+		"text_length": map[string]interface{}{
+			"type": "integer",
+		},
+		"word_count": map[string]interface{}{
+			"type": "integer",
+		},
+		"hour_of_day": map[string]interface{}{
+			"type": "byte",
+		},
+		"weekday": map[string]interface{}{
+			"type": "byte",
+		},
+		"lang": map[string]interface{}{
+			"type": "keyword",
+		},
+		"is_spam": map[string]interface{}{
+			"type": "boolean",
+		},
+		"spam_score": map[string]interface{}{
+			"type": "float",
+		},
+
+		// Edit tracking
+		"edit_count": map[string]interface{}{
+			"type": "integer",
+		},
+		"last_edited": map[string]interface{}{
+			"type": "long",
+		},
+
+		// Engagement
+		"reactions_count": map[string]interface{}{
+			"type": "integer",
+		},
+		"views": map[string]interface{}{
+			"type": "integer",
+		},
+		"forwards": map[string]interface{}{
+			"type": "integer",
+		},
+
+		// Entity values
+		"urls": map[string]interface{}{
+			"type":         "keyword",
+			"ignore_above": 2048,
+		},
+		"mentions": map[string]interface{}{
+			"type": "keyword",
+		},
+		"hashtags": map[string]interface{}{
+			"type": "keyword",
+		},
+		"terms": map[string]interface{}{
+			"type": "keyword",
+		},
+
+		// Entities (unchanged)
+		"entities": map[string]interface{}{
+			"type": "nested",
 			"properties": map[string]interface{}{
-				// Core identifiers
-				"id": map[string]interface{}{
+				"type": map[string]interface{}{
 					"type": "keyword",
 				},
-				"message_id": map[string]interface{}{
-					"type": "long",
-				},
-				"chat_id": map[string]interface{}{
-					"type": "long",
+				"offset": map[string]interface{}{
+					"type": "integer",
 				},
-				"timestamp": map[string]interface{}{
-					"type": "long",
+				"length": map[string]interface{}{
+					"type": "integer",
 				},
-				"date": map[string]interface{}{
+				"user_id": map[string]interface{}{
 					"type": "long",
 				},
+			},
+		},
 
-				// Chat information (searchable)
-				"chat_type": map[string]interface{}{
-					"type": "keyword",
-				},
-				"chat_title": map[string]interface{}{
-					"type":     "text",
-					"analyzer": "cjk_analyzer",
-				},
-				"chat_username": map[string]interface{}{
-					"type": "keyword",
-				},
+		// Soft-delete (unchanged)
+		"is_deleted": map[string]interface{}{
+			"type": "boolean",
+		},
+		"deleted_at": map[string]interface{}{
+			"type": "long",
+		},
+		"deleted_by": map[string]interface{}{
+			"type": "keyword",
+		},
 
-				// Sender information (normalized)
-				"sender_type": map[string]interface{}{
-					"type": "keyword",
-				},
-				"sender_id": map[string]interface{}{
+		// Backward compatibility (deprecated, keep for now)
+		"chat": map[string]interface{}{
+			"properties": map[string]interface{}{
+				"id": map[string]interface{}{
 					"type": "long",
 				},
-				"sender_name": map[string]interface{}{
-					"type":     "text",
-					"analyzer": "cjk_analyzer",
-				},
-				"sender_username": map[string]interface{}{
+				"type": map[string]interface{}{
 					"type": "keyword",
 				},
-				"sender_first_name": map[string]interface{}{
-					"type":     "text",
-					"analyzer": "cjk_analyzer",
-				},
-				"sender_last_name": map[string]interface{}{
+				"title": map[string]interface{}{
 					"type":     "text",
 					"analyzer": "cjk_analyzer",
 				},
-				"sender_chat_title": map[string]interface{}{
-					"type":     "text",
-					"analyzer": "cjk_analyzer",
-				},
-
-				// Forward information
-				"is_forwarded": map[string]interface{}{
-					"type": "boolean",
-				},
-				"forward_from_type": map[string]interface{}{
+				"username": map[string]interface{}{
 					"type": "keyword",
 				},
-				"forward_from_id": map[string]interface{}{
-					"type": "long",
-				},
-				"forward_from_name": map[string]interface{}{
-					"type":     "text",
-					"analyzer": "cjk_analyzer",
-				},
-				"forward_timestamp": map[string]interface{}{
+			},
+		},
+		"from_user": map[string]interface{}{
+			"properties": map[string]interface{}{
+				"id": map[string]interface{}{
 					"type": "long",
 				},
-
-				// Content information
-				"content_type": map[string]interface{}{
-					"type": "keyword",
+				"is_bot": map[string]interface{}{
+					"type": "boolean",
 				},
-				"text": map[string]interface{}{
+				"first_name": map[string]interface{}{
 					"type":     "text",
 					"analyzer": "cjk_analyzer",
-					"fields": map[string]interface{}{
-						"exact": map[string]interface{}{
-							"type":     "text",
-							"analyzer": "exact_analyzer",
-						},
-					},
 				},
-				"caption": map[string]interface{}{
+				"last_name": map[string]interface{}{
 					"type":     "text",
 					"analyzer": "cjk_analyzer",
 				},
-				"sticker_emoji": map[string]interface{}{
+				"username": map[string]interface{}{
 					"type": "keyword",
 				},
-				"sticker_set_name": map[string]interface{}{
-					"type": "keyword",
-				},
-
-				// Entities (unchanged)
-				"entities": map[string]interface{}{
-					"type": "nested",
-					"properties": map[string]interface{}{
-						"type": map[string]interface{}{
-							"type": "keyword",
-						},
-						"offset": map[string]interface{}{
-							"type": "integer",
-						},
-						"length": map[string]interface{}{
-							"type": "integer",
-						},
-						"user_id": map[string]interface{}{
-							"type": "long",
-						},
-					},
-				},
-
-				// Soft-delete (unchanged)
-				"is_deleted": map[string]interface{}{
-					"type": "boolean",
-				},
-				"deleted_at": map[string]interface{}{
-					"type": "long",
-				},
-
-				// Backward compatibility (deprecated, keep for now)
-				"chat": map[string]interface{}{
-					"properties": map[string]interface{}{
-						"id": map[string]interface{}{
-							"type": "long",
-						},
-						"type": map[string]interface{}{
-							"type": "keyword",
-						},
-						"title": map[string]interface{}{
-							"type":     "text",
-							"analyzer": "cjk_analyzer",
-						},
-						"username": map[string]interface{}{
-							"type": "keyword",
-						},
-					},
-				},
-				"from_user": map[string]interface{}{
-					"properties": map[string]interface{}{
-						"id": map[string]interface{}{
-							"type": "long",
-						},
-						"is_bot": map[string]interface{}{
-							"type": "boolean",
-						},
-						"first_name": map[string]interface{}{
-							"type":     "text",
-							"analyzer": "cjk_analyzer",
-						},
-						"last_name": map[string]interface{}{
-							"type":     "text",
-							"analyzer": "cjk_analyzer",
-						},
-						"username": map[string]interface{}{
-							"type": "keyword",
-						},
-					},
-				},
-
-				// Full message (stored, not indexed)
-				"raw_message": map[string]interface{}{
-					"type":    "object",
-					"enabled": false, // Don't index, just store
-				},
 			},
 		},
-	}
 
-	_, err = e.client.CreateIndex(e.index).BodyJson(indexSettings).Do(ctx)
-	if err != nil {
-		return fmt.Errorf("failed to create index: %w", err)
+		// Full message (stored, not indexed)
+		"raw_message": map[string]interface{}{
+			"type":    "object",
+			"enabled": false, // Don't index, just store
+		},
 	}
-
-	log.WithField("index", e.index).Info("Created index with CJK optimization")
-	return nil
 }
 
 // Upsert indexes or updates a message
-func (e *ElasticsearchEngine) Upsert(message *models.Message) error {
-	ctx := context.Background()
+func (e *ElasticsearchEngine) Upsert(ctx context.Context, message *models.Message) error {
+	index, err := e.writeIndex(ctx, message)
+	if err != nil {
+		return fmt.Errorf("failed to upsert document: %w", err)
+	}
+	doc, err := e.document(message)
+	if err != nil {
+		return fmt.Errorf("failed to upsert document: %w", err)
+	}
 
-	_, err := e.client.Index().
-		Index(e.index).
+	_, err = e.client.Index().
+		Index(index).
 		Id(message.ID).
-		BodyJson(message).
+		BodyJson(doc).
 		Do(ctx)
 
 	if err != nil {
@@ -313,10 +542,125 @@ func (e *ElasticsearchEngine) Upsert(message *models.Message) error {
 	return nil
 }
 
-// UpsertBatch indexes or updates multiple messages using the Bulk API
-func (e *ElasticsearchEngine) UpsertBatch(messages []models.Message) (int, []string, error) {
-	ctx := context.Background()
+// GetMessage retrieves a single message by composite ID
+func (e *ElasticsearchEngine) GetMessage(ctx context.Context, id string) (*models.Message, error) {
+	doc, err := e.getDocument(ctx, id, true)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get message %s: %w", id, err)
+	}
+	if doc == nil {
+		return nil, nil
+	}
+
+	var message models.Message
+	if err := e.decodeMessage(doc.Source, &message); err != nil {
+		return nil, fmt.Errorf("failed to decode message %s: %w", id, err)
+	}
+
+	return &message, nil
+}
+
+// GetMessageContext retrieves the messages surrounding messageID in a chat
+func (e *ElasticsearchEngine) GetMessageContext(ctx context.Context, chatID, messageID int64, before, after int) ([]models.Message, []models.Message, error) {
+	fetch := func(size int, rangeQuery *elastic.RangeQuery, ascending bool) ([]models.Message, error) {
+		if size <= 0 {
+			return []models.Message{}, nil
+		}
+
+		// Use new field with fallback to old for backward compat
+		chatFilter := elastic.NewBoolQuery()
+		chatFilter.Should(elastic.NewTermQuery("chat_id", chatID))
+		chatFilter.Should(elastic.NewTermQuery("chat.id", chatID))
+
+		query := elastic.NewBoolQuery().
+			Filter(chatFilter).
+			Filter(rangeQuery).
+			MustNot(elastic.NewTermQuery("is_deleted", true))
+
+		result, err := e.client.Search().
+			Index(e.index).
+			Query(query).
+			Sort("message_id", ascending).
+			Size(size).
+			Do(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		messages := make([]models.Message, 0, len(result.Hits.Hits))
+		for _, hit := range result.Hits.Hits {
+			var msg models.Message
+			if err := e.decodeMessage(hit.Source, &msg); err != nil {
+				log.WithError(err).Warn("Failed to unmarshal message")
+				continue
+			}
+			messages = append(messages, msg)
+		}
+		return messages, nil
+	}
+
+	beforeMessages, err := fetch(before, elastic.NewRangeQuery("message_id").Lt(messageID), false)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to fetch preceding messages: %w", err)
+	}
+	// Fetched nearest-first; flip to chronological order
+	for i, j := 0, len(beforeMessages)-1; i < j; i, j = i+1, j-1 {
+		beforeMessages[i], beforeMessages[j] = beforeMessages[j], beforeMessages[i]
+	}
+
+	afterMessages, err := fetch(after, elastic.NewRangeQuery("message_id").Gt(messageID), true)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to fetch following messages: %w", err)
+	}
+
+	return beforeMessages, afterMessages, nil
+}
+
+// UpdateMessage performs a read-modify-write of a single message, retrying
+// when a concurrent write changes the document in between
+func (e *ElasticsearchEngine) UpdateMessage(ctx context.Context, id string, fn func(message *models.Message)) (*models.Message, error) {
+	for attempt := 0; attempt < maxUpdateRetries; attempt++ {
+		doc, err := e.getDocument(ctx, id, true)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get message %s: %w", id, err)
+		}
+		if doc == nil {
+			return nil, nil
+		}
 
+		var message models.Message
+		if err := e.decodeMessage(doc.Source, &message); err != nil {
+			return nil, fmt.Errorf("failed to decode message %s: %w", id, err)
+		}
+
+		fn(&message)
+
+		body, err := e.document(&message)
+		if err != nil {
+			return nil, fmt.Errorf("failed to update message %s: %w", id, err)
+		}
+		_, err = e.client.Index().
+			Index(doc.Index).
+			Id(id).
+			IfSeqNo(*doc.SeqNo).
+			IfPrimaryTerm(*doc.PrimaryTerm).
+			BodyJson(body).
+			Do(ctx)
+		if elastic.IsConflict(err) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to update message %s: %w", id, err)
+		}
+
+		return &message, nil
+	}
+
+	return nil, fmt.Errorf("failed to update message %s: too many concurrent modifications", id)
+}
+
+// UpsertBatch indexes or updates multiple messages using the Bulk API
+func (e *ElasticsearchEngine) UpsertBatch(ctx context.Context, messages []models.Message) (int, []string, error) {
 	if len(messages) == 0 {
 		return 0, nil, nil
 	}
@@ -324,11 +668,20 @@ func (e *ElasticsearchEngine) UpsertBatch(messages []models.Message) (int, []str
 	// Create bulk request
 	bulkRequest := e.client.Bulk().Index(e.index)
 
-	// Add all messages to bulk request
+	// Add all messages to bulk request, each into its partition if any
 	for i := range messages {
+		index, err := e.writeIndex(ctx, &messages[i])
+		if err != nil {
+			return 0, nil, fmt.Errorf("failed to execute bulk upsert: %w", err)
+		}
+		doc, err := e.document(&messages[i])
+		if err != nil {
+			return 0, nil, fmt.Errorf("failed to execute bulk upsert: %w", err)
+		}
 		req := elastic.NewBulkIndexRequest().
+			Index(index).
 			Id(messages[i].ID).
-			Doc(&messages[i])
+			Doc(doc)
 		bulkRequest.Add(req)
 	}
 
@@ -374,7 +727,7 @@ func (e *ElasticsearchEngine) UpsertBatch(messages []models.Message) (int, []str
 
 // Search performs a search query
 func (e *ElasticsearchEngine) Search(req *models.SearchRequest) (*models.SearchResponse, error) {
-	ctx := context.Background()
+	ctx := req.Context()
 
 	// DEBUG: Log incoming search request
 	log.WithFields(log.Fields{
@@ -391,18 +744,121 @@ func (e *ElasticsearchEngine) Search(req *models.SearchRequest) (*models.SearchR
 		"page_size":       req.PageSize,
 	}).Info("DEBUG: Incoming search request")
 
+	// Pagination
+	if req.Page < 1 {
+		req.Page = 1
+	}
+	if req.PageSize < 1 {
+		req.PageSize = 10
+	}
+	from := (req.Page - 1) * req.PageSize
+
+	if req.Snapshot != "" {
+		if err := e.checkMounted(ctx, req.Snapshot); err != nil {
+			return nil, err
+		}
+	}
+
+	if req.Semantic && len(req.QueryVector) > 0 {
+		return e.semanticSearch(ctx, req)
+	}
+
 	// Build the query
+	boolQuery := e.buildQuery(req)
+
+	// DEBUG: Log the final query
+	querySource, _ := boolQuery.Source()
+	log.WithFields(log.Fields{
+		"query":     querySource,
+		"from":      from,
+		"size":      req.PageSize,
+		"index":     e.indexFor(req),
+	}).Info("DEBUG: Executing Elasticsearch query")
+
+	// Boosting adds log(1 + engagement) to the relevance score
+	var query elastic.Query = boolQuery
+	if field := models.EngagementField(req.BoostBy); field != "" {
+		query = elastic.NewFunctionScoreQuery().
+			Query(boolQuery).
+			AddScoreFunc(elastic.NewFieldValueFactorFunction().Field(field).Modifier("log1p").Missing(0)).
+			BoostMode("sum")
+	}
+
+	// Execute search
+	search := e.client.Search().
+		Index(e.indexFor(req)).
+		Query(query).
+		FetchSourceContext(searchSource())
+
+	// Primary key plus tie-breakers, so pages are stable
+	sortOrder := models.SortOrder(req.SortBy)
+	search = sortedSearch(search, sortOrder)
+
+	// Under load, stop counting at degradedTotalHits instead of counting every match
+	var trackTotalHits interface{} = true
+	if req.Degraded {
+		trackTotalHits = degradedTotalHits
+	}
+
+	searchResult, err := search.
+		From(from).
+		Size(req.HitLimit()).
+		TrackTotalHits(trackTotalHits).
+		Do(ctx)
+
+	if err != nil {
+		log.WithError(err).Error("DEBUG: Elasticsearch query failed")
+		return nil, fmt.Errorf("search query failed: %w", err)
+	}
+
+	// DEBUG: Log search results
+	log.WithFields(log.Fields{
+		"total_hits":    searchResult.Hits.TotalHits.Value,
+		"returned_hits": len(searchResult.Hits.Hits),
+		"took_ms":       searchResult.TookInMillis,
+	}).Info("DEBUG: Search results received")
+
+	// Parse results
+	messages := e.decodeHits(searchResult.Hits.Hits)
+
+	totalHits := searchResult.Hits.TotalHits.Value
+	totalPages := int((totalHits + int64(req.PageSize) - 1) / int64(req.PageSize))
+
+	return &models.SearchResponse{
+		Hits:        messages,
+		TotalHits:   totalHits,
+		TotalPages:  totalPages,
+		Page:        req.Page,
+		HitsPerPage: req.PageSize,
+		Sort:        sortOrder,
+	}, nil
+}
+
+// buildQuery translates search filters into an Elasticsearch bool query
+func (e *ElasticsearchEngine) buildQuery(req *models.SearchRequest) *elastic.BoolQuery {
 	boolQuery := elastic.NewBoolQuery()
 
 	// Text search query (fuzzy or exact)
-	// Search in both text and caption fields
+	// Search in text, caption and file name fields
 	if req.Keyword != "" {
+		// Translations of the keyword are alternatives to it
+		keywords := append([]string{req.Keyword}, req.Translations...)
 		if req.ExactMatch {
 			// Exact match using match_phrase
 			// Search in both text and caption
 			textCaptionQuery := elastic.NewBoolQuery()
-			textCaptionQuery.Should(elastic.NewMatchPhraseQuery("text.exact", req.Keyword))
-			textCaptionQuery.Should(elastic.NewMatchPhraseQuery("caption", req.Keyword))
+			for _, keyword := range keywords {
+				if blind := e.blindQuery(keyword, true); blind != nil {
+					textCaptionQuery.Should(blind)
+				}
+				textCaptionQuery.Should(elastic.NewMatchPhraseQuery("text.exact", keyword))
+				textCaptionQuery.Should(elastic.NewMatchPhraseQuery("caption", keyword))
+				textCaptionQuery.Should(elastic.NewMatchPhraseQuery("file_name", keyword))
+				textCaptionQuery.Should(elastic.NewMatchPhraseQuery("poll_question", keyword))
+				textCaptionQuery.Should(elastic.NewMatchPhraseQuery("poll_options", keyword))
+				textCaptionQuery.Should(elastic.NewMatchPhraseQuery("transcript", keyword))
+				textCaptionQuery.Should(elastic.NewMatchPhraseQuery("ocr_text", keyword))
+			}
 			boolQuery.Must(textCaptionQuery)
 			log.WithField("query_type", "exact_match_phrase").Info("DEBUG: Using exact match query (text + caption)")
 		} else {
@@ -411,9 +867,20 @@ func (e *ElasticsearchEngine) Search(req *models.SearchRequest) (*models.SearchR
 			// NOTE: Fuzziness removed for CJK compatibility
 			// CJK bigram tokenization doesn't work well with AUTO fuzziness
 			// because bigrams are only 2 characters long and must match exactly with AUTO
+			// Keywords mixing CJK with other scripts are matched per script,
+			// dictionary terms in them as a whole
 			textCaptionQuery := elastic.NewBoolQuery()
-			textCaptionQuery.Should(elastic.NewMatchQuery("text", req.Keyword))
-			textCaptionQuery.Should(elastic.NewMatchQuery("caption", req.Keyword))
+			for _, keyword := range keywords {
+				textCaptionQuery.Should(e.keywordQuery(keyword))
+				if blind := e.blindQuery(keyword, false); blind != nil {
+					textCaptionQuery.Should(blind)
+				}
+			}
+			// Spellings the keyword may have been meant as, such as the
+			// keyword retyped on another keyboard layout, rank below it
+			for _, expansion := range e.expandKeyword(req.Keyword) {
+				textCaptionQuery.Should(elastic.NewBoolQuery().Must(e.keywordQuery(expansion)).Boost(expansionBoost))
+			}
 			boolQuery.Must(textCaptionQuery)
 			log.WithField("query_type", "fuzzy_match").Info("DEBUG: Using fuzzy match query (text + caption)")
 		}
@@ -450,18 +917,127 @@ func (e *ElasticsearchEngine) Search(req *models.SearchRequest) (*models.SearchR
 		boolQuery.Filter(chatIDFilter)
 	}
 
-	// Exclude blocked users (filter by sender_id when sender_type=user)
-	if len(req.BlockedUsers) > 0 {
-		for _, userID := range req.BlockedUsers {
-			// Use new fields with sender_type filter
-			blockedUserQuery := elastic.NewBoolQuery().
-				Filter(elastic.NewTermQuery("sender_type", "user")).
-				Filter(elastic.NewTermQuery("sender_id", userID))
-			boolQuery.MustNot(blockedUserQuery)
+	// Filter by a set of chats
+	if len(req.ChatIDs) > 0 {
+		chatIDs := int64Values(req.ChatIDs)
+		chatIDsFilter := elastic.NewBoolQuery()
+		chatIDsFilter.Should(elastic.NewTermsQuery("chat_id", chatIDs...))
+		chatIDsFilter.Should(elastic.NewTermsQuery("chat.id", chatIDs...))
+		boolQuery.Filter(chatIDsFilter)
+	}
+
+	// Filter by message documents, e.g. the saved messages with a tag
+	if len(req.MessageIDs) > 0 {
+		boolQuery.Filter(elastic.NewIdsQuery().Ids(req.MessageIDs...))
+	}
+
+	// Exclude chats
+	if len(req.ExcludedChats) > 0 {
+		chatIDs := int64Values(req.ExcludedChats)
+		boolQuery.MustNot(elastic.NewTermsQuery("chat_id", chatIDs...))
+		boolQuery.MustNot(elastic.NewTermsQuery("chat.id", chatIDs...))
+	}
+
+	// Exclude blocked users (filter by sender_id when sender_type=user),
+	// including every account grouped with them
+	if len(req.BlockedUsers) > 0 {
+		for _, userID := range req.UserGroups.ExpandAll(req.BlockedUsers) {
+			// Use new fields with sender_type filter
+			blockedUserQuery := elastic.NewBoolQuery().
+				Filter(elastic.NewTermQuery("sender_type", "user")).
+				Filter(elastic.NewTermQuery("sender_id", userID))
+			boolQuery.MustNot(blockedUserQuery)
+
+			// Also exclude via old field for backward compat
+			boolQuery.MustNot(elastic.NewTermQuery("from_user.id", userID))
+		}
+	}
+
+	// Filter by minimum total reactions
+	if req.MinReactions > 0 {
+		boolQuery.Filter(elastic.NewRangeQuery("reactions_count").Gte(req.MinReactions))
+	}
+
+	// Filter by text length
+	if req.MinLength > 0 || req.MaxLength > 0 {
+		lengthRange := elastic.NewRangeQuery("text_length")
+		if req.MinLength > 0 {
+			lengthRange.Gte(req.MinLength)
+		}
+		if req.MaxLength > 0 {
+			lengthRange.Lte(req.MaxLength)
+		}
+		boolQuery.Filter(lengthRange)
+	}
+
+	// Filter by forum topic
+	if req.ThreadID != nil {
+		boolQuery.Filter(elastic.NewTermQuery("thread_id", *req.ThreadID))
+	}
+
+	// Filter by entity values
+	if req.Hashtag != "" {
+		boolQuery.Filter(elastic.NewTermQuery("hashtags", models.NormalizeHashtag(req.Hashtag)))
+	}
+	if req.Lang != "" {
+		boolQuery.Filter(elastic.NewTermQuery("lang", models.NormalizeLanguage(req.Lang)))
+	}
+	if req.Mention != "" {
+		boolQuery.Filter(elastic.NewTermQuery("mentions", models.NormalizeMention(req.Mention)))
+	}
+	if req.HasLink != nil {
+		if *req.HasLink {
+			boolQuery.Filter(elastic.NewExistsQuery("urls"))
+		} else {
+			boolQuery.MustNot(elastic.NewExistsQuery("urls"))
+		}
+	}
+
+	// Filter by media metadata
+	if req.MediaType != "" {
+		boolQuery.Filter(elastic.NewTermQuery("media_type", strings.ToLower(req.MediaType)))
+	}
+	if req.MimeType != "" {
+		mimeType := strings.ToLower(req.MimeType)
+		if family, ok := strings.CutSuffix(mimeType, "/*"); ok {
+			boolQuery.Filter(elastic.NewPrefixQuery("mime_type", family+"/"))
+		} else {
+			boolQuery.Filter(elastic.NewTermQuery("mime_type", mimeType))
+		}
+	}
+
+	// Filter polls
+	if req.IsPoll != nil {
+		if *req.IsPoll {
+			boolQuery.Filter(elastic.NewTermQuery("is_poll", true))
+		} else {
+			boolQuery.MustNot(elastic.NewTermQuery("is_poll", true))
+		}
+	}
+
+	// Filter by distance from a point
+	if req.Near != nil {
+		boolQuery.Filter(elastic.NewGeoDistanceQuery("location").
+			Lat(*req.Near.Lat).
+			Lon(*req.Near.Lon).
+			Distance(strings.TrimSpace(req.Near.Radius)))
+	}
+
+	// Filter by sender ID
+	if req.SenderID != nil {
+		boolQuery.Filter(elastic.NewTermsQuery("sender_id", int64Values(req.UserGroups.Expand(*req.SenderID))...))
+	}
 
-			// Also exclude via old field for backward compat
-			boolQuery.MustNot(elastic.NewTermQuery("from_user.id", userID))
+	// Filter by date range (unix timestamps, inclusive)
+	if req.DateFrom != nil || req.DateTo != nil {
+		dateRange := elastic.NewRangeQuery("timestamp")
+		if req.DateFrom != nil {
+			dateRange.Gte(req.DateFrom.Unix())
+		}
+		if req.DateTo != nil {
+			dateRange.Lte(req.DateTo.Unix())
 		}
+		boolQuery.Filter(dateRange)
 	}
 
 	// Exclude soft-deleted messages by default (unless include_deleted is true)
@@ -469,80 +1045,48 @@ func (e *ElasticsearchEngine) Search(req *models.SearchRequest) (*models.SearchR
 		boolQuery.MustNot(elastic.NewTermQuery("is_deleted", true))
 	}
 
-	// Pagination
-	if req.Page < 1 {
-		req.Page = 1
-	}
-	if req.PageSize < 1 {
-		req.PageSize = 10
+	// Exclude messages classified as spam on request
+	if req.ExcludeSpam {
+		boolQuery.MustNot(elastic.NewTermQuery("is_spam", true))
 	}
-	from := (req.Page - 1) * req.PageSize
-
-	// DEBUG: Log the final query
-	querySource, _ := boolQuery.Source()
-	log.WithFields(log.Fields{
-		"query":     querySource,
-		"from":      from,
-		"size":      req.PageSize,
-		"index":     e.index,
-	}).Info("DEBUG: Executing Elasticsearch query")
 
-	// Execute search
-	searchResult, err := e.client.Search().
-		Index(e.index).
-		Query(boolQuery).
-		Sort("timestamp", false). // Sort by timestamp descending
-		From(from).
-		Size(req.PageSize).
-		TrackTotalHits(true).
-		Do(ctx)
+	return boolQuery
+}
 
-	if err != nil {
-		log.WithError(err).Error("DEBUG: Elasticsearch query failed")
-		return nil, fmt.Errorf("search query failed: %w", err)
-	}
+// DeleteByQuery permanently removes messages matching the search filters.
+// With dryRun, only the number of matching messages is returned.
+func (e *ElasticsearchEngine) DeleteByQuery(req *models.SearchRequest, dryRun bool) (int64, error) {
+	ctx := req.Context()
 
-	// DEBUG: Log search results
-	log.WithFields(log.Fields{
-		"total_hits":    searchResult.Hits.TotalHits.Value,
-		"returned_hits": len(searchResult.Hits.Hits),
-		"took_ms":       searchResult.TookInMillis,
-	}).Info("DEBUG: Search results received")
+	query := e.buildQuery(req)
 
-	// Parse results
-	var messages []models.Message
-	for _, hit := range searchResult.Hits.Hits {
-		var msg models.Message
-		if err := json.Unmarshal(hit.Source, &msg); err != nil {
-			log.WithError(err).Warn("Failed to unmarshal search result")
-			continue
+	if dryRun {
+		count, err := e.client.Count(e.index).Query(query).Do(ctx)
+		if err != nil {
+			return 0, fmt.Errorf("failed to count matching messages: %w", err)
 		}
-		messages = append(messages, msg)
+		return count, nil
 	}
 
-	totalHits := searchResult.Hits.TotalHits.Value
-	totalPages := int((totalHits + int64(req.PageSize) - 1) / int64(req.PageSize))
+	result, err := e.client.DeleteByQuery(e.index).
+		Query(query).
+		Refresh("true").
+		Do(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete by query: %w", err)
+	}
 
-	return &models.SearchResponse{
-		Hits:        messages,
-		TotalHits:   totalHits,
-		TotalPages:  totalPages,
-		Page:        req.Page,
-		HitsPerPage: req.PageSize,
-	}, nil
+	log.WithField("deleted", result.Deleted).Info("Deleted messages by query")
+
+	return result.Deleted, nil
 }
 
 // Delete soft-deletes messages by chat ID
-func (e *ElasticsearchEngine) Delete(chatID int64) (int64, error) {
-	ctx := context.Background()
-
-	// Use new field, fallback to old for backward compat
-	query := elastic.NewBoolQuery()
-	query.Should(elastic.NewTermQuery("chat_id", chatID))
-	query.Should(elastic.NewTermQuery("chat.id", chatID))
+func (e *ElasticsearchEngine) Delete(ctx context.Context, chatID int64) (int64, error) {
+	query := chatQuery(chatID)
 
 	// Soft-delete: mark is_deleted=true and set deleted_at timestamp
-	script := elastic.NewScript("ctx._source.is_deleted = true; ctx._source.deleted_at = params.now").
+	script := elastic.NewScript(markDeletedScript).
 		Param("now", time.Now().Unix())
 
 	result, err := e.client.UpdateByQuery(e.index).
@@ -562,22 +1106,39 @@ func (e *ElasticsearchEngine) Delete(chatID int64) (int64, error) {
 	return result.Updated, nil
 }
 
-// DeleteUser soft-deletes all messages from a specific user
-func (e *ElasticsearchEngine) DeleteUser(userID int64) (int64, error) {
-	ctx := context.Background()
+// DeleteMessage permanently removes a single message by composite ID
+func (e *ElasticsearchEngine) DeleteMessage(ctx context.Context, id string) (bool, error) {
+	index, err := e.documentIndex(ctx, id)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete message %s: %w", id, err)
+	}
+	if index == "" {
+		return false, nil
+	}
 
-	// Use new fields (sender_id + sender_type), fallback to old for backward compat
-	query := elastic.NewBoolQuery()
-	// New field: sender_id with type filter
-	senderQuery := elastic.NewBoolQuery().
-		Filter(elastic.NewTermQuery("sender_type", "user")).
-		Filter(elastic.NewTermQuery("sender_id", userID))
-	query.Should(senderQuery)
-	// Old field: from_user.id
-	query.Should(elastic.NewTermQuery("from_user.id", userID))
+	_, err = e.client.Delete().
+		Index(index).
+		Id(id).
+		Do(ctx)
+
+	if elastic.IsNotFound(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to delete message %s: %w", id, err)
+	}
+
+	log.WithField("doc_id", id).Info("Deleted message")
+
+	return true, nil
+}
+
+// DeleteUser soft-deletes all messages from a specific user
+func (e *ElasticsearchEngine) DeleteUser(ctx context.Context, userID int64) (int64, error) {
+	query := userQuery(userID)
 
 	// Soft-delete: mark is_deleted=true and set deleted_at timestamp
-	script := elastic.NewScript("ctx._source.is_deleted = true; ctx._source.deleted_at = params.now").
+	script := elastic.NewScript(markDeletedScript).
 		Param("now", time.Now().Unix())
 
 	result, err := e.client.UpdateByQuery(e.index).
@@ -597,10 +1158,40 @@ func (e *ElasticsearchEngine) DeleteUser(userID int64) (int64, error) {
 	return result.Updated, nil
 }
 
-// Clear removes all documents from the index
-func (e *ElasticsearchEngine) Clear() error {
-	ctx := context.Background()
+// int64Values converts IDs to the values of a terms query
+func int64Values(ids []int64) []interface{} {
+	values := make([]interface{}, len(ids))
+	for i, id := range ids {
+		values[i] = id
+	}
+	return values
+}
+
+// chatQuery matches all messages of a chat
+func chatQuery(chatID int64) *elastic.BoolQuery {
+	// Use new field, fallback to old for backward compat
+	query := elastic.NewBoolQuery()
+	query.Should(elastic.NewTermQuery("chat_id", chatID))
+	query.Should(elastic.NewTermQuery("chat.id", chatID))
+	return query
+}
+
+// userQuery matches all messages sent by a user
+func userQuery(userID int64) *elastic.BoolQuery {
+	// Use new fields (sender_id + sender_type), fallback to old for backward compat
+	query := elastic.NewBoolQuery()
+	// New field: sender_id with type filter
+	senderQuery := elastic.NewBoolQuery().
+		Filter(elastic.NewTermQuery("sender_type", "user")).
+		Filter(elastic.NewTermQuery("sender_id", userID))
+	query.Should(senderQuery)
+	// Old field: from_user.id
+	query.Should(elastic.NewTermQuery("from_user.id", userID))
+	return query
+}
 
+// Clear removes all documents from the index
+func (e *ElasticsearchEngine) Clear(ctx context.Context) error {
 	query := elastic.NewMatchAllQuery()
 
 	_, err := e.client.DeleteByQuery().
@@ -651,9 +1242,7 @@ func (e *ElasticsearchEngine) Ping() (*models.PingResponse, error) {
 }
 
 // Stats returns detailed statistics
-func (e *ElasticsearchEngine) Stats() (*models.StatsResponse, error) {
-	ctx := context.Background()
-
+func (e *ElasticsearchEngine) Stats(ctx context.Context) (*models.StatsResponse, error) {
 	// Total documents
 	totalDocs, err := e.client.Count(e.index).Do(ctx)
 	if err != nil {
@@ -693,10 +1282,9 @@ func (e *ElasticsearchEngine) Stats() (*models.StatsResponse, error) {
 	// Get index stats
 	indexStats, err := e.client.IndexStats(e.index).Do(ctx)
 	var indexSize int64 = 0
-	if err == nil {
-		if stats, found := indexStats.Indices[e.index]; found {
-			indexSize = stats.Total.Store.SizeInBytes
-		}
+	if err == nil && indexStats.All != nil && indexStats.All.Total != nil && indexStats.All.Total.Store != nil {
+		// The index name may be an alias for a reindexed copy
+		indexSize = indexStats.All.Total.Store.SizeInBytes
 	}
 
 	return &models.StatsResponse{
@@ -709,27 +1297,27 @@ func (e *ElasticsearchEngine) Stats() (*models.StatsResponse, error) {
 	}, nil
 }
 
-// Dedup removes duplicate messages (keeps latest by timestamp)
-func (e *ElasticsearchEngine) Dedup() (*models.DedupResponse, error) {
-	ctx := context.Background()
-
-	log.Info("Starting deduplication process...")
+// Dedup removes duplicate messages (keeps latest by timestamp).
+// Duplicate groups are detected from composite bucket doc counts alone; each
+// group is then resolved with a follow-up query that fetches only document
+// IDs, or with a sliced delete-by-query when the group is too large to list.
+// A dry run stops after detection and reports the groups per chat.
+func (e *ElasticsearchEngine) Dedup(ctx context.Context, dryRun bool, progress func(*models.DedupResponse)) (*models.DedupResponse, error) {
+	log.WithField("dry_run", dryRun).Info("Starting deduplication process...")
+
+	var report *dedupReport
+	if dryRun {
+		report = newDedupReport()
+	}
 
-	// Use aggregations to find duplicates by chat_id + message_id
-	// Try new field first, fallback to old if it doesn't exist
+	// Group by chat_id + message_id; buckets with doc_count > 1 are duplicates
 	compositeAgg := elastic.NewCompositeAggregation().
-		Size(1000).
+		Size(dedupPageSize).
 		Sources(
 			elastic.NewCompositeAggregationTermsValuesSource("chat_id").Field("chat_id").MissingBucket(true),
 			elastic.NewCompositeAggregationTermsValuesSource("message_id").Field("message_id"),
 		)
 
-	topHitsAgg := elastic.NewTopHitsAggregation().
-		Size(100).
-		Sort("timestamp", false) // Sort by timestamp descending
-
-	compositeAgg.SubAggregation("docs", topHitsAgg)
-
 	var duplicatesFound int64 = 0
 	var duplicatesRemoved int64 = 0
 	var afterKey map[string]interface{} = nil
@@ -737,6 +1325,17 @@ func (e *ElasticsearchEngine) Dedup() (*models.DedupResponse, error) {
 
 	// Process all composite aggregation pages
 	for {
+		if err := ctx.Err(); err != nil {
+			return &models.DedupResponse{
+				Success:           false,
+				DuplicatesFound:   duplicatesFound,
+				DuplicatesRemoved: duplicatesRemoved,
+				PagesProcessed:    pageCount,
+				Message:           "Deduplication cancelled",
+				DryRun:            dryRun,
+			}, err
+		}
+
 		pageCount++
 		if afterKey != nil {
 			compositeAgg.AggregateAfter(afterKey)
@@ -758,61 +1357,63 @@ func (e *ElasticsearchEngine) Dedup() (*models.DedupResponse, error) {
 			break
 		}
 
-		log.WithFields(log.Fields{
-			"page":              pageCount,
-			"buckets":           len(compAgg.Buckets),
-			"duplicates_found":  duplicatesFound,
-			"duplicates_removed": duplicatesRemoved,
-		}).Info("Processing deduplication page")
-
-		// Process each bucket (unique chat_id + message_id combination)
+		var small, large []dedupGroup
 		for _, bucket := range compAgg.Buckets {
-			// Get top hits for this bucket
-			topHits, found := bucket.Aggregations.TopHits("docs")
-			if !found || topHits == nil || topHits.Hits == nil {
+			if bucket.DocCount <= 1 {
 				continue
 			}
-
-			hitCount := len(topHits.Hits.Hits)
-			if hitCount <= 1 {
-				// No duplicates for this message
+			group := dedupGroup{
+				chatID:    bucket.Key["chat_id"],
+				messageID: bucket.Key["message_id"],
+				count:     bucket.DocCount,
+			}
+			duplicatesFound += group.count - 1
+			if dryRun {
+				report.add(group)
 				continue
 			}
-
-			// Found duplicates! Keep the first one (latest timestamp), delete the rest
-			duplicatesFound += int64(hitCount - 1)
-
-			// Collect document IDs to delete (skip the first one)
-			bulkDelete := e.client.Bulk().Index(e.index)
-			for i := 1; i < hitCount; i++ {
-				docID := topHits.Hits.Hits[i].Id
-				deleteReq := elastic.NewBulkDeleteRequest().Id(docID)
-				bulkDelete.Add(deleteReq)
+			if group.count <= dedupInlineGroupSize {
+				small = append(small, group)
+			} else {
+				large = append(large, group)
 			}
+		}
 
-			// Execute bulk delete
-			if bulkDelete.NumberOfActions() > 0 {
-				bulkResp, err := bulkDelete.Do(ctx)
-				if err != nil {
-					log.WithError(err).Warn("Failed to delete duplicate documents")
-					continue
-				}
+		log.WithFields(log.Fields{
+			"page":               pageCount,
+			"buckets":            len(compAgg.Buckets),
+			"duplicate_groups":   len(small) + len(large),
+			"duplicates_found":   duplicatesFound,
+			"duplicates_removed": duplicatesRemoved,
+		}).Info("Processing deduplication page")
 
-				if !bulkResp.Errors {
-					duplicatesRemoved += int64(bulkDelete.NumberOfActions())
-				} else {
-					// Count successful deletions
-					for _, item := range bulkResp.Items {
-						for _, result := range item {
-							if result.Error == nil {
-								duplicatesRemoved++
-							}
-						}
-					}
-				}
+		removed, err := e.dedupSmallGroups(ctx, small)
+		duplicatesRemoved += removed
+		if err != nil {
+			log.WithError(err).Warn("Failed to remove duplicate documents")
+		}
+
+		for _, group := range large {
+			removed, err := e.dedupLargeGroup(ctx, group)
+			duplicatesRemoved += removed
+			if err != nil {
+				log.WithError(err).WithFields(log.Fields{
+					"chat_id":    group.chatID,
+					"message_id": group.messageID,
+					"count":      group.count,
+				}).Warn("Failed to remove duplicate group")
 			}
 		}
 
+		if progress != nil {
+			progress(&models.DedupResponse{
+				DuplicatesFound:   duplicatesFound,
+				DuplicatesRemoved: duplicatesRemoved,
+				PagesProcessed:    pageCount,
+				DryRun:            dryRun,
+			})
+		}
+
 		// Check if there are more pages
 		if compAgg.AfterKey == nil || len(compAgg.Buckets) == 0 {
 			break
@@ -820,6 +1421,20 @@ func (e *ElasticsearchEngine) Dedup() (*models.DedupResponse, error) {
 		afterKey = compAgg.AfterKey
 	}
 
+	if dryRun {
+		message := fmt.Sprintf("Dry run complete: found %d duplicates in %d messages, nothing removed", duplicatesFound, report.groups)
+		log.Info(message)
+
+		return &models.DedupResponse{
+			Success:         true,
+			DuplicatesFound: duplicatesFound,
+			PagesProcessed:  pageCount,
+			Message:         message,
+			DryRun:          true,
+			Report:          report.build(),
+		}, nil
+	}
+
 	message := fmt.Sprintf("Deduplication complete: found %d duplicates, removed %d", duplicatesFound, duplicatesRemoved)
 	log.Info(message)
 
@@ -827,23 +1442,204 @@ func (e *ElasticsearchEngine) Dedup() (*models.DedupResponse, error) {
 		Success:           true,
 		DuplicatesFound:   duplicatesFound,
 		DuplicatesRemoved: duplicatesRemoved,
+		PagesProcessed:    pageCount,
 		Message:           message,
 	}, nil
 }
 
-// SoftDeleteMessage marks a single message as deleted
-func (e *ElasticsearchEngine) SoftDeleteMessage(chatID int64, messageID int64) error {
-	ctx := context.Background()
+// dedupReport accumulates duplicate groups for a dry run
+type dedupReport struct {
+	groups  int64
+	docs    int64
+	chats   map[int64]*models.DedupChatReport
+	samples []models.DedupSample
+}
+
+func newDedupReport() *dedupReport {
+	return &dedupReport{chats: make(map[int64]*models.DedupChatReport)}
+}
+
+// add records one duplicate group
+func (r *dedupReport) add(group dedupGroup) {
+	chatID := keyInt64(group.chatID)
+	messageID := keyInt64(group.messageID)
+	reclaimable := group.count - 1
+
+	r.groups++
+	r.docs += reclaimable
+
+	chat, ok := r.chats[chatID]
+	if !ok {
+		chat = &models.DedupChatReport{ChatID: chatID}
+		r.chats[chatID] = chat
+	}
+	chat.DuplicateGroups++
+	chat.ReclaimableDocs += reclaimable
+
+	if len(r.samples) < dedupReportSamples {
+		r.samples = append(r.samples, models.DedupSample{
+			ID:        models.MessageDocumentID(chatID, messageID),
+			ChatID:    chatID,
+			MessageID: messageID,
+			Copies:    group.count,
+		})
+	}
+}
+
+// build returns the report with the most affected chats first
+func (r *dedupReport) build() *models.DedupReport {
+	chats := make([]models.DedupChatReport, 0, len(r.chats))
+	for _, chat := range r.chats {
+		chats = append(chats, *chat)
+	}
+	sort.Slice(chats, func(i, j int) bool {
+		if chats[i].ReclaimableDocs != chats[j].ReclaimableDocs {
+			return chats[i].ReclaimableDocs > chats[j].ReclaimableDocs
+		}
+		return chats[i].ChatID < chats[j].ChatID
+	})
+	if len(chats) > dedupReportChats {
+		chats = chats[:dedupReportChats]
+	}
+
+	return &models.DedupReport{
+		DuplicateGroups: r.groups,
+		ReclaimableDocs: r.docs,
+		Chats:           chats,
+		Samples:         r.samples,
+	}
+}
+
+// keyInt64 converts a numeric composite aggregation key to int64
+func keyInt64(key interface{}) int64 {
+	switch value := key.(type) {
+	case float64:
+		return int64(value)
+	case json.Number:
+		n, _ := value.Int64()
+		return n
+	}
+	return 0
+}
+
+// dedupGroup is one chat_id + message_id combination stored more than once
+type dedupGroup struct {
+	chatID    interface{} // nil for documents without chat_id
+	messageID interface{}
+	count     int64
+}
+
+// query matches all documents of the group
+func (g dedupGroup) query() elastic.Query {
+	query := elastic.NewBoolQuery().Filter(elastic.NewTermQuery("message_id", g.messageID))
+	if g.chatID == nil {
+		return query.MustNot(elastic.NewExistsQuery("chat_id"))
+	}
+	return query.Filter(elastic.NewTermQuery("chat_id", g.chatID))
+}
+
+// dedupSmallGroups lists the document IDs of each group (no _source) with a
+// multi-search and bulk-deletes all but the latest of each
+func (e *ElasticsearchEngine) dedupSmallGroups(ctx context.Context, groups []dedupGroup) (int64, error) {
+	var removed int64
+
+	for start := 0; start < len(groups); start += dedupMultiSearchSize {
+		end := start + dedupMultiSearchSize
+		if end > len(groups) {
+			end = len(groups)
+		}
+
+		msearch := e.client.MultiSearch().Index(e.index)
+		for _, group := range groups[start:end] {
+			msearch.Add(elastic.NewSearchRequest().
+				Query(group.query()).
+				Size(int(group.count)).
+				Sort("timestamp", false).
+				FetchSource(false))
+		}
+
+		result, err := msearch.Do(ctx)
+		if err != nil {
+			return removed, fmt.Errorf("failed to list duplicate documents: %w", err)
+		}
+
+		bulkDelete := e.client.Bulk().Index(e.index)
+		for _, resp := range result.Responses {
+			if resp == nil || resp.Hits == nil {
+				continue
+			}
+			// Keep the first one (latest timestamp), delete the rest
+			for i := 1; i < len(resp.Hits.Hits); i++ {
+				hit := resp.Hits.Hits[i]
+				bulkDelete.Add(elastic.NewBulkDeleteRequest().Index(hit.Index).Id(hit.Id))
+			}
+		}
+		if bulkDelete.NumberOfActions() == 0 {
+			continue
+		}
+
+		bulkResp, err := bulkDelete.Do(ctx)
+		if err != nil {
+			return removed, fmt.Errorf("failed to delete duplicate documents: %w", err)
+		}
+		removed += int64(len(bulkResp.Succeeded()))
+	}
 
+	return removed, nil
+}
+
+// dedupLargeGroup keeps the latest document of a heavily duplicated group and
+// removes the others with a sliced delete-by-query
+func (e *ElasticsearchEngine) dedupLargeGroup(ctx context.Context, group dedupGroup) (int64, error) {
+	latest, err := e.client.Search().
+		Index(e.index).
+		Query(group.query()).
+		Size(1).
+		Sort("timestamp", false).
+		FetchSource(false).
+		Do(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to find latest duplicate: %w", err)
+	}
+	if latest.Hits == nil || len(latest.Hits.Hits) == 0 {
+		return 0, nil
+	}
+
+	query := elastic.NewBoolQuery().
+		Filter(group.query()).
+		MustNot(elastic.NewIdsQuery().Ids(latest.Hits.Hits[0].Id))
+
+	result, err := e.client.DeleteByQuery(e.index).
+		Query(query).
+		Slices("auto").
+		ProceedOnVersionConflict().
+		Do(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete duplicate group: %w", err)
+	}
+
+	return result.Deleted, nil
+}
+
+// SoftDeleteMessage marks a single message as deleted
+func (e *ElasticsearchEngine) SoftDeleteMessage(ctx context.Context, chatID int64, messageID int64) error {
 	// Construct composite document ID
-	documentID := fmt.Sprintf("%d-%d", chatID, messageID)
+	documentID := models.MessageDocumentID(chatID, messageID)
 
 	// Soft-delete: mark is_deleted=true and set deleted_at timestamp
-	script := elastic.NewScript("ctx._source.is_deleted = true; ctx._source.deleted_at = params.now").
+	script := elastic.NewScript(markDeletedScript).
 		Param("now", time.Now().Unix())
 
-	_, err := e.client.Update().
-		Index(e.index).
+	index, err := e.documentIndex(ctx, documentID)
+	if err != nil {
+		return fmt.Errorf("failed to soft-delete message %s: %w", documentID, err)
+	}
+	if index == "" {
+		return fmt.Errorf("failed to soft-delete message %s: not found", documentID)
+	}
+
+	_, err = e.client.Update().
+		Index(index).
 		Id(documentID).
 		Script(script).
 		Do(ctx)
@@ -862,9 +1658,7 @@ func (e *ElasticsearchEngine) SoftDeleteMessage(chatID int64, messageID int64) e
 }
 
 // GetUserStats retrieves activity statistics for a user in a group
-func (e *ElasticsearchEngine) GetUserStats(req *models.UserStatsRequest) (*models.UserStatsResponse, error) {
-	ctx := context.Background()
-
+func (e *ElasticsearchEngine) GetUserStats(ctx context.Context, req *models.UserStatsRequest) (*models.UserStatsResponse, error) {
 	// Build base query: filter by group and time range
 	// Use new field with fallback to old for backward compat
 	chatIDFilter := elastic.NewBoolQuery()
@@ -880,25 +1674,37 @@ func (e *ElasticsearchEngine) GetUserStats(req *models.UserStatsRequest) (*model
 		baseQuery.MustNot(elastic.NewTermQuery("is_deleted", true))
 	}
 
+	// Count every account grouped with the user as the same person
+	userIDs := int64Values(req.UserGroups.Expand(req.UserID))
+
 	// Query 1: Count messages from the specific user
 	// Use new fields (sender_id + sender_type), fallback to old
 	userIDFilter := elastic.NewBoolQuery()
 	// New field: sender_id with type filter
 	senderQuery := elastic.NewBoolQuery().
 		Filter(elastic.NewTermQuery("sender_type", "user")).
-		Filter(elastic.NewTermQuery("sender_id", req.UserID))
+		Filter(elastic.NewTermsQuery("sender_id", userIDs...))
 	userIDFilter.Should(senderQuery)
 	// Old field: from_user.id
-	userIDFilter.Should(elastic.NewTermQuery("from_user.id", req.UserID))
+	userIDFilter.Should(elastic.NewTermsQuery("from_user.id", userIDs...))
 
 	userQuery := elastic.NewBoolQuery().
 		Must(baseQuery).
 		Filter(userIDFilter)
 
-	userCount, err := e.client.Count(e.index).Query(userQuery).Do(ctx)
+	// Count user messages and average verbosity in one request
+	userResult, err := e.client.Search().
+		Index(e.index).
+		Query(userQuery).
+		Size(0).
+		TrackTotalHits(true).
+		Aggregation("avg_length", elastic.NewAvgAggregation().Field("text_length")).
+		Aggregation("avg_words", elastic.NewAvgAggregation().Field("word_count")).
+		Do(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("failed to count user messages: %w", err)
 	}
+	userCount := userResult.Hits.TotalHits.Value
 
 	// Query 2: Count total messages in the group
 	groupCount, err := e.client.Count(e.index).Query(baseQuery).Do(ctx)
@@ -919,6 +1725,15 @@ func (e *ElasticsearchEngine) GetUserStats(req *models.UserStatsRequest) (*model
 		MentionsOut:       0,
 		MentionsIn:        0,
 	}
+	if avg, found := userResult.Aggregations.Avg("avg_length"); found && avg.Value != nil {
+		response.AvgTextLength = *avg.Value
+	}
+	if avg, found := userResult.Aggregations.Avg("avg_words"); found && avg.Value != nil {
+		response.AvgWordCount = *avg.Value
+	}
+	if len(userIDs) > 1 {
+		response.UserIDs = req.UserGroups.Expand(req.UserID)
+	}
 
 	// Query 3 & 4: Count mentions if requested
 	if req.IncludeMentions {
@@ -944,17 +1759,17 @@ func (e *ElasticsearchEngine) GetUserStats(req *models.UserStatsRequest) (*model
 		// New field: exclude sender_id with type filter
 		notSenderQuery := elastic.NewBoolQuery().
 			Filter(elastic.NewTermQuery("sender_type", "user")).
-			Filter(elastic.NewTermQuery("sender_id", req.UserID))
+			Filter(elastic.NewTermsQuery("sender_id", userIDs...))
 		notUserFilter.MustNot(notSenderQuery)
 		// Old field: exclude from_user.id
-		notUserFilter.MustNot(elastic.NewTermQuery("from_user.id", req.UserID))
+		notUserFilter.MustNot(elastic.NewTermsQuery("from_user.id", userIDs...))
 
 		mentionsInQuery := elastic.NewBoolQuery().
 			Must(baseQuery).
 			Must(notUserFilter).
 			Filter(elastic.NewNestedQuery("entities", elastic.NewBoolQuery().
 				Must(elastic.NewTermQuery("entities.type", "text_mention")).
-				Must(elastic.NewTermQuery("entities.user_id", req.UserID)),
+				Must(elastic.NewTermsQuery("entities.user_id", userIDs...)),
 			))
 
 		mentionsInCount, err := e.client.Count(e.index).Query(mentionsInQuery).Do(ctx)
@@ -979,9 +1794,7 @@ func (e *ElasticsearchEngine) GetUserStats(req *models.UserStatsRequest) (*model
 }
 
 // CleanCommands removes all messages starting with '/' (bot commands)
-func (e *ElasticsearchEngine) CleanCommands() (*models.CleanCommandsResponse, error) {
-	ctx := context.Background()
-
+func (e *ElasticsearchEngine) CleanCommands(ctx context.Context) (*models.CleanCommandsResponse, error) {
 	log.Info("Starting command cleanup: removing messages starting with '/'")
 
 	// Use wildcard query to match messages starting with '/' (case-insensitive)
@@ -1028,10 +1841,80 @@ func (e *ElasticsearchEngine) CleanCommands() (*models.CleanCommandsResponse, er
 	}, nil
 }
 
-// GetMessageIDs retrieves all message IDs for a specific chat (for gap detection)
-func (e *ElasticsearchEngine) GetMessageIDs(chatID int64) (*models.GetMessageIDsResponse, error) {
-	ctx := context.Background()
+// ListChats lists indexed chats, most active first. With titleContains only
+// chats whose title (current or past) contains the phrase are listed.
+func (e *ElasticsearchEngine) ListChats(ctx context.Context, titleContains string, limit int) ([]models.ChatSummary, error) {
+	query := elastic.NewBoolQuery().MustNot(elastic.NewTermQuery("is_deleted", true))
+	if titleContains != "" {
+		// Use new field, fallback to old for backward compat
+		titleQuery := elastic.NewBoolQuery()
+		titleQuery.Should(elastic.NewMatchPhraseQuery("chat_title", titleContains))
+		titleQuery.Should(elastic.NewMatchPhraseQuery("chat.title", titleContains))
+		query.Filter(titleQuery)
+	}
+
+	// chat.id is set on every document, including legacy ones
+	chatsAgg := elastic.NewTermsAggregation().
+		Field("chat.id").
+		Size(limit).
+		SubAggregation("latest", elastic.NewTopHitsAggregation().
+			Size(1).
+			Sort("timestamp", false).
+			FetchSourceContext(elastic.NewFetchSourceContext(true).
+				Include("chat_id", "chat_type", "chat_title", "chat_username", "timestamp", "chat")))
+
+	result, err := e.client.Search().
+		Index(e.index).
+		Query(query).
+		Size(0).
+		Aggregation("chats", chatsAgg).
+		Do(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list chats: %w", err)
+	}
+
+	chats := []models.ChatSummary{}
+	agg, found := result.Aggregations.Terms("chats")
+	if !found {
+		return chats, nil
+	}
+
+	for _, bucket := range agg.Buckets {
+		chatID, err := bucket.KeyNumber.Int64()
+		if err != nil {
+			continue
+		}
+		chat := models.ChatSummary{
+			ChatID:       chatID,
+			MessageCount: bucket.DocCount,
+		}
+		if latest, found := bucket.TopHits("latest"); found && latest.Hits != nil && len(latest.Hits.Hits) > 0 {
+			var message models.Message
+			if err := json.Unmarshal(latest.Hits.Hits[0].Source, &message); err == nil {
+				chat.ChatType = firstNonEmpty(message.ChatType, message.Chat.Type)
+				chat.ChatTitle = firstNonEmpty(message.ChatTitle, message.Chat.Title)
+				chat.ChatUsername = firstNonEmpty(message.ChatUsername, message.Chat.Username)
+				chat.LastMessageAt = message.Timestamp
+			}
+		}
+		chats = append(chats, chat)
+	}
+
+	return chats, nil
+}
+
+// firstNonEmpty returns the first non-empty string
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+	return ""
+}
 
+// GetMessageIDs retrieves all message IDs for a specific chat (for gap detection)
+func (e *ElasticsearchEngine) GetMessageIDs(ctx context.Context, chatID int64) (*models.GetMessageIDsResponse, error) {
 	// Query to filter by chat ID (use new field, fallback to old)
 	query := elastic.NewBoolQuery()
 	query.Should(elastic.NewTermQuery("chat_id", chatID))
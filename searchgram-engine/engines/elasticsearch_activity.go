@@ -0,0 +1,145 @@
+package engines
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/olivere/elastic/v7"
+	"github.com/zhishengyuan/searchgram-engine/models"
+)
+
+const (
+	// activityWeekOffset starts weekly buckets on Mondays; the Unix epoch
+	// was a Thursday
+	activityWeekOffset = 4 * 24 * 60 * 60
+
+	// trendingSampleSize bounds the messages per shard significant terms
+	// are computed from
+	trendingSampleSize = 1000
+)
+
+// activityQuery matches the messages of a chat sent in [from, to)
+func activityQuery(chatID, from, to int64) *elastic.BoolQuery {
+	return elastic.NewBoolQuery().
+		Filter(chatQuery(chatID)).
+		Filter(elastic.NewRangeQuery("timestamp").Gte(from).Lt(to)).
+		MustNot(elastic.NewTermQuery("is_deleted", true))
+}
+
+// sendersAgg counts the distinct users sending the messages in a bucket.
+// Legacy documents without sender_id are not counted.
+func sendersAgg() elastic.Aggregation {
+	return elastic.NewCardinalityAggregation().Field("sender_id")
+}
+
+// senderCount reads the sendersAgg of a bucket
+func senderCount(aggs elastic.Aggregations) int64 {
+	if senders, found := aggs.Cardinality("senders"); found && senders.Value != nil {
+		return int64(*senders.Value)
+	}
+	return 0
+}
+
+// ChatActivity counts the messages of a chat and the users sending them per
+// day or week. Intervals without messages are left out.
+func (e *ElasticsearchEngine) ChatActivity(ctx context.Context, req *models.ActivityRequest) (*models.ChatActivity, error) {
+	histogram := elastic.NewHistogramAggregation().
+		Field("timestamp").
+		Interval(24*60*60).
+		SubAggregation("senders", sendersAgg())
+	if req.Interval == models.ActivityIntervalWeek {
+		histogram.Interval(7 * 24 * 60 * 60).Offset(activityWeekOffset)
+	}
+
+	result, err := e.client.Search().
+		Index(e.index).
+		Query(activityQuery(req.ChatID, req.From, req.To)).
+		Size(0).
+		Aggregation("activity", histogram).
+		Do(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to aggregate chat activity: %w", err)
+	}
+
+	activity := &models.ChatActivity{
+		ChatID:   req.ChatID,
+		Interval: req.Interval,
+		From:     req.From,
+		To:       req.To,
+		Buckets:  []models.ActivityBucket{},
+	}
+	if agg, found := result.Aggregations.Histogram("activity"); found {
+		for _, bucket := range agg.Buckets {
+			activity.Buckets = append(activity.Buckets, models.ActivityBucket{
+				Start:    int64(bucket.Key),
+				Messages: bucket.DocCount,
+				Senders:  senderCount(bucket.Aggregations),
+			})
+		}
+	}
+	return activity, nil
+}
+
+// TrendingTerms finds the hashtags used most and the words used unusually
+// often in a chat during a period, compared to the chat's whole history
+func (e *ElasticsearchEngine) TrendingTerms(ctx context.Context, req *models.TrendingRequest) (*models.TrendingTerms, error) {
+	hashtags := elastic.NewTermsAggregation().
+		Field("hashtags").
+		Size(req.Size).
+		SubAggregation("senders", sendersAgg())
+
+	// significant_text re-analyzes the text of a sample of the best matching
+	// messages, so it needs no fielddata
+	words := elastic.NewSamplerAggregation().
+		ShardSize(trendingSampleSize).
+		SubAggregation("words", elastic.NewSignificantTextAggregation().
+			Field("text").
+			Size(req.Size).
+			FilterDuplicateText(true).
+			BackgroundFilter(chatQuery(req.ChatID)).
+			SubAggregation("senders", sendersAgg()))
+
+	result, err := e.client.Search().
+		Index(e.index).
+		Query(activityQuery(req.ChatID, req.From, req.To)).
+		Size(0).
+		Aggregation("hashtags", hashtags).
+		Aggregation("sample", words).
+		Do(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to aggregate trending terms: %w", err)
+	}
+
+	trending := &models.TrendingTerms{
+		ChatID:   req.ChatID,
+		From:     req.From,
+		To:       req.To,
+		Hashtags: []models.TermCount{},
+		Words:    []models.TermCount{},
+	}
+	if agg, found := result.Aggregations.Terms("hashtags"); found {
+		for _, bucket := range agg.Buckets {
+			term, ok := bucket.Key.(string)
+			if !ok {
+				continue
+			}
+			trending.Hashtags = append(trending.Hashtags, models.TermCount{
+				Term:     term,
+				Messages: bucket.DocCount,
+				Senders:  senderCount(bucket.Aggregations),
+			})
+		}
+	}
+	if sample, found := result.Aggregations.Sampler("sample"); found {
+		if agg, found := sample.SignificantTerms("words"); found {
+			for _, bucket := range agg.Buckets {
+				trending.Words = append(trending.Words, models.TermCount{
+					Term:     bucket.Key,
+					Messages: bucket.DocCount,
+					Senders:  senderCount(bucket.Aggregations),
+				})
+			}
+		}
+	}
+	return trending, nil
+}
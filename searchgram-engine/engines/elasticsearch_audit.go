@@ -0,0 +1,137 @@
+package engines
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/olivere/elastic/v7"
+)
+
+// auditIndexSuffix names the audit index after the message index
+const auditIndexSuffix = "-audit"
+
+// auditIndex returns the name of the index the audit trail is copied to
+func (e *ElasticsearchEngine) auditIndex() string {
+	return e.index + auditIndexSuffix
+}
+
+// ensureAuditIndex creates the audit index the first time it is needed.
+// Entries are stored as written; only the chain fields are indexed.
+func (e *ElasticsearchEngine) ensureAuditIndex(ctx context.Context) error {
+	e.auditMu.Lock()
+	defer e.auditMu.Unlock()
+
+	if e.auditReady {
+		return nil
+	}
+
+	index := e.auditIndex()
+	exists, err := e.client.IndexExists(index).Do(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to check audit index existence: %w", err)
+	}
+	if !exists {
+		_, err = e.client.CreateIndex(index).BodyJson(map[string]interface{}{
+			"settings": map[string]interface{}{
+				"number_of_shards":   1,
+				"number_of_replicas": e.replicas,
+			},
+			"mappings": map[string]interface{}{
+				"dynamic": false,
+				"properties": map[string]interface{}{
+					"seq":       map[string]interface{}{"type": "long"},
+					"time":      map[string]interface{}{"type": "date"},
+					"action":    map[string]interface{}{"type": "keyword"},
+					"actor":     map[string]interface{}{"type": "keyword"},
+					"ip":        map[string]interface{}{"type": "keyword"},
+					"hash":      map[string]interface{}{"type": "keyword"},
+					"prev_hash": map[string]interface{}{"type": "keyword"},
+				},
+			},
+		}).Do(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to create audit index: %w", err)
+		}
+	}
+
+	e.auditReady = true
+	return nil
+}
+
+// AppendAudit stores an encoded audit entry under its seq. Entries are only
+// ever created, never updated, so an entry already stored under seq is
+// left as it is.
+func (e *ElasticsearchEngine) AppendAudit(ctx context.Context, seq int64, entry json.RawMessage) error {
+	if err := e.ensureAuditIndex(ctx); err != nil {
+		return err
+	}
+
+	_, err := e.client.Index().
+		Index(e.auditIndex()).
+		Id(strconv.FormatInt(seq, 10)).
+		OpType("create").
+		BodyString(string(entry)).
+		Do(ctx)
+	if err != nil && !elastic.IsConflict(err) {
+		return fmt.Errorf("failed to append audit entry %d: %w", seq, err)
+	}
+	return nil
+}
+
+// LastAuditSeq returns the highest seq in the audit index (0 = none)
+func (e *ElasticsearchEngine) LastAuditSeq(ctx context.Context) (int64, error) {
+	resp, err := e.client.Search(e.auditIndex()).
+		Query(elastic.NewMatchAllQuery()).
+		Aggregation("last_seq", elastic.NewMaxAggregation().Field("seq")).
+		Size(0).
+		Do(ctx)
+	if err != nil {
+		if elastic.IsNotFound(err) {
+			return 0, nil
+		}
+		return 0, fmt.Errorf("failed to get last audit entry: %w", err)
+	}
+
+	if agg, found := resp.Aggregations.Max("last_seq"); found && agg.Value != nil {
+		return int64(*agg.Value), nil
+	}
+	return 0, nil
+}
+
+// ScanAudit passes the entries in the audit index to fn in pages, ordered
+// by seq
+func (e *ElasticsearchEngine) ScanAudit(ctx context.Context, fn func(entries []json.RawMessage) error) error {
+	scroll := e.client.Scroll(e.auditIndex()).
+		Query(elastic.NewMatchAllQuery()).
+		Size(scanPageSize).
+		Sort("seq", true)
+	defer scroll.Clear(context.Background())
+
+	for {
+		page, err := scroll.Do(ctx)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			if elastic.IsNotFound(err) {
+				return nil
+			}
+			return fmt.Errorf("failed to scan audit entries: %w", err)
+		}
+
+		entries := make([]json.RawMessage, 0, len(page.Hits.Hits))
+		for _, hit := range page.Hits.Hits {
+			entries = append(entries, hit.Source)
+		}
+		if len(entries) == 0 {
+			continue
+		}
+
+		if err := fn(entries); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,202 @@
+package engines
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/olivere/elastic/v7"
+	log "github.com/sirupsen/logrus"
+	"github.com/zhishengyuan/searchgram-engine/models"
+)
+
+// errNoSealer is returned when reading an encrypted message while
+// encryption is not configured
+var errNoSealer = errors.New("message text is encrypted but encryption is not configured")
+
+// TextSealer encrypts message text before it is indexed, and derives the
+// blind tokens keyword searches match in its place
+type TextSealer interface {
+	Seal(id string, plaintext []byte) (json.RawMessage, error)
+	Open(id string, sealed json.RawMessage) ([]byte, error)
+	BlindTokens(text string) []string
+}
+
+// SetTextSealer makes messages store their text, every field derived from
+// it and the raw message encrypted in text_sealed, which is not indexed, and
+// the blind tokens of the searchable text in text_blind. Messages indexed
+// before keep their fields in the clear until rewritten.
+func (e *ElasticsearchEngine) SetTextSealer(sealer TextSealer) {
+	e.sealer = sealer
+}
+
+// sealedFields are the message fields encrypted together: the content of a
+// message and everything extracted from it
+type sealedFields struct {
+	Text         string                 `json:"text,omitempty"`
+	Caption      *string                `json:"caption,omitempty"`
+	PollQuestion string                 `json:"poll_question,omitempty"`
+	PollOptions  []string               `json:"poll_options,omitempty"`
+	Transcript   string                 `json:"transcript,omitempty"`
+	OCRText      string                 `json:"ocr_text,omitempty"`
+	Entities     []models.MessageEntity `json:"entities,omitempty"`
+	URLs         []string               `json:"urls,omitempty"`
+	Mentions     []string               `json:"mentions,omitempty"`
+	Hashtags     []string               `json:"hashtags,omitempty"`
+	Terms        []string               `json:"terms,omitempty"`
+	RawMessage   map[string]interface{} `json:"raw_message,omitempty"`
+}
+
+// sealedFieldsOf takes the sealed fields out of message, leaving them empty
+func sealedFieldsOf(message *models.Message) sealedFields {
+	fields := sealedFields{
+		Text:         message.Text,
+		Caption:      message.Caption,
+		PollQuestion: message.PollQuestion,
+		PollOptions:  message.PollOptions,
+		Transcript:   message.Transcript,
+		OCRText:      message.OCRText,
+		Entities:     message.Entities,
+		URLs:         message.URLs,
+		Mentions:     message.Mentions,
+		Hashtags:     message.Hashtags,
+		Terms:        message.Terms,
+		RawMessage:   message.RawMessage,
+	}
+	message.Text = ""
+	message.Caption = nil
+	message.PollQuestion = ""
+	message.PollOptions = nil
+	message.Transcript = ""
+	message.OCRText = ""
+	message.Entities = nil
+	message.URLs = nil
+	message.Mentions = nil
+	message.Hashtags = nil
+	message.Terms = nil
+	message.RawMessage = nil
+	return fields
+}
+
+// restore puts the sealed fields back into message
+func (f sealedFields) restore(message *models.Message) {
+	message.Text = f.Text
+	message.Caption = f.Caption
+	message.PollQuestion = f.PollQuestion
+	message.PollOptions = f.PollOptions
+	message.Transcript = f.Transcript
+	message.OCRText = f.OCRText
+	message.Entities = f.Entities
+	message.URLs = f.URLs
+	message.Mentions = f.Mentions
+	message.Hashtags = f.Hashtags
+	message.Terms = f.Terms
+	message.RawMessage = f.RawMessage
+}
+
+// searchable joins the fields keyword searches match, for blind tokens
+func (f sealedFields) searchable() string {
+	parts := []string{f.Text, f.PollQuestion, f.Transcript, f.OCRText}
+	if f.Caption != nil {
+		parts = append(parts, *f.Caption)
+	}
+	parts = append(parts, f.PollOptions...)
+	return strings.Join(parts, "\n")
+}
+
+// sealedMessage is a message document with its sealed fields encrypted
+type sealedMessage struct {
+	*models.Message
+	TextSealed json.RawMessage `json:"text_sealed,omitempty"`
+	TextBlind  []string        `json:"text_blind,omitempty"`
+}
+
+// document returns what is indexed for message: the message itself, or
+// with encryption a copy whose sealed fields are encrypted
+func (e *ElasticsearchEngine) document(message *models.Message) (interface{}, error) {
+	if e.sealer == nil {
+		return message, nil
+	}
+
+	stored := *message
+	fields := sealedFieldsOf(&stored)
+	plaintext, err := json.Marshal(fields)
+	if err != nil {
+		return nil, err
+	}
+	if string(plaintext) == "{}" {
+		return message, nil
+	}
+	sealed, err := e.sealer.Seal(message.ID, plaintext)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt message %s: %w", message.ID, err)
+	}
+
+	return sealedMessage{
+		Message:    &stored,
+		TextSealed: sealed,
+		TextBlind:  e.sealer.BlindTokens(fields.searchable()),
+	}, nil
+}
+
+// decodeMessage unmarshals a message document, decrypting its sealed fields
+func (e *ElasticsearchEngine) decodeMessage(source json.RawMessage, message *models.Message) error {
+	doc := sealedMessage{Message: message}
+	if err := json.Unmarshal(source, &doc); err != nil {
+		return err
+	}
+	if len(doc.TextSealed) == 0 {
+		return nil
+	}
+	if e.sealer == nil {
+		return errNoSealer
+	}
+
+	plaintext, err := e.sealer.Open(message.ID, doc.TextSealed)
+	if err != nil {
+		return err
+	}
+	var fields sealedFields
+	if err := json.Unmarshal(plaintext, &fields); err != nil {
+		return fmt.Errorf("invalid encrypted fields: %w", err)
+	}
+	fields.restore(message)
+	return nil
+}
+
+// decodeHits unmarshals search hits into messages, skipping broken documents
+func (e *ElasticsearchEngine) decodeHits(hits []*elastic.SearchHit) []models.Message {
+	var messages []models.Message
+	for _, hit := range hits {
+		var msg models.Message
+		if err := e.decodeMessage(hit.Source, &msg); err != nil {
+			log.WithError(err).WithField("document_id", hit.Id).Warn("Failed to unmarshal search result")
+			continue
+		}
+		messages = append(messages, msg)
+	}
+	return messages
+}
+
+// blindQuery matches the blind tokens of keyword, or is nil without
+// encryption. Exact matches need every token; tokens carry no positions, so
+// an exact match cannot tell whether they are in the keyword's order.
+func (e *ElasticsearchEngine) blindQuery(keyword string, exact bool) elastic.Query {
+	if e.sealer == nil {
+		return nil
+	}
+	tokens := e.sealer.BlindTokens(keyword)
+	if len(tokens) == 0 {
+		return nil
+	}
+
+	query := elastic.NewBoolQuery()
+	for _, token := range tokens {
+		query.Should(elastic.NewTermQuery("text_blind", token))
+	}
+	if exact {
+		return query.MinimumNumberShouldMatch(len(tokens))
+	}
+	return query.MinimumShouldMatch(cjkMinimumShouldMatch)
+}
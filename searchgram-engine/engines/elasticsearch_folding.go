@@ -0,0 +1,80 @@
+package engines
+
+import (
+	"context"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// FoldTraditionalToSimplified indexes and searches Chinese text as
+	// Simplified, so either script matches both
+	FoldTraditionalToSimplified = "t2s"
+
+	// FoldSimplifiedToTraditional folds into Traditional instead
+	FoldSimplifiedToTraditional = "s2t"
+
+	// foldingCharFilter names the char filter converting between scripts
+	foldingCharFilter = "chinese_folding"
+)
+
+// AnalysisConfig selects optional text analysis steps. They are part of
+// the index settings, so they apply to new indices and take effect on
+// existing ones after a reindex.
+type AnalysisConfig struct {
+	// ChineseFolding converts Traditional and Simplified Chinese into one
+	// script before tokenizing, at index and query time alike: one of the
+	// Fold constants, or "" to match each script only by itself. It needs
+	// the STConvert analysis plugin on every Elasticsearch node.
+	ChineseFolding string
+}
+
+// charFilters returns the char filters of the text analyzers
+func (a AnalysisConfig) charFilters() map[string]interface{} {
+	if a.ChineseFolding == "" {
+		return nil
+	}
+	return map[string]interface{}{
+		foldingCharFilter: map[string]interface{}{
+			"type":         "stconvert",
+			"convert_type": a.ChineseFolding,
+		},
+	}
+}
+
+// checkFolding warns when the message index was created with another
+// Chinese folding than configured, which only a reindex changes
+func (e *ElasticsearchEngine) checkFolding(ctx context.Context) {
+	settings, err := e.client.IndexGetSettings(e.index).Do(ctx)
+	if err != nil {
+		log.WithError(err).WithField("index", e.index).Warn("Failed to check Chinese folding of the index")
+		return
+	}
+
+	for index, response := range settings {
+		current := ""
+		if filter, ok := nestedSetting(response.Settings, "index", "analysis", "char_filter", foldingCharFilter).(map[string]interface{}); ok {
+			current, _ = filter["convert_type"].(string)
+		}
+		if current != e.analysis.ChineseFolding {
+			log.WithFields(log.Fields{
+				"index":      index,
+				"configured": e.analysis.ChineseFolding,
+				"current":    current,
+			}).Warn("Chinese folding of the index differs from the configuration until a reindex")
+		}
+	}
+}
+
+// nestedSetting looks up a value in nested index settings
+func nestedSetting(settings map[string]interface{}, path ...string) interface{} {
+	var value interface{} = settings
+	for _, key := range path {
+		m, ok := value.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		value = m[key]
+	}
+	return value
+}
@@ -0,0 +1,196 @@
+package engines
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/olivere/elastic/v7"
+)
+
+const (
+	// cjkMinimumShouldMatch is the share of bigrams of a CJK run a message
+	// must contain, so one odd bigram does not rule out a match
+	cjkMinimumShouldMatch = "75%"
+
+	// termBoost ranks messages tagged with a dictionary term above those
+	// merely containing it
+	termBoost = 2.0
+
+	// expansionBoost ranks messages matching another spelling of a keyword
+	// below those matching it as typed
+	expansionBoost = 0.5
+)
+
+// TermMatcher finds the dictionary terms a text contains
+type TermMatcher interface {
+	Match(text string) []string
+}
+
+// SetDictionary makes dictionary terms in keywords match as a whole
+func (e *ElasticsearchEngine) SetDictionary(dictionary TermMatcher) {
+	e.dictionary = dictionary
+}
+
+// KeywordExpander returns the other spellings a keyword may have been
+// meant as, such as the keyword retyped on another keyboard layout
+type KeywordExpander interface {
+	Expand(keyword string) []string
+}
+
+// SetKeywordExpander makes fuzzy searches also match the spellings expander
+// returns for their keyword
+func (e *ElasticsearchEngine) SetKeywordExpander(expander KeywordExpander) {
+	e.expander = expander
+}
+
+// expandKeyword returns the other spellings searched for keyword
+func (e *ElasticsearchEngine) expandKeyword(keyword string) []string {
+	if e.expander == nil {
+		return nil
+	}
+	return e.expander.Expand(keyword)
+}
+
+// keywordFields are the message fields a fuzzy keyword search matches
+var keywordFields = []string{"text", "caption", "file_name", "poll_question", "poll_options", "transcript", "ocr_text"}
+
+// scriptRun is a stretch of a keyword written in one kind of script
+type scriptRun struct {
+	text string
+	cjk  bool
+}
+
+// isCJK reports whether r is a Han, Kana or Hangul character
+func isCJK(r rune) bool {
+	return unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul)
+}
+
+// scriptRuns splits a keyword where it switches between CJK and other
+// letters or digits. Spaces and punctuation stay with the run they follow,
+// so "内存 泄漏" is one run and "glibc 内存泄漏" two.
+func scriptRuns(keyword string) []scriptRun {
+	var runs []scriptRun
+	var current strings.Builder
+	cjk, started := false, false
+
+	flush := func() {
+		if text := strings.TrimSpace(current.String()); text != "" {
+			runs = append(runs, scriptRun{text: text, cjk: cjk})
+		}
+		current.Reset()
+	}
+
+	for _, r := range keyword {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if started && isCJK(r) != cjk {
+				flush()
+			}
+			cjk, started = isCJK(r), true
+		}
+		current.WriteRune(r)
+	}
+	flush()
+	return runs
+}
+
+// foldWidth turns full-width ASCII into its regular form, as the cjk_width
+// filter does for indexed text
+func foldWidth(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= '\uFF01' && r <= '\uFF5E' {
+			return r - 0xFEE0
+		}
+		if r == '\u3000' {
+			return ' '
+		}
+		return r
+	}, s)
+}
+
+// isMixedScript reports whether runs hold both CJK and other letters
+func isMixedScript(runs []scriptRun) bool {
+	cjk, other := false, false
+	for _, run := range runs {
+		if run.cjk {
+			cjk = true
+		} else {
+			other = true
+		}
+	}
+	return cjk && other
+}
+
+// fieldsQuery matches when the query built for any keyword field does
+func fieldsQuery(build func(field string) elastic.Query) *elastic.BoolQuery {
+	query := elastic.NewBoolQuery()
+	for _, field := range keywordFields {
+		query.Should(build(field))
+	}
+	return query
+}
+
+// keywordQuery matches a keyword in any keyword field.
+//
+// A single match query handles keywords mixing CJK with other scripts, such
+// as "glibc 内存泄漏", poorly: any one bigram or word is enough to match, so
+// the few messages about the topic drown among messages sharing a common
+// bigram. Mixed keywords are split into runs of one script instead, each of
+// which has to match: a CJK run as bigrams through the CJK analyzer, most of
+// which must be present, and other runs as words through the standard
+// analyzer, all of which must be present. Messages containing the keyword as
+// written rank first.
+func keywordQuery(keyword string) elastic.Query {
+	runs := scriptRuns(keyword)
+	if !isMixedScript(runs) {
+		return fieldsQuery(func(field string) elastic.Query {
+			return elastic.NewMatchQuery(field, keyword)
+		})
+	}
+
+	query := elastic.NewBoolQuery()
+	for _, run := range runs {
+		run := run
+		if run.cjk {
+			query.Must(fieldsQuery(func(field string) elastic.Query {
+				return elastic.NewMatchQuery(field, run.text).
+					Analyzer("cjk_analyzer").
+					MinimumShouldMatch(cjkMinimumShouldMatch)
+			}))
+		} else {
+			query.Must(fieldsQuery(func(field string) elastic.Query {
+				return elastic.NewMatchQuery(field, foldWidth(run.text)).
+					Analyzer("standard").
+					Operator("and")
+			}))
+		}
+	}
+	query.Should(fieldsQuery(func(field string) elastic.Query {
+		return elastic.NewMatchPhraseQuery(field, keyword)
+	}))
+	return query
+}
+
+// keywordQuery matches a keyword like the package-level keywordQuery, but
+// requires the dictionary terms it contains as a whole. Bigrams alone let
+// "鸿蒙系统" match any message about some 系统; a term matches messages
+// tagged with it at ingest, or containing it as a phrase, which covers
+// messages indexed before the term was added. Tagged messages rank first.
+func (e *ElasticsearchEngine) keywordQuery(keyword string) elastic.Query {
+	query := keywordQuery(keyword)
+	if e.dictionary == nil {
+		return query
+	}
+	terms := e.dictionary.Match(keyword)
+	if len(terms) == 0 {
+		return query
+	}
+
+	whole := elastic.NewBoolQuery().Must(query)
+	for _, term := range terms {
+		term := term
+		whole.Must(fieldsQuery(func(field string) elastic.Query {
+			return elastic.NewMatchPhraseQuery(field, term)
+		}).Should(elastic.NewTermQuery("terms", term).Boost(termBoost)))
+	}
+	return whole
+}
@@ -0,0 +1,254 @@
+package engines
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/olivere/elastic/v7"
+	log "github.com/sirupsen/logrus"
+	"github.com/zhishengyuan/searchgram-engine/models"
+)
+
+// ErrSnapshotNotFound is returned when restoring a snapshot that does not exist
+var ErrSnapshotNotFound = errors.New("snapshot not found")
+
+// snapshotTimeLayout names snapshots after the index and their UTC start time
+const snapshotTimeLayout = "20060102-150405"
+
+// ForceMerge merges the message index down to maxSegments segments per
+// shard (0 = Elasticsearch's choice), reclaiming the space of deleted
+// documents. It blocks until the merge is done.
+func (e *ElasticsearchEngine) ForceMerge(ctx context.Context, maxSegments int) (*models.ForceMergeResult, error) {
+	start := time.Now()
+
+	service := e.client.Forcemerge(e.index)
+	if maxSegments > 0 {
+		service = service.MaxNumSegments(maxSegments)
+	}
+	resp, err := service.Do(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to force merge: %w", err)
+	}
+
+	result := &models.ForceMergeResult{
+		Index:       e.index,
+		MaxSegments: maxSegments,
+		TookMs:      time.Since(start).Milliseconds(),
+	}
+	if resp.Shards != nil {
+		result.Shards = resp.Shards.Successful
+		result.Failed = resp.Shards.Failed
+	}
+
+	log.WithFields(log.Fields{
+		"index":   e.index,
+		"shards":  result.Shards,
+		"took_ms": result.TookMs,
+	}).Info("Force merged index")
+
+	return result, nil
+}
+
+// Snapshot snapshots the message index and recycle bin into a registered
+// snapshot repository and waits for it to finish. With keep > 0, older
+// snapshots made this way beyond the newest keep are deleted afterwards.
+func (e *ElasticsearchEngine) Snapshot(ctx context.Context, repository string, keep int) (*models.SnapshotResult, error) {
+	prefix := strings.ToLower(e.index) + "-"
+	name := prefix + time.Now().UTC().Format(snapshotTimeLayout)
+
+	resp, err := e.client.SnapshotCreate(repository, name).
+		WaitForCompletion(true).
+		BodyJson(map[string]interface{}{
+			"indices":              e.index + "," + e.trashIndex(),
+			"ignore_unavailable":   true, // The recycle bin index may not exist
+			"include_global_state": false,
+		}).
+		Do(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create snapshot %s: %w", name, err)
+	}
+	if resp.Snapshot == nil {
+		return nil, fmt.Errorf("snapshot %s was not reported as finished", name)
+	}
+
+	result := &models.SnapshotResult{
+		Repository: repository,
+		Snapshot:   resp.Snapshot.Snapshot,
+		State:      resp.Snapshot.State,
+		Indices:    resp.Snapshot.Indices,
+		DurationMs: resp.Snapshot.DurationInMillis,
+	}
+	if result.State != "SUCCESS" {
+		return result, fmt.Errorf("snapshot %s finished in state %s", name, result.State)
+	}
+
+	log.WithFields(log.Fields{
+		"repository":  repository,
+		"snapshot":    result.Snapshot,
+		"duration_ms": result.DurationMs,
+	}).Info("Created snapshot")
+
+	if keep > 0 {
+		result.Deleted, err = e.pruneSnapshots(ctx, repository, prefix, keep)
+		if err != nil {
+			return result, err
+		}
+	}
+	return result, nil
+}
+
+// pruneSnapshots deletes all but the newest keep snapshots named prefix
+// followed by a snapshot time
+func (e *ElasticsearchEngine) pruneSnapshots(ctx context.Context, repository, prefix string, keep int) ([]string, error) {
+	resp, err := e.client.SnapshotGet(repository).Snapshot(prefix + "*").Do(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list snapshots: %w", err)
+	}
+
+	var names []string
+	for _, snapshot := range resp.Snapshots {
+		suffix := strings.TrimPrefix(snapshot.Snapshot, prefix)
+		if _, err := time.Parse(snapshotTimeLayout, suffix); err == nil && suffix != snapshot.Snapshot {
+			names = append(names, snapshot.Snapshot)
+		}
+	}
+	if len(names) <= keep {
+		return nil, nil
+	}
+
+	// The layout sorts chronologically
+	sort.Strings(names)
+	var deleted []string
+	for _, name := range names[:len(names)-keep] {
+		if _, err := e.client.SnapshotDelete(repository, name).Do(ctx); err != nil {
+			return deleted, fmt.Errorf("failed to delete snapshot %s: %w", name, err)
+		}
+		deleted = append(deleted, name)
+	}
+
+	log.WithFields(log.Fields{
+		"repository": repository,
+		"deleted":    len(deleted),
+	}).Info("Deleted old snapshots")
+
+	return deleted, nil
+}
+
+// DropIndex deletes the message index with all its versions and partitions,
+// the recycle bin and the audit index, e.g. when the tenant owning them is
+// removed. The engine cannot be used afterwards.
+func (e *ElasticsearchEngine) DropIndex(ctx context.Context) error {
+	rows, err := e.client.CatIndices().Index(e.index + "*").Do(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list indices of %s: %w", e.index, err)
+	}
+
+	for _, row := range rows {
+		if !e.isMessageIndex(row.Index) && row.Index != e.trashIndex() && row.Index != e.auditIndex() {
+			continue
+		}
+		if _, err := e.client.DeleteIndex(row.Index).Do(ctx); err != nil && !elastic.IsNotFound(err) {
+			return fmt.Errorf("failed to delete index %s: %w", row.Index, err)
+		}
+	}
+	e.forgetPartitions()
+
+	log.WithField("index", e.index).Info("Dropped index")
+	return nil
+}
+
+// RecreateIndex deletes the message index and creates it again with the
+// current settings and mappings, including the embedding field if mapped,
+// as monthly partitions when configured. The recycle bin and versions the
+// alias no longer points to are left alone.
+func (e *ElasticsearchEngine) RecreateIndex(ctx context.Context) error {
+	indices, err := e.backingIndices(ctx)
+	if err != nil {
+		return err
+	}
+	for _, index := range indices {
+		if _, err := e.client.DeleteIndex(index).Do(ctx); err != nil && !elastic.IsNotFound(err) {
+			return fmt.Errorf("failed to delete index %s: %w", index, err)
+		}
+	}
+	e.forgetPartitions()
+	if err := e.initializeIndex(e.shards, e.replicas); err != nil {
+		return err
+	}
+	if e.embeddingDims > 0 {
+		if err := e.EnableEmbeddings(e.embeddingDims); err != nil {
+			return err
+		}
+	}
+
+	log.WithField("index", e.index).Info("Recreated index")
+	return nil
+}
+
+// RestoreSnapshot replaces the message index and recycle bin with their
+// copies in a snapshot and waits for the restore to finish. Mappings added
+// since the snapshot was taken are applied afterwards.
+func (e *ElasticsearchEngine) RestoreSnapshot(ctx context.Context, repository, snapshot string) error {
+	// Check the snapshot exists before deleting anything
+	found, err := e.client.SnapshotGet(repository).Snapshot(snapshot).Do(ctx)
+	if err != nil {
+		if elastic.IsNotFound(err) {
+			return fmt.Errorf("%w: %s", ErrSnapshotNotFound, snapshot)
+		}
+		return fmt.Errorf("failed to get snapshot %s: %w", snapshot, err)
+	}
+
+	e.trashMu.Lock()
+	defer e.trashMu.Unlock()
+	e.trashReady = false
+
+	// The snapshot may hold a version under its own name, restored together
+	// with the alias pointing to it
+	indices := []string{e.trashIndex()}
+	for _, info := range found.Snapshots {
+		indices = append(indices, e.messageIndices(info.Indices)...)
+	}
+	current, err := e.backingIndices(ctx)
+	if err != nil {
+		return err
+	}
+	for _, index := range append(current, indices...) {
+		if _, err := e.client.DeleteIndex(index).Do(ctx); err != nil && !elastic.IsNotFound(err) {
+			return fmt.Errorf("failed to delete index %s: %w", index, err)
+		}
+	}
+	e.forgetPartitions()
+
+	resp, err := e.client.SnapshotRestore(repository, snapshot).
+		Indices(indices...).
+		IgnoreUnavailable(true). // The recycle bin may not have been snapshotted
+		IncludeGlobalState(false).
+		WaitForCompletion(true).
+		Do(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to restore snapshot %s: %w", snapshot, err)
+	}
+	if resp.Snapshot != nil && resp.Snapshot.Shards.Failed > 0 {
+		return fmt.Errorf("snapshot %s restored with %d failed shards", snapshot, resp.Snapshot.Shards.Failed)
+	}
+
+	// A snapshot without the message index leaves none behind
+	if err := e.initializeIndex(e.shards, e.replicas); err != nil {
+		return err
+	}
+	if e.embeddingDims > 0 {
+		if err := e.EnableEmbeddings(e.embeddingDims); err != nil {
+			return err
+		}
+	}
+
+	log.WithFields(log.Fields{
+		"repository": repository,
+		"snapshot":   snapshot,
+	}).Info("Restored snapshot")
+	return nil
+}
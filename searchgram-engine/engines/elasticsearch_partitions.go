@@ -0,0 +1,305 @@
+package engines
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/olivere/elastic/v7"
+	log "github.com/sirupsen/logrus"
+	"github.com/zhishengyuan/searchgram-engine/models"
+)
+
+const (
+	// PartitionMonth stores messages in one index per UTC month they were
+	// sent in
+	PartitionMonth = "month"
+
+	// partitionLayout names partitions after their month, e.g. telegram-2026.10
+	partitionLayout = "2006.01"
+
+	// partitionScript routes a copied message to the partition of the month
+	// it was sent in, like messageTime; params.prefix is the index name and a dash
+	partitionScript = `def ts = ctx._source.timestamp;
+if (ts == null || ((Number) ts).longValue() <= 0) { ts = ctx._source.date; }
+long seconds = ts == null ? 0 : ((Number) ts).longValue();
+ctx._index = params.prefix + DateTimeFormatter.ofPattern('yyyy.MM').format(Instant.ofEpochSecond(seconds).atZone(ZoneOffset.UTC));`
+)
+
+// PartitionConfig selects time-partitioned message indices
+type PartitionConfig struct {
+	Period    string // PartitionMonth, or "" for a single index
+	ILMPolicy string // Index lifecycle policy attached to new partitions ("" = none)
+}
+
+// partitionIndex returns the partition holding the messages sent in the
+// month of t
+func (e *ElasticsearchEngine) partitionIndex(t time.Time) string {
+	return e.index + "-" + t.UTC().Format(partitionLayout)
+}
+
+// isPartition reports whether name is a monthly partition of the message index
+func (e *ElasticsearchEngine) isPartition(name string) bool {
+	suffix := strings.TrimPrefix(name, e.index+"-")
+	if suffix == name {
+		return false
+	}
+	_, err := time.Parse(partitionLayout, suffix)
+	return err == nil
+}
+
+// messageTime returns when a message was sent, falling back to the legacy
+// date field
+func messageTime(message *models.Message) time.Time {
+	if message.Timestamp > 0 {
+		return time.Unix(message.Timestamp, 0)
+	}
+	return time.Unix(message.Date, 0)
+}
+
+// writeIndex returns the index a message is written to: its month's
+// partition, created on first use, or the message index itself
+func (e *ElasticsearchEngine) writeIndex(ctx context.Context, message *models.Message) (string, error) {
+	if !e.partitioned.Load() {
+		return e.index, nil
+	}
+	index := e.partitionIndex(messageTime(message))
+	if err := e.ensurePartition(ctx, index, true); err != nil {
+		return "", err
+	}
+	return index, nil
+}
+
+// ensurePartition creates a partition unless it exists. Partitions created
+// for a reindex are only added to the alias when it switches over, and must
+// not exist yet, e.g. left over from the layout before a rollback.
+func (e *ElasticsearchEngine) ensurePartition(ctx context.Context, index string, aliased bool) error {
+	e.partitionMu.Lock()
+	defer e.partitionMu.Unlock()
+
+	if aliased && e.partitions[index] {
+		return nil
+	}
+
+	exists, err := e.client.IndexExists(index).Do(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to check partition %s: %w", index, err)
+	}
+	if exists && !aliased {
+		return fmt.Errorf("partition %s already exists", index)
+	}
+	if !exists {
+		month, err := time.Parse(partitionLayout, strings.TrimPrefix(index, e.index+"-"))
+		if err != nil {
+			return fmt.Errorf("invalid partition %s: %w", index, err)
+		}
+
+		// Lifecycle ages count from the end of the month, once the
+		// partition no longer receives new messages
+		settings := map[string]interface{}{}
+		if e.partition.ILMPolicy != "" && !e.lifecycleHeld.Load() {
+			settings["index.lifecycle.name"] = e.partition.ILMPolicy
+			settings["index.lifecycle.origination_date"] = month.AddDate(0, 1, 0).UnixMilli()
+		}
+
+		alias := ""
+		if aliased {
+			alias = e.index
+		}
+		if err := e.createIndex(ctx, index, alias, settings); err != nil {
+			// Another instance may have created it meanwhile
+			if exists, _ := e.client.IndexExists(index).Do(ctx); !exists || !aliased {
+				return err
+			}
+		} else {
+			log.WithField("index", index).Info("Created message index partition")
+		}
+	}
+
+	e.partitions[index] = true
+	return nil
+}
+
+// PauseLifecycle detaches the lifecycle policy from the partitions behind
+// the alias, so none of them is deleted, or attaches it again. Partitions
+// created while paused get no policy until it is attached again.
+func (e *ElasticsearchEngine) PauseLifecycle(ctx context.Context, paused bool) (int, error) {
+	e.partitionMu.Lock()
+	defer e.partitionMu.Unlock()
+
+	e.lifecycleHeld.Store(paused)
+	if e.partition.ILMPolicy == "" {
+		return 0, nil
+	}
+
+	backing, err := e.backingIndices(ctx)
+	if err != nil {
+		return 0, err
+	}
+	var indices []string
+	for _, index := range backing {
+		if e.isPartition(index) {
+			indices = append(indices, index)
+		}
+	}
+	if len(indices) == 0 {
+		return 0, nil
+	}
+
+	var policy interface{}
+	if !paused {
+		policy = e.partition.ILMPolicy
+	}
+	_, err = e.client.IndexPutSettings(indices...).
+		BodyJson(map[string]interface{}{"index.lifecycle.name": policy}).
+		Do(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to update partition lifecycle: %w", err)
+	}
+
+	log.WithFields(log.Fields{
+		"partitions": len(indices),
+		"paused":     paused,
+	}).Info("Updated partition lifecycle")
+	return len(indices), nil
+}
+
+// ensurePartitions creates the partitions for the months between from and
+// to. On failure the ones handled so far are returned with the error.
+func (e *ElasticsearchEngine) ensurePartitions(ctx context.Context, from, to time.Time, aliased bool) ([]string, error) {
+	var indices []string
+	month := time.Date(from.UTC().Year(), from.UTC().Month(), 1, 0, 0, 0, 0, time.UTC)
+	for !month.After(to) {
+		index := e.partitionIndex(month)
+		if err := e.ensurePartition(ctx, index, aliased); err != nil {
+			return indices, err
+		}
+		indices = append(indices, index)
+		month = month.AddDate(0, 1, 0)
+	}
+	return indices, nil
+}
+
+// messageTimeRange returns when the first and last messages matching query
+// in indices were sent; ok is false when none match
+func (e *ElasticsearchEngine) messageTimeRange(ctx context.Context, indices []string, query elastic.Query) (first, last time.Time, ok bool, err error) {
+	// Legacy messages only carry date
+	sentAt := elastic.NewScript("doc['timestamp'].size() > 0 && doc['timestamp'].value > 0 ? doc['timestamp'].value : (doc['date'].size() > 0 ? doc['date'].value : 0)")
+
+	result, err := e.client.Search(indices...).
+		Query(query).
+		Size(0).
+		Aggregation("first", elastic.NewMinAggregation().Script(sentAt)).
+		Aggregation("last", elastic.NewMaxAggregation().Script(sentAt)).
+		Do(ctx)
+	if err != nil {
+		return first, last, false, fmt.Errorf("failed to get message time range: %w", err)
+	}
+
+	min, found := result.Aggregations.Min("first")
+	if !found || min.Value == nil {
+		return first, last, false, nil
+	}
+	max, found := result.Aggregations.Max("last")
+	if !found || max.Value == nil {
+		return first, last, false, nil
+	}
+	return time.Unix(int64(*min.Value), 0), time.Unix(int64(*max.Value), 0), true, nil
+}
+
+// partitionTargets creates the partitions needed to copy the messages
+// matching query from indices, plus the current month's, and returns them
+func (e *ElasticsearchEngine) partitionTargets(ctx context.Context, indices []string, query elastic.Query, aliased bool) ([]string, error) {
+	now := time.Now()
+	first, last, ok, err := e.messageTimeRange(ctx, indices, query)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		first, last = now, now
+	}
+	if last.Before(now) {
+		last = now
+	}
+	return e.ensurePartitions(ctx, first, last, aliased)
+}
+
+// forgetPartitions drops the partitions known to exist after indices were
+// deleted
+func (e *ElasticsearchEngine) forgetPartitions() {
+	e.partitionMu.Lock()
+	defer e.partitionMu.Unlock()
+	e.partitions = make(map[string]bool)
+}
+
+// refreshPartitioned records whether the alias points to partitions, which
+// decides where writes go
+func (e *ElasticsearchEngine) refreshPartitioned(ctx context.Context) error {
+	indices, err := e.backingIndices(ctx)
+	if err != nil {
+		return err
+	}
+
+	partitioned := len(indices) > 0
+	for _, index := range indices {
+		if !e.isPartition(index) {
+			partitioned = false
+		}
+	}
+	e.partitioned.Store(partitioned)
+	return nil
+}
+
+// getDocument fetches a message document by ID, wherever it is stored.
+// Partitions are looked up with a multi-get, which unlike a search also
+// finds messages written a moment ago. nil is returned when not found.
+func (e *ElasticsearchEngine) getDocument(ctx context.Context, id string, source bool) (*elastic.GetResult, error) {
+	if !e.partitioned.Load() {
+		doc, err := e.client.Get().
+			Index(e.index).
+			Id(id).
+			FetchSource(source).
+			Do(ctx)
+		if elastic.IsNotFound(err) {
+			return nil, nil
+		}
+		return doc, err
+	}
+
+	indices, err := e.backingIndices(ctx)
+	if err != nil || len(indices) == 0 {
+		return nil, err
+	}
+	mget := e.client.Mget()
+	for _, index := range indices {
+		mget.Add(elastic.NewMultiGetItem().
+			Index(index).
+			Id(id).
+			FetchSource(elastic.NewFetchSourceContext(source)))
+	}
+
+	resp, err := mget.Do(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, doc := range resp.Docs {
+		if doc.Found {
+			return doc, nil
+		}
+	}
+	return nil, nil
+}
+
+// documentIndex returns the concrete index holding a message, or "" when
+// it is not found. Without partitions this is the message index as is.
+func (e *ElasticsearchEngine) documentIndex(ctx context.Context, id string) (string, error) {
+	if !e.partitioned.Load() {
+		return e.index, nil
+	}
+	doc, err := e.getDocument(ctx, id, false)
+	if err != nil || doc == nil {
+		return "", err
+	}
+	return doc.Index, nil
+}
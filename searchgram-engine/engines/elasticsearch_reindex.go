@@ -0,0 +1,346 @@
+package engines
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/olivere/elastic/v7"
+	log "github.com/sirupsen/logrus"
+	"github.com/zhishengyuan/searchgram-engine/models"
+)
+
+const (
+	// indexVersionLayout names versions of the message index after their
+	// UTC creation time, e.g. telegram-v20261016-040000
+	indexVersionLayout = "20060102-150405"
+
+	// reindexPollInterval is how often a running reindex task is checked
+	reindexPollInterval = 2 * time.Second
+)
+
+// versionIndex returns the name of a version of the message index
+func (e *ElasticsearchEngine) versionIndex(t time.Time) string {
+	return e.index + "-v" + t.UTC().Format(indexVersionLayout)
+}
+
+// isMessageIndex reports whether name is the message index, a version or a
+// partition of it, as opposed to the recycle bin or a mounted snapshot
+func (e *ElasticsearchEngine) isMessageIndex(name string) bool {
+	if name == e.index || e.isPartition(name) {
+		return true
+	}
+	suffix := strings.TrimPrefix(name, e.index+"-v")
+	if suffix == name {
+		return false
+	}
+	_, err := time.Parse(indexVersionLayout, suffix)
+	return err == nil
+}
+
+// messageIndices returns the message indices among a snapshot's indices
+func (e *ElasticsearchEngine) messageIndices(indices []string) []string {
+	var list []string
+	for _, index := range indices {
+		if e.isMessageIndex(index) {
+			list = append(list, index)
+		}
+	}
+	return list
+}
+
+// backingIndices returns the concrete indices the message index name
+// resolves to: the alias target, or itself for an index created by an older
+// release before its first reindex. None are returned when the index does
+// not exist.
+func (e *ElasticsearchEngine) backingIndices(ctx context.Context) ([]string, error) {
+	resp, err := e.client.Aliases().Index(e.index).Do(ctx)
+	if err != nil {
+		if elastic.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to resolve index %s: %w", e.index, err)
+	}
+
+	indices := make([]string, 0, len(resp.Indices))
+	for index := range resp.Indices {
+		indices = append(indices, index)
+	}
+	return indices, nil
+}
+
+// createIndex creates an empty index with the current settings plus extra
+// ones and mappings, including the embedding field if mapped, and
+// optionally an alias pointing to it
+func (e *ElasticsearchEngine) createIndex(ctx context.Context, name, alias string, extra map[string]interface{}) error {
+	properties := indexProperties()
+	if e.embeddingDims > 0 {
+		properties["embedding"] = map[string]interface{}{
+			"type": "dense_vector",
+			"dims": e.embeddingDims,
+		}
+	}
+
+	settings := map[string]interface{}{
+		"number_of_shards":   e.shards,
+		"number_of_replicas": e.replicas,
+		"analysis":           e.indexAnalysis(),
+	}
+	for key, value := range extra {
+		settings[key] = value
+	}
+
+	body := map[string]interface{}{
+		"settings": settings,
+		"mappings": map[string]interface{}{
+			"properties": properties,
+		},
+	}
+	if alias != "" {
+		body["aliases"] = map[string]interface{}{
+			alias: map[string]interface{}{},
+		}
+	}
+
+	_, err := e.client.CreateIndex(name).BodyJson(body).Do(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to create index %s: %w", name, err)
+	}
+	return nil
+}
+
+// Reindex copies every message into a new index created with the current
+// settings and mappings, then atomically points the index name at it as an
+// alias, so mapping changes Elasticsearch cannot apply in place take effect
+// without downtime. Searches and writes keep using the old index until the
+// switch. A second pass afterwards copies messages indexed or edited during
+// the first; writes made during that pass and hard deletes made while
+// copying are not carried over.
+//
+// The previous version is kept after the switch for RollbackIndex. An index
+// created by an older release is a concrete index under the alias name,
+// which has to be deleted in the same step as the alias is added.
+//
+// With partitioning configured, an unpartitioned index is copied into
+// monthly partitions; without it, partitions are merged into a single
+// version. Partitioned messages cannot be reindexed into partitions again,
+// as they would be copied onto themselves.
+func (e *ElasticsearchEngine) Reindex(ctx context.Context, progress func(*models.ReindexProgress)) (*models.ReindexResult, error) {
+	start := time.Now()
+
+	sources, err := e.backingIndices(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(sources) == 0 {
+		return nil, fmt.Errorf("index %s does not exist", e.index)
+	}
+	partition := e.partition.Period == PartitionMonth
+	if partition && e.partitioned.Load() {
+		return nil, fmt.Errorf("index %s is already partitioned", e.index)
+	}
+	if len(sources) > 1 && !e.partitioned.Load() {
+		return nil, fmt.Errorf("index %s resolves to %d indices, expected 1", e.index, len(sources))
+	}
+	sort.Strings(sources)
+
+	// Until the alias is switched a failed reindex leaves the new indices behind
+	var targets []string
+	switched := false
+	defer func() {
+		if switched {
+			return
+		}
+		for _, target := range targets {
+			if _, err := e.client.DeleteIndex(target).Do(context.Background()); err != nil {
+				log.WithError(err).WithField("index", target).Warn("Failed to delete incomplete reindex target")
+			}
+		}
+		e.forgetPartitions()
+	}()
+
+	// Partitions are created for every month with messages; the script
+	// routes each message to its month's, whatever the destination index
+	var script *elastic.Script
+	if partition {
+		targets, err = e.partitionTargets(ctx, sources, elastic.NewMatchAllQuery(), false)
+		if err != nil {
+			return nil, err
+		}
+		script = elastic.NewScript(partitionScript).Param("prefix", e.index+"-")
+	} else {
+		target := e.versionIndex(start)
+		if err := e.createIndex(ctx, target, "", nil); err != nil {
+			return nil, err
+		}
+		targets = []string{target}
+	}
+
+	result := &models.ReindexResult{
+		Alias:  e.index,
+		Source: strings.Join(sources, ","),
+		Target: strings.Join(targets, ","),
+	}
+	dest := targets[len(targets)-1]
+
+	log.WithFields(log.Fields{
+		"source": result.Source,
+		"target": result.Target,
+	}).Info("Reindexing messages...")
+
+	copied, err := e.copyIndex(ctx, models.ReindexPhaseCopy, result.Source, dest, script, progress)
+	if err != nil {
+		return nil, err
+	}
+	result.Copied = copied.Created + copied.Updated
+
+	// External versions carry over edits made during the first pass; messages
+	// unchanged since they were copied are skipped as version conflicts
+	caughtUp, err := e.copyIndex(ctx, models.ReindexPhaseCatchUp, result.Source, dest, script, progress)
+	if err != nil {
+		return nil, err
+	}
+	result.CaughtUp = caughtUp.Created + caughtUp.Updated
+
+	var actions []elastic.AliasAction
+	for _, target := range targets {
+		actions = append(actions, elastic.NewAliasAddAction(e.index).Index(target))
+	}
+	for _, source := range sources {
+		if source == e.index {
+			actions = append(actions, elastic.NewAliasRemoveIndexAction(source))
+			result.SourceRemoved = true
+		} else {
+			actions = append(actions, elastic.NewAliasRemoveAction(e.index).Index(source))
+		}
+	}
+	if _, err := e.client.Alias().Action(actions...).Do(ctx); err != nil {
+		return nil, fmt.Errorf("failed to switch alias %s to %s: %w", e.index, result.Target, err)
+	}
+	switched = true
+	e.partitioned.Store(partition)
+	result.TookMs = time.Since(start).Milliseconds()
+
+	log.WithFields(log.Fields{
+		"source":    result.Source,
+		"target":    result.Target,
+		"copied":    result.Copied,
+		"caught_up": result.CaughtUp,
+		"took_ms":   result.TookMs,
+	}).Info("Reindexed messages")
+
+	return result, nil
+}
+
+// reindexStatus holds the counts of a reindex task
+type reindexStatus struct {
+	Total            int64 `json:"total"`
+	Created          int64 `json:"created"`
+	Updated          int64 `json:"updated"`
+	VersionConflicts int64 `json:"version_conflicts"`
+	Noops            int64 `json:"noops"`
+}
+
+// done returns how many messages the task has read so far
+func (s reindexStatus) done() int64 {
+	return s.Created + s.Updated + s.VersionConflicts + s.Noops
+}
+
+// reindexTask is the task management API's view of a reindex
+type reindexTask struct {
+	Completed bool `json:"completed"`
+	Task      struct {
+		Status reindexStatus `json:"status"`
+	} `json:"task"`
+	Error    *elastic.ErrorDetails `json:"error"`
+	Response *struct {
+		Failures []json.RawMessage `json:"failures"`
+	} `json:"response"`
+}
+
+// copyIndex copies all documents of source, a comma-separated list of
+// indices, into target with the Reindex API, keeping their versions as
+// external versions, and reports progress until the task completes. A
+// script may route documents to other indices. Cancelling ctx cancels the
+// task.
+func (e *ElasticsearchEngine) copyIndex(ctx context.Context, phase, source, target string, script *elastic.Script, progress func(*models.ReindexProgress)) (*reindexStatus, error) {
+	body := map[string]interface{}{
+		"source": map[string]interface{}{
+			"index": strings.Split(source, ","),
+			"size":  scanPageSize,
+		},
+		"dest": map[string]interface{}{
+			"index":        target,
+			"version_type": "external",
+		},
+		"conflicts": "proceed",
+	}
+	if script != nil {
+		src, err := script.Source()
+		if err != nil {
+			return nil, fmt.Errorf("failed to start reindex into %s: %w", target, err)
+		}
+		body["script"] = src
+	}
+
+	started, err := e.client.Reindex().
+		Body(body).
+		Slices("auto").
+		Refresh("true").
+		DoAsync(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start reindex into %s: %w", target, err)
+	}
+
+	ticker := time.NewTicker(reindexPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			if _, err := e.client.TasksCancel().TaskId(started.TaskId).Do(context.Background()); err != nil {
+				log.WithError(err).WithField("task", started.TaskId).Warn("Failed to cancel reindex task")
+			}
+			return nil, ctx.Err()
+		case <-ticker.C:
+		}
+
+		resp, err := e.client.PerformRequest(ctx, elastic.PerformRequestOptions{
+			Method: "GET",
+			Path:   "/_tasks/" + url.PathEscape(started.TaskId),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to check reindex task %s: %w", started.TaskId, err)
+		}
+		var task reindexTask
+		if err := json.Unmarshal(resp.Body, &task); err != nil {
+			return nil, fmt.Errorf("failed to decode reindex task %s: %w", started.TaskId, err)
+		}
+
+		status := task.Task.Status
+		if progress != nil {
+			progress(&models.ReindexProgress{
+				Phase:  phase,
+				Source: source,
+				Target: target,
+				Total:  status.Total,
+				Done:   status.done(),
+			})
+		}
+		if !task.Completed {
+			continue
+		}
+
+		if task.Error != nil {
+			return nil, fmt.Errorf("reindex into %s failed: %s", target, task.Error.Reason)
+		}
+		if task.Response != nil && len(task.Response.Failures) > 0 {
+			return nil, fmt.Errorf("reindex into %s failed for %d documents, first: %s", target, len(task.Response.Failures), task.Response.Failures[0])
+		}
+		return &status, nil
+	}
+}
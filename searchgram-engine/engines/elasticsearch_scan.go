@@ -0,0 +1,94 @@
+package engines
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/olivere/elastic/v7"
+	"github.com/zhishengyuan/searchgram-engine/models"
+)
+
+// ScanMessages reads messages in pages of scanPageSize
+const scanPageSize = 1000
+
+// ScanMessages passes every stored message, soft-deleted ones included, to
+// fn one page at a time. The pages come from a point-in-time scroll, so
+// writes made while scanning are not seen. An error from fn stops the scan.
+func (e *ElasticsearchEngine) ScanMessages(ctx context.Context, fn func(messages []models.Message) error) error {
+	scroll := e.client.Scroll(e.index).
+		Query(elastic.NewMatchAllQuery()).
+		Size(scanPageSize).
+		Sort("_doc", true)
+	return e.scanPages(ctx, scroll, fn)
+}
+
+// ScanUserMessages passes every message a user sent to fn, soft-deleted
+// ones and those in the recycle bin included, ordered by chat and message ID.
+// A message both indexed and in the recycle bin, e.g. indexed again after
+// its delete, is passed once, as indexed.
+func (e *ElasticsearchEngine) ScanUserMessages(ctx context.Context, userID int64, fn func(messages []models.Message) error) error {
+	// Legacy messages only carry chat.id
+	chatID := elastic.NewScript("doc['chat_id'].size() > 0 && doc['chat_id'].value != 0 ? doc['chat_id'].value : (doc['chat.id'].size() > 0 ? doc['chat.id'].value : 0)")
+
+	scroll := e.client.Scroll(e.index, e.trashIndex()).
+		IgnoreUnavailable(true).
+		Query(userQuery(userID)).
+		Size(scanPageSize).
+		SortBy(
+			elastic.NewScriptSort(chatID, "number"),
+			elastic.NewFieldSort("message_id"),
+			elastic.NewFieldSort("_index"), // The live index sorts before its trash index
+		)
+
+	var lastChatID, lastMessageID int64
+	seen := false
+	return e.scanPages(ctx, scroll, func(messages []models.Message) error {
+		unique := messages[:0]
+		for _, message := range messages {
+			if message.ChatID == 0 {
+				message.ChatID = message.Chat.ID
+			}
+			if seen && message.ChatID == lastChatID && message.MessageID == lastMessageID {
+				continue
+			}
+			lastChatID, lastMessageID, seen = message.ChatID, message.MessageID, true
+			unique = append(unique, message)
+		}
+		if len(unique) == 0 {
+			return nil
+		}
+		return fn(unique)
+	})
+}
+
+// scanPages runs a scroll to the end, passing each page to fn
+func (e *ElasticsearchEngine) scanPages(ctx context.Context, scroll *elastic.ScrollService, fn func(messages []models.Message) error) error {
+	defer scroll.Clear(context.Background())
+
+	for {
+		page, err := scroll.Do(ctx)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to scan messages: %w", err)
+		}
+
+		messages := make([]models.Message, 0, len(page.Hits.Hits))
+		for _, hit := range page.Hits.Hits {
+			var message models.Message
+			if err := e.decodeMessage(hit.Source, &message); err != nil {
+				return fmt.Errorf("failed to decode message %s: %w", hit.Id, err)
+			}
+			messages = append(messages, message)
+		}
+		if len(messages) == 0 {
+			continue
+		}
+
+		if err := fn(messages); err != nil {
+			return err
+		}
+	}
+}
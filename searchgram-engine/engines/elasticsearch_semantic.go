@@ -0,0 +1,290 @@
+package engines
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/olivere/elastic/v7"
+	log "github.com/sirupsen/logrus"
+	"github.com/zhishengyuan/searchgram-engine/models"
+)
+
+const (
+	// rrfK dampens the weight of top ranks in reciprocal rank fusion
+	rrfK = 60
+
+	// Hybrid search fuses at least hybridMinWindow and at most
+	// hybridMaxWindow results from each ranking
+	hybridMinWindow = 100
+	hybridMaxWindow = 1000
+
+	// vectorScript scores by cosine similarity, shifted to stay non-negative
+	vectorScript = "cosineSimilarity(params.query_vector, 'embedding') + 1.0"
+)
+
+// Reranker reorders candidate messages by relevance to a query, typically
+// with a cross-encoder. It returns one score per message, higher is better.
+type Reranker interface {
+	Rerank(ctx context.Context, query string, messages []models.Message) ([]float64, error)
+}
+
+// SetReranker enables reranking the top of hybrid search results
+func (e *ElasticsearchEngine) SetReranker(reranker Reranker) {
+	e.reranker = reranker
+}
+
+// EnableEmbeddings maps the dense_vector field that semantic search ranks by.
+// The dimensions must match the embedding model and cannot change later.
+func (e *ElasticsearchEngine) EnableEmbeddings(dims int) error {
+	_, err := e.client.PutMapping().
+		Index(e.index).
+		BodyJson(map[string]interface{}{
+			"properties": map[string]interface{}{
+				"embedding": map[string]interface{}{
+					"type": "dense_vector",
+					"dims": dims,
+				},
+			},
+		}).
+		Do(context.Background())
+	if err != nil {
+		return fmt.Errorf("failed to map embedding field: %w", err)
+	}
+	e.embeddingDims = dims
+
+	log.WithField("dims", dims).Info("Embedding field mapped for semantic search")
+	return nil
+}
+
+// vectorQuery ranks the messages matching the request filters by similarity
+// to the query vector; messages without an embedding are left out
+func (e *ElasticsearchEngine) vectorQuery(req *models.SearchRequest) elastic.Query {
+	filters := *req
+	filters.Keyword = ""
+	filterQuery := e.buildQuery(&filters).Filter(elastic.NewExistsQuery("embedding"))
+
+	return elastic.NewScriptScoreQuery(
+		filterQuery,
+		elastic.NewScript(vectorScript).Param("query_vector", req.QueryVector),
+	)
+}
+
+// semanticSearch ranks by embedding similarity ("knn"), or fuses the
+// similarity ranking with the keyword ranking by reciprocal rank fusion
+// ("hybrid"), so paraphrases are found alongside literal matches
+func (e *ElasticsearchEngine) semanticSearch(ctx context.Context, req *models.SearchRequest) (*models.SearchResponse, error) {
+	if req.SemanticMode == models.SemanticKNN {
+		return e.knnSearch(ctx, req)
+	}
+	return e.hybridSearch(ctx, req)
+}
+
+// knnSearch returns a page of the messages most similar to the query
+func (e *ElasticsearchEngine) knnSearch(ctx context.Context, req *models.SearchRequest) (*models.SearchResponse, error) {
+	sortOrder := models.SortOrder(models.SortByRelevance)
+
+	var trackTotalHits interface{} = true
+	if req.Degraded {
+		trackTotalHits = degradedTotalHits
+	}
+
+	search := e.client.Search().
+		Index(e.indexFor(req)).
+		Query(e.vectorQuery(req)).
+		FetchSourceContext(searchSource()).
+		From((req.Page - 1) * req.PageSize).
+		Size(req.HitLimit()).
+		TrackTotalHits(trackTotalHits)
+	result, err := sortedSearch(search, sortOrder).Do(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("semantic search failed: %w", err)
+	}
+
+	messages := e.decodeHits(result.Hits.Hits)
+	totalHits := result.Hits.TotalHits.Value
+
+	return &models.SearchResponse{
+		Hits:        messages,
+		TotalHits:   totalHits,
+		TotalPages:  int((totalHits + int64(req.PageSize) - 1) / int64(req.PageSize)),
+		Page:        req.Page,
+		HitsPerPage: req.PageSize,
+		Sort:        sortOrder,
+	}, nil
+}
+
+// hybridSearch fuses the top keyword and vector matches by weighted
+// reciprocal rank fusion, optionally reranks the head of the fused ranking,
+// and returns a page of the result
+func (e *ElasticsearchEngine) hybridSearch(ctx context.Context, req *models.SearchRequest) (*models.SearchResponse, error) {
+	rerankTopK := 0
+	if req.RerankTopK != nil && e.reranker != nil {
+		rerankTopK = *req.RerankTopK
+	}
+
+	window := req.Page * req.PageSize
+	if window < rerankTopK {
+		window = rerankTopK
+	}
+	if window < hybridMinWindow {
+		window = hybridMinWindow
+	}
+	if window > hybridMaxWindow {
+		window = hybridMaxWindow
+	}
+	sortOrder := models.SortOrder(models.SortByRelevance)
+
+	queries := []struct {
+		query  elastic.Query
+		weight *float64
+	}{
+		{e.buildQuery(req), req.LexicalWeight},
+		{e.vectorQuery(req), req.SemanticWeight},
+	}
+
+	rankings := make([][]*elastic.SearchHit, 0, len(queries))
+	weights := make([]float64, 0, len(queries))
+	for _, q := range queries {
+		weight := 1.0
+		if q.weight != nil {
+			weight = *q.weight
+		}
+		if weight <= 0 {
+			continue
+		}
+
+		search := e.client.Search().
+			Index(e.indexFor(req)).
+			Query(q.query).
+			FetchSourceContext(searchSource()).
+			Size(window)
+		result, err := sortedSearch(search, sortOrder).Do(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("hybrid search failed: %w", err)
+		}
+		rankings = append(rankings, result.Hits.Hits)
+		weights = append(weights, weight)
+	}
+
+	fused := fuseRankings(rankings, weights)
+	totalHits := int64(len(fused))
+
+	from := (req.Page - 1) * req.PageSize
+	if from > len(fused) {
+		from = len(fused)
+	}
+	to := from + req.HitLimit()
+	if to > len(fused) {
+		to = len(fused)
+	}
+
+	sortKeys := []string{"rrf:desc"}
+	var messages []models.Message
+	if rerankTopK > 0 && from < rerankTopK {
+		// Decode everything up to the end of the page so the reranked head
+		// and the fused tail line up
+		end := rerankTopK
+		if end < to {
+			end = to
+		}
+		if end > len(fused) {
+			end = len(fused)
+		}
+		candidates := e.decodeHits(fused[:end])
+		if e.rerank(ctx, req.Keyword, candidates, rerankTopK) {
+			sortKeys = []string{"rerank:desc", "rrf:desc"}
+		}
+		if from > len(candidates) {
+			from = len(candidates)
+		}
+		if to > len(candidates) {
+			to = len(candidates)
+		}
+		messages = candidates[from:to]
+	} else {
+		messages = e.decodeHits(fused[from:to])
+	}
+
+	return &models.SearchResponse{
+		Hits:        messages,
+		TotalHits:   totalHits,
+		TotalPages:  int((totalHits + int64(req.PageSize) - 1) / int64(req.PageSize)),
+		Page:        req.Page,
+		HitsPerPage: req.PageSize,
+		Sort:        sortKeys,
+	}, nil
+}
+
+// rerank reorders the first topK messages in place by reranker score. A
+// failing reranker leaves the fused order and reports false.
+func (e *ElasticsearchEngine) rerank(ctx context.Context, query string, messages []models.Message, topK int) bool {
+	if topK > len(messages) {
+		topK = len(messages)
+	}
+	head := messages[:topK]
+
+	scores, err := e.reranker.Rerank(ctx, query, head)
+	if err == nil && len(scores) != len(head) {
+		err = fmt.Errorf("reranker returned %d scores for %d messages", len(scores), len(head))
+	}
+	if err != nil {
+		log.WithError(err).Warn("Reranking failed, keeping fused order")
+		return false
+	}
+
+	order := make([]int, len(head))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return scores[order[i]] > scores[order[j]]
+	})
+
+	reranked := make([]models.Message, len(head))
+	for i, idx := range order {
+		reranked[i] = head[idx]
+	}
+	copy(head, reranked)
+	return true
+}
+
+// fuseRankings merges rankings by weighted reciprocal rank fusion: each hit
+// scores the sum of weight/(rrfK + rank) over the rankings it appears in.
+// Ties keep the order of first appearance.
+func fuseRankings(rankings [][]*elastic.SearchHit, weights []float64) []*elastic.SearchHit {
+	scores := make(map[string]float64)
+	var hits []*elastic.SearchHit
+	for i, ranking := range rankings {
+		for rank, hit := range ranking {
+			if _, seen := scores[hit.Id]; !seen {
+				hits = append(hits, hit)
+			}
+			scores[hit.Id] += weights[i] / float64(rrfK+rank+1)
+		}
+	}
+
+	sort.SliceStable(hits, func(i, j int) bool {
+		return scores[hits[i].Id] > scores[hits[j].Id]
+	})
+	return hits
+}
+
+// searchSource leaves the embedding and blind tokens out of returned documents
+func searchSource() *elastic.FetchSourceContext {
+	return elastic.NewFetchSourceContext(true).Exclude("embedding", "text_blind")
+}
+
+// sortedSearch applies a SortOrder to a search
+func sortedSearch(search *elastic.SearchService, sortOrder []string) *elastic.SearchService {
+	for _, key := range sortOrder {
+		field, direction, _ := strings.Cut(key, ":")
+		if field == "_score" {
+			search = search.SortBy(elastic.NewScoreSort().Order(direction == "asc"))
+			continue
+		}
+		search = search.SortBy(elastic.NewFieldSort(field).Order(direction == "asc").Missing("_last"))
+	}
+	return search
+}
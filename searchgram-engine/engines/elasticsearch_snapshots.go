@@ -0,0 +1,200 @@
+package engines
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/olivere/elastic/v7"
+	log "github.com/sirupsen/logrus"
+	"github.com/zhishengyuan/searchgram-engine/models"
+)
+
+// ErrSnapshotNotMounted is returned when searching a snapshot that has not
+// been mounted
+var ErrSnapshotNotMounted = errors.New("snapshot not mounted")
+
+// snapshotIndex returns the read-only index a snapshot is mounted as; a
+// snapshot of partitions is mounted as one index per partition behind it as
+// an alias
+func (e *ElasticsearchEngine) snapshotIndex(snapshot string) string {
+	return e.index + "-snapshot-" + strings.ToLower(snapshot)
+}
+
+// indexFor returns the index a search request reads: the live index, or the
+// mounted snapshot it names
+func (e *ElasticsearchEngine) indexFor(req *models.SearchRequest) string {
+	if req.Snapshot != "" {
+		return e.snapshotIndex(req.Snapshot)
+	}
+	return e.index
+}
+
+// checkMounted returns ErrSnapshotNotMounted unless the snapshot is mounted
+func (e *ElasticsearchEngine) checkMounted(ctx context.Context, snapshot string) error {
+	exists, err := e.client.IndexExists(e.snapshotIndex(snapshot)).Do(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to check snapshot index: %w", err)
+	}
+	if !exists {
+		return fmt.Errorf("%w: %s", ErrSnapshotNotMounted, snapshot)
+	}
+	return nil
+}
+
+// ListSnapshots lists the snapshots in a repository that hold the message
+// index, newest first, and whether each is mounted for searching
+func (e *ElasticsearchEngine) ListSnapshots(ctx context.Context, repository string) ([]models.SnapshotInfo, error) {
+	resp, err := e.client.SnapshotGet(repository).Do(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list snapshots: %w", err)
+	}
+
+	mounted := make(map[string]bool)
+	names, err := e.client.IndexNames()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list indices: %w", err)
+	}
+	for _, name := range names {
+		mounted[name] = true
+		// Partitions are mounted as <snapshot index>-<partition>
+		if i := strings.Index(name, "-"+e.index+"-"); i > 0 {
+			mounted[name[:i]] = true
+		}
+	}
+
+	list := make([]models.SnapshotInfo, 0, len(resp.Snapshots))
+	for _, snapshot := range resp.Snapshots {
+		if len(e.messageIndices(snapshot.Indices)) == 0 {
+			continue
+		}
+		info := models.SnapshotInfo{
+			Snapshot:  snapshot.Snapshot,
+			State:     snapshot.State,
+			StartTime: snapshot.StartTimeInMillis / 1000,
+			Mounted:   mounted[e.snapshotIndex(snapshot.Snapshot)],
+		}
+		if info.Mounted {
+			info.Index = e.snapshotIndex(snapshot.Snapshot)
+		}
+		list = append(list, info)
+	}
+
+	sort.Slice(list, func(i, j int) bool { return list[i].StartTime > list[j].StartTime })
+	return list, nil
+}
+
+// MountSnapshot restores the message index of a snapshot next to the live
+// one, read-only and without replicas, so it can be searched with
+// SearchRequest.Snapshot. Mounting a mounted snapshot does nothing.
+func (e *ElasticsearchEngine) MountSnapshot(ctx context.Context, repository, snapshot string) (*models.SnapshotMount, error) {
+	start := time.Now()
+	index := e.snapshotIndex(snapshot)
+	result := &models.SnapshotMount{
+		Repository: repository,
+		Snapshot:   snapshot,
+		Index:      index,
+	}
+
+	exists, err := e.client.IndexExists(index).Do(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check snapshot index: %w", err)
+	}
+	if exists {
+		return result, nil
+	}
+
+	// The snapshot holds the message index under its name at the time, the
+	// index name itself, a version or the partitions
+	found, err := e.client.SnapshotGet(repository).Snapshot(snapshot).Do(ctx)
+	if err != nil {
+		if elastic.IsNotFound(err) {
+			return nil, fmt.Errorf("%w: %s", ErrSnapshotNotFound, snapshot)
+		}
+		return nil, fmt.Errorf("failed to get snapshot %s: %w", snapshot, err)
+	}
+	var indices []string
+	for _, info := range found.Snapshots {
+		indices = append(indices, e.messageIndices(info.Indices)...)
+	}
+	if len(indices) == 0 {
+		return nil, fmt.Errorf("%w: %s holds no messages", ErrSnapshotNotFound, snapshot)
+	}
+
+	rename := index
+	if len(indices) > 1 {
+		rename = index + "-$1"
+	}
+	resp, err := e.client.SnapshotRestore(repository, snapshot).
+		Indices(indices...).
+		RenamePattern("(.+)").
+		RenameReplacement(rename).
+		IndexSettings(map[string]interface{}{
+			"index.number_of_replicas": 0,
+			"index.blocks.write":       true,
+		}).
+		IncludeAliases(false).
+		IncludeGlobalState(false).
+		WaitForCompletion(true).
+		Do(ctx)
+	if err != nil {
+		if elastic.IsNotFound(err) {
+			return nil, fmt.Errorf("%w: %s", ErrSnapshotNotFound, snapshot)
+		}
+		return nil, fmt.Errorf("failed to mount snapshot %s: %w", snapshot, err)
+	}
+	if resp.Snapshot != nil && resp.Snapshot.Shards.Failed > 0 {
+		return nil, fmt.Errorf("snapshot %s mounted with %d failed shards", snapshot, resp.Snapshot.Shards.Failed)
+	}
+	if len(indices) > 1 {
+		actions := make([]elastic.AliasAction, 0, len(indices))
+		for _, name := range indices {
+			actions = append(actions, elastic.NewAliasAddAction(index).Index(index+"-"+name))
+		}
+		if _, err := e.client.Alias().Action(actions...).Do(ctx); err != nil {
+			return nil, fmt.Errorf("failed to mount snapshot %s: %w", snapshot, err)
+		}
+	}
+	result.TookMs = time.Since(start).Milliseconds()
+
+	log.WithFields(log.Fields{
+		"repository": repository,
+		"snapshot":   snapshot,
+		"index":      index,
+	}).Info("Mounted snapshot")
+	return result, nil
+}
+
+// UnmountSnapshot deletes the indices a snapshot is mounted as; the
+// snapshot itself is kept
+func (e *ElasticsearchEngine) UnmountSnapshot(ctx context.Context, snapshot string) error {
+	index := e.snapshotIndex(snapshot)
+
+	// Resolves partitions mounted behind the alias, or the index itself
+	resp, err := e.client.Aliases().Index(index).Do(ctx)
+	if err != nil {
+		if elastic.IsNotFound(err) {
+			return fmt.Errorf("%w: %s", ErrSnapshotNotMounted, snapshot)
+		}
+		return fmt.Errorf("failed to unmount snapshot %s: %w", snapshot, err)
+	}
+	indices := make([]string, 0, len(resp.Indices))
+	for name := range resp.Indices {
+		indices = append(indices, name)
+	}
+	if _, err := e.client.DeleteIndex(indices...).Do(ctx); err != nil {
+		if elastic.IsNotFound(err) {
+			return fmt.Errorf("%w: %s", ErrSnapshotNotMounted, snapshot)
+		}
+		return fmt.Errorf("failed to unmount snapshot %s: %w", snapshot, err)
+	}
+
+	log.WithFields(log.Fields{
+		"snapshot": snapshot,
+		"index":    index,
+	}).Info("Unmounted snapshot")
+	return nil
+}
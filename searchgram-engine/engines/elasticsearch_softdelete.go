@@ -0,0 +1,124 @@
+package engines
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/olivere/elastic/v7"
+	log "github.com/sirupsen/logrus"
+	"github.com/zhishengyuan/searchgram-engine/models"
+)
+
+// markDeletedScript marks documents as deleted for Delete, DeleteUser and
+// SoftDeleteMessage. Their deletes are final, so a delete by query made
+// before is no longer restorable.
+const markDeletedScript = "ctx._source.is_deleted = true; ctx._source.deleted_at = params.now; ctx._source.remove('deleted_by')"
+
+// softDeleteScript marks documents as deleted by query, which RestoreDeleted
+// and PurgeDeleted act on
+const softDeleteScript = "ctx._source.is_deleted = true; ctx._source.deleted_at = params.now; ctx._source.deleted_by = params.by"
+
+// undeleteScript clears the deleted mark
+const undeleteScript = "ctx._source.is_deleted = false; ctx._source.remove('deleted_at'); ctx._source.remove('deleted_by')"
+
+// deletedQuery selects the messages soft-deleted by query matching filter.
+// Messages deleted in Telegram or with their chat or sender are left alone,
+// so a restore doesn't undo them.
+func (e *ElasticsearchEngine) deletedQuery(filter *models.DeletedFilter) *elastic.BoolQuery {
+	req := filter.SearchRequest
+	req.IncludeDeleted = true
+
+	query := e.buildQuery(&req).
+		Filter(elastic.NewTermQuery("is_deleted", true)).
+		Filter(elastic.NewTermQuery("deleted_by", models.DeletedByQuery))
+	if filter.DeletedAfter != nil || filter.DeletedBefore != nil {
+		deletedAt := elastic.NewRangeQuery("deleted_at")
+		if filter.DeletedAfter != nil {
+			deletedAt.Gte(*filter.DeletedAfter)
+		}
+		if filter.DeletedBefore != nil {
+			deletedAt.Lt(*filter.DeletedBefore)
+		}
+		query.Filter(deletedAt)
+	}
+	return query
+}
+
+// SoftDeleteByQuery marks live messages matching the search filters as deleted
+func (e *ElasticsearchEngine) SoftDeleteByQuery(req *models.SearchRequest) (int64, error) {
+	ctx := req.Context()
+
+	live := *req
+	live.IncludeDeleted = false
+
+	result, err := e.client.UpdateByQuery(e.index).
+		Query(e.buildQuery(&live)).
+		Script(elastic.NewScript(softDeleteScript).Param("now", time.Now().Unix()).Param("by", models.DeletedByQuery)).
+		ProceedOnVersionConflict().
+		Refresh("true").
+		Do(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to soft-delete by query: %w", err)
+	}
+
+	log.WithField("count", result.Updated).Info("Soft-deleted messages by query")
+
+	return result.Updated, nil
+}
+
+// RestoreDeleted clears the deleted mark of matching soft-deleted messages.
+// With dryRun, only the number of matching messages is returned.
+func (e *ElasticsearchEngine) RestoreDeleted(filter *models.DeletedFilter, dryRun bool) (int64, error) {
+	ctx := filter.Context()
+
+	query := e.deletedQuery(filter)
+	if dryRun {
+		count, err := e.client.Count(e.index).Query(query).Do(ctx)
+		if err != nil {
+			return 0, fmt.Errorf("failed to count deleted messages: %w", err)
+		}
+		return count, nil
+	}
+
+	result, err := e.client.UpdateByQuery(e.index).
+		Query(query).
+		Script(elastic.NewScript(undeleteScript)).
+		ProceedOnVersionConflict().
+		Refresh("true").
+		Do(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to restore deleted messages: %w", err)
+	}
+
+	log.WithField("count", result.Updated).Info("Restored soft-deleted messages")
+
+	return result.Updated, nil
+}
+
+// PurgeDeleted permanently removes matching soft-deleted messages. With
+// dryRun, only the number of matching messages is returned.
+func (e *ElasticsearchEngine) PurgeDeleted(filter *models.DeletedFilter, dryRun bool) (int64, error) {
+	ctx := filter.Context()
+
+	query := e.deletedQuery(filter)
+	if dryRun {
+		count, err := e.client.Count(e.index).Query(query).Do(ctx)
+		if err != nil {
+			return 0, fmt.Errorf("failed to count deleted messages: %w", err)
+		}
+		return count, nil
+	}
+
+	result, err := e.client.DeleteByQuery(e.index).
+		Query(query).
+		ProceedOnVersionConflict().
+		Refresh("true").
+		Do(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge deleted messages: %w", err)
+	}
+
+	log.WithField("count", result.Deleted).Info("Purged soft-deleted messages")
+
+	return result.Deleted, nil
+}
@@ -0,0 +1,453 @@
+package engines
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/olivere/elastic/v7"
+	log "github.com/sirupsen/logrus"
+	"github.com/zhishengyuan/searchgram-engine/models"
+)
+
+const (
+	// trashIndexSuffix names the recycle bin index after the message index
+	trashIndexSuffix = "-trash"
+
+	// Trashed documents are removed from the message index in pages of trashPageSize
+	trashPageSize = 1000
+
+	// ListTrash reports at most this many batches
+	maxTrashBatches = 1000
+)
+
+// trashScript stamps documents copied to the recycle bin with their batch
+const trashScript = "ctx._source.trash_batch = params.batch; ctx._source.trash_operation = params.operation; " +
+	"ctx._source.trash_target = params.target; ctx._source.trashed_at = params.now; " +
+	"ctx._source.trash_expires_at = params.expires"
+
+// trashFields are the recycle bin bookkeeping fields added to trashed documents
+var trashFields = []string{"trash_batch", "trash_operation", "trash_target", "trashed_at", "trash_expires_at"}
+
+// trashIndex returns the name of the recycle bin index
+func (e *ElasticsearchEngine) trashIndex() string {
+	return e.index + trashIndexSuffix
+}
+
+// trashProperties returns the field mappings for trashed documents
+func trashProperties() map[string]interface{} {
+	properties := indexProperties()
+	properties["trash_batch"] = map[string]interface{}{
+		"type": "keyword",
+	}
+	properties["trash_operation"] = map[string]interface{}{
+		"type": "keyword",
+	}
+	properties["trash_target"] = map[string]interface{}{
+		"type": "keyword",
+	}
+	properties["trashed_at"] = map[string]interface{}{
+		"type": "long",
+	}
+	properties["trash_expires_at"] = map[string]interface{}{
+		"type": "long",
+	}
+	// Kept for restores only, never searched in the trash
+	properties["embedding"] = map[string]interface{}{
+		"type":       "float",
+		"index":      false,
+		"doc_values": false,
+	}
+	return properties
+}
+
+// ensureTrashIndex creates the recycle bin index, or brings its mapping up to
+// date, the first time it is needed
+func (e *ElasticsearchEngine) ensureTrashIndex(ctx context.Context) error {
+	e.trashMu.Lock()
+	defer e.trashMu.Unlock()
+
+	if e.trashReady {
+		return nil
+	}
+
+	index := e.trashIndex()
+	exists, err := e.client.IndexExists(index).Do(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to check trash index existence: %w", err)
+	}
+
+	if exists {
+		_, err = e.client.PutMapping().
+			Index(index).
+			BodyJson(map[string]interface{}{
+				"properties": trashProperties(),
+			}).
+			Do(ctx)
+		if err != nil {
+			log.WithError(err).WithField("index", index).Warn("Failed to update trash index mapping, new fields may be dynamically mapped")
+		}
+	} else {
+		// Same analysis as the message index so restored documents match exactly;
+		// a single shard suffices for the comparatively small trash
+		_, err = e.client.CreateIndex(index).BodyJson(map[string]interface{}{
+			"settings": map[string]interface{}{
+				"number_of_shards":   1,
+				"number_of_replicas": e.replicas,
+				"analysis":           e.indexAnalysis(),
+			},
+			"mappings": map[string]interface{}{
+				"properties": trashProperties(),
+			},
+		}).Do(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to create trash index: %w", err)
+		}
+		log.WithField("index", index).Info("Created trash index")
+	}
+
+	e.trashReady = true
+	return nil
+}
+
+// trashSelectorQuery builds the query matching the messages a selector names
+func (e *ElasticsearchEngine) trashSelectorQuery(selector models.TrashSelector) (elastic.Query, error) {
+	switch {
+	case selector.ChatID != nil:
+		return chatQuery(*selector.ChatID), nil
+	case selector.UserID != nil:
+		return userQuery(*selector.UserID), nil
+	case selector.MessageID != "":
+		return elastic.NewIdsQuery().Ids(selector.MessageID), nil
+	case selector.Query != nil:
+		return e.buildQuery(selector.Query), nil
+	}
+	return nil, fmt.Errorf("empty trash selector")
+}
+
+// MoveToTrash copies the selected messages to the recycle bin as one batch
+// expiring after ttl, then removes exactly the copied versions from the index
+func (e *ElasticsearchEngine) MoveToTrash(ctx context.Context, selector models.TrashSelector, operation, target string, ttl time.Duration) (*models.TrashBatch, error) {
+	if err := e.ensureTrashIndex(ctx); err != nil {
+		return nil, err
+	}
+
+	query, err := e.trashSelectorQuery(selector)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	batch := &models.TrashBatch{
+		ID:        uuid.New().String(),
+		Operation: operation,
+		Target:    target,
+		TrashedAt: now.Unix(),
+		ExpiresAt: now.Add(ttl).Unix(),
+	}
+
+	// Read with sequence numbers, so each message is only deleted if it is
+	// still the version copied; messages indexed again meanwhile stay
+	scroll := e.client.Scroll(e.index).
+		SearchSource(elastic.NewSearchSource().Query(query).SeqNoAndPrimaryTerm(true)).
+		Size(trashPageSize)
+	defer scroll.Clear(context.Background())
+
+	var kept int64
+	for {
+		page, err := scroll.Do(ctx)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to list messages to trash: %w", err)
+		}
+
+		moved, changed, err := e.trashPage(ctx, batch, page.Hits.Hits)
+		batch.Count += moved
+		kept += changed
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	log.WithFields(log.Fields{
+		"batch":     batch.ID,
+		"operation": operation,
+		"target":    target,
+		"count":     batch.Count,
+		"kept":      kept,
+	}).Info("Moved messages to trash")
+
+	return batch, nil
+}
+
+// trashPage copies a page of messages into a trash batch and deletes the
+// copied versions from the index. Messages changed since they were read
+// are left in the index and their copies dropped; it returns how many
+// messages were moved and how many were left.
+func (e *ElasticsearchEngine) trashPage(ctx context.Context, batch *models.TrashBatch, hits []*elastic.SearchHit) (int64, int64, error) {
+	if len(hits) == 0 {
+		return 0, 0, nil
+	}
+
+	// A message trashed before is overwritten and leaves its earlier batch
+	copies := e.client.Bulk().Refresh("true")
+	for _, hit := range hits {
+		var source map[string]interface{}
+		if err := json.Unmarshal(hit.Source, &source); err != nil {
+			return 0, 0, fmt.Errorf("failed to decode message %s: %w", hit.Id, err)
+		}
+		source["trash_batch"] = batch.ID
+		source["trash_operation"] = batch.Operation
+		source["trash_target"] = batch.Target
+		source["trashed_at"] = batch.TrashedAt
+		source["trash_expires_at"] = batch.ExpiresAt
+		copies.Add(elastic.NewBulkIndexRequest().Index(e.trashIndex()).Id(hit.Id).Doc(source))
+	}
+	copied, err := copies.Do(ctx)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to copy messages to trash: %w", err)
+	}
+	failed := make(map[string]bool)
+	for _, item := range copied.Failed() {
+		failed[item.Id] = true
+	}
+	if len(failed) > 0 {
+		log.WithField("count", len(failed)).Warn("Some messages could not be copied to trash and were kept")
+	}
+
+	deletes := e.client.Bulk().Refresh("true")
+	for _, hit := range hits {
+		if failed[hit.Id] || hit.SeqNo == nil || hit.PrimaryTerm == nil {
+			continue
+		}
+		deletes.Add(elastic.NewBulkDeleteRequest().
+			Index(hit.Index).
+			Id(hit.Id).
+			IfSeqNo(*hit.SeqNo).
+			IfPrimaryTerm(*hit.PrimaryTerm))
+	}
+	if deletes.NumberOfActions() == 0 {
+		return 0, int64(len(hits)), nil
+	}
+	deleted, err := deletes.Do(ctx)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to remove trashed messages: %w", err)
+	}
+
+	var moved int64
+	stale := e.client.Bulk().Refresh("true")
+	for _, item := range deleted.Deleted() {
+		switch {
+		case item.Status < 300, item.Status == 404:
+			// Deleted here, or by a concurrent delete after the copy
+			moved++
+		default:
+			// Indexed again since it was read: the live message stays and
+			// its outdated copy leaves the batch
+			stale.Add(elastic.NewBulkDeleteRequest().Index(e.trashIndex()).Id(item.Id))
+		}
+	}
+	if stale.NumberOfActions() > 0 {
+		if _, err := stale.Do(ctx); err != nil {
+			return moved, 0, fmt.Errorf("failed to drop outdated trash copies: %w", err)
+		}
+	}
+
+	return moved, int64(len(hits)) - moved, nil
+}
+
+// ListTrash lists recycle bin batches, most recently trashed first
+func (e *ElasticsearchEngine) ListTrash(ctx context.Context) ([]models.TrashBatch, error) {
+	if err := e.ensureTrashIndex(ctx); err != nil {
+		return nil, err
+	}
+
+	batchesAgg := elastic.NewTermsAggregation().
+		Field("trash_batch").
+		Size(maxTrashBatches).
+		OrderByAggregation("trashed_at", false).
+		SubAggregation("trashed_at", elastic.NewMaxAggregation().Field("trashed_at")).
+		SubAggregation("expires_at", elastic.NewMaxAggregation().Field("trash_expires_at")).
+		SubAggregation("info", elastic.NewTopHitsAggregation().
+			Size(1).
+			FetchSourceContext(elastic.NewFetchSourceContext(true).Include("trash_operation", "trash_target")))
+
+	result, err := e.client.Search().
+		Index(e.trashIndex()).
+		Size(0).
+		Aggregation("batches", batchesAgg).
+		Do(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list trash: %w", err)
+	}
+
+	batches := []models.TrashBatch{}
+	agg, found := result.Aggregations.Terms("batches")
+	if !found {
+		return batches, nil
+	}
+
+	for _, bucket := range agg.Buckets {
+		batch := models.TrashBatch{
+			ID:    fmt.Sprint(bucket.Key),
+			Count: bucket.DocCount,
+		}
+		if trashedAt, ok := bucket.Max("trashed_at"); ok && trashedAt.Value != nil {
+			batch.TrashedAt = int64(*trashedAt.Value)
+		}
+		if expiresAt, ok := bucket.Max("expires_at"); ok && expiresAt.Value != nil {
+			batch.ExpiresAt = int64(*expiresAt.Value)
+		}
+		if info, ok := bucket.TopHits("info"); ok && info.Hits != nil && len(info.Hits.Hits) > 0 {
+			var source struct {
+				Operation string `json:"trash_operation"`
+				Target    string `json:"trash_target"`
+			}
+			if err := json.Unmarshal(info.Hits.Hits[0].Source, &source); err == nil {
+				batch.Operation = source.Operation
+				batch.Target = source.Target
+			}
+		}
+		batches = append(batches, batch)
+	}
+
+	return batches, nil
+}
+
+// RestoreTrashBatch moves every message of a batch back into the index.
+// Messages indexed again since they were trashed are kept and counted as skipped.
+func (e *ElasticsearchEngine) RestoreTrashBatch(ctx context.Context, batchID string) (int64, int64, error) {
+	return e.restoreTrash(ctx, elastic.NewTermQuery("trash_batch", batchID))
+}
+
+// RestoreTrashMessage moves a single trashed message back into the index
+func (e *ElasticsearchEngine) RestoreTrashMessage(ctx context.Context, id string) (int64, int64, error) {
+	return e.restoreTrash(ctx, elastic.NewIdsQuery().Ids(id))
+}
+
+// restoreTrash copies matching trashed documents back without their trash
+// fields, never overwriting a live message, then drops them from the trash
+func (e *ElasticsearchEngine) restoreTrash(ctx context.Context, query elastic.Query) (int64, int64, error) {
+	if err := e.ensureTrashIndex(ctx); err != nil {
+		return 0, 0, err
+	}
+
+	source := "for (String field : params.fields) { ctx._source.remove(field); }"
+	dest := e.index
+
+	// Messages go back to the partitions of the months they were sent in
+	if e.partitioned.Load() {
+		if _, err := e.partitionTargets(ctx, []string{e.trashIndex()}, query, true); err != nil {
+			return 0, 0, err
+		}
+		source += "\n" + partitionScript
+		dest = e.partitionIndex(time.Now())
+	}
+	script := elastic.NewScript(source).
+		Param("fields", trashFields).
+		Param("prefix", e.index+"-")
+
+	result, err := e.client.Reindex().
+		Source(elastic.NewReindexSource().Index(e.trashIndex()).Query(query)).
+		Destination(elastic.NewReindexDestination().Index(dest).OpType("create")).
+		Script(script).
+		ProceedOnVersionConflict().
+		Refresh("true").
+		Do(ctx)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to restore messages from trash: %w", err)
+	}
+
+	_, err = e.client.DeleteByQuery(e.trashIndex()).
+		Query(query).
+		ProceedOnVersionConflict().
+		Refresh("true").
+		Do(ctx)
+	if err != nil {
+		return result.Created, result.VersionConflicts, fmt.Errorf("failed to remove restored messages from trash: %w", err)
+	}
+
+	log.WithFields(log.Fields{
+		"restored": result.Created,
+		"skipped":  result.VersionConflicts,
+	}).Info("Restored messages from trash")
+
+	return result.Created, result.VersionConflicts, nil
+}
+
+// PurgeTrash permanently removes trashed messages that expired before now
+func (e *ElasticsearchEngine) PurgeTrash(ctx context.Context, now time.Time, keepChats []int64) (int64, []string, error) {
+	if err := e.ensureTrashIndex(ctx); err != nil {
+		return 0, nil, err
+	}
+
+	expired := elastic.NewRangeQuery("trash_expires_at").Lte(now.Unix())
+	kept, err := e.trashBatchesOf(ctx, expired, keepChats)
+	if err != nil {
+		return 0, nil, err
+	}
+	query := elastic.NewBoolQuery().Filter(expired)
+	if len(kept) > 0 {
+		query.MustNot(elastic.NewTermsQuery("trash_batch", stringValues(kept)...))
+	}
+
+	result, err := e.client.DeleteByQuery(e.trashIndex()).
+		Query(query).
+		ProceedOnVersionConflict().
+		Do(ctx)
+	if err != nil {
+		return 0, kept, fmt.Errorf("failed to purge trash: %w", err)
+	}
+
+	if result.Deleted > 0 || len(kept) > 0 {
+		log.WithFields(log.Fields{
+			"purged": result.Deleted,
+			"kept":   len(kept),
+		}).Info("Purged expired messages from trash")
+	}
+
+	return result.Deleted, kept, nil
+}
+
+// trashBatchesOf returns the recycle bin batches matching query that hold
+// messages of chats
+func (e *ElasticsearchEngine) trashBatchesOf(ctx context.Context, query elastic.Query, chats []int64) ([]string, error) {
+	if len(chats) == 0 {
+		return nil, nil
+	}
+
+	ids := int64Values(chats)
+	inChats := elastic.NewBoolQuery().
+		Should(elastic.NewTermsQuery("chat_id", ids...)).
+		Should(elastic.NewTermsQuery("chat.id", ids...))
+	result, err := e.client.Search().
+		Index(e.trashIndex()).
+		Query(elastic.NewBoolQuery().Filter(query, inChats)).
+		Size(0).
+		Aggregation("batches", elastic.NewTermsAggregation().Field("trash_batch").Size(maxTrashBatches)).
+		Do(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find trash batches of chats: %w", err)
+	}
+
+	var batches []string
+	if agg, found := result.Aggregations.Terms("batches"); found {
+		for _, bucket := range agg.Buckets {
+			batches = append(batches, fmt.Sprint(bucket.Key))
+		}
+	}
+	return batches, nil
+}
+
+func stringValues(values []string) []interface{} {
+	out := make([]interface{}, len(values))
+	for i, value := range values {
+		out[i] = value
+	}
+	return out
+}
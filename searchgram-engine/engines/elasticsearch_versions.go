@@ -0,0 +1,150 @@
+package engines
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/olivere/elastic/v7"
+	log "github.com/sirupsen/logrus"
+	"github.com/zhishengyuan/searchgram-engine/models"
+)
+
+var (
+	// ErrIndexVersionNotFound is returned when rolling back to a version of
+	// the message index that does not exist
+	ErrIndexVersionNotFound = errors.New("index version not found")
+
+	// ErrIndexNotVersioned is returned when rolling back while the message
+	// index is a concrete index rather than an alias
+	ErrIndexNotVersioned = errors.New("index is not versioned")
+)
+
+// ListIndexVersions lists the versions and partitions of the message index,
+// newest first. Before the first reindex an index created by an older
+// release is listed under the index name itself.
+func (e *ElasticsearchEngine) ListIndexVersions(ctx context.Context) ([]models.IndexVersion, error) {
+	active, err := e.backingIndices(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := e.client.CatIndices().
+		Index(e.index + "*").
+		Bytes("b").
+		Do(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list index versions: %w", err)
+	}
+
+	isActive := make(map[string]bool, len(active))
+	for _, index := range active {
+		isActive[index] = true
+	}
+
+	list := make([]models.IndexVersion, 0, len(rows))
+	for _, row := range rows {
+		if !e.isMessageIndex(row.Index) {
+			continue
+		}
+		size, _ := strconv.ParseInt(row.StoreSize, 10, 64)
+		version := models.IndexVersion{
+			Index:     row.Index,
+			Active:    isActive[row.Index],
+			CreatedAt: row.CreationDate / 1000,
+			Documents: int64(row.DocsCount),
+			SizeBytes: size,
+		}
+		if e.isPartition(row.Index) {
+			version.Partition = strings.TrimPrefix(row.Index, e.index+"-")
+		}
+		list = append(list, version)
+	}
+
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].CreatedAt > list[j].CreatedAt
+	})
+	return list, nil
+}
+
+// RollbackIndex atomically points the message index alias at another
+// version, by default the newest one older than the active version, or
+// than the oldest active partition. Messages written since that version was
+// last active are not in it; they come back when rolling forward again.
+// Partitions cannot be rolled back to, as some may have been deleted since.
+func (e *ElasticsearchEngine) RollbackIndex(ctx context.Context, index string) (*models.IndexRollback, error) {
+	versions, err := e.ListIndexVersions(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var from []*models.IndexVersion
+	for i := range versions {
+		if versions[i].Active {
+			from = append(from, &versions[i])
+		}
+	}
+	if len(from) == 0 || from[0].Index == e.index {
+		return nil, ErrIndexNotVersioned
+	}
+	if len(from) > 1 && !e.partitioned.Load() {
+		return nil, fmt.Errorf("alias %s points to more than one index", e.index)
+	}
+	since := from[0].CreatedAt
+	for _, version := range from {
+		if version.CreatedAt < since {
+			since = version.CreatedAt
+		}
+	}
+
+	var to *models.IndexVersion
+	for i := range versions {
+		version := &versions[i]
+		if version.Active || version.Index == e.index || version.Partition != "" {
+			continue
+		}
+		if index == version.Index || (index == "" && version.CreatedAt <= since) {
+			to = version
+			break
+		}
+	}
+	if to == nil {
+		return nil, ErrIndexVersionNotFound
+	}
+
+	actions := []elastic.AliasAction{elastic.NewAliasAddAction(e.index).Index(to.Index)}
+	names := make([]string, 0, len(from))
+	for _, version := range from {
+		actions = append(actions, elastic.NewAliasRemoveAction(e.index).Index(version.Index))
+		names = append(names, version.Index)
+	}
+	sort.Strings(names)
+	if _, err := e.client.Alias().Action(actions...).Do(ctx); err != nil {
+		return nil, fmt.Errorf("failed to switch alias %s to %s: %w", e.index, to.Index, err)
+	}
+	e.partitioned.Store(false)
+
+	// The version may predate fields added since
+	if err := e.updateMapping(ctx); err != nil {
+		return nil, err
+	}
+	if e.embeddingDims > 0 {
+		if err := e.EnableEmbeddings(e.embeddingDims); err != nil {
+			return nil, err
+		}
+	}
+
+	log.WithFields(log.Fields{
+		"from": strings.Join(names, ","),
+		"to":   to.Index,
+	}).Info("Rolled back message index")
+
+	return &models.IndexRollback{
+		Alias: e.index,
+		From:  strings.Join(names, ","),
+		To:    to.Index,
+	}, nil
+}
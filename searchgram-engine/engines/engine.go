@@ -1,47 +1,168 @@
 package engines
 
-import "github.com/zhishengyuan/searchgram-engine/models"
+import (
+	"context"
+	"time"
+
+	"github.com/zhishengyuan/searchgram-engine/models"
+)
 
 // SearchEngine defines the interface for all search engine implementations
 type SearchEngine interface {
 	// Upsert indexes or updates a message
-	Upsert(message *models.Message) error
+	Upsert(ctx context.Context, message *models.Message) error
 
 	// UpsertBatch indexes or updates multiple messages in a single operation
-	UpsertBatch(messages []models.Message) (int, []string, error)
+	UpsertBatch(ctx context.Context, messages []models.Message) (int, []string, error)
+
+	// GetMessage retrieves a single message by composite ID.
+	// Returns nil if the message does not exist.
+	GetMessage(ctx context.Context, id string) (*models.Message, error)
+
+	// GetMessageContext retrieves up to before/after non-deleted messages
+	// adjacent to messageID in the same chat, each sorted by message_id ascending
+	GetMessageContext(ctx context.Context, chatID, messageID int64, before, after int) ([]models.Message, []models.Message, error)
+
+	// UpdateMessage applies fn to the stored message and writes it back.
+	// Returns nil if the message does not exist.
+	UpdateMessage(ctx context.Context, id string, fn func(message *models.Message)) (*models.Message, error)
 
 	// Search performs a search query
 	Search(req *models.SearchRequest) (*models.SearchResponse, error)
 
 	// Delete removes messages by chat ID
-	Delete(chatID int64) (int64, error)
+	Delete(ctx context.Context, chatID int64) (int64, error)
+
+	// DeleteMessage permanently removes a single message by composite ID.
+	// Returns false if the message does not exist.
+	DeleteMessage(ctx context.Context, id string) (bool, error)
+
+	// DeleteByQuery removes messages matching search filters; with dryRun it only counts them
+	DeleteByQuery(req *models.SearchRequest, dryRun bool) (int64, error)
+
+	// SoftDeleteByQuery marks live messages matching search filters as
+	// deleted, hiding them from searches until restored or purged
+	SoftDeleteByQuery(req *models.SearchRequest) (int64, error)
+
+	// RestoreDeleted clears the deleted mark of the selected soft-deleted
+	// messages; with dryRun it only counts them
+	RestoreDeleted(filter *models.DeletedFilter, dryRun bool) (int64, error)
+
+	// PurgeDeleted permanently removes the selected soft-deleted messages;
+	// with dryRun it only counts them
+	PurgeDeleted(filter *models.DeletedFilter, dryRun bool) (int64, error)
 
 	// DeleteUser removes all messages from a specific user
-	DeleteUser(userID int64) (int64, error)
+	DeleteUser(ctx context.Context, userID int64) (int64, error)
+
+	// MoveToTrash moves the selected messages to the recycle bin as one batch
+	// that expires after ttl. operation and target describe the delete.
+	MoveToTrash(ctx context.Context, selector models.TrashSelector, operation, target string, ttl time.Duration) (*models.TrashBatch, error)
+
+	// ListTrash lists recycle bin batches, most recently trashed first
+	ListTrash(ctx context.Context) ([]models.TrashBatch, error)
+
+	// RestoreTrashBatch moves a recycle bin batch back into the index and
+	// returns how many messages were restored and how many were skipped
+	// because they had been indexed again
+	RestoreTrashBatch(ctx context.Context, batchID string) (int64, int64, error)
+
+	// RestoreTrashMessage moves a single message back from the recycle bin,
+	// with the same counts as RestoreTrashBatch (both zero if it is not there)
+	RestoreTrashMessage(ctx context.Context, id string) (int64, int64, error)
+
+	// PurgeTrash permanently removes recycle bin messages that expired before
+	// now. Batches holding messages of keepChats are kept whole; their IDs
+	// are returned with the number of messages purged.
+	PurgeTrash(ctx context.Context, now time.Time, keepChats []int64) (int64, []string, error)
 
 	// Clear removes all documents from the index
-	Clear() error
+	Clear(ctx context.Context) error
+
+	// RecreateIndex drops the index and creates it empty with the current
+	// mappings, e.g. before restoring a backup taken by an older version
+	RecreateIndex(ctx context.Context) error
 
 	// Ping checks the health and returns stats
 	Ping() (*models.PingResponse, error)
 
 	// Stats returns detailed statistics
-	Stats() (*models.StatsResponse, error)
+	Stats(ctx context.Context) (*models.StatsResponse, error)
 
-	// Dedup removes duplicate messages (keeps latest by timestamp)
-	Dedup() (*models.DedupResponse, error)
+	// Dedup removes duplicate messages (keeps latest by timestamp).
+	// With dryRun nothing is deleted and the result carries a report instead.
+	// progress, if non-nil, receives running totals after each page.
+	Dedup(ctx context.Context, dryRun bool, progress func(*models.DedupResponse)) (*models.DedupResponse, error)
 
 	// GetUserStats retrieves activity statistics for a user in a group
-	GetUserStats(req *models.UserStatsRequest) (*models.UserStatsResponse, error)
+	GetUserStats(ctx context.Context, req *models.UserStatsRequest) (*models.UserStatsResponse, error)
 
 	// SoftDeleteMessage marks a single message as deleted
-	SoftDeleteMessage(chatID int64, messageID int64) error
+	SoftDeleteMessage(ctx context.Context, chatID int64, messageID int64) error
 
 	// CleanCommands removes all messages starting with '/' (bot commands)
-	CleanCommands() (*models.CleanCommandsResponse, error)
+	CleanCommands(ctx context.Context) (*models.CleanCommandsResponse, error)
+
+	// ListChats lists indexed chats with their latest title, most active first.
+	// With titleContains only chats whose title contains the phrase are listed.
+	ListChats(ctx context.Context, titleContains string, limit int) ([]models.ChatSummary, error)
+
+	// ChatActivity counts a chat's messages and their senders per interval
+	ChatActivity(ctx context.Context, req *models.ActivityRequest) (*models.ChatActivity, error)
+
+	// TrendingTerms finds the terms used unusually often in a chat during a
+	// period
+	TrendingTerms(ctx context.Context, req *models.TrendingRequest) (*models.TrendingTerms, error)
 
 	// GetMessageIDs retrieves all message IDs for a specific chat (for gap detection)
-	GetMessageIDs(chatID int64) (*models.GetMessageIDsResponse, error)
+	GetMessageIDs(ctx context.Context, chatID int64) (*models.GetMessageIDsResponse, error)
+
+	// ScanMessages passes every stored message, soft-deleted ones included,
+	// to fn in pages; an error from fn stops the scan
+	ScanMessages(ctx context.Context, fn func(messages []models.Message) error) error
+
+	// ScanUserMessages passes every message a user sent, soft-deleted and
+	// trashed ones included, to fn in pages ordered by chat and message ID,
+	// each message once
+	ScanUserMessages(ctx context.Context, userID int64, fn func(messages []models.Message) error) error
+
+	// ForceMerge merges the index down to maxSegments segments per shard
+	// (0 = engine's choice) to reclaim the space of deleted documents
+	ForceMerge(ctx context.Context, maxSegments int) (*models.ForceMergeResult, error)
+
+	// Snapshot snapshots the index into a snapshot repository, then deletes
+	// older snapshots made this way beyond the newest keep (0 = keep all)
+	Snapshot(ctx context.Context, repository string, keep int) (*models.SnapshotResult, error)
+
+	// RestoreSnapshot replaces the index with its copy in a snapshot
+	RestoreSnapshot(ctx context.Context, repository, snapshot string) error
+
+	// Reindex copies all messages into a new index with the current settings
+	// and mappings and switches searches and writes to it atomically.
+	// progress, if non-nil, receives the state of the running copy.
+	Reindex(ctx context.Context, progress func(*models.ReindexProgress)) (*models.ReindexResult, error)
+
+	// ListIndexVersions lists the versions of the index, newest first
+	ListIndexVersions(ctx context.Context) ([]models.IndexVersion, error)
+
+	// RollbackIndex switches searches and writes to another version of the
+	// index, by default the newest one older than the active version
+	RollbackIndex(ctx context.Context, index string) (*models.IndexRollback, error)
+
+	// PauseLifecycle detaches the lifecycle policy from the monthly
+	// partitions, so it deletes none of them, or attaches it again, and
+	// returns how many partitions were changed
+	PauseLifecycle(ctx context.Context, paused bool) (int, error)
+
+	// ListSnapshots lists the snapshots holding the index, newest first
+	ListSnapshots(ctx context.Context, repository string) ([]models.SnapshotInfo, error)
+
+	// MountSnapshot makes a snapshot searchable read-only next to the
+	// index, for searches with SearchRequest.Snapshot set
+	MountSnapshot(ctx context.Context, repository, snapshot string) (*models.SnapshotMount, error)
+
+	// UnmountSnapshot removes a mounted snapshot; the snapshot is kept
+	UnmountSnapshot(ctx context.Context, snapshot string) error
 
 	// Close closes the connection to the search engine
 	Close() error
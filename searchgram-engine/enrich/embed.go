@@ -0,0 +1,148 @@
+package enrich
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/zhishengyuan/searchgram-engine/models"
+)
+
+// maxEmbeddingRunes bounds the text sent for one embedding; longer messages
+// are embedded by their beginning
+const maxEmbeddingRunes = 2000
+
+// EmbedderConfig holds embedding backend settings
+type EmbedderConfig struct {
+	URL        string        // OpenAI-compatible /v1/embeddings endpoint
+	APIKey     string        // Optional bearer token
+	Model      string        // Model name sent with each request
+	Dimensions int           // Vector length the model returns
+	Timeout    time.Duration // Deadline for one embedding request
+}
+
+// Embedder turns message text into dense vectors for semantic search. As an
+// enricher it fills Embedding at ingest; Embed is also used for queries.
+// Messages that already carry an embedding are left alone.
+type Embedder struct {
+	cfg    EmbedderConfig
+	client *http.Client
+}
+
+// NewEmbedder creates an embedding stage
+func NewEmbedder(cfg EmbedderConfig) *Embedder {
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 10 * time.Second
+	}
+	return &Embedder{
+		cfg:    cfg,
+		client: &http.Client{},
+	}
+}
+
+// Name identifies the enricher
+func (e *Embedder) Name() string {
+	return "embedding"
+}
+
+// Enrich embeds the message's searchable text into Embedding
+func (e *Embedder) Enrich(message *models.Message) error {
+	if len(message.Embedding) > 0 {
+		return nil
+	}
+
+	text := EmbeddingText(message)
+	if text == "" {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), e.cfg.Timeout)
+	defer cancel()
+
+	vector, err := e.Embed(ctx, text)
+	if err != nil {
+		return err
+	}
+	message.Embedding = vector
+	return nil
+}
+
+// Embed returns the embedding of text
+func (e *Embedder) Embed(ctx context.Context, text string) ([]float32, error) {
+	payload, err := json.Marshal(map[string]interface{}{
+		"model": e.cfg.Model,
+		"input": text,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.cfg.URL, bytes.NewReader(payload))
+	if err != nil {
+		return nil, fmt.Errorf("invalid embeddings url: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if e.cfg.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+e.cfg.APIKey)
+	}
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("embedding request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, fmt.Errorf("embedding failed: HTTP %d: %s", resp.StatusCode, strings.TrimSpace(string(detail)))
+	}
+
+	var result struct {
+		Data []struct {
+			Embedding []float32 `json:"embedding"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("invalid embedding response: %w", err)
+	}
+	if len(result.Data) == 0 {
+		return nil, fmt.Errorf("embedding response has no data")
+	}
+
+	vector := result.Data[0].Embedding
+	if len(vector) != e.cfg.Dimensions {
+		return nil, fmt.Errorf("embedding has %d dimensions, expected %d", len(vector), e.cfg.Dimensions)
+	}
+	return vector, nil
+}
+
+// EmbeddingText joins the searchable text of a message for embedding
+func EmbeddingText(message *models.Message) string {
+	parts := []string{message.Text}
+	if message.Caption != nil {
+		parts = append(parts, *message.Caption)
+	}
+	if message.IsPoll {
+		parts = append(parts, message.PollQuestion)
+		parts = append(parts, message.PollOptions...)
+	}
+	parts = append(parts, message.Transcript, message.OCRText)
+
+	var nonEmpty []string
+	for _, part := range parts {
+		if part = strings.TrimSpace(part); part != "" {
+			nonEmpty = append(nonEmpty, part)
+		}
+	}
+
+	text := []rune(strings.Join(nonEmpty, "\n"))
+	if len(text) > maxEmbeddingRunes {
+		text = text[:maxEmbeddingRunes]
+	}
+	return string(text)
+}
@@ -0,0 +1,56 @@
+package enrich
+
+import (
+	log "github.com/sirupsen/logrus"
+	"github.com/zhishengyuan/searchgram-engine/models"
+)
+
+// Enricher derives or rewrites message fields before indexing
+type Enricher interface {
+	// Name identifies the enricher in logs
+	Name() string
+
+	// Enrich mutates the message in place
+	Enrich(message *models.Message) error
+}
+
+// Pipeline runs enrichers in order on every ingested message
+type Pipeline struct {
+	enrichers []Enricher
+	media     []Enricher // Read media files; run by a MediaWorker after indexing
+}
+
+// NewPipeline creates a pipeline from the given enrichers
+func NewPipeline(enrichers ...Enricher) *Pipeline {
+	return &Pipeline{enrichers: enrichers}
+}
+
+// Add appends an enricher to the pipeline
+func (p *Pipeline) Add(enricher Enricher) {
+	p.enrichers = append(p.enrichers, enricher)
+}
+
+// AddMedia appends an enricher reading the message's media file, e.g.
+// transcription or OCR. Downloading and reading media takes seconds per
+// message, so Process leaves them out; a MediaWorker runs them once the
+// message is indexed and enriches the stored message again.
+func (p *Pipeline) AddMedia(enricher Enricher) {
+	p.media = append(p.media, enricher)
+}
+
+// Process runs all enrichers but the media ones on a message. A failing enricher is logged and
+// skipped so that enrichment problems never block indexing.
+func (p *Pipeline) Process(message *models.Message) {
+	if p == nil {
+		return
+	}
+
+	for _, enricher := range p.enrichers {
+		if err := enricher.Enrich(message); err != nil {
+			log.WithError(err).WithFields(log.Fields{
+				"enricher": enricher.Name(),
+				"id":       message.ID,
+			}).Warn("Message enrichment failed")
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package enrich
+
+import (
+	"regexp"
+	"strings"
+	"unicode/utf16"
+
+	"github.com/zhishengyuan/searchgram-engine/models"
+)
+
+var (
+	hashtagPattern = regexp.MustCompile(`#[\p{L}\p{N}_]+`)
+	mentionPattern = regexp.MustCompile(`@[A-Za-z][A-Za-z0-9_]{3,31}`)
+	urlPattern     = regexp.MustCompile(`(?i)\bhttps?://[^\s<>"]+`)
+)
+
+// Entities fills the urls, mentions and hashtags keyword fields. Values the
+// client supplied are kept (normalized); otherwise they come from the message
+// entities, or from the text itself when the client sent no entities. Edits
+// clear the fields first, so they are extracted from the edited text.
+type Entities struct{}
+
+// Name identifies the enricher
+func (Entities) Name() string {
+	return "entities"
+}
+
+// Enrich sets URLs, Mentions and Hashtags
+func (Entities) Enrich(message *models.Message) error {
+	text := message.Text
+	if text == "" {
+		text = derefString(message.Caption)
+	}
+
+	var urls, mentions, hashtags []string
+	if len(message.Entities) > 0 {
+		urls, mentions, hashtags = fromEntities(text, message.Entities)
+	} else {
+		urls, mentions, hashtags = fromText(text)
+	}
+
+	if len(message.URLs) == 0 {
+		message.URLs = urls
+	}
+	if len(message.Mentions) == 0 {
+		message.Mentions = mentions
+	}
+	if len(message.Hashtags) == 0 {
+		message.Hashtags = hashtags
+	}
+
+	message.URLs = normalizeAll(message.URLs, strings.TrimSpace)
+	message.Mentions = normalizeAll(message.Mentions, models.NormalizeMention)
+	message.Hashtags = normalizeAll(message.Hashtags, models.NormalizeHashtag)
+	return nil
+}
+
+// fromEntities reads URLs, mentions and hashtags from Telegram entities,
+// whose offsets are in UTF-16 code units
+func fromEntities(text string, entities []models.MessageEntity) (urls, mentions, hashtags []string) {
+	units := utf16.Encode([]rune(text))
+	slice := func(entity models.MessageEntity) string {
+		end := entity.Offset + entity.Length
+		if entity.Offset < 0 || entity.Length <= 0 || end > len(units) {
+			return ""
+		}
+		return string(utf16.Decode(units[entity.Offset:end]))
+	}
+
+	for _, entity := range entities {
+		switch entity.Type {
+		case "URL":
+			urls = append(urls, slice(entity))
+		case "TEXT_LINK":
+			urls = append(urls, entity.URL)
+		case "MENTION":
+			mentions = append(mentions, slice(entity))
+		case "TEXT_MENTION":
+			if entity.User != nil && entity.User.Username != "" {
+				mentions = append(mentions, entity.User.Username)
+			}
+		case "HASHTAG":
+			hashtags = append(hashtags, slice(entity))
+		}
+	}
+	return urls, mentions, hashtags
+}
+
+// fromText finds URLs, mentions and hashtags with patterns when the client
+// sent no entities
+func fromText(text string) (urls, mentions, hashtags []string) {
+	for _, url := range urlPattern.FindAllString(text, -1) {
+		urls = append(urls, strings.TrimRight(url, ".,;:!?)]}'"))
+	}
+	for _, mention := range mentionPattern.FindAllStringIndex(text, -1) {
+		// Skip e-mail addresses
+		if mention[0] > 0 && isWordByte(text[mention[0]-1]) {
+			continue
+		}
+		mentions = append(mentions, text[mention[0]:mention[1]])
+	}
+	hashtags = hashtagPattern.FindAllString(text, -1)
+	return urls, mentions, hashtags
+}
+
+// isWordByte reports whether b is an ASCII letter, digit or underscore
+func isWordByte(b byte) bool {
+	return b == '_' || b >= '0' && b <= '9' || b >= 'a' && b <= 'z' || b >= 'A' && b <= 'Z'
+}
+
+// normalizeAll normalizes values and drops empty and duplicate ones
+func normalizeAll(values []string, normalize func(string) string) []string {
+	if len(values) == 0 {
+		return nil
+	}
+
+	seen := make(map[string]bool, len(values))
+	out := values[:0]
+	for _, value := range values {
+		value = normalize(value)
+		if value == "" || seen[value] {
+			continue
+		}
+		seen[value] = true
+		out = append(out, value)
+	}
+	if len(out) == 0 {
+		return nil
+	}
+	return out
+}
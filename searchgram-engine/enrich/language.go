@@ -0,0 +1,213 @@
+package enrich
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/zhishengyuan/searchgram-engine/models"
+)
+
+// minLanguageUnits is the least evidence (CJK characters or words) needed
+// before a language is guessed; shorter messages stay undetermined
+const minLanguageUnits = 2
+
+// Language fills the lang keyword field with an ISO 639-1 code. The script
+// decides most languages outright; Latin-script text is told apart by common
+// function words and distinctive letters. Messages too short or ambiguous to
+// tell are left without a language. Values the client supplied are kept
+// (normalized).
+type Language struct{}
+
+// Name identifies the enricher
+func (Language) Name() string {
+	return "language"
+}
+
+// Enrich sets Lang
+func (Language) Enrich(message *models.Message) error {
+	if message.Lang != "" {
+		message.Lang = models.NormalizeLanguage(message.Lang)
+		return nil
+	}
+
+	parts := []string{message.Text, derefString(message.Caption), message.PollQuestion, message.Transcript}
+	message.Lang = DetectLanguage(strings.Join(parts, "\n"))
+	return nil
+}
+
+// scriptLanguages maps non-Latin scripts to their most common language
+var scriptLanguages = []struct {
+	script string
+	table  *unicode.RangeTable
+	lang   string
+}{
+	{"hangul", unicode.Hangul, "ko"},
+	{"cyrillic", unicode.Cyrillic, "ru"},
+	{"arabic", unicode.Arabic, "ar"},
+	{"hebrew", unicode.Hebrew, "he"},
+	{"greek", unicode.Greek, "el"},
+	{"thai", unicode.Thai, "th"},
+	{"devanagari", unicode.Devanagari, "hi"},
+}
+
+// DetectLanguage guesses the language of text, returning "" when unsure.
+// Han and Kana characters count one unit each, other scripts one per word,
+// so a Chinese sentence with a few English terms is still Chinese.
+func DetectLanguage(text string) string {
+	counts := make(map[string]int)
+	var latinWords []string
+	var word []rune
+	wordScript := ""
+
+	flush := func() {
+		if len(word) > 0 {
+			counts[wordScript]++
+			if wordScript == "latin" {
+				latinWords = append(latinWords, strings.ToLower(string(word)))
+			}
+		}
+		word = word[:0]
+		wordScript = ""
+	}
+
+	for _, r := range text {
+		switch {
+		case unicode.In(r, unicode.Hiragana, unicode.Katakana):
+			flush()
+			counts["kana"]++
+		case unicode.Is(unicode.Han, r):
+			flush()
+			counts["han"]++
+		case unicode.IsLetter(r) || (r == '\'' && len(word) > 0):
+			script := letterScript(r)
+			if wordScript != "" && script != wordScript && r != '\'' {
+				flush()
+			}
+			if wordScript == "" {
+				wordScript = script
+			}
+			word = append(word, r)
+		case unicode.IsMark(r) && len(word) > 0:
+			word = append(word, r)
+		default:
+			flush()
+		}
+	}
+	flush()
+
+	// Kana only occurs in Japanese; Japanese text also uses plenty of Han
+	cjk := counts["han"] + counts["kana"]
+	best, bestCount := "", 0
+	for script, count := range counts {
+		if script == "han" || script == "kana" {
+			continue
+		}
+		if count > bestCount || (count == bestCount && script < best) {
+			best, bestCount = script, count
+		}
+	}
+	if cjk >= bestCount && cjk >= minLanguageUnits {
+		if counts["kana"] > 0 && counts["kana"]*10 >= cjk {
+			return "ja"
+		}
+		return "zh"
+	}
+
+	switch best {
+	case "latin":
+		// Function words are evidence enough, even in a single word
+		return latinLanguage(latinWords)
+	case "cyrillic":
+		if bestCount >= minLanguageUnits && strings.ContainsAny(text, "іїєґІЇЄҐ") {
+			return "uk"
+		}
+	case "arabic":
+		if bestCount >= minLanguageUnits && strings.ContainsAny(text, "پچژگی") {
+			return "fa"
+		}
+	}
+	if bestCount < minLanguageUnits {
+		return ""
+	}
+	for _, script := range scriptLanguages {
+		if script.script == best {
+			return script.lang
+		}
+	}
+	return ""
+}
+
+// letterScript names the script of a letter
+func letterScript(r rune) string {
+	if unicode.Is(unicode.Latin, r) {
+		return "latin"
+	}
+	for _, script := range scriptLanguages {
+		if unicode.Is(script.table, r) {
+			return script.script
+		}
+	}
+	return "other"
+}
+
+// latinFunctionWords holds frequent, short words per Latin-script language
+var latinFunctionWords = map[string][]string{
+	"en": {"the", "and", "is", "are", "was", "you", "that", "this", "with", "for", "have", "not", "but", "what", "of", "to", "in", "my", "your", "will", "can", "just", "it", "be", "we", "they", "i'm", "don't", "it's"},
+	"es": {"el", "la", "los", "las", "que", "y", "es", "en", "un", "una", "por", "para", "con", "no", "se", "lo", "como", "pero", "está", "muy", "más", "yo", "del", "al"},
+	"fr": {"le", "la", "les", "des", "est", "et", "une", "un", "du", "je", "il", "que", "pas", "pour", "dans", "avec", "ce", "c'est", "sur", "mais", "vous", "nous", "très", "oui"},
+	"de": {"der", "die", "das", "und", "ist", "nicht", "ich", "du", "ein", "eine", "zu", "mit", "auf", "für", "es", "sie", "wir", "den", "dem", "auch", "aber", "wie", "sind", "hat", "noch"},
+	"pt": {"o", "os", "as", "que", "e", "é", "não", "um", "uma", "com", "para", "por", "do", "da", "dos", "das", "em", "no", "na", "você", "mas", "muito", "está", "eu"},
+	"it": {"il", "lo", "gli", "che", "di", "e", "è", "non", "un", "una", "per", "con", "del", "della", "sono", "ma", "anche", "come", "questo", "ho", "ci", "mi", "sei", "molto"},
+	"nl": {"de", "het", "een", "en", "is", "van", "niet", "dat", "ik", "je", "op", "te", "met", "voor", "zijn", "maar", "ook", "wat", "hij", "er", "dit", "wel", "nog"},
+	"id": {"yang", "dan", "di", "ini", "itu", "dengan", "untuk", "tidak", "ada", "saya", "aku", "kamu", "akan", "dari", "ke", "juga", "sudah", "bisa", "apa", "tapi", "kita"},
+	"tr": {"bir", "ve", "bu", "da", "de", "için", "ne", "çok", "ben", "sen", "var", "yok", "ile", "gibi", "ama", "mi", "değil", "daha", "olarak", "şey"},
+	"vi": {"là", "và", "của", "có", "không", "được", "cho", "này", "một", "những", "các", "với", "người", "tôi", "bạn", "đã", "thì", "cũng", "rất"},
+	"pl": {"i", "w", "nie", "się", "na", "to", "jest", "z", "że", "do", "jak", "ale", "co", "tak", "po", "mnie", "czy", "już", "ten", "był"},
+}
+
+// latinLetters holds letters that point to one Latin-script language
+var latinLetters = map[string]string{
+	"es": "ñ¿¡",
+	"de": "ßä",
+	"pt": "ãõ",
+	"fr": "œùû",
+	"tr": "ığş",
+	"pl": "łąęśźżń",
+	"vi": "ươđăạảấầẩẫậắằẳẵặẹẻẽếềểễệỉịọỏốồổỗộớờởỡợụủứừửữựỳỵỷỹ",
+}
+
+// latinLanguage scores lowercased words against each language's function
+// words and distinctive letters and returns the clear winner, if any
+func latinLanguage(words []string) string {
+	scores := make(map[string]int)
+	for lang, list := range latinFunctionWords {
+		for _, w := range words {
+			for _, fw := range list {
+				if w == fw {
+					scores[lang]++
+					break
+				}
+			}
+		}
+	}
+	joined := strings.Join(words, " ")
+	for lang, letters := range latinLetters {
+		if strings.ContainsAny(joined, letters) {
+			scores[lang] += 2
+		}
+	}
+
+	best, bestScore, runnerUp := "", 0, 0
+	for lang, score := range scores {
+		switch {
+		case score > bestScore:
+			best, bestScore, runnerUp = lang, score, bestScore
+		case score > runnerUp:
+			runnerUp = score
+		}
+	}
+	if bestScore == 0 || bestScore == runnerUp {
+		return ""
+	}
+	return best
+}
@@ -0,0 +1,142 @@
+package enrich
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/zhishengyuan/searchgram-engine/models"
+)
+
+// mediaContentTypes are the content types that imply a media attachment
+var mediaContentTypes = map[string]bool{
+	"photo":     true,
+	"video":     true,
+	"document":  true,
+	"audio":     true,
+	"voice":     true,
+	"animation": true,
+	"sticker":   true,
+}
+
+// MediaInfo normalizes media metadata. Clients that predate media_type only
+// send content_type, so the media type is derived from it.
+type MediaInfo struct{}
+
+// Name identifies the enricher
+func (MediaInfo) Name() string {
+	return "media_info"
+}
+
+// Enrich fills MediaType from ContentType when missing and lowercases
+// MediaType and MimeType so the keyword filters match
+func (MediaInfo) Enrich(message *models.Message) error {
+	message.MediaType = strings.ToLower(message.MediaType)
+	if message.MediaType == "" && mediaContentTypes[message.ContentType] {
+		message.MediaType = message.ContentType
+	}
+	message.MimeType = strings.ToLower(strings.TrimSpace(message.MimeType))
+	return nil
+}
+
+// maxMediaRedirects bounds the redirects followed when downloading media
+const maxMediaRedirects = 5
+
+// mediaFetcher downloads media files over HTTPS from an allowlist of hosts.
+// media_url comes from clients, so without the allowlist the engine could be
+// made to request internal addresses.
+type mediaFetcher struct {
+	hosts  []string // Lowercased host names; "*.example.com" matches subdomains
+	client *http.Client
+}
+
+// newMediaFetcher creates a fetcher for the given host names
+func newMediaFetcher(hosts []string) *mediaFetcher {
+	f := &mediaFetcher{}
+	for _, host := range hosts {
+		f.hosts = append(f.hosts, strings.ToLower(strings.TrimSpace(host)))
+	}
+	f.client = &http.Client{
+		// Redirects may not leave the host that was allowed
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= maxMediaRedirects {
+				return fmt.Errorf("stopped after %d redirects", maxMediaRedirects)
+			}
+			if req.URL.Scheme != "https" || !strings.EqualFold(req.URL.Host, via[0].URL.Host) {
+				return fmt.Errorf("refusing redirect to %s://%s", req.URL.Scheme, req.URL.Host)
+			}
+			return nil
+		},
+	}
+	return f
+}
+
+// allowed reports whether media may be downloaded from u
+func (f *mediaFetcher) allowed(u *url.URL) bool {
+	if u.Scheme != "https" || u.User != nil {
+		return false
+	}
+	host := strings.ToLower(u.Hostname())
+	for _, allowed := range f.hosts {
+		if host == allowed {
+			return true
+		}
+		if suffix, ok := strings.CutPrefix(allowed, "*"); ok && strings.HasSuffix(host, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// fetch downloads a message's media file for enrichment, refusing files
+// over maxSize bytes
+func (f *mediaFetcher) fetch(ctx context.Context, mediaURL string, maxSize int64) ([]byte, error) {
+	u, err := url.Parse(mediaURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid media_url: %w", err)
+	}
+	if !f.allowed(u) {
+		return nil, fmt.Errorf("media_url host %q is not an allowed https media host", u.Hostname())
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("invalid media_url: %w", err)
+	}
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download media: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to download media: HTTP %d", resp.StatusCode)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to download media: %w", err)
+	}
+	if int64(len(data)) > maxSize {
+		return nil, fmt.Errorf("media exceeds %d bytes", maxSize)
+	}
+	return data, nil
+}
+
+// mediaFileName names an uploaded media file; backends use the extension to
+// detect the format
+func mediaFileName(message *models.Message, fallback string) string {
+	if message.FileName != "" {
+		return message.FileName
+	}
+	if u, err := url.Parse(message.MediaURL); err == nil {
+		if ext := path.Ext(u.Path); ext != "" {
+			return "media" + ext
+		}
+	}
+	return fallback
+}
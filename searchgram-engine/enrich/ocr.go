@@ -0,0 +1,178 @@
+package enrich
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/zhishengyuan/searchgram-engine/models"
+)
+
+// OCRConfig holds OCR backend settings
+type OCRConfig struct {
+	URL         string            // Endpoint receiving the image as multipart form data
+	APIKey      string            // Optional bearer token
+	FileField   string            // Form field carrying the image
+	Fields      map[string]string // Extra form fields sent with every image
+	TextField   string            // Dotted path of the extracted text in the JSON response
+	MediaTypes  []string          // Media types to read text from
+	MaxFileSize int64             // Larger images are skipped
+	MediaHosts  []string          // Hosts images may be downloaded from over HTTPS
+	Timeout     time.Duration     // Deadline for downloading and reading one image
+}
+
+// OCR fills OCRText for images by downloading the file at MediaURL and
+// sending it to an OCR service, e.g. a Tesseract server or a cloud API.
+// Images sent as documents (image/* MIME types) are read as well. Messages
+// that already carry OCR text or have no MediaURL are skipped. Add it to a
+// pipeline with AddMedia.
+type OCR struct {
+	cfg        OCRConfig
+	mediaTypes map[string]bool
+	textPath   []string
+	media      *mediaFetcher
+	client     *http.Client
+}
+
+// NewOCR creates an OCR stage
+func NewOCR(cfg OCRConfig) *OCR {
+	if cfg.FileField == "" {
+		cfg.FileField = "file"
+	}
+	if cfg.TextField == "" {
+		cfg.TextField = "text"
+	}
+	if len(cfg.MediaTypes) == 0 {
+		cfg.MediaTypes = []string{"photo"}
+	}
+	if cfg.MaxFileSize <= 0 {
+		cfg.MaxFileSize = 10 << 20
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 30 * time.Second
+	}
+
+	mediaTypes := make(map[string]bool, len(cfg.MediaTypes))
+	for _, mediaType := range cfg.MediaTypes {
+		mediaTypes[strings.ToLower(mediaType)] = true
+	}
+
+	return &OCR{
+		cfg:        cfg,
+		mediaTypes: mediaTypes,
+		textPath:   strings.Split(cfg.TextField, "."),
+		media:      newMediaFetcher(cfg.MediaHosts),
+		client:     &http.Client{},
+	}
+}
+
+// Name identifies the enricher
+func (o *OCR) Name() string {
+	return "ocr"
+}
+
+// Enrich reads the text in the message's image into OCRText
+func (o *OCR) Enrich(message *models.Message) error {
+	if message.OCRText != "" || message.MediaURL == "" || !o.wants(message) {
+		return nil
+	}
+	if message.FileSize > o.cfg.MaxFileSize {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), o.cfg.Timeout)
+	defer cancel()
+
+	image, err := o.media.fetch(ctx, message.MediaURL, o.cfg.MaxFileSize)
+	if err != nil {
+		return err
+	}
+
+	text, err := o.recognize(ctx, image, mediaFileName(message, "image.jpg"))
+	if err != nil {
+		return err
+	}
+	message.OCRText = strings.TrimSpace(text)
+	return nil
+}
+
+// wants reports whether the message carries an image to read
+func (o *OCR) wants(message *models.Message) bool {
+	if o.mediaTypes[message.MediaType] {
+		return true
+	}
+	return message.MediaType == "document" && strings.HasPrefix(message.MimeType, "image/")
+}
+
+// recognize posts the image as multipart form data and returns the text
+func (o *OCR) recognize(ctx context.Context, image []byte, name string) (string, error) {
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+
+	part, err := form.CreateFormFile(o.cfg.FileField, name)
+	if err != nil {
+		return "", err
+	}
+	if _, err := part.Write(image); err != nil {
+		return "", err
+	}
+
+	// Sorted for reproducible requests
+	keys := make([]string, 0, len(o.cfg.Fields))
+	for key := range o.cfg.Fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if err := form.WriteField(key, o.cfg.Fields[key]); err != nil {
+			return "", err
+		}
+	}
+	if err := form.Close(); err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, o.cfg.URL, &body)
+	if err != nil {
+		return "", fmt.Errorf("invalid ocr url: %w", err)
+	}
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	if o.cfg.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+o.cfg.APIKey)
+	}
+
+	resp, err := o.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("ocr request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return "", fmt.Errorf("ocr failed: HTTP %d: %s", resp.StatusCode, strings.TrimSpace(string(detail)))
+	}
+
+	var result interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", fmt.Errorf("invalid ocr response: %w", err)
+	}
+	for _, key := range o.textPath {
+		object, ok := result.(map[string]interface{})
+		if !ok {
+			return "", fmt.Errorf("ocr response has no %q", o.cfg.TextField)
+		}
+		result = object[key]
+	}
+	text, ok := result.(string)
+	if !ok {
+		return "", fmt.Errorf("ocr response has no %q", o.cfg.TextField)
+	}
+	return text, nil
+}
@@ -0,0 +1,139 @@
+package enrich
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf16"
+
+	"github.com/zhishengyuan/searchgram-engine/models"
+)
+
+// Built-in redaction patterns by name
+var redactionPatterns = map[string]*regexp.Regexp{
+	"email": regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9-]+(?:\.[A-Za-z0-9-]+)*\.[A-Za-z]{2,}`),
+	// International (+country), with area code, Chinese mobile and 7-digit local numbers
+	"phone": regexp.MustCompile(`\+\d{1,3}[ .-]?(?:\(\d{1,4}\)[ .-]?)?\d{2,4}(?:[ .-]?\d{2,4}){1,3}\b` +
+		`|(?:\(\d{2,4}\)[ .-]?|\b\d{2,4}[ .-])\d{3,4}[ .-]?\d{4}\b` +
+		`|\b1[3-9]\d{9}\b` +
+		`|\b\d{3}[ .-]\d{4}\b`),
+	// 13-19 digits, optionally grouped; matches are Luhn-checked
+	"credit_card": regexp.MustCompile(`\b\d(?:[ -]?\d){12,18}\b`),
+}
+
+// RedactionTypes lists the built-in redaction pattern names
+func RedactionTypes() []string {
+	return []string{"email", "phone", "credit_card"}
+}
+
+// redactionRule is one pattern to mask
+type redactionRule struct {
+	pattern *regexp.Regexp
+	luhn    bool // Only mask matches that pass the Luhn checksum
+}
+
+// Redactor masks personal data (emails, phone numbers, card numbers and
+// custom patterns) in message text before anything else sees it, so it is
+// neither indexed nor derived into other fields. Each masked character is
+// replaced by as many mask characters as it has UTF-16 code units, keeping
+// Telegram entity offsets valid.
+type Redactor struct {
+	rules []redactionRule
+	mask  string
+}
+
+// NewRedactor creates a redaction stage from built-in types and custom
+// regular expressions. maskChar must be a single character that is one
+// UTF-16 unit long, such as "*".
+func NewRedactor(types, patterns []string, maskChar string) (*Redactor, error) {
+	if maskChar == "" {
+		maskChar = "*"
+	}
+	for _, t := range types {
+		if _, ok := redactionPatterns[t]; !ok {
+			return nil, fmt.Errorf("unknown redaction type %q", t)
+		}
+	}
+	r := &Redactor{mask: maskChar}
+
+	// Custom patterns first: they are the most specific
+	for _, pattern := range patterns {
+		compiled, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid redaction pattern %q: %w", pattern, err)
+		}
+		r.rules = append(r.rules, redactionRule{pattern: compiled})
+	}
+	// Cards before phones, whose patterns also match grouped card digits
+	for _, name := range []string{"email", "credit_card", "phone"} {
+		for _, t := range types {
+			if t == name {
+				r.rules = append(r.rules, redactionRule{pattern: redactionPatterns[name], luhn: name == "credit_card"})
+			}
+		}
+	}
+	return r, nil
+}
+
+// Name identifies the enricher
+func (r *Redactor) Name() string {
+	return "redaction"
+}
+
+// Enrich masks personal data in text, caption, poll, OCR text and transcript
+func (r *Redactor) Enrich(message *models.Message) error {
+	message.Text = r.Redact(message.Text)
+	if message.Caption != nil {
+		caption := r.Redact(*message.Caption)
+		message.Caption = &caption
+	}
+	message.PollQuestion = r.Redact(message.PollQuestion)
+	for i, option := range message.PollOptions {
+		message.PollOptions[i] = r.Redact(option)
+	}
+	message.OCRText = r.Redact(message.OCRText)
+	message.Transcript = r.Redact(message.Transcript)
+	return nil
+}
+
+// Redact returns text with every match masked
+func (r *Redactor) Redact(text string) string {
+	if text == "" {
+		return text
+	}
+	for _, rule := range r.rules {
+		text = rule.pattern.ReplaceAllStringFunc(text, func(match string) string {
+			if rule.luhn && !luhnValid(match) {
+				return match
+			}
+			return r.maskOf(match)
+		})
+	}
+	return text
+}
+
+// maskOf returns the mask for match, one mask character per UTF-16 unit
+func (r *Redactor) maskOf(match string) string {
+	return strings.Repeat(r.mask, len(utf16.Encode([]rune(match))))
+}
+
+// luhnValid reports whether the digits in s pass the Luhn checksum
+func luhnValid(s string) bool {
+	sum, double := 0, false
+	for i := len(s) - 1; i >= 0; i-- {
+		c := s[i]
+		if c < '0' || c > '9' {
+			continue
+		}
+		digit := int(c - '0')
+		if double {
+			digit *= 2
+			if digit > 9 {
+				digit -= 9
+			}
+		}
+		sum += digit
+		double = !double
+	}
+	return sum%10 == 0
+}
@@ -0,0 +1,109 @@
+package enrich
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/zhishengyuan/searchgram-engine/models"
+)
+
+// RerankerConfig holds cross-encoder reranking backend settings
+type RerankerConfig struct {
+	URL     string        // Cohere/Jina-compatible /v1/rerank endpoint
+	APIKey  string        // Optional bearer token
+	Model   string        // Model name sent with each request
+	Timeout time.Duration // Deadline for one rerank request
+}
+
+// Reranker scores search candidates against the query with a cross-encoder,
+// which reads query and message together and so judges relevance better than
+// the fused keyword and vector ranks alone
+type Reranker struct {
+	cfg    RerankerConfig
+	client *http.Client
+}
+
+// NewReranker creates a reranking client
+func NewReranker(cfg RerankerConfig) *Reranker {
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 10 * time.Second
+	}
+	return &Reranker{
+		cfg:    cfg,
+		client: &http.Client{},
+	}
+}
+
+// Rerank returns one relevance score per message, in message order
+func (r *Reranker) Rerank(ctx context.Context, query string, messages []models.Message) ([]float64, error) {
+	documents := make([]string, len(messages))
+	for i := range messages {
+		documents[i] = EmbeddingText(&messages[i])
+	}
+
+	payload, err := json.Marshal(map[string]interface{}{
+		"model":            r.cfg.Model,
+		"query":            query,
+		"documents":        documents,
+		"top_n":            len(documents),
+		"return_documents": false,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, r.cfg.Timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, r.cfg.URL, bytes.NewReader(payload))
+	if err != nil {
+		return nil, fmt.Errorf("invalid rerank url: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if r.cfg.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+r.cfg.APIKey)
+	}
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("rerank request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, fmt.Errorf("rerank failed: HTTP %d: %s", resp.StatusCode, strings.TrimSpace(string(detail)))
+	}
+
+	var result struct {
+		Results []struct {
+			Index          int     `json:"index"`
+			RelevanceScore float64 `json:"relevance_score"`
+		} `json:"results"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("invalid rerank response: %w", err)
+	}
+
+	scores := make([]float64, len(documents))
+	scored := make([]bool, len(documents))
+	for _, item := range result.Results {
+		if item.Index < 0 || item.Index >= len(documents) {
+			return nil, fmt.Errorf("rerank response has out-of-range index %d", item.Index)
+		}
+		scores[item.Index] = item.RelevanceScore
+		scored[item.Index] = true
+	}
+	for i, ok := range scored {
+		if !ok {
+			return nil, fmt.Errorf("rerank response has no score for document %d", i)
+		}
+	}
+	return scores, nil
+}
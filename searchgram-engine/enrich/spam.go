@@ -0,0 +1,195 @@
+package enrich
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/zhishengyuan/searchgram-engine/models"
+)
+
+// SpamClassifier scores how likely a message is spam, from 0 (clean) to 1
+type SpamClassifier interface {
+	Classify(message *models.Message) (float64, error)
+}
+
+// Spam sets is_spam and spam_score so searches can leave out airdrop
+// promotions, scams and other noise. Each classifier scores the message;
+// the highest score counts and marks the message as spam at threshold.
+// Messages the client already flagged keep their flag.
+type Spam struct {
+	threshold   float64
+	classifiers []SpamClassifier
+}
+
+// NewSpam creates a spam stage
+func NewSpam(threshold float64, classifiers ...SpamClassifier) *Spam {
+	return &Spam{
+		threshold:   threshold,
+		classifiers: classifiers,
+	}
+}
+
+// Name identifies the enricher
+func (s *Spam) Name() string {
+	return "spam"
+}
+
+// Enrich sets SpamScore and IsSpam. A failing classifier is reported but
+// does not discard the scores of the others.
+func (s *Spam) Enrich(message *models.Message) error {
+	if message.IsSpam {
+		return nil
+	}
+
+	var errs []error
+	score := 0.0
+	for _, classifier := range s.classifiers {
+		classifierScore, err := classifier.Classify(message)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		score = max(score, classifierScore)
+	}
+
+	message.SpamScore = min(max(score, 0), 1)
+	message.IsSpam = message.SpamScore >= s.threshold
+	return errors.Join(errs...)
+}
+
+// spamText joins the text a message shows, including text read from media
+func spamText(message *models.Message) string {
+	parts := []string{message.Text, derefString(message.Caption), message.PollQuestion, message.OCRText, message.Transcript}
+	return strings.Join(parts, "\n")
+}
+
+// SpamRule adds Weight to the spam score of messages matching Pattern
+type SpamRule struct {
+	Pattern string
+	Weight  float64
+}
+
+// SpamRules scores messages by regular expressions, e.g. "(?i)airdrop"
+// or wallet addresses. The weights of all matching rules add up.
+type SpamRules struct {
+	patterns []*regexp.Regexp
+	weights  []float64
+}
+
+// NewSpamRules compiles spam rules
+func NewSpamRules(rules []SpamRule) (*SpamRules, error) {
+	s := &SpamRules{}
+	for _, rule := range rules {
+		pattern, err := regexp.Compile(rule.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid spam rule %q: %w", rule.Pattern, err)
+		}
+		s.patterns = append(s.patterns, pattern)
+		s.weights = append(s.weights, rule.Weight)
+	}
+	return s, nil
+}
+
+// Classify sums the weights of the matching rules
+func (s *SpamRules) Classify(message *models.Message) (float64, error) {
+	text := spamText(message)
+	score := 0.0
+	for i, pattern := range s.patterns {
+		if pattern.MatchString(text) {
+			score += s.weights[i]
+		}
+	}
+	return score, nil
+}
+
+// SpamModelConfig holds external spam model settings
+type SpamModelConfig struct {
+	URL        string        // Endpoint receiving {"text": ...} as JSON
+	APIKey     string        // Optional bearer token
+	ScoreField string        // Dotted path of the 0-1 score in the JSON response
+	Timeout    time.Duration // Deadline for scoring one message
+}
+
+// SpamModel scores messages with an external classifier over HTTP, e.g. a
+// fine-tuned text classification model behind a small web service
+type SpamModel struct {
+	cfg       SpamModelConfig
+	scorePath []string
+	client    *http.Client
+}
+
+// NewSpamModel creates an external spam classifier
+func NewSpamModel(cfg SpamModelConfig) *SpamModel {
+	if cfg.ScoreField == "" {
+		cfg.ScoreField = "score"
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 5 * time.Second
+	}
+	return &SpamModel{
+		cfg:       cfg,
+		scorePath: strings.Split(cfg.ScoreField, "."),
+		client:    &http.Client{},
+	}
+}
+
+// Classify posts the message text and returns the model's score
+func (s *SpamModel) Classify(message *models.Message) (float64, error) {
+	text := strings.TrimSpace(spamText(message))
+	if text == "" {
+		return 0, nil
+	}
+
+	payload, err := json.Marshal(map[string]string{"text": text})
+	if err != nil {
+		return 0, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), s.cfg.Timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.cfg.URL, bytes.NewReader(payload))
+	if err != nil {
+		return 0, fmt.Errorf("invalid spam model url: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if s.cfg.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+s.cfg.APIKey)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("spam model request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return 0, fmt.Errorf("spam model failed: HTTP %d: %s", resp.StatusCode, strings.TrimSpace(string(detail)))
+	}
+
+	var result interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return 0, fmt.Errorf("invalid spam model response: %w", err)
+	}
+	for _, key := range s.scorePath {
+		object, ok := result.(map[string]interface{})
+		if !ok {
+			return 0, fmt.Errorf("spam model response has no %q", s.cfg.ScoreField)
+		}
+		result = object[key]
+	}
+	score, ok := result.(float64)
+	if !ok {
+		return 0, fmt.Errorf("spam model response has no %q", s.cfg.ScoreField)
+	}
+	return score, nil
+}
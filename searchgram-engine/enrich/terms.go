@@ -0,0 +1,45 @@
+package enrich
+
+import (
+	"strings"
+
+	"github.com/zhishengyuan/searchgram-engine/models"
+)
+
+// TermMatcher finds the dictionary terms a text contains
+type TermMatcher interface {
+	Match(text string) []string
+}
+
+// Terms tags messages with the dictionary terms they contain, so domain
+// terms can be matched as a whole rather than as bigrams
+type Terms struct {
+	matcher TermMatcher
+}
+
+// NewTerms creates a term tagger using matcher
+func NewTerms(matcher TermMatcher) *Terms {
+	return &Terms{matcher: matcher}
+}
+
+// Name identifies the enricher
+func (t *Terms) Name() string {
+	return "terms"
+}
+
+// Enrich sets Terms from the searchable text of the message
+func (t *Terms) Enrich(message *models.Message) error {
+	parts := []string{
+		message.Text,
+		derefString(message.Caption),
+		message.FileName,
+		message.PollQuestion,
+		message.Transcript,
+		message.OCRText,
+	}
+	parts = append(parts, message.PollOptions...)
+
+	// Separate the parts so no term spans two of them
+	message.Terms = t.matcher.Match(strings.Join(parts, "\n"))
+	return nil
+}
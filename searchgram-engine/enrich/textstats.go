@@ -0,0 +1,67 @@
+package enrich
+
+import (
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/zhishengyuan/searchgram-engine/models"
+)
+
+// TextStats computes text length and a CJK-aware word count
+type TextStats struct{}
+
+// Name identifies the enricher
+func (TextStats) Name() string {
+	return "text_stats"
+}
+
+// Enrich sets TextLength and WordCount from text and caption
+func (TextStats) Enrich(message *models.Message) error {
+	length, words := 0, 0
+
+	for _, content := range []string{message.Text, derefString(message.Caption)} {
+		length += utf8.RuneCountInString(content)
+		words += CountWords(content)
+	}
+
+	message.TextLength = length
+	message.WordCount = words
+	return nil
+}
+
+// CountWords counts words in mixed-script text. Each CJK character counts as
+// one word (there are no spaces to split on); runs of other letters and
+// digits count as one word each.
+func CountWords(text string) int {
+	count := 0
+	inWord := false
+
+	for _, r := range text {
+		switch {
+		case isCJK(r):
+			count++
+			inWord = false
+		case unicode.IsLetter(r) || unicode.IsDigit(r) || (r == '\'' && inWord):
+			if !inWord {
+				count++
+				inWord = true
+			}
+		default:
+			inWord = false
+		}
+	}
+
+	return count
+}
+
+// isCJK reports whether r is a Han, Kana or Hangul character
+func isCJK(r rune) bool {
+	return unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul)
+}
+
+func derefString(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
@@ -0,0 +1,48 @@
+package enrich
+
+import (
+	"time"
+
+	"github.com/zhishengyuan/searchgram-engine/models"
+)
+
+// TimeBuckets derives hour-of-day and weekday from the message timestamp
+// in a fixed timezone, so activity heatmaps are plain terms aggregations
+type TimeBuckets struct {
+	location *time.Location
+}
+
+// NewTimeBuckets creates a TimeBuckets enricher for the given timezone (nil means UTC)
+func NewTimeBuckets(location *time.Location) *TimeBuckets {
+	if location == nil {
+		location = time.UTC
+	}
+	return &TimeBuckets{location: location}
+}
+
+// Name identifies the enricher
+func (t *TimeBuckets) Name() string {
+	return "time_buckets"
+}
+
+// Enrich sets HourOfDay (0-23) and Weekday (ISO 8601, Monday=1 .. Sunday=7)
+func (t *TimeBuckets) Enrich(message *models.Message) error {
+	timestamp := message.Timestamp
+	if timestamp == 0 {
+		timestamp = message.Date
+	}
+	if timestamp == 0 {
+		return nil
+	}
+
+	local := time.Unix(timestamp, 0).In(t.location)
+	message.HourOfDay = local.Hour()
+
+	weekday := int(local.Weekday())
+	if weekday == 0 {
+		weekday = 7
+	}
+	message.Weekday = weekday
+
+	return nil
+}
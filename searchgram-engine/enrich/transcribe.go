@@ -0,0 +1,164 @@
+package enrich
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/zhishengyuan/searchgram-engine/models"
+)
+
+// TranscriberConfig holds transcription backend settings
+type TranscriberConfig struct {
+	URL         string        // OpenAI-compatible /v1/audio/transcriptions endpoint
+	APIKey      string        // Optional bearer token
+	Model       string        // Model name sent with each request
+	Language    string        // Optional ISO-639-1 hint (empty = auto-detect)
+	MediaTypes  []string      // Media types to transcribe
+	MaxFileSize int64         // Larger files are skipped
+	MediaHosts  []string      // Hosts files may be downloaded from over HTTPS
+	Timeout     time.Duration // Deadline for downloading and transcribing one message
+}
+
+// Transcriber fills Transcript for voice and video notes by downloading the
+// file at MediaURL and sending it to a Whisper-style HTTP backend.
+// Messages that already carry a transcript or have no MediaURL are skipped.
+// Add it to a pipeline with AddMedia.
+type Transcriber struct {
+	cfg        TranscriberConfig
+	mediaTypes map[string]bool
+	media      *mediaFetcher
+	client     *http.Client
+}
+
+// NewTranscriber creates a transcription stage
+func NewTranscriber(cfg TranscriberConfig) *Transcriber {
+	if cfg.Model == "" {
+		cfg.Model = "whisper-1"
+	}
+	if len(cfg.MediaTypes) == 0 {
+		cfg.MediaTypes = []string{"voice", "video_note"}
+	}
+	if cfg.MaxFileSize <= 0 {
+		cfg.MaxFileSize = 25 << 20
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = time.Minute
+	}
+
+	mediaTypes := make(map[string]bool, len(cfg.MediaTypes))
+	for _, mediaType := range cfg.MediaTypes {
+		mediaTypes[strings.ToLower(mediaType)] = true
+	}
+
+	return &Transcriber{
+		cfg:        cfg,
+		mediaTypes: mediaTypes,
+		media:      newMediaFetcher(cfg.MediaHosts),
+		client:     &http.Client{},
+	}
+}
+
+// Name identifies the enricher
+func (t *Transcriber) Name() string {
+	return "transcription"
+}
+
+// Enrich transcribes the message's audio into Transcript
+func (t *Transcriber) Enrich(message *models.Message) error {
+	if message.Transcript != "" || message.MediaURL == "" || !t.mediaTypes[message.MediaType] {
+		return nil
+	}
+	if message.FileSize > t.cfg.MaxFileSize {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), t.cfg.Timeout)
+	defer cancel()
+
+	audio, err := t.media.fetch(ctx, message.MediaURL, t.cfg.MaxFileSize)
+	if err != nil {
+		return err
+	}
+
+	transcript, err := t.transcribe(ctx, audio, audioFileName(message))
+	if err != nil {
+		return err
+	}
+	message.Transcript = strings.TrimSpace(transcript)
+	return nil
+}
+
+// transcribe posts the audio as multipart form data and returns the text
+func (t *Transcriber) transcribe(ctx context.Context, audio []byte, name string) (string, error) {
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+
+	part, err := form.CreateFormFile("file", name)
+	if err != nil {
+		return "", err
+	}
+	if _, err := part.Write(audio); err != nil {
+		return "", err
+	}
+	if err := form.WriteField("model", t.cfg.Model); err != nil {
+		return "", err
+	}
+	if t.cfg.Language != "" {
+		if err := form.WriteField("language", t.cfg.Language); err != nil {
+			return "", err
+		}
+	}
+	if err := form.WriteField("response_format", "json"); err != nil {
+		return "", err
+	}
+	if err := form.Close(); err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.cfg.URL, &body)
+	if err != nil {
+		return "", fmt.Errorf("invalid transcription url: %w", err)
+	}
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	if t.cfg.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+t.cfg.APIKey)
+	}
+
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("transcription request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return "", fmt.Errorf("transcription failed: HTTP %d: %s", resp.StatusCode, strings.TrimSpace(string(detail)))
+	}
+
+	var result struct {
+		Text string `json:"text"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", fmt.Errorf("invalid transcription response: %w", err)
+	}
+	return result.Text, nil
+}
+
+// audioFileName names the uploaded audio; Telegram voice notes are Opus in
+// an Ogg container
+func audioFileName(message *models.Message) string {
+	switch message.MimeType {
+	case "audio/mpeg":
+		return mediaFileName(message, "audio.mp3")
+	case "video/mp4":
+		return mediaFileName(message, "audio.mp4")
+	}
+	return mediaFileName(message, "audio.ogg")
+}
@@ -0,0 +1,164 @@
+package enrich
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/zhishengyuan/searchgram-engine/models"
+)
+
+// TranslatorConfig holds machine translation backend settings
+type TranslatorConfig struct {
+	URL       string        // LibreTranslate-compatible /translate endpoint
+	APIKey    string        // Optional api_key sent with each request
+	Targets   []string      // Languages queries are translated into
+	Timeout   time.Duration // Deadline for translating one query into all targets
+	CacheSize int           // Translations kept in memory (0 disables caching)
+}
+
+// Translator translates search keywords so a query in one language also
+// finds messages written in another. Recent translations are cached since
+// the same keywords are searched over and over.
+type Translator struct {
+	cfg    TranslatorConfig
+	client *http.Client
+
+	mu    sync.Mutex
+	cache map[string]string // target + "\x00" + text -> translation
+}
+
+// NewTranslator creates a query translator
+func NewTranslator(cfg TranslatorConfig) *Translator {
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 5 * time.Second
+	}
+	targets := make([]string, len(cfg.Targets))
+	for i, target := range cfg.Targets {
+		targets[i] = models.NormalizeLanguage(target)
+	}
+	cfg.Targets = targets
+	return &Translator{
+		cfg:    cfg,
+		client: &http.Client{},
+		cache:  make(map[string]string),
+	}
+}
+
+// TranslateQuery returns the keyword translated into every target language
+// other than its own, without duplicates or unchanged copies. Failed
+// translations are logged and left out, so search degrades to the original
+// keyword.
+func (t *Translator) TranslateQuery(ctx context.Context, keyword string) []string {
+	keyword = strings.TrimSpace(keyword)
+	if keyword == "" {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, t.cfg.Timeout)
+	defer cancel()
+
+	source := DetectLanguage(keyword)
+	seen := map[string]bool{strings.ToLower(keyword): true}
+	var translations []string
+	for _, target := range t.cfg.Targets {
+		if target == source {
+			continue
+		}
+
+		translation, err := t.Translate(ctx, keyword, target)
+		if err != nil {
+			log.WithError(err).WithField("target", target).Warn("Failed to translate search query")
+			continue
+		}
+
+		key := strings.ToLower(translation)
+		if translation == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		translations = append(translations, translation)
+	}
+	return translations
+}
+
+// Translate returns text translated into target, detecting the source language
+func (t *Translator) Translate(ctx context.Context, text, target string) (string, error) {
+	cacheKey := target + "\x00" + text
+	if translation, ok := t.cached(cacheKey); ok {
+		return translation, nil
+	}
+
+	body := map[string]interface{}{
+		"q":      text,
+		"source": "auto",
+		"target": target,
+		"format": "text",
+	}
+	if t.cfg.APIKey != "" {
+		body["api_key"] = t.cfg.APIKey
+	}
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.cfg.URL, bytes.NewReader(payload))
+	if err != nil {
+		return "", fmt.Errorf("invalid translation url: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("translation request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return "", fmt.Errorf("translation failed: HTTP %d: %s", resp.StatusCode, strings.TrimSpace(string(detail)))
+	}
+
+	var result struct {
+		TranslatedText string `json:"translatedText"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", fmt.Errorf("invalid translation response: %w", err)
+	}
+
+	translation := strings.TrimSpace(result.TranslatedText)
+	t.store(cacheKey, translation)
+	return translation, nil
+}
+
+// cached looks up a translation
+func (t *Translator) cached(key string) (string, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	translation, ok := t.cache[key]
+	return translation, ok
+}
+
+// store caches a translation, starting over once the cache is full
+func (t *Translator) store(key, translation string) {
+	if t.cfg.CacheSize <= 0 {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if len(t.cache) >= t.cfg.CacheSize {
+		t.cache = make(map[string]string)
+	}
+	t.cache[key] = translation
+}
@@ -0,0 +1,216 @@
+package enrich
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/zhishengyuan/searchgram-engine/models"
+)
+
+// Retries of messages not found in the index yet, e.g. still waiting in the
+// write-behind queue
+const (
+	mediaUpdateAttempts   = 5
+	mediaUpdateRetryDelay = 5 * time.Second
+)
+
+// MessageStore is where a MediaWorker writes back enriched messages
+type MessageStore interface {
+	UpdateMessage(ctx context.Context, id string, fn func(message *models.Message)) (*models.Message, error)
+}
+
+// MediaWorkerConfig holds media enrichment worker settings
+type MediaWorkerConfig struct {
+	Workers   int // Messages enriched in parallel
+	QueueSize int // Messages waiting for enrichment; more are dropped
+}
+
+// mediaJob is an indexed message waiting for its media to be read
+type mediaJob struct {
+	store   MessageStore
+	message models.Message
+	attempt int
+	read    bool // Media enrichers have run on message
+}
+
+// MediaWorker runs the pipeline's media enrichers in the background, so
+// ingestion requests don't wait for downloads and media backends. The
+// stored message is updated with the text read and enriched again, so
+// language, terms, spam and embeddings take it into account. Messages
+// queued when the engine stops are indexed without their media text.
+type MediaWorker struct {
+	pipeline *Pipeline
+	cfg      MediaWorkerConfig
+	jobs     chan mediaJob
+	stop     chan struct{}
+	wg       sync.WaitGroup
+
+	mu       sync.Mutex
+	enriched int64
+	failed   int64
+	dropped  int64
+}
+
+// NewMediaWorker creates a worker for the pipeline's media enrichers; call
+// Start to begin enriching
+func NewMediaWorker(pipeline *Pipeline, cfg MediaWorkerConfig) *MediaWorker {
+	if cfg.Workers <= 0 {
+		cfg.Workers = 2
+	}
+	if cfg.QueueSize <= 0 {
+		cfg.QueueSize = 1000
+	}
+	return &MediaWorker{
+		pipeline: pipeline,
+		cfg:      cfg,
+		jobs:     make(chan mediaJob, cfg.QueueSize),
+		stop:     make(chan struct{}),
+	}
+}
+
+// Start begins enriching queued messages
+func (w *MediaWorker) Start() {
+	for i := 0; i < w.cfg.Workers; i++ {
+		w.wg.Add(1)
+		go func() {
+			defer w.wg.Done()
+			for {
+				select {
+				case job := <-w.jobs:
+					w.process(job)
+				case <-w.stop:
+					return
+				}
+			}
+		}()
+	}
+
+	log.WithFields(log.Fields{
+		"workers":    w.cfg.Workers,
+		"queue_size": w.cfg.QueueSize,
+	}).Info("Media enrichment worker started")
+}
+
+// Stop waits for the messages being enriched, up to ctx's deadline; queued
+// ones are left as indexed
+func (w *MediaWorker) Stop(ctx context.Context) {
+	if w == nil {
+		return
+	}
+	close(w.stop)
+
+	done := make(chan struct{})
+	go func() {
+		w.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		log.Warn("Media enrichment still running at shutdown")
+	}
+	if pending := len(w.jobs); pending > 0 {
+		log.WithField("pending", pending).Warn("Messages left without media enrichment")
+	}
+}
+
+// Enqueue schedules reading the media of an indexed message, which is
+// updated in store. Messages without a media URL are ignored, and messages
+// over the queue's capacity are dropped.
+func (w *MediaWorker) Enqueue(store MessageStore, message models.Message) {
+	if w == nil || message.MediaURL == "" || len(w.pipeline.media) == 0 {
+		return
+	}
+	w.enqueue(mediaJob{store: store, message: message})
+}
+
+func (w *MediaWorker) enqueue(job mediaJob) {
+	select {
+	case w.jobs <- job:
+	default:
+		w.count(&w.dropped)
+		log.WithField("id", job.message.ID).Warn("Media enrichment queue is full, message left without media text")
+	}
+}
+
+// Stats returns the worker's counters
+func (w *MediaWorker) Stats() *models.MediaEnrichmentStats {
+	if w == nil {
+		return nil
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return &models.MediaEnrichmentStats{
+		Pending:  len(w.jobs),
+		Capacity: w.cfg.QueueSize,
+		Enriched: w.enriched,
+		Failed:   w.failed,
+		Dropped:  w.dropped,
+	}
+}
+
+func (w *MediaWorker) count(counter *int64) {
+	w.mu.Lock()
+	*counter++
+	w.mu.Unlock()
+}
+
+// process reads the media of a message and writes the text into the stored
+// message, retrying while the message is not indexed yet
+func (w *MediaWorker) process(job mediaJob) {
+	if !job.read {
+		before := job.message
+		for _, enricher := range w.pipeline.media {
+			if err := enricher.Enrich(&job.message); err != nil {
+				w.count(&w.failed)
+				log.WithError(err).WithFields(log.Fields{
+					"enricher": enricher.Name(),
+					"id":       job.message.ID,
+				}).Warn("Message enrichment failed")
+			}
+		}
+		if job.message.Transcript == before.Transcript && job.message.OCRText == before.OCRText {
+			return
+		}
+		job.read = true
+	}
+
+	read := job.message
+	updated, err := job.store.UpdateMessage(context.Background(), read.ID, func(message *models.Message) {
+		// The media was replaced by an edit in the meantime
+		if message.MediaURL != read.MediaURL {
+			return
+		}
+		message.Transcript = read.Transcript
+		message.OCRText = read.OCRText
+		message.Embedding = nil // Re-embedded with the media text
+		message.Lang = ""       // Re-detected with the media text
+		message.IsSpam = false  // Re-classified with the media text
+		message.SpamScore = 0
+		w.pipeline.Process(message)
+	})
+	if err != nil {
+		w.count(&w.failed)
+		log.WithError(err).WithField("id", read.ID).Warn("Failed to store media enrichment")
+		return
+	}
+	if updated == nil {
+		job.attempt++
+		if job.attempt >= mediaUpdateAttempts {
+			log.WithField("id", read.ID).Debug("Message gone before its media text was stored")
+			return
+		}
+		time.AfterFunc(mediaUpdateRetryDelay, func() {
+			select {
+			case <-w.stop:
+			default:
+				w.enqueue(job)
+			}
+		})
+		return
+	}
+	w.count(&w.enriched)
+}
@@ -0,0 +1,246 @@
+package events
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/zhishengyuan/searchgram-engine/engines"
+	"github.com/zhishengyuan/searchgram-engine/models"
+)
+
+// Engine emits an event for every successful write of the engine it wraps,
+// so every path that writes (API, ingest queue, consumers, backfills,
+// retention, scheduled tasks) is covered. Reads pass through.
+type Engine struct {
+	engines.SearchEngine
+	bus *Bus
+}
+
+// Upsert indexes a message
+func (e *Engine) Upsert(ctx context.Context, message *models.Message) error {
+	if err := e.SearchEngine.Upsert(ctx, message); err != nil {
+		return err
+	}
+	e.bus.Emit(messageEvent(models.EventIndexed, message))
+	return nil
+}
+
+// UpsertBatch indexes messages; those the engine refused emit nothing
+func (e *Engine) UpsertBatch(ctx context.Context, messages []models.Message) (int, []string, error) {
+	indexed, failures, err := e.SearchEngine.UpsertBatch(ctx, messages)
+	if err != nil {
+		return indexed, failures, err
+	}
+	for i := range messages {
+		if !mentions(failures, messages[i].ID) {
+			e.bus.Emit(messageEvent(models.EventIndexed, &messages[i]))
+		}
+	}
+	return indexed, failures, nil
+}
+
+// mentions reports whether any failure names the document
+// ("Document <id> failed ...")
+func mentions(failures []string, id string) bool {
+	for _, failure := range failures {
+		if strings.Contains(failure, "Document "+id+" ") {
+			return true
+		}
+	}
+	return false
+}
+
+// UpdateMessage edits a message
+func (e *Engine) UpdateMessage(ctx context.Context, id string, fn func(message *models.Message)) (*models.Message, error) {
+	message, err := e.SearchEngine.UpdateMessage(ctx, id, fn)
+	if err == nil && message != nil {
+		e.bus.Emit(messageEvent(models.EventUpdated, message))
+	}
+	return message, err
+}
+
+// Delete soft-deletes a chat's messages
+func (e *Engine) Delete(ctx context.Context, chatID int64) (int64, error) {
+	count, err := e.SearchEngine.Delete(ctx, chatID)
+	if err == nil && count > 0 {
+		e.bus.Emit(models.LifecycleEvent{
+			Type:      models.EventDeleted,
+			Operation: "chat",
+			ChatID:    &chatID,
+			Count:     count,
+		})
+	}
+	return count, err
+}
+
+// DeleteMessage removes a single message
+func (e *Engine) DeleteMessage(ctx context.Context, id string) (bool, error) {
+	deleted, err := e.SearchEngine.DeleteMessage(ctx, id)
+	if err == nil && deleted {
+		event := models.LifecycleEvent{
+			Type:      models.EventDeleted,
+			Operation: "message",
+			MessageID: id,
+			Count:     1,
+		}
+		if chatID, _, err := models.ParseMessageID(id); err == nil {
+			event.ChatID = &chatID
+		}
+		e.bus.Emit(event)
+	}
+	return deleted, err
+}
+
+// DeleteByQuery removes matching messages; dry runs emit nothing. Purges
+// of the retention manager are reported as such.
+func (e *Engine) DeleteByQuery(req *models.SearchRequest, dryRun bool) (int64, error) {
+	count, err := e.SearchEngine.DeleteByQuery(req, dryRun)
+	if err == nil && !dryRun && count > 0 {
+		query := *req
+		event := models.LifecycleEvent{
+			Type:      models.EventDeleted,
+			Operation: "query",
+			ChatID:    query.ChatID,
+			Count:     count,
+			Query:     &query,
+		}
+		if req.Cause == models.DeleteCauseRetention {
+			event.Type = models.EventRetentionPruned
+			event.Operation = ""
+		}
+		e.bus.Emit(event)
+	}
+	return count, err
+}
+
+// SoftDeleteByQuery marks matching messages as deleted
+func (e *Engine) SoftDeleteByQuery(req *models.SearchRequest) (int64, error) {
+	count, err := e.SearchEngine.SoftDeleteByQuery(req)
+	if err == nil && count > 0 {
+		query := *req
+		e.bus.Emit(models.LifecycleEvent{
+			Type:      models.EventDeleted,
+			Operation: "soft_delete",
+			ChatID:    query.ChatID,
+			Count:     count,
+			Query:     &query,
+		})
+	}
+	return count, err
+}
+
+// PurgeDeleted removes soft-deleted messages; dry runs emit nothing
+func (e *Engine) PurgeDeleted(filter *models.DeletedFilter, dryRun bool) (int64, error) {
+	count, err := e.SearchEngine.PurgeDeleted(filter, dryRun)
+	if err == nil && !dryRun && count > 0 {
+		query := filter.SearchRequest
+		e.bus.Emit(models.LifecycleEvent{
+			Type:      models.EventDeleted,
+			Operation: "purge",
+			ChatID:    query.ChatID,
+			Count:     count,
+			Query:     &query,
+		})
+	}
+	return count, err
+}
+
+// DeleteUser removes a user's messages
+func (e *Engine) DeleteUser(ctx context.Context, userID int64) (int64, error) {
+	count, err := e.SearchEngine.DeleteUser(ctx, userID)
+	if err == nil && count > 0 {
+		e.bus.Emit(models.LifecycleEvent{
+			Type:      models.EventDeleted,
+			Operation: "user",
+			UserID:    &userID,
+			Count:     count,
+		})
+	}
+	return count, err
+}
+
+// MoveToTrash moves messages to the recycle bin
+func (e *Engine) MoveToTrash(ctx context.Context, selector models.TrashSelector, operation, target string, ttl time.Duration) (*models.TrashBatch, error) {
+	batch, err := e.SearchEngine.MoveToTrash(ctx, selector, operation, target, ttl)
+	if err == nil && batch != nil && batch.Count > 0 {
+		event := models.LifecycleEvent{
+			Type:      models.EventDeleted,
+			Operation: "trash",
+			MessageID: selector.MessageID,
+			ChatID:    selector.ChatID,
+			UserID:    selector.UserID,
+			Count:     batch.Count,
+		}
+		if selector.Query != nil {
+			query := *selector.Query
+			event.Query = &query
+		}
+		e.bus.Emit(event)
+	}
+	return batch, err
+}
+
+// Clear removes all documents
+func (e *Engine) Clear(ctx context.Context) error {
+	err := e.SearchEngine.Clear(ctx)
+	if err == nil {
+		e.bus.Emit(models.LifecycleEvent{
+			Type:      models.EventDeleted,
+			Operation: "clear",
+		})
+	}
+	return err
+}
+
+// Dedup removes duplicate messages; dry runs emit nothing
+func (e *Engine) Dedup(ctx context.Context, dryRun bool, progress func(*models.DedupResponse)) (*models.DedupResponse, error) {
+	result, err := e.SearchEngine.Dedup(ctx, dryRun, progress)
+	if err == nil && !dryRun && result != nil && result.DuplicatesRemoved > 0 {
+		e.bus.Emit(models.LifecycleEvent{
+			Type:  models.EventDedupRemoved,
+			Count: result.DuplicatesRemoved,
+		})
+	}
+	return result, err
+}
+
+// SoftDeleteMessage marks a message as deleted
+func (e *Engine) SoftDeleteMessage(ctx context.Context, chatID int64, messageID int64) error {
+	if err := e.SearchEngine.SoftDeleteMessage(ctx, chatID, messageID); err != nil {
+		return err
+	}
+	e.bus.Emit(models.LifecycleEvent{
+		Type:      models.EventDeleted,
+		Operation: "soft_delete",
+		MessageID: models.MessageDocumentID(chatID, messageID),
+		ChatID:    &chatID,
+		Count:     1,
+	})
+	return nil
+}
+
+// CleanCommands removes bot command messages
+func (e *Engine) CleanCommands(ctx context.Context) (*models.CleanCommandsResponse, error) {
+	result, err := e.SearchEngine.CleanCommands(ctx)
+	if err == nil && result != nil && result.DeletedCount > 0 {
+		e.bus.Emit(models.LifecycleEvent{
+			Type:      models.EventDeleted,
+			Operation: "commands",
+			Count:     result.DeletedCount,
+		})
+	}
+	return result, err
+}
+
+// messageEvent reports a write of a single message
+func messageEvent(eventType string, message *models.Message) models.LifecycleEvent {
+	chatID := message.ChatID
+	copied := *message
+	return models.LifecycleEvent{
+		Type:      eventType,
+		MessageID: message.ID,
+		ChatID:    &chatID,
+		Message:   &copied,
+	}
+}
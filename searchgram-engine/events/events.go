@@ -0,0 +1,433 @@
+package events
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	log "github.com/sirupsen/logrus"
+	"github.com/zhishengyuan/searchgram-engine/engines"
+	"github.com/zhishengyuan/searchgram-engine/models"
+)
+
+// deliveryTimeout bounds one delivery to the sink
+const deliveryTimeout = 30 * time.Second
+
+// Config holds event delivery configuration
+type Config struct {
+	Types          []string      // Event types to emit (empty = all)
+	QueueSize      int           // Maximum buffered events; more go to the dead-letter log
+	BatchSize      int           // Maximum events per delivery
+	MaxAttempts    int           // Deliveries of an event before it is dead-lettered
+	MaxBackoff     time.Duration // Longest pause between retries of a failing delivery
+	DeadLetterPath string        // JSON Lines file of undeliverable events
+}
+
+// pending is a queued event and how often its delivery failed
+type pending struct {
+	event    models.LifecycleEvent
+	attempts int
+}
+
+// Bus delivers lifecycle events to a sink in the background, in the order
+// they were emitted. Failing deliveries are retried with backoff; an event
+// is delivered at least once unless it fails MaxAttempts times, overflows
+// the queue or is still queued at shutdown, in which case it is appended
+// to the dead-letter log, from where it can be redelivered. A nil Bus
+// emits nothing.
+type Bus struct {
+	sink  Sink
+	cfg   Config
+	types map[string]bool
+
+	mu              sync.Mutex
+	queue           []pending
+	emitted         int64
+	delivered       int64
+	retries         int64
+	deadLettered    int64
+	deadLetters     int
+	lastDeliveredAt time.Time
+	lastError       string
+
+	deadMu sync.Mutex // Serializes access to the dead-letter log
+
+	wake chan struct{}
+	stop chan struct{}
+	done chan struct{}
+}
+
+// New creates a bus delivering to sink, counting the events already in the
+// dead-letter log
+func New(sink Sink, cfg Config) (*Bus, error) {
+	if cfg.QueueSize <= 0 {
+		cfg.QueueSize = 10000
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 100
+	}
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = 10
+	}
+	if cfg.MaxBackoff <= 0 {
+		cfg.MaxBackoff = time.Minute
+	}
+
+	b := &Bus{
+		sink: sink,
+		cfg:  cfg,
+		wake: make(chan struct{}, 1),
+	}
+	if len(cfg.Types) > 0 {
+		b.types = make(map[string]bool, len(cfg.Types))
+		for _, t := range cfg.Types {
+			b.types[t] = true
+		}
+	}
+
+	letters, err := b.readDeadLetters()
+	if err != nil {
+		return nil, err
+	}
+	b.deadLetters = len(letters)
+	return b, nil
+}
+
+// Wrap returns engine with its successful writes emitted as events
+func (b *Bus) Wrap(engine engines.SearchEngine) engines.SearchEngine {
+	return &Engine{SearchEngine: engine, bus: b}
+}
+
+// Start begins delivering emitted events
+func (b *Bus) Start() {
+	b.stop = make(chan struct{})
+	b.done = make(chan struct{})
+
+	go b.run()
+
+	log.WithFields(log.Fields{
+		"sink":         b.sink.Type(),
+		"queue_size":   b.cfg.QueueSize,
+		"dead_letters": b.Stats().DeadLetters,
+	}).Info("Lifecycle events enabled")
+}
+
+// Stop stops the background worker and delivers what is still queued until
+// ctx expires. Events left over are dead-lettered.
+func (b *Bus) Stop(ctx context.Context) {
+	if b == nil || b.stop == nil {
+		return
+	}
+	close(b.stop)
+	<-b.done
+
+	for ctx.Err() == nil {
+		delivered, err := b.deliverNext(ctx)
+		if err != nil || delivered == 0 {
+			break
+		}
+	}
+
+	b.mu.Lock()
+	left := b.queue
+	b.queue = nil
+	b.mu.Unlock()
+	if len(left) > 0 {
+		log.WithField("pending", len(left)).Warn("Lifecycle events stopped with undelivered events, dead-lettering them")
+		b.deadLetter(left, errors.New("not delivered before shutdown"))
+	}
+
+	if err := b.sink.Close(); err != nil {
+		log.WithError(err).Warn("Failed to close event sink")
+	}
+}
+
+// Stats returns delivery counters
+func (b *Bus) Stats() *models.EventStats {
+	if b == nil {
+		return nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	stats := &models.EventStats{
+		Sink:         b.sink.Type(),
+		Pending:      len(b.queue),
+		Capacity:     b.cfg.QueueSize,
+		Emitted:      b.emitted,
+		Delivered:    b.delivered,
+		Retries:      b.retries,
+		DeadLettered: b.deadLettered,
+		DeadLetters:  b.deadLetters,
+		LastError:    b.lastError,
+	}
+	if !b.lastDeliveredAt.IsZero() {
+		stats.LastDeliveredAt = b.lastDeliveredAt.Unix()
+	}
+	return stats
+}
+
+// Emit queues an event for delivery, stamping its ID and time
+func (b *Bus) Emit(event models.LifecycleEvent) {
+	if b == nil || (b.types != nil && !b.types[event.Type]) {
+		return
+	}
+	event.ID = uuid.NewString()
+	event.Time = time.Now().Unix()
+
+	b.mu.Lock()
+	b.emitted++
+	if len(b.queue) >= b.cfg.QueueSize {
+		b.mu.Unlock()
+		b.deadLetter([]pending{{event: event}}, errors.New("the event queue is full"))
+		return
+	}
+	b.queue = append(b.queue, pending{event: event})
+	b.mu.Unlock()
+
+	b.signal()
+}
+
+// signal wakes the worker
+func (b *Bus) signal() {
+	select {
+	case b.wake <- struct{}{}:
+	default:
+	}
+}
+
+// run delivers queued events, retrying failures with exponential backoff
+func (b *Bus) run() {
+	defer close(b.done)
+
+	backoff := time.Second
+	for {
+		delivered, err := b.deliverNext(context.Background())
+		if err != nil {
+			log.WithError(err).WithField("retry_in", backoff.String()).Warn("Lifecycle event delivery failed")
+
+			select {
+			case <-time.After(backoff):
+			case <-b.stop:
+				return
+			}
+			backoff = min(backoff*2, b.cfg.MaxBackoff)
+			continue
+		}
+		backoff = time.Second
+
+		// Stop leaves the rest to be delivered within its deadline
+		select {
+		case <-b.stop:
+			return
+		default:
+		}
+		if delivered == 0 {
+			select {
+			case <-b.wake:
+			case <-b.stop:
+				return
+			}
+		}
+	}
+}
+
+// deliverNext delivers the head of the queue as one batch and returns how
+// many events it delivered. On failure, events out of attempts are
+// dead-lettered and the rest stay queued.
+func (b *Bus) deliverNext(ctx context.Context) (int, error) {
+	b.mu.Lock()
+	n := min(len(b.queue), b.cfg.BatchSize)
+	batch := append([]pending(nil), b.queue[:n]...)
+	b.mu.Unlock()
+	if n == 0 {
+		return 0, nil
+	}
+
+	events := make([]models.LifecycleEvent, n)
+	for i, p := range batch {
+		events[i] = p.event
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, deliveryTimeout)
+	err := b.sink.Deliver(ctx, events)
+	cancel()
+
+	// Only the worker or Stop removes from the head, so the batch is
+	// still there
+	b.mu.Lock()
+	if err == nil {
+		b.queue = b.queue[n:]
+		b.delivered += int64(n)
+		b.lastDeliveredAt = time.Now()
+		b.lastError = ""
+	} else {
+		b.retries++
+		b.lastError = err.Error()
+		var expired []pending
+		kept := make([]pending, 0, len(b.queue))
+		for i, p := range b.queue {
+			if i < n {
+				p.attempts++
+				if p.attempts >= b.cfg.MaxAttempts {
+					expired = append(expired, p)
+					continue
+				}
+			}
+			kept = append(kept, p)
+		}
+		b.queue = kept
+		b.mu.Unlock()
+
+		if len(expired) > 0 {
+			b.deadLetter(expired, err)
+		}
+		return 0, fmt.Errorf("%s sink: %w", b.sink.Type(), err)
+	}
+	if len(b.queue) == 0 {
+		b.queue = nil
+	}
+	b.mu.Unlock()
+
+	return n, nil
+}
+
+// deadLetter appends undeliverable events to the dead-letter log
+func (b *Bus) deadLetter(events []pending, cause error) {
+	b.deadMu.Lock()
+	defer b.deadMu.Unlock()
+
+	written := 0
+	err := func() error {
+		if err := os.MkdirAll(filepath.Dir(b.cfg.DeadLetterPath), 0o755); err != nil {
+			return err
+		}
+		f, err := os.OpenFile(b.cfg.DeadLetterPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		now := time.Now().Unix()
+		enc := json.NewEncoder(f)
+		for _, p := range events {
+			letter := models.DeadLetter{
+				Event:    p.event,
+				Error:    cause.Error(),
+				Attempts: p.attempts,
+				FailedAt: now,
+			}
+			if err := enc.Encode(letter); err != nil {
+				return err
+			}
+			written++
+		}
+		return nil
+	}()
+
+	b.mu.Lock()
+	b.deadLettered += int64(written)
+	b.deadLetters += written
+	b.mu.Unlock()
+
+	if err != nil {
+		log.WithError(err).WithField("lost", len(events)-written).Error("Failed to write lifecycle events to the dead-letter log")
+		return
+	}
+	log.WithFields(log.Fields{
+		"events": written,
+		"cause":  cause.Error(),
+	}).Warn("Lifecycle events dead-lettered")
+}
+
+// Redeliver moves the events of the dead-letter log back into the queue, as
+// many as fit, and returns how many it queued
+func (b *Bus) Redeliver() (int, error) {
+	b.deadMu.Lock()
+	defer b.deadMu.Unlock()
+
+	letters, err := b.readDeadLetters()
+	if err != nil {
+		return 0, err
+	}
+
+	b.mu.Lock()
+	n := min(len(letters), max(b.cfg.QueueSize-len(b.queue), 0))
+	for _, letter := range letters[:n] {
+		b.queue = append(b.queue, pending{event: letter.Event})
+	}
+	b.mu.Unlock()
+
+	if err := b.writeDeadLetters(letters[n:]); err != nil {
+		// The queued events stay in the log too and may be delivered twice
+		return n, err
+	}
+
+	b.mu.Lock()
+	b.deadLetters = len(letters) - n
+	b.mu.Unlock()
+	b.signal()
+
+	log.WithFields(log.Fields{
+		"queued": n,
+		"left":   len(letters) - n,
+	}).Info("Dead-lettered lifecycle events queued for redelivery")
+	return n, nil
+}
+
+// readDeadLetters reads the dead-letter log; lines that do not parse, e.g.
+// one cut short by a crash, are skipped
+func (b *Bus) readDeadLetters() ([]models.DeadLetter, error) {
+	f, err := os.Open(b.cfg.DeadLetterPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open dead-letter log: %w", err)
+	}
+	defer f.Close()
+
+	var letters []models.DeadLetter
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	for scanner.Scan() {
+		var letter models.DeadLetter
+		if err := json.Unmarshal(scanner.Bytes(), &letter); err != nil || letter.Event.ID == "" {
+			continue
+		}
+		letters = append(letters, letter)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read dead-letter log: %w", err)
+	}
+	return letters, nil
+}
+
+// writeDeadLetters replaces the dead-letter log with letters
+func (b *Bus) writeDeadLetters(letters []models.DeadLetter) error {
+	tmp := b.cfg.DeadLetterPath + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(f)
+	for _, letter := range letters {
+		if err := enc.Encode(letter); err != nil {
+			f.Close()
+			os.Remove(tmp)
+			return err
+		}
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, b.cfg.DeadLetterPath)
+}
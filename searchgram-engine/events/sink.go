@@ -0,0 +1,198 @@
+package events
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+	"github.com/segmentio/kafka-go"
+	"github.com/zhishengyuan/searchgram-engine/export"
+	"github.com/zhishengyuan/searchgram-engine/models"
+)
+
+// Sink types
+const (
+	SinkWebhook = "webhook"
+	SinkNATS    = "nats"
+	SinkKafka   = "kafka"
+)
+
+// Sinks lists the supported sinks
+func Sinks() []string {
+	return []string{SinkWebhook, SinkNATS, SinkKafka}
+}
+
+// Sink receives lifecycle events
+type Sink interface {
+	Type() string
+	// Deliver hands over events in order; an error means none or only some
+	// arrived, and all of them are delivered again
+	Deliver(ctx context.Context, events []models.LifecycleEvent) error
+	Close() error
+}
+
+// Webhook POSTs each batch of events to a URL as a JSON array
+type Webhook struct {
+	URL     string
+	Secret  string // Signs the body in export.SignatureHeader (empty = unsigned)
+	Timeout time.Duration
+}
+
+// Type implements Sink
+func (w *Webhook) Type() string {
+	return SinkWebhook
+}
+
+// Deliver implements Sink; any status other than 2xx fails the delivery
+func (w *Webhook) Deliver(ctx context.Context, events []models.LifecycleEvent) error {
+	body, err := json.Marshal(events)
+	if err != nil {
+		return err
+	}
+
+	if w.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, w.Timeout)
+		defer cancel()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if w.Secret != "" {
+		mac := hmac.New(sha256.New, []byte(w.Secret))
+		mac.Write(body)
+		req.Header.Set(export.SignatureHeader, "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// Close implements Sink
+func (w *Webhook) Close() error {
+	return nil
+}
+
+// NATS publishes each event to a JetStream subject and waits for the
+// stream's acknowledgement. The event ID is sent as Nats-Msg-Id, so the
+// stream drops redeliveries within its duplicate window.
+type NATS struct {
+	conn    *nats.Conn
+	js      jetstream.JetStream
+	subject string
+}
+
+// NewNATS connects to NATS; a stream must capture subject
+func NewNATS(url, subject string) (*NATS, error) {
+	conn, err := nats.Connect(url,
+		nats.Name("searchgram-engine-events"),
+		nats.MaxReconnects(-1),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to NATS: %w", err)
+	}
+	js, err := jetstream.New(conn)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to create JetStream context: %w", err)
+	}
+	return &NATS{conn: conn, js: js, subject: subject}, nil
+}
+
+// Type implements Sink
+func (n *NATS) Type() string {
+	return SinkNATS
+}
+
+// Deliver implements Sink
+func (n *NATS) Deliver(ctx context.Context, events []models.LifecycleEvent) error {
+	for _, event := range events {
+		data, err := json.Marshal(event)
+		if err != nil {
+			return err
+		}
+		msg := nats.NewMsg(n.subject)
+		msg.Data = data
+		msg.Header.Set(jetstream.MsgIDHeader, event.ID)
+		msg.Header.Set("SearchGram-Event", event.Type)
+		if _, err := n.js.PublishMsg(ctx, msg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close implements Sink
+func (n *NATS) Close() error {
+	return n.conn.Drain()
+}
+
+// Kafka writes events to a topic, keyed by chat so a chat's events keep
+// their order, and waits for all in-sync replicas to acknowledge them
+type Kafka struct {
+	writer *kafka.Writer
+}
+
+// NewKafka creates a Kafka sink; connections are made on first delivery
+func NewKafka(brokers []string, topic string) *Kafka {
+	return &Kafka{writer: &kafka.Writer{
+		Addr:         kafka.TCP(brokers...),
+		Topic:        topic,
+		Balancer:     &kafka.Hash{},
+		RequiredAcks: kafka.RequireAll,
+	}}
+}
+
+// Type implements Sink
+func (k *Kafka) Type() string {
+	return SinkKafka
+}
+
+// Deliver implements Sink
+func (k *Kafka) Deliver(ctx context.Context, events []models.LifecycleEvent) error {
+	messages := make([]kafka.Message, 0, len(events))
+	for _, event := range events {
+		data, err := json.Marshal(event)
+		if err != nil {
+			return err
+		}
+		var key []byte
+		if event.ChatID != nil {
+			key = []byte(strconv.FormatInt(*event.ChatID, 10))
+		}
+		messages = append(messages, kafka.Message{
+			Key:   key,
+			Value: data,
+			Headers: []kafka.Header{
+				{Key: "searchgram-event", Value: []byte(event.Type)},
+				{Key: "searchgram-event-id", Value: []byte(event.ID)},
+			},
+		})
+	}
+	return k.writer.WriteMessages(ctx, messages...)
+}
+
+// Close implements Sink
+func (k *Kafka) Close() error {
+	return k.writer.Close()
+}
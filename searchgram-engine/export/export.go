@@ -0,0 +1,132 @@
+package export
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/zhishengyuan/searchgram-engine/engines"
+	"github.com/zhishengyuan/searchgram-engine/models"
+)
+
+// Options selects the messages of an export
+type Options struct {
+	Name    string  // Task name, used in file names and object keys
+	ChatIDs []int64 // Chats to export (empty = all)
+	Days    int     // Only messages sent within the last Days days (0 = all)
+	TempDir string  // Where the export is assembled (empty = os.TempDir)
+}
+
+// Run exports the selected messages as gzip-compressed NDJSON, one message
+// per line, and hands the file to target. Soft-deleted messages are left
+// out. Messages are read through a single point-in-time scan, so the export
+// is consistent even while writes continue.
+func Run(ctx context.Context, engine engines.SearchEngine, opts Options, target Target) (*models.ExportResult, error) {
+	started := time.Now()
+	result := &models.ExportResult{
+		Name:      opts.Name,
+		Target:    target.Type(),
+		File:      fileName(opts.Name, started),
+		CreatedAt: started.Unix(),
+	}
+	if opts.Days > 0 {
+		result.Since = started.AddDate(0, 0, -opts.Days).Unix()
+	}
+
+	f, err := os.CreateTemp(opts.TempDir, "export-*.ndjson.gz")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create export file: %w", err)
+	}
+	path := f.Name()
+	defer os.Remove(path)
+
+	if err := write(ctx, engine, f, opts, result); err != nil {
+		return nil, err
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	result.Bytes = info.Size()
+
+	location, err := target.Deliver(ctx, result.File, path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to deliver export to %s: %w", target.Type(), err)
+	}
+	result.Location = location
+	result.DurationMs = time.Since(started).Milliseconds()
+
+	log.WithFields(log.Fields{
+		"name":     opts.Name,
+		"target":   target.Type(),
+		"location": location,
+		"messages": result.Messages,
+	}).Info("Export delivered")
+	return result, nil
+}
+
+// write scans the selected messages into f and closes it
+func write(ctx context.Context, engine engines.SearchEngine, f *os.File, opts Options, result *models.ExportResult) error {
+	chats := make(map[int64]bool, len(opts.ChatIDs))
+	for _, chatID := range opts.ChatIDs {
+		chats[chatID] = true
+	}
+
+	gz := gzip.NewWriter(f)
+	buffered := bufio.NewWriter(gz)
+	encoder := json.NewEncoder(buffered)
+	seen := make(map[int64]bool)
+
+	err := engine.ScanMessages(ctx, func(messages []models.Message) error {
+		for i := range messages {
+			message := &messages[i]
+			if message.IsDeleted {
+				continue
+			}
+			if len(chats) > 0 && !chats[message.ChatID] {
+				continue
+			}
+			if result.Since > 0 && messageTime(message) < result.Since {
+				continue
+			}
+			if err := encoder.Encode(message); err != nil {
+				return fmt.Errorf("failed to write export: %w", err)
+			}
+			result.Messages++
+			seen[message.ChatID] = true
+		}
+		return nil
+	})
+	if err == nil {
+		err = buffered.Flush()
+	}
+	if err == nil {
+		err = gz.Close()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	result.Chats = len(seen)
+	return err
+}
+
+// fileName returns the name of an export: <name>-<time>.ndjson.gz
+func fileName(name string, t time.Time) string {
+	return fmt.Sprintf("%s-%s.ndjson.gz", filepath.Base(name), t.UTC().Format("20060102-150405"))
+}
+
+// messageTime returns when a message was sent; legacy documents only carry
+// date
+func messageTime(message *models.Message) int64 {
+	if message.Timestamp != 0 {
+		return message.Timestamp
+	}
+	return message.Date
+}
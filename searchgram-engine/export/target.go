@@ -0,0 +1,174 @@
+package export
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/zhishengyuan/searchgram-engine/s3"
+)
+
+// Targets an export can be delivered to
+const (
+	TargetDir     = "dir"
+	TargetS3      = "s3"
+	TargetWebhook = "webhook"
+)
+
+// Targets lists the supported delivery targets
+func Targets() []string {
+	return []string{TargetDir, TargetS3, TargetWebhook}
+}
+
+// SignatureHeader carries the hex HMAC-SHA256 of a webhook body, keyed with
+// the configured secret
+const SignatureHeader = "X-SearchGram-Signature"
+
+// webhookTimeout bounds one webhook delivery
+const webhookTimeout = 5 * time.Minute
+
+// Target delivers a finished export file
+type Target interface {
+	Type() string
+	// Deliver hands over the file at path as name and returns where it went
+	Deliver(ctx context.Context, name, path string) (string, error)
+}
+
+// Dir copies exports into a local directory
+type Dir struct {
+	Path string
+}
+
+// Type implements Target
+func (d Dir) Type() string {
+	return TargetDir
+}
+
+// Deliver implements Target. The file is written next to its final name
+// and renamed, so readers of the directory never see a partial export.
+func (d Dir) Deliver(ctx context.Context, name, path string) (string, error) {
+	if err := os.MkdirAll(d.Path, 0o755); err != nil {
+		return "", err
+	}
+	dst := filepath.Join(d.Path, name)
+	partial := dst + ".partial"
+	if err := copyFile(path, partial); err != nil {
+		os.Remove(partial)
+		return "", err
+	}
+	if err := os.Rename(partial, dst); err != nil {
+		os.Remove(partial)
+		return "", err
+	}
+	return dst, nil
+}
+
+// Bucket uploads exports to an S3-compatible bucket below Prefix
+type Bucket struct {
+	Client *s3.Client
+	Prefix string
+}
+
+// Type implements Target
+func (b Bucket) Type() string {
+	return TargetS3
+}
+
+// Deliver implements Target
+func (b Bucket) Deliver(ctx context.Context, name, path string) (string, error) {
+	key := name
+	if b.Prefix != "" {
+		key = b.Prefix + "/" + name
+	}
+	if err := b.Client.PutFile(ctx, key, path); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("s3://%s/%s", b.Client.Bucket(), key), nil
+}
+
+// Webhook POSTs exports to a URL as gzip-encoded NDJSON
+type Webhook struct {
+	URL    string
+	Secret string // Signs the body in SignatureHeader (empty = unsigned)
+}
+
+// Type implements Target
+func (w Webhook) Type() string {
+	return TargetWebhook
+}
+
+// Deliver implements Target; any status other than 2xx fails the export
+func (w Webhook) Deliver(ctx context.Context, name, path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return "", err
+	}
+	var signature string
+	if w.Secret != "" {
+		mac := hmac.New(sha256.New, []byte(w.Secret))
+		if _, err := io.Copy(mac, f); err != nil {
+			return "", err
+		}
+		signature = hex.EncodeToString(mac.Sum(nil))
+		if _, err := f.Seek(0, io.SeekStart); err != nil {
+			return "", err
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, webhookTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.URL, f)
+	if err != nil {
+		return "", err
+	}
+	req.ContentLength = info.Size()
+	req.Header.Set("Content-Type", "application/x-ndjson")
+	req.Header.Set("Content-Encoding", "gzip")
+	req.Header.Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name))
+	if signature != "" {
+		req.Header.Set(SignatureHeader, "sha256="+signature)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return "", fmt.Errorf("webhook returned status %d", resp.StatusCode)
+	}
+	return w.URL, nil
+}
+
+// copyFile copies src to dst
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
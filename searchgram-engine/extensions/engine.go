@@ -0,0 +1,224 @@
+package extensions
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/zhishengyuan/searchgram-engine/engines"
+	"github.com/zhishengyuan/searchgram-engine/models"
+)
+
+// Engine runs extension hooks around a search engine, so every path that
+// indexes, searches or deletes (API, ingest queue, consumers, backfills)
+// reaches them. Other methods pass through.
+type Engine struct {
+	engines.SearchEngine
+	hooks *Hooks
+}
+
+// Wrap returns engine with hooks applied, or engine itself when no hooks
+// are registered
+func Wrap(engine engines.SearchEngine, hooks *Hooks) engines.SearchEngine {
+	if hooks.Empty() {
+		return engine
+	}
+	return &Engine{SearchEngine: engine, hooks: hooks}
+}
+
+// Upsert indexes a message unless a hook rejects it
+func (e *Engine) Upsert(ctx context.Context, message *models.Message) error {
+	if err := e.hooks.runBeforeIndex(message); err != nil {
+		return err
+	}
+	if err := e.SearchEngine.Upsert(ctx, message); err != nil {
+		return err
+	}
+	e.hooks.runAfterIndex([]models.Message{*message})
+	return nil
+}
+
+// UpsertBatch indexes the messages no hook rejects; rejections are reported
+// like indexing failures
+func (e *Engine) UpsertBatch(ctx context.Context, messages []models.Message) (int, []string, error) {
+	var rejections []string
+	accepted := make([]models.Message, 0, len(messages))
+	for i := range messages {
+		if err := e.hooks.runBeforeIndex(&messages[i]); err != nil {
+			rejections = append(rejections, fmt.Sprintf("Document %s %v", messages[i].ID, err))
+			continue
+		}
+		accepted = append(accepted, messages[i])
+	}
+	if len(accepted) == 0 {
+		return 0, rejections, nil
+	}
+
+	indexed, failures, err := e.SearchEngine.UpsertBatch(ctx, accepted)
+	if err != nil {
+		return indexed, failures, err
+	}
+
+	// Failures name their document ("Document <id> failed ...")
+	if len(failures) > 0 {
+		written := accepted[:0:0]
+		for _, message := range accepted {
+			if !mentions(failures, message.ID) {
+				written = append(written, message)
+			}
+		}
+		accepted = written
+	}
+	e.hooks.runAfterIndex(accepted)
+	return indexed, append(rejections, failures...), nil
+}
+
+// mentions reports whether any failure names the document
+func mentions(failures []string, id string) bool {
+	for _, failure := range failures {
+		if strings.Contains(failure, "Document "+id+" ") {
+			return true
+		}
+	}
+	return false
+}
+
+// UpdateMessage runs the before-index hooks on the updated message. Edits
+// of indexed messages cannot be rejected, so hook errors are only logged.
+func (e *Engine) UpdateMessage(ctx context.Context, id string, fn func(message *models.Message)) (*models.Message, error) {
+	message, err := e.SearchEngine.UpdateMessage(ctx, id, func(message *models.Message) {
+		fn(message)
+		if err := e.hooks.runBeforeIndex(message); err != nil {
+			log.WithError(err).WithField("id", id).Warn("Extension hook failed on updated message")
+		}
+	})
+	if err == nil && message != nil {
+		e.hooks.runAfterIndex([]models.Message{*message})
+	}
+	return message, err
+}
+
+// Search runs the search hooks on successful searches
+func (e *Engine) Search(req *models.SearchRequest) (*models.SearchResponse, error) {
+	start := time.Now()
+	resp, err := e.SearchEngine.Search(req)
+	if err == nil {
+		e.hooks.runSearch(req, resp, time.Since(start))
+	}
+	return resp, err
+}
+
+// Delete removes a chat's messages
+func (e *Engine) Delete(ctx context.Context, chatID int64) (int64, error) {
+	count, err := e.SearchEngine.Delete(ctx, chatID)
+	if err == nil {
+		e.hooks.runDelete(DeleteEvent{Operation: models.TrashOpDeleteChat, ChatID: chatID, Count: count})
+	}
+	return count, err
+}
+
+// DeleteMessage removes a single message
+func (e *Engine) DeleteMessage(ctx context.Context, id string) (bool, error) {
+	deleted, err := e.SearchEngine.DeleteMessage(ctx, id)
+	if err == nil && deleted {
+		e.hooks.runDelete(DeleteEvent{Operation: models.TrashOpDeleteMessage, MessageID: id, Count: 1})
+	}
+	return deleted, err
+}
+
+// DeleteByQuery removes matching messages; dry runs are not reported
+func (e *Engine) DeleteByQuery(req *models.SearchRequest, dryRun bool) (int64, error) {
+	count, err := e.SearchEngine.DeleteByQuery(req, dryRun)
+	if err == nil && !dryRun {
+		e.hooks.runDelete(DeleteEvent{Operation: models.TrashOpDeleteByQuery, Query: req, Count: count})
+	}
+	return count, err
+}
+
+// SoftDeleteByQuery marks matching messages as deleted
+func (e *Engine) SoftDeleteByQuery(req *models.SearchRequest) (int64, error) {
+	count, err := e.SearchEngine.SoftDeleteByQuery(req)
+	if err == nil {
+		e.hooks.runDelete(DeleteEvent{Operation: DeleteOpSoftDelete, Query: req, Count: count})
+	}
+	return count, err
+}
+
+// PurgeDeleted removes soft-deleted messages; dry runs are not reported
+func (e *Engine) PurgeDeleted(filter *models.DeletedFilter, dryRun bool) (int64, error) {
+	count, err := e.SearchEngine.PurgeDeleted(filter, dryRun)
+	if err == nil && !dryRun {
+		e.hooks.runDelete(DeleteEvent{Operation: DeleteOpPurge, Query: &filter.SearchRequest, Count: count})
+	}
+	return count, err
+}
+
+// DeleteUser removes a user's messages
+func (e *Engine) DeleteUser(ctx context.Context, userID int64) (int64, error) {
+	count, err := e.SearchEngine.DeleteUser(ctx, userID)
+	if err == nil {
+		e.hooks.runDelete(DeleteEvent{Operation: models.TrashOpDeleteUser, UserID: userID, Count: count})
+	}
+	return count, err
+}
+
+// MoveToTrash moves messages to the recycle bin
+func (e *Engine) MoveToTrash(ctx context.Context, selector models.TrashSelector, operation, target string, ttl time.Duration) (*models.TrashBatch, error) {
+	batch, err := e.SearchEngine.MoveToTrash(ctx, selector, operation, target, ttl)
+	if err != nil || batch == nil {
+		return batch, err
+	}
+
+	event := DeleteEvent{Operation: operation, Trashed: true, MessageID: selector.MessageID, Query: selector.Query, Count: batch.Count}
+	if selector.ChatID != nil {
+		event.ChatID = *selector.ChatID
+	}
+	if selector.UserID != nil {
+		event.UserID = *selector.UserID
+	}
+	e.hooks.runDelete(event)
+	return batch, nil
+}
+
+// SoftDeleteMessage marks a message as deleted
+func (e *Engine) SoftDeleteMessage(ctx context.Context, chatID int64, messageID int64) error {
+	err := e.SearchEngine.SoftDeleteMessage(ctx, chatID, messageID)
+	if err == nil {
+		e.hooks.runDelete(DeleteEvent{
+			Operation: DeleteOpSoftDelete,
+			ChatID:    chatID,
+			MessageID: models.MessageDocumentID(chatID, messageID),
+			Count:     1,
+		})
+	}
+	return err
+}
+
+// Clear removes all documents
+func (e *Engine) Clear(ctx context.Context) error {
+	err := e.SearchEngine.Clear(ctx)
+	if err == nil {
+		e.hooks.runDelete(DeleteEvent{Operation: DeleteOpClear})
+	}
+	return err
+}
+
+// RestoreSnapshot replaces all documents with those of a snapshot
+func (e *Engine) RestoreSnapshot(ctx context.Context, repository, snapshot string) error {
+	err := e.SearchEngine.RestoreSnapshot(ctx, repository, snapshot)
+	if err == nil {
+		e.hooks.runDelete(DeleteEvent{Operation: DeleteOpClear})
+	}
+	return err
+}
+
+// RecreateIndex drops all documents along with the index
+func (e *Engine) RecreateIndex(ctx context.Context) error {
+	err := e.SearchEngine.RecreateIndex(ctx)
+	if err == nil {
+		e.hooks.runDelete(DeleteEvent{Operation: DeleteOpClear})
+	}
+	return err
+}
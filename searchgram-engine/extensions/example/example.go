@@ -0,0 +1,62 @@
+// Package example is a minimal extension showing the hook API. It counts
+// indexed and deleted messages and logs slow searches. Build the engine
+// with `-tags ext_example` to compile it in.
+package example
+
+import (
+	"sync/atomic"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/zhishengyuan/searchgram-engine/extensions"
+	"github.com/zhishengyuan/searchgram-engine/models"
+)
+
+func init() {
+	extensions.Register(&Example{})
+}
+
+// Example counts ingest activity and reports slow searches
+type Example struct {
+	indexed atomic.Int64
+	deleted atomic.Int64
+}
+
+// Name identifies the extension
+func (e *Example) Name() string {
+	return "example"
+}
+
+// Init registers the hooks. The slow_search_ms setting (default 1000) is
+// the latency from which searches are logged.
+func (e *Example) Init(host *extensions.Host) error {
+	slow := time.Second
+	if ms, ok := host.Settings["slow_search_ms"].(int); ok && ms > 0 {
+		slow = time.Duration(ms) * time.Millisecond
+	}
+
+	host.OnAfterIndex(func(messages []models.Message) {
+		total := e.indexed.Add(int64(len(messages)))
+		host.Log.WithField("indexed_total", total).Debug("Messages indexed")
+	})
+
+	host.OnSearch(func(req *models.SearchRequest, resp *models.SearchResponse, took time.Duration) {
+		if took >= slow {
+			host.Log.WithFields(log.Fields{
+				"keyword": req.Keyword,
+				"hits":    resp.TotalHits,
+				"took_ms": took.Milliseconds(),
+			}).Warn("Slow search")
+		}
+	})
+
+	host.OnDelete(func(event extensions.DeleteEvent) {
+		total := e.deleted.Add(event.Count)
+		host.Log.WithFields(log.Fields{
+			"operation":     event.Operation,
+			"count":         event.Count,
+			"deleted_total": total,
+		}).Info("Messages deleted")
+	})
+	return nil
+}
@@ -0,0 +1,160 @@
+package extensions
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"plugin"
+	"sort"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/zhishengyuan/searchgram-engine/enrich"
+)
+
+// Extension adds behavior to the engine without patching it: custom
+// enrichment, metrics, replication and the like. Extensions are compiled in
+// (calling Register from an init function, usually in a file behind a build
+// tag) or loaded as Go plugins from a directory.
+type Extension interface {
+	// Name identifies the extension in logs and configuration
+	Name() string
+
+	// Init registers the extension's hooks and enrichers. It runs once at
+	// startup, before any request is served.
+	Init(host *Host) error
+}
+
+// Host is what an extension can hook into
+type Host struct {
+	Name     string                 // Name of the extension being initialized
+	Settings map[string]interface{} // The extension's entry in extensions.settings
+	Log      *log.Entry             // Logger tagged with the extension name
+
+	hooks    *Hooks
+	pipeline *enrich.Pipeline
+}
+
+// AddEnricher appends an ingest enrichment stage. Extension stages run
+// after the built-in ones, embeddings included.
+func (h *Host) AddEnricher(enricher enrich.Enricher) {
+	h.pipeline.Add(enricher)
+}
+
+var (
+	registryMu sync.Mutex
+	registry   = map[string]Extension{}
+)
+
+// Register makes a compiled-in extension available. It is meant to be
+// called from init and panics on duplicate names.
+func Register(ext Extension) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	if _, dup := registry[ext.Name()]; dup {
+		panic(fmt.Sprintf("extensions: %s registered twice", ext.Name()))
+	}
+	registry[ext.Name()] = ext
+}
+
+// Config selects and configures extensions
+type Config struct {
+	PluginsDir string                            // Directory of Go plugins (*.so) to load (empty = none)
+	Disabled   []string                          // Extensions not to initialize
+	Settings   map[string]map[string]interface{} // Extension name -> settings passed to it
+}
+
+// Load initializes the registered extensions and the plugins in
+// cfg.PluginsDir in name order, and returns the hooks they registered.
+// Enrichers they add go to pipeline.
+func Load(cfg Config, pipeline *enrich.Pipeline) (*Hooks, error) {
+	exts, err := loadPlugins(cfg.PluginsDir)
+	if err != nil {
+		return nil, err
+	}
+
+	registryMu.Lock()
+	for name, ext := range registry {
+		if _, dup := exts[name]; dup {
+			registryMu.Unlock()
+			return nil, fmt.Errorf("extension %s is both compiled in and a plugin", name)
+		}
+		exts[name] = ext
+	}
+	registryMu.Unlock()
+
+	disabled := make(map[string]bool, len(cfg.Disabled))
+	for _, name := range cfg.Disabled {
+		disabled[name] = true
+	}
+
+	names := make([]string, 0, len(exts))
+	for name := range exts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	hooks := &Hooks{}
+	for _, name := range names {
+		if disabled[name] {
+			log.WithField("extension", name).Info("Extension disabled")
+			continue
+		}
+
+		host := &Host{
+			Name:     name,
+			Settings: cfg.Settings[name],
+			Log:      log.WithField("extension", name),
+			hooks:    hooks,
+			pipeline: pipeline,
+		}
+		if err := exts[name].Init(host); err != nil {
+			return nil, fmt.Errorf("failed to initialize extension %s: %w", name, err)
+		}
+		log.WithField("extension", name).Info("Extension loaded")
+	}
+	return hooks, nil
+}
+
+// loadPlugins opens every *.so in dir. A plugin exports its extension as
+// `var Extension` (any type implementing Extension) or as
+// `func NewExtension() extensions.Extension`.
+func loadPlugins(dir string) (map[string]Extension, error) {
+	exts := make(map[string]Extension)
+	if dir == "" {
+		return exts, nil
+	}
+
+	if _, err := os.Stat(dir); err != nil {
+		return nil, fmt.Errorf("plugins directory: %w", err)
+	}
+	paths, err := filepath.Glob(filepath.Join(dir, "*.so"))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, path := range paths {
+		p, err := plugin.Open(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open plugin %s: %w", path, err)
+		}
+
+		var ext Extension
+		if symbol, err := p.Lookup("Extension"); err == nil {
+			ext, _ = symbol.(Extension)
+		} else if symbol, err := p.Lookup("NewExtension"); err == nil {
+			if constructor, ok := symbol.(func() Extension); ok {
+				ext = constructor()
+			}
+		}
+		if ext == nil {
+			return nil, fmt.Errorf("plugin %s exports neither Extension nor NewExtension", path)
+		}
+		if _, dup := exts[ext.Name()]; dup {
+			return nil, fmt.Errorf("plugin %s: extension %s loaded twice", path, ext.Name())
+		}
+		exts[ext.Name()] = ext
+	}
+	return exts, nil
+}
@@ -0,0 +1,142 @@
+package extensions
+
+import (
+	"fmt"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/zhishengyuan/searchgram-engine/models"
+)
+
+// BeforeIndexHook runs on every message after enrichment, right before it is
+// written to the index, including edits. It may modify the message; a
+// returned error rejects the message (edits are logged and kept).
+type BeforeIndexHook func(message *models.Message) error
+
+// AfterIndexHook runs with the messages that were written to the index
+type AfterIndexHook func(messages []models.Message)
+
+// SearchHook runs after every successful search. It may modify the response.
+type SearchHook func(req *models.SearchRequest, resp *models.SearchResponse, took time.Duration)
+
+// DeleteHook runs after messages were deleted or moved to the recycle bin
+type DeleteHook func(event DeleteEvent)
+
+// Delete operations reported to DeleteHook, in addition to the recycle bin
+// operations in models (delete_chat, delete_user, ...)
+const (
+	DeleteOpSoftDelete = "soft_delete"
+	DeleteOpPurge      = "purge_deleted"
+	DeleteOpClear      = "clear"
+)
+
+// DeleteEvent describes a completed delete. Only the fields matching the
+// operation are set.
+type DeleteEvent struct {
+	Operation string                // models.TrashOp* or DeleteOp*
+	Trashed   bool                  // Moved to the recycle bin instead of removed
+	ChatID    int64                 // delete_chat, soft_delete
+	UserID    int64                 // delete_user
+	MessageID string                // delete_message, soft_delete
+	Query     *models.SearchRequest // delete_by_query, soft_delete by query, purge_deleted
+	Count     int64                 // Messages affected, when known
+}
+
+// Hooks holds the hooks registered by extensions. They are registered during
+// startup only, so running them needs no locking.
+type Hooks struct {
+	beforeIndex []BeforeIndexHook
+	afterIndex  []AfterIndexHook
+	search      []SearchHook
+	delete      []DeleteHook
+}
+
+// OnBeforeIndex registers a hook run before messages are indexed
+func (h *Host) OnBeforeIndex(fn BeforeIndexHook) {
+	name := h.Name
+	h.hooks.beforeIndex = append(h.hooks.beforeIndex, func(message *models.Message) (err error) {
+		guard(name, "before_index", func() { err = fn(message) })
+		if err != nil {
+			return fmt.Errorf("rejected by extension %s: %w", name, err)
+		}
+		return nil
+	})
+}
+
+// OnAfterIndex registers a hook run after messages were indexed
+func (h *Host) OnAfterIndex(fn AfterIndexHook) {
+	name := h.Name
+	h.hooks.afterIndex = append(h.hooks.afterIndex, func(messages []models.Message) {
+		guard(name, "after_index", func() { fn(messages) })
+	})
+}
+
+// OnSearch registers a hook run after searches
+func (h *Host) OnSearch(fn SearchHook) {
+	name := h.Name
+	h.hooks.search = append(h.hooks.search, func(req *models.SearchRequest, resp *models.SearchResponse, took time.Duration) {
+		guard(name, "search", func() { fn(req, resp, took) })
+	})
+}
+
+// OnDelete registers a hook run after deletes
+func (h *Host) OnDelete(fn DeleteHook) {
+	name := h.Name
+	h.hooks.delete = append(h.hooks.delete, func(event DeleteEvent) {
+		guard(name, "delete", func() { fn(event) })
+	})
+}
+
+// Empty reports whether no hooks are registered
+func (h *Hooks) Empty() bool {
+	return h == nil || len(h.beforeIndex)+len(h.afterIndex)+len(h.search)+len(h.delete) == 0
+}
+
+// runBeforeIndex runs the before-index hooks until one rejects the message
+func (h *Hooks) runBeforeIndex(message *models.Message) error {
+	for _, fn := range h.beforeIndex {
+		if err := fn(message); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// runAfterIndex runs the after-index hooks
+func (h *Hooks) runAfterIndex(messages []models.Message) {
+	if len(messages) == 0 {
+		return
+	}
+	for _, fn := range h.afterIndex {
+		fn(messages)
+	}
+}
+
+// runSearch runs the search hooks
+func (h *Hooks) runSearch(req *models.SearchRequest, resp *models.SearchResponse, took time.Duration) {
+	for _, fn := range h.search {
+		fn(req, resp, took)
+	}
+}
+
+// runDelete runs the delete hooks
+func (h *Hooks) runDelete(event DeleteEvent) {
+	for _, fn := range h.delete {
+		fn(event)
+	}
+}
+
+// guard runs a hook, turning a panic into a logged error so a faulty
+// extension cannot take down ingest workers
+func guard(extension, point string, fn func()) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			log.WithFields(log.Fields{
+				"extension": extension,
+				"hook":      point,
+				"error":     recovered,
+			}).Error("Extension hook panicked")
+		}
+	}()
+	fn()
+}
@@ -0,0 +1,8 @@
+//go:build ext_example
+
+package main
+
+// Compile in the example extension with `go build -tags ext_example`.
+// Downstream forks add their own extensions the same way: a file like this
+// one importing the extension package for its Register call.
+import _ "github.com/zhishengyuan/searchgram-engine/extensions/example"
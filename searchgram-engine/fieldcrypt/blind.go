@@ -0,0 +1,90 @@
+package fieldcrypt
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// blindTokenSize is the bytes of HMAC kept per token: enough to make
+// collisions between distinct terms rare
+const blindTokenSize = 12
+
+// isCJK reports whether r is a Han, Kana or Hangul character
+func isCJK(r rune) bool {
+	return unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul)
+}
+
+// normalize lowercases text and turns full-width ASCII into its regular
+// form, as the index analyzers do
+func normalize(text string) string {
+	return strings.ToLower(strings.Map(func(r rune) rune {
+		if r >= '\uFF01' && r <= '\uFF5E' {
+			return r - 0xFEE0
+		}
+		return r
+	}, text))
+}
+
+// terms splits text into the terms of the blind index: words of letters
+// and digits, and the bigrams of CJK runs as the cjk_bigram filter makes
+// them. A lone CJK character is a term of its own.
+func terms(text string) []string {
+	var result []string
+	var word []rune
+	cjk := false
+
+	flush := func() {
+		switch {
+		case len(word) == 0:
+		case !cjk:
+			result = append(result, string(word))
+		case len(word) == 1:
+			result = append(result, string(word))
+		default:
+			for i := 0; i+1 < len(word); i++ {
+				result = append(result, string(word[i:i+2]))
+			}
+		}
+		word = word[:0]
+	}
+
+	for _, r := range normalize(text) {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			flush()
+			continue
+		}
+		if len(word) > 0 && isCJK(r) != cjk {
+			flush()
+		}
+		cjk = isCJK(r)
+		word = append(word, r)
+	}
+	flush()
+	return result
+}
+
+// BlindTokens returns the keyed hashes of the distinct terms of text. The
+// index stores them in place of the text, and a search for a keyword
+// matches the tokens of the keyword: without the key they reveal neither
+// the terms nor which terms a keyword contains, only that messages share
+// them.
+func (s *Sealer) BlindTokens(text string) []string {
+	seen := make(map[string]bool)
+	var tokens []string
+	for _, term := range terms(text) {
+		mac := hmac.New(sha256.New, s.blind)
+		mac.Write([]byte(term))
+		token := base64.RawURLEncoding.EncodeToString(mac.Sum(nil)[:blindTokenSize])
+		if !seen[token] {
+			seen[token] = true
+			tokens = append(tokens, token)
+		}
+	}
+	// Sorted, so the order does not reveal where terms are in the text
+	sort.Strings(tokens)
+	return tokens
+}
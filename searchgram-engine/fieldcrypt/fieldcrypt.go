@@ -0,0 +1,169 @@
+package fieldcrypt
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// keySize is the length of keys and data keys: AES-256
+const keySize = 32
+
+// ErrUnknownKey is returned when opening text sealed with a key that is no
+// longer configured
+var ErrUnknownKey = errors.New("text was encrypted with an unknown key")
+
+// Config holds the keys text is encrypted with
+type Config struct {
+	Key          string            // Base64 key encrypting new text
+	KeyID        string            // Recorded with new text, so the key can be rotated
+	PreviousKeys map[string]string // Key ID -> base64 key of text encrypted before a rotation
+	BlindKey     string            // Base64 key of the blind index (empty = derived from Key)
+}
+
+// envelope is encrypted text as stored: the text is encrypted with a data
+// key of its own, which is in turn encrypted with the configured key
+type envelope struct {
+	KeyID   string `json:"key_id"`
+	DataKey []byte `json:"data_key"` // Nonce and encrypted data key
+	Data    []byte `json:"data"`     // Nonce and encrypted text
+}
+
+// Sealer encrypts text with envelope encryption and derives the blind
+// tokens searches match instead of the text
+type Sealer struct {
+	keyID string
+	keys  map[string]cipher.AEAD // By key ID
+	blind []byte
+}
+
+// ParseKey decodes a base64 key of 32 bytes
+func ParseKey(s string) ([]byte, error) {
+	key, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("key is not valid base64: %w", err)
+	}
+	if len(key) != keySize {
+		return nil, fmt.Errorf("key must be %d bytes, got %d", keySize, len(key))
+	}
+	return key, nil
+}
+
+// New creates a sealer encrypting with cfg.Key
+func New(cfg Config) (*Sealer, error) {
+	if cfg.KeyID == "" {
+		return nil, fmt.Errorf("key_id is required")
+	}
+	s := &Sealer{keyID: cfg.KeyID, keys: make(map[string]cipher.AEAD)}
+
+	key, err := ParseKey(cfg.Key)
+	if err != nil {
+		return nil, err
+	}
+	if s.keys[cfg.KeyID], err = newAEAD(key); err != nil {
+		return nil, err
+	}
+	for id, encoded := range cfg.PreviousKeys {
+		if id == cfg.KeyID {
+			return nil, fmt.Errorf("previous key %s has the id of the current key", id)
+		}
+		previous, err := ParseKey(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("previous key %s: %w", id, err)
+		}
+		if s.keys[id], err = newAEAD(previous); err != nil {
+			return nil, err
+		}
+	}
+
+	if cfg.BlindKey != "" {
+		if s.blind, err = ParseKey(cfg.BlindKey); err != nil {
+			return nil, fmt.Errorf("blind key: %w", err)
+		}
+	} else {
+		mac := hmac.New(sha256.New, key)
+		mac.Write([]byte("searchgram blind index"))
+		s.blind = mac.Sum(nil)
+	}
+	return s, nil
+}
+
+// newAEAD creates AES-GCM with a 32-byte key
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// seal encrypts plaintext with aead under a random nonce, which is prepended
+func seal(aead cipher.AEAD, plaintext, additional []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plaintext)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, plaintext, additional), nil
+}
+
+// open reverses seal
+func open(aead cipher.AEAD, sealed, additional []byte) ([]byte, error) {
+	if len(sealed) < aead.NonceSize() {
+		return nil, fmt.Errorf("encrypted value is truncated")
+	}
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	return aead.Open(nil, nonce, ciphertext, additional)
+}
+
+// Seal encrypts plaintext belonging to the document id. The id is
+// authenticated, so text copied into another document does not open.
+func (s *Sealer) Seal(id string, plaintext []byte) (json.RawMessage, error) {
+	dataKey := make([]byte, keySize)
+	if _, err := rand.Read(dataKey); err != nil {
+		return nil, fmt.Errorf("failed to generate data key: %w", err)
+	}
+	dataAEAD, err := newAEAD(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	data, err := seal(dataAEAD, plaintext, []byte(id))
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt text: %w", err)
+	}
+	wrapped, err := seal(s.keys[s.keyID], dataKey, []byte(s.keyID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt data key: %w", err)
+	}
+	return json.Marshal(envelope{KeyID: s.keyID, DataKey: wrapped, Data: data})
+}
+
+// Open decrypts text that Seal encrypted for the document id
+func (s *Sealer) Open(id string, sealed json.RawMessage) ([]byte, error) {
+	var env envelope
+	if err := json.Unmarshal(sealed, &env); err != nil {
+		return nil, fmt.Errorf("invalid encrypted text: %w", err)
+	}
+	keyAEAD, ok := s.keys[env.KeyID]
+	if !ok {
+		return nil, fmt.Errorf("%w %q", ErrUnknownKey, env.KeyID)
+	}
+	dataKey, err := open(keyAEAD, env.DataKey, []byte(env.KeyID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt data key: %w", err)
+	}
+	dataAEAD, err := newAEAD(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := open(dataAEAD, env.Data, []byte(id))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt text: %w", err)
+	}
+	return plaintext, nil
+}
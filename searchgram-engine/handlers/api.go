@@ -1,11 +1,16 @@
 package handlers
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"os"
 	"runtime"
 	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -15,34 +20,167 @@ import (
 	"github.com/shirou/gopsutil/v3/load"
 	"github.com/shirou/gopsutil/v3/mem"
 	log "github.com/sirupsen/logrus"
+	"github.com/zhishengyuan/searchgram-engine/archive"
+	"github.com/zhishengyuan/searchgram-engine/audit"
+	"github.com/zhishengyuan/searchgram-engine/authguard"
+	"github.com/zhishengyuan/searchgram-engine/backup"
+	"github.com/zhishengyuan/searchgram-engine/capture"
+	"github.com/zhishengyuan/searchgram-engine/degrade"
+	"github.com/zhishengyuan/searchgram-engine/dictionary"
 	"github.com/zhishengyuan/searchgram-engine/engines"
+	"github.com/zhishengyuan/searchgram-engine/enrich"
+	"github.com/zhishengyuan/searchgram-engine/events"
+	"github.com/zhishengyuan/searchgram-engine/i18n"
+	"github.com/zhishengyuan/searchgram-engine/ingest"
+	"github.com/zhishengyuan/searchgram-engine/jobs"
+	"github.com/zhishengyuan/searchgram-engine/jwt"
+	"github.com/zhishengyuan/searchgram-engine/legalhold"
+	"github.com/zhishengyuan/searchgram-engine/middleware"
 	"github.com/zhishengyuan/searchgram-engine/models"
+	"github.com/zhishengyuan/searchgram-engine/profiles"
+	"github.com/zhishengyuan/searchgram-engine/publicstats"
+	"github.com/zhishengyuan/searchgram-engine/recyclebin"
+	"github.com/zhishengyuan/searchgram-engine/replication"
+	"github.com/zhishengyuan/searchgram-engine/retention"
+	"github.com/zhishengyuan/searchgram-engine/saved"
+	"github.com/zhishengyuan/searchgram-engine/scheduler"
+	"github.com/zhishengyuan/searchgram-engine/sessions"
+	"github.com/zhishengyuan/searchgram-engine/signedurl"
+	"github.com/zhishengyuan/searchgram-engine/subscriptions"
+	"github.com/zhishengyuan/searchgram-engine/tenants"
+	"github.com/zhishengyuan/searchgram-engine/usage"
 )
 
 // APIHandler handles all API endpoints
 type APIHandler struct {
 	engine    engines.SearchEngine
+	jobs      *jobs.Manager
+	pipeline  *enrich.Pipeline
 	startTime time.Time
+	usage     *usage.Recorder
+	location  *time.Location // Default timezone for date filters
+
+	maxBatchSize int // Messages per bulk request when streaming NDJSON
+
+	queue *ingest.Queue // Write-behind queue for single upserts (nil = synchronous)
+
+	media *enrich.MediaWorker // Transcribes and reads media of indexed messages (nil = disabled)
+
+	readyTimeout   time.Duration // How long the engine may take to answer a readiness check
+	queueThreshold float64       // Share of the ingest queue filled before the instance is not ready
+
+	authGuard *authguard.Guard // Brute-force protection reported in stats (nil = disabled)
+
+	capture        *capture.Store
+	enforceCapture bool
+
+	configSigner *jwt.JWTAuth // Signs the capture config served to clients (nil = unsigned config disabled)
+
+	subscriptions *subscriptions.Manager
+
+	webhookSecret string                 // Secret token for Telegram Bot API webhook deliveries
+	telegram      *ingest.TelegramClient // Built-in MTProto client for backfills (nil = disabled)
+
+	degrade          *degrade.Governor // Search load detection (nil = never degrade)
+	degradedPageSize int               // Page size cap while degraded
+
+	deleteThreshold int64 // Deletes affecting more messages need force (0 = no limit)
+
+	clearDisabled      bool                          // Refuse DELETE /clear entirely
+	clearMu            sync.Mutex                    // Guards clearConfirmations
+	clearConfirmations map[string]*clearConfirmation // Outstanding clear confirmation per caller
+
+	recycleBin *recyclebin.Bin // Trash for deleted messages (nil = delete immediately)
+	softDelete bool            // Delete-by-query marks messages as deleted instead of removing them
+
+	profiles *profiles.Store // Per-caller search defaults (nil = disabled)
+
+	embedder *enrich.Embedder // Query embeddings for semantic search (nil = disabled)
+	hybrid   HybridOptions    // Fusion and reranking defaults for hybrid semantic search
+
+	translator *enrich.Translator // Query translation for cross-language search (nil = disabled)
+
+	sessions *sessions.Store // Cookie sessions for the web dashboard (nil = disabled)
+	audit    *audit.Log      // Trail of security-relevant actions (nil = not recorded)
+
+	urlSigner     *signedurl.Signer // Signs download URLs (nil = disabled)
+	signedURLBase string            // Public address prepended to signed URLs
+
+	tokenMinter  *jwt.JWTAuth  // Mints service tokens (nil = disabled)
+	tokenIssuers []string      // Issuers tokens may be minted for
+	maxTokenTTL  time.Duration // Longest lifetime of a minted token
+
+	apiKeys *middleware.KeyRing // API keys that can be reloaded (nil = API key auth disabled)
+
+	retention *retention.Manager // Per-chat retention policies (nil = keep everything)
+
+	replicator *replication.Replicator // Copies writes to a secondary engine (nil = disabled)
+
+	events *events.Bus // Delivers document lifecycle events (nil = disabled)
+
+	scheduler        *scheduler.Scheduler // Recurring maintenance tasks (nil = disabled)
+	scheduleLocation *time.Location       // Location schedules are evaluated in
+
+	backup *backup.Service // Backups and restores started via the API (nil = disabled)
+
+	archiver *archive.Archiver // Copies indexed messages to object storage (nil = disabled)
+
+	snapshotRepository string // Repository whose snapshots can be mounted and searched ("" = disabled)
+
+	legalHolds *legalhold.Registry // Chats whose messages must not be deleted (nil = disabled)
+
+	publicStats *publicstats.Publisher // Noised stats of published chats (nil = disabled)
+
+	tenants *tenants.Registry // Tenants with indices of their own (nil = disabled)
+
+	dictionary *dictionary.Dictionary // Domain terms matched as a whole (nil = disabled)
+
+	saved *saved.Store // Tags of users' Saved Messages (nil = disabled)
 }
 
 // NewAPIHandler creates a new API handler
-func NewAPIHandler(engine engines.SearchEngine, startTime time.Time) *APIHandler {
+func NewAPIHandler(engine engines.SearchEngine, jobManager *jobs.Manager, pipeline *enrich.Pipeline, startTime time.Time) *APIHandler {
 	return &APIHandler{
 		engine:    engine,
+		jobs:      jobManager,
+		pipeline:  pipeline,
 		startTime: startTime,
 	}
 }
 
+// SetUsageRecorder enables per-tenant usage accounting
+func (h *APIHandler) SetUsageRecorder(recorder *usage.Recorder) {
+	h.usage = recorder
+}
+
+// SetLocation sets the default timezone for date filters without an explicit offset
+func (h *APIHandler) SetLocation(location *time.Location) {
+	h.location = location
+}
+
+// SetIngestQueue makes single upserts asynchronous via the given queue
+func (h *APIHandler) SetIngestQueue(queue *ingest.Queue) {
+	h.queue = queue
+}
+
+// SetMediaWorker makes indexed messages with media get transcribed and
+// read in the background
+func (h *APIHandler) SetMediaWorker(worker *enrich.MediaWorker) {
+	h.media = worker
+}
+
+// SetAuthGuard reports brute-force protection counters in stats
+func (h *APIHandler) SetAuthGuard(guard *authguard.Guard) {
+	h.authGuard = guard
+}
+
 // Upsert handles message indexing
 // POST /api/v1/upsert
 func (h *APIHandler) Upsert(c *gin.Context) {
 	var message models.Message
 	if err := c.ShouldBindJSON(&message); err != nil {
-		log.WithError(err).Warn("Invalid upsert request")
-		c.JSON(http.StatusBadRequest, models.ErrorResponse{
-			Error:   "Bad Request",
-			Message: err.Error(),
-		})
+		middleware.Log(c).WithError(err).Warn("Invalid upsert request")
+		c.JSON(http.StatusBadRequest, validationError(c, err))
 		return
 	}
 
@@ -50,20 +188,77 @@ func (h *APIHandler) Upsert(c *gin.Context) {
 	if message.ID == "" {
 		c.JSON(http.StatusBadRequest, models.ErrorResponse{
 			Error:   "Bad Request",
-			Message: "message ID is required",
+			Message: i18n.Tc(c, "message ID is required"),
 		})
 		return
 	}
 
-	if err := h.engine.Upsert(&message); err != nil {
-		log.WithError(err).Error("Failed to upsert message")
+	// Tenants index straight into their own index
+	if _, ok := tenantEngine(c); ok {
+		result, err := h.indexBatch(c, []models.Message{message})
+		if err != nil || result.IndexedCount == 0 {
+			middleware.Log(c).WithError(err).Error("Failed to upsert tenant message")
+			c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+				Error:   "Internal Server Error",
+				Message: i18n.Tc(c, "Failed to index message"),
+			})
+			return
+		}
+		c.JSON(http.StatusOK, models.UpsertResponse{
+			Success: true,
+			ID:      message.ID,
+		})
+		return
+	}
+
+	// Acknowledge but drop messages excluded by the capture rules
+	if !h.captureAllows(&message) {
+		c.JSON(http.StatusOK, models.UpsertResponse{
+			Success: true,
+			ID:      message.ID,
+			Skipped: true,
+		})
+		return
+	}
+
+	h.pipeline.Process(&message)
+
+	if h.queue != nil {
+		if err := h.queue.Enqueue(message); err != nil {
+			middleware.Log(c).WithError(err).Warn("Rejected upsert")
+			c.Header("Retry-After", "1")
+			c.JSON(http.StatusServiceUnavailable, models.ErrorResponse{
+				Error:   "Service Unavailable",
+				Message: i18n.Tc(c, "Ingest queue is full, retry later"),
+			})
+			return
+		}
+
+		h.usage.RecordIndexed(callerTenant(c), 1)
+		h.subscriptions.Match(&message)
+		h.media.Enqueue(h.engine, message)
+
+		c.JSON(http.StatusAccepted, models.UpsertResponse{
+			Success: true,
+			ID:      message.ID,
+			Queued:  true,
+		})
+		return
+	}
+
+	if err := h.engine.Upsert(engineContext(c), &message); err != nil {
+		middleware.Log(c).WithError(err).Error("Failed to upsert message")
 		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
 			Error:   "Internal Server Error",
-			Message: "Failed to index message",
+			Message: i18n.Tc(c, "Failed to index message"),
 		})
 		return
 	}
 
+	h.usage.RecordIndexed(callerTenant(c), 1)
+	h.subscriptions.Match(&message)
+	h.media.Enqueue(h.engine, message)
+
 	c.JSON(http.StatusOK, models.UpsertResponse{
 		Success: true,
 		ID:      message.ID,
@@ -73,13 +268,15 @@ func (h *APIHandler) Upsert(c *gin.Context) {
 // UpsertBatch handles batch message indexing
 // POST /api/v1/upsert/batch
 func (h *APIHandler) UpsertBatch(c *gin.Context) {
+	if c.ContentType() == ndjsonContentType {
+		h.upsertStream(c)
+		return
+	}
+
 	var req models.BatchUpsertRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		log.WithError(err).Warn("Invalid batch upsert request")
-		c.JSON(http.StatusBadRequest, models.ErrorResponse{
-			Error:   "Bad Request",
-			Message: err.Error(),
-		})
+		middleware.Log(c).WithError(err).Warn("Invalid batch upsert request")
+		c.JSON(http.StatusBadRequest, validationError(c, err))
 		return
 	}
 
@@ -87,7 +284,7 @@ func (h *APIHandler) UpsertBatch(c *gin.Context) {
 	if len(req.Messages) == 0 {
 		c.JSON(http.StatusBadRequest, models.ErrorResponse{
 			Error:   "Bad Request",
-			Message: "messages array cannot be empty",
+			Message: i18n.Tc(c, "messages array cannot be empty"),
 		})
 		return
 	}
@@ -97,32 +294,90 @@ func (h *APIHandler) UpsertBatch(c *gin.Context) {
 		if message.ID == "" {
 			c.JSON(http.StatusBadRequest, models.ErrorResponse{
 				Error:   "Bad Request",
-				Message: fmt.Sprintf("message at index %d is missing ID", i),
+				Message: i18n.Tc(c, "message at index %d is missing ID", i),
 			})
 			return
 		}
 	}
 
-	log.WithField("count", len(req.Messages)).Info("Processing batch upsert")
-
-	indexed, errors, err := h.engine.UpsertBatch(req.Messages)
+	result, err := h.indexBatch(c, req.Messages)
 	if err != nil {
-		log.WithError(err).Error("Failed to batch upsert messages")
+		middleware.Log(c).WithError(err).Error("Failed to batch upsert messages")
 		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
 			Error:   "Internal Server Error",
-			Message: "Failed to batch index messages",
+			Message: i18n.Tc(c, "Failed to batch index messages"),
 		})
 		return
 	}
 
-	failed := len(req.Messages) - indexed
+	c.JSON(http.StatusOK, result)
+}
+
+// indexBatch indexes a batch on behalf of the calling tenant, into the
+// tenant's own index when it has one
+func (h *APIHandler) indexBatch(c *gin.Context, batch []models.Message) (models.BatchUpsertResponse, error) {
+	if engine, ok := tenantEngine(c); ok {
+		return h.indexInto(engineContext(c), engine, batch, callerTenant(c), false)
+	}
+	return h.indexInto(engineContext(c), h.engine, batch, callerTenant(c), true)
+}
+
+// IndexMessages applies capture rules and enrichment to messages, bulk-indexes
+// the remainder and records usage and subscription matches. It is shared by
+// the HTTP batch endpoints and the non-HTTP ingestion consumers.
+func (h *APIHandler) IndexMessages(batch []models.Message, tenant string) (models.BatchUpsertResponse, error) {
+	return h.indexInto(context.Background(), h.engine, batch, tenant, true)
+}
+
+// indexInto enriches and bulk-indexes messages into engine. Capture rules
+// and subscriptions only apply to the shared index.
+func (h *APIHandler) indexInto(ctx context.Context, engine engines.SearchEngine, batch []models.Message, tenant string, shared bool) (models.BatchUpsertResponse, error) {
+	// Drop messages excluded by the capture rules and enrich the rest
+	messages := batch[:0]
+	for i := range batch {
+		if !shared || h.captureAllows(&batch[i]) {
+			h.pipeline.Process(&batch[i])
+			messages = append(messages, batch[i])
+		}
+	}
+	skipped := len(batch) - len(messages)
+	if len(messages) == 0 {
+		return models.BatchUpsertResponse{
+			Success:      true,
+			SkippedCount: skipped,
+		}, nil
+	}
+
+	log.WithFields(log.Fields{
+		"count":   len(messages),
+		"skipped": skipped,
+	}).Info("Processing batch upsert")
+
+	indexed, errors, err := engine.UpsertBatch(ctx, messages)
+	if err != nil {
+		return models.BatchUpsertResponse{}, err
+	}
+
+	failed := len(messages) - indexed
+	h.usage.RecordIndexed(tenant, indexed)
+	if indexed > 0 && shared {
+		for i := range messages {
+			h.subscriptions.Match(&messages[i])
+		}
+	}
+	if indexed > 0 {
+		for i := range messages {
+			h.media.Enqueue(engine, messages[i])
+		}
+	}
 
-	c.JSON(http.StatusOK, models.BatchUpsertResponse{
+	return models.BatchUpsertResponse{
 		Success:      failed == 0,
 		IndexedCount: indexed,
 		FailedCount:  failed,
+		SkippedCount: skipped,
 		Errors:       errors,
-	})
+	}, nil
 }
 
 // Search handles search queries
@@ -133,14 +388,32 @@ func (h *APIHandler) Search(c *gin.Context) {
 
 	var req models.SearchRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		log.WithError(err).Warn("Invalid search request")
-		c.JSON(http.StatusBadRequest, models.ErrorResponse{
-			Error:   "Bad Request",
-			Message: err.Error(),
+		middleware.Log(c).WithError(err).Warn("Invalid search request")
+		c.JSON(http.StatusBadRequest, validationError(c, err))
+		return
+	}
+	if req.Snapshot != "" {
+		c.JSON(http.StatusForbidden, models.ErrorResponse{
+			Error:   "Forbidden",
+			Message: i18n.Tc(c, "Snapshots are searched via POST /api/v1/search/snapshot"),
 		})
 		return
 	}
 
+	h.search(c, startTime, &req)
+}
+
+// search runs a bound search request, applying the caller's profile
+func (h *APIHandler) search(c *gin.Context, startTime time.Time, req *models.SearchRequest) {
+	if !req.IgnoreProfile {
+		if profile, ok := h.profiles.Get(callerID(c)); ok {
+			profile.Apply(req)
+		}
+	}
+	if !scopeSearch(c, req) {
+		return
+	}
+
 	// Set defaults
 	if req.Page < 1 {
 		req.Page = 1
@@ -151,34 +424,122 @@ func (h *APIHandler) Search(c *gin.Context) {
 	if req.PageSize > 100 {
 		req.PageSize = 100 // Max page size
 	}
+	if !models.ValidSortBy(req.SortBy) {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Bad Request",
+			Message: i18n.Tc(c, "unsupported sort_by: %s", req.SortBy),
+		})
+		return
+	}
+	if req.BoostBy != "" {
+		if models.EngagementField(req.BoostBy) == "" {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{
+				Error:   "Bad Request",
+				Message: i18n.Tc(c, "unsupported boost_by: %s", req.BoostBy),
+			})
+			return
+		}
+		// Boosting only matters when results are ranked by score
+		if req.SortBy == "" {
+			req.SortBy = models.SortByRelevance
+		}
+		if req.SortBy != models.SortByRelevance {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{
+				Error:   "Bad Request",
+				Message: i18n.Tc(c, "boost_by requires sort_by relevance"),
+			})
+			return
+		}
+	}
+	if err := req.ResolveDates(h.location); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Bad Request",
+			Message: err.Error(),
+		})
+		return
+	}
+	matched, ok := h.resolveChatTitles(c, req)
+	if !ok {
+		return
+	}
+	if !matched {
+		// No chat has a matching title, so nothing can match
+		c.JSON(http.StatusOK, models.SearchResponse{
+			Hits:        []models.Message{},
+			Page:        req.Page,
+			HitsPerPage: req.PageSize,
+			TookMs:      time.Since(startTime).Milliseconds(),
+			Sort:        models.SortOrder(req.SortBy),
+		})
+		return
+	}
+	if !h.embedQuery(c, req) {
+		return
+	}
+	if !h.translateQuery(c, req) {
+		return
+	}
 
-	result, err := h.engine.Search(&req)
+	// Shed expensive work while the engine is under load
+	if h.degrade.Begin() {
+		req.Degraded = true
+		actions := []string{"approximate-total"}
+		if h.degradedPageSize > 0 && req.PageSize > h.degradedPageSize {
+			req.MaxHits = h.degradedPageSize
+			actions = append(actions, "page-size-clamped")
+		}
+		c.Header(degradedHeader, strings.Join(actions, ", "))
+	}
+	req.Ctx = c.Request.Context()
+	engineStart := time.Now()
+	result, err := h.engineFor(c).Search(req)
+	h.degrade.End(time.Since(engineStart))
+	if errors.Is(err, engines.ErrSnapshotNotMounted) {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error:   "Not Found",
+			Message: i18n.Tc(c, "Snapshot %s is not mounted", req.Snapshot),
+		})
+		return
+	}
 	if err != nil {
-		log.WithError(err).Error("Search failed")
+		middleware.Log(c).WithError(err).Error("Search failed")
 		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
 			Error:   "Internal Server Error",
-			Message: "Search query failed",
+			Message: i18n.Tc(c, "Search query failed"),
 		})
 		return
 	}
 
+	// Consecutive messages of a sender typing in short lines become one hit
+	if req.MergeWindow > 0 {
+		mergeRuns(engineContext(c), h.engineFor(c), result, int64(req.MergeWindow))
+	}
+
+	// Show the tags users gave their saved messages
+	if h.saved != nil {
+		result.Tags = h.saved.Annotate(result.Hits)
+	}
+
 	// Calculate elapsed time in milliseconds
 	tookMs := time.Since(startTime).Milliseconds()
 
 	// Add timing to response
 	result.TookMs = tookMs
+	result.Translations = req.Translations
+
+	h.usage.RecordSearch(callerTenant(c))
 
 	c.JSON(http.StatusOK, result)
 }
 
 // DeleteMessages handles deletion by chat ID
-// DELETE /api/v1/messages?chat_id=123456
+// DELETE /api/v1/messages?chat_id=123456[&force=true]
 func (h *APIHandler) DeleteMessages(c *gin.Context) {
 	chatIDStr := c.Query("chat_id")
 	if chatIDStr == "" {
 		c.JSON(http.StatusBadRequest, models.ErrorResponse{
 			Error:   "Bad Request",
-			Message: "chat_id query parameter is required",
+			Message: i18n.Tc(c, "chat_id query parameter is required"),
 		})
 		return
 	}
@@ -187,20 +548,43 @@ func (h *APIHandler) DeleteMessages(c *gin.Context) {
 	if err != nil {
 		c.JSON(http.StatusBadRequest, models.ErrorResponse{
 			Error:   "Bad Request",
-			Message: "Invalid chat_id",
+			Message: i18n.Tc(c, "Invalid chat_id"),
 		})
 		return
 	}
+	if !allowChat(c, chatID) {
+		return
+	}
+
+	if !h.guardDelete(c, forceRequested(c), h.countMatching(&models.SearchRequest{ChatID: &chatID, IncludeDeleted: true})) {
+		return
+	}
+
+	if h.recycleBin != nil {
+		batch, ok := h.trashMessages(c, models.TrashSelector{ChatID: &chatID}, models.TrashOpDeleteChat, fmt.Sprintf("chat_id=%d", chatID))
+		if ok {
+			c.JSON(http.StatusOK, models.DeleteResponse{
+				Success:      true,
+				DeletedCount: batch.Count,
+				TrashBatch:   batch.ID,
+			})
+		}
+		return
+	}
 
-	deletedCount, err := h.engine.Delete(chatID)
+	deletedCount, err := h.engine.Delete(engineContext(c), chatID)
+	if refuseHeld(c, err) {
+		return
+	}
 	if err != nil {
-		log.WithError(err).Error("Failed to delete messages")
+		middleware.Log(c).WithError(err).Error("Failed to delete messages")
 		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
 			Error:   "Internal Server Error",
-			Message: "Failed to delete messages",
+			Message: i18n.Tc(c, "Failed to delete messages"),
 		})
 		return
 	}
+	h.auditDelete(c, models.TrashOpDeleteChat, fmt.Sprintf("chat_id=%d", chatID), nil, deletedCount, "")
 
 	c.JSON(http.StatusOK, models.DeleteResponse{
 		Success:      true,
@@ -208,14 +592,297 @@ func (h *APIHandler) DeleteMessages(c *gin.Context) {
 	})
 }
 
+// DeleteMessage handles permanent deletion of a single message, or moves it
+// to the recycle bin when enabled
+// DELETE /api/v1/messages/:id
+func (h *APIHandler) DeleteMessage(c *gin.Context) {
+	id := c.Param("id")
+	chatID, _, err := models.ParseMessageID(id)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Bad Request",
+			Message: err.Error(),
+		})
+		return
+	}
+	if !allowChat(c, chatID) {
+		return
+	}
+
+	// Tenant indices have no recycle bin
+	if _, tenant := tenantEngine(c); h.recycleBin != nil && !tenant {
+		batch, ok := h.trashMessages(c, models.TrashSelector{MessageID: id}, models.TrashOpDeleteMessage, "id="+id)
+		if !ok {
+			return
+		}
+		if batch.Count == 0 {
+			c.JSON(http.StatusNotFound, models.ErrorResponse{
+				Error:   "Not Found",
+				Message: i18n.Tc(c, "Message %s not found", id),
+			})
+			return
+		}
+		c.JSON(http.StatusOK, models.DeleteResponse{
+			Success:      true,
+			DeletedCount: batch.Count,
+			TrashBatch:   batch.ID,
+		})
+		return
+	}
+
+	found, err := h.engineFor(c).DeleteMessage(engineContext(c), id)
+	if refuseHeld(c, err) {
+		return
+	}
+	if err != nil {
+		middleware.Log(c).WithError(err).Error("Failed to delete message")
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "Internal Server Error",
+			Message: i18n.Tc(c, "Failed to delete message"),
+		})
+		return
+	}
+	if !found {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error:   "Not Found",
+			Message: i18n.Tc(c, "Message %s not found", id),
+		})
+		return
+	}
+	h.auditDelete(c, models.TrashOpDeleteMessage, "id="+id, nil, 1, "")
+	if _, tenant := tenantEngine(c); h.saved != nil && !tenant {
+		if err := h.saved.Remove(id); err != nil {
+			middleware.Log(c).WithError(err).Warn("Failed to remove tags of deleted message")
+		}
+	}
+
+	c.JSON(http.StatusOK, models.DeleteResponse{
+		Success:      true,
+		DeletedCount: 1,
+	})
+}
+
+// UpdateMessage handles partial updates of an edited message
+// PATCH /api/v1/messages/:id
+func (h *APIHandler) UpdateMessage(c *gin.Context) {
+	id := c.Param("id")
+	if _, _, err := models.ParseMessageID(id); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Bad Request",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	var req models.MessageUpdateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		middleware.Log(c).WithError(err).Warn("Invalid message update request")
+		c.JSON(http.StatusBadRequest, validationError(c, err))
+		return
+	}
+	if req.IsEmpty() {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Bad Request",
+			Message: i18n.Tc(c, "At least one of text, caption or edit_date is required"),
+		})
+		return
+	}
+
+	editDate := time.Now().Unix()
+	if req.EditDate != nil {
+		editDate = *req.EditDate
+	}
+
+	message, err := h.engineFor(c).UpdateMessage(engineContext(c), id, func(message *models.Message) {
+		if req.Text != nil {
+			message.Text = *req.Text
+		}
+		if req.Caption != nil {
+			message.Caption = req.Caption
+		}
+		message.Entities = nil  // Offsets refer to the old text
+		message.URLs = nil      // Re-extracted from the edited text
+		message.Mentions = nil
+		message.Hashtags = nil
+		message.Embedding = nil // Re-embedded from the edited text
+		message.Lang = ""       // Re-detected from the edited text
+		message.IsSpam = false  // Re-classified from the edited text
+		message.SpamScore = 0
+		message.EditCount++
+		message.LastEdited = editDate
+		h.pipeline.Process(message)
+	})
+	if err != nil {
+		middleware.Log(c).WithError(err).Error("Failed to update message")
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "Internal Server Error",
+			Message: i18n.Tc(c, "Failed to update message"),
+		})
+		return
+	}
+	if message == nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error:   "Not Found",
+			Message: i18n.Tc(c, "Message %s not found", id),
+		})
+		return
+	}
+
+	if _, tenant := tenantEngine(c); !tenant {
+		h.subscriptions.Match(message)
+	}
+
+	c.JSON(http.StatusOK, models.MessageUpdateResponse{
+		Success: true,
+		Message: message,
+	})
+}
+
+// ApplyEdit updates an indexed message with the edited text and caption,
+// indexing it in full when it was never stored (used by built-in ingestion)
+func (h *APIHandler) ApplyEdit(edited *models.Message) error {
+	editDate := time.Now().Unix()
+
+	message, err := h.engine.UpdateMessage(context.Background(), edited.ID, func(message *models.Message) {
+		message.Text = edited.Text
+		message.Caption = edited.Caption
+		message.Entities = edited.Entities
+		message.URLs = nil      // Re-extracted from the edited text
+		message.Mentions = nil
+		message.Hashtags = nil
+		message.Embedding = nil // Re-embedded from the edited text
+		message.Lang = ""       // Re-detected from the edited text
+		message.IsSpam = false  // Re-classified from the edited text
+		message.SpamScore = 0
+		message.EditCount++
+		message.LastEdited = editDate
+		h.pipeline.Process(message)
+	})
+	if err != nil {
+		return err
+	}
+	if message == nil {
+		edited.EditCount = 1
+		edited.LastEdited = editDate
+		_, err = h.IndexMessages([]models.Message{*edited}, usage.DefaultTenant)
+		return err
+	}
+
+	h.subscriptions.Match(message)
+	return nil
+}
+
+// DeleteByQuery handles deletion of messages matching search filters
+// POST /api/v1/messages/delete-by-query
+func (h *APIHandler) DeleteByQuery(c *gin.Context) {
+	var req models.DeleteByQueryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		middleware.Log(c).WithError(err).Warn("Invalid delete-by-query request")
+		c.JSON(http.StatusBadRequest, validationError(c, err))
+		return
+	}
+
+	// Refuse match-all deletes; Clear exists for that
+	if !req.HasFilters() {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Bad Request",
+			Message: i18n.Tc(c, "at least one filter (keyword, chat_id, chat_type, username, sender_id, date_from, date_to) is required"),
+		})
+		return
+	}
+	if !scopeSearch(c, &req.SearchRequest) {
+		return
+	}
+	if err := req.ResolveDates(h.location); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Bad Request",
+			Message: err.Error(),
+		})
+		return
+	}
+	req.Ctx = c.Request.Context()
+
+	matched, ok := h.resolveChatTitles(c, &req.SearchRequest)
+	if !ok {
+		return
+	}
+	if !matched {
+		c.JSON(http.StatusOK, models.DeleteByQueryResponse{
+			Success: true,
+			DryRun:  req.DryRun,
+		})
+		return
+	}
+
+	if !req.DryRun && !h.guardDelete(c, req.Force, h.countMatching(&req.SearchRequest)) {
+		return
+	}
+
+	// Validate refuses soft_delete together with the recycle bin; should
+	// both be set, soft delete wins
+	if !req.DryRun && h.softDelete {
+		count, err := h.engine.SoftDeleteByQuery(&req.SearchRequest)
+		if err != nil {
+			middleware.Log(c).WithError(err).Error("Failed to soft-delete by query")
+			c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+				Error:   "Internal Server Error",
+				Message: i18n.Tc(c, "Failed to delete messages by query"),
+			})
+			return
+		}
+		h.auditDelete(c, opSoftDeleteByQuery, "", &req.SearchRequest, count, "")
+		c.JSON(http.StatusOK, models.DeleteByQueryResponse{
+			Success:      true,
+			DeletedCount: count,
+			SoftDeleted:  true,
+		})
+		return
+	}
+
+	if !req.DryRun && h.recycleBin != nil {
+		batch, ok := h.trashMessages(c, models.TrashSelector{Query: &req.SearchRequest}, models.TrashOpDeleteByQuery, "")
+		if ok {
+			c.JSON(http.StatusOK, models.DeleteByQueryResponse{
+				Success:      true,
+				DeletedCount: batch.Count,
+				TrashBatch:   batch.ID,
+			})
+		}
+		return
+	}
+
+	count, err := h.engine.DeleteByQuery(&req.SearchRequest, req.DryRun)
+	if err != nil {
+		middleware.Log(c).WithError(err).Error("Failed to delete by query")
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "Internal Server Error",
+			Message: i18n.Tc(c, "Failed to delete messages by query"),
+		})
+		return
+	}
+
+	response := models.DeleteByQueryResponse{
+		Success: true,
+		DryRun:  req.DryRun,
+	}
+	if req.DryRun {
+		response.MatchedCount = count
+	} else {
+		response.DeletedCount = count
+		h.auditDelete(c, models.TrashOpDeleteByQuery, "", &req.SearchRequest, count, "")
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
 // DeleteUser handles deletion by user ID
-// DELETE /api/v1/users/:user_id
+// DELETE /api/v1/users/:user_id[?force=true]
 func (h *APIHandler) DeleteUser(c *gin.Context) {
 	userIDStr := c.Param("user_id")
 	if userIDStr == "" {
 		c.JSON(http.StatusBadRequest, models.ErrorResponse{
 			Error:   "Bad Request",
-			Message: "user_id is required",
+			Message: i18n.Tc(c, "user_id is required"),
 		})
 		return
 	}
@@ -224,20 +891,40 @@ func (h *APIHandler) DeleteUser(c *gin.Context) {
 	if err != nil {
 		c.JSON(http.StatusBadRequest, models.ErrorResponse{
 			Error:   "Bad Request",
-			Message: "Invalid user_id",
+			Message: i18n.Tc(c, "Invalid user_id"),
 		})
 		return
 	}
 
-	deletedCount, err := h.engine.DeleteUser(userID)
+	if !h.guardDelete(c, forceRequested(c), h.countMatching(&models.SearchRequest{SenderID: &userID, IncludeDeleted: true})) {
+		return
+	}
+
+	if h.recycleBin != nil {
+		batch, ok := h.trashMessages(c, models.TrashSelector{UserID: &userID}, models.TrashOpDeleteUser, fmt.Sprintf("user_id=%d", userID))
+		if ok {
+			c.JSON(http.StatusOK, models.DeleteResponse{
+				Success:      true,
+				DeletedCount: batch.Count,
+				TrashBatch:   batch.ID,
+			})
+		}
+		return
+	}
+
+	deletedCount, err := h.engine.DeleteUser(engineContext(c), userID)
+	if refuseHeld(c, err) {
+		return
+	}
 	if err != nil {
-		log.WithError(err).Error("Failed to delete user messages")
+		middleware.Log(c).WithError(err).Error("Failed to delete user messages")
 		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
 			Error:   "Internal Server Error",
-			Message: "Failed to delete user messages",
+			Message: i18n.Tc(c, "Failed to delete user messages"),
 		})
 		return
 	}
+	h.auditDelete(c, models.TrashOpDeleteUser, fmt.Sprintf("user_id=%d", userID), nil, deletedCount, "")
 
 	c.JSON(http.StatusOK, models.DeleteResponse{
 		Success:      true,
@@ -245,33 +932,106 @@ func (h *APIHandler) DeleteUser(c *gin.Context) {
 	})
 }
 
-// Clear handles database clearing
-// DELETE /api/v1/clear
+// Clear starts clearing the database as a background job. Without a
+// confirm token it only answers 428 with a token that confirms the clear
+// when sent back by the same caller within clearConfirmTTL.
+// DELETE /api/v1/clear[?confirm=TOKEN]
 func (h *APIHandler) Clear(c *gin.Context) {
-	if err := h.engine.Clear(); err != nil {
-		log.WithError(err).Error("Failed to clear database")
-		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
-			Error:   "Internal Server Error",
-			Message: "Failed to clear database",
+	if h.clearDisabled {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error:   "Not Found",
+			Message: i18n.Tc(c, "Clear is not enabled"),
 		})
 		return
 	}
+	if h.refuseWhileHeld(c) {
+		return
+	}
+	// Refused before a confirmation is issued or redeemed
+	if running := h.runningJob(jobTypeClear); running != nil {
+		jobConflict(c, running)
+		return
+	}
 
-	c.JSON(http.StatusOK, models.ClearResponse{
-		Success: true,
-		Message: "Database cleared successfully",
+	caller := callerID(c)
+	token := c.Query("confirm")
+	if token == "" {
+		stats, err := h.engine.Stats(engineContext(c))
+		if err != nil {
+			middleware.Log(c).WithError(err).Error("Failed to estimate affected messages")
+			c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+				Error:   "Internal Server Error",
+				Message: i18n.Tc(c, "Failed to estimate affected messages"),
+			})
+			return
+		}
+		confirmation, err := h.issueClearConfirmation(caller)
+		if err != nil {
+			middleware.Log(c).WithError(err).Error("Failed to issue clear confirmation")
+			c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+				Error:   "Internal Server Error",
+				Message: i18n.Tc(c, "Failed to issue clear confirmation"),
+			})
+			return
+		}
+		c.JSON(http.StatusPreconditionRequired, models.ClearConfirmationResponse{
+			Error:          "Precondition Required",
+			Message:        i18n.Tc(c, "This would delete all %d messages; repeat the request with confirm=%s within %d seconds to proceed", stats.TotalDocuments, confirmation.token, int(clearConfirmTTL.Seconds())),
+			ConfirmToken:   confirmation.token,
+			ExpiresAt:      confirmation.expires.Unix(),
+			EstimatedCount: stats.TotalDocuments,
+		})
+		return
+	}
+	if !h.redeemClearConfirmation(caller, token) {
+		c.JSON(http.StatusPreconditionFailed, models.ErrorResponse{
+			Error:   "Precondition Failed",
+			Message: i18n.Tc(c, "Invalid or expired confirmation token; request a new one without confirm"),
+		})
+		return
+	}
+
+	job, started := h.jobs.StartExclusive(jobTypeClear, func(ctx context.Context, update func(progress interface{})) (interface{}, error) {
+		if err := h.engine.Clear(ctx); err != nil {
+			return nil, err
+		}
+		if h.saved != nil {
+			if err := h.saved.Replace(nil); err != nil {
+				log.WithError(err).Warn("Failed to remove tags of cleared messages")
+			}
+		}
+		return models.ClearResponse{
+			Success: true,
+			Message: "Database cleared successfully",
+		}, nil
 	})
+	if !started {
+		jobConflict(c, job)
+		return
+	}
+
+	h.audit.Record(audit.Entry{
+		Action: "index.clear",
+		Actor:  callerID(c),
+		IP:     c.ClientIP(),
+		Detail: map[string]interface{}{
+			"job_id": job.ID,
+		},
+	})
+
+	c.Header("Location", "/api/v1/jobs/"+job.ID)
+	c.JSON(http.StatusAccepted, job)
 }
 
 // Ping handles health checks
 // GET /api/v1/ping
 func (h *APIHandler) Ping(c *gin.Context) {
-	result, err := h.engine.Ping()
+	result, err := h.engineFor(c).Ping()
 	if err != nil {
-		log.WithError(err).Error("Ping failed")
+		middleware.Log(c).WithError(err).Error("Ping failed")
 		c.JSON(http.StatusServiceUnavailable, models.ErrorResponse{
 			Error:   "Service Unavailable",
-			Message: "Search engine is not available",
+			Message: i18n.Tc(c, "Search engine is not available"),
 		})
 		return
 	}
@@ -282,52 +1042,99 @@ func (h *APIHandler) Ping(c *gin.Context) {
 // Stats handles statistics requests
 // GET /api/v1/stats
 func (h *APIHandler) Stats(c *gin.Context) {
-	result, err := h.engine.Stats()
+	result, err := h.engineFor(c).Stats(engineContext(c))
 	if err != nil {
-		log.WithError(err).Error("Failed to get stats")
+		middleware.Log(c).WithError(err).Error("Failed to get stats")
 		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
 			Error:   "Internal Server Error",
-			Message: "Failed to retrieve statistics",
+			Message: i18n.Tc(c, "Failed to retrieve statistics"),
 		})
 		return
 	}
 
+	// Instance-wide state is not shown to tenants
+	if _, tenant := tenantEngine(c); tenant {
+		c.JSON(http.StatusOK, result)
+		return
+	}
+	result.IngestQueue = h.queue.Stats()
+	result.MediaEnrichment = h.media.Stats()
+	result.AuthGuard = h.authGuard.Stats()
+	result.Replication = h.replicator.Stats()
+	result.Archive = h.archiver.Stats()
+
 	c.JSON(http.StatusOK, result)
 }
 
-// Dedup handles deduplication requests
+// Dedup starts deduplication as a background job. With {"dry_run": true}
+// the job only reports duplicate groups without deleting anything.
 // POST /api/v1/dedup
 func (h *APIHandler) Dedup(c *gin.Context) {
-	log.Info("Starting deduplication...")
+	// The body is optional
+	var req models.DedupRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		middleware.Log(c).WithError(err).Warn("Invalid dedup request")
+		c.JSON(http.StatusBadRequest, validationError(c, err))
+		return
+	}
+	if !req.DryRun && h.refuseWhileHeld(c) {
+		return
+	}
 
-	result, err := h.engine.Dedup()
-	if err != nil {
-		log.WithError(err).Error("Deduplication failed")
-		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
-			Error:   "Internal Server Error",
-			Message: "Deduplication failed",
+	// Only one dedup pass at a time
+	job, started := h.jobs.StartExclusive(jobTypeDedup, func(ctx context.Context, update func(progress interface{})) (interface{}, error) {
+		log.WithField("dry_run", req.DryRun).Info("Starting deduplication...")
+		return h.engine.Dedup(ctx, req.DryRun, func(progress *models.DedupResponse) {
+			update(progress)
 		})
+	})
+	if !started {
+		jobConflict(c, job)
 		return
 	}
 
-	c.JSON(http.StatusOK, result)
+	if !req.DryRun {
+		h.audit.Record(audit.Entry{
+			Action: "index.dedup",
+			Actor:  callerID(c),
+			IP:     c.ClientIP(),
+			Detail: map[string]interface{}{
+				"job_id": job.ID,
+			},
+		})
+	}
+
+	c.Header("Location", "/api/v1/jobs/"+job.ID)
+	c.JSON(http.StatusAccepted, job)
 }
 
 // CleanCommands handles cleaning command messages (starting with '/')
 // DELETE /api/v1/commands
 func (h *APIHandler) CleanCommands(c *gin.Context) {
-	log.Info("Starting command cleanup...")
+	middleware.Log(c).Info("Starting command cleanup...")
 
-	result, err := h.engine.CleanCommands()
+	result, err := h.engine.CleanCommands(engineContext(c))
+	if refuseHeld(c, err) {
+		return
+	}
 	if err != nil {
-		log.WithError(err).Error("Command cleanup failed")
+		middleware.Log(c).WithError(err).Error("Command cleanup failed")
 		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
 			Error:   "Internal Server Error",
-			Message: "Command cleanup failed",
+			Message: i18n.Tc(c, "Command cleanup failed"),
 		})
 		return
 	}
 
+	h.audit.Record(audit.Entry{
+		Action: "commands.clean",
+		Actor:  callerID(c),
+		IP:     c.ClientIP(),
+		Detail: map[string]interface{}{
+			"deleted_count": result.DeletedCount,
+		},
+	})
+
 	c.JSON(http.StatusOK, result)
 }
 
@@ -369,19 +1176,19 @@ func (h *APIHandler) SoftDeleteMessage(c *gin.Context) {
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
-		log.WithError(err).Warn("Invalid soft-delete request")
-		c.JSON(http.StatusBadRequest, models.ErrorResponse{
-			Error:   "Bad Request",
-			Message: err.Error(),
-		})
+		middleware.Log(c).WithError(err).Warn("Invalid soft-delete request")
+		c.JSON(http.StatusBadRequest, validationError(c, err))
+		return
+	}
+	if !allowChat(c, req.ChatID) {
 		return
 	}
 
-	if err := h.engine.SoftDeleteMessage(req.ChatID, req.MessageID); err != nil {
-		log.WithError(err).Error("Failed to soft-delete message")
+	if err := h.engineFor(c).SoftDeleteMessage(engineContext(c), req.ChatID, req.MessageID); err != nil {
+		middleware.Log(c).WithError(err).Error("Failed to soft-delete message")
 		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
 			Error:   "Internal Server Error",
-			Message: "Failed to soft-delete message",
+			Message: i18n.Tc(c, "Failed to soft-delete message"),
 		})
 		return
 	}
@@ -397,11 +1204,8 @@ func (h *APIHandler) SoftDeleteMessage(c *gin.Context) {
 func (h *APIHandler) UserStats(c *gin.Context) {
 	var req models.UserStatsRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		log.WithError(err).Warn("Invalid user stats request")
-		c.JSON(http.StatusBadRequest, models.ErrorResponse{
-			Error:   "Bad Request",
-			Message: err.Error(),
-		})
+		middleware.Log(c).WithError(err).Warn("Invalid user stats request")
+		c.JSON(http.StatusBadRequest, validationError(c, err))
 		return
 	}
 
@@ -409,38 +1213,38 @@ func (h *APIHandler) UserStats(c *gin.Context) {
 	if req.GroupID == 0 {
 		c.JSON(http.StatusBadRequest, models.ErrorResponse{
 			Error:   "Bad Request",
-			Message: "group_id is required",
+			Message: i18n.Tc(c, "group_id is required"),
 		})
 		return
 	}
 	if req.UserID == 0 {
 		c.JSON(http.StatusBadRequest, models.ErrorResponse{
 			Error:   "Bad Request",
-			Message: "user_id is required",
+			Message: i18n.Tc(c, "user_id is required"),
 		})
 		return
 	}
 	if req.FromTimestamp == 0 || req.ToTimestamp == 0 {
 		c.JSON(http.StatusBadRequest, models.ErrorResponse{
 			Error:   "Bad Request",
-			Message: "from_timestamp and to_timestamp are required",
+			Message: i18n.Tc(c, "from_timestamp and to_timestamp are required"),
 		})
 		return
 	}
 	if req.FromTimestamp > req.ToTimestamp {
 		c.JSON(http.StatusBadRequest, models.ErrorResponse{
 			Error:   "Bad Request",
-			Message: "from_timestamp must be less than to_timestamp",
+			Message: i18n.Tc(c, "from_timestamp must be less than to_timestamp"),
 		})
 		return
 	}
 
-	result, err := h.engine.GetUserStats(&req)
+	result, err := h.engineFor(c).GetUserStats(engineContext(c), &req)
 	if err != nil {
-		log.WithError(err).Error("Failed to get user stats")
+		middleware.Log(c).WithError(err).Error("Failed to get user stats")
 		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
 			Error:   "Internal Server Error",
-			Message: "Failed to retrieve user statistics",
+			Message: i18n.Tc(c, "Failed to retrieve user statistics"),
 		})
 		return
 	}
@@ -454,18 +1258,18 @@ func (h *APIHandler) SystemInfo(c *gin.Context) {
 	// Get memory stats
 	memInfo, err := mem.VirtualMemory()
 	if err != nil {
-		log.WithError(err).Error("Failed to get memory info")
+		middleware.Log(c).WithError(err).Error("Failed to get memory info")
 	}
 
 	swapInfo, err := mem.SwapMemory()
 	if err != nil {
-		log.WithError(err).Error("Failed to get swap info")
+		middleware.Log(c).WithError(err).Error("Failed to get swap info")
 	}
 
 	// Get CPU stats
 	cpuPercent, err := cpu.Percent(time.Second, false)
 	if err != nil {
-		log.WithError(err).Error("Failed to get CPU usage")
+		middleware.Log(c).WithError(err).Error("Failed to get CPU usage")
 	}
 	cpuUsage := 0.0
 	if len(cpuPercent) > 0 {
@@ -498,13 +1302,13 @@ func (h *APIHandler) SystemInfo(c *gin.Context) {
 	// Get disk stats (root partition)
 	diskInfo, err := disk.Usage("/")
 	if err != nil {
-		log.WithError(err).Error("Failed to get disk info")
+		middleware.Log(c).WithError(err).Error("Failed to get disk info")
 	}
 
 	// Get host info (uptime, OS, etc.)
 	hostInfo, err := host.Info()
 	if err != nil {
-		log.WithError(err).Error("Failed to get host info")
+		middleware.Log(c).WithError(err).Error("Failed to get host info")
 	}
 
 	// Calculate uptime
@@ -0,0 +1,55 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/zhishengyuan/searchgram-engine/audit"
+	"github.com/zhishengyuan/searchgram-engine/i18n"
+	"github.com/zhishengyuan/searchgram-engine/middleware"
+	"github.com/zhishengyuan/searchgram-engine/models"
+)
+
+// SetAPIKeys enables reloading the API keys via the API
+func (h *APIHandler) SetAPIKeys(keys *middleware.KeyRing) {
+	h.apiKeys = keys
+}
+
+// ReloadAPIKeys reads the API keys from the configuration file again, so a
+// key can be rotated without a restart: add the new key, move clients over,
+// then remove the old one
+// POST /api/v1/auth/keys/reload
+func (h *APIHandler) ReloadAPIKeys(c *gin.Context) {
+	if h.apiKeys == nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error:   "Not Found",
+			Message: i18n.Tc(c, "API key authentication is not enabled"),
+		})
+		return
+	}
+
+	count, err := h.apiKeys.Reload()
+	if err != nil {
+		middleware.Log(c).WithError(err).Error("Failed to reload API keys")
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "Internal Server Error",
+			Message: i18n.Tc(c, "Failed to reload the API keys: %s", err.Error()),
+		})
+		return
+	}
+
+	h.audit.Record(audit.Entry{
+		Action: "auth.keys.reload",
+		Actor:  callerID(c),
+		IP:     c.ClientIP(),
+		Detail: map[string]interface{}{
+			"keys": count,
+		},
+	})
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":   true,
+		"count":     count,
+		"loaded_at": h.apiKeys.LoadedAt().Unix(),
+	})
+}
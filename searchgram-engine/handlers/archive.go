@@ -0,0 +1,8 @@
+package handlers
+
+import "github.com/zhishengyuan/searchgram-engine/archive"
+
+// SetArchiver reports archiving to object storage in the stats
+func (h *APIHandler) SetArchiver(archiver *archive.Archiver) {
+	h.archiver = archiver
+}
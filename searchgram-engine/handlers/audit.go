@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+	"github.com/zhishengyuan/searchgram-engine/audit"
+	"github.com/zhishengyuan/searchgram-engine/i18n"
+	"github.com/zhishengyuan/searchgram-engine/middleware"
+	"github.com/zhishengyuan/searchgram-engine/models"
+)
+
+// VerifyAudit checks the audit trail's hash chain, and its copy in the
+// audit index when enabled, for altered or removed entries
+// GET /api/v1/audit/verify
+func (h *APIHandler) VerifyAudit(c *gin.Context) {
+	if h.audit == nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error:   "Not Found",
+			Message: i18n.Tc(c, "The audit log is not enabled"),
+		})
+		return
+	}
+
+	result, err := h.audit.Verify(c.Request.Context())
+	if err != nil {
+		middleware.Log(c).WithError(err).Error("Failed to verify audit log")
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "Internal Server Error",
+			Message: i18n.Tc(c, "Failed to verify the audit log"),
+		})
+		return
+	}
+	if !result.Valid || (result.Index != nil && !result.Index.Valid) {
+		middleware.Log(c).WithFields(log.Fields{
+			"broken_at": result.BrokenAt,
+			"reason":    result.Reason,
+		}).Warn("Audit log failed verification")
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// auditDelete records a delete of messages: operation is one of the
+// models.TrashOp* values, target or query what was deleted, and batch the
+// recycle bin batch holding the messages, if any
+func (h *APIHandler) auditDelete(c *gin.Context, operation, target string, query *models.SearchRequest, count int64, batch string) {
+	detail := map[string]interface{}{
+		"deleted_count": count,
+	}
+	if target != "" {
+		detail["target"] = target
+	}
+	if query != nil {
+		detail["query"] = query
+	}
+	if batch != "" {
+		detail["trash_batch"] = batch
+	}
+
+	h.audit.Record(audit.Entry{
+		Action: "messages." + operation,
+		Actor:  callerID(c),
+		IP:     c.ClientIP(),
+		Detail: detail,
+	})
+}
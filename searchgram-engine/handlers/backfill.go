@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/zhishengyuan/searchgram-engine/i18n"
+	"github.com/zhishengyuan/searchgram-engine/ingest"
+	"github.com/zhishengyuan/searchgram-engine/middleware"
+	"github.com/zhishengyuan/searchgram-engine/models"
+)
+
+// SetTelegramClient enables history backfills through the built-in Telegram client
+func (h *APIHandler) SetTelegramClient(client *ingest.TelegramClient) {
+	h.telegram = client
+}
+
+// Backfill imports the full history of a chat as a background job. Only one
+// backfill runs at a time to stay within Telegram's rate limits; an in-flight
+// job is handed back instead.
+// POST /api/v1/backfill
+func (h *APIHandler) Backfill(c *gin.Context) {
+	if h.telegram == nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error:   "Not Found",
+			Message: i18n.Tc(c, "The built-in Telegram client is not enabled"),
+		})
+		return
+	}
+
+	var req models.BackfillRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		middleware.Log(c).WithError(err).Warn("Invalid backfill request")
+		c.JSON(http.StatusBadRequest, validationError(c, err))
+		return
+	}
+	if req.OffsetID < 0 || req.Limit < 0 {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Bad Request",
+			Message: i18n.Tc(c, "offset_id and limit must not be negative"),
+		})
+		return
+	}
+
+	job, started := h.jobs.StartExclusive(jobTypeBackfill, func(ctx context.Context, update func(progress interface{})) (interface{}, error) {
+		return h.telegram.Backfill(ctx, req.ChatID, req.OffsetID, req.Limit, func(progress ingest.BackfillProgress) {
+			update(progress)
+		})
+	})
+	if !started {
+		jobConflict(c, job)
+		return
+	}
+
+	c.Header("Location", "/api/v1/jobs/"+job.ID)
+	c.JSON(http.StatusAccepted, job)
+}
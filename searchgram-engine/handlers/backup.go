@@ -0,0 +1,170 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+	"github.com/zhishengyuan/searchgram-engine/audit"
+	"github.com/zhishengyuan/searchgram-engine/backup"
+	"github.com/zhishengyuan/searchgram-engine/i18n"
+	"github.com/zhishengyuan/searchgram-engine/middleware"
+	"github.com/zhishengyuan/searchgram-engine/models"
+)
+
+// SetBackup enables backups and restores via the API
+func (h *APIHandler) SetBackup(service *backup.Service) {
+	h.backup = service
+}
+
+// requireBackup writes a 404 when backups are disabled
+func (h *APIHandler) requireBackup(c *gin.Context) bool {
+	if h.backup == nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error:   "Not Found",
+			Message: i18n.Tc(c, "Backups are not enabled"),
+		})
+		return false
+	}
+	return true
+}
+
+// Backup starts backing up the index as a background job; the job result
+// names the backup to pass to Restore
+// POST /api/v1/backup
+func (h *APIHandler) Backup(c *gin.Context) {
+	if !h.requireBackup(c) {
+		return
+	}
+
+	job, started := h.jobs.StartExclusive(jobTypeBackup, func(ctx context.Context, update func(progress interface{})) (interface{}, error) {
+		log.WithField("type", h.backup.Type()).Info("Starting backup...")
+		return h.backup.Backup(ctx, func(progress *models.BackupProgress) {
+			update(progress)
+		})
+	})
+	if !started {
+		jobConflict(c, job)
+		return
+	}
+
+	h.audit.Record(audit.Entry{
+		Action: "backup.create",
+		Actor:  callerID(c),
+		IP:     c.ClientIP(),
+		Detail: map[string]interface{}{
+			"type":   h.backup.Type(),
+			"job_id": job.ID,
+		},
+	})
+
+	c.Header("Location", "/api/v1/jobs/"+job.ID)
+	c.JSON(http.StatusAccepted, job)
+}
+
+// Restore starts restoring a backup as a background job. A non-empty index
+// is only replaced when requested.
+// POST /api/v1/restore[?force=true]
+func (h *APIHandler) Restore(c *gin.Context) {
+	if !h.requireBackup(c) {
+		return
+	}
+
+	var req models.RestoreRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		middleware.Log(c).WithError(err).Warn("Invalid restore request")
+		c.JSON(http.StatusBadRequest, validationError(c, err))
+		return
+	}
+	if !backup.ValidName(req.Name) {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Bad Request",
+			Message: i18n.Tc(c, "Invalid backup name"),
+		})
+		return
+	}
+
+	stats, err := h.engine.Stats(engineContext(c))
+	if err != nil {
+		middleware.Log(c).WithError(err).Error("Failed to get stats")
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "Internal Server Error",
+			Message: i18n.Tc(c, "Failed to retrieve statistics"),
+		})
+		return
+	}
+	if stats.TotalDocuments > 0 {
+		if !req.Replace {
+			c.JSON(http.StatusConflict, models.ErrorResponse{
+				Error:   "Conflict",
+				Message: i18n.Tc(c, "The index is not empty; set replace to restore over it"),
+			})
+			return
+		}
+		if h.refuseWhileHeld(c) {
+			return
+		}
+		estimate := func() (int64, error) { return stats.TotalDocuments, nil }
+		if !h.guardDelete(c, forceRequested(c), estimate) {
+			return
+		}
+	}
+
+	job, started := h.jobs.StartExclusive(jobTypeRestore, func(ctx context.Context, update func(progress interface{})) (interface{}, error) {
+		log.WithField("backup", req.Name).Info("Starting restore...")
+		return h.backup.Restore(ctx, req.Name, req.Replace, h.applyBackupState, func(progress *models.BackupProgress) {
+			update(progress)
+		})
+	})
+	if !started {
+		jobConflict(c, job)
+		return
+	}
+
+	h.audit.Record(audit.Entry{
+		Action: "backup.restore",
+		Actor:  callerID(c),
+		IP:     c.ClientIP(),
+		Detail: map[string]interface{}{
+			"backup":  req.Name,
+			"replace": req.Replace,
+			"job_id":  job.ID,
+		},
+	})
+
+	c.Header("Location", "/api/v1/jobs/"+job.ID)
+	c.JSON(http.StatusAccepted, job)
+}
+
+// applyBackupState stores the saved searches of a restored backup through
+// the running stores, so they take effect without a restart. Files of
+// disabled features are skipped.
+func (h *APIHandler) applyBackupState(name string, data []byte) error {
+	switch {
+	case name == "profiles.json" && h.profiles != nil:
+		var list []models.SearchProfile
+		if err := json.Unmarshal(data, &list); err != nil {
+			return err
+		}
+		return h.profiles.Replace(list)
+
+	case name == "subscriptions.json" && h.subscriptions != nil:
+		var list []models.Subscription
+		if err := json.Unmarshal(data, &list); err != nil {
+			return err
+		}
+		return h.subscriptions.Replace(list)
+
+	case name == "saved_tags.json" && h.saved != nil:
+		var list []models.SavedTags
+		if err := json.Unmarshal(data, &list); err != nil {
+			return err
+		}
+		return h.saved.Replace(list)
+	}
+
+	log.WithField("file", name).Warn("Skipping state file of a disabled feature")
+	return nil
+}
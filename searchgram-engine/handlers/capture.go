@@ -0,0 +1,224 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/zhishengyuan/searchgram-engine/capture"
+	"github.com/zhishengyuan/searchgram-engine/i18n"
+	"github.com/zhishengyuan/searchgram-engine/jwt"
+	"github.com/zhishengyuan/searchgram-engine/middleware"
+	"github.com/zhishengyuan/searchgram-engine/models"
+)
+
+const (
+	// captureConfigContentType marks the signed capture config; being no
+	// application/json, the body is never rewritten after signing
+	captureConfigContentType = "application/vnd.searchgram.capture-config+json"
+
+	// configSignatureHeader carries the base64 Ed25519 signature of the body
+	configSignatureHeader = "X-Config-Signature"
+)
+
+// SetCaptureRules enables the capture rules API. When enforce is true,
+// upserts that the rules exclude are acknowledged but not indexed.
+func (h *APIHandler) SetCaptureRules(store *capture.Store, enforce bool) {
+	h.capture = store
+	h.enforceCapture = enforce
+}
+
+// captureAllows reports whether a message passes the enforced capture rules
+func (h *APIHandler) captureAllows(message *models.Message) bool {
+	if h.capture == nil || !h.enforceCapture {
+		return true
+	}
+	return h.capture.Allows(message)
+}
+
+// SetConfigSigner enables the signed capture config endpoint, signing with
+// the JWT private key
+func (h *APIHandler) SetConfigSigner(signer *jwt.JWTAuth) {
+	h.configSigner = signer
+}
+
+// requireCapture writes a 404 when the capture rules API is disabled
+func (h *APIHandler) requireCapture(c *gin.Context) bool {
+	if h.capture == nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error:   "Not Found",
+			Message: i18n.Tc(c, "Capture rules are not enabled"),
+		})
+		return false
+	}
+	return true
+}
+
+// GetCaptureRules returns the full capture rule set. Polling clients can send
+// If-None-Match with the previous ETag to receive 304 when nothing changed.
+// GET /api/v1/capture/rules
+func (h *APIHandler) GetCaptureRules(c *gin.Context) {
+	if !h.requireCapture(c) {
+		return
+	}
+
+	rules := h.capture.Get()
+	etag := fmt.Sprintf(`"%d"`, rules.Version)
+	c.Header("ETag", etag)
+
+	if c.GetHeader("If-None-Match") == etag {
+		c.Status(http.StatusNotModified)
+		return
+	}
+
+	c.JSON(http.StatusOK, rules)
+}
+
+// GetCaptureConfig serves the capture rule set as the signed configuration
+// document capture clients poll. The body is signed as sent, with the
+// Ed25519 signature (base64) in X-Config-Signature; its content type keeps
+// response field renaming away so the signature stays valid. If-None-Match
+// with the previous ETag returns 304 when nothing changed.
+// GET /api/v1/capture/config
+func (h *APIHandler) GetCaptureConfig(c *gin.Context) {
+	if !h.requireCapture(c) {
+		return
+	}
+	if h.configSigner == nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error:   "Not Found",
+			Message: i18n.Tc(c, "Signed capture config requires a JWT private key"),
+		})
+		return
+	}
+
+	rules := h.capture.Get()
+	etag := fmt.Sprintf(`"%d"`, rules.Version)
+	c.Header("ETag", etag)
+
+	if c.GetHeader("If-None-Match") == etag {
+		c.Status(http.StatusNotModified)
+		return
+	}
+
+	body, err := json.Marshal(rules)
+	if err == nil {
+		var signature []byte
+		if signature, err = h.configSigner.Sign(body); err == nil {
+			c.Header(configSignatureHeader, base64.StdEncoding.EncodeToString(signature))
+		}
+	}
+	if err != nil {
+		middleware.Log(c).WithError(err).Error("Failed to sign capture config")
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "Internal Server Error",
+			Message: i18n.Tc(c, "Failed to sign capture config"),
+		})
+		return
+	}
+
+	c.Data(http.StatusOK, captureConfigContentType, body)
+}
+
+// ReplaceCaptureRules replaces the whole capture rule set
+// PUT /api/v1/capture/rules
+func (h *APIHandler) ReplaceCaptureRules(c *gin.Context) {
+	if !h.requireCapture(c) {
+		return
+	}
+
+	var req models.CaptureRuleSet
+	if err := c.ShouldBindJSON(&req); err != nil {
+		middleware.Log(c).WithError(err).Warn("Invalid capture rules request")
+		c.JSON(http.StatusBadRequest, validationError(c, err))
+		return
+	}
+
+	rules, err := h.capture.Replace(req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Bad Request",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.Header("ETag", fmt.Sprintf(`"%d"`, rules.Version))
+	c.JSON(http.StatusOK, rules)
+}
+
+// AddCaptureRule appends a single capture rule
+// POST /api/v1/capture/rules
+func (h *APIHandler) AddCaptureRule(c *gin.Context) {
+	if !h.requireCapture(c) {
+		return
+	}
+
+	var req models.CaptureRule
+	if err := c.ShouldBindJSON(&req); err != nil {
+		middleware.Log(c).WithError(err).Warn("Invalid capture rule request")
+		c.JSON(http.StatusBadRequest, validationError(c, err))
+		return
+	}
+
+	rule, err := h.capture.AddRule(req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Bad Request",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, rule)
+}
+
+// DeleteCaptureRule removes a capture rule
+// DELETE /api/v1/capture/rules/:id
+func (h *APIHandler) DeleteCaptureRule(c *gin.Context) {
+	if !h.requireCapture(c) {
+		return
+	}
+
+	if err := h.capture.DeleteRule(c.Param("id")); err != nil {
+		if err == capture.ErrRuleNotFound {
+			c.JSON(http.StatusNotFound, models.ErrorResponse{
+				Error:   "Not Found",
+				Message: err.Error(),
+			})
+			return
+		}
+		middleware.Log(c).WithError(err).Error("Failed to delete capture rule")
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "Internal Server Error",
+			Message: i18n.Tc(c, "Failed to delete capture rule"),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+	})
+}
+
+// EvaluateCaptureRules resolves the policy for a chat
+// GET /api/v1/capture/evaluate?chat_id=123&chat_type=GROUP
+func (h *APIHandler) EvaluateCaptureRules(c *gin.Context) {
+	if !h.requireCapture(c) {
+		return
+	}
+
+	chatID, err := strconv.ParseInt(c.Query("chat_id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Bad Request",
+			Message: i18n.Tc(c, "Invalid chat_id"),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, h.capture.Evaluate(chatID, c.Query("chat_type")))
+}
@@ -0,0 +1,190 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/zhishengyuan/searchgram-engine/i18n"
+	"github.com/zhishengyuan/searchgram-engine/middleware"
+	"github.com/zhishengyuan/searchgram-engine/models"
+)
+
+const (
+	maxTopLimit = 100
+
+	// maxResolvedChats bounds chat listings and title_contains resolution
+	maxResolvedChats = 1000
+)
+
+// ListChats lists indexed chats with their latest title, most active first
+// GET /api/v1/chats?title_contains=工作&limit=100
+func (h *APIHandler) ListChats(c *gin.Context) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "100"))
+	if err != nil || limit < 1 {
+		limit = 100
+	}
+	if limit > maxResolvedChats {
+		limit = maxResolvedChats
+	}
+
+	// Scoped API keys only see their chats, which need not be the most active
+	_, scoped := chatScope(c)
+	fetch := limit
+	if scoped {
+		fetch = maxResolvedChats
+	}
+
+	chats, err := h.engineFor(c).ListChats(engineContext(c), strings.TrimSpace(c.Query("title_contains")), fetch)
+	if err != nil {
+		middleware.Log(c).WithError(err).Error("Failed to list chats")
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "Internal Server Error",
+			Message: i18n.Tc(c, "Failed to list chats"),
+		})
+		return
+	}
+	if scoped {
+		visible := chats[:0]
+		for _, chat := range chats {
+			if inScope(c, chat.ChatID) && len(visible) < limit {
+				visible = append(visible, chat)
+			}
+		}
+		chats = visible
+	}
+
+	c.JSON(http.StatusOK, models.ChatListResponse{
+		Chats: chats,
+		Total: len(chats),
+	})
+}
+
+// resolveChatTitles narrows a request with title_contains to the IDs of the
+// chats whose title contains the phrase, intersected with any chat_ids.
+// matched is false when no chat qualifies; ok is false when an error
+// response was written.
+func (h *APIHandler) resolveChatTitles(c *gin.Context, req *models.SearchRequest) (matched bool, ok bool) {
+	title := strings.TrimSpace(req.TitleContains)
+	if title == "" {
+		return true, true
+	}
+
+	chats, err := h.engineFor(c).ListChats(engineContext(c), title, maxResolvedChats)
+	if err != nil {
+		middleware.Log(c).WithError(err).Error("Failed to resolve chat titles")
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "Internal Server Error",
+			Message: i18n.Tc(c, "Failed to resolve chat titles"),
+		})
+		return false, false
+	}
+	if len(chats) == maxResolvedChats {
+		middleware.Log(c).WithField("title_contains", title).Warn("Chat title matches too many chats, only the most active are searched")
+	}
+
+	requested := make(map[int64]bool, len(req.ChatIDs))
+	for _, chatID := range req.ChatIDs {
+		requested[chatID] = true
+	}
+
+	chatIDs := make([]int64, 0, len(chats))
+	for _, chat := range chats {
+		if len(requested) == 0 || requested[chat.ChatID] {
+			chatIDs = append(chatIDs, chat.ChatID)
+		}
+	}
+	req.ChatIDs = chatIDs
+
+	return len(chatIDs) > 0, true
+}
+
+// TopMessages returns the most-reacted messages in a chat over a recent period
+// GET /api/v1/chats/:chat_id/top?period=7d&limit=10
+func (h *APIHandler) TopMessages(c *gin.Context) {
+	startTime := time.Now()
+
+	chatID, err := strconv.ParseInt(c.Param("chat_id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Bad Request",
+			Message: i18n.Tc(c, "Invalid chat_id"),
+		})
+		return
+	}
+	if !allowChat(c, chatID) {
+		return
+	}
+
+	period, err := parsePeriod(c.DefaultQuery("period", "7d"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Bad Request",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "10"))
+	if err != nil || limit < 1 {
+		limit = 10
+	}
+	if limit > maxTopLimit {
+		limit = maxTopLimit
+	}
+
+	since := time.Now().Add(-period).Unix()
+	req := models.SearchRequest{
+		ChatID:       &chatID,
+		DateFrom:     models.NewDateBound(since),
+		SortBy:       models.SortByReactions,
+		MinReactions: 1,
+		Page:         1,
+		PageSize:     limit,
+		Ctx:          c.Request.Context(),
+	}
+
+	result, err := h.engineFor(c).Search(&req)
+	if err != nil {
+		middleware.Log(c).WithError(err).Error("Top messages query failed")
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "Internal Server Error",
+			Message: i18n.Tc(c, "Failed to retrieve top messages"),
+		})
+		return
+	}
+
+	result.TookMs = time.Since(startTime).Milliseconds()
+	c.JSON(http.StatusOK, result)
+}
+
+// parsePeriod parses lookback periods like "24h", "7d" or "4w".
+// Plain Go durations ("90m") are accepted as well.
+func parsePeriod(period string) (time.Duration, error) {
+	period = strings.TrimSpace(period)
+	if period == "" {
+		return 0, fmt.Errorf("period is required")
+	}
+
+	unit := period[len(period)-1]
+	if unit == 'd' || unit == 'w' {
+		n, err := strconv.Atoi(period[:len(period)-1])
+		if err != nil || n <= 0 {
+			return 0, fmt.Errorf("invalid period: %s", period)
+		}
+		days := n
+		if unit == 'w' {
+			days = n * 7
+		}
+		return time.Duration(days) * 24 * time.Hour, nil
+	}
+
+	d, err := time.ParseDuration(period)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("invalid period: %s (use e.g. 24h, 7d, 4w)", period)
+	}
+	return d, nil
+}
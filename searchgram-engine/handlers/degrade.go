@@ -0,0 +1,13 @@
+package handlers
+
+import "github.com/zhishengyuan/searchgram-engine/degrade"
+
+// degradedHeader lists what was shed from a search served under load
+const degradedHeader = "X-Search-Degraded"
+
+// SetDegradation enables load-based degradation of searches; while degraded,
+// page sizes are capped at pageSize and totals are approximate
+func (h *APIHandler) SetDegradation(governor *degrade.Governor, pageSize int) {
+	h.degrade = governor
+	h.degradedPageSize = pageSize
+}
@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/zhishengyuan/searchgram-engine/audit"
+	"github.com/zhishengyuan/searchgram-engine/dictionary"
+	"github.com/zhishengyuan/searchgram-engine/i18n"
+	"github.com/zhishengyuan/searchgram-engine/middleware"
+	"github.com/zhishengyuan/searchgram-engine/models"
+)
+
+// SetDictionary enables listing and reloading the dictionary of domain terms
+func (h *APIHandler) SetDictionary(dict *dictionary.Dictionary) {
+	h.dictionary = dict
+}
+
+// requireDictionary writes a 404 when the dictionary is disabled
+func (h *APIHandler) requireDictionary(c *gin.Context) bool {
+	if h.dictionary == nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error:   "Not Found",
+			Message: i18n.Tc(c, "The dictionary is not enabled"),
+		})
+		return false
+	}
+	return true
+}
+
+// GetDictionary lists the terms of the dictionary
+// GET /api/v1/dictionary
+func (h *APIHandler) GetDictionary(c *gin.Context) {
+	if !h.requireDictionary(c) {
+		return
+	}
+
+	terms := h.dictionary.Terms()
+	c.JSON(http.StatusOK, gin.H{
+		"terms":     terms,
+		"count":     len(terms),
+		"loaded_at": h.dictionary.LoadedAt().Unix(),
+	})
+}
+
+// ReloadDictionary reads the dictionary file again. New terms apply to
+// searches and to messages indexed from now on.
+// POST /api/v1/dictionary/reload
+func (h *APIHandler) ReloadDictionary(c *gin.Context) {
+	if !h.requireDictionary(c) {
+		return
+	}
+
+	count, err := h.dictionary.Reload()
+	if err != nil {
+		middleware.Log(c).WithError(err).Error("Failed to reload dictionary")
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "Internal Server Error",
+			Message: i18n.Tc(c, "Failed to reload the dictionary"),
+		})
+		return
+	}
+
+	h.audit.Record(audit.Entry{
+		Action: "dictionary.reload",
+		Actor:  callerID(c),
+		IP:     c.ClientIP(),
+		Detail: map[string]interface{}{
+			"terms": count,
+		},
+	})
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"count":   count,
+	})
+}
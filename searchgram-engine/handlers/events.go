@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/zhishengyuan/searchgram-engine/audit"
+	"github.com/zhishengyuan/searchgram-engine/events"
+	"github.com/zhishengyuan/searchgram-engine/i18n"
+	"github.com/zhishengyuan/searchgram-engine/middleware"
+	"github.com/zhishengyuan/searchgram-engine/models"
+)
+
+// SetEvents enables lifecycle event status and dead-letter redelivery
+func (h *APIHandler) SetEvents(bus *events.Bus) {
+	h.events = bus
+}
+
+// requireEvents writes a 404 when lifecycle events are disabled
+func (h *APIHandler) requireEvents(c *gin.Context) bool {
+	if h.events == nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error:   "Not Found",
+			Message: i18n.Tc(c, "Lifecycle events are not enabled"),
+		})
+		return false
+	}
+	return true
+}
+
+// EventsStatus returns lifecycle event delivery counters
+// GET /api/v1/events
+func (h *APIHandler) EventsStatus(c *gin.Context) {
+	if !h.requireEvents(c) {
+		return
+	}
+
+	c.JSON(http.StatusOK, h.events.Stats())
+}
+
+// RedeliverEvents queues the events of the dead-letter log for another
+// delivery, e.g. once a failed sink is back
+// POST /api/v1/events/dead-letters/redeliver
+func (h *APIHandler) RedeliverEvents(c *gin.Context) {
+	if !h.requireEvents(c) {
+		return
+	}
+
+	queued, err := h.events.Redeliver()
+	if err != nil {
+		middleware.Log(c).WithError(err).Error("Failed to redeliver dead-lettered events")
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "Internal Server Error",
+			Message: i18n.Tc(c, "Failed to redeliver dead-lettered events"),
+		})
+		return
+	}
+
+	h.audit.Record(audit.Entry{
+		Action: "events.redeliver",
+		Actor:  callerID(c),
+		IP:     c.ClientIP(),
+		Detail: map[string]interface{}{
+			"queued": queued,
+		},
+	})
+
+	c.JSON(http.StatusOK, models.EventRedeliverResult{Queued: queued})
+}
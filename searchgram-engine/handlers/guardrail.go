@@ -0,0 +1,122 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+	"github.com/zhishengyuan/searchgram-engine/i18n"
+	"github.com/zhishengyuan/searchgram-engine/middleware"
+	"github.com/zhishengyuan/searchgram-engine/models"
+)
+
+// SetDeleteThreshold sets how many messages a delete or clear may affect
+// before it must be forced (0 disables the guardrail)
+func (h *APIHandler) SetDeleteThreshold(threshold int64) {
+	h.deleteThreshold = threshold
+}
+
+// clearConfirmTTL is how long a clear confirmation token can be redeemed
+const clearConfirmTTL = 60 * time.Second
+
+// clearConfirmation is an outstanding confirmation token of a clear
+type clearConfirmation struct {
+	token   string
+	caller  string
+	expires time.Time
+}
+
+// SetClearEnabled enables or disables DELETE /clear
+func (h *APIHandler) SetClearEnabled(enabled bool) {
+	h.clearDisabled = !enabled
+}
+
+// issueClearConfirmation returns a new one-time token confirming a clear
+// by caller, replacing the caller's previous one
+func (h *APIHandler) issueClearConfirmation(caller string) (*clearConfirmation, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return nil, err
+	}
+	confirmation := &clearConfirmation{
+		token:   hex.EncodeToString(buf),
+		caller:  caller,
+		expires: time.Now().Add(clearConfirmTTL),
+	}
+
+	h.clearMu.Lock()
+	defer h.clearMu.Unlock()
+	if h.clearConfirmations == nil {
+		h.clearConfirmations = make(map[string]*clearConfirmation)
+	}
+	h.clearConfirmations[caller] = confirmation
+	return confirmation, nil
+}
+
+// redeemClearConfirmation reports whether token is the caller's unexpired
+// confirmation token and consumes it, so each token clears at most once
+func (h *APIHandler) redeemClearConfirmation(caller, token string) bool {
+	h.clearMu.Lock()
+	defer h.clearMu.Unlock()
+
+	confirmation, ok := h.clearConfirmations[caller]
+	if !ok || subtle.ConstantTimeCompare([]byte(confirmation.token), []byte(token)) != 1 {
+		return false
+	}
+	delete(h.clearConfirmations, caller)
+	return time.Now().Before(confirmation.expires)
+}
+
+// forceRequested reports whether a DELETE request carries force=true
+func forceRequested(c *gin.Context) bool {
+	return c.Query("force") == "true"
+}
+
+// guardDelete estimates how many messages an operation affects and refuses
+// it with 428 when the estimate exceeds the threshold and force is not set.
+// It returns false when the request was answered.
+func (h *APIHandler) guardDelete(c *gin.Context, force bool, estimate func() (int64, error)) bool {
+	if h.deleteThreshold <= 0 || force {
+		return true
+	}
+
+	count, err := estimate()
+	if err != nil {
+		middleware.Log(c).WithError(err).Error("Failed to estimate affected messages")
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "Internal Server Error",
+			Message: i18n.Tc(c, "Failed to estimate affected messages"),
+		})
+		return false
+	}
+	if count <= h.deleteThreshold {
+		return true
+	}
+
+	middleware.Log(c).WithFields(log.Fields{
+		"path":      c.Request.URL.Path,
+		"estimated": count,
+		"threshold": h.deleteThreshold,
+	}).Warn("Refusing unforced delete above threshold")
+
+	c.JSON(http.StatusPreconditionRequired, models.DeleteGuardResponse{
+		Error:          "Precondition Required",
+		Message:        i18n.Tc(c, "This would delete about %d messages, more than the %d allowed without confirmation; repeat the request with force=true to proceed", count, h.deleteThreshold),
+		EstimatedCount: count,
+		Threshold:      h.deleteThreshold,
+	})
+	return false
+}
+
+// countMatching counts messages matching search filters, soft-deleted ones
+// only with IncludeDeleted. Chat and user deletes set it, as they act on
+// every message of the chat or user, soft-deleted ones included.
+func (h *APIHandler) countMatching(req *models.SearchRequest) func() (int64, error) {
+	return func() (int64, error) {
+		return h.engine.DeleteByQuery(req, true)
+	}
+}
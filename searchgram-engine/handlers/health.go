@@ -0,0 +1,137 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/zhishengyuan/searchgram-engine/middleware"
+	"github.com/zhishengyuan/searchgram-engine/models"
+)
+
+// Readiness defaults, for handlers SetReadiness was not called on
+const (
+	defaultReadyTimeout   = 5 * time.Second
+	defaultQueueThreshold = 0.9
+)
+
+// SetReadiness sets how long the engine may take to answer a readiness
+// check, and how full the ingest queue may get before the instance is
+// reported not ready so it stops receiving traffic while the queue drains
+func (h *APIHandler) SetReadiness(timeout time.Duration, queueThreshold float64) {
+	h.readyTimeout = timeout
+	h.queueThreshold = queueThreshold
+}
+
+// Live handles liveness probes: the process is up and serving requests
+// GET /health/live
+func (h *APIHandler) Live(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"status": "alive",
+	})
+}
+
+// Ready handles readiness probes. It pings the engine and checks the
+// backlog of the ingest queue, the event bus and replication, answering 503
+// while the engine is unreachable or the ingest queue is too full to take
+// writes. Event and replication backlogs only degrade the report.
+// GET /health/ready, GET /health
+func (h *APIHandler) Ready(c *gin.Context) {
+	threshold := h.queueThreshold
+	if threshold <= 0 {
+		threshold = defaultQueueThreshold
+	}
+
+	checks := map[string]models.HealthCheck{
+		"engine": h.checkEngine(c),
+	}
+	if stats := h.queue.Stats(); stats != nil {
+		check := backlogCheck(stats.Depth, stats.Capacity, threshold)
+		if check.Status != models.HealthUp {
+			// A full queue rejects upserts
+			check.Status = models.HealthDown
+		}
+		checks["ingest_queue"] = check
+	}
+	if stats := h.events.Stats(); stats != nil {
+		checks["events"] = backlogCheck(stats.Pending, stats.Capacity, threshold)
+	}
+	if stats := h.replicator.Stats(); stats != nil {
+		check := backlogCheck(stats.Pending, stats.Capacity, threshold)
+		switch {
+		case !stats.Connected:
+			check.Status = models.HealthDegraded
+			check.Message = "Secondary engine not reached"
+		case !stats.InSync:
+			check.Status = models.HealthDegraded
+			check.Message = "Secondary engine needs a resync"
+		}
+		checks["replication"] = check
+	}
+
+	response := models.ReadinessResponse{Status: "ready", Checks: checks}
+	status := http.StatusOK
+	for _, check := range checks {
+		if check.Status == models.HealthDown {
+			response.Status = "not_ready"
+			status = http.StatusServiceUnavailable
+		}
+	}
+	c.JSON(status, response)
+}
+
+// checkEngine pings the engine, giving up after the readiness timeout. The
+// probe is unauthenticated, so failures are only detailed in the log.
+func (h *APIHandler) checkEngine(c *gin.Context) models.HealthCheck {
+	timeout := h.readyTimeout
+	if timeout <= 0 {
+		timeout = defaultReadyTimeout
+	}
+
+	type result struct {
+		ping *models.PingResponse
+		err  error
+	}
+	done := make(chan result, 1)
+	start := time.Now()
+	go func() {
+		ping, err := h.engine.Ping()
+		done <- result{ping, err}
+	}()
+
+	select {
+	case r := <-done:
+		check := models.HealthCheck{Status: models.HealthUp, LatencyMs: millis(time.Since(start))}
+		if r.err != nil || r.ping == nil || r.ping.Status != "ok" {
+			if r.err != nil {
+				middleware.Log(c).WithError(r.err).Warn("Readiness check: engine ping failed")
+			}
+			check.Status = models.HealthDown
+			check.Message = "Search engine is not available"
+		}
+		return check
+	case <-time.After(timeout):
+		middleware.Log(c).WithField("timeout", timeout.String()).Warn("Readiness check: engine ping timed out")
+		return models.HealthCheck{
+			Status:    models.HealthDown,
+			LatencyMs: millis(timeout),
+			Message:   fmt.Sprintf("Search engine did not answer within %s", timeout),
+		}
+	}
+}
+
+// backlogCheck reports a queue as degraded once it is threshold full
+func backlogCheck(pending, capacity int, threshold float64) models.HealthCheck {
+	check := models.HealthCheck{Status: models.HealthUp, Pending: pending, Capacity: capacity}
+	if capacity > 0 && float64(pending) >= threshold*float64(capacity) {
+		check.Status = models.HealthDegraded
+		check.Message = fmt.Sprintf("%d%% full", pending*100/capacity)
+	}
+	return check
+}
+
+// millis converts a duration to fractional milliseconds
+func millis(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+	"github.com/zhishengyuan/searchgram-engine/i18n"
+	"github.com/zhishengyuan/searchgram-engine/ingest"
+	"github.com/zhishengyuan/searchgram-engine/middleware"
+	"github.com/zhishengyuan/searchgram-engine/models"
+)
+
+// errIndexing marks an engine failure while importing, as opposed to a malformed export
+var errIndexing = errors.New("indexing failed")
+
+// ImportTelegramExport indexes the result.json of Telegram Desktop's "Export
+// chat history". The body is streamed and bulk-indexed maxBatchSize messages
+// at a time, so exports of any size can be imported.
+// POST /api/v1/import/telegram-export
+func (h *APIHandler) ImportTelegramExport(c *gin.Context) {
+	batchSize := h.maxBatchSize
+	if batchSize <= 0 {
+		batchSize = defaultMaxBatchSize
+	}
+
+	total := models.ImportResponse{}
+	var indexErr error
+
+	batch := make([]models.Message, 0, batchSize)
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		result, err := h.indexBatch(c, batch)
+		if err != nil {
+			indexErr = err
+			return errIndexing
+		}
+		total.IndexedCount += result.IndexedCount
+		total.FailedCount += result.FailedCount
+		total.SkippedCount += result.SkippedCount
+		for _, msg := range result.Errors {
+			if len(total.Errors) < maxReportedErrors {
+				total.Errors = append(total.Errors, msg)
+			}
+		}
+		batch = batch[:0]
+		return nil
+	}
+
+	stats, err := ingest.ImportDesktopExport(c.Request.Body, func(message models.Message) error {
+		batch = append(batch, message)
+		if len(batch) >= batchSize {
+			return flush()
+		}
+		return nil
+	})
+	if err == nil {
+		err = flush()
+	}
+	total.Chats = stats.Chats
+	total.Messages = stats.Messages
+	total.Ignored = stats.Ignored
+
+	if errors.Is(err, errIndexing) {
+		h.streamFailed(c, indexErr, models.BatchUpsertResponse{IndexedCount: total.IndexedCount})
+		return
+	}
+	if err != nil {
+		middleware.Log(c).WithError(err).WithField("indexed", total.IndexedCount).Warn("Invalid Telegram Desktop export")
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Bad Request",
+			Message: i18n.Tc(c, "invalid Telegram Desktop export: %v (indexed %d so far)", err, total.IndexedCount),
+		})
+		return
+	}
+
+	middleware.Log(c).WithFields(log.Fields{
+		"chats":   total.Chats,
+		"indexed": total.IndexedCount,
+		"failed":  total.FailedCount,
+		"ignored": total.Ignored,
+	}).Info("Imported Telegram Desktop export")
+
+	total.Success = total.FailedCount == 0
+	c.JSON(http.StatusOK, total)
+}
@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/zhishengyuan/searchgram-engine/i18n"
+	"github.com/zhishengyuan/searchgram-engine/jobs"
+	"github.com/zhishengyuan/searchgram-engine/models"
+)
+
+// Job types started by the API handler
+const (
+	jobTypeDedup             = "dedup"
+	jobTypeClear             = "clear"
+	jobTypeBackfill          = "backfill"
+	jobTypeRetentionPurge    = "retention_purge"
+	jobTypeReplicationResync = "replication_resync"
+	jobTypeBackup            = "backup"
+	jobTypeRestore           = "restore"
+	jobTypeSnapshotMount     = "snapshot_mount"
+	jobTypeReindex           = "reindex"
+)
+
+// jobConflict refuses to start a job because job, of the same type, is
+// still running
+func jobConflict(c *gin.Context, job *jobs.Job) {
+	c.Header("Location", "/api/v1/jobs/"+job.ID)
+	c.JSON(http.StatusConflict, models.JobConflictResponse{
+		Error:   "Conflict",
+		Message: i18n.Tc(c, "A %s job is already running", job.Type),
+		JobID:   job.ID,
+	})
+}
+
+// runningJob returns the running job of a type, or nil when none is
+func (h *APIHandler) runningJob(jobType string) *jobs.Job {
+	running := h.jobs.List(jobs.Filter{Type: jobType, Status: jobs.StatusRunning})
+	if len(running) == 0 {
+		return nil
+	}
+	return running[0]
+}
+
+// ListJobs lists background jobs, newest first
+// GET /api/v1/jobs?type=dedup&status=running
+func (h *APIHandler) ListJobs(c *gin.Context) {
+	list := h.jobs.List(jobs.Filter{
+		Type:   c.Query("type"),
+		Status: jobs.Status(c.Query("status")),
+	})
+
+	c.JSON(http.StatusOK, gin.H{
+		"jobs":  list,
+		"count": len(list),
+	})
+}
+
+// GetJob returns the state of a background job
+// GET /api/v1/jobs/:id
+func (h *APIHandler) GetJob(c *gin.Context) {
+	job, ok := h.jobs.Get(c.Param("id"))
+	if !ok {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error:   "Not Found",
+			Message: i18n.Tc(c, "Job not found"),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, job)
+}
+
+// CancelJob requests cancellation of a running job
+// DELETE /api/v1/jobs/:id
+func (h *APIHandler) CancelJob(c *gin.Context) {
+	id := c.Param("id")
+
+	switch err := h.jobs.Cancel(id); err {
+	case nil:
+	case jobs.ErrNotFound:
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error:   "Not Found",
+			Message: err.Error(),
+		})
+		return
+	case jobs.ErrNotRunning:
+		c.JSON(http.StatusConflict, models.ErrorResponse{
+			Error:   "Conflict",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	job, _ := h.jobs.Get(id)
+	c.JSON(http.StatusAccepted, job)
+}
@@ -0,0 +1,173 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/zhishengyuan/searchgram-engine/audit"
+	"github.com/zhishengyuan/searchgram-engine/i18n"
+	"github.com/zhishengyuan/searchgram-engine/legalhold"
+	"github.com/zhishengyuan/searchgram-engine/middleware"
+	"github.com/zhishengyuan/searchgram-engine/models"
+)
+
+// SetLegalHolds enables placing chats under legal hold
+func (h *APIHandler) SetLegalHolds(holds *legalhold.Registry) {
+	h.legalHolds = holds
+}
+
+// requireLegalHolds writes a 404 when legal holds are disabled
+func (h *APIHandler) requireLegalHolds(c *gin.Context) bool {
+	if h.legalHolds == nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error:   "Not Found",
+			Message: i18n.Tc(c, "Legal holds are not enabled"),
+		})
+		return false
+	}
+	return true
+}
+
+// refuseHeld writes a 423 when err is a delete refused by a legal hold
+func refuseHeld(c *gin.Context, err error) bool {
+	if !errors.Is(err, legalhold.ErrHeld) {
+		return false
+	}
+	middleware.Log(c).WithError(err).Warn("Delete refused by legal hold")
+	c.JSON(http.StatusLocked, models.ErrorResponse{
+		Error:   "Locked",
+		Message: i18n.Tc(c, "This would delete messages of chats under legal hold"),
+	})
+	return true
+}
+
+// refuseWhileHeld writes a 423 when any chat is held, for deletes of the
+// whole index that run as background jobs
+func (h *APIHandler) refuseWhileHeld(c *gin.Context) bool {
+	if h.legalHolds == nil || !h.legalHolds.Any() {
+		return false
+	}
+	return refuseHeld(c, legalhold.ErrHeld)
+}
+
+// refuseOperationWhileHeld writes a 423 when any chat is held and records
+// the refused operation, for operations that can leave held messages behind
+func (h *APIHandler) refuseOperationWhileHeld(c *gin.Context, operation string) bool {
+	if !h.refuseWhileHeld(c) {
+		return false
+	}
+	h.audit.Record(audit.Entry{
+		Action: "legal_hold.refuse",
+		Actor:  callerID(c),
+		IP:     c.ClientIP(),
+		Detail: map[string]interface{}{
+			"operation": operation,
+		},
+	})
+	return true
+}
+
+// ListLegalHolds lists the chats under legal hold
+// GET /api/v1/legal-holds
+func (h *APIHandler) ListLegalHolds(c *gin.Context) {
+	if !h.requireLegalHolds(c) {
+		return
+	}
+
+	list := h.legalHolds.List()
+	c.JSON(http.StatusOK, gin.H{
+		"holds": list,
+		"count": len(list),
+	})
+}
+
+// PlaceLegalHold keeps all messages of a chat from being deleted until the
+// hold is released
+// PUT /api/v1/legal-holds/:chat_id
+func (h *APIHandler) PlaceLegalHold(c *gin.Context) {
+	if !h.requireLegalHolds(c) {
+		return
+	}
+
+	chatID, ok := retentionChatID(c)
+	if !ok {
+		return
+	}
+
+	var req models.LegalHoldRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		middleware.Log(c).WithError(err).Warn("Invalid legal hold request")
+		c.JSON(http.StatusBadRequest, validationError(c, err))
+		return
+	}
+
+	hold, err := h.legalHolds.Hold(chatID, req.Reason, callerID(c))
+	if err != nil {
+		middleware.Log(c).WithError(err).Error("Failed to save legal hold")
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "Internal Server Error",
+			Message: i18n.Tc(c, "Failed to save legal hold"),
+		})
+		return
+	}
+
+	h.audit.Record(audit.Entry{
+		Action: "legal_hold.place",
+		Actor:  callerID(c),
+		IP:     c.ClientIP(),
+		Detail: map[string]interface{}{
+			"chat_id": chatID,
+			"reason":  req.Reason,
+		},
+	})
+
+	c.JSON(http.StatusOK, hold)
+}
+
+// ReleaseLegalHold lets the messages of a held chat be deleted again
+// DELETE /api/v1/legal-holds/:chat_id
+func (h *APIHandler) ReleaseLegalHold(c *gin.Context) {
+	if !h.requireLegalHolds(c) {
+		return
+	}
+
+	chatID, ok := retentionChatID(c)
+	if !ok {
+		return
+	}
+
+	hold, err := h.legalHolds.Release(chatID)
+	if err != nil {
+		if err == legalhold.ErrNotFound {
+			c.JSON(http.StatusNotFound, models.ErrorResponse{
+				Error:   "Not Found",
+				Message: i18n.Tc(c, "Chat %d is not under legal hold", chatID),
+			})
+			return
+		}
+		middleware.Log(c).WithError(err).Error("Failed to release legal hold")
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "Internal Server Error",
+			Message: i18n.Tc(c, "Failed to release legal hold"),
+		})
+		return
+	}
+
+	h.audit.Record(audit.Entry{
+		Action: "legal_hold.release",
+		Actor:  callerID(c),
+		IP:     c.ClientIP(),
+		Detail: map[string]interface{}{
+			"chat_id": chatID,
+			"reason":  hold.Reason,
+			"held_by": hold.HeldBy,
+			"held_at": hold.HeldAt,
+		},
+	})
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"chat_id": chatID,
+	})
+}
@@ -0,0 +1,141 @@
+package handlers
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/zhishengyuan/searchgram-engine/engines"
+	"github.com/zhishengyuan/searchgram-engine/models"
+)
+
+const (
+	// maxMergeRun bounds how many messages on each side of a hit are looked
+	// at when merging it with its run
+	maxMergeRun = 20
+
+	// mergeConcurrency bounds the context lookups of one search running at
+	// the same time
+	mergeConcurrency = 8
+)
+
+// mergeRuns merges every hit with the consecutive messages its sender sent
+// around it, each within window seconds of the one before, and drops later
+// hits belonging to a run already shown. A message from anyone else ends a
+// run. Hits whose context cannot be fetched are kept unmerged.
+func mergeRuns(ctx context.Context, engine engines.SearchEngine, result *models.SearchResponse, window int64) {
+	runs := make([]*models.MergedHit, len(result.Hits))
+	sem := make(chan struct{}, mergeConcurrency)
+	var wg sync.WaitGroup
+	for i := range result.Hits {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			runs[i] = mergeRun(ctx, engine, &result.Hits[i], window)
+		}(i)
+	}
+	wg.Wait()
+
+	result.Merged = make(map[string]models.MergedHit)
+	shown := make(map[string]bool)
+	hits := result.Hits[:0]
+	for i, hit := range result.Hits {
+		if shown[hit.ID] {
+			continue
+		}
+		hits = append(hits, hit)
+		run := runs[i]
+		if run == nil {
+			continue
+		}
+		for _, messageID := range run.MessageIDs {
+			shown[models.MessageDocumentID(hit.ChatID, messageID)] = true
+		}
+		if len(run.MessageIDs) > 1 {
+			result.Merged[hit.ID] = *run
+		}
+	}
+	result.Hits = hits
+}
+
+// mergeRun returns the run of messages around hit, or nil when its context
+// cannot be fetched
+func mergeRun(ctx context.Context, engine engines.SearchEngine, hit *models.Message, window int64) *models.MergedHit {
+	chatID, messageID, err := models.ParseMessageID(hit.ID)
+	if err != nil {
+		return nil
+	}
+	before, after, err := engine.GetMessageContext(ctx, chatID, messageID, maxMergeRun, maxMergeRun)
+	if err != nil {
+		log.WithError(err).WithField("id", hit.ID).Warn("Failed to fetch context for merging")
+		return nil
+	}
+
+	run := []models.Message{*hit}
+	previous := hit
+	for i := len(before) - 1; i >= 0; i-- {
+		if !continuesRun(&before[i], previous, window) {
+			break
+		}
+		run = append([]models.Message{before[i]}, run...)
+		previous = &before[i]
+	}
+	previous = hit
+	for i := range after {
+		if !continuesRun(previous, &after[i], window) {
+			break
+		}
+		run = append(run, after[i])
+		previous = &after[i]
+	}
+
+	merged := &models.MergedHit{
+		FromTimestamp: messageTime(&run[0]),
+		ToTimestamp:   messageTime(&run[len(run)-1]),
+	}
+	var texts []string
+	for i := range run {
+		// Use the composite ID rather than the stored field, which legacy
+		// documents may lack
+		_, id, _ := models.ParseMessageID(run[i].ID)
+		merged.MessageIDs = append(merged.MessageIDs, id)
+		if text := runText(&run[i]); text != "" {
+			texts = append(texts, text)
+		}
+	}
+	merged.Text = strings.Join(texts, "\n")
+	return merged
+}
+
+// continuesRun reports whether next was sent by the sender of previous
+// within window seconds of it
+func continuesRun(previous, next *models.Message, window int64) bool {
+	if next.SenderID != previous.SenderID {
+		return false
+	}
+	gap := messageTime(next) - messageTime(previous)
+	return gap >= 0 && gap <= window
+}
+
+// messageTime returns when a message was sent; legacy documents only carry
+// date
+func messageTime(message *models.Message) int64 {
+	if message.Timestamp != 0 {
+		return message.Timestamp
+	}
+	return message.Date
+}
+
+// runText returns the text a message contributes to a merged run
+func runText(message *models.Message) string {
+	if text := strings.TrimSpace(message.Text); text != "" {
+		return text
+	}
+	if message.Caption != nil {
+		return strings.TrimSpace(*message.Caption)
+	}
+	return strings.TrimSpace(message.Transcript)
+}
@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/zhishengyuan/searchgram-engine/i18n"
+	"github.com/zhishengyuan/searchgram-engine/middleware"
+	"github.com/zhishengyuan/searchgram-engine/models"
+)
+
+const (
+	defaultContextSize = 5
+	maxContextSize     = 50
+)
+
+// GetMessage returns a single message by composite ID
+// GET /api/v1/messages/:id
+func (h *APIHandler) GetMessage(c *gin.Context) {
+	message, ok := h.lookupMessage(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, message)
+}
+
+// MessageContext returns a message together with its neighbours in the same chat
+// GET /api/v1/messages/:id/context?before=5&after=5
+func (h *APIHandler) MessageContext(c *gin.Context) {
+	before, err := parseContextSize(c.DefaultQuery("before", strconv.Itoa(defaultContextSize)))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Bad Request",
+			Message: "Invalid before: " + err.Error(),
+		})
+		return
+	}
+	after, err := parseContextSize(c.DefaultQuery("after", strconv.Itoa(defaultContextSize)))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Bad Request",
+			Message: "Invalid after: " + err.Error(),
+		})
+		return
+	}
+
+	message, ok := h.lookupMessage(c)
+	if !ok {
+		return
+	}
+
+	// Use the composite ID rather than the stored fields, which legacy documents may lack
+	chatID, messageID, _ := models.ParseMessageID(c.Param("id"))
+
+	beforeMessages, afterMessages, err := h.engineFor(c).GetMessageContext(engineContext(c), chatID, messageID, before, after)
+	if err != nil {
+		middleware.Log(c).WithError(err).Error("Failed to fetch message context")
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "Internal Server Error",
+			Message: i18n.Tc(c, "Failed to fetch message context"),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.MessageContextResponse{
+		Message: message,
+		Before:  beforeMessages,
+		After:   afterMessages,
+	})
+}
+
+// lookupMessage loads the message named by the :id parameter, writing an
+// error response and returning false when it is invalid or missing
+func (h *APIHandler) lookupMessage(c *gin.Context) (*models.Message, bool) {
+	id := c.Param("id")
+	chatID, _, err := models.ParseMessageID(id)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Bad Request",
+			Message: err.Error(),
+		})
+		return nil, false
+	}
+	if !allowChat(c, chatID) {
+		return nil, false
+	}
+
+	message, err := h.engineFor(c).GetMessage(engineContext(c), id)
+	if err != nil {
+		middleware.Log(c).WithError(err).Error("Failed to get message")
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "Internal Server Error",
+			Message: i18n.Tc(c, "Failed to get message"),
+		})
+		return nil, false
+	}
+	if message == nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error:   "Not Found",
+			Message: i18n.Tc(c, "Message %s not found", id),
+		})
+		return nil, false
+	}
+
+	return message, true
+}
+
+// parseContextSize parses a before/after count, capping it at maxContextSize
+func parseContextSize(value string) (int, error) {
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("must be a non-negative integer")
+	}
+	if n > maxContextSize {
+		n = maxContextSize
+	}
+	return n, nil
+}
@@ -0,0 +1,150 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/zhishengyuan/searchgram-engine/i18n"
+	"github.com/zhishengyuan/searchgram-engine/jwt"
+	"github.com/zhishengyuan/searchgram-engine/middleware"
+	"github.com/zhishengyuan/searchgram-engine/models"
+	"github.com/zhishengyuan/searchgram-engine/profiles"
+	"github.com/zhishengyuan/searchgram-engine/tenants"
+	"github.com/zhishengyuan/searchgram-engine/usage"
+)
+
+// SetProfiles enables per-caller search profiles merged into searches
+func (h *APIHandler) SetProfiles(store *profiles.Store) {
+	h.profiles = store
+}
+
+// requireProfiles writes a 404 when search profiles are disabled
+func (h *APIHandler) requireProfiles(c *gin.Context) bool {
+	if h.profiles == nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error:   "Not Found",
+			Message: i18n.Tc(c, "Search profiles are not enabled"),
+		})
+		return false
+	}
+	return true
+}
+
+// callerID identifies the caller a search profile belongs to: the JWT
+// subject, else the tenant, else the JWT issuer, else the name of a scoped
+// API key, else the shared API key
+func callerID(c *gin.Context) string {
+	if value, ok := c.Get("jwt_claims"); ok {
+		if claims, ok := value.(*jwt.Claims); ok && claims.Subject != "" {
+			return claims.Subject
+		}
+	}
+	if tenant := c.GetString(tenants.ContextKey); tenant != "" {
+		return tenant
+	}
+	if issuer := c.GetString("jwt_issuer"); issuer != "" {
+		return issuer
+	}
+	if name := c.GetString(middleware.APIKeyNameKey); name != "" {
+		return name
+	}
+	return usage.DefaultTenant
+}
+
+// GetProfile returns the caller's search profile
+// GET /api/v1/profile
+func (h *APIHandler) GetProfile(c *gin.Context) {
+	if !h.requireProfiles(c) {
+		return
+	}
+
+	caller := callerID(c)
+	profile, ok := h.profiles.Get(caller)
+	if !ok {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error:   "Not Found",
+			Message: i18n.Tc(c, "No search profile for %s", caller),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, profile)
+}
+
+// PutProfile replaces the caller's search profile
+// PUT /api/v1/profile
+func (h *APIHandler) PutProfile(c *gin.Context) {
+	if !h.requireProfiles(c) {
+		return
+	}
+
+	var req models.SearchProfile
+	if err := c.ShouldBindJSON(&req); err != nil {
+		middleware.Log(c).WithError(err).Warn("Invalid search profile")
+		c.JSON(http.StatusBadRequest, validationError(c, err))
+		return
+	}
+	if !models.ValidSortBy(req.SortBy) {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Bad Request",
+			Message: i18n.Tc(c, "unsupported sort_by: %s", req.SortBy),
+		})
+		return
+	}
+
+	profile, err := h.profiles.Put(callerID(c), req)
+	if err != nil {
+		middleware.Log(c).WithError(err).Error("Failed to save search profile")
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "Internal Server Error",
+			Message: i18n.Tc(c, "Failed to save search profile"),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, profile)
+}
+
+// DeleteProfile removes the caller's search profile
+// DELETE /api/v1/profile
+func (h *APIHandler) DeleteProfile(c *gin.Context) {
+	if !h.requireProfiles(c) {
+		return
+	}
+
+	caller := callerID(c)
+	if err := h.profiles.Delete(caller); err != nil {
+		if err == profiles.ErrNotFound {
+			c.JSON(http.StatusNotFound, models.ErrorResponse{
+				Error:   "Not Found",
+				Message: i18n.Tc(c, "No search profile for %s", caller),
+			})
+			return
+		}
+		middleware.Log(c).WithError(err).Error("Failed to delete search profile")
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "Internal Server Error",
+			Message: i18n.Tc(c, "Failed to delete search profile"),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"caller":  caller,
+	})
+}
+
+// ListProfiles lists the search profiles of all callers
+// GET /api/v1/profiles
+func (h *APIHandler) ListProfiles(c *gin.Context) {
+	if !h.requireProfiles(c) {
+		return
+	}
+
+	list := h.profiles.List()
+	c.JSON(http.StatusOK, gin.H{
+		"profiles": list,
+		"count":    len(list),
+	})
+}
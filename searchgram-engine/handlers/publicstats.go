@@ -0,0 +1,123 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/zhishengyuan/searchgram-engine/i18n"
+	"github.com/zhishengyuan/searchgram-engine/middleware"
+	"github.com/zhishengyuan/searchgram-engine/models"
+	"github.com/zhishengyuan/searchgram-engine/publicstats"
+)
+
+const (
+	// defaultActivityDays and defaultTrendingDays are the periods served
+	// when none is requested
+	defaultActivityDays = 30
+	defaultTrendingDays = 7
+
+	// maxTrendingTerms bounds the terms of each kind served
+	maxTrendingTerms = 50
+)
+
+// SetPublicStats enables the unauthenticated stats of published chats
+func (h *APIHandler) SetPublicStats(publisher *publicstats.Publisher) {
+	h.publicStats = publisher
+}
+
+// publishedChatID reads the chat_id path parameter, writing a 404 when
+// public stats are disabled or the chat is not published. Unpublished and
+// unknown chats are answered alike.
+func (h *APIHandler) publishedChatID(c *gin.Context) (int64, bool) {
+	if h.publicStats == nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error:   "Not Found",
+			Message: i18n.Tc(c, "Public stats are not enabled"),
+		})
+		return 0, false
+	}
+
+	chatID, err := strconv.ParseInt(c.Param("chat_id"), 10, 64)
+	if err != nil || !h.publicStats.Published(chatID) {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error:   "Not Found",
+			Message: i18n.Tc(c, "No public stats for chat %s", c.Param("chat_id")),
+		})
+		return 0, false
+	}
+	return chatID, true
+}
+
+// publicStatsDays reads the days query parameter, clamped to the maximum
+func (h *APIHandler) publicStatsDays(c *gin.Context, fallback int) int {
+	days, err := strconv.Atoi(c.Query("days"))
+	if err != nil || days < 1 {
+		days = fallback
+	}
+	if days > h.publicStats.MaxDays() {
+		days = h.publicStats.MaxDays()
+	}
+	return days
+}
+
+// PublicChatActivity returns a chat's noised message and sender counts per
+// day or week
+// GET /api/v1/public/stats/chats/:chat_id/activity?days=30&interval=day
+func (h *APIHandler) PublicChatActivity(c *gin.Context) {
+	chatID, ok := h.publishedChatID(c)
+	if !ok {
+		return
+	}
+
+	interval := c.DefaultQuery("interval", models.ActivityIntervalDay)
+	if interval != models.ActivityIntervalDay && interval != models.ActivityIntervalWeek {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Bad Request",
+			Message: i18n.Tc(c, "interval must be day or week"),
+		})
+		return
+	}
+
+	activity, err := h.publicStats.Activity(c.Request.Context(), chatID, h.publicStatsDays(c, defaultActivityDays), interval)
+	if err != nil {
+		middleware.Log(c).WithError(err).Error("Failed to get public chat activity")
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "Internal Server Error",
+			Message: i18n.Tc(c, "Failed to retrieve statistics"),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, activity)
+}
+
+// PublicTrendingTerms returns the hashtags and words a chat used unusually
+// often, leaving out terms used by too few people
+// GET /api/v1/public/stats/chats/:chat_id/trending?days=7&limit=20
+func (h *APIHandler) PublicTrendingTerms(c *gin.Context) {
+	chatID, ok := h.publishedChatID(c)
+	if !ok {
+		return
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if err != nil || limit < 1 {
+		limit = 20
+	}
+	if limit > maxTrendingTerms {
+		limit = maxTrendingTerms
+	}
+
+	trending, err := h.publicStats.Trending(c.Request.Context(), chatID, h.publicStatsDays(c, defaultTrendingDays), limit)
+	if err != nil {
+		middleware.Log(c).WithError(err).Error("Failed to get public trending terms")
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "Internal Server Error",
+			Message: i18n.Tc(c, "Failed to retrieve statistics"),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, trending)
+}
@@ -0,0 +1,140 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+	"github.com/zhishengyuan/searchgram-engine/audit"
+	"github.com/zhishengyuan/searchgram-engine/engines"
+	"github.com/zhishengyuan/searchgram-engine/i18n"
+	"github.com/zhishengyuan/searchgram-engine/jobs"
+	"github.com/zhishengyuan/searchgram-engine/middleware"
+	"github.com/zhishengyuan/searchgram-engine/models"
+)
+
+// Reindex starts copying all messages into a new index with the current
+// mappings as a background job, switching to it when done
+// POST /api/v1/admin/reindex
+func (h *APIHandler) Reindex(c *gin.Context) {
+	if h.refuseOperationWhileHeld(c, "reindex") {
+		return
+	}
+
+	// Only one reindex at a time
+	job, started := h.jobs.StartExclusive(jobTypeReindex, func(ctx context.Context, update func(progress interface{})) (interface{}, error) {
+		log.Info("Starting reindex...")
+		return h.engine.Reindex(ctx, func(progress *models.ReindexProgress) {
+			update(progress)
+		})
+	})
+	if !started {
+		jobConflict(c, job)
+		return
+	}
+
+	h.audit.Record(audit.Entry{
+		Action: "index.reindex",
+		Actor:  callerID(c),
+		IP:     c.ClientIP(),
+		Detail: map[string]interface{}{
+			"job_id": job.ID,
+		},
+	})
+
+	c.Header("Location", "/api/v1/jobs/"+job.ID)
+	c.JSON(http.StatusAccepted, job)
+}
+
+// ListIndexVersions lists the versions of the message index, newest first
+// GET /api/v1/index/versions
+func (h *APIHandler) ListIndexVersions(c *gin.Context) {
+	list, err := h.engine.ListIndexVersions(c.Request.Context())
+	if err != nil {
+		middleware.Log(c).WithError(err).Error("Failed to list index versions")
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "Internal Server Error",
+			Message: i18n.Tc(c, "Failed to list index versions"),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"versions": list,
+		"count":    len(list),
+	})
+}
+
+// RollbackIndex switches searches and writes to another version of the
+// message index, by default the one before the active version
+// POST /api/v1/index/rollback
+func (h *APIHandler) RollbackIndex(c *gin.Context) {
+	// The body is optional
+	var req models.IndexRollbackRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		middleware.Log(c).WithError(err).Warn("Invalid rollback request")
+		c.JSON(http.StatusBadRequest, validationError(c, err))
+		return
+	}
+
+	if h.refuseOperationWhileHeld(c, "index_rollback") {
+		return
+	}
+
+	// A running reindex switches the alias from the version it copies
+	running := h.jobs.List(jobs.Filter{Type: jobTypeReindex, Status: jobs.StatusRunning})
+	if len(running) > 0 {
+		c.JSON(http.StatusConflict, models.ErrorResponse{
+			Error:   "Conflict",
+			Message: i18n.Tc(c, "Wait for the running reindex to finish"),
+		})
+		return
+	}
+
+	result, err := h.engine.RollbackIndex(c.Request.Context(), req.Index)
+	if refuseHeld(c, err) {
+		return
+	}
+	switch {
+	case errors.Is(err, engines.ErrIndexNotVersioned):
+		c.JSON(http.StatusConflict, models.ErrorResponse{
+			Error:   "Conflict",
+			Message: i18n.Tc(c, "The index is not versioned yet; run a reindex first"),
+		})
+		return
+	case errors.Is(err, engines.ErrIndexVersionNotFound) && req.Index == "":
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error:   "Not Found",
+			Message: i18n.Tc(c, "No older index version to roll back to"),
+		})
+		return
+	case errors.Is(err, engines.ErrIndexVersionNotFound):
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error:   "Not Found",
+			Message: i18n.Tc(c, "Index version %s not found", req.Index),
+		})
+		return
+	case err != nil:
+		middleware.Log(c).WithError(err).Error("Failed to roll back index")
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "Internal Server Error",
+			Message: i18n.Tc(c, "Failed to roll back the index"),
+		})
+		return
+	}
+
+	h.audit.Record(audit.Entry{
+		Action: "index.rollback",
+		Actor:  callerID(c),
+		IP:     c.ClientIP(),
+		Detail: map[string]interface{}{
+			"from": result.From,
+			"to":   result.To,
+		},
+	})
+
+	c.JSON(http.StatusOK, result)
+}
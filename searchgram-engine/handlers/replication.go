@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+	"github.com/zhishengyuan/searchgram-engine/audit"
+	"github.com/zhishengyuan/searchgram-engine/i18n"
+	"github.com/zhishengyuan/searchgram-engine/models"
+	"github.com/zhishengyuan/searchgram-engine/replication"
+)
+
+// SetReplicator enables replication status and resyncs
+func (h *APIHandler) SetReplicator(replicator *replication.Replicator) {
+	h.replicator = replicator
+}
+
+// requireReplication writes a 404 when replication is disabled
+func (h *APIHandler) requireReplication(c *gin.Context) bool {
+	if h.replicator == nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error:   "Not Found",
+			Message: i18n.Tc(c, "Replication is not enabled"),
+		})
+		return false
+	}
+	return true
+}
+
+// ReplicationStatus returns replication lag and counters
+// GET /api/v1/replication
+func (h *APIHandler) ReplicationStatus(c *gin.Context) {
+	if !h.requireReplication(c) {
+		return
+	}
+
+	c.JSON(http.StatusOK, h.replicator.Stats())
+}
+
+// ResyncReplication starts copying every message to the secondary engine as
+// a background job, replacing its contents
+// POST /api/v1/replication/resync
+func (h *APIHandler) ResyncReplication(c *gin.Context) {
+	if !h.requireReplication(c) {
+		return
+	}
+
+	job, started := h.jobs.StartExclusive(jobTypeReplicationResync, func(ctx context.Context, update func(progress interface{})) (interface{}, error) {
+		log.Info("Starting replication resync...")
+		return h.replicator.Resync(ctx, func(progress *models.ReplicationResyncResult) {
+			update(progress)
+		})
+	})
+	if !started {
+		jobConflict(c, job)
+		return
+	}
+
+	h.audit.Record(audit.Entry{
+		Action: "replication.resync",
+		Actor:  callerID(c),
+		IP:     c.ClientIP(),
+		Detail: map[string]interface{}{
+			"job_id": job.ID,
+		},
+	})
+
+	c.Header("Location", "/api/v1/jobs/"+job.ID)
+	c.JSON(http.StatusAccepted, job)
+}
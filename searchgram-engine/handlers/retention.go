@@ -0,0 +1,169 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+	"github.com/zhishengyuan/searchgram-engine/audit"
+	"github.com/zhishengyuan/searchgram-engine/i18n"
+	"github.com/zhishengyuan/searchgram-engine/middleware"
+	"github.com/zhishengyuan/searchgram-engine/models"
+	"github.com/zhishengyuan/searchgram-engine/retention"
+)
+
+// SetRetention enables per-chat retention policies
+func (h *APIHandler) SetRetention(manager *retention.Manager) {
+	h.retention = manager
+}
+
+// requireRetention writes a 404 when retention is disabled
+func (h *APIHandler) requireRetention(c *gin.Context) bool {
+	if h.retention == nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error:   "Not Found",
+			Message: i18n.Tc(c, "Retention is not enabled"),
+		})
+		return false
+	}
+	return true
+}
+
+// retentionChatID parses the chat_id path parameter, writing a 400 when invalid
+func retentionChatID(c *gin.Context) (int64, bool) {
+	chatID, err := strconv.ParseInt(c.Param("chat_id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Bad Request",
+			Message: i18n.Tc(c, "Invalid chat_id"),
+		})
+		return 0, false
+	}
+	return chatID, true
+}
+
+// ListRetention returns the default retention and all chat policies
+// GET /api/v1/retention
+func (h *APIHandler) ListRetention(c *gin.Context) {
+	if !h.requireRetention(c) {
+		return
+	}
+
+	c.JSON(http.StatusOK, h.retention.List())
+}
+
+// PutRetention sets the retention of a chat
+// PUT /api/v1/retention/chats/:chat_id
+func (h *APIHandler) PutRetention(c *gin.Context) {
+	if !h.requireRetention(c) {
+		return
+	}
+
+	chatID, ok := retentionChatID(c)
+	if !ok {
+		return
+	}
+
+	var req models.RetentionPolicyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		middleware.Log(c).WithError(err).Warn("Invalid retention policy")
+		c.JSON(http.StatusBadRequest, validationError(c, err))
+		return
+	}
+
+	policy, err := h.retention.Put(chatID, *req.Days)
+	if err != nil {
+		middleware.Log(c).WithError(err).Error("Failed to save retention policy")
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "Internal Server Error",
+			Message: i18n.Tc(c, "Failed to save retention policy"),
+		})
+		return
+	}
+
+	h.audit.Record(audit.Entry{
+		Action: "retention.update",
+		Actor:  callerID(c),
+		IP:     c.ClientIP(),
+		Detail: map[string]interface{}{
+			"chat_id": chatID,
+			"days":    policy.Days,
+		},
+	})
+
+	c.JSON(http.StatusOK, policy)
+}
+
+// DeleteRetention removes the policy set for a chat via the API
+// DELETE /api/v1/retention/chats/:chat_id
+func (h *APIHandler) DeleteRetention(c *gin.Context) {
+	if !h.requireRetention(c) {
+		return
+	}
+
+	chatID, ok := retentionChatID(c)
+	if !ok {
+		return
+	}
+
+	if err := h.retention.Delete(chatID); err != nil {
+		if err == retention.ErrNotFound {
+			c.JSON(http.StatusNotFound, models.ErrorResponse{
+				Error:   "Not Found",
+				Message: i18n.Tc(c, "No retention policy for chat %d", chatID),
+			})
+			return
+		}
+		middleware.Log(c).WithError(err).Error("Failed to delete retention policy")
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "Internal Server Error",
+			Message: i18n.Tc(c, "Failed to delete retention policy"),
+		})
+		return
+	}
+
+	h.audit.Record(audit.Entry{
+		Action: "retention.delete",
+		Actor:  callerID(c),
+		IP:     c.ClientIP(),
+		Detail: map[string]interface{}{
+			"chat_id": chatID,
+		},
+	})
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"chat_id": chatID,
+	})
+}
+
+// PurgeRetention starts a retention purge now instead of waiting for the schedule
+// POST /api/v1/retention/purge
+func (h *APIHandler) PurgeRetention(c *gin.Context) {
+	if !h.requireRetention(c) {
+		return
+	}
+
+	job, started := h.jobs.StartExclusive(jobTypeRetentionPurge, func(ctx context.Context, update func(progress interface{})) (interface{}, error) {
+		log.Info("Starting retention purge...")
+		return h.retention.Purge(ctx)
+	})
+	if !started {
+		jobConflict(c, job)
+		return
+	}
+
+	h.audit.Record(audit.Entry{
+		Action: "retention.purge",
+		Actor:  callerID(c),
+		IP:     c.ClientIP(),
+		Detail: map[string]interface{}{
+			"job_id": job.ID,
+		},
+	})
+
+	c.Header("Location", "/api/v1/jobs/"+job.ID)
+	c.JSON(http.StatusAccepted, job)
+}
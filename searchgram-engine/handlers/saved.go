@@ -0,0 +1,372 @@
+package handlers
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/zhishengyuan/searchgram-engine/audit"
+	"github.com/zhishengyuan/searchgram-engine/i18n"
+	"github.com/zhishengyuan/searchgram-engine/middleware"
+	"github.com/zhishengyuan/searchgram-engine/models"
+	"github.com/zhishengyuan/searchgram-engine/saved"
+)
+
+const (
+	// maxDigestMessages bounds the messages listed in a Saved Messages digest
+	maxDigestMessages = 100
+
+	// liveSavedBatch is the number of tagged messages looked up at once when
+	// counting tags
+	liveSavedBatch = 500
+
+	// digestSnippetLength is the length of a digest entry's snippet in
+	// characters
+	digestSnippetLength = 200
+)
+
+// SetSaved enables the Saved Messages endpoints and tags in search results
+func (h *APIHandler) SetSaved(store *saved.Store) {
+	h.saved = store
+}
+
+// requireSaved writes a 404 when Saved Messages support is disabled
+func (h *APIHandler) requireSaved(c *gin.Context) bool {
+	if h.saved == nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error:   "Not Found",
+			Message: i18n.Tc(c, "Saved Messages support is not enabled"),
+		})
+		return false
+	}
+	return true
+}
+
+// savedUserID parses the user_id path parameter, writing a 400 when invalid
+// and a 403 when the caller's scope excludes the user's Saved Messages
+func savedUserID(c *gin.Context) (int64, bool) {
+	userID, err := strconv.ParseInt(c.Param("user_id"), 10, 64)
+	if err != nil || userID <= 0 {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Bad Request",
+			Message: i18n.Tc(c, "Invalid user_id"),
+		})
+		return 0, false
+	}
+	if !allowChat(c, userID) {
+		return 0, false
+	}
+	return userID, true
+}
+
+// savedRequest returns a search of a user's Saved Messages
+func savedRequest(userID int64) models.SearchRequest {
+	return models.SearchRequest{
+		ChatID:   &userID,
+		ChatType: models.SavedMessagesChatType,
+	}
+}
+
+// SearchSaved searches a user's Saved Messages, optionally only those with
+// a tag
+// POST /api/v1/saved/:user_id/search
+func (h *APIHandler) SearchSaved(c *gin.Context) {
+	startTime := time.Now()
+	if !h.requireSaved(c) {
+		return
+	}
+	userID, ok := savedUserID(c)
+	if !ok {
+		return
+	}
+
+	var req models.SavedSearchRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		middleware.Log(c).WithError(err).Warn("Invalid saved messages search request")
+		c.JSON(http.StatusBadRequest, validationError(c, err))
+		return
+	}
+	if req.Snapshot != "" {
+		c.JSON(http.StatusForbidden, models.ErrorResponse{
+			Error:   "Forbidden",
+			Message: i18n.Tc(c, "Snapshots are searched via POST /api/v1/search/snapshot"),
+		})
+		return
+	}
+
+	// Other chat filters would only contradict the user's chat
+	base := savedRequest(userID)
+	req.ChatID = base.ChatID
+	req.ChatType = base.ChatType
+	req.ChatIDs = nil
+	req.TitleContains = ""
+
+	if req.Tag != "" {
+		req.MessageIDs = h.saved.WithTag(userID, req.Tag)
+		if len(req.MessageIDs) == 0 {
+			// No message carries the tag, so nothing can match
+			c.JSON(http.StatusOK, models.SearchResponse{
+				Hits:        []models.Message{},
+				Page:        max(req.Page, 1),
+				HitsPerPage: req.PageSize,
+				TookMs:      time.Since(startTime).Milliseconds(),
+				Sort:        models.SortOrder(req.SortBy),
+			})
+			return
+		}
+	}
+
+	h.search(c, startTime, &req.SearchRequest)
+}
+
+// PutSavedTags replaces the tags of a saved message
+// PUT /api/v1/saved/:user_id/messages/:message_id/tags
+func (h *APIHandler) PutSavedTags(c *gin.Context) {
+	if !h.requireSaved(c) {
+		return
+	}
+	userID, ok := savedUserID(c)
+	if !ok {
+		return
+	}
+	messageID, err := strconv.ParseInt(c.Param("message_id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Bad Request",
+			Message: i18n.Tc(c, "Invalid message_id"),
+		})
+		return
+	}
+
+	var req models.SavedTagsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		middleware.Log(c).WithError(err).Warn("Invalid saved message tags")
+		c.JSON(http.StatusBadRequest, validationError(c, err))
+		return
+	}
+	if len(saved.Normalize(req.Tags)) > h.saved.MaxTags() {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Bad Request",
+			Message: i18n.Tc(c, "A message may carry at most %d tags", h.saved.MaxTags()),
+		})
+		return
+	}
+
+	id := models.MessageDocumentID(userID, messageID)
+	message, err := h.engineFor(c).GetMessage(engineContext(c), id)
+	if err != nil {
+		middleware.Log(c).WithError(err).Error("Failed to get message")
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "Internal Server Error",
+			Message: i18n.Tc(c, "Failed to get message"),
+		})
+		return
+	}
+	if message == nil || !saved.IsSavedMessage(message) {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error:   "Not Found",
+			Message: i18n.Tc(c, "Message %s is not in the Saved Messages of user %d", id, userID),
+		})
+		return
+	}
+
+	tags, err := h.saved.Set(id, req.Tags)
+	if err != nil {
+		middleware.Log(c).WithError(err).Error("Failed to save saved message tags")
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "Internal Server Error",
+			Message: i18n.Tc(c, "Failed to save tags"),
+		})
+		return
+	}
+
+	h.audit.Record(audit.Entry{
+		Action: "saved.tags",
+		Actor:  callerID(c),
+		IP:     c.ClientIP(),
+		Detail: map[string]interface{}{
+			"id":   id,
+			"tags": tags.Tags,
+		},
+	})
+
+	c.JSON(http.StatusOK, tags)
+}
+
+// SavedStats describes a user's Saved Messages: how many there are, their
+// tags and how long they are kept
+// GET /api/v1/saved/:user_id/stats
+func (h *APIHandler) SavedStats(c *gin.Context) {
+	if !h.requireSaved(c) {
+		return
+	}
+	userID, ok := savedUserID(c)
+	if !ok {
+		return
+	}
+
+	req := savedRequest(userID)
+	req.Page = 1
+	req.PageSize = 1
+	req.Ctx = c.Request.Context()
+	result, err := h.engineFor(c).Search(&req)
+	if err != nil {
+		middleware.Log(c).WithError(err).Error("Saved messages stats query failed")
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "Internal Server Error",
+			Message: i18n.Tc(c, "Failed to retrieve Saved Messages stats"),
+		})
+		return
+	}
+
+	stats := models.SavedStats{
+		UserID:   userID,
+		Messages: result.TotalHits,
+	}
+	live, err := h.liveSavedMessages(c, userID, h.saved.Tagged(userID))
+	if err != nil {
+		middleware.Log(c).WithError(err).Error("Saved messages stats query failed")
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "Internal Server Error",
+			Message: i18n.Tc(c, "Failed to retrieve Saved Messages stats"),
+		})
+		return
+	}
+	stats.Tags, stats.Tagged = h.saved.Counts(userID, live)
+	if len(result.Hits) > 0 {
+		stats.LastSavedAt = messageTime(&result.Hits[0])
+	}
+	if h.retention != nil {
+		days := h.retention.Days(userID)
+		stats.RetentionDays = &days
+	}
+
+	c.JSON(http.StatusOK, stats)
+}
+
+// liveSavedMessages returns which of a user's tagged messages are still
+// searchable, leaving out deleted and trashed ones
+func (h *APIHandler) liveSavedMessages(c *gin.Context, userID int64, ids []string) (map[string]bool, error) {
+	live := make(map[string]bool, len(ids))
+	for start := 0; start < len(ids); start += liveSavedBatch {
+		end := start + liveSavedBatch
+		if end > len(ids) {
+			end = len(ids)
+		}
+		req := savedRequest(userID)
+		req.MessageIDs = ids[start:end]
+		req.Page = 1
+		req.PageSize = end - start
+		req.Ctx = c.Request.Context()
+		result, err := h.engineFor(c).Search(&req)
+		if err != nil {
+			return nil, err
+		}
+		for i := range result.Hits {
+			live[result.Hits[i].ID] = true
+		}
+	}
+	return live, nil
+}
+
+// SavedDigest lists what a user saved during a period, grouped by tag
+// GET /api/v1/saved/:user_id/digest?period=7d
+func (h *APIHandler) SavedDigest(c *gin.Context) {
+	if !h.requireSaved(c) {
+		return
+	}
+	userID, ok := savedUserID(c)
+	if !ok {
+		return
+	}
+
+	period, err := parsePeriod(c.DefaultQuery("period", "7d"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Bad Request",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	since := time.Now().Add(-period).Unix()
+	req := savedRequest(userID)
+	req.DateFrom = models.NewDateBound(since)
+	req.SortBy = models.SortByTimestamp
+	req.Page = 1
+	req.PageSize = maxDigestMessages
+	req.Ctx = c.Request.Context()
+	result, err := h.engineFor(c).Search(&req)
+	if err != nil {
+		middleware.Log(c).WithError(err).Error("Saved messages digest query failed")
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "Internal Server Error",
+			Message: i18n.Tc(c, "Failed to build Saved Messages digest"),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SavedDigest{
+		UserID:    userID,
+		Since:     since,
+		Messages:  result.TotalHits,
+		Truncated: result.TotalHits > int64(len(result.Hits)),
+		Groups:    digestGroups(result.Hits, h.saved.Annotate(result.Hits)),
+	})
+}
+
+// digestGroups groups messages by tag, largest group first; a message with
+// several tags is listed under each, untagged ones last
+func digestGroups(messages []models.Message, tags map[string][]string) []models.SavedDigestGroup {
+	byTag := make(map[string][]models.SavedDigestEntry)
+	var untagged []models.SavedDigestEntry
+	for i := range messages {
+		entry := digestEntry(&messages[i], tags[messages[i].ID])
+		if len(entry.Tags) == 0 {
+			untagged = append(untagged, entry)
+			continue
+		}
+		for _, tag := range entry.Tags {
+			byTag[tag] = append(byTag[tag], entry)
+		}
+	}
+
+	groups := make([]models.SavedDigestGroup, 0, len(byTag)+1)
+	for tag, entries := range byTag {
+		groups = append(groups, models.SavedDigestGroup{Tag: tag, Messages: entries})
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		if len(groups[i].Messages) != len(groups[j].Messages) {
+			return len(groups[i].Messages) > len(groups[j].Messages)
+		}
+		return groups[i].Tag < groups[j].Tag
+	})
+	if len(untagged) > 0 {
+		groups = append(groups, models.SavedDigestGroup{Messages: untagged})
+	}
+	return groups
+}
+
+// digestEntry summarises a saved message for a digest
+func digestEntry(message *models.Message, tags []string) models.SavedDigestEntry {
+	// Use the composite ID rather than the stored field, which legacy
+	// documents may lack
+	_, messageID, _ := models.ParseMessageID(message.ID)
+	entry := models.SavedDigestEntry{
+		ID:        message.ID,
+		MessageID: messageID,
+		Timestamp: messageTime(message),
+		Snippet:   runText(message),
+		URLs:      message.URLs,
+		Tags:      tags,
+	}
+	if snippet := []rune(entry.Snippet); len(snippet) > digestSnippetLength {
+		entry.Snippet = string(snippet[:digestSnippetLength]) + "…"
+	}
+	if message.ForwardFromName != nil {
+		entry.ForwardFromName = *message.ForwardFromName
+	}
+	return entry
+}
@@ -0,0 +1,35 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/zhishengyuan/searchgram-engine/i18n"
+	"github.com/zhishengyuan/searchgram-engine/models"
+	"github.com/zhishengyuan/searchgram-engine/scheduler"
+)
+
+// SetScheduler enables listing scheduled maintenance tasks
+func (h *APIHandler) SetScheduler(s *scheduler.Scheduler, location *time.Location) {
+	h.scheduler = s
+	h.scheduleLocation = location
+}
+
+// ListSchedule lists the scheduled tasks with their next and last runs;
+// the jobs they started are listed by /api/v1/jobs
+// GET /api/v1/schedule
+func (h *APIHandler) ListSchedule(c *gin.Context) {
+	if h.scheduler == nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error:   "Not Found",
+			Message: i18n.Tc(c, "The scheduler is not enabled"),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.ScheduleResponse{
+		Timezone: h.scheduleLocation.String(),
+		Tasks:    h.scheduler.List(),
+	})
+}
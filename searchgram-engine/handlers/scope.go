@@ -0,0 +1,66 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/zhishengyuan/searchgram-engine/i18n"
+	"github.com/zhishengyuan/searchgram-engine/middleware"
+	"github.com/zhishengyuan/searchgram-engine/models"
+)
+
+// chatScope returns the chats a scoped API key is limited to; ok is false
+// for callers that are not limited
+func chatScope(c *gin.Context) (chatIDs []int64, ok bool) {
+	if value, exists := c.Get(middleware.ChatScopeKey); exists {
+		chatIDs, ok = value.([]int64)
+	}
+	return chatIDs, ok
+}
+
+// inScope reports whether the caller may access the messages of a chat
+func inScope(c *gin.Context, chatID int64) bool {
+	scope, ok := chatScope(c)
+	if !ok {
+		return true
+	}
+	for _, id := range scope {
+		if id == chatID {
+			return true
+		}
+	}
+	return false
+}
+
+// allowChat writes a 403 unless the caller may access the messages of a chat
+func allowChat(c *gin.Context, chatID int64) bool {
+	if inScope(c, chatID) {
+		return true
+	}
+	c.JSON(http.StatusForbidden, models.ErrorResponse{
+		Error:   "Forbidden",
+		Message: i18n.Tc(c, "Chat %d is outside the scope of this API key", chatID),
+	})
+	return false
+}
+
+// scopeSearch limits a search or delete to the chats of a scoped API key,
+// writing a 403 when it names other chats
+func scopeSearch(c *gin.Context, req *models.SearchRequest) bool {
+	scope, ok := chatScope(c)
+	if !ok {
+		return true
+	}
+	if req.ChatID != nil && !allowChat(c, *req.ChatID) {
+		return false
+	}
+	for _, chatID := range req.ChatIDs {
+		if !allowChat(c, chatID) {
+			return false
+		}
+	}
+	if len(req.ChatIDs) == 0 {
+		req.ChatIDs = append([]int64(nil), scope...)
+	}
+	return true
+}
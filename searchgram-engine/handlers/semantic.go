@@ -0,0 +1,132 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/zhishengyuan/searchgram-engine/enrich"
+	"github.com/zhishengyuan/searchgram-engine/i18n"
+	"github.com/zhishengyuan/searchgram-engine/middleware"
+	"github.com/zhishengyuan/searchgram-engine/models"
+)
+
+// SetEmbedder enables semantic search, embedding queries with the same
+// model that embeds messages at ingest
+func (h *APIHandler) SetEmbedder(embedder *enrich.Embedder) {
+	h.embedder = embedder
+}
+
+// HybridOptions are the server defaults for hybrid semantic search; requests
+// may override each of them
+type HybridOptions struct {
+	LexicalWeight  float64 // Weight of the keyword ranking in the fusion
+	SemanticWeight float64 // Weight of the vector ranking in the fusion
+	Rerank         bool    // A cross-encoder reranker is configured
+	RerankTopK     int     // Fused results to rerank (0 = off)
+}
+
+// SetHybridOptions sets the fusion weights and reranking defaults
+func (h *APIHandler) SetHybridOptions(options HybridOptions) {
+	h.hybrid = options
+}
+
+// embedQuery validates a semantic search and sets its query vector. It
+// returns false when an error response was written.
+func (h *APIHandler) embedQuery(c *gin.Context, req *models.SearchRequest) bool {
+	if !req.Semantic {
+		return true
+	}
+
+	if h.embedder == nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Bad Request",
+			Message: i18n.Tc(c, "Semantic search is not enabled"),
+		})
+		return false
+	}
+	if strings.TrimSpace(req.Keyword) == "" {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Bad Request",
+			Message: i18n.Tc(c, "semantic search requires a keyword"),
+		})
+		return false
+	}
+	if req.BoostBy != "" || (req.SortBy != "" && req.SortBy != models.SortByRelevance) {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Bad Request",
+			Message: i18n.Tc(c, "semantic search ranks by similarity and cannot be combined with sort_by or boost_by"),
+		})
+		return false
+	}
+
+	if !h.applyHybridOptions(c, req) {
+		return false
+	}
+
+	vector, err := h.embedder.Embed(c.Request.Context(), req.Keyword)
+	if err != nil {
+		middleware.Log(c).WithError(err).Error("Failed to embed search query")
+		c.JSON(http.StatusBadGateway, models.ErrorResponse{
+			Error:   "Bad Gateway",
+			Message: i18n.Tc(c, "Failed to embed the search query"),
+		})
+		return false
+	}
+
+	req.QueryVector = vector
+	req.SortBy = models.SortByRelevance
+	return true
+}
+
+// applyHybridOptions fills the fusion and reranking parameters of a hybrid
+// search from the server defaults. It returns false when an error response
+// was written.
+func (h *APIHandler) applyHybridOptions(c *gin.Context, req *models.SearchRequest) bool {
+	if req.SemanticMode == "" {
+		req.SemanticMode = models.SemanticHybrid
+	}
+
+	if req.SemanticMode != models.SemanticHybrid {
+		if req.LexicalWeight != nil || req.SemanticWeight != nil || req.RerankTopK != nil {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{
+				Error:   "Bad Request",
+				Message: i18n.Tc(c, "lexical_weight, semantic_weight and rerank_top_k require semantic_mode hybrid"),
+			})
+			return false
+		}
+		return true
+	}
+
+	if req.LexicalWeight == nil {
+		weight := h.hybrid.LexicalWeight
+		req.LexicalWeight = &weight
+	}
+	if req.SemanticWeight == nil {
+		weight := h.hybrid.SemanticWeight
+		req.SemanticWeight = &weight
+	}
+	if *req.LexicalWeight == 0 && *req.SemanticWeight == 0 {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Bad Request",
+			Message: i18n.Tc(c, "lexical_weight and semantic_weight cannot both be 0"),
+		})
+		return false
+	}
+
+	if req.RerankTopK == nil {
+		topK := 0
+		if h.hybrid.Rerank {
+			topK = h.hybrid.RerankTopK
+		}
+		req.RerankTopK = &topK
+	}
+	if *req.RerankTopK > 0 && !h.hybrid.Rerank {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Bad Request",
+			Message: i18n.Tc(c, "Reranking is not enabled"),
+		})
+		return false
+	}
+	return true
+}
@@ -0,0 +1,138 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/zhishengyuan/searchgram-engine/audit"
+	"github.com/zhishengyuan/searchgram-engine/i18n"
+	"github.com/zhishengyuan/searchgram-engine/jwt"
+	"github.com/zhishengyuan/searchgram-engine/middleware"
+	"github.com/zhishengyuan/searchgram-engine/models"
+	"github.com/zhishengyuan/searchgram-engine/sessions"
+)
+
+// SetSessions enables cookie sessions for the web dashboard
+func (h *APIHandler) SetSessions(store *sessions.Store) {
+	h.sessions = store
+}
+
+// SetAuditLog records security-relevant API actions
+func (h *APIHandler) SetAuditLog(auditLog *audit.Log) {
+	h.audit = auditLog
+}
+
+// requireSessions writes a 404 when dashboard sessions are disabled
+func (h *APIHandler) requireSessions(c *gin.Context) bool {
+	if h.sessions == nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error:   "Not Found",
+			Message: i18n.Tc(c, "Sessions are not enabled"),
+		})
+		return false
+	}
+	return true
+}
+
+// requestSession returns the session a request was authenticated with
+func requestSession(c *gin.Context) (*sessions.Session, bool) {
+	value, ok := c.Get(sessions.ContextKey)
+	if !ok {
+		return nil, false
+	}
+	session, ok := value.(*sessions.Session)
+	return session, ok
+}
+
+// sessionResponse builds the client view of a session
+func sessionResponse(session *sessions.Session) models.SessionResponse {
+	return models.SessionResponse{
+		CSRFToken: session.CSRFToken,
+		Issuer:    session.Issuer,
+		Role:      session.Role,
+		ExpiresAt: session.ExpiresAt.Unix(),
+	}
+}
+
+// CreateSession exchanges the API key or JWT of the request for a session
+// cookie. Called with an existing session, it replaces that session.
+// POST /api/v1/auth/session
+func (h *APIHandler) CreateSession(c *gin.Context) {
+	if !h.requireSessions(c) {
+		return
+	}
+
+	issuer := c.GetString("jwt_issuer")
+	var claims *jwt.Claims
+	if value, ok := c.Get("jwt_claims"); ok {
+		claims, _ = value.(*jwt.Claims)
+	}
+
+	if previous, ok := requestSession(c); ok {
+		h.sessions.Delete(previous.ID)
+	}
+
+	session, err := h.sessions.Create(issuer, middleware.CredentialRole(c), claims)
+	if err != nil {
+		middleware.Log(c).WithError(err).Error("Failed to create session")
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "Internal Server Error",
+			Message: i18n.Tc(c, "Failed to create session"),
+		})
+		return
+	}
+
+	h.sessions.SetCookie(c, session)
+	h.audit.Record(audit.Entry{
+		Action: "session.create",
+		Actor:  callerID(c),
+		IP:     c.ClientIP(),
+		Detail: map[string]interface{}{
+			"expires_at": session.ExpiresAt.Unix(),
+		},
+	})
+
+	c.JSON(http.StatusCreated, sessionResponse(session))
+}
+
+// GetSession returns the current session, so a reloaded dashboard can
+// recover its CSRF token
+// GET /api/v1/auth/session
+func (h *APIHandler) GetSession(c *gin.Context) {
+	if !h.requireSessions(c) {
+		return
+	}
+
+	session, ok := requestSession(c)
+	if !ok {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error:   "Not Found",
+			Message: i18n.Tc(c, "No active session"),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, sessionResponse(session))
+}
+
+// DeleteSession ends the current session and clears its cookie
+// DELETE /api/v1/auth/session
+func (h *APIHandler) DeleteSession(c *gin.Context) {
+	if !h.requireSessions(c) {
+		return
+	}
+
+	if session, ok := requestSession(c); ok {
+		h.sessions.Delete(session.ID)
+		h.audit.Record(audit.Entry{
+			Action: "session.delete",
+			Actor:  callerID(c),
+			IP:     c.ClientIP(),
+		})
+	}
+	h.sessions.ClearCookie(c)
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+	})
+}
@@ -0,0 +1,66 @@
+package handlers
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/zhishengyuan/searchgram-engine/i18n"
+	"github.com/zhishengyuan/searchgram-engine/middleware"
+	"github.com/zhishengyuan/searchgram-engine/models"
+	"github.com/zhishengyuan/searchgram-engine/signedurl"
+)
+
+// signedURLPrefix is the API prefix signable routes live under
+const signedURLPrefix = "/api/v1"
+
+// SetURLSigner enables signed download URLs, returned relative to baseURL
+// (the engine's public address; empty returns bare paths)
+func (h *APIHandler) SetURLSigner(signer *signedurl.Signer, baseURL string) {
+	h.urlSigner = signer
+	h.signedURLBase = strings.TrimSuffix(baseURL, "/")
+}
+
+// SignURL returns a time-limited URL for a download that works without the
+// API key or JWT, carrying the caller's identity
+// POST /api/v1/signed-urls
+func (h *APIHandler) SignURL(c *gin.Context) {
+	if h.urlSigner == nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error:   "Not Found",
+			Message: i18n.Tc(c, "Signed URLs are not enabled"),
+		})
+		return
+	}
+
+	var req models.SignURLRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		middleware.Log(c).WithError(err).Warn("Invalid signed URL request")
+		c.JSON(http.StatusBadRequest, validationError(c, err))
+		return
+	}
+
+	target, err := url.Parse(req.Path)
+	route, ok := "", false
+	if err == nil && !target.IsAbs() && target.Host == "" {
+		route, ok = strings.CutPrefix(target.Path, signedURLPrefix)
+	}
+	if !ok || !h.urlSigner.Signable(route) {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Bad Request",
+			Message: i18n.Tc(c, "path %s cannot be signed", req.Path),
+		})
+		return
+	}
+
+	ttl := h.urlSigner.ClampTTL(time.Duration(req.TTLSeconds) * time.Second)
+	expiresAt := time.Now().Add(ttl)
+	signed := h.urlSigner.Sign(target.Path, target.Query(), c.GetString("jwt_issuer"), middleware.CredentialRole(c), expiresAt)
+
+	c.JSON(http.StatusOK, models.SignURLResponse{
+		URL:       h.signedURLBase + signed,
+		ExpiresAt: expiresAt.Unix(),
+	})
+}
@@ -0,0 +1,179 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"regexp"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+	"github.com/zhishengyuan/searchgram-engine/audit"
+	"github.com/zhishengyuan/searchgram-engine/engines"
+	"github.com/zhishengyuan/searchgram-engine/i18n"
+	"github.com/zhishengyuan/searchgram-engine/middleware"
+	"github.com/zhishengyuan/searchgram-engine/models"
+)
+
+// snapshotNamePattern matches the snapshot names the engine can mount
+var snapshotNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9._+-]*$`)
+
+// SetSnapshotSearch enables mounting and searching the snapshots in a
+// snapshot repository
+func (h *APIHandler) SetSnapshotSearch(repository string) {
+	h.snapshotRepository = repository
+}
+
+// requireSnapshotSearch writes a 404 when snapshot search is disabled
+func (h *APIHandler) requireSnapshotSearch(c *gin.Context) bool {
+	if h.snapshotRepository == "" {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error:   "Not Found",
+			Message: i18n.Tc(c, "Snapshot search is not enabled"),
+		})
+		return false
+	}
+	return true
+}
+
+// snapshotName reads the name path parameter, writing a 400 when invalid
+func snapshotName(c *gin.Context) (string, bool) {
+	name := c.Param("name")
+	if !snapshotNamePattern.MatchString(name) {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Bad Request",
+			Message: i18n.Tc(c, "Invalid snapshot name"),
+		})
+		return "", false
+	}
+	return name, true
+}
+
+// ListSnapshots lists the snapshots of the index, newest first
+// GET /api/v1/snapshots
+func (h *APIHandler) ListSnapshots(c *gin.Context) {
+	if !h.requireSnapshotSearch(c) {
+		return
+	}
+
+	list, err := h.engine.ListSnapshots(c.Request.Context(), h.snapshotRepository)
+	if err != nil {
+		middleware.Log(c).WithError(err).Error("Failed to list snapshots")
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "Internal Server Error",
+			Message: i18n.Tc(c, "Failed to list snapshots"),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"repository": h.snapshotRepository,
+		"snapshots":  list,
+		"count":      len(list),
+	})
+}
+
+// MountSnapshot starts making a snapshot searchable as a background job
+// POST /api/v1/snapshots/:name/mount
+func (h *APIHandler) MountSnapshot(c *gin.Context) {
+	if !h.requireSnapshotSearch(c) {
+		return
+	}
+	name, ok := snapshotName(c)
+	if !ok {
+		return
+	}
+
+	job, started := h.jobs.StartExclusive(jobTypeSnapshotMount, func(ctx context.Context, update func(progress interface{})) (interface{}, error) {
+		log.WithField("snapshot", name).Info("Mounting snapshot...")
+		return h.engine.MountSnapshot(ctx, h.snapshotRepository, name)
+	})
+	if !started {
+		jobConflict(c, job)
+		return
+	}
+
+	h.audit.Record(audit.Entry{
+		Action: "snapshot.mount",
+		Actor:  callerID(c),
+		IP:     c.ClientIP(),
+		Detail: map[string]interface{}{
+			"snapshot": name,
+			"job_id":   job.ID,
+		},
+	})
+
+	c.Header("Location", "/api/v1/jobs/"+job.ID)
+	c.JSON(http.StatusAccepted, job)
+}
+
+// UnmountSnapshot removes a mounted snapshot, freeing its disk space
+// DELETE /api/v1/snapshots/:name/mount
+func (h *APIHandler) UnmountSnapshot(c *gin.Context) {
+	if !h.requireSnapshotSearch(c) {
+		return
+	}
+	name, ok := snapshotName(c)
+	if !ok {
+		return
+	}
+
+	err := h.engine.UnmountSnapshot(c.Request.Context(), name)
+	if errors.Is(err, engines.ErrSnapshotNotMounted) {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error:   "Not Found",
+			Message: i18n.Tc(c, "Snapshot %s is not mounted", name),
+		})
+		return
+	}
+	if err != nil {
+		middleware.Log(c).WithError(err).Error("Failed to unmount snapshot")
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "Internal Server Error",
+			Message: i18n.Tc(c, "Failed to unmount snapshot"),
+		})
+		return
+	}
+
+	h.audit.Record(audit.Entry{
+		Action: "snapshot.unmount",
+		Actor:  callerID(c),
+		IP:     c.ClientIP(),
+		Detail: map[string]interface{}{
+			"snapshot": name,
+		},
+	})
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":  true,
+		"snapshot": name,
+	})
+}
+
+// SearchSnapshot searches a mounted snapshot, taking the same request as
+// Search with snapshot set, to see what the index held at that time
+// POST /api/v1/search/snapshot
+func (h *APIHandler) SearchSnapshot(c *gin.Context) {
+	startTime := time.Now()
+
+	if !h.requireSnapshotSearch(c) {
+		return
+	}
+
+	var req models.SearchRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		middleware.Log(c).WithError(err).Warn("Invalid snapshot search request")
+		c.JSON(http.StatusBadRequest, validationError(c, err))
+		return
+	}
+	if !snapshotNamePattern.MatchString(req.Snapshot) {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Bad Request",
+			Message: i18n.Tc(c, "Invalid snapshot name"),
+		})
+		return
+	}
+
+	h.search(c, startTime, &req)
+}
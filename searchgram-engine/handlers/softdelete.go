@@ -0,0 +1,127 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/zhishengyuan/searchgram-engine/audit"
+	"github.com/zhishengyuan/searchgram-engine/i18n"
+	"github.com/zhishengyuan/searchgram-engine/middleware"
+	"github.com/zhishengyuan/searchgram-engine/models"
+)
+
+// Audited operations on soft-deleted messages
+const (
+	opSoftDeleteByQuery = "soft_delete_by_query"
+	opPurgeDeleted      = "purge_deleted"
+)
+
+// SetSoftDelete makes delete-by-query mark messages as deleted instead of
+// removing them
+func (h *APIHandler) SetSoftDelete(enabled bool) {
+	h.softDelete = enabled
+}
+
+// bindDeletedRequest reads the selection of soft-deleted messages, writing
+// an error response and returning false when it is invalid
+func (h *APIHandler) bindDeletedRequest(c *gin.Context) (*models.DeletedMessagesRequest, bool) {
+	var req models.DeletedMessagesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		middleware.Log(c).WithError(err).Warn("Invalid deleted messages request")
+		c.JSON(http.StatusBadRequest, validationError(c, err))
+		return nil, false
+	}
+	if !scopeSearch(c, &req.SearchRequest) {
+		return nil, false
+	}
+	if err := req.ResolveDates(h.location); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Bad Request",
+			Message: err.Error(),
+		})
+		return nil, false
+	}
+	req.Ctx = c.Request.Context()
+	return &req, true
+}
+
+// RestoreDeleted clears the deleted mark of soft-deleted messages matching
+// search filters and deletion times, making them searchable again
+// POST /api/v1/messages/deleted/restore
+func (h *APIHandler) RestoreDeleted(c *gin.Context) {
+	req, ok := h.bindDeletedRequest(c)
+	if !ok {
+		return
+	}
+
+	count, err := h.engine.RestoreDeleted(&req.DeletedFilter, req.DryRun)
+	if err != nil {
+		middleware.Log(c).WithError(err).Error("Failed to restore deleted messages")
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "Internal Server Error",
+			Message: i18n.Tc(c, "Failed to restore deleted messages"),
+		})
+		return
+	}
+
+	response := models.DeletedMessagesResponse{
+		Success: true,
+		DryRun:  req.DryRun,
+	}
+	if req.DryRun {
+		response.MatchedCount = count
+	} else {
+		response.RestoredCount = count
+		h.audit.Record(audit.Entry{
+			Action: "messages.restore_deleted",
+			Actor:  callerID(c),
+			IP:     c.ClientIP(),
+			Detail: map[string]interface{}{
+				"restored_count": count,
+				"query":          &req.DeletedFilter,
+			},
+		})
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// PurgeDeleted permanently removes soft-deleted messages matching search
+// filters and deletion times
+// POST /api/v1/messages/deleted/purge
+func (h *APIHandler) PurgeDeleted(c *gin.Context) {
+	req, ok := h.bindDeletedRequest(c)
+	if !ok {
+		return
+	}
+
+	estimate := func() (int64, error) {
+		return h.engine.PurgeDeleted(&req.DeletedFilter, true)
+	}
+	if !req.DryRun && !h.guardDelete(c, req.Force, estimate) {
+		return
+	}
+
+	count, err := h.engine.PurgeDeleted(&req.DeletedFilter, req.DryRun)
+	if err != nil {
+		middleware.Log(c).WithError(err).Error("Failed to purge deleted messages")
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "Internal Server Error",
+			Message: i18n.Tc(c, "Failed to purge deleted messages"),
+		})
+		return
+	}
+
+	response := models.DeletedMessagesResponse{
+		Success: true,
+		DryRun:  req.DryRun,
+	}
+	if req.DryRun {
+		response.MatchedCount = count
+	} else {
+		response.PurgedCount = count
+		h.auditDelete(c, opPurgeDeleted, "", &req.SearchRequest, count, "")
+	}
+
+	c.JSON(http.StatusOK, response)
+}
@@ -0,0 +1,185 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+	"github.com/zhishengyuan/searchgram-engine/audit"
+	"github.com/zhishengyuan/searchgram-engine/capture"
+	"github.com/zhishengyuan/searchgram-engine/i18n"
+	"github.com/zhishengyuan/searchgram-engine/middleware"
+	"github.com/zhishengyuan/searchgram-engine/models"
+	"github.com/zhishengyuan/searchgram-engine/profiles"
+	"github.com/zhishengyuan/searchgram-engine/retention"
+)
+
+// State bundle sections
+const (
+	stateCaptureRules  = "capture_rules"
+	stateSubscriptions = "subscriptions"
+	stateProfiles      = "profiles"
+	stateRetention     = "retention"
+	stateSavedTags     = "saved_tags"
+)
+
+// stateSection is one section of a bundle being imported
+type stateSection struct {
+	name     string
+	present  bool         // Set in the bundle
+	enabled  bool         // The feature is enabled on this host
+	validate func() error // Checks the section without storing it
+	replace  func() error // Stores the section
+}
+
+// ExportState returns the configuration created via the API as one bundle
+// GET /api/v1/state
+func (h *APIHandler) ExportState(c *gin.Context) {
+	h.audit.Record(audit.Entry{
+		Action: "state.export",
+		Actor:  callerID(c),
+		IP:     c.ClientIP(),
+	})
+
+	c.Header("Content-Disposition", `attachment; filename="searchgram-state.json"`)
+	c.JSON(http.StatusOK, h.exportState())
+}
+
+// exportState collects the state of every enabled feature
+func (h *APIHandler) exportState() models.StateBundle {
+	bundle := models.StateBundle{
+		Version:    models.StateBundleVersion,
+		ExportedAt: time.Now().Unix(),
+	}
+	if h.capture != nil {
+		rules := h.capture.Get()
+		bundle.CaptureRules = &rules
+	}
+	if h.subscriptions != nil {
+		bundle.Subscriptions = h.subscriptions.List(0)
+	}
+	if h.profiles != nil {
+		bundle.Profiles = h.profiles.List()
+	}
+	if h.retention != nil {
+		bundle.Retention = h.retention.Overrides()
+	}
+	if h.saved != nil {
+		bundle.SavedTags = h.saved.List()
+	}
+	return bundle
+}
+
+// ImportState replaces the stored state with the sections of a bundle.
+// All sections are validated before any is stored.
+// PUT /api/v1/state
+func (h *APIHandler) ImportState(c *gin.Context) {
+	var bundle models.StateBundle
+	if err := c.ShouldBindJSON(&bundle); err != nil {
+		middleware.Log(c).WithError(err).Warn("Invalid state bundle")
+		c.JSON(http.StatusBadRequest, validationError(c, err))
+		return
+	}
+	if bundle.Version > models.StateBundleVersion {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Bad Request",
+			Message: i18n.Tc(c, "Unsupported state bundle version %d", bundle.Version),
+		})
+		return
+	}
+
+	sections := []stateSection{
+		{
+			name:     stateCaptureRules,
+			present:  bundle.CaptureRules != nil,
+			enabled:  h.capture != nil,
+			validate: func() error { return capture.Validate(*bundle.CaptureRules) },
+			replace: func() error {
+				_, err := h.capture.Replace(*bundle.CaptureRules)
+				return err
+			},
+		},
+		{
+			name:     stateSubscriptions,
+			present:  bundle.Subscriptions != nil,
+			enabled:  h.subscriptions != nil,
+			validate: func() error { return h.subscriptions.Validate(bundle.Subscriptions) },
+			replace:  func() error { return h.subscriptions.Replace(bundle.Subscriptions) },
+		},
+		{
+			name:     stateProfiles,
+			present:  bundle.Profiles != nil,
+			enabled:  h.profiles != nil,
+			validate: func() error { return profiles.Validate(bundle.Profiles) },
+			replace:  func() error { return h.profiles.Replace(bundle.Profiles) },
+		},
+		{
+			name:     stateRetention,
+			present:  bundle.Retention != nil,
+			enabled:  h.retention != nil,
+			validate: func() error { return retention.Validate(bundle.Retention) },
+			replace:  func() error { return h.retention.Replace(bundle.Retention) },
+		},
+		{
+			name:     stateSavedTags,
+			present:  bundle.SavedTags != nil,
+			enabled:  h.saved != nil,
+			validate: func() error { return h.saved.Validate(bundle.SavedTags) },
+			replace:  func() error { return h.saved.Replace(bundle.SavedTags) },
+		},
+	}
+
+	result := models.StateImportResult{Imported: []string{}}
+	var apply []stateSection
+	for _, section := range sections {
+		if !section.present {
+			continue
+		}
+		if !section.enabled {
+			result.Skipped = append(result.Skipped, section.name)
+			continue
+		}
+		if err := section.validate(); err != nil {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{
+				Error:   "Bad Request",
+				Message: fmt.Sprintf("%s: %v", section.name, err),
+			})
+			return
+		}
+		apply = append(apply, section)
+	}
+
+	for _, section := range apply {
+		if err := section.replace(); err != nil {
+			middleware.Log(c).WithError(err).WithFields(log.Fields{
+				"section":  section.name,
+				"imported": result.Imported,
+			}).Error("Failed to import state")
+			c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+				Error:   "Internal Server Error",
+				Message: i18n.Tc(c, "Failed to import state"),
+			})
+			return
+		}
+		result.Imported = append(result.Imported, section.name)
+	}
+
+	h.audit.Record(audit.Entry{
+		Action: "state.import",
+		Actor:  callerID(c),
+		IP:     c.ClientIP(),
+		Detail: map[string]interface{}{
+			"imported": result.Imported,
+			"skipped":  result.Skipped,
+		},
+	})
+
+	middleware.Log(c).WithFields(log.Fields{
+		"imported": result.Imported,
+		"skipped":  result.Skipped,
+	}).Info("State imported")
+
+	c.JSON(http.StatusOK, result)
+}
@@ -0,0 +1,140 @@
+package handlers
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+	"github.com/zhishengyuan/searchgram-engine/i18n"
+	"github.com/zhishengyuan/searchgram-engine/middleware"
+	"github.com/zhishengyuan/searchgram-engine/models"
+)
+
+const (
+	ndjsonContentType = "application/x-ndjson"
+
+	// maxNDJSONLine bounds a single message line (raw_message can be large)
+	maxNDJSONLine = 16 * 1024 * 1024
+
+	// defaultMaxBatchSize is used when no batch size is configured
+	defaultMaxBatchSize = 1000
+
+	// maxReportedErrors caps the per-message errors returned for one stream
+	maxReportedErrors = 100
+)
+
+// SetMaxBatchSize sets how many streamed messages are bulk-indexed at a time
+func (h *APIHandler) SetMaxBatchSize(size int) {
+	h.maxBatchSize = size
+}
+
+// upsertStream indexes an application/x-ndjson body (one message per line),
+// flushing every maxBatchSize messages so the body is never held in memory
+// POST /api/v1/upsert/batch
+func (h *APIHandler) upsertStream(c *gin.Context) {
+	batchSize := h.maxBatchSize
+	if batchSize <= 0 {
+		batchSize = defaultMaxBatchSize
+	}
+
+	total := models.BatchUpsertResponse{Success: true}
+	addError := func(msg string) {
+		if len(total.Errors) < maxReportedErrors {
+			total.Errors = append(total.Errors, msg)
+		}
+	}
+
+	batch := make([]models.Message, 0, batchSize)
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		result, err := h.indexBatch(c, batch)
+		if err != nil {
+			return err
+		}
+		total.IndexedCount += result.IndexedCount
+		total.FailedCount += result.FailedCount
+		total.SkippedCount += result.SkippedCount
+		for _, msg := range result.Errors {
+			addError(msg)
+		}
+		batch = batch[:0]
+		return nil
+	}
+
+	scanner := bufio.NewScanner(c.Request.Body)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxNDJSONLine)
+
+	line := 0
+	for scanner.Scan() {
+		line++
+		data := scanner.Bytes()
+		if len(data) == 0 {
+			continue
+		}
+
+		var message models.Message
+		if err := json.Unmarshal(data, &message); err != nil {
+			total.FailedCount++
+			addError(fmt.Sprintf("line %d: invalid JSON: %v", line, err))
+			continue
+		}
+		if message.ID == "" {
+			total.FailedCount++
+			addError(fmt.Sprintf("line %d: message is missing ID", line))
+			continue
+		}
+
+		batch = append(batch, message)
+		if len(batch) >= batchSize {
+			if err := flush(); err != nil {
+				h.streamFailed(c, err, total)
+				return
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		middleware.Log(c).WithError(err).WithField("line", line).Warn("Failed to read NDJSON stream")
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Bad Request",
+			Message: i18n.Tc(c, "failed to read stream after line %d: %v (indexed %d so far)", line, err, total.IndexedCount),
+		})
+		return
+	}
+	if err := flush(); err != nil {
+		h.streamFailed(c, err, total)
+		return
+	}
+
+	if total.IndexedCount+total.SkippedCount == 0 && total.FailedCount == 0 {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Bad Request",
+			Message: i18n.Tc(c, "stream contains no messages"),
+		})
+		return
+	}
+
+	middleware.Log(c).WithFields(log.Fields{
+		"lines":   line,
+		"indexed": total.IndexedCount,
+		"failed":  total.FailedCount,
+		"skipped": total.SkippedCount,
+	}).Info("Processed NDJSON batch upsert")
+
+	total.Success = total.FailedCount == 0
+	c.JSON(http.StatusOK, total)
+}
+
+// streamFailed reports an engine failure part-way through a stream,
+// including how much was already indexed so the client can resume
+func (h *APIHandler) streamFailed(c *gin.Context, err error, total models.BatchUpsertResponse) {
+	middleware.Log(c).WithError(err).WithField("indexed", total.IndexedCount).Error("Failed to batch upsert streamed messages")
+	c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+		Error:   "Internal Server Error",
+		Message: i18n.Tc(c, "Failed to batch index messages (indexed %d before the failure)", total.IndexedCount),
+	})
+}
@@ -0,0 +1,159 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/zhishengyuan/searchgram-engine/i18n"
+	"github.com/zhishengyuan/searchgram-engine/middleware"
+	"github.com/zhishengyuan/searchgram-engine/models"
+	"github.com/zhishengyuan/searchgram-engine/subscriptions"
+)
+
+const (
+	maxPollTimeout = 60 * time.Second
+	maxPollLimit   = 500
+)
+
+// SetSubscriptions enables keyword subscriptions evaluated on upsert
+func (h *APIHandler) SetSubscriptions(manager *subscriptions.Manager) {
+	h.subscriptions = manager
+}
+
+// requireSubscriptions writes a 404 when subscriptions are disabled
+func (h *APIHandler) requireSubscriptions(c *gin.Context) bool {
+	if h.subscriptions == nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error:   "Not Found",
+			Message: i18n.Tc(c, "Keyword subscriptions are not enabled"),
+		})
+		return false
+	}
+	return true
+}
+
+// CreateSubscription registers a keyword subscription for a Telegram user
+// POST /api/v1/subscriptions
+func (h *APIHandler) CreateSubscription(c *gin.Context) {
+	if !h.requireSubscriptions(c) {
+		return
+	}
+
+	var req models.Subscription
+	if err := c.ShouldBindJSON(&req); err != nil {
+		middleware.Log(c).WithError(err).Warn("Invalid subscription request")
+		c.JSON(http.StatusBadRequest, validationError(c, err))
+		return
+	}
+
+	sub, err := h.subscriptions.Add(req)
+	if err == subscriptions.ErrLimitReached {
+		c.JSON(http.StatusConflict, models.ErrorResponse{
+			Error:   "Conflict",
+			Message: err.Error(),
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Bad Request",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, sub)
+}
+
+// ListSubscriptions lists subscriptions, optionally for one user
+// GET /api/v1/subscriptions?user_id=123
+func (h *APIHandler) ListSubscriptions(c *gin.Context) {
+	if !h.requireSubscriptions(c) {
+		return
+	}
+
+	var userID int64
+	if raw := c.Query("user_id"); raw != "" {
+		parsed, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{
+				Error:   "Bad Request",
+				Message: i18n.Tc(c, "Invalid user_id"),
+			})
+			return
+		}
+		userID = parsed
+	}
+
+	list := h.subscriptions.List(userID)
+	c.JSON(http.StatusOK, gin.H{
+		"subscriptions": list,
+		"count":         len(list),
+	})
+}
+
+// DeleteSubscription removes a subscription
+// DELETE /api/v1/subscriptions/:id
+func (h *APIHandler) DeleteSubscription(c *gin.Context) {
+	if !h.requireSubscriptions(c) {
+		return
+	}
+
+	if err := h.subscriptions.Delete(c.Param("id")); err != nil {
+		if err == subscriptions.ErrNotFound {
+			c.JSON(http.StatusNotFound, models.ErrorResponse{
+				Error:   "Not Found",
+				Message: err.Error(),
+			})
+			return
+		}
+		middleware.Log(c).WithError(err).Error("Failed to delete subscription")
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "Internal Server Error",
+			Message: i18n.Tc(c, "Failed to delete subscription"),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+	})
+}
+
+// PollSubscriptionEvents long-polls for notification events
+// GET /api/v1/subscriptions/events?after=0&limit=100&timeout=30
+func (h *APIHandler) PollSubscriptionEvents(c *gin.Context) {
+	if !h.requireSubscriptions(c) {
+		return
+	}
+
+	after, err := strconv.ParseInt(c.DefaultQuery("after", "0"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Bad Request",
+			Message: i18n.Tc(c, "Invalid after cursor"),
+		})
+		return
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "100"))
+	if err != nil || limit < 1 {
+		limit = 100
+	}
+	if limit > maxPollLimit {
+		limit = maxPollLimit
+	}
+
+	timeoutSeconds, err := strconv.Atoi(c.DefaultQuery("timeout", "30"))
+	if err != nil || timeoutSeconds < 0 {
+		timeoutSeconds = 30
+	}
+	timeout := time.Duration(timeoutSeconds) * time.Second
+	if timeout > maxPollTimeout {
+		timeout = maxPollTimeout
+	}
+
+	c.JSON(http.StatusOK, h.subscriptions.Poll(c.Request.Context(), after, limit, timeout))
+}
@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+	"github.com/zhishengyuan/searchgram-engine/i18n"
+	"github.com/zhishengyuan/searchgram-engine/ingest"
+	"github.com/zhishengyuan/searchgram-engine/middleware"
+	"github.com/zhishengyuan/searchgram-engine/models"
+	"github.com/zhishengyuan/searchgram-engine/usage"
+)
+
+// telegramSecretHeader carries the secret_token registered with setWebhook
+const telegramSecretHeader = "X-Telegram-Bot-Api-Secret-Token"
+
+// SetTelegramWebhookSecret sets the secret token Telegram must send with
+// webhook deliveries
+func (h *APIHandler) SetTelegramWebhookSecret(secret string) {
+	h.webhookSecret = secret
+}
+
+// TelegramWebhook indexes messages from raw Telegram Bot API updates.
+// Telegram retries deliveries that do not get a 2xx, so updates without an
+// indexable message are acknowledged and only indexing failures return 500.
+// POST /api/v1/telegram/webhook
+func (h *APIHandler) TelegramWebhook(c *gin.Context) {
+	secret := c.GetHeader(telegramSecretHeader)
+	if subtle.ConstantTimeCompare([]byte(secret), []byte(h.webhookSecret)) != 1 {
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{
+			Error:   "Unauthorized",
+			Message: i18n.Tc(c, "Invalid or missing %s", telegramSecretHeader),
+		})
+		return
+	}
+
+	var update ingest.BotUpdate
+	if err := c.ShouldBindJSON(&update); err != nil {
+		middleware.Log(c).WithError(err).Warn("Invalid Telegram update")
+		c.JSON(http.StatusBadRequest, validationError(c, err))
+		return
+	}
+
+	botMessage, edited := update.Extract()
+	if botMessage == nil {
+		c.JSON(http.StatusOK, gin.H{"ok": true, "indexed": false})
+		return
+	}
+
+	message := ingest.ConvertBotMessage(botMessage)
+
+	var err error
+	if edited {
+		err = h.ApplyEdit(&message)
+	} else {
+		var result models.BatchUpsertResponse
+		result, err = h.IndexMessages([]models.Message{message}, usage.DefaultTenant)
+		if err == nil && result.FailedCount > 0 {
+			err = errors.New(strings.Join(result.Errors, "; "))
+		}
+	}
+	if err != nil {
+		middleware.Log(c).WithError(err).WithFields(log.Fields{
+			"update_id": update.UpdateID,
+			"id":        message.ID,
+		}).Error("Failed to index Telegram update")
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "Internal Server Error",
+			Message: i18n.Tc(c, "Failed to index message"),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"ok": true, "indexed": true, "id": message.ID})
+}
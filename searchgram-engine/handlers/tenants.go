@@ -0,0 +1,217 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/zhishengyuan/searchgram-engine/audit"
+	"github.com/zhishengyuan/searchgram-engine/engines"
+	"github.com/zhishengyuan/searchgram-engine/i18n"
+	"github.com/zhishengyuan/searchgram-engine/middleware"
+	"github.com/zhishengyuan/searchgram-engine/models"
+	"github.com/zhishengyuan/searchgram-engine/tenants"
+)
+
+// SetTenants enables managing tenants with indices of their own
+func (h *APIHandler) SetTenants(registry *tenants.Registry) {
+	h.tenants = registry
+}
+
+// requireTenants writes a 404 when tenants are disabled
+func (h *APIHandler) requireTenants(c *gin.Context) bool {
+	if h.tenants == nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error:   "Not Found",
+			Message: i18n.Tc(c, "Tenants are not enabled"),
+		})
+		return false
+	}
+	return true
+}
+
+// tenantEngine returns the engine of the tenant a request acts for, as set
+// by middleware.TenantScope
+func tenantEngine(c *gin.Context) (engines.SearchEngine, bool) {
+	if value, ok := c.Get(tenants.EngineKey); ok {
+		if engine, ok := value.(engines.SearchEngine); ok {
+			return engine, true
+		}
+	}
+	return nil, false
+}
+
+// engineFor returns the engine a request reads and writes: its tenant's,
+// or the shared one
+func (h *APIHandler) engineFor(c *gin.Context) engines.SearchEngine {
+	if engine, ok := tenantEngine(c); ok {
+		return engine
+	}
+	return h.engine
+}
+
+// engineContext returns the context of engine calls made for a request: it
+// carries the request's trace, but not its cancellation and deadline, so
+// calls outlive the request as SearchRequest.Context does
+func engineContext(c *gin.Context) context.Context {
+	return context.WithoutCancel(c.Request.Context())
+}
+
+// tenantError writes the response for a failed tenant change
+func tenantError(c *gin.Context, id string, err error) {
+	switch err {
+	case tenants.ErrExists:
+		c.JSON(http.StatusConflict, models.ErrorResponse{
+			Error:   "Conflict",
+			Message: i18n.Tc(c, "Tenant %s already exists", id),
+		})
+	case tenants.ErrNotFound:
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error:   "Not Found",
+			Message: i18n.Tc(c, "Tenant %s not found", id),
+		})
+	case tenants.ErrInvalidID:
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Bad Request",
+			Message: i18n.Tc(c, "Tenant IDs are 1-32 lowercase letters, digits and underscores, starting with a letter, other than trash, audit, snapshot and default"),
+		})
+	default:
+		middleware.Log(c).WithError(err).WithField("tenant", id).Error("Failed to change tenant")
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "Internal Server Error",
+			Message: i18n.Tc(c, "Failed to save tenant"),
+		})
+	}
+}
+
+// ListTenants lists the tenants
+// GET /api/v1/tenants
+func (h *APIHandler) ListTenants(c *gin.Context) {
+	if !h.requireTenants(c) {
+		return
+	}
+
+	list := h.tenants.List()
+	c.JSON(http.StatusOK, gin.H{
+		"tenants": list,
+		"count":   len(list),
+	})
+}
+
+// GetTenant returns a tenant
+// GET /api/v1/tenants/:tenant
+func (h *APIHandler) GetTenant(c *gin.Context) {
+	if !h.requireTenants(c) {
+		return
+	}
+
+	id := c.Param("tenant")
+	tenant, ok := h.tenants.Get(id)
+	if !ok {
+		tenantError(c, id, tenants.ErrNotFound)
+		return
+	}
+	c.JSON(http.StatusOK, tenant)
+}
+
+// CreateTenant adds a tenant and creates its index. The response holds the
+// tenant's API key, which is not shown again.
+// POST /api/v1/tenants
+func (h *APIHandler) CreateTenant(c *gin.Context) {
+	if !h.requireTenants(c) {
+		return
+	}
+
+	var req models.TenantRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		middleware.Log(c).WithError(err).Warn("Invalid tenant request")
+		c.JSON(http.StatusBadRequest, validationError(c, err))
+		return
+	}
+
+	created, err := h.tenants.Create(req.ID, req.Name, callerID(c))
+	if err != nil {
+		tenantError(c, req.ID, err)
+		return
+	}
+
+	h.audit.Record(audit.Entry{
+		Action: "tenant.create",
+		Actor:  callerID(c),
+		IP:     c.ClientIP(),
+		Detail: map[string]interface{}{
+			"tenant": created.ID,
+			"index":  created.Index,
+		},
+	})
+
+	c.JSON(http.StatusCreated, created)
+}
+
+// UpdateTenant renames a tenant
+// PATCH /api/v1/tenants/:tenant
+func (h *APIHandler) UpdateTenant(c *gin.Context) {
+	if !h.requireTenants(c) {
+		return
+	}
+
+	var req models.TenantUpdateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		middleware.Log(c).WithError(err).Warn("Invalid tenant update request")
+		c.JSON(http.StatusBadRequest, validationError(c, err))
+		return
+	}
+
+	id := c.Param("tenant")
+	tenant, err := h.tenants.Update(id, req.Name)
+	if err != nil {
+		tenantError(c, id, err)
+		return
+	}
+
+	h.audit.Record(audit.Entry{
+		Action: "tenant.update",
+		Actor:  callerID(c),
+		IP:     c.ClientIP(),
+		Detail: map[string]interface{}{
+			"tenant": id,
+			"name":   req.Name,
+		},
+	})
+
+	c.JSON(http.StatusOK, tenant)
+}
+
+// DeleteTenant removes a tenant and revokes its API key. Its indices are
+// kept unless purge is set.
+// DELETE /api/v1/tenants/:tenant?purge=true
+func (h *APIHandler) DeleteTenant(c *gin.Context) {
+	if !h.requireTenants(c) {
+		return
+	}
+
+	id := c.Param("tenant")
+	purge := c.Query("purge") == "true"
+	tenant, err := h.tenants.Delete(c.Request.Context(), id, purge)
+	if err != nil {
+		tenantError(c, id, err)
+		return
+	}
+
+	h.audit.Record(audit.Entry{
+		Action: "tenant.delete",
+		Actor:  callerID(c),
+		IP:     c.ClientIP(),
+		Detail: map[string]interface{}{
+			"tenant": id,
+			"index":  tenant.Index,
+			"purged": purge,
+		},
+	})
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"tenant":  id,
+		"purged":  purge,
+	})
+}
@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/zhishengyuan/searchgram-engine/audit"
+	"github.com/zhishengyuan/searchgram-engine/i18n"
+	"github.com/zhishengyuan/searchgram-engine/jwt"
+	"github.com/zhishengyuan/searchgram-engine/middleware"
+	"github.com/zhishengyuan/searchgram-engine/models"
+)
+
+// SetTokenMinter enables minting service tokens for the given issuers,
+// valid for at most maxTTL
+func (h *APIHandler) SetTokenMinter(minter *jwt.JWTAuth, issuers []string, maxTTL time.Duration) {
+	h.tokenMinter = minter
+	h.tokenIssuers = issuers
+	h.maxTokenTTL = maxTTL
+}
+
+// MintToken signs a JWT for a service, so e.g. an ingestion worker can be
+// handed a short-lived token instead of the private key
+// POST /api/v1/auth/token
+func (h *APIHandler) MintToken(c *gin.Context) {
+	if h.tokenMinter == nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error:   "Not Found",
+			Message: i18n.Tc(c, "Token minting is not enabled"),
+		})
+		return
+	}
+
+	var req models.MintTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		middleware.Log(c).WithError(err).Warn("Invalid token request")
+		c.JSON(http.StatusBadRequest, validationError(c, err))
+		return
+	}
+
+	if !slices.Contains(h.tokenIssuers, req.Issuer) {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Bad Request",
+			Message: i18n.Tc(c, "issuer %s is not an allowed issuer", req.Issuer),
+		})
+		return
+	}
+	if req.Role != "" && !middleware.ValidCredentialRole(req.Role) {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Bad Request",
+			Message: i18n.Tc(c, "role must be reader, writer or admin"),
+		})
+		return
+	}
+
+	ttl := time.Duration(req.TTLSeconds) * time.Second
+	if ttl > h.maxTokenTTL {
+		ttl = h.maxTokenTTL
+	}
+
+	token, expiresAt, err := h.tokenMinter.MintToken(req.Issuer, req.Audience, req.Role, ttl)
+	if err != nil {
+		middleware.Log(c).WithError(err).Error("Failed to mint token")
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "Internal Server Error",
+			Message: i18n.Tc(c, "Failed to mint token"),
+		})
+		return
+	}
+
+	h.audit.Record(audit.Entry{
+		Action: "token.mint",
+		Actor:  callerID(c),
+		IP:     c.ClientIP(),
+		Detail: map[string]interface{}{
+			"issuer":     req.Issuer,
+			"audience":   req.Audience,
+			"role":       req.Role,
+			"expires_at": expiresAt.Unix(),
+		},
+	})
+
+	c.JSON(http.StatusCreated, models.MintTokenResponse{
+		Token:     token,
+		Issuer:    req.Issuer,
+		Role:      req.Role,
+		ExpiresAt: expiresAt.Unix(),
+	})
+}
@@ -0,0 +1,36 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/zhishengyuan/searchgram-engine/enrich"
+	"github.com/zhishengyuan/searchgram-engine/i18n"
+	"github.com/zhishengyuan/searchgram-engine/models"
+)
+
+// SetTranslator enables "translate": true searches
+func (h *APIHandler) SetTranslator(translator *enrich.Translator) {
+	h.translator = translator
+}
+
+// translateQuery sets the translations of a search keyword when the request
+// asks for them. Translation failures only narrow the search back to the
+// original keyword. It returns false when an error response was written.
+func (h *APIHandler) translateQuery(c *gin.Context, req *models.SearchRequest) bool {
+	if !req.Translate || strings.TrimSpace(req.Keyword) == "" {
+		return true
+	}
+
+	if h.translator == nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Bad Request",
+			Message: i18n.Tc(c, "Query translation is not enabled"),
+		})
+		return false
+	}
+
+	req.Translations = h.translator.TranslateQuery(c.Request.Context(), req.Keyword)
+	return true
+}
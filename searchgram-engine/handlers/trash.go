@@ -0,0 +1,143 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/zhishengyuan/searchgram-engine/i18n"
+	"github.com/zhishengyuan/searchgram-engine/middleware"
+	"github.com/zhishengyuan/searchgram-engine/models"
+	"github.com/zhishengyuan/searchgram-engine/recyclebin"
+)
+
+// SetRecycleBin makes deletes move messages to the recycle bin instead of
+// removing them
+func (h *APIHandler) SetRecycleBin(bin *recyclebin.Bin) {
+	h.recycleBin = bin
+}
+
+// requireRecycleBin writes a 404 when the recycle bin is disabled
+func (h *APIHandler) requireRecycleBin(c *gin.Context) bool {
+	if h.recycleBin == nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error:   "Not Found",
+			Message: i18n.Tc(c, "The recycle bin is not enabled"),
+		})
+		return false
+	}
+	return true
+}
+
+// trashMessages moves the selected messages to the recycle bin, writing an
+// error response and returning false when that fails
+func (h *APIHandler) trashMessages(c *gin.Context, selector models.TrashSelector, operation, target string) (*models.TrashBatch, bool) {
+	batch, err := h.recycleBin.Move(c.Request.Context(), selector, operation, target)
+	if refuseHeld(c, err) {
+		return nil, false
+	}
+	if err != nil {
+		middleware.Log(c).WithError(err).WithField("operation", operation).Error("Failed to move messages to trash")
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "Internal Server Error",
+			Message: i18n.Tc(c, "Failed to move messages to the recycle bin"),
+		})
+		return nil, false
+	}
+	h.auditDelete(c, operation, target, selector.Query, batch.Count, batch.ID)
+	return batch, true
+}
+
+// ListTrash lists recycle bin batches, most recently deleted first
+// GET /api/v1/trash
+func (h *APIHandler) ListTrash(c *gin.Context) {
+	if !h.requireRecycleBin(c) {
+		return
+	}
+
+	batches, err := h.recycleBin.List(c.Request.Context())
+	if err != nil {
+		middleware.Log(c).WithError(err).Error("Failed to list trash")
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "Internal Server Error",
+			Message: i18n.Tc(c, "Failed to list the recycle bin"),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.TrashListResponse{
+		Batches: batches,
+		Total:   len(batches),
+	})
+}
+
+// RestoreTrashBatch restores every message deleted by one operation
+// POST /api/v1/trash/batches/:batch/restore
+func (h *APIHandler) RestoreTrashBatch(c *gin.Context) {
+	if !h.requireRecycleBin(c) {
+		return
+	}
+
+	batchID := c.Param("batch")
+	restored, skipped, err := h.recycleBin.RestoreBatch(c.Request.Context(), batchID)
+	if err != nil {
+		middleware.Log(c).WithError(err).WithField("batch", batchID).Error("Failed to restore trash batch")
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "Internal Server Error",
+			Message: i18n.Tc(c, "Failed to restore messages from the recycle bin"),
+		})
+		return
+	}
+	if restored == 0 && skipped == 0 {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error:   "Not Found",
+			Message: i18n.Tc(c, "Recycle bin batch %s not found", batchID),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.RestoreResponse{
+		Success:       true,
+		RestoredCount: restored,
+		SkippedCount:  skipped,
+	})
+}
+
+// RestoreTrashMessage restores a single deleted message
+// POST /api/v1/trash/messages/:id/restore
+func (h *APIHandler) RestoreTrashMessage(c *gin.Context) {
+	if !h.requireRecycleBin(c) {
+		return
+	}
+
+	id := c.Param("id")
+	if _, _, err := models.ParseMessageID(id); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Bad Request",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	restored, skipped, err := h.recycleBin.RestoreMessage(c.Request.Context(), id)
+	if err != nil {
+		middleware.Log(c).WithError(err).WithField("doc_id", id).Error("Failed to restore trashed message")
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "Internal Server Error",
+			Message: i18n.Tc(c, "Failed to restore messages from the recycle bin"),
+		})
+		return
+	}
+	if restored == 0 && skipped == 0 {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error:   "Not Found",
+			Message: i18n.Tc(c, "Message %s not found in the recycle bin", id),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.RestoreResponse{
+		Success:       true,
+		RestoredCount: restored,
+		SkippedCount:  skipped,
+	})
+}
@@ -0,0 +1,63 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/zhishengyuan/searchgram-engine/i18n"
+	"github.com/zhishengyuan/searchgram-engine/middleware"
+	"github.com/zhishengyuan/searchgram-engine/models"
+	"github.com/zhishengyuan/searchgram-engine/tenants"
+	"github.com/zhishengyuan/searchgram-engine/usage"
+)
+
+// callerTenant resolves the tenant a request is accounted to
+func callerTenant(c *gin.Context) string {
+	if tenant := c.GetString(tenants.ContextKey); tenant != "" {
+		return tenant
+	}
+	if issuer := c.GetString("jwt_issuer"); issuer != "" {
+		return issuer
+	}
+	if name := c.GetString(middleware.APIKeyNameKey); name != "" {
+		return name
+	}
+	return usage.DefaultTenant
+}
+
+// Usage returns the usage records for the current (not yet exported) period
+// GET /api/v1/usage?format=json|csv
+func (h *APIHandler) Usage(c *gin.Context) {
+	if h.usage == nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error:   "Not Found",
+			Message: i18n.Tc(c, "Usage tracking is not enabled"),
+		})
+		return
+	}
+
+	records := h.usage.Snapshot()
+
+	switch c.DefaultQuery("format", "json") {
+	case "csv":
+		data, err := usage.EncodeCSV(records)
+		if err != nil {
+			middleware.Log(c).WithError(err).Error("Failed to encode usage records")
+			c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+				Error:   "Internal Server Error",
+				Message: i18n.Tc(c, "Failed to encode usage records"),
+			})
+			return
+		}
+		c.Data(http.StatusOK, "text/csv", data)
+	case "json":
+		c.JSON(http.StatusOK, gin.H{
+			"records": records,
+		})
+	default:
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Bad Request",
+			Message: i18n.Tc(c, "format must be json or csv"),
+		})
+	}
+}
@@ -0,0 +1,194 @@
+package handlers
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+	"github.com/zhishengyuan/searchgram-engine/audit"
+	"github.com/zhishengyuan/searchgram-engine/i18n"
+	"github.com/zhishengyuan/searchgram-engine/middleware"
+	"github.com/zhishengyuan/searchgram-engine/models"
+)
+
+// ExportUser streams every message a user sent across all chats, soft-
+// deleted and trashed ones included, for right-to-access requests: as
+// NDJSON, or as a zip with one JSON array per chat and an export.json
+// summary. A scoped API key only exports the chats it may access.
+// GET /api/v1/users/:user_id/export[?format=ndjson|zip]
+func (h *APIHandler) ExportUser(c *gin.Context) {
+	userID, err := strconv.ParseInt(c.Param("user_id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Bad Request",
+			Message: i18n.Tc(c, "Invalid user_id"),
+		})
+		return
+	}
+	format := c.DefaultQuery("format", models.UserExportNDJSON)
+	if format != models.UserExportNDJSON && format != models.UserExportZip {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Bad Request",
+			Message: i18n.Tc(c, "format must be ndjson or zip"),
+		})
+		return
+	}
+
+	h.audit.Record(audit.Entry{
+		Action: "users.export",
+		Actor:  callerID(c),
+		IP:     c.ClientIP(),
+		Detail: map[string]interface{}{
+			"user_id": userID,
+			"format":  format,
+		},
+	})
+
+	var exported int64
+	if format == models.UserExportZip {
+		exported, err = h.exportUserZip(c, userID)
+	} else {
+		exported, err = h.exportUserNDJSON(c, userID)
+	}
+	fields := log.Fields{
+		"user_id":  userID,
+		"format":   format,
+		"messages": exported,
+	}
+	if err != nil {
+		middleware.Log(c).WithError(err).WithFields(fields).Error("Failed to export user messages")
+		if !c.Writer.Written() {
+			c.Writer.Header().Del("Content-Type")
+			c.Writer.Header().Del("Content-Disposition")
+			c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+				Error:   "Internal Server Error",
+				Message: i18n.Tc(c, "Failed to export user messages"),
+			})
+		}
+		return
+	}
+	middleware.Log(c).WithFields(fields).Info("Exported user messages")
+}
+
+// exportUserNDJSON writes one message per line. Once lines were sent, a
+// failure ends the stream with an error line instead of a status.
+func (h *APIHandler) exportUserNDJSON(c *gin.Context, userID int64) (int64, error) {
+	c.Header("Content-Type", ndjsonContentType)
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="user-%d-messages.ndjson"`, userID))
+
+	enc := json.NewEncoder(c.Writer)
+	var exported int64
+	err := h.engine.ScanUserMessages(c.Request.Context(), userID, func(messages []models.Message) error {
+		for i := range messages {
+			if !inScope(c, messages[i].ChatID) {
+				continue
+			}
+			if err := enc.Encode(&messages[i]); err != nil {
+				return err
+			}
+			exported++
+		}
+		c.Writer.Flush()
+		return nil
+	})
+	if err != nil && c.Writer.Written() {
+		enc.Encode(models.ErrorResponse{
+			Error:   "Internal Server Error",
+			Message: i18n.Tc(c, "Failed to export user messages (exported %d before the failure)", exported),
+		})
+	}
+	return exported, err
+}
+
+// exportUserZip writes chats/<chat_id>.json per chat, then export.json.
+// Messages arrive ordered by chat, so each chat's file is written in one go.
+func (h *APIHandler) exportUserZip(c *gin.Context, userID int64) (int64, error) {
+	c.Header("Content-Type", "application/zip")
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="user-%d-messages.zip"`, userID))
+
+	summary := models.UserExportSummary{
+		UserID:     userID,
+		ExportedAt: time.Now().Unix(),
+		Chats:      []models.UserExportChat{},
+	}
+	archive := zip.NewWriter(c.Writer)
+
+	var file io.Writer // JSON array of the current chat
+	var chat *models.UserExportChat
+	err := h.engine.ScanUserMessages(c.Request.Context(), userID, func(messages []models.Message) error {
+		for i := range messages {
+			message := &messages[i]
+			if !inScope(c, message.ChatID) {
+				continue
+			}
+
+			separator := ",\n"
+			if chat == nil || chat.ChatID != message.ChatID {
+				if chat != nil {
+					if _, err := io.WriteString(file, "\n]\n"); err != nil {
+						return err
+					}
+				}
+				var err error
+				if file, err = archive.Create(fmt.Sprintf("chats/%d.json", message.ChatID)); err != nil {
+					return err
+				}
+				summary.Chats = append(summary.Chats, models.UserExportChat{ChatID: message.ChatID})
+				chat = &summary.Chats[len(summary.Chats)-1]
+				separator = "[\n"
+			}
+
+			data, err := json.Marshal(message)
+			if err != nil {
+				return err
+			}
+			if _, err := io.WriteString(file, separator); err != nil {
+				return err
+			}
+			if _, err := file.Write(data); err != nil {
+				return err
+			}
+			if message.ChatTitle != "" {
+				chat.ChatTitle = message.ChatTitle
+			}
+			chat.Messages++
+			summary.Messages++
+		}
+		if err := archive.Flush(); err != nil {
+			return err
+		}
+		c.Writer.Flush()
+		return nil
+	})
+	if err == nil && chat != nil {
+		_, err = io.WriteString(file, "\n]\n")
+	}
+	if err != nil && !c.Writer.Written() {
+		return summary.Messages, err
+	}
+
+	// The summary records a failure, so a truncated export is recognizable
+	summary.Complete = err == nil
+	if err != nil {
+		summary.Error = i18n.Tc(c, "Failed to export user messages (exported %d before the failure)", summary.Messages)
+	}
+	f, createErr := archive.Create("export.json")
+	if createErr == nil {
+		enc := json.NewEncoder(f)
+		enc.SetIndent("", "  ")
+		createErr = enc.Encode(summary)
+	}
+	if createErr == nil {
+		createErr = archive.Close()
+	}
+	if err == nil {
+		err = createErr
+	}
+	return summary.Messages, err
+}
@@ -0,0 +1,208 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+	"github.com/zhishengyuan/searchgram-engine/i18n"
+	"github.com/zhishengyuan/searchgram-engine/models"
+)
+
+// bodyField names the request body as a whole in field errors
+const bodyField = "(body)"
+
+var dateBoundType = reflect.TypeOf(models.DateBound{})
+
+func init() {
+	// Report validation failures by JSON field name rather than Go field name
+	if v, ok := binding.Validator.Engine().(*validator.Validate); ok {
+		v.RegisterTagNameFunc(func(field reflect.StructField) string {
+			name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+			if name == "-" {
+				return ""
+			}
+			if name == "" {
+				return field.Name
+			}
+			return name
+		})
+
+		// Geo filter radii such as "500m" or "2km"
+		v.RegisterValidation("distance", func(fl validator.FieldLevel) bool {
+			return models.ValidDistance(fl.Field().String())
+		})
+	}
+}
+
+// validationError turns a request binding error into a 400 body that lists
+// each offending field path, the violated constraint and an example value,
+// explained in the request language
+func validationError(c *gin.Context, err error) models.ErrorResponse {
+	lang := i18n.Language(c)
+	fields := describeBindError(lang, err)
+
+	message := i18n.T(lang, "%d fields are invalid", len(fields))
+	if len(fields) == 1 {
+		message = fields[0].Field + ": " + fields[0].Message
+	}
+
+	return models.ErrorResponse{
+		Error:   "Bad Request",
+		Message: message,
+		Fields:  fields,
+	}
+}
+
+// describeBindError maps JSON decoding and validator errors to field errors
+func describeBindError(lang string, err error) []models.FieldError {
+	var (
+		syntaxErr     *json.SyntaxError
+		typeErr       *json.UnmarshalTypeError
+		validationErr validator.ValidationErrors
+	)
+
+	switch {
+	case errors.Is(err, io.EOF):
+		return []models.FieldError{{
+			Field:      bodyField,
+			Constraint: "required",
+			Message:    i18n.T(lang, "request body is empty, expected a JSON object"),
+			Example:    map[string]interface{}{},
+		}}
+
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		return []models.FieldError{{
+			Field:      bodyField,
+			Constraint: "json",
+			Message:    i18n.T(lang, "truncated JSON, the body ends before the value is complete"),
+		}}
+
+	case errors.As(err, &syntaxErr):
+		return []models.FieldError{{
+			Field:      bodyField,
+			Constraint: "json",
+			Message:    i18n.T(lang, "malformed JSON at byte %d: %v", syntaxErr.Offset, syntaxErr),
+		}}
+
+	case errors.As(err, &typeErr):
+		field := typeErr.Field
+		if field == "" {
+			field = bodyField
+		}
+		return []models.FieldError{{
+			Field:      field,
+			Constraint: "type",
+			Message:    i18n.T(lang, "expected %s, got JSON %s", describeType(lang, typeErr.Type), typeErr.Value),
+			Example:    exampleValue(typeErr.Type),
+		}}
+
+	case errors.As(err, &validationErr):
+		fields := make([]models.FieldError, 0, len(validationErr))
+		for _, fe := range validationErr {
+			constraint := fe.Tag()
+			if fe.Param() != "" {
+				constraint += "=" + fe.Param()
+			}
+			example := exampleValue(fe.Type())
+			if fe.Tag() == "distance" {
+				example = "2km"
+			}
+			fields = append(fields, models.FieldError{
+				Field:      fieldPath(fe.Namespace()),
+				Constraint: constraint,
+				Message:    constraintMessage(lang, fe),
+				Example:    example,
+			})
+		}
+		return fields
+	}
+
+	// Errors from custom decoders (e.g. dates) carry no field path
+	return []models.FieldError{{
+		Field:      bodyField,
+		Constraint: "invalid",
+		Message:    err.Error(),
+	}}
+}
+
+// fieldPath drops the root struct name from a validator namespace
+// ("BackfillRequest.chat_id" -> "chat_id")
+func fieldPath(namespace string) string {
+	if _, path, ok := strings.Cut(namespace, "."); ok {
+		return path
+	}
+	return namespace
+}
+
+// constraintMessage explains a failed validation tag
+func constraintMessage(lang string, fe validator.FieldError) string {
+	switch fe.Tag() {
+	case "required":
+		return i18n.T(lang, "is required")
+	case "min", "gte":
+		return i18n.T(lang, "must be at least %s", fe.Param())
+	case "max", "lte":
+		return i18n.T(lang, "must be at most %s", fe.Param())
+	case "oneof":
+		return i18n.T(lang, "must be one of: %s", strings.ReplaceAll(fe.Param(), " ", ", "))
+	case "distance":
+		return i18n.T(lang, "must be a distance such as 500m or 2km")
+	}
+	return i18n.T(lang, "failed the %q constraint", fe.Tag())
+}
+
+// describeType names a Go type in JSON terms
+func describeType(lang string, t reflect.Type) string {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == dateBoundType {
+		return i18n.T(lang, "a date (unix timestamp, YYYY-MM-DD, RFC3339 or now-7d)")
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return i18n.T(lang, "a string")
+	case reflect.Bool:
+		return i18n.T(lang, "a boolean")
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return i18n.T(lang, "an integer")
+	case reflect.Float32, reflect.Float64:
+		return i18n.T(lang, "a number")
+	case reflect.Slice, reflect.Array:
+		return i18n.T(lang, "an array")
+	}
+	return i18n.T(lang, "an object")
+}
+
+// exampleValue returns a valid JSON value for a Go type
+func exampleValue(t reflect.Type) interface{} {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == dateBoundType {
+		return "2024-01-31"
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return "text"
+	case reflect.Bool:
+		return true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return 123
+	case reflect.Float32, reflect.Float64:
+		return 1.5
+	case reflect.Slice, reflect.Array:
+		return []interface{}{exampleValue(t.Elem())}
+	}
+	return map[string]interface{}{}
+}
@@ -0,0 +1,107 @@
+// Package i18n localizes user-facing API messages. Messages are looked up by
+// their English text (the format string), so untranslated messages fall back
+// to English unchanged.
+package i18n
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Supported languages
+const (
+	English = "en"
+	Chinese = "zh-CN"
+)
+
+// contextKey stores the negotiated language in the gin context
+const contextKey = "i18n_language"
+
+// catalogs maps a language to its translations, keyed by English format string
+var catalogs = map[string]map[string]string{
+	Chinese: zhCN,
+}
+
+// Match maps a BCP 47 language tag to a supported language, or "" if none
+// fits. All Chinese variants map to Simplified Chinese.
+func Match(tag string) string {
+	tag = strings.ToLower(strings.TrimSpace(tag))
+	switch {
+	case tag == "en" || strings.HasPrefix(tag, "en-"):
+		return English
+	case tag == "zh" || strings.HasPrefix(tag, "zh-"):
+		return Chinese
+	}
+	return ""
+}
+
+// Negotiate picks the best supported language from an Accept-Language
+// header, falling back to fallback
+func Negotiate(acceptLanguage, fallback string) string {
+	type candidate struct {
+		tag string
+		q   float64
+	}
+
+	var candidates []candidate
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		q := 1.0
+		if value, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+		if tag != "" && q > 0 {
+			candidates = append(candidates, candidate{tag: tag, q: q})
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].q > candidates[j].q
+	})
+
+	for _, c := range candidates {
+		if c.tag == "*" {
+			return fallback
+		}
+		if lang := Match(c.tag); lang != "" {
+			return lang
+		}
+	}
+	return fallback
+}
+
+// T translates format into lang and formats it with args
+func T(lang, format string, args ...interface{}) string {
+	if translated, ok := catalogs[lang][format]; ok {
+		format = translated
+	}
+	if len(args) == 0 {
+		return format
+	}
+	return fmt.Sprintf(format, args...)
+}
+
+// SetLanguage stores the request language in the gin context
+func SetLanguage(c *gin.Context, lang string) {
+	c.Set(contextKey, lang)
+}
+
+// Language returns the request language, English if none was negotiated
+func Language(c *gin.Context) string {
+	if lang := c.GetString(contextKey); lang != "" {
+		return lang
+	}
+	return English
+}
+
+// Tc translates format into the request language
+func Tc(c *gin.Context, format string, args ...interface{}) string {
+	return T(Language(c), format, args...)
+}
@@ -0,0 +1,196 @@
+package i18n
+
+// zhCN holds the Simplified Chinese translations
+var zhCN = map[string]string{
+	// Request validation
+	"%d fields are invalid":                                      "%d 个字段无效",
+	"request body is empty, expected a JSON object":              "请求体为空，应为 JSON 对象",
+	"truncated JSON, the body ends before the value is complete": "JSON 不完整，请求体在值结束前中断",
+	"malformed JSON at byte %d: %v":                              "JSON 格式错误（第 %d 字节）：%v",
+	"expected %s, got JSON %s":                                   "应为%s，实际为 JSON %s",
+	"is required":                                                "为必填项",
+	"must be at least %s":                                        "不能小于 %s",
+	"must be at most %s":                                         "不能大于 %s",
+	"must be one of: %s":                                         "必须是以下之一：%s",
+	"must be a distance such as 500m or 2km":                     "必须是距离，例如 500m 或 2km",
+	"failed the %q constraint":                                   "不满足 %q 约束",
+	"a date (unix timestamp, YYYY-MM-DD, RFC3339 or now-7d)":     "日期（Unix 时间戳、YYYY-MM-DD、RFC3339 或 now-7d）",
+	"a string":   "字符串",
+	"a boolean":  "布尔值",
+	"an integer": "整数",
+	"a number":   "数字",
+	"an array":   "数组",
+	"an object":  "对象",
+
+	// Authentication and limits
+	"Missing or invalid Authorization header":         "缺少或无效的 Authorization 请求头",
+	"Invalid Authorization header format":             "Authorization 请求头格式无效",
+	"Invalid token: %s":                               "令牌无效：%s",
+	"Invalid or missing API key":                      "API 密钥无效或缺失",
+	"Invalid or missing %s":                           "%s 无效或缺失",
+	"An unexpected error occurred":                    "发生意外错误",
+	"Request exceeded the %s deadline for this route": "请求超过了此接口 %s 的时限",
+	"Too many concurrent %s requests, retry later":    "并发 %s 请求过多，请稍后重试",
+	"Rate limit exceeded, retry in %d seconds":        "请求频率超出限制，请在 %d 秒后重试",
+	"Requests from %s are not allowed":                "不允许来自 %s 的请求",
+	"Ingest queue is full, retry later":               "写入队列已满，请稍后重试",
+	"Issuer %s may not use %s routes":                 "签发方 %s 无权使用 %s 类接口",
+	"Credentials with role %s may not use %s routes":  "角色为 %s 的凭据无权使用 %s 类接口",
+	"%s routes require %s credentials":                "%s 类接口需要 %s 凭据",
+	"%s may not call %s %s":                           "%s 无权调用 %s %s",
+	"Tenants may not call %s %s":                      "租户无权调用 %s %s",
+	"API key %s may not call %s %s":                   "API 密钥 %s 无权调用 %s %s",
+	"Chat %d is outside the scope of this API key":    "会话 %d 不在此 API 密钥的访问范围内",
+	"Unknown tenant %s":                               "未知租户 %s",
+	"Too many failed auth attempts, retry in %s":      "认证失败次数过多，请在 %s 后重试",
+	"Missing or invalid CSRF token":                   "缺少或无效的 CSRF 令牌",
+	"Invalid signed URL":                              "签名链接无效",
+	"Signed URL has expired":                          "签名链接已过期",
+
+	// Messages and search
+	"message ID is required":                                      "消息 ID 为必填项",
+	"message at index %d is missing ID":                           "第 %d 条消息缺少 ID",
+	"messages array cannot be empty":                              "messages 数组不能为空",
+	"stream contains no messages":                                 "数据流中没有消息",
+	"failed to read stream after line %d: %v (indexed %d so far)": "读取数据流第 %d 行之后失败：%v（已索引 %d 条）",
+	"invalid Telegram Desktop export: %v (indexed %d so far)":     "无效的 Telegram Desktop 导出文件：%v（已索引 %d 条）",
+	"unsupported boost_by: %s":                                    "不支持的 boost_by：%s",
+	"boost_by requires sort_by relevance":                         "使用 boost_by 时 sort_by 必须为 relevance",
+	"unsupported sort_by: %s":                                     "不支持的 sort_by：%s",
+	"chat_id query parameter is required":                         "缺少 chat_id 查询参数",
+	"Invalid chat_id":                                             "chat_id 无效",
+	"Invalid user_id":                                             "user_id 无效",
+	"user_id is required":                                         "user_id 为必填项",
+	"group_id is required":                                        "group_id 为必填项",
+	"from_timestamp and to_timestamp are required":                "from_timestamp 和 to_timestamp 为必填项",
+	"from_timestamp must be less than to_timestamp":               "from_timestamp 必须小于 to_timestamp",
+	"Message %s not found":                                        "未找到消息 %s",
+	"Message %s not found in the recycle bin":                     "回收站中未找到消息 %s",
+	"Recycle bin batch %s not found":                              "未找到回收站批次 %s",
+	"No retention policy for chat %d":                             "会话 %d 没有通过 API 设置的保留策略",
+	"Unsupported state bundle version %d":                         "不支持的状态包版本 %d",
+	"Invalid backup name":                                         "备份名称无效",
+	"The index is not empty; set replace to restore over it":      "索引不为空；如需覆盖恢复，请设置 replace",
+	"Invalid snapshot name":                                       "快照名称无效",
+	"Snapshot %s is not mounted":                                  "快照 %s 未挂载",
+	"Snapshots are searched via POST /api/v1/search/snapshot":     "快照需通过 POST /api/v1/search/snapshot 搜索",
+	"Chat %d is not under legal hold":                             "会话 %d 未处于法律保留状态",
+	"This would delete messages of chats under legal hold":        "该操作会删除处于法律保留状态的会话消息",
+	"Wait for the running reindex to finish":                      "请等待正在进行的重建索引完成",
+	"The index is not versioned yet; run a reindex first":         "索引尚未版本化，请先重建索引",
+	"No older index version to roll back to":                      "没有可回滚的旧索引版本",
+	"Index version %s not found":                                  "未找到索引版本 %s",
+	"No public stats for chat %s":                                 "会话 %s 没有公开统计",
+	"Tenant %s already exists":                                    "租户 %s 已存在",
+	"Tenant %s not found":                                         "未找到租户 %s",
+	"At least one of text, caption or edit_date is required":      "text、caption 和 edit_date 至少需要提供一项",
+	"at least one filter (keyword, chat_id, chat_type, username, sender_id, date_from, date_to) is required":                               "至少需要一个过滤条件（keyword、chat_id、chat_type、username、sender_id、date_from、date_to）",
+	"offset_id and limit must not be negative":                                                                                             "offset_id 和 limit 不能为负数",
+	"Tenant IDs are 1-32 lowercase letters, digits and underscores, starting with a letter, other than trash, audit, snapshot and default": "租户 ID 须为 1-32 个小写字母、数字或下划线，以字母开头，且不能是 trash、audit、snapshot 或 default",
+	"Invalid after cursor":         "after 游标无效",
+	"format must be json or csv":   "format 必须为 json 或 csv",
+	"format must be ndjson or zip": "format 必须为 ndjson 或 zip",
+	"interval must be day or week": "interval 必须为 day 或 week",
+	"This would delete about %d messages, more than the %d allowed without confirmation; repeat the request with force=true to proceed": "此操作将删除约 %d 条消息，超过了无需确认即可删除的上限 %d 条；如确认执行，请带上 force=true 重新请求",
+	"This would delete all %d messages; repeat the request with confirm=%s within %d seconds to proceed":                                "此操作将删除全部 %d 条消息；如确认执行，请带上 confirm=%s 并在 %d 秒内重新请求",
+	"Invalid or expired confirmation token; request a new one without confirm":                                                          "确认令牌无效或已过期；请不带 confirm 重新请求以获取新令牌",
+	"Job not found":                        "未找到任务",
+	"A %s job is already running":          "已有 %s 任务正在运行",
+	"No search profile for %s":             "%s 没有搜索配置",
+	"No active session":                    "没有有效的会话",
+	"path %s cannot be signed":             "路径 %s 不能签名",
+	"issuer %s is not an allowed issuer":   "签发方 %s 不在允许的签发方之列",
+	"role must be reader, writer or admin": "role 必须为 reader、writer 或 admin",
+	"semantic search ranks by similarity and cannot be combined with sort_by or boost_by": "语义搜索按相似度排序，不能与 sort_by 或 boost_by 同时使用",
+	"semantic search requires a keyword":                                                  "语义搜索需要提供关键词",
+	"lexical_weight, semantic_weight and rerank_top_k require semantic_mode hybrid":       "lexical_weight、semantic_weight 和 rerank_top_k 仅适用于 semantic_mode 为 hybrid 的搜索",
+	"lexical_weight and semantic_weight cannot both be 0":                                 "lexical_weight 和 semantic_weight 不能同时为 0",
+	"Invalid message_id":                                 "message_id 无效",
+	"A message may carry at most %d tags":                "每条消息最多只能有 %d 个标签",
+	"Message %s is not in the Saved Messages of user %d": "消息 %s 不在用户 %d 的收藏夹中",
+
+	// Failures
+	"Search query failed":                                             "搜索失败",
+	"Search engine is not available":                                  "搜索引擎不可用",
+	"Failed to index message":                                         "消息索引失败",
+	"Failed to batch index messages":                                  "批量索引消息失败",
+	"Failed to batch index messages (indexed %d before the failure)":  "批量索引消息失败（失败前已索引 %d 条）",
+	"Failed to get message":                                           "获取消息失败",
+	"Failed to fetch message context":                                 "获取消息上下文失败",
+	"Failed to update message":                                        "更新消息失败",
+	"Failed to delete message":                                        "删除消息失败",
+	"Failed to delete messages":                                       "删除消息失败",
+	"Failed to delete messages by query":                              "按条件删除消息失败",
+	"Failed to delete user messages":                                  "删除用户消息失败",
+	"Failed to soft-delete message":                                   "标记删除消息失败",
+	"Failed to retrieve statistics":                                   "获取统计信息失败",
+	"Failed to retrieve user statistics":                              "获取用户统计信息失败",
+	"Failed to retrieve top messages":                                 "获取热门消息失败",
+	"Failed to delete capture rule":                                   "删除采集规则失败",
+	"Failed to delete subscription":                                   "删除订阅失败",
+	"Failed to encode usage records":                                  "编码用量记录失败",
+	"Failed to estimate affected messages":                            "估算受影响的消息数失败",
+	"Failed to issue clear confirmation":                              "生成清空确认令牌失败",
+	"Failed to move messages to the recycle bin":                      "将消息移入回收站失败",
+	"Failed to list the recycle bin":                                  "获取回收站列表失败",
+	"Failed to restore deleted messages":                              "恢复已删除的消息失败",
+	"Failed to purge deleted messages":                                "彻底删除已删除的消息失败",
+	"Failed to export user messages":                                  "导出用户消息失败",
+	"Failed to export user messages (exported %d before the failure)": "导出用户消息失败（失败前已导出 %d 条）",
+	"Failed to list chats":                                            "获取会话列表失败",
+	"Failed to resolve chat titles":                                   "解析会话标题失败",
+	"Failed to restore messages from the recycle bin":                 "从回收站恢复消息失败",
+	"Failed to embed the search query":                                "生成搜索语句向量失败",
+	"Failed to sign capture config":                                   "签名采集配置失败",
+	"Signed capture config requires a JWT private key":                "签名采集配置需要 JWT 私钥",
+	"Failed to save search profile":                                   "保存搜索配置失败",
+	"Failed to delete search profile":                                 "删除搜索配置失败",
+	"Failed to create session":                                        "创建会话失败",
+	"Failed to mint token":                                            "签发令牌失败",
+	"Failed to save retention policy":                                 "保存保留策略失败",
+	"Failed to delete retention policy":                               "删除保留策略失败",
+	"Failed to import state":                                          "导入状态失败",
+	"Failed to list snapshots":                                        "获取快照列表失败",
+	"Failed to unmount snapshot":                                      "卸载快照失败",
+	"Failed to save legal hold":                                       "保存法律保留失败",
+	"Failed to release legal hold":                                    "解除法律保留失败",
+	"Failed to verify the audit log":                                  "校验审计日志失败",
+	"Failed to list index versions":                                   "获取索引版本列表失败",
+	"Failed to roll back the index":                                   "回滚索引失败",
+	"Failed to save tenant":                                           "保存租户失败",
+	"Failed to reload the dictionary":                                 "重新加载词典失败",
+	"Failed to reload the API keys: %s":                               "重新加载 API 密钥失败：%s",
+	"Failed to redeliver dead-lettered events":                        "重新投递死信事件失败",
+	"Command cleanup failed":                                          "清理命令消息失败",
+	"Failed to save tags":                                             "保存标签失败",
+	"Failed to retrieve Saved Messages stats":                         "获取收藏夹统计失败",
+	"Failed to build Saved Messages digest":                           "生成收藏夹摘要失败",
+
+	// Disabled features
+	"The built-in Telegram client is not enabled": "内置 Telegram 客户端未启用",
+	"Capture rules are not enabled":               "采集规则未启用",
+	"Keyword subscriptions are not enabled":       "关键词订阅未启用",
+	"Usage tracking is not enabled":               "用量统计未启用",
+	"The recycle bin is not enabled":              "回收站未启用",
+	"Clear is not enabled":                        "清空功能未启用",
+	"Search profiles are not enabled":             "搜索配置未启用",
+	"Semantic search is not enabled":              "语义搜索未启用",
+	"Reranking is not enabled":                    "重排序未启用",
+	"Query translation is not enabled":            "查询翻译未启用",
+	"Sessions are not enabled":                    "会话未启用",
+	"Signed URLs are not enabled":                 "签名链接未启用",
+	"Token minting is not enabled":                "令牌签发未启用",
+	"Retention is not enabled":                    "数据保留策略未启用",
+	"Replication is not enabled":                  "数据复制未启用",
+	"Lifecycle events are not enabled":            "生命周期事件未启用",
+	"The scheduler is not enabled":                "定时任务未启用",
+	"Backups are not enabled":                     "备份未启用",
+	"Snapshot search is not enabled":              "快照搜索未启用",
+	"Legal holds are not enabled":                 "法律保留未启用",
+	"The audit log is not enabled":                "审计日志未启用",
+	"Public stats are not enabled":                "公开统计未启用",
+	"Tenants are not enabled":                     "租户未启用",
+	"The dictionary is not enabled":               "词典未启用",
+	"API key authentication is not enabled":       "API 密钥认证未启用",
+	"Saved Messages support is not enabled":       "收藏夹功能未启用",
+}
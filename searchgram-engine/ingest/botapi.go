@@ -0,0 +1,351 @@
+package ingest
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/zhishengyuan/searchgram-engine/models"
+)
+
+// BotUpdate is the subset of a Telegram Bot API Update that carries messages
+type BotUpdate struct {
+	UpdateID          int64       `json:"update_id"`
+	Message           *BotMessage `json:"message"`
+	EditedMessage     *BotMessage `json:"edited_message"`
+	ChannelPost       *BotMessage `json:"channel_post"`
+	EditedChannelPost *BotMessage `json:"edited_channel_post"`
+}
+
+// BotMessage is a Telegram Bot API Message
+type BotMessage struct {
+	MessageID       int64       `json:"message_id"`
+	From            *BotUser    `json:"from"`
+	SenderChat      *BotChat    `json:"sender_chat"`
+	Chat            BotChat     `json:"chat"`
+	Date            int64       `json:"date"`
+	EditDate        int64       `json:"edit_date"`
+	ForwardOrigin   *BotOrigin  `json:"forward_origin"`
+	ReplyToMessage  *BotReply   `json:"reply_to_message"`
+	MessageThreadID int64       `json:"message_thread_id"`
+	IsTopicMessage  bool        `json:"is_topic_message"`
+	Text            string      `json:"text"`
+	Entities        []BotEntity `json:"entities"`
+	Caption         string      `json:"caption"`
+	CaptionEntities []BotEntity `json:"caption_entities"`
+	Sticker         *BotSticker `json:"sticker"`
+
+	Photo     json.RawMessage `json:"photo"`
+	Video     json.RawMessage `json:"video"`
+	Voice     json.RawMessage `json:"voice"`
+	Audio     json.RawMessage `json:"audio"`
+	Animation json.RawMessage `json:"animation"`
+	Document  json.RawMessage `json:"document"`
+	VideoNote json.RawMessage `json:"video_note"`
+
+	Location *BotLocation `json:"location"` // Also set on venue messages
+	Poll     *BotPoll     `json:"poll"`
+}
+
+// BotFile is the metadata shared by Bot API file objects (Document, Video,
+// Audio, PhotoSize, ...)
+type BotFile struct {
+	FileName string `json:"file_name"`
+	MimeType string `json:"mime_type"`
+	FileSize int64  `json:"file_size"`
+}
+
+// BotLocation is a Telegram Bot API Location
+type BotLocation struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+}
+
+// BotPoll is the part of a Telegram Bot API Poll that is indexed
+type BotPoll struct {
+	Question string `json:"question"`
+	Options  []struct {
+		Text string `json:"text"`
+	} `json:"options"`
+}
+
+// BotReply is the part of a replied-to Bot API Message that is indexed
+type BotReply struct {
+	MessageID int64 `json:"message_id"`
+}
+
+// BotUser is a Telegram Bot API User
+type BotUser struct {
+	ID        int64  `json:"id"`
+	IsBot     bool   `json:"is_bot"`
+	FirstName string `json:"first_name"`
+	LastName  string `json:"last_name"`
+	Username  string `json:"username"`
+}
+
+// BotChat is a Telegram Bot API Chat
+type BotChat struct {
+	ID        int64  `json:"id"`
+	Type      string `json:"type"` // private, group, supergroup or channel
+	Title     string `json:"title"`
+	Username  string `json:"username"`
+	FirstName string `json:"first_name"`
+	LastName  string `json:"last_name"`
+}
+
+// BotOrigin is a Telegram Bot API MessageOrigin
+type BotOrigin struct {
+	Type           string   `json:"type"` // user, hidden_user, chat or channel
+	Date           int64    `json:"date"`
+	SenderUser     *BotUser `json:"sender_user"`
+	SenderUserName string   `json:"sender_user_name"`
+	SenderChat     *BotChat `json:"sender_chat"`
+	Chat           *BotChat `json:"chat"`
+}
+
+// BotEntity is a Telegram Bot API MessageEntity
+type BotEntity struct {
+	Type   string   `json:"type"`
+	Offset int      `json:"offset"`
+	Length int      `json:"length"`
+	URL    string   `json:"url"`
+	User   *BotUser `json:"user"`
+}
+
+// BotSticker is the part of a Telegram Bot API Sticker that is indexed
+type BotSticker struct {
+	Emoji   string `json:"emoji"`
+	SetName string `json:"set_name"`
+}
+
+// Extract returns the message carried by the update and whether it is an edit
+func (u *BotUpdate) Extract() (message *BotMessage, edited bool) {
+	switch {
+	case u.Message != nil:
+		return u.Message, false
+	case u.ChannelPost != nil:
+		return u.ChannelPost, false
+	case u.EditedMessage != nil:
+		return u.EditedMessage, true
+	case u.EditedChannelPost != nil:
+		return u.EditedChannelPost, true
+	}
+	return nil, false
+}
+
+// ConvertBotMessage builds the indexed document for a Bot API message,
+// mirroring the fields produced by the Python client's MessageConverter
+func ConvertBotMessage(m *BotMessage) models.Message {
+	chatType := strings.ToUpper(m.Chat.Type)
+	chatTitle := m.Chat.Title
+	if chatTitle == "" {
+		chatTitle = strings.TrimSpace(m.Chat.FirstName + " " + m.Chat.LastName)
+	}
+
+	message := models.Message{
+		ID:           models.MessageDocumentID(m.Chat.ID, m.MessageID),
+		MessageID:    m.MessageID,
+		ChatID:       m.Chat.ID,
+		Timestamp:    m.Date,
+		Date:         m.Date,
+		ChatType:     chatType,
+		ChatTitle:    chatTitle,
+		ChatUsername: m.Chat.Username,
+		Chat: models.Chat{
+			ID:       m.Chat.ID,
+			Type:     chatType,
+			Title:    chatTitle,
+			Username: m.Chat.Username,
+		},
+	}
+
+	// Sender: sender_chat (anonymous admins, channel posts) takes precedence
+	switch {
+	case m.SenderChat != nil:
+		message.SenderType = "chat"
+		message.SenderID = m.SenderChat.ID
+		message.SenderName = m.SenderChat.Title
+		message.SenderUsername = m.SenderChat.Username
+		if m.SenderChat.Title != "" {
+			title := m.SenderChat.Title
+			message.SenderChatTitle = &title
+		}
+	case m.From != nil:
+		message.SenderType = "user"
+		message.SenderID = m.From.ID
+		message.SenderName = strings.TrimSpace(m.From.FirstName + " " + m.From.LastName)
+		message.SenderUsername = m.From.Username
+		if m.From.FirstName != "" {
+			first := m.From.FirstName
+			message.SenderFirstName = &first
+		}
+		if m.From.LastName != "" {
+			last := m.From.LastName
+			message.SenderLastName = &last
+		}
+		message.FromUser = botUser(m.From)
+	default:
+		message.SenderType = "unknown"
+	}
+
+	if origin := m.ForwardOrigin; origin != nil {
+		message.IsForwarded = true
+		if origin.Date != 0 {
+			ts := origin.Date
+			message.ForwardTimestamp = &ts
+		}
+
+		var (
+			fromType string
+			fromID   *int64
+			fromName string
+		)
+		switch {
+		case origin.Chat != nil:
+			fromType, fromID, fromName = "chat", &origin.Chat.ID, origin.Chat.Title
+		case origin.SenderChat != nil:
+			fromType, fromID, fromName = "chat", &origin.SenderChat.ID, origin.SenderChat.Title
+		case origin.SenderUser != nil:
+			fromType, fromID = "user", &origin.SenderUser.ID
+			fromName = strings.TrimSpace(origin.SenderUser.FirstName + " " + origin.SenderUser.LastName)
+		case origin.SenderUserName != "":
+			fromType, fromName = "name_only", origin.SenderUserName
+		}
+		if fromType != "" {
+			message.ForwardFromType = &fromType
+			message.ForwardFromID = fromID
+			if fromName != "" {
+				message.ForwardFromName = &fromName
+			}
+		}
+	}
+
+	// In forum topics, messages that are not replies point at the topic's
+	// creation message
+	if m.IsTopicMessage {
+		message.ThreadID = m.MessageThreadID
+	}
+	if m.ReplyToMessage != nil && !(m.IsTopicMessage && m.ReplyToMessage.MessageID == m.MessageThreadID) {
+		message.ReplyToMessageID = m.ReplyToMessage.MessageID
+	}
+
+	entities := m.Entities
+	switch {
+	case m.Sticker != nil:
+		message.ContentType = "sticker"
+		message.MediaType = "sticker"
+		if m.Sticker.Emoji != "" {
+			emoji := m.Sticker.Emoji
+			message.StickerEmoji = &emoji
+		}
+		if m.Sticker.SetName != "" {
+			setName := m.Sticker.SetName
+			message.StickerSetName = &setName
+		}
+	case m.Text != "":
+		message.ContentType = "text"
+		message.Text = m.Text
+	default:
+		message.ContentType = botMediaType(m)
+		botMedia(m, &message)
+		if m.Caption != "" {
+			caption := m.Caption
+			message.Caption = &caption
+		}
+		entities = m.CaptionEntities
+	}
+	if m.Location != nil {
+		message.Location = &models.GeoPoint{Lat: m.Location.Latitude, Lon: m.Location.Longitude}
+	}
+	if m.Poll != nil {
+		message.IsPoll = true
+		message.PollQuestion = m.Poll.Question
+		for _, option := range m.Poll.Options {
+			message.PollOptions = append(message.PollOptions, option.Text)
+		}
+	}
+
+	for _, entity := range entities {
+		converted := models.MessageEntity{
+			Type:   strings.ToUpper(entity.Type),
+			Offset: entity.Offset,
+			Length: entity.Length,
+			URL:    entity.URL,
+		}
+		if entity.User != nil {
+			user := botUser(entity.User)
+			converted.UserID = &user.ID
+			converted.User = &user
+		}
+		message.Entities = append(message.Entities, converted)
+	}
+
+	return message
+}
+
+// botMediaType classifies a media message
+func botMediaType(m *BotMessage) string {
+	switch {
+	case len(m.Photo) > 0:
+		return "photo"
+	case len(m.Animation) > 0:
+		// Animations also carry a document field, so check them first
+		return "animation"
+	case len(m.Video) > 0, len(m.VideoNote) > 0:
+		return "video"
+	case len(m.Voice) > 0:
+		return "voice"
+	case len(m.Audio) > 0:
+		return "audio"
+	case len(m.Document) > 0:
+		return "document"
+	}
+	return "other"
+}
+
+// botMedia sets the media type and file metadata of a media message
+func botMedia(m *BotMessage, message *models.Message) {
+	var raw json.RawMessage
+	switch {
+	case len(m.Photo) > 0:
+		// Photos come as an array of sizes, smallest first
+		var sizes []BotFile
+		if err := json.Unmarshal(m.Photo, &sizes); err == nil && len(sizes) > 0 {
+			message.MediaType = "photo"
+			message.MimeType = "image/jpeg"
+			message.FileSize = sizes[len(sizes)-1].FileSize
+		}
+		return
+	case len(m.Animation) > 0:
+		message.MediaType, raw = "animation", m.Animation
+	case len(m.VideoNote) > 0:
+		message.MediaType, raw = "video_note", m.VideoNote
+	case len(m.Video) > 0:
+		message.MediaType, raw = "video", m.Video
+	case len(m.Voice) > 0:
+		message.MediaType, raw = "voice", m.Voice
+	case len(m.Audio) > 0:
+		message.MediaType, raw = "audio", m.Audio
+	case len(m.Document) > 0:
+		message.MediaType, raw = "document", m.Document
+	default:
+		return
+	}
+
+	var file BotFile
+	if err := json.Unmarshal(raw, &file); err == nil {
+		message.FileName = file.FileName
+		message.MimeType = file.MimeType
+		message.FileSize = file.FileSize
+	}
+}
+
+// botUser converts a Bot API user to the legacy nested user object
+func botUser(u *BotUser) models.User {
+	return models.User{
+		ID:        u.ID,
+		IsBot:     u.IsBot,
+		FirstName: u.FirstName,
+		LastName:  u.LastName,
+		Username:  u.Username,
+	}
+}
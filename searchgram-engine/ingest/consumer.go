@@ -0,0 +1,61 @@
+package ingest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/zhishengyuan/searchgram-engine/models"
+)
+
+// BatchIndexer indexes a batch of messages and reports how many were indexed
+type BatchIndexer func(messages []models.Message) (int, error)
+
+// maxRetryBackoff caps the delay between attempts to index a consumed batch
+const maxRetryBackoff = 30 * time.Second
+
+// decodeMessage parses one consumed record as message JSON
+func decodeMessage(data []byte) (models.Message, error) {
+	var message models.Message
+	if err := json.Unmarshal(data, &message); err != nil {
+		return message, err
+	}
+	if message.ID == "" {
+		return message, fmt.Errorf("message is missing ID")
+	}
+	return message, nil
+}
+
+// indexWithRetry indexes messages, retrying with backoff until it succeeds.
+// Returns false if ctx was cancelled first, in which case the source should
+// leave the batch unacknowledged so it is redelivered.
+func indexWithRetry(ctx context.Context, source string, index BatchIndexer, messages []models.Message) bool {
+	backoff := time.Second
+	for {
+		indexed, err := index(messages)
+		if err == nil {
+			log.WithFields(log.Fields{
+				"source":  source,
+				"count":   len(messages),
+				"indexed": indexed,
+			}).Debug("Indexed consumed batch")
+			return true
+		}
+
+		log.WithError(err).WithFields(log.Fields{
+			"source": source,
+			"count":  len(messages),
+		}).Warn("Failed to index consumed batch, retrying")
+
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return false
+		}
+		if backoff *= 2; backoff > maxRetryBackoff {
+			backoff = maxRetryBackoff
+		}
+	}
+}
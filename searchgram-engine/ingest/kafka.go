@@ -0,0 +1,145 @@
+package ingest
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	log "github.com/sirupsen/logrus"
+	"github.com/zhishengyuan/searchgram-engine/models"
+)
+
+// KafkaConfig holds Kafka consumer configuration
+type KafkaConfig struct {
+	Brokers      []string
+	Topic        string
+	GroupID      string
+	BatchSize    int           // Maximum records per bulk request
+	BatchTimeout time.Duration // Flush a partial batch after this long
+}
+
+// KafkaConsumer reads message JSON from a Kafka topic and bulk-indexes it.
+// Offsets are committed only after a batch is indexed (at-least-once).
+type KafkaConsumer struct {
+	cfg    KafkaConfig
+	index  BatchIndexer
+	reader *kafka.Reader
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// NewKafkaConsumer creates a consumer; call Start to begin consuming
+func NewKafkaConsumer(cfg KafkaConfig, index BatchIndexer) *KafkaConsumer {
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 500
+	}
+	if cfg.BatchTimeout <= 0 {
+		cfg.BatchTimeout = time.Second
+	}
+
+	reader := kafka.NewReader(kafka.ReaderConfig{
+		Brokers:  cfg.Brokers,
+		Topic:    cfg.Topic,
+		GroupID:  cfg.GroupID,
+		MinBytes: 1,
+		MaxBytes: 10e6,
+	})
+
+	return &KafkaConsumer{
+		cfg:    cfg,
+		index:  index,
+		reader: reader,
+		done:   make(chan struct{}),
+	}
+}
+
+// Start begins consuming in the background
+func (k *KafkaConsumer) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	k.cancel = cancel
+
+	go func() {
+		defer close(k.done)
+		k.run(ctx)
+	}()
+
+	log.WithFields(log.Fields{
+		"brokers": k.cfg.Brokers,
+		"topic":   k.cfg.Topic,
+		"group":   k.cfg.GroupID,
+	}).Info("Kafka consumer started")
+}
+
+// Stop stops consuming; an in-flight batch is finished and committed first
+func (k *KafkaConsumer) Stop() {
+	if k == nil || k.cancel == nil {
+		return
+	}
+	k.cancel()
+	<-k.done
+
+	if err := k.reader.Close(); err != nil {
+		log.WithError(err).Warn("Failed to close Kafka reader")
+	}
+	log.Info("Kafka consumer stopped")
+}
+
+// run fetches records into batches and indexes them until ctx is cancelled
+func (k *KafkaConsumer) run(ctx context.Context) {
+	records := make([]kafka.Message, 0, k.cfg.BatchSize)
+
+	for ctx.Err() == nil {
+		deadline := time.Now().Add(k.cfg.BatchTimeout)
+		for len(records) < k.cfg.BatchSize {
+			fetchCtx, cancel := context.WithDeadline(ctx, deadline)
+			record, err := k.reader.FetchMessage(fetchCtx)
+			cancel()
+			if err != nil {
+				if !errors.Is(err, context.DeadlineExceeded) && !errors.Is(err, context.Canceled) {
+					log.WithError(err).Warn("Failed to fetch Kafka message")
+					time.Sleep(time.Second)
+				}
+				break
+			}
+			records = append(records, record)
+		}
+
+		if len(records) == 0 {
+			continue
+		}
+		if !k.process(ctx, records) {
+			return
+		}
+		records = records[:0]
+	}
+}
+
+// process indexes a batch, retrying until it succeeds or ctx is cancelled,
+// then commits the offsets. Returns false if the consumer should stop.
+func (k *KafkaConsumer) process(ctx context.Context, records []kafka.Message) bool {
+	messages := make([]models.Message, 0, len(records))
+	for _, record := range records {
+		message, err := decodeMessage(record.Value)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"partition": record.Partition,
+				"offset":    record.Offset,
+			}).WithError(err).Warn("Skipping invalid Kafka message")
+			continue
+		}
+		messages = append(messages, message)
+	}
+
+	// Uncommitted records are redelivered after restart
+	if len(messages) > 0 && !indexWithRetry(ctx, "kafka", k.index, messages) {
+		return false
+	}
+
+	// Commit even if the consumer is stopping; the batch is already indexed
+	if err := k.reader.CommitMessages(context.Background(), records...); err != nil {
+		log.WithError(err).Warn("Failed to commit Kafka offsets")
+	}
+	return true
+}
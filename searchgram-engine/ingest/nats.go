@@ -0,0 +1,174 @@
+package ingest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+	log "github.com/sirupsen/logrus"
+	"github.com/zhishengyuan/searchgram-engine/models"
+)
+
+// NATSConfig holds NATS JetStream consumer configuration
+type NATSConfig struct {
+	URL          string
+	Stream       string
+	Subject      string // Optional subject filter within the stream
+	Durable      string // Durable consumer name, so progress survives restarts
+	BatchSize    int
+	BatchTimeout time.Duration
+	AckWait      time.Duration // Redelivery timeout for unacknowledged messages
+}
+
+// NATSConsumer pulls message JSON from a JetStream stream and bulk-indexes it.
+// Messages are acknowledged only after their batch is indexed (at-least-once).
+type NATSConsumer struct {
+	cfg      NATSConfig
+	index    BatchIndexer
+	conn     *nats.Conn
+	consumer jetstream.Consumer
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// NewNATSConsumer connects to NATS and creates or updates the durable pull consumer
+func NewNATSConsumer(cfg NATSConfig, index BatchIndexer) (*NATSConsumer, error) {
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 500
+	}
+	if cfg.BatchTimeout <= 0 {
+		cfg.BatchTimeout = time.Second
+	}
+	if cfg.AckWait <= 0 {
+		cfg.AckWait = time.Minute
+	}
+
+	conn, err := nats.Connect(cfg.URL,
+		nats.Name("searchgram-engine"),
+		nats.MaxReconnects(-1),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to NATS: %w", err)
+	}
+
+	js, err := jetstream.New(conn)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to create JetStream context: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	consumer, err := js.CreateOrUpdateConsumer(ctx, cfg.Stream, jetstream.ConsumerConfig{
+		Durable:       cfg.Durable,
+		FilterSubject: cfg.Subject,
+		AckPolicy:     jetstream.AckExplicitPolicy,
+		AckWait:       cfg.AckWait,
+		MaxAckPending: cfg.BatchSize * 4,
+	})
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to create JetStream consumer %s on stream %s: %w", cfg.Durable, cfg.Stream, err)
+	}
+
+	return &NATSConsumer{
+		cfg:      cfg,
+		index:    index,
+		conn:     conn,
+		consumer: consumer,
+		done:     make(chan struct{}),
+	}, nil
+}
+
+// Start begins consuming in the background
+func (n *NATSConsumer) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	n.cancel = cancel
+
+	go func() {
+		defer close(n.done)
+		n.run(ctx)
+	}()
+
+	log.WithFields(log.Fields{
+		"url":     n.cfg.URL,
+		"stream":  n.cfg.Stream,
+		"subject": n.cfg.Subject,
+		"durable": n.cfg.Durable,
+	}).Info("NATS JetStream consumer started")
+}
+
+// Stop stops consuming; an in-flight batch is finished and acknowledged first
+func (n *NATSConsumer) Stop() {
+	if n == nil || n.cancel == nil {
+		return
+	}
+	n.cancel()
+	<-n.done
+
+	n.conn.Close()
+	log.Info("NATS JetStream consumer stopped")
+}
+
+// run fetches batches until ctx is cancelled
+func (n *NATSConsumer) run(ctx context.Context) {
+	for ctx.Err() == nil {
+		batch, err := n.consumer.Fetch(n.cfg.BatchSize, jetstream.FetchMaxWait(n.cfg.BatchTimeout))
+		if err != nil {
+			log.WithError(err).Warn("Failed to fetch from JetStream")
+			select {
+			case <-time.After(time.Second):
+			case <-ctx.Done():
+			}
+			continue
+		}
+
+		var records []jetstream.Msg
+		for record := range batch.Messages() {
+			records = append(records, record)
+		}
+		if err := batch.Error(); err != nil && !errors.Is(err, nats.ErrTimeout) {
+			log.WithError(err).Warn("JetStream fetch ended with error")
+		}
+
+		if len(records) > 0 && !n.process(ctx, records) {
+			return
+		}
+	}
+}
+
+// process indexes a batch and acknowledges it. Invalid messages are
+// terminated so they are not redelivered. Returns false if the consumer
+// should stop; the batch is then left unacknowledged for redelivery.
+func (n *NATSConsumer) process(ctx context.Context, records []jetstream.Msg) bool {
+	messages := make([]models.Message, 0, len(records))
+	valid := records[:0]
+	for _, record := range records {
+		message, err := decodeMessage(record.Data())
+		if err != nil {
+			log.WithError(err).WithField("subject", record.Subject()).Warn("Skipping invalid JetStream message")
+			if err := record.Term(); err != nil {
+				log.WithError(err).Warn("Failed to terminate JetStream message")
+			}
+			continue
+		}
+		messages = append(messages, message)
+		valid = append(valid, record)
+	}
+
+	if len(messages) > 0 && !indexWithRetry(ctx, "nats", n.index, messages) {
+		return false
+	}
+
+	for _, record := range valid {
+		if err := record.Ack(); err != nil {
+			log.WithError(err).Warn("Failed to acknowledge JetStream message")
+		}
+	}
+	return true
+}
@@ -0,0 +1,210 @@
+package ingest
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/zhishengyuan/searchgram-engine/engines"
+	"github.com/zhishengyuan/searchgram-engine/models"
+)
+
+// ErrQueueFull is returned when the queue is at capacity
+var ErrQueueFull = errors.New("ingest queue is full")
+
+// Config holds write-behind queue configuration
+type Config struct {
+	Capacity      int           // Maximum buffered messages
+	FlushSize     int           // Flush once this many messages are buffered
+	FlushInterval time.Duration // Flush at least this often
+}
+
+// Queue is a bounded write-behind buffer that bulk-indexes messages in the background
+type Queue struct {
+	engine engines.SearchEngine
+	cfg    Config
+
+	mu       sync.Mutex
+	buffer   []models.Message
+	enqueued int64
+	flushed  int64
+	failed   int64
+
+	kick chan struct{}
+	stop chan struct{}
+	done chan struct{}
+}
+
+// NewQueue creates a queue; call Start to begin flushing
+func NewQueue(engine engines.SearchEngine, cfg Config) *Queue {
+	if cfg.Capacity <= 0 {
+		cfg.Capacity = 10000
+	}
+	if cfg.FlushSize <= 0 || cfg.FlushSize > cfg.Capacity {
+		cfg.FlushSize = cfg.Capacity
+	}
+	if cfg.FlushInterval <= 0 {
+		cfg.FlushInterval = time.Second
+	}
+
+	return &Queue{
+		engine: engine,
+		cfg:    cfg,
+		buffer: make([]models.Message, 0, cfg.FlushSize),
+		kick:   make(chan struct{}, 1),
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+}
+
+// Enqueue buffers a message for indexing
+func (q *Queue) Enqueue(message models.Message) error {
+	q.mu.Lock()
+	if len(q.buffer) >= q.cfg.Capacity {
+		q.mu.Unlock()
+		return ErrQueueFull
+	}
+	q.buffer = append(q.buffer, message)
+	q.enqueued++
+	full := len(q.buffer) >= q.cfg.FlushSize
+	q.mu.Unlock()
+
+	if full {
+		select {
+		case q.kick <- struct{}{}:
+		default:
+		}
+	}
+	return nil
+}
+
+// Stats returns the current queue counters
+func (q *Queue) Stats() *models.IngestQueueStats {
+	if q == nil {
+		return nil
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	return &models.IngestQueueStats{
+		Depth:    len(q.buffer),
+		Capacity: q.cfg.Capacity,
+		Enqueued: q.enqueued,
+		Flushed:  q.flushed,
+		Failed:   q.failed,
+	}
+}
+
+// Start begins background flushing
+func (q *Queue) Start() {
+	go func() {
+		defer close(q.done)
+
+		ticker := time.NewTicker(q.cfg.FlushInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				q.flush()
+			case <-q.kick:
+				q.flush()
+			case <-q.stop:
+				return
+			}
+		}
+	}()
+
+	log.WithFields(log.Fields{
+		"capacity":       q.cfg.Capacity,
+		"flush_size":     q.cfg.FlushSize,
+		"flush_interval": q.cfg.FlushInterval.String(),
+	}).Info("Ingest queue started")
+}
+
+// Stop stops the flusher and drains the remaining messages, giving up when ctx expires
+func (q *Queue) Stop(ctx context.Context) {
+	if q == nil {
+		return
+	}
+	close(q.stop)
+	<-q.done
+
+	for {
+		q.mu.Lock()
+		remaining := len(q.buffer)
+		q.mu.Unlock()
+		if remaining == 0 {
+			log.Info("Ingest queue drained")
+			return
+		}
+		if ctx.Err() != nil {
+			log.WithField("dropped", remaining).Error("Timed out draining ingest queue")
+			return
+		}
+		if !q.flush() {
+			// Back off briefly before retrying a failing engine
+			select {
+			case <-time.After(time.Second):
+			case <-ctx.Done():
+			}
+		}
+	}
+}
+
+// flush indexes up to FlushSize buffered messages; failed bulk requests are
+// put back at the front of the queue. Returns false if the bulk request failed.
+func (q *Queue) flush() bool {
+	q.mu.Lock()
+	n := len(q.buffer)
+	if n == 0 {
+		q.mu.Unlock()
+		return true
+	}
+	if n > q.cfg.FlushSize {
+		n = q.cfg.FlushSize
+	}
+	batch := make([]models.Message, n)
+	copy(batch, q.buffer[:n])
+	q.buffer = append(q.buffer[:0], q.buffer[n:]...)
+	q.mu.Unlock()
+
+	indexed, errs, err := q.engine.UpsertBatch(context.Background(), batch)
+	if err != nil {
+		log.WithError(err).WithField("count", len(batch)).Warn("Ingest queue flush failed, will retry")
+		q.requeue(batch)
+		return false
+	}
+
+	q.mu.Lock()
+	q.flushed += int64(indexed)
+	q.failed += int64(len(batch) - indexed)
+	q.mu.Unlock()
+
+	if len(errs) > 0 {
+		log.WithFields(log.Fields{
+			"indexed": indexed,
+			"failed":  len(batch) - indexed,
+			"errors":  errs,
+		}).Warn("Ingest queue flush had document errors")
+	} else {
+		log.WithField("count", indexed).Debug("Ingest queue flushed")
+	}
+	return true
+}
+
+// requeue puts a failed batch back at the front, dropping what no longer fits
+func (q *Queue) requeue(batch []models.Message) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	room := q.cfg.Capacity - len(q.buffer)
+	if room < len(batch) {
+		q.failed += int64(len(batch) - room)
+		log.WithField("dropped", len(batch)-room).Error("Ingest queue full, dropping messages from failed flush")
+		batch = batch[:room]
+	}
+	q.buffer = append(batch, q.buffer...)
+}
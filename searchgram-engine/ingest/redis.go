@@ -0,0 +1,262 @@
+package ingest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	log "github.com/sirupsen/logrus"
+	"github.com/zhishengyuan/searchgram-engine/models"
+)
+
+// RedisConfig holds Redis Streams consumer configuration
+type RedisConfig struct {
+	Addr         string
+	Password     string
+	DB           int
+	Stream       string
+	Group        string
+	Consumer     string // Consumer name within the group (default: hostname)
+	Field        string // Entry field holding the message JSON
+	BatchSize    int
+	BatchTimeout time.Duration // XREADGROUP block time
+	ClaimIdle    time.Duration // Claim entries left pending this long by other consumers (0 disables)
+}
+
+// RedisConsumer reads message JSON from a Redis Stream via XREADGROUP and
+// bulk-indexes it. Entries are XACKed only after indexing, and entries left
+// pending by a previous run are recovered on start.
+type RedisConsumer struct {
+	cfg    RedisConfig
+	index  BatchIndexer
+	client *redis.Client
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// NewRedisConsumer connects to Redis and ensures the consumer group exists
+func NewRedisConsumer(cfg RedisConfig, index BatchIndexer) (*RedisConsumer, error) {
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 500
+	}
+	if cfg.BatchTimeout <= 0 {
+		cfg.BatchTimeout = time.Second
+	}
+	if cfg.Field == "" {
+		cfg.Field = "message"
+	}
+	if cfg.Consumer == "" {
+		hostname, err := os.Hostname()
+		if err != nil {
+			hostname = "searchgram-engine"
+		}
+		cfg.Consumer = hostname
+	}
+
+	client := redis.NewClient(&redis.Options{
+		Addr:     cfg.Addr,
+		Password: cfg.Password,
+		DB:       cfg.DB,
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	err := client.XGroupCreateMkStream(ctx, cfg.Stream, cfg.Group, "0").Err()
+	if err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		client.Close()
+		return nil, fmt.Errorf("failed to create consumer group %s on stream %s: %w", cfg.Group, cfg.Stream, err)
+	}
+
+	return &RedisConsumer{
+		cfg:    cfg,
+		index:  index,
+		client: client,
+		done:   make(chan struct{}),
+	}, nil
+}
+
+// Start begins consuming in the background
+func (r *RedisConsumer) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	r.cancel = cancel
+
+	go func() {
+		defer close(r.done)
+		r.run(ctx)
+	}()
+
+	log.WithFields(log.Fields{
+		"addr":     r.cfg.Addr,
+		"stream":   r.cfg.Stream,
+		"group":    r.cfg.Group,
+		"consumer": r.cfg.Consumer,
+	}).Info("Redis Streams consumer started")
+}
+
+// Stop stops consuming; an in-flight batch is finished and acknowledged first
+func (r *RedisConsumer) Stop() {
+	if r == nil || r.cancel == nil {
+		return
+	}
+	r.cancel()
+	<-r.done
+
+	if err := r.client.Close(); err != nil {
+		log.WithError(err).Warn("Failed to close Redis client")
+	}
+	log.Info("Redis Streams consumer stopped")
+}
+
+// run recovers pending entries, then reads new ones until ctx is cancelled
+func (r *RedisConsumer) run(ctx context.Context) {
+	if !r.recoverPending(ctx) {
+		return
+	}
+
+	lastClaim := time.Now()
+	for ctx.Err() == nil {
+		if r.cfg.ClaimIdle > 0 && time.Since(lastClaim) >= r.cfg.ClaimIdle {
+			if !r.claimIdle(ctx) {
+				return
+			}
+			lastClaim = time.Now()
+		}
+
+		entries, err := r.read(ctx, ">")
+		if err != nil {
+			log.WithError(err).Warn("Failed to read from Redis stream")
+			select {
+			case <-time.After(time.Second):
+			case <-ctx.Done():
+			}
+			continue
+		}
+		if len(entries) > 0 && !r.process(ctx, entries) {
+			return
+		}
+	}
+}
+
+// recoverPending re-processes entries this consumer read but never acknowledged
+// (e.g. the engine stopped mid-batch), then claims idle entries of other consumers
+func (r *RedisConsumer) recoverPending(ctx context.Context) bool {
+	recovered := 0
+	for ctx.Err() == nil {
+		entries, err := r.read(ctx, "0")
+		if err != nil {
+			log.WithError(err).Warn("Failed to read pending Redis entries")
+			return ctx.Err() == nil
+		}
+		if len(entries) == 0 {
+			break
+		}
+		if !r.process(ctx, entries) {
+			return false
+		}
+		recovered += len(entries)
+	}
+	if recovered > 0 {
+		log.WithField("entries", recovered).Info("Recovered pending Redis stream entries")
+	}
+
+	if r.cfg.ClaimIdle > 0 {
+		return r.claimIdle(ctx)
+	}
+	return true
+}
+
+// claimIdle takes over and processes entries idle longer than ClaimIdle
+func (r *RedisConsumer) claimIdle(ctx context.Context) bool {
+	start := "0-0"
+	for ctx.Err() == nil {
+		entries, next, err := r.client.XAutoClaim(ctx, &redis.XAutoClaimArgs{
+			Stream:   r.cfg.Stream,
+			Group:    r.cfg.Group,
+			Consumer: r.cfg.Consumer,
+			MinIdle:  r.cfg.ClaimIdle,
+			Start:    start,
+			Count:    int64(r.cfg.BatchSize),
+		}).Result()
+		if err != nil {
+			log.WithError(err).Warn("Failed to claim idle Redis entries")
+			return true
+		}
+		if len(entries) > 0 {
+			log.WithField("entries", len(entries)).Info("Claimed idle Redis stream entries")
+			if !r.process(ctx, entries) {
+				return false
+			}
+		}
+		if next == "0-0" || next == "" {
+			return true
+		}
+		start = next
+	}
+	return false
+}
+
+// read issues XREADGROUP for new (">") or this consumer's pending ("0") entries
+func (r *RedisConsumer) read(ctx context.Context, id string) ([]redis.XMessage, error) {
+	args := &redis.XReadGroupArgs{
+		Group:    r.cfg.Group,
+		Consumer: r.cfg.Consumer,
+		Streams:  []string{r.cfg.Stream, id},
+		Count:    int64(r.cfg.BatchSize),
+	}
+	if id == ">" {
+		args.Block = r.cfg.BatchTimeout
+	}
+
+	streams, err := r.client.XReadGroup(ctx, args).Result()
+	if errors.Is(err, redis.Nil) || errors.Is(err, context.Canceled) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []redis.XMessage
+	for _, stream := range streams {
+		entries = append(entries, stream.Messages...)
+	}
+	return entries, nil
+}
+
+// process indexes a batch and acknowledges it. Invalid entries are
+// acknowledged without indexing. Returns false if the consumer should stop;
+// the batch then stays pending and is recovered on the next start.
+func (r *RedisConsumer) process(ctx context.Context, entries []redis.XMessage) bool {
+	messages := make([]models.Message, 0, len(entries))
+	ids := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		ids = append(ids, entry.ID)
+
+		raw, ok := entry.Values[r.cfg.Field].(string)
+		if !ok {
+			log.WithField("entry_id", entry.ID).Warnf("Skipping Redis entry without %q field", r.cfg.Field)
+			continue
+		}
+		message, err := decodeMessage([]byte(raw))
+		if err != nil {
+			log.WithError(err).WithField("entry_id", entry.ID).Warn("Skipping invalid Redis entry")
+			continue
+		}
+		messages = append(messages, message)
+	}
+
+	if len(messages) > 0 && !indexWithRetry(ctx, "redis", r.index, messages) {
+		return false
+	}
+
+	// Acknowledge even if the consumer is stopping; the batch is already indexed
+	if err := r.client.XAck(context.Background(), r.cfg.Stream, r.cfg.Group, ids...).Err(); err != nil {
+		log.WithError(err).Warn("Failed to acknowledge Redis stream entries")
+	}
+	return true
+}
@@ -0,0 +1,457 @@
+package ingest
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf16"
+
+	"github.com/zhishengyuan/searchgram-engine/models"
+)
+
+// desktopChatTypes maps Telegram Desktop export chat types to stored chat types
+var desktopChatTypes = map[string]string{
+	"personal_chat":      "PRIVATE",
+	"saved_messages":     "PRIVATE",
+	"bot_chat":           "BOT",
+	"private_group":      "GROUP",
+	"private_supergroup": "SUPERGROUP",
+	"public_supergroup":  "SUPERGROUP",
+	"private_channel":    "CHANNEL",
+	"public_channel":     "CHANNEL",
+}
+
+// desktopEntityTypes maps export text entity types whose names differ from the stored ones
+var desktopEntityTypes = map[string]string{
+	"link":         "URL",
+	"phone":        "PHONE_NUMBER",
+	"mention_name": "TEXT_MENTION",
+}
+
+// desktopMediaTypes maps export media_type values to content types
+var desktopMediaTypes = map[string]string{
+	"sticker":       "sticker",
+	"animation":     "animation",
+	"video_file":    "video",
+	"video_message": "video",
+	"voice_message": "voice",
+	"audio_file":    "audio",
+}
+
+// DesktopExportStats summarizes an imported Telegram Desktop export
+type DesktopExportStats struct {
+	Chats    int `json:"chats"`
+	Messages int `json:"messages"`
+	Ignored  int `json:"ignored"` // Service messages (joins, pins, ...)
+}
+
+// desktopChat is the chat currently being read
+type desktopChat struct {
+	id       int64
+	chatType string
+	name     string
+	seenID   bool
+}
+
+// desktopMessage is one entry of an export's messages array
+type desktopMessage struct {
+	ID            int64             `json:"id"`
+	Type          string            `json:"type"`
+	Date          string            `json:"date"`
+	DateUnix      string            `json:"date_unixtime"`
+	EditedUnix    string            `json:"edited_unixtime"`
+	From          *string           `json:"from"`
+	FromID        string            `json:"from_id"`
+	ForwardedFrom *string           `json:"forwarded_from"`
+	ReplyTo       int64             `json:"reply_to_message_id"`
+	Photo         string            `json:"photo"`
+	PhotoFileSize int64             `json:"photo_file_size"`
+	File          string            `json:"file"`
+	FileName      string            `json:"file_name"`
+	FileSize      int64             `json:"file_size"`
+	MimeType      string            `json:"mime_type"`
+	MediaType     string            `json:"media_type"`
+	StickerEmoji  string            `json:"sticker_emoji"`
+	Location      *desktopLocation  `json:"location_information"` // Location, live location and venue messages
+	Poll          *desktopPoll      `json:"poll"`
+	Reactions     []desktopReaction `json:"reactions"`
+	TextEntities  []desktopEntity   `json:"text_entities"`
+	Text          json.RawMessage   `json:"text"`
+}
+
+// desktopLocation is the position of a location or venue message
+type desktopLocation struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+}
+
+// desktopPoll is the question and answers of a poll message
+type desktopPoll struct {
+	Question string `json:"question"`
+	Answers  []struct {
+		Text string `json:"text"`
+	} `json:"answers"`
+}
+
+// desktopReaction is one reaction (emoji or custom emoji) with its count
+type desktopReaction struct {
+	Count int `json:"count"`
+}
+
+// desktopEntity is a piece of message text with its formatting
+type desktopEntity struct {
+	Type   string `json:"type"`
+	Text   string `json:"text"`
+	UserID int64  `json:"user_id"`
+	Href   string `json:"href"` // Target of text_link entities
+}
+
+// ImportDesktopExport streams the result.json of Telegram Desktop's "Export
+// chat history" (a single chat or a full account export) and passes each
+// message to emit. Messages are decoded one at a time, so exports of any size
+// can be imported.
+func ImportDesktopExport(r io.Reader, emit func(models.Message) error) (DesktopExportStats, error) {
+	var stats DesktopExportStats
+	dec := json.NewDecoder(r)
+
+	top := &desktopChat{}
+	err := readObject(dec, func(key string) error {
+		return readChatKey(dec, key, top, &stats, emit)
+	})
+	if err != nil {
+		return stats, err
+	}
+	if stats.Chats == 0 {
+		return stats, errors.New("no chats found: expected a Telegram Desktop result.json")
+	}
+	return stats, nil
+}
+
+// readChatKey handles one key of a chat object. Full exports nest chats
+// under chats.list and left_chats.list; the top level of a single-chat
+// export is itself a chat.
+func readChatKey(dec *json.Decoder, key string, chat *desktopChat, stats *DesktopExportStats, emit func(models.Message) error) error {
+	switch key {
+	case "id":
+		chat.seenID = true
+		return dec.Decode(&chat.id)
+	case "type":
+		return dec.Decode(&chat.chatType)
+	case "name":
+		var name *string
+		err := dec.Decode(&name)
+		if name != nil {
+			chat.name = *name
+		}
+		return err
+	case "messages":
+		if !chat.seenID {
+			return errors.New("chat id must precede its messages")
+		}
+		stats.Chats++
+		return readArray(dec, func() error {
+			var raw desktopMessage
+			if err := dec.Decode(&raw); err != nil {
+				return fmt.Errorf("chat %d: invalid message: %w", chat.id, err)
+			}
+			if raw.Type != "message" {
+				stats.Ignored++
+				return nil
+			}
+			stats.Messages++
+			return emit(convertDesktopMessage(chat, &raw))
+		})
+	case "chats", "left_chats":
+		return readObject(dec, func(key string) error {
+			if key != "list" {
+				return skipValue(dec)
+			}
+			return readArray(dec, func() error {
+				nested := &desktopChat{}
+				return readObject(dec, func(key string) error {
+					return readChatKey(dec, key, nested, stats, emit)
+				})
+			})
+		})
+	}
+	return skipValue(dec)
+}
+
+// convertDesktopMessage builds the indexed document for an export message
+func convertDesktopMessage(chat *desktopChat, raw *desktopMessage) models.Message {
+	chatType := desktopChatTypes[chat.chatType]
+	if chatType == "" {
+		chatType = "PRIVATE"
+	}
+	chatID := desktopChatID(chatType, chat.id)
+
+	timestamp := parseDesktopDate(raw.DateUnix, raw.Date)
+
+	message := models.Message{
+		ID:        models.MessageDocumentID(chatID, raw.ID),
+		MessageID: raw.ID,
+		ChatID:    chatID,
+		Timestamp: timestamp,
+		Date:      timestamp,
+		ChatType:  chatType,
+		ChatTitle: chat.name,
+		Chat: models.Chat{
+			ID:    chatID,
+			Type:  chatType,
+			Title: chat.name,
+		},
+	}
+
+	// Sender: from_id is "user<id>", "channel<id>" or "chat<id>"
+	senderName := ""
+	if raw.From != nil {
+		senderName = *raw.From
+	}
+	switch {
+	case strings.HasPrefix(raw.FromID, "user"):
+		id, _ := strconv.ParseInt(strings.TrimPrefix(raw.FromID, "user"), 10, 64)
+		message.SenderType = "user"
+		message.SenderID = id
+		message.SenderName = senderName
+		message.FromUser = models.User{ID: id, FirstName: senderName}
+		if senderName != "" {
+			message.SenderFirstName = &senderName
+		}
+	case strings.HasPrefix(raw.FromID, "channel"):
+		id, _ := strconv.ParseInt(strings.TrimPrefix(raw.FromID, "channel"), 10, 64)
+		message.SenderType = "chat"
+		message.SenderID = channelIDOffset - id
+		message.SenderName = senderName
+		if senderName != "" {
+			message.SenderChatTitle = &senderName
+		}
+	case strings.HasPrefix(raw.FromID, "chat"):
+		id, _ := strconv.ParseInt(strings.TrimPrefix(raw.FromID, "chat"), 10, 64)
+		message.SenderType = "chat"
+		message.SenderID = -id
+		message.SenderName = senderName
+		if senderName != "" {
+			message.SenderChatTitle = &senderName
+		}
+	default:
+		message.SenderType = "unknown"
+	}
+
+	// Exports only keep the name of the original sender
+	if raw.ForwardedFrom != nil {
+		message.IsForwarded = true
+		if *raw.ForwardedFrom != "" {
+			fromType := "name_only"
+			name := *raw.ForwardedFrom
+			message.ForwardFromType = &fromType
+			message.ForwardFromName = &name
+		}
+	}
+
+	message.ReplyToMessageID = raw.ReplyTo
+	for _, reaction := range raw.Reactions {
+		message.ReactionsCount += reaction.Count
+	}
+
+	if edited := parseDesktopDate(raw.EditedUnix, ""); edited > 0 {
+		message.EditCount = 1
+		message.LastEdited = edited
+	}
+
+	text, entities := desktopText(raw)
+	switch {
+	case raw.MediaType != "":
+		message.ContentType = desktopMediaTypes[raw.MediaType]
+		if message.ContentType == "" {
+			message.ContentType = "other"
+		}
+	case raw.Photo != "":
+		message.ContentType = "photo"
+	case raw.File != "":
+		message.ContentType = "document"
+	case raw.Location != nil, raw.Poll != nil:
+		message.ContentType = "other"
+	default:
+		message.ContentType = "text"
+	}
+
+	if raw.Location != nil {
+		message.Location = &models.GeoPoint{Lat: raw.Location.Latitude, Lon: raw.Location.Longitude}
+	}
+	if raw.Poll != nil {
+		message.IsPoll = true
+		message.PollQuestion = raw.Poll.Question
+		for _, answer := range raw.Poll.Answers {
+			message.PollOptions = append(message.PollOptions, answer.Text)
+		}
+	}
+
+	switch {
+	case raw.Photo != "":
+		message.MediaType = "photo"
+		message.MimeType = "image/jpeg"
+		message.FileSize = raw.PhotoFileSize
+	case raw.File != "" || raw.MediaType != "":
+		message.MediaType = message.ContentType
+		if raw.MediaType == "video_message" {
+			message.MediaType = "video_note"
+		}
+		message.FileName = raw.FileName
+		message.MimeType = raw.MimeType
+		message.FileSize = raw.FileSize
+	}
+
+	switch {
+	case message.ContentType == "sticker":
+		if raw.StickerEmoji != "" {
+			emoji := raw.StickerEmoji
+			message.StickerEmoji = &emoji
+		}
+	case message.ContentType == "text":
+		message.Text = text
+		message.Entities = entities
+	case text != "":
+		message.Caption = &text
+		message.Entities = entities
+	}
+
+	return message
+}
+
+// desktopText flattens the message text and computes entity offsets in UTF-16
+// code units. text_entities covers the whole text; older exports only have
+// text, which is a string or an array of strings and entity objects.
+func desktopText(raw *desktopMessage) (string, []models.MessageEntity) {
+	parts := raw.TextEntities
+	if len(parts) == 0 && len(raw.Text) > 0 {
+		var plain string
+		if err := json.Unmarshal(raw.Text, &plain); err == nil {
+			return plain, nil
+		}
+		var mixed []json.RawMessage
+		if err := json.Unmarshal(raw.Text, &mixed); err != nil {
+			return "", nil
+		}
+		for _, item := range mixed {
+			var entity desktopEntity
+			if err := json.Unmarshal(item, &entity.Text); err == nil {
+				entity.Type = "plain"
+			} else if err := json.Unmarshal(item, &entity); err != nil {
+				continue
+			}
+			parts = append(parts, entity)
+		}
+	}
+
+	var (
+		text     strings.Builder
+		entities []models.MessageEntity
+		offset   int
+	)
+	for _, part := range parts {
+		length := len(utf16.Encode([]rune(part.Text)))
+		if part.Type != "plain" && part.Type != "" {
+			entity := models.MessageEntity{
+				Type:   desktopEntityType(part.Type),
+				Offset: offset,
+				Length: length,
+				URL:    part.Href,
+			}
+			if part.UserID != 0 {
+				userID := part.UserID
+				entity.UserID = &userID
+			}
+			entities = append(entities, entity)
+		}
+		text.WriteString(part.Text)
+		offset += length
+	}
+	return text.String(), entities
+}
+
+// desktopEntityType maps an export entity type to the stored type
+func desktopEntityType(exportType string) string {
+	if name, ok := desktopEntityTypes[exportType]; ok {
+		return name
+	}
+	return strings.ToUpper(exportType)
+}
+
+// desktopChatID converts an export chat ID (always positive) to a Bot API style chat ID
+func desktopChatID(chatType string, id int64) int64 {
+	switch chatType {
+	case "SUPERGROUP", "CHANNEL":
+		return channelIDOffset - id
+	case "GROUP":
+		return -id
+	}
+	return id
+}
+
+// parseDesktopDate prefers the unix timestamp; older exports only have a
+// local time without offset, which is read as UTC
+func parseDesktopDate(unix, local string) int64 {
+	if ts, err := strconv.ParseInt(unix, 10, 64); err == nil {
+		return ts
+	}
+	if t, err := time.Parse("2006-01-02T15:04:05", local); err == nil {
+		return t.Unix()
+	}
+	return 0
+}
+
+// readObject consumes a JSON object, calling fn for each key; fn must consume the value
+func readObject(dec *json.Decoder, fn func(key string) error) error {
+	if err := expectDelim(dec, '{'); err != nil {
+		return err
+	}
+	for dec.More() {
+		token, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		key, ok := token.(string)
+		if !ok {
+			return fmt.Errorf("expected object key, got %v", token)
+		}
+		if err := fn(key); err != nil {
+			return err
+		}
+	}
+	return expectDelim(dec, '}')
+}
+
+// readArray consumes a JSON array, calling fn for each element; fn must consume the element
+func readArray(dec *json.Decoder, fn func() error) error {
+	if err := expectDelim(dec, '['); err != nil {
+		return err
+	}
+	for dec.More() {
+		if err := fn(); err != nil {
+			return err
+		}
+	}
+	return expectDelim(dec, ']')
+}
+
+// expectDelim consumes the given delimiter token
+func expectDelim(dec *json.Decoder, want json.Delim) error {
+	token, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if delim, ok := token.(json.Delim); !ok || delim != want {
+		return fmt.Errorf("expected %q, got %v", want, token)
+	}
+	return nil
+}
+
+// skipValue consumes and discards the next value
+func skipValue(dec *json.Decoder) error {
+	var discard json.RawMessage
+	return dec.Decode(&discard)
+}
@@ -0,0 +1,230 @@
+package ingest
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gotd/td/session"
+	"github.com/gotd/td/telegram"
+	"github.com/gotd/td/telegram/auth"
+	"github.com/gotd/td/telegram/updates"
+	"github.com/gotd/td/tg"
+	log "github.com/sirupsen/logrus"
+	"github.com/zhishengyuan/searchgram-engine/models"
+)
+
+// TelegramConfig holds the built-in MTProto client configuration
+type TelegramConfig struct {
+	AppID       int
+	AppHash     string
+	Phone       string
+	Password    string  // Two-step verification password, if set
+	SessionFile string  // Persisted authorization, so login happens once
+	Chats       []int64 // Bot API style chat IDs to index (empty = all)
+
+	BackfillDelay time.Duration // Pause between history pages during backfill
+}
+
+// TelegramSink receives the changes observed by the Telegram client
+type TelegramSink struct {
+	Index  BatchIndexer
+	Edit   func(message *models.Message) error
+	Delete func(chatID int64, messageID int64) error
+}
+
+// TelegramClient logs in as a user account and indexes new, edited and
+// deleted messages directly, without the separate Python client
+type TelegramClient struct {
+	cfg   TelegramConfig
+	sink  TelegramSink
+	chats map[int64]bool
+
+	mu  sync.Mutex
+	api *tg.Client // Set while logged in, used for backfills
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// NewTelegramClient creates a client; call Start to log in and listen
+func NewTelegramClient(cfg TelegramConfig, sink TelegramSink) *TelegramClient {
+	chats := make(map[int64]bool, len(cfg.Chats))
+	for _, id := range cfg.Chats {
+		chats[id] = true
+	}
+
+	return &TelegramClient{
+		cfg:   cfg,
+		sink:  sink,
+		chats: chats,
+		done:  make(chan struct{}),
+	}
+}
+
+// Start connects in the background, reconnecting with backoff if the session drops
+func (t *TelegramClient) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.cancel = cancel
+
+	go func() {
+		defer close(t.done)
+
+		backoff := time.Second
+		for ctx.Err() == nil {
+			err := t.run(ctx)
+			if ctx.Err() != nil {
+				return
+			}
+			log.WithError(err).WithField("retry_in", backoff.String()).Error("Telegram client stopped, reconnecting")
+			select {
+			case <-time.After(backoff):
+			case <-ctx.Done():
+				return
+			}
+			if backoff *= 2; backoff > maxRetryBackoff {
+				backoff = maxRetryBackoff
+			}
+		}
+	}()
+}
+
+// Stop disconnects from Telegram
+func (t *TelegramClient) Stop() {
+	if t == nil || t.cancel == nil {
+		return
+	}
+	t.cancel()
+	<-t.done
+	log.Info("Telegram client stopped")
+}
+
+// run logs in if necessary and processes updates until ctx is cancelled
+func (t *TelegramClient) run(ctx context.Context) error {
+	dispatcher := tg.NewUpdateDispatcher()
+	gaps := updates.New(updates.Config{Handler: dispatcher})
+
+	client := telegram.NewClient(t.cfg.AppID, t.cfg.AppHash, telegram.Options{
+		SessionStorage: &session.FileStorage{Path: t.cfg.SessionFile},
+		UpdateHandler:  gaps,
+	})
+
+	dispatcher.OnNewMessage(func(ctx context.Context, e tg.Entities, u *tg.UpdateNewMessage) error {
+		return t.handleNew(e, u.Message)
+	})
+	dispatcher.OnNewChannelMessage(func(ctx context.Context, e tg.Entities, u *tg.UpdateNewChannelMessage) error {
+		return t.handleNew(e, u.Message)
+	})
+	dispatcher.OnEditMessage(func(ctx context.Context, e tg.Entities, u *tg.UpdateEditMessage) error {
+		return t.handleEdit(e, u.Message)
+	})
+	dispatcher.OnEditChannelMessage(func(ctx context.Context, e tg.Entities, u *tg.UpdateEditChannelMessage) error {
+		return t.handleEdit(e, u.Message)
+	})
+	dispatcher.OnDeleteChannelMessages(func(ctx context.Context, e tg.Entities, u *tg.UpdateDeleteChannelMessages) error {
+		return t.handleDelete(channelIDOffset-u.ChannelID, u.Messages)
+	})
+	dispatcher.OnDeleteMessages(func(ctx context.Context, e tg.Entities, u *tg.UpdateDeleteMessages) error {
+		// Private and basic group deletions do not say which chat they belong to
+		log.WithField("count", len(u.Messages)).Debug("Ignoring deletion without chat information")
+		return nil
+	})
+
+	return client.Run(ctx, func(ctx context.Context) error {
+		flow := auth.NewFlow(
+			auth.Constant(t.cfg.Phone, t.cfg.Password, auth.CodeAuthenticatorFunc(promptCode)),
+			auth.SendCodeOptions{},
+		)
+		if err := client.Auth().IfNecessary(ctx, flow); err != nil {
+			return fmt.Errorf("telegram login failed: %w", err)
+		}
+
+		self, err := client.Self(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to get own account: %w", err)
+		}
+
+		log.WithFields(log.Fields{
+			"user_id":  self.ID,
+			"username": self.Username,
+			"chats":    len(t.chats),
+		}).Info("Telegram client logged in")
+
+		t.setAPI(client.API())
+		defer t.setAPI(nil)
+
+		return gaps.Run(ctx, client.API(), self.ID, updates.AuthOptions{})
+	})
+}
+
+// handleNew indexes a new message
+func (t *TelegramClient) handleNew(e tg.Entities, class tg.MessageClass) error {
+	msg, ok := t.accept(class)
+	if !ok {
+		return nil
+	}
+
+	message := convertMessage(e, msg)
+	if _, err := t.sink.Index([]models.Message{message}); err != nil {
+		log.WithError(err).WithField("id", message.ID).Error("Failed to index Telegram message")
+	}
+	return nil
+}
+
+// handleEdit applies an edit to an indexed message
+func (t *TelegramClient) handleEdit(e tg.Entities, class tg.MessageClass) error {
+	msg, ok := t.accept(class)
+	if !ok {
+		return nil
+	}
+
+	message := convertMessage(e, msg)
+	if err := t.sink.Edit(&message); err != nil {
+		log.WithError(err).WithField("id", message.ID).Error("Failed to apply Telegram edit")
+	}
+	return nil
+}
+
+// handleDelete soft-deletes messages removed from a channel or supergroup
+func (t *TelegramClient) handleDelete(chatID int64, messageIDs []int) error {
+	if len(t.chats) > 0 && !t.chats[chatID] {
+		return nil
+	}
+	for _, messageID := range messageIDs {
+		// Messages deleted before they were indexed fail here, so keep going
+		if err := t.sink.Delete(chatID, int64(messageID)); err != nil {
+			log.WithError(err).WithFields(log.Fields{
+				"chat_id":    chatID,
+				"message_id": messageID,
+			}).Warn("Failed to apply Telegram deletion")
+		}
+	}
+	return nil
+}
+
+// accept returns regular messages from configured chats
+func (t *TelegramClient) accept(class tg.MessageClass) (*tg.Message, bool) {
+	msg, ok := class.(*tg.Message)
+	if !ok {
+		return nil, false // Service or empty message
+	}
+	if len(t.chats) > 0 && !t.chats[peerChatID(msg.PeerID)] {
+		return nil, false
+	}
+	return msg, true
+}
+
+// promptCode reads the login code from the terminal. It is only needed on the
+// first start; afterwards the session file keeps the account logged in.
+func promptCode(ctx context.Context, sentCode *tg.AuthSentCode) (string, error) {
+	fmt.Fprint(os.Stderr, "Enter the Telegram login code: ")
+	code, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil {
+		return "", fmt.Errorf("failed to read login code (run the engine interactively once to log in): %w", err)
+	}
+	return strings.TrimSpace(code), nil
+}
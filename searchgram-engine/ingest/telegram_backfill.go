@@ -0,0 +1,215 @@
+package ingest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/gotd/td/telegram/query/dialogs"
+	"github.com/gotd/td/tg"
+	"github.com/gotd/td/tgerr"
+	log "github.com/sirupsen/logrus"
+	"github.com/zhishengyuan/searchgram-engine/models"
+)
+
+const (
+	// historyPageSize is the maximum messages.getHistory returns per call
+	historyPageSize = 100
+
+	// defaultBackfillDelay spaces history requests to stay clear of FLOOD_WAIT
+	defaultBackfillDelay = time.Second
+)
+
+var (
+	// ErrTelegramNotConnected is returned when backfilling before login completed
+	ErrTelegramNotConnected = errors.New("telegram client is not connected")
+
+	// errPeerFound stops the dialog scan once the requested chat is found
+	errPeerFound = errors.New("peer found")
+)
+
+// BackfillProgress reports how far a history backfill got
+type BackfillProgress struct {
+	ChatID        int64 `json:"chat_id"`
+	Fetched       int   `json:"fetched"`
+	Indexed       int   `json:"indexed"`
+	OldestMessage int   `json:"oldest_message_id,omitempty"` // Resume point for a later run
+	FloodWaits    int   `json:"flood_waits"`
+}
+
+// Backfill walks the history of chatID from the newest message (or from
+// offsetID when resuming) backwards via messages.getHistory and indexes every
+// page. limit caps the number of messages fetched (0 = full history).
+// Requests are spaced by the configured delay and FLOOD_WAIT errors are waited out.
+func (t *TelegramClient) Backfill(ctx context.Context, chatID int64, offsetID, limit int, update func(BackfillProgress)) (BackfillProgress, error) {
+	progress := BackfillProgress{ChatID: chatID, OldestMessage: offsetID}
+
+	api := t.connectedAPI()
+	if api == nil {
+		return progress, ErrTelegramNotConnected
+	}
+
+	peer, err := findPeer(ctx, api, chatID)
+	if err != nil {
+		return progress, err
+	}
+
+	delay := t.cfg.BackfillDelay
+	if delay <= 0 {
+		delay = defaultBackfillDelay
+	}
+
+	log.WithFields(log.Fields{
+		"chat_id":   chatID,
+		"offset_id": offsetID,
+		"limit":     limit,
+	}).Info("Starting Telegram history backfill")
+
+	for limit == 0 || progress.Fetched < limit {
+		pageSize := historyPageSize
+		if limit > 0 && limit-progress.Fetched < pageSize {
+			pageSize = limit - progress.Fetched
+		}
+
+		result, err := api.MessagesGetHistory(ctx, &tg.MessagesGetHistoryRequest{
+			Peer:     peer,
+			OffsetID: progress.OldestMessage,
+			Limit:    pageSize,
+		})
+		if d, ok := tgerr.AsFloodWait(err); ok {
+			progress.FloodWaits++
+			log.WithField("wait", d.String()).Warn("Telegram rate limit hit during backfill, waiting")
+			if err := sleepCtx(ctx, d); err != nil {
+				return progress, err
+			}
+			continue
+		}
+		if err != nil {
+			return progress, fmt.Errorf("failed to fetch history: %w", err)
+		}
+
+		page, ok := result.AsModified()
+		if !ok || len(page.GetMessages()) == 0 {
+			break // Reached the start of the chat
+		}
+		entities := entitiesOf(page.GetUsers(), page.GetChats())
+
+		batch := make([]models.Message, 0, len(page.GetMessages()))
+		for _, class := range page.GetMessages() {
+			progress.Fetched++
+			if id := class.GetID(); progress.OldestMessage == 0 || id < progress.OldestMessage {
+				progress.OldestMessage = id
+			}
+			if msg, ok := class.(*tg.Message); ok {
+				batch = append(batch, convertMessage(entities, msg))
+			}
+		}
+
+		if len(batch) > 0 {
+			indexed, err := t.sink.Index(batch)
+			progress.Indexed += indexed
+			if err != nil {
+				return progress, fmt.Errorf("failed to index history page: %w", err)
+			}
+		}
+		update(progress)
+
+		if err := sleepCtx(ctx, delay); err != nil {
+			return progress, err
+		}
+	}
+
+	log.WithFields(log.Fields{
+		"chat_id": chatID,
+		"fetched": progress.Fetched,
+		"indexed": progress.Indexed,
+	}).Info("Telegram history backfill complete")
+
+	return progress, nil
+}
+
+// connectedAPI returns the raw API client while logged in
+func (t *TelegramClient) connectedAPI() *tg.Client {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.api
+}
+
+// setAPI records the raw API client of the current session (nil when disconnected)
+func (t *TelegramClient) setAPI(api *tg.Client) {
+	t.mu.Lock()
+	t.api = api
+	t.mu.Unlock()
+}
+
+// findPeer resolves a Bot API style chat ID to an input peer (with access
+// hash) by scanning the account's dialogs
+func findPeer(ctx context.Context, api *tg.Client, chatID int64) (tg.InputPeerClass, error) {
+	var peer tg.InputPeerClass
+
+	err := dialogs.NewQueryBuilder(api).GetDialogs().BatchSize(historyPageSize).
+		ForEach(ctx, func(ctx context.Context, elem dialogs.Elem) error {
+			if inputPeerChatID(elem.Peer) == chatID {
+				peer = elem.Peer
+				return errPeerFound
+			}
+			return nil
+		})
+	if peer != nil {
+		return peer, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to list dialogs: %w", err)
+	}
+	return nil, fmt.Errorf("chat %d is not in the account's dialogs", chatID)
+}
+
+// inputPeerChatID converts an input peer to a Bot API style chat ID
+func inputPeerChatID(peer tg.InputPeerClass) int64 {
+	switch p := peer.(type) {
+	case *tg.InputPeerUser:
+		return p.UserID
+	case *tg.InputPeerChat:
+		return -p.ChatID
+	case *tg.InputPeerChannel:
+		return channelIDOffset - p.ChannelID
+	}
+	return 0
+}
+
+// entitiesOf indexes the users and chats returned alongside messages
+func entitiesOf(users []tg.UserClass, chats []tg.ChatClass) tg.Entities {
+	entities := tg.Entities{
+		Users:    make(map[int64]*tg.User, len(users)),
+		Chats:    make(map[int64]*tg.Chat),
+		Channels: make(map[int64]*tg.Channel),
+	}
+	for _, class := range users {
+		if user, ok := class.(*tg.User); ok {
+			entities.Users[user.ID] = user
+		}
+	}
+	for _, class := range chats {
+		switch chat := class.(type) {
+		case *tg.Chat:
+			entities.Chats[chat.ID] = chat
+		case *tg.Channel:
+			entities.Channels[chat.ID] = chat
+		}
+	}
+	return entities
+}
+
+// sleepCtx waits for d or until ctx is cancelled
+func sleepCtx(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,425 @@
+package ingest
+
+import (
+	"strings"
+
+	"github.com/gotd/td/tg"
+	"github.com/zhishengyuan/searchgram-engine/models"
+)
+
+// channelIDOffset converts MTProto channel IDs to Bot API style chat IDs (-100...)
+const channelIDOffset = -1000000000000
+
+// entityTypes maps MTProto entity constructors to the names used by the Python client
+var entityTypes = map[string]string{
+	"messageEntityMention":     "MENTION",
+	"messageEntityMentionName": "TEXT_MENTION",
+	"messageEntityHashtag":     "HASHTAG",
+	"messageEntityCashtag":     "CASHTAG",
+	"messageEntityBotCommand":  "BOT_COMMAND",
+	"messageEntityUrl":         "URL",
+	"messageEntityTextUrl":     "TEXT_LINK",
+	"messageEntityEmail":       "EMAIL",
+	"messageEntityPhone":       "PHONE_NUMBER",
+	"messageEntityBold":        "BOLD",
+	"messageEntityItalic":      "ITALIC",
+	"messageEntityUnderline":   "UNDERLINE",
+	"messageEntityStrike":      "STRIKETHROUGH",
+	"messageEntitySpoiler":     "SPOILER",
+	"messageEntityCode":        "CODE",
+	"messageEntityPre":         "PRE",
+	"messageEntityBlockquote":  "BLOCKQUOTE",
+	"messageEntityCustomEmoji": "CUSTOM_EMOJI",
+}
+
+// chatInfo is the resolved chat a message belongs to
+type chatInfo struct {
+	id       int64
+	chatType string
+	title    string
+	username string
+}
+
+// peerChatID converts an MTProto peer to a Bot API style chat ID
+func peerChatID(peer tg.PeerClass) int64 {
+	switch p := peer.(type) {
+	case *tg.PeerUser:
+		return p.UserID
+	case *tg.PeerChat:
+		return -p.ChatID
+	case *tg.PeerChannel:
+		return channelIDOffset - p.ChannelID
+	}
+	return 0
+}
+
+// resolveChat looks up chat details for a peer in the update entities
+func resolveChat(e tg.Entities, peer tg.PeerClass) chatInfo {
+	info := chatInfo{id: peerChatID(peer)}
+
+	switch p := peer.(type) {
+	case *tg.PeerUser:
+		info.chatType = "PRIVATE"
+		if user, ok := e.Users[p.UserID]; ok {
+			if user.Bot {
+				info.chatType = "BOT"
+			}
+			info.title = userName(user)
+			info.username = user.Username
+		}
+	case *tg.PeerChat:
+		info.chatType = "GROUP"
+		if chat, ok := e.Chats[p.ChatID]; ok {
+			info.title = chat.Title
+		}
+	case *tg.PeerChannel:
+		info.chatType = "SUPERGROUP"
+		if channel, ok := e.Channels[p.ChannelID]; ok {
+			if channel.Broadcast {
+				info.chatType = "CHANNEL"
+			}
+			info.title = channel.Title
+			info.username = channel.Username
+		}
+	}
+
+	return info
+}
+
+// userName joins first and last name
+func userName(user *tg.User) string {
+	return strings.TrimSpace(user.FirstName + " " + user.LastName)
+}
+
+// convertMessage builds the indexed document for an MTProto message, mirroring
+// the fields produced by the Python client's MessageConverter
+func convertMessage(e tg.Entities, msg *tg.Message) models.Message {
+	chat := resolveChat(e, msg.PeerID)
+
+	message := models.Message{
+		ID:           models.MessageDocumentID(chat.id, int64(msg.ID)),
+		MessageID:    int64(msg.ID),
+		ChatID:       chat.id,
+		Timestamp:    int64(msg.Date),
+		Date:         int64(msg.Date),
+		ChatType:     chat.chatType,
+		ChatTitle:    chat.title,
+		ChatUsername: chat.username,
+		Chat: models.Chat{
+			ID:       chat.id,
+			Type:     chat.chatType,
+			Title:    chat.title,
+			Username: chat.username,
+		},
+	}
+
+	resolveSender(e, msg, &message)
+	resolveForward(e, msg, &message)
+	resolveReply(msg, &message)
+	resolveContent(msg, &message)
+	resolveEngagement(msg, &message)
+
+	for _, entity := range msg.Entities {
+		converted := models.MessageEntity{
+			Type:   entityType(entity.TypeName()),
+			Offset: entity.GetOffset(),
+			Length: entity.GetLength(),
+		}
+		if link, ok := entity.(*tg.MessageEntityTextURL); ok {
+			converted.URL = link.URL
+		}
+		if mention, ok := entity.(*tg.MessageEntityMentionName); ok {
+			userID := mention.UserID
+			converted.UserID = &userID
+			if user, ok := e.Users[userID]; ok {
+				converted.User = &models.User{
+					ID:        user.ID,
+					FirstName: user.FirstName,
+					LastName:  user.LastName,
+					Username:  user.Username,
+				}
+			}
+		}
+		message.Entities = append(message.Entities, converted)
+	}
+
+	return message
+}
+
+// entityType maps an MTProto entity constructor name to the stored type
+func entityType(typeName string) string {
+	if name, ok := entityTypes[typeName]; ok {
+		return name
+	}
+	return strings.ToUpper(strings.TrimPrefix(typeName, "messageEntity"))
+}
+
+// resolveSender fills the normalized sender fields
+func resolveSender(e tg.Entities, msg *tg.Message, message *models.Message) {
+	from, ok := msg.GetFromID()
+	if !ok {
+		// Private chats and channel posts carry no from_id
+		from = msg.PeerID
+		if _, isUser := from.(*tg.PeerUser); isUser && msg.Out {
+			message.SenderType = "unknown"
+			return
+		}
+	}
+
+	switch p := from.(type) {
+	case *tg.PeerUser:
+		message.SenderType = "user"
+		message.SenderID = p.UserID
+		message.FromUser.ID = p.UserID
+		if user, ok := e.Users[p.UserID]; ok {
+			message.SenderName = userName(user)
+			message.SenderUsername = user.Username
+			if user.FirstName != "" {
+				first := user.FirstName
+				message.SenderFirstName = &first
+			}
+			if user.LastName != "" {
+				last := user.LastName
+				message.SenderLastName = &last
+			}
+			message.FromUser = models.User{
+				ID:        user.ID,
+				IsBot:     user.Bot,
+				FirstName: user.FirstName,
+				LastName:  user.LastName,
+				Username:  user.Username,
+			}
+		}
+	case *tg.PeerChat, *tg.PeerChannel:
+		chat := resolveChat(e, from)
+		message.SenderType = "chat"
+		message.SenderID = chat.id
+		message.SenderName = chat.title
+		message.SenderUsername = chat.username
+		if chat.title != "" {
+			title := chat.title
+			message.SenderChatTitle = &title
+		}
+	default:
+		message.SenderType = "unknown"
+	}
+}
+
+// resolveForward fills the forward fields (precedence: chat > user > name only)
+func resolveForward(e tg.Entities, msg *tg.Message, message *models.Message) {
+	fwd, ok := msg.GetFwdFrom()
+	if !ok {
+		return
+	}
+
+	message.IsForwarded = true
+	if fwd.Date != 0 {
+		ts := int64(fwd.Date)
+		message.ForwardTimestamp = &ts
+	}
+
+	setForward := func(fromType string, id *int64, name string) {
+		message.ForwardFromType = &fromType
+		message.ForwardFromID = id
+		if name != "" {
+			message.ForwardFromName = &name
+		}
+	}
+
+	if from, ok := fwd.GetFromID(); ok {
+		switch p := from.(type) {
+		case *tg.PeerUser:
+			id := p.UserID
+			name := ""
+			if user, ok := e.Users[p.UserID]; ok {
+				name = userName(user)
+			}
+			setForward("user", &id, name)
+		default:
+			chat := resolveChat(e, from)
+			setForward("chat", &chat.id, chat.title)
+		}
+		return
+	}
+	if fwd.FromName != "" {
+		setForward("name_only", nil, fwd.FromName)
+	}
+}
+
+// resolveReply sets the replied-to message and forum topic. In forum groups
+// a plain message's reply header points at the topic's first message; real
+// replies inside a topic carry the topic as ReplyToTopID.
+func resolveReply(msg *tg.Message, message *models.Message) {
+	header, ok := msg.ReplyTo.(*tg.MessageReplyHeader)
+	if !ok {
+		return
+	}
+
+	replyTo, hasReply := header.GetReplyToMsgID()
+	if header.ForumTopic {
+		if topID, ok := header.GetReplyToTopID(); ok {
+			message.ThreadID = int64(topID)
+		} else if hasReply {
+			// Posted in the topic, not a reply to its first message
+			message.ThreadID = int64(replyTo)
+			return
+		}
+	}
+	if hasReply {
+		message.ReplyToMessageID = int64(replyTo)
+	}
+}
+
+// resolveEngagement sets the reaction, view and forward counts
+func resolveEngagement(msg *tg.Message, message *models.Message) {
+	message.Views, _ = msg.GetViews()
+	message.Forwards, _ = msg.GetForwards()
+	if reactions, ok := msg.GetReactions(); ok {
+		for _, result := range reactions.Results {
+			message.ReactionsCount += result.Count
+		}
+	}
+}
+
+// resolveContent sets the content type, text and caption. MTProto carries
+// media captions in the message text.
+func resolveContent(msg *tg.Message, message *models.Message) {
+	if msg.Media == nil {
+		message.ContentType = "text"
+		message.Text = msg.Message
+		return
+	}
+
+	if msg.Message != "" {
+		caption := msg.Message
+		message.Caption = &caption
+	}
+
+	switch media := msg.Media.(type) {
+	case *tg.MessageMediaPhoto:
+		message.ContentType = "photo"
+		message.MediaType = "photo"
+		if photo, ok := media.Photo.(*tg.Photo); ok {
+			message.MimeType = "image/jpeg"
+			message.FileSize = photoSize(photo)
+		}
+	case *tg.MessageMediaDocument:
+		message.ContentType = documentType(media)
+		message.MediaType = message.ContentType
+		if message.ContentType == "sticker" {
+			message.Caption = nil
+		}
+		if document, ok := media.Document.(*tg.Document); ok {
+			resolveDocument(document, message)
+		}
+	case *tg.MessageMediaWebPage:
+		// Link previews are plain text messages
+		message.ContentType = "text"
+		message.Text = msg.Message
+		message.Caption = nil
+	case *tg.MessageMediaGeo:
+		message.ContentType = "other"
+		message.Location = geoPoint(media.Geo)
+	case *tg.MessageMediaGeoLive:
+		message.ContentType = "other"
+		message.Location = geoPoint(media.Geo)
+	case *tg.MessageMediaVenue:
+		message.ContentType = "other"
+		message.Location = geoPoint(media.Geo)
+	case *tg.MessageMediaPoll:
+		message.ContentType = "other"
+		message.IsPoll = true
+		message.PollQuestion = media.Poll.Question
+		for _, answer := range media.Poll.Answers {
+			message.PollOptions = append(message.PollOptions, answer.Text)
+		}
+	default:
+		message.ContentType = "other"
+	}
+
+	if message.ContentType == "sticker" {
+		if doc, ok := msg.Media.(*tg.MessageMediaDocument); ok {
+			if document, ok := doc.Document.(*tg.Document); ok {
+				for _, attr := range document.Attributes {
+					if sticker, ok := attr.(*tg.DocumentAttributeSticker); ok {
+						emoji := sticker.Alt
+						message.StickerEmoji = &emoji
+						if set, ok := sticker.Stickerset.(*tg.InputStickerSetShortName); ok {
+							name := set.ShortName
+							message.StickerSetName = &name
+						}
+					}
+				}
+			}
+		}
+	}
+}
+
+// geoPoint converts an MTProto geo point; empty points yield nil
+func geoPoint(geo tg.GeoPointClass) *models.GeoPoint {
+	point, ok := geo.(*tg.GeoPoint)
+	if !ok {
+		return nil
+	}
+	return &models.GeoPoint{Lat: point.Lat, Lon: point.Long}
+}
+
+// resolveDocument sets the file name, MIME type and size of a document.
+// Round video messages are reported as the video_note media type.
+func resolveDocument(document *tg.Document, message *models.Message) {
+	message.MimeType = document.MimeType
+	message.FileSize = document.Size
+	for _, attr := range document.Attributes {
+		switch a := attr.(type) {
+		case *tg.DocumentAttributeFilename:
+			message.FileName = a.FileName
+		case *tg.DocumentAttributeVideo:
+			if a.RoundMessage {
+				message.MediaType = "video_note"
+			}
+		}
+	}
+}
+
+// photoSize returns the byte size of the largest photo variant
+func photoSize(photo *tg.Photo) int64 {
+	var largest int
+	for _, size := range photo.Sizes {
+		switch s := size.(type) {
+		case *tg.PhotoSize:
+			largest = max(largest, s.Size)
+		case *tg.PhotoSizeProgressive:
+			if len(s.Sizes) > 0 {
+				largest = max(largest, s.Sizes[len(s.Sizes)-1])
+			}
+		}
+	}
+	return int64(largest)
+}
+
+// documentType classifies a document by its attributes
+func documentType(media *tg.MessageMediaDocument) string {
+	document, ok := media.Document.(*tg.Document)
+	if !ok {
+		return "document"
+	}
+
+	contentType := "document"
+	for _, attr := range document.Attributes {
+		switch a := attr.(type) {
+		case *tg.DocumentAttributeSticker:
+			return "sticker"
+		case *tg.DocumentAttributeAnimated:
+			return "animation"
+		case *tg.DocumentAttributeVideo:
+			contentType = "video"
+		case *tg.DocumentAttributeAudio:
+			if a.Voice {
+				contentType = "voice"
+			} else {
+				contentType = "audio"
+			}
+		}
+	}
+	return contentType
+}
@@ -0,0 +1,362 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	log "github.com/sirupsen/logrus"
+	"github.com/zhishengyuan/searchgram-engine/storage"
+)
+
+const (
+	// maxFinishedJobs bounds how many completed jobs are kept for polling
+	maxFinishedJobs = 100
+
+	// progressSaveInterval throttles persisting progress-only updates
+	progressSaveInterval = 5 * time.Second
+)
+
+// Status represents the lifecycle state of a job
+type Status string
+
+const (
+	StatusRunning     Status = "running"
+	StatusSucceeded   Status = "succeeded"
+	StatusFailed      Status = "failed"
+	StatusCancelled   Status = "cancelled"
+	StatusInterrupted Status = "interrupted" // Was running when the engine stopped
+)
+
+var (
+	// ErrNotFound is returned when a job ID does not exist
+	ErrNotFound = errors.New("job not found")
+
+	// ErrNotRunning is returned when cancelling a job that already finished
+	ErrNotRunning = errors.New("job is not running")
+)
+
+// Job represents a long-running background operation
+type Job struct {
+	ID         string      `json:"id"`
+	Type       string      `json:"type"`
+	Status     Status      `json:"status"`
+	Progress   interface{} `json:"progress,omitempty"`
+	Result     interface{} `json:"result,omitempty"`
+	Error      string      `json:"error,omitempty"`
+	CreatedAt  time.Time   `json:"created_at"`
+	FinishedAt *time.Time  `json:"finished_at,omitempty"`
+}
+
+// Func is the body of a job. It must stop when ctx is cancelled, reports
+// intermediate progress via update and returns the final result.
+type Func func(ctx context.Context, update func(progress interface{})) (interface{}, error)
+
+// Filter narrows job listings; empty fields match everything
+type Filter struct {
+	Type   string
+	Status Status
+}
+
+// Manager runs jobs in the background and tracks their state
+type Manager struct {
+	mu        sync.RWMutex
+	jobs      map[string]*Job
+	cancels   map[string]context.CancelFunc
+	file      *storage.Document
+	lastSaved time.Time
+	stopping  bool
+	wg        sync.WaitGroup
+}
+
+// NewManager creates a job manager. When file is non-nil, job history is
+// persisted there and jobs left running by a previous process are marked interrupted.
+func NewManager(file *storage.Document) (*Manager, error) {
+	m := &Manager{
+		jobs:    make(map[string]*Job),
+		cancels: make(map[string]context.CancelFunc),
+		file:    file,
+	}
+
+	if file == nil {
+		return m, nil
+	}
+
+	var saved []*Job
+	if err := file.Load(&saved); err != nil {
+		return nil, err
+	}
+
+	interrupted := 0
+	for _, job := range saved {
+		if job.Status == StatusRunning {
+			now := time.Now().UTC()
+			job.Status = StatusInterrupted
+			job.Error = "engine restarted while job was running"
+			job.FinishedAt = &now
+			interrupted++
+		}
+		m.jobs[job.ID] = job
+	}
+
+	if interrupted > 0 {
+		m.save()
+	}
+
+	log.WithFields(log.Fields{
+		"jobs":        len(saved),
+		"interrupted": interrupted,
+	}).Info("Job history loaded")
+
+	return m, nil
+}
+
+// Start runs fn in the background and returns a snapshot of the new job
+func (m *Manager) Start(jobType string, fn Func) *Job {
+	m.mu.Lock()
+	job, ctx := m.add(jobType)
+	snapshot := *job
+	m.mu.Unlock()
+
+	m.launch(ctx, job, fn)
+
+	return &snapshot
+}
+
+// StartExclusive starts a job unless one of the same type is already running,
+// in which case the running job is returned and started is false
+func (m *Manager) StartExclusive(jobType string, fn Func) (job *Job, started bool) {
+	m.mu.Lock()
+	for _, existing := range m.jobs {
+		if existing.Type == jobType && existing.Status == StatusRunning {
+			snapshot := *existing
+			m.mu.Unlock()
+			return &snapshot, false
+		}
+	}
+	created, ctx := m.add(jobType)
+	snapshot := *created
+	m.mu.Unlock()
+
+	m.launch(ctx, created, fn)
+
+	return &snapshot, true
+}
+
+// add registers a new running job (caller holds lock)
+func (m *Manager) add(jobType string) (*Job, context.Context) {
+	job := &Job{
+		ID:        uuid.New().String(),
+		Type:      jobType,
+		Status:    StatusRunning,
+		CreatedAt: time.Now().UTC(),
+	}
+	m.jobs[job.ID] = job
+
+	ctx, cancel := context.WithCancel(context.Background())
+	m.cancels[job.ID] = cancel
+
+	m.save()
+
+	log.WithFields(log.Fields{
+		"job_id": job.ID,
+		"type":   jobType,
+	}).Info("Job started")
+
+	return job, ctx
+}
+
+// launch runs the job body in a tracked goroutine
+func (m *Manager) launch(ctx context.Context, job *Job, fn Func) {
+	m.wg.Add(1)
+	go func() {
+		defer m.wg.Done()
+		m.run(ctx, job, fn)
+	}()
+}
+
+// run executes the job body and records its outcome
+func (m *Manager) run(ctx context.Context, job *Job, fn Func) {
+	update := func(progress interface{}) {
+		m.mu.Lock()
+		job.Progress = progress
+		if time.Since(m.lastSaved) >= progressSaveInterval {
+			m.save()
+		}
+		m.mu.Unlock()
+	}
+
+	result, err := func() (result interface{}, err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				err = fmt.Errorf("job panicked: %v", r)
+			}
+		}()
+		return fn(ctx, update)
+	}()
+
+	m.mu.Lock()
+	now := time.Now().UTC()
+	job.FinishedAt = &now
+	switch {
+	case ctx.Err() != nil && m.stopping:
+		job.Status = StatusInterrupted
+		job.Error = "engine shut down while job was running"
+	case ctx.Err() != nil:
+		job.Status = StatusCancelled
+		job.Result = result
+		if err != nil {
+			job.Error = err.Error()
+		}
+	case err != nil:
+		job.Status = StatusFailed
+		job.Error = err.Error()
+	default:
+		job.Status = StatusSucceeded
+		job.Result = result
+	}
+	if cancel, ok := m.cancels[job.ID]; ok {
+		cancel()
+		delete(m.cancels, job.ID)
+	}
+	m.prune()
+	m.save()
+	status := job.Status
+	m.mu.Unlock()
+
+	fields := log.Fields{
+		"job_id":      job.ID,
+		"type":        job.Type,
+		"status":      status,
+		"duration_ms": now.Sub(job.CreatedAt).Milliseconds(),
+	}
+	if status == StatusFailed {
+		log.WithFields(fields).WithError(err).Error("Job failed")
+	} else {
+		log.WithFields(fields).Info("Job finished")
+	}
+}
+
+// Get returns a snapshot of the job with the given ID
+func (m *Manager) Get(id string) (*Job, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	job, ok := m.jobs[id]
+	if !ok {
+		return nil, false
+	}
+	snapshot := *job
+	return &snapshot, true
+}
+
+// List returns snapshots of jobs matching the filter, newest first
+func (m *Manager) List(filter Filter) []*Job {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	list := make([]*Job, 0, len(m.jobs))
+	for _, job := range m.jobs {
+		if filter.Type != "" && job.Type != filter.Type {
+			continue
+		}
+		if filter.Status != "" && job.Status != filter.Status {
+			continue
+		}
+		snapshot := *job
+		list = append(list, &snapshot)
+	}
+
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].CreatedAt.After(list[j].CreatedAt)
+	})
+	return list
+}
+
+// Cancel requests cancellation of a running job. The job reaches the
+// cancelled state once its body observes the cancelled context.
+func (m *Manager) Cancel(id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	job, ok := m.jobs[id]
+	if !ok {
+		return ErrNotFound
+	}
+	cancel, ok := m.cancels[id]
+	if !ok || job.Status != StatusRunning {
+		return ErrNotRunning
+	}
+
+	cancel()
+
+	log.WithFields(log.Fields{
+		"job_id": id,
+		"type":   job.Type,
+	}).Info("Job cancellation requested")
+
+	return nil
+}
+
+// Shutdown cancels all running jobs and waits for them to stop or for ctx to expire
+func (m *Manager) Shutdown(ctx context.Context) {
+	m.mu.Lock()
+	m.stopping = true
+	for _, cancel := range m.cancels {
+		cancel()
+	}
+	m.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		m.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		log.Warn("Timed out waiting for jobs to stop")
+	}
+}
+
+// save persists all jobs (caller holds lock)
+func (m *Manager) save() {
+	if m.file == nil {
+		return
+	}
+
+	list := make([]*Job, 0, len(m.jobs))
+	for _, job := range m.jobs {
+		list = append(list, job)
+	}
+	if err := m.file.Save(list); err != nil {
+		log.WithError(err).Warn("Failed to persist jobs")
+		return
+	}
+	m.lastSaved = time.Now()
+}
+
+// prune drops the oldest finished jobs beyond maxFinishedJobs (caller holds lock)
+func (m *Manager) prune() {
+	var finished []*Job
+	for _, job := range m.jobs {
+		if job.FinishedAt != nil {
+			finished = append(finished, job)
+		}
+	}
+	if len(finished) <= maxFinishedJobs {
+		return
+	}
+
+	sort.Slice(finished, func(i, j int) bool {
+		return finished[i].FinishedAt.Before(*finished[j].FinishedAt)
+	})
+	for _, job := range finished[:len(finished)-maxFinishedJobs] {
+		delete(m.jobs, job.ID)
+	}
+}
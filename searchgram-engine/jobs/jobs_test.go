@@ -0,0 +1,176 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/zhishengyuan/searchgram-engine/storage"
+)
+
+// blockUntilCancelled runs until its job is cancelled
+func blockUntilCancelled(ctx context.Context, update func(progress interface{})) (interface{}, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+// succeed finishes at once
+func succeed(ctx context.Context, update func(progress interface{})) (interface{}, error) {
+	return "done", nil
+}
+
+// waitFinished polls until the job left the running state
+func waitFinished(t *testing.T, m *Manager, id string) *Job {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if job, ok := m.Get(id); ok && job.Status != StatusRunning {
+			return job
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("job %s still running", id)
+	return nil
+}
+
+func TestStartExclusive(t *testing.T) {
+	tests := []struct {
+		name        string
+		runningType string // Type of the job already running ("" = none)
+		jobType     string
+		wantStarted bool
+	}{
+		{name: "no job running", jobType: "reindex", wantStarted: true},
+		{name: "same type running", runningType: "reindex", jobType: "reindex", wantStarted: false},
+		{name: "other type running", runningType: "backup", jobType: "reindex", wantStarted: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := NewManager(nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer m.Shutdown(context.Background())
+
+			var running *Job
+			if tt.runningType != "" {
+				running = m.Start(tt.runningType, blockUntilCancelled)
+			}
+
+			job, started := m.StartExclusive(tt.jobType, blockUntilCancelled)
+			if started != tt.wantStarted {
+				t.Fatalf("started = %v, want %v", started, tt.wantStarted)
+			}
+			if job.Status != StatusRunning {
+				t.Errorf("status = %s, want %s", job.Status, StatusRunning)
+			}
+			if !started && job.ID != running.ID {
+				t.Errorf("job = %s, want the running job %s", job.ID, running.ID)
+			}
+			if started && running != nil && job.ID == running.ID {
+				t.Errorf("job = %s, want a new job", job.ID)
+			}
+		})
+	}
+}
+
+func TestCancel(t *testing.T) {
+	tests := []struct {
+		name    string
+		fn      Func // Body of the job to cancel (nil = unknown ID)
+		wantErr error
+	}{
+		{name: "running job", fn: blockUntilCancelled},
+		{name: "finished job", fn: succeed, wantErr: ErrNotRunning},
+		{name: "unknown job", wantErr: ErrNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := NewManager(nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer m.Shutdown(context.Background())
+
+			id := "unknown"
+			if tt.fn != nil {
+				id = m.Start("test", tt.fn).ID
+			}
+			if tt.wantErr == ErrNotRunning {
+				waitFinished(t, m, id)
+			}
+
+			err = m.Cancel(id)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Cancel() = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr == nil {
+				if job := waitFinished(t, m, id); job.Status != StatusCancelled {
+					t.Errorf("status = %s, want %s", job.Status, StatusCancelled)
+				}
+			}
+		})
+	}
+}
+
+func TestNewManagerInterruptsRunningJobs(t *testing.T) {
+	finished := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name       string
+		saved      Job
+		wantStatus Status
+		wantError  string
+	}{
+		{
+			name:       "running job",
+			saved:      Job{ID: "a", Type: "reindex", Status: StatusRunning},
+			wantStatus: StatusInterrupted,
+			wantError:  "engine restarted while job was running",
+		},
+		{
+			name:       "finished job",
+			saved:      Job{ID: "b", Type: "reindex", Status: StatusSucceeded, FinishedAt: &finished},
+			wantStatus: StatusSucceeded,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file := storage.NewDocument(storage.NewDir(t.TempDir()), "jobs.json")
+			if err := file.Save([]*Job{&tt.saved}); err != nil {
+				t.Fatal(err)
+			}
+
+			m, err := NewManager(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			job, ok := m.Get(tt.saved.ID)
+			if !ok {
+				t.Fatalf("job %s not loaded", tt.saved.ID)
+			}
+			if job.Status != tt.wantStatus {
+				t.Errorf("status = %s, want %s", job.Status, tt.wantStatus)
+			}
+			if job.Error != tt.wantError {
+				t.Errorf("error = %q, want %q", job.Error, tt.wantError)
+			}
+			if job.FinishedAt == nil {
+				t.Error("finished_at not set")
+			}
+
+			// The interruption is persisted for the next restart
+			var saved []*Job
+			if err := file.Load(&saved); err != nil {
+				t.Fatal(err)
+			}
+			if len(saved) != 1 || saved[0].Status != tt.wantStatus {
+				t.Errorf("saved = %+v, want one job with status %s", saved, tt.wantStatus)
+			}
+		})
+	}
+}
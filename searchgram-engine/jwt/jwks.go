@@ -0,0 +1,213 @@
+package jwt
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// jwksMinRefetch spaces out fetches for unknown key IDs, so tokens with
+	// made-up key IDs cannot make us hammer the remote JWKS
+	jwksMinRefetch = 30 * time.Second
+
+	// jwksTimeout bounds one fetch of the remote JWKS
+	jwksTimeout = 10 * time.Second
+)
+
+// JWK is an Ed25519 public key in JSON Web Key form (RFC 8037)
+type JWK struct {
+	Kty string `json:"kty"`           // Always "OKP"
+	Crv string `json:"crv"`           // Always "Ed25519"
+	X   string `json:"x"`             // The public key, base64url without padding
+	Kid string `json:"kid,omitempty"` // Key ID tokens name in their header
+	Alg string `json:"alg,omitempty"`
+	Use string `json:"use,omitempty"`
+}
+
+// JWKSet is a set of JSON Web Keys as served at /.well-known/jwks.json
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+// KeyID returns the RFC 7638 thumbprint of an Ed25519 public key, used as
+// the key ID of the tokens it verifies
+func KeyID(key ed25519.PublicKey) string {
+	// Required members in lexicographic order, without whitespace
+	canonical := fmt.Sprintf(`{"crv":"Ed25519","kty":"OKP","x":"%s"}`, base64.RawURLEncoding.EncodeToString(key))
+	sum := sha256.Sum256([]byte(canonical))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// publicJWK returns the JWK of an Ed25519 public key
+func publicJWK(key ed25519.PublicKey) JWK {
+	return JWK{
+		Kty: "OKP",
+		Crv: "Ed25519",
+		X:   base64.RawURLEncoding.EncodeToString(key),
+		Kid: KeyID(key),
+		Alg: "EdDSA",
+		Use: "sig",
+	}
+}
+
+// parseJWK returns the Ed25519 public key of a JWK
+func parseJWK(jwk JWK) (ed25519.PublicKey, error) {
+	if jwk.Kty != "OKP" || jwk.Crv != "Ed25519" {
+		return nil, fmt.Errorf("unsupported key type %s/%s", jwk.Kty, jwk.Crv)
+	}
+	key, err := base64.RawURLEncoding.DecodeString(jwk.X)
+	if err != nil || len(key) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("invalid Ed25519 key")
+	}
+	return ed25519.PublicKey(key), nil
+}
+
+// JWKS returns the public keys of this service: the verification key and,
+// when it differs, the key matching the private key tokens are signed with
+func (a *JWTAuth) JWKS() JWKSet {
+	set := JWKSet{Keys: []JWK{}}
+	if a.publicKey != nil {
+		set.Keys = append(set.Keys, publicJWK(a.publicKey))
+	}
+	if a.privateKey != nil {
+		signing := a.privateKey.Public().(ed25519.PublicKey)
+		if a.publicKey == nil || !signing.Equal(a.publicKey) {
+			set.Keys = append(set.Keys, publicJWK(signing))
+		}
+	}
+	return set
+}
+
+// JWKSHandler serves JWKS, so other services can verify our tokens without
+// a copy of the PEM file
+// GET /.well-known/jwks.json
+func (a *JWTAuth) JWKSHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Header("Cache-Control", "public, max-age=300")
+		c.JSON(http.StatusOK, a.JWKS())
+	}
+}
+
+// remoteKeys caches the keys of a remote JWKS. Keys are fetched again once
+// refresh has passed, and early when a token names an unknown key ID, so a
+// rotated key is picked up without a restart.
+type remoteKeys struct {
+	url     string
+	refresh time.Duration
+	client  *http.Client
+
+	mu          sync.Mutex
+	keys        map[string]ed25519.PublicKey // Key ID -> key
+	fetchedAt   time.Time
+	attemptedAt time.Time
+}
+
+// newRemoteKeys creates a cache of the JWKS at url
+func newRemoteKeys(url string, refresh time.Duration) *remoteKeys {
+	return &remoteKeys{
+		url:     url,
+		refresh: refresh,
+		client:  &http.Client{Timeout: jwksTimeout},
+		keys:    make(map[string]ed25519.PublicKey),
+	}
+}
+
+// key returns the key with the given ID
+func (r *remoteKeys) key(kid string) (ed25519.PublicKey, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key, ok := r.keys[kid]
+	if !ok || time.Since(r.fetchedAt) > r.refresh {
+		r.update(!ok)
+		key, ok = r.keys[kid]
+	}
+	if !ok {
+		return nil, fmt.Errorf("unknown key ID %s", kid)
+	}
+	return key, nil
+}
+
+// all returns every cached key, for tokens without a key ID
+func (r *remoteKeys) all() []ed25519.PublicKey {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if len(r.keys) == 0 || time.Since(r.fetchedAt) > r.refresh {
+		r.update(len(r.keys) == 0)
+	}
+	keys := make([]ed25519.PublicKey, 0, len(r.keys))
+	for _, key := range r.keys {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// update fetches the keys unless an attempt was made very recently; the
+// caller holds mu. When missing is false the cached keys are still usable,
+// so a failed fetch keeps them.
+func (r *remoteKeys) update(missing bool) {
+	if missing && time.Since(r.attemptedAt) < jwksMinRefetch {
+		return
+	}
+	if !missing && time.Since(r.attemptedAt) < r.refresh {
+		return
+	}
+	r.attemptedAt = time.Now()
+
+	keys, err := r.fetch()
+	if err != nil {
+		log.WithError(err).WithField("url", r.url).Warn("Failed to fetch JWKS, keeping cached keys")
+		return
+	}
+	r.keys = keys
+	r.fetchedAt = time.Now()
+	log.WithFields(log.Fields{
+		"url":  r.url,
+		"keys": len(keys),
+	}).Debug("Fetched JWKS")
+}
+
+// fetch downloads the JWKS and returns its Ed25519 keys by key ID. Keys of
+// other types are skipped.
+func (r *remoteKeys) fetch() (map[string]ed25519.PublicKey, error) {
+	resp, err := r.client.Get(r.url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+
+	var set JWKSet
+	if err := json.NewDecoder(resp.Body).Decode(&set); err != nil {
+		return nil, fmt.Errorf("failed to decode JWKS: %w", err)
+	}
+
+	keys := make(map[string]ed25519.PublicKey, len(set.Keys))
+	for _, jwk := range set.Keys {
+		key, err := parseJWK(jwk)
+		if err != nil {
+			continue
+		}
+		kid := jwk.Kid
+		if kid == "" {
+			kid = KeyID(key)
+		}
+		keys[kid] = key
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("JWKS holds no Ed25519 keys")
+	}
+	return keys, nil
+}
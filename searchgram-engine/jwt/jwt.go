@@ -14,6 +14,7 @@ import (
 	"github.com/golang-jwt/jwt/v5"
 	log "github.com/sirupsen/logrus"
 	"github.com/google/uuid"
+	"github.com/zhishengyuan/searchgram-engine/i18n"
 )
 
 // Config holds JWT configuration
@@ -25,6 +26,11 @@ type Config struct {
 	PublicKeyInline  interface{} // Can be string or []string
 	PrivateKeyInline interface{} // Can be string or []string
 	TokenTTL         int         // seconds
+
+	// Remote JWKS whose keys are trusted too, e.g. another SearchGram
+	// service's /.well-known/jwks.json, cached for JWKSRefresh
+	JWKSURL     string
+	JWKSRefresh time.Duration
 }
 
 // JWTAuth handles JWT authentication
@@ -34,11 +40,14 @@ type JWTAuth struct {
 	publicKey  ed25519.PublicKey
 	privateKey ed25519.PrivateKey
 	tokenTTL   int
+	remote     *remoteKeys // Keys of a remote JWKS (nil = local key only)
 }
 
 // Claims represents JWT claims
 type Claims struct {
 	jwt.RegisteredClaims
+	Tenant string `json:"tenant,omitempty"` // Tenant the caller acts for (multi-tenancy)
+	Role   string `json:"role,omitempty"`   // Credential role (reader, writer, admin); empty = not restricted by role
 }
 
 // NewJWTAuth creates a new JWT authenticator
@@ -99,11 +108,24 @@ func NewJWTAuth(cfg Config) (*JWTAuth, error) {
 		log.WithField("path", cfg.PrivateKeyPath).Info("Loaded Ed25519 private key from file")
 	}
 
+	// Remote JWKS (optional, for trusting other services' keys)
+	if cfg.JWKSURL != "" {
+		refresh := cfg.JWKSRefresh
+		if refresh <= 0 {
+			refresh = 10 * time.Minute
+		}
+		auth.remote = newRemoteKeys(cfg.JWKSURL, refresh)
+		if len(auth.remote.all()) == 0 {
+			log.WithField("url", cfg.JWKSURL).Warn("Remote JWKS not reachable yet, retrying on demand")
+		}
+	}
+
 	log.WithFields(log.Fields{
 		"issuer":      auth.issuer,
 		"audience":    auth.audience,
 		"has_public":  auth.publicKey != nil,
 		"has_private": auth.privateKey != nil,
+		"jwks_url":    cfg.JWKSURL,
 	}).Info("JWT auth initialized")
 
 	return auth, nil
@@ -215,10 +237,9 @@ func (a *JWTAuth) GenerateToken(targetAudience string) (string, error) {
 		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodEdDSA, claims)
-	tokenString, err := token.SignedString(a.privateKey)
+	tokenString, err := a.signClaims(claims)
 	if err != nil {
-		return "", fmt.Errorf("failed to sign token: %w", err)
+		return "", err
 	}
 
 	log.WithFields(log.Fields{
@@ -230,9 +251,80 @@ func (a *JWTAuth) GenerateToken(targetAudience string) (string, error) {
 	return tokenString, nil
 }
 
+// MintToken generates a token on behalf of another service, e.g. an
+// ingestion worker that should not hold the private key. The token names
+// issuer, carries role as its credential role (empty = not restricted by
+// role) and is addressed to audience, defaulting to our own so this engine
+// accepts it.
+func (a *JWTAuth) MintToken(issuer, audience, role string, ttl time.Duration) (string, time.Time, error) {
+	if a.privateKey == nil {
+		return "", time.Time{}, fmt.Errorf("private key not loaded, cannot generate tokens")
+	}
+
+	if audience == "" {
+		audience = a.audience
+	}
+	if ttl <= 0 {
+		ttl = time.Duration(a.tokenTTL) * time.Second
+	}
+
+	now := time.Now()
+	expiresAt := now.Add(ttl)
+	claims := Claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    issuer,
+			Audience:  jwt.ClaimStrings{audience},
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+			ID:        uuid.New().String(),
+		},
+		Role: role,
+	}
+
+	tokenString, err := a.signClaims(claims)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	log.WithFields(log.Fields{
+		"iss":  claims.Issuer,
+		"aud":  audience,
+		"role": role,
+		"jti":  claims.ID,
+	}).Info("Minted JWT token")
+
+	return tokenString, expiresAt, nil
+}
+
+// signClaims signs claims with the Ed25519 private key, naming its key ID
+// so verifiers using our JWKS can pick the key
+func (a *JWTAuth) signClaims(claims Claims) (string, error) {
+	token := jwt.NewWithClaims(jwt.SigningMethodEdDSA, claims)
+	token.Header["kid"] = KeyID(a.privateKey.Public().(ed25519.PublicKey))
+	tokenString, err := token.SignedString(a.privateKey)
+	if err != nil {
+		return "", fmt.Errorf("failed to sign token: %w", err)
+	}
+	return tokenString, nil
+}
+
+// CanSign reports whether a private key is loaded
+func (a *JWTAuth) CanSign() bool {
+	return a.privateKey != nil
+}
+
+// Sign signs data with the Ed25519 private key used for tokens, so anyone
+// holding the matching public key can verify it
+func (a *JWTAuth) Sign(data []byte) ([]byte, error) {
+	if a.privateKey == nil {
+		return nil, fmt.Errorf("private key not loaded, cannot sign")
+	}
+	return ed25519.Sign(a.privateKey, data), nil
+}
+
 // VerifyToken verifies a JWT token
 func (a *JWTAuth) VerifyToken(tokenString string, allowedIssuers []string) (*Claims, error) {
-	if a.publicKey == nil {
+	if a.publicKey == nil && a.remote == nil {
 		return nil, fmt.Errorf("public key not loaded, cannot verify tokens")
 	}
 
@@ -242,7 +334,7 @@ func (a *JWTAuth) VerifyToken(tokenString string, allowedIssuers []string) (*Cla
 		if _, ok := token.Method.(*jwt.SigningMethodEd25519); !ok {
 			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
 		}
-		return a.publicKey, nil
+		return a.verificationKey(token)
 	})
 
 	if err != nil {
@@ -297,6 +389,32 @@ func (a *JWTAuth) VerifyToken(tokenString string, allowedIssuers []string) (*Cla
 	return claims, nil
 }
 
+// verificationKey returns the keys a token may be signed with: the one its
+// key ID names, or every known key for tokens without one
+func (a *JWTAuth) verificationKey(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	if kid != "" {
+		if a.publicKey != nil && (a.remote == nil || kid == KeyID(a.publicKey)) {
+			return a.publicKey, nil
+		}
+		return a.remote.key(kid)
+	}
+
+	var set jwt.VerificationKeySet
+	if a.publicKey != nil {
+		set.Keys = append(set.Keys, a.publicKey)
+	}
+	if a.remote != nil {
+		for _, key := range a.remote.all() {
+			set.Keys = append(set.Keys, key)
+		}
+	}
+	if len(set.Keys) == 0 {
+		return nil, fmt.Errorf("no verification keys available")
+	}
+	return set, nil
+}
+
 // Middleware creates a Gin middleware for JWT authentication
 func (a *JWTAuth) Middleware(allowedIssuers []string) gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -311,7 +429,7 @@ func (a *JWTAuth) Middleware(allowedIssuers []string) gin.HandlerFunc {
 
 			c.JSON(http.StatusUnauthorized, gin.H{
 				"error":   "Unauthorized",
-				"message": "Missing or invalid Authorization header",
+				"message": i18n.Tc(c, "Missing or invalid Authorization header"),
 			})
 			c.Abort()
 			return
@@ -327,7 +445,7 @@ func (a *JWTAuth) Middleware(allowedIssuers []string) gin.HandlerFunc {
 
 			c.JSON(http.StatusUnauthorized, gin.H{
 				"error":   "Unauthorized",
-				"message": "Invalid Authorization header format",
+				"message": i18n.Tc(c, "Invalid Authorization header format"),
 			})
 			c.Abort()
 			return
@@ -348,7 +466,7 @@ func (a *JWTAuth) Middleware(allowedIssuers []string) gin.HandlerFunc {
 
 			c.JSON(http.StatusUnauthorized, gin.H{
 				"error":   "Unauthorized",
-				"message": fmt.Sprintf("Invalid token: %s", err.Error()),
+				"message": i18n.Tc(c, "Invalid token: %s", err.Error()),
 			})
 			c.Abort()
 			return
@@ -0,0 +1,231 @@
+package legalhold
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/zhishengyuan/searchgram-engine/engines"
+	"github.com/zhishengyuan/searchgram-engine/models"
+)
+
+// Engine refuses deletes that would remove messages of held chats, so every
+// path that deletes (API, retention, scheduled tasks, restores) is covered.
+// Deletes by query leave held chats out; deletes aimed at a held chat, a
+// message in one, or a sender with messages in one fail with ErrHeld, as do
+// deletes of the whole index while any chat is held. Soft-deletes keep the
+// message and pass through; purges of soft-deleted messages leave held
+// chats out, and trash purges keep recycle bin batches with messages of
+// held chats. Reindexes and rollbacks, which can leave messages behind, are
+// refused while any chat is held.
+type Engine struct {
+	engines.SearchEngine
+	holds *Registry
+
+	mu   sync.Mutex
+	kept map[string]bool // Trash batches kept by a hold, audited once each
+}
+
+// Delete soft-deletes a chat's messages
+func (e *Engine) Delete(ctx context.Context, chatID int64) (int64, error) {
+	if e.holds.Held(chatID) {
+		return 0, fmt.Errorf("%w: chat %d", ErrHeld, chatID)
+	}
+	return e.SearchEngine.Delete(ctx, chatID)
+}
+
+// DeleteMessage permanently removes a single message
+func (e *Engine) DeleteMessage(ctx context.Context, id string) (bool, error) {
+	if err := e.checkMessage(id); err != nil {
+		return false, err
+	}
+	return e.SearchEngine.DeleteMessage(ctx, id)
+}
+
+// DeleteByQuery removes matching messages outside held chats
+func (e *Engine) DeleteByQuery(req *models.SearchRequest, dryRun bool) (int64, error) {
+	return e.SearchEngine.DeleteByQuery(e.excludeHeld(req), dryRun)
+}
+
+// PurgeDeleted removes soft-deleted messages outside held chats
+func (e *Engine) PurgeDeleted(filter *models.DeletedFilter, dryRun bool) (int64, error) {
+	scoped := *filter
+	scoped.SearchRequest = *e.excludeHeld(&filter.SearchRequest)
+	return e.SearchEngine.PurgeDeleted(&scoped, dryRun)
+}
+
+// DeleteUser soft-deletes a sender's messages unless some are in held chats
+func (e *Engine) DeleteUser(ctx context.Context, userID int64) (int64, error) {
+	if err := e.checkUser(userID); err != nil {
+		return 0, err
+	}
+	return e.SearchEngine.DeleteUser(ctx, userID)
+}
+
+// MoveToTrash moves the selected messages outside held chats to the
+// recycle bin
+func (e *Engine) MoveToTrash(ctx context.Context, selector models.TrashSelector, operation, target string, ttl time.Duration) (*models.TrashBatch, error) {
+	switch {
+	case selector.ChatID != nil:
+		if e.holds.Held(*selector.ChatID) {
+			return nil, fmt.Errorf("%w: chat %d", ErrHeld, *selector.ChatID)
+		}
+	case selector.UserID != nil:
+		if err := e.checkUser(*selector.UserID); err != nil {
+			return nil, err
+		}
+	case selector.MessageID != "":
+		if err := e.checkMessage(selector.MessageID); err != nil {
+			return nil, err
+		}
+	case selector.Query != nil:
+		selector.Query = e.excludeHeld(selector.Query)
+	}
+	return e.SearchEngine.MoveToTrash(ctx, selector, operation, target, ttl)
+}
+
+// PurgeTrash removes expired recycle bin messages, keeping whole batches
+// with messages of held chats
+func (e *Engine) PurgeTrash(ctx context.Context, now time.Time, keepChats []int64) (int64, []string, error) {
+	keep := append(append([]int64(nil), keepChats...), e.holds.ChatIDs()...)
+	purged, kept, err := e.SearchEngine.PurgeTrash(ctx, now, keep)
+	if err != nil {
+		return purged, kept, err
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	var added []string
+	for _, batch := range kept {
+		if !e.kept[batch] {
+			e.kept[batch] = true
+			added = append(added, batch)
+		}
+	}
+	if len(added) > 0 {
+		log.WithField("batches", added).Info("Kept expired trash batches of chats under legal hold")
+		e.holds.refused("trash_purge", map[string]interface{}{
+			"batches": added,
+		})
+	}
+	return purged, kept, nil
+}
+
+// Reindex copies all messages into a new index unless a chat is held, as
+// writes made during its last pass are left behind
+func (e *Engine) Reindex(ctx context.Context, progress func(*models.ReindexProgress)) (*models.ReindexResult, error) {
+	if err := e.checkNone(); err != nil {
+		e.holds.refused("reindex", nil)
+		return nil, err
+	}
+	return e.SearchEngine.Reindex(ctx, progress)
+}
+
+// RollbackIndex switches to another index version unless a chat is held,
+// as messages written since that version are left behind
+func (e *Engine) RollbackIndex(ctx context.Context, index string) (*models.IndexRollback, error) {
+	if err := e.checkNone(); err != nil {
+		e.holds.refused("index_rollback", map[string]interface{}{
+			"index": index,
+		})
+		return nil, err
+	}
+	return e.SearchEngine.RollbackIndex(ctx, index)
+}
+
+// Clear removes all documents unless a chat is held
+func (e *Engine) Clear(ctx context.Context) error {
+	if err := e.checkNone(); err != nil {
+		return err
+	}
+	return e.SearchEngine.Clear(ctx)
+}
+
+// RecreateIndex drops all documents unless a chat is held
+func (e *Engine) RecreateIndex(ctx context.Context) error {
+	if err := e.checkNone(); err != nil {
+		return err
+	}
+	return e.SearchEngine.RecreateIndex(ctx)
+}
+
+// RestoreSnapshot replaces all documents unless a chat is held
+func (e *Engine) RestoreSnapshot(ctx context.Context, repository, snapshot string) error {
+	if err := e.checkNone(); err != nil {
+		return err
+	}
+	return e.SearchEngine.RestoreSnapshot(ctx, repository, snapshot)
+}
+
+// Dedup removes duplicate messages unless a chat is held; dry runs pass
+func (e *Engine) Dedup(ctx context.Context, dryRun bool, progress func(*models.DedupResponse)) (*models.DedupResponse, error) {
+	if !dryRun {
+		if err := e.checkNone(); err != nil {
+			return nil, err
+		}
+	}
+	return e.SearchEngine.Dedup(ctx, dryRun, progress)
+}
+
+// CleanCommands removes bot commands unless a chat is held
+func (e *Engine) CleanCommands(ctx context.Context) (*models.CleanCommandsResponse, error) {
+	if err := e.checkNone(); err != nil {
+		return nil, err
+	}
+	return e.SearchEngine.CleanCommands(ctx)
+}
+
+// excludeHeld returns a copy of req that leaves held chats out
+func (e *Engine) excludeHeld(req *models.SearchRequest) *models.SearchRequest {
+	held := e.holds.ChatIDs()
+	if len(held) == 0 {
+		return req
+	}
+
+	scoped := *req
+	scoped.ExcludedChats = append(append([]int64(nil), req.ExcludedChats...), held...)
+	return &scoped
+}
+
+// checkMessage fails when a message belongs to a held chat
+func (e *Engine) checkMessage(id string) error {
+	chatID, _, err := models.ParseMessageID(id)
+	if err != nil {
+		return err
+	}
+	if e.holds.Held(chatID) {
+		return fmt.Errorf("%w: chat %d", ErrHeld, chatID)
+	}
+	return nil
+}
+
+// checkUser fails when a sender has messages in a held chat
+func (e *Engine) checkUser(userID int64) error {
+	held := e.holds.ChatIDs()
+	if len(held) == 0 {
+		return nil
+	}
+
+	count, err := e.SearchEngine.DeleteByQuery(&models.SearchRequest{
+		SenderID:       &userID,
+		ChatIDs:        held,
+		IncludeDeleted: true,
+	}, true)
+	if err != nil {
+		return fmt.Errorf("failed to check legal holds: %w", err)
+	}
+	if count > 0 {
+		return fmt.Errorf("%w: user %d has %d messages in held chats", ErrHeld, userID, count)
+	}
+	return nil
+}
+
+// checkNone fails while any chat is held
+func (e *Engine) checkNone() error {
+	if e.holds.Any() {
+		return fmt.Errorf("%w: %d chats are held", ErrHeld, len(e.holds.ChatIDs()))
+	}
+	return nil
+}
@@ -0,0 +1,239 @@
+package legalhold
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/zhishengyuan/searchgram-engine/audit"
+	"github.com/zhishengyuan/searchgram-engine/engines"
+	"github.com/zhishengyuan/searchgram-engine/models"
+	"github.com/zhishengyuan/searchgram-engine/storage"
+)
+
+// ErrHeld is returned when a delete would remove messages of a held chat
+var ErrHeld = errors.New("messages of chats under legal hold cannot be deleted")
+
+// ErrNotFound is returned when releasing a chat that is not held
+var ErrNotFound = errors.New("legal hold not found")
+
+// Registry holds the chats under legal hold, persisted across restarts.
+// While any chat is held the lifecycle policy of the monthly partitions is
+// detached, so no partition is deleted with held messages in it.
+type Registry struct {
+	file  *storage.Document
+	audit *audit.Log // Refusals and lifecycle changes (nil = not recorded)
+
+	mu     sync.RWMutex
+	holds  map[int64]models.LegalHold
+	engine engines.SearchEngine // Engine whose partition lifecycle is paused (nil = not wrapped yet)
+}
+
+// New loads the legal holds from file
+func New(file *storage.Document, auditLog *audit.Log) (*Registry, error) {
+	r := &Registry{
+		file:  file,
+		audit: auditLog,
+		holds: make(map[int64]models.LegalHold),
+	}
+
+	var saved []models.LegalHold
+	if err := file.Load(&saved); err != nil {
+		return nil, err
+	}
+	for _, hold := range saved {
+		r.holds[hold.ChatID] = hold
+	}
+
+	log.WithFields(log.Fields{
+		"path":  file.Path(),
+		"holds": len(r.holds),
+	}).Info("Legal holds loaded")
+
+	return r, nil
+}
+
+// Wrap returns engine with deletes of held chats refused, pausing or
+// resuming its partition lifecycle to match the loaded holds
+func (r *Registry) Wrap(engine engines.SearchEngine) (engines.SearchEngine, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.engine = engine
+	if err := r.pauseLifecycle(len(r.holds) > 0); err != nil {
+		return nil, err
+	}
+	return &Engine{SearchEngine: engine, holds: r, kept: make(map[string]bool)}, nil
+}
+
+// List returns all holds ordered by chat ID
+func (r *Registry) List() []models.LegalHold {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	list := make([]models.LegalHold, 0, len(r.holds))
+	for _, hold := range r.holds {
+		list = append(list, hold)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].ChatID < list[j].ChatID
+	})
+	return list
+}
+
+// Held reports whether a chat is under legal hold
+func (r *Registry) Held(chatID int64) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	_, ok := r.holds[chatID]
+	return ok
+}
+
+// Any reports whether any chat is under legal hold
+func (r *Registry) Any() bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return len(r.holds) > 0
+}
+
+// ChatIDs returns the held chats in ascending order
+func (r *Registry) ChatIDs() []int64 {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	ids := make([]int64, 0, len(r.holds))
+	for chatID := range r.holds {
+		ids = append(ids, chatID)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
+
+// Hold places a chat under legal hold, replacing the reason of an existing
+// hold but keeping when and by whom it was first placed
+func (r *Registry) Hold(chatID int64, reason, heldBy string) (models.LegalHold, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	previous, existed := r.holds[chatID]
+	hold := models.LegalHold{
+		ChatID: chatID,
+		Reason: reason,
+		HeldBy: heldBy,
+		HeldAt: time.Now().Unix(),
+	}
+	if existed {
+		hold.HeldBy = previous.HeldBy
+		hold.HeldAt = previous.HeldAt
+	}
+
+	// The first hold stops partition deletes before it takes effect
+	if len(r.holds) == 0 {
+		if err := r.pauseLifecycle(true); err != nil {
+			return models.LegalHold{}, err
+		}
+	}
+
+	r.holds[chatID] = hold
+	if err := r.save(); err != nil {
+		if len(r.holds) == 1 {
+			r.resumeLifecycle()
+		}
+		if existed {
+			r.holds[chatID] = previous
+		} else {
+			delete(r.holds, chatID)
+		}
+		return models.LegalHold{}, err
+	}
+
+	log.WithFields(log.Fields{
+		"chat_id": chatID,
+		"reason":  reason,
+	}).Info("Legal hold placed")
+
+	return hold, nil
+}
+
+// Release lifts the legal hold of a chat
+func (r *Registry) Release(chatID int64) (models.LegalHold, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	hold, ok := r.holds[chatID]
+	if !ok {
+		return models.LegalHold{}, ErrNotFound
+	}
+
+	delete(r.holds, chatID)
+	if err := r.save(); err != nil {
+		r.holds[chatID] = hold
+		return models.LegalHold{}, err
+	}
+
+	log.WithField("chat_id", chatID).Info("Legal hold released")
+
+	if len(r.holds) == 0 {
+		r.resumeLifecycle()
+	}
+	return hold, nil
+}
+
+// pauseLifecycle detaches or attaches the partition lifecycle policy of
+// the wrapped engine (caller holds lock)
+func (r *Registry) pauseLifecycle(paused bool) error {
+	if r.engine == nil {
+		return nil
+	}
+
+	changed, err := r.engine.PauseLifecycle(context.Background(), paused)
+	if err != nil {
+		return fmt.Errorf("failed to update partition lifecycle: %w", err)
+	}
+	if changed > 0 {
+		r.audit.Record(audit.Entry{
+			Action: "legal_hold.lifecycle",
+			Detail: map[string]interface{}{
+				"paused":     paused,
+				"partitions": changed,
+			},
+		})
+	}
+	return nil
+}
+
+// resumeLifecycle attaches the partition lifecycle policy again once no
+// chat is held. On failure it stays detached, which keeps all partitions,
+// until the next restart or release. (caller holds lock)
+func (r *Registry) resumeLifecycle() {
+	if err := r.pauseLifecycle(false); err != nil {
+		log.WithError(err).Warn("Failed to resume partition lifecycle after legal holds were released")
+	}
+}
+
+// refused records an operation refused by a legal hold
+func (r *Registry) refused(operation string, detail map[string]interface{}) {
+	if detail == nil {
+		detail = map[string]interface{}{}
+	}
+	detail["operation"] = operation
+	r.audit.Record(audit.Entry{
+		Action: "legal_hold.refuse",
+		Detail: detail,
+	})
+}
+
+// save persists all holds (caller holds lock)
+func (r *Registry) save() error {
+	list := make([]models.LegalHold, 0, len(r.holds))
+	for _, hold := range r.holds {
+		list = append(list, hold)
+	}
+	return r.file.Save(list)
+}
@@ -6,16 +6,49 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"syscall"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/olivere/elastic/v7"
 	log "github.com/sirupsen/logrus"
+	"github.com/zhishengyuan/searchgram-engine/archive"
+	"github.com/zhishengyuan/searchgram-engine/audit"
+	"github.com/zhishengyuan/searchgram-engine/authguard"
+	"github.com/zhishengyuan/searchgram-engine/backup"
+	"github.com/zhishengyuan/searchgram-engine/capture"
 	"github.com/zhishengyuan/searchgram-engine/config"
+	"github.com/zhishengyuan/searchgram-engine/degrade"
+	"github.com/zhishengyuan/searchgram-engine/dictionary"
 	"github.com/zhishengyuan/searchgram-engine/engines"
+	"github.com/zhishengyuan/searchgram-engine/enrich"
+	"github.com/zhishengyuan/searchgram-engine/events"
+	"github.com/zhishengyuan/searchgram-engine/extensions"
+	"github.com/zhishengyuan/searchgram-engine/fieldcrypt"
 	"github.com/zhishengyuan/searchgram-engine/handlers"
+	"github.com/zhishengyuan/searchgram-engine/ingest"
+	"github.com/zhishengyuan/searchgram-engine/jobs"
 	jwtpkg "github.com/zhishengyuan/searchgram-engine/jwt"
+	"github.com/zhishengyuan/searchgram-engine/legalhold"
 	"github.com/zhishengyuan/searchgram-engine/middleware"
+	"github.com/zhishengyuan/searchgram-engine/models"
+	"github.com/zhishengyuan/searchgram-engine/profiles"
+	"github.com/zhishengyuan/searchgram-engine/publicstats"
+	"github.com/zhishengyuan/searchgram-engine/recyclebin"
+	"github.com/zhishengyuan/searchgram-engine/replication"
+	"github.com/zhishengyuan/searchgram-engine/retention"
+	"github.com/zhishengyuan/searchgram-engine/s3"
+	"github.com/zhishengyuan/searchgram-engine/saved"
+	"github.com/zhishengyuan/searchgram-engine/scheduler"
+	"github.com/zhishengyuan/searchgram-engine/sessions"
+	"github.com/zhishengyuan/searchgram-engine/signedurl"
+	"github.com/zhishengyuan/searchgram-engine/storage"
+	"github.com/zhishengyuan/searchgram-engine/subscriptions"
+	"github.com/zhishengyuan/searchgram-engine/tenants"
+	"github.com/zhishengyuan/searchgram-engine/tracing"
+	"github.com/zhishengyuan/searchgram-engine/translit"
+	"github.com/zhishengyuan/searchgram-engine/usage"
 	"golang.org/x/net/http2"
 	"golang.org/x/net/http2/h2c"
 )
@@ -42,6 +75,33 @@ func main() {
 		log.WithError(err).Fatal("Failed to load configuration")
 	}
 
+	// Maintenance commands such as backup and restore run instead of the API
+	if len(os.Args) > 1 {
+		if err := runCommand(cfg, os.Args[1:]); err != nil {
+			log.WithError(err).Fatal("Command failed")
+		}
+		return
+	}
+
+	// Export spans of requests, engine calls and Elasticsearch requests
+	var tracer *tracing.Provider
+	if cfg.Tracing.Enabled {
+		tracer, err = tracing.New(tracing.Config{
+			Endpoint:    cfg.Tracing.Endpoint,
+			Headers:     cfg.Tracing.Headers,
+			ServiceName: cfg.Tracing.ServiceName,
+			SampleRatio: cfg.Tracing.SampleRatio,
+			Timeout:     cfg.Tracing.Timeout,
+		})
+		if err != nil {
+			log.WithError(err).Fatal("Failed to initialize tracing")
+		}
+		log.WithFields(log.Fields{
+			"endpoint":     cfg.Tracing.Endpoint,
+			"sample_ratio": cfg.Tracing.SampleRatio,
+		}).Info("Tracing enabled")
+	}
+
 	// Initialize JWT auth if enabled
 	var jwtAuth *jwtpkg.JWTAuth
 	if cfg.Auth.UseJWT {
@@ -53,6 +113,8 @@ func main() {
 			PublicKeyInline:  cfg.Auth.PublicKeyInline,
 			PrivateKeyInline: cfg.Auth.PrivateKeyInline,
 			TokenTTL:         cfg.Auth.TokenTTL,
+			JWKSURL:          cfg.Auth.JWKSURL,
+			JWKSRefresh:      cfg.Auth.JWKSRefresh,
 		}
 		jwtAuth, err = jwtpkg.NewJWTAuth(jwtConfig)
 		if err != nil {
@@ -60,28 +122,651 @@ func main() {
 		}
 	}
 
-	// Initialize search engine
+	// Domain terms that are tagged at ingest and matched as a whole instead
+	// of as bigrams
+	var dict *dictionary.Dictionary
+	if cfg.Dictionary.Enabled {
+		dict, err = dictionary.Load(cfg.Dictionary.Path)
+		if err != nil {
+			log.WithError(err).Fatal("Failed to load dictionary")
+		}
+	}
+
+	// Optionally serve /livez while the engine connects, so orchestrators
+	// don't restart us while Elasticsearch is still booting
+	gate := &startupGate{}
+	var srv *http.Server
+	if cfg.Server.EarlyLivez {
+		srv = newServer(cfg, gate)
+		startServer(srv, cfg)
+	}
+
+	// Initialize search engine, retrying while it comes up
+	startupCtx, stopStartup := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
 	var engine engines.SearchEngine
 	switch cfg.SearchEngine.Type {
 	case "elasticsearch":
-		engine, err = engines.NewElasticsearch(
-			cfg.Elasticsearch.Host,
-			cfg.Elasticsearch.Username,
-			cfg.Elasticsearch.Password,
-			cfg.Elasticsearch.Index,
-			cfg.Elasticsearch.Shards,
-			cfg.Elasticsearch.Replicas,
-		)
+		err = connectWithRetry(startupCtx, "Elasticsearch", cfg.Elasticsearch.StartupMaxWait, cfg.Elasticsearch.StartupBackoff, func() error {
+			es, err := newElasticsearch(cfg, cfg.Elasticsearch.Index, dict)
+			if err != nil {
+				return err
+			}
+			if cfg.Rerank.Enabled {
+				log.WithFields(log.Fields{
+					"url":   cfg.Rerank.URL,
+					"top_k": cfg.Rerank.TopK,
+				}).Info("Hybrid search reranking enabled")
+			}
+			engine = es
+			return nil
+		})
 		if err != nil {
 			log.WithError(err).Fatal("Failed to initialize Elasticsearch")
 		}
 	default:
 		log.Fatalf("Unsupported search engine type: %s", cfg.SearchEngine.Type)
 	}
+	stopStartup()
 	defer engine.Close()
 
+	// Engine state: jobs, tenants, saved searches, rules and checkpoints
+	state, err := stateBackend(cfg)
+	if err != nil {
+		log.WithError(err).Fatal("Failed to initialize state storage")
+	}
+	log.WithField("backend", state.Name()).Info("State storage ready")
+
+	// Append-only trail of security events
+	var auditLog *audit.Log
+	if cfg.Audit.Enabled {
+		auditLog, err = audit.Open(filepath.Join(cfg.Storage.DataDir, "audit.log"))
+		if err != nil {
+			log.WithError(err).Fatal("Failed to open audit log")
+		}
+		defer auditLog.Close()
+
+		if cfg.Audit.Index {
+			index, ok := engine.(audit.Index)
+			if !ok {
+				log.Fatalf("The audit index is not supported by search engine %s", cfg.SearchEngine.Type)
+			}
+			auditLog.SetIndex(index)
+		}
+	}
+
+	// Background job manager for long-running operations
+	jobManager, err := jobs.NewManager(storage.NewDocument(state, "jobs.json"))
+	if err != nil {
+		log.WithError(err).Fatal("Failed to initialize job manager")
+	}
+
+	// Ingest-time enrichment applied to every indexed message
+	location, err := cfg.Time.Location()
+	if err != nil {
+		log.WithError(err).Fatal("Failed to load timezone")
+	}
+	pipeline := enrich.NewPipeline()
+
+	// Mask personal data before any other stage derives fields from the text
+	var redactor *enrich.Redactor
+	if cfg.Redaction.Enabled {
+		redactor, err = enrich.NewRedactor(cfg.Redaction.Types, cfg.Redaction.Patterns, cfg.Redaction.MaskChar)
+		if err != nil {
+			log.WithError(err).Fatal("Failed to load redaction patterns")
+		}
+		pipeline.Add(redactor)
+		log.WithFields(log.Fields{
+			"types":    cfg.Redaction.Types,
+			"patterns": len(cfg.Redaction.Patterns),
+		}).Info("PII redaction enabled")
+	}
+
+	pipeline.Add(enrich.TextStats{})
+	pipeline.Add(enrich.NewTimeBuckets(location))
+	pipeline.Add(enrich.MediaInfo{})
+	pipeline.Add(enrich.Entities{})
+	// Media is read in the background, after indexing
+	if cfg.Transcription.Enabled {
+		pipeline.AddMedia(enrich.NewTranscriber(enrich.TranscriberConfig{
+			URL:         cfg.Transcription.URL,
+			APIKey:      cfg.Transcription.APIKey,
+			Model:       cfg.Transcription.Model,
+			Language:    cfg.Transcription.Language,
+			MediaTypes:  cfg.Transcription.MediaTypes,
+			MaxFileSize: cfg.Transcription.MaxFileSize,
+			MediaHosts:  cfg.Media.AllowedHosts,
+			Timeout:     cfg.Transcription.Timeout,
+		}))
+		log.WithField("url", cfg.Transcription.URL).Info("Voice transcription enabled")
+	}
+	if cfg.OCR.Enabled {
+		pipeline.AddMedia(enrich.NewOCR(enrich.OCRConfig{
+			URL:         cfg.OCR.URL,
+			APIKey:      cfg.OCR.APIKey,
+			FileField:   cfg.OCR.FileField,
+			Fields:      cfg.OCR.Fields,
+			TextField:   cfg.OCR.TextField,
+			MediaTypes:  cfg.OCR.MediaTypes,
+			MaxFileSize: cfg.OCR.MaxFileSize,
+			MediaHosts:  cfg.Media.AllowedHosts,
+			Timeout:     cfg.OCR.Timeout,
+		}))
+		log.WithField("url", cfg.OCR.URL).Info("Image OCR enabled")
+	}
+
+	// Detect the language once transcripts are known
+	pipeline.Add(enrich.Language{})
+
+	// Tag dictionary terms in the final text, including transcripts and OCR text
+	if dict != nil {
+		pipeline.Add(enrich.NewTerms(dict))
+	}
+
+	// Classify spam on the full text, including transcripts and OCR text
+	if cfg.Spam.Enabled {
+		rules := make([]enrich.SpamRule, len(cfg.Spam.Rules))
+		for i, rule := range cfg.Spam.Rules {
+			rules[i] = enrich.SpamRule{Pattern: rule.Pattern, Weight: rule.Weight}
+		}
+		spamRules, err := enrich.NewSpamRules(rules)
+		if err != nil {
+			log.WithError(err).Fatal("Failed to load spam rules")
+		}
+		classifiers := []enrich.SpamClassifier{spamRules}
+		if cfg.Spam.URL != "" {
+			classifiers = append(classifiers, enrich.NewSpamModel(enrich.SpamModelConfig{
+				URL:        cfg.Spam.URL,
+				APIKey:     cfg.Spam.APIKey,
+				ScoreField: cfg.Spam.ScoreField,
+				Timeout:    cfg.Spam.Timeout,
+			}))
+		}
+		pipeline.Add(enrich.NewSpam(cfg.Spam.Threshold, classifiers...))
+		log.WithFields(log.Fields{
+			"rules":      len(rules),
+			"classifier": cfg.Spam.URL,
+		}).Info("Spam classification enabled")
+	}
+
+	// Embed last so transcripts and OCR text are part of the vector
+	var embedder *enrich.Embedder
+	if cfg.Embeddings.Enabled {
+		embedder = enrich.NewEmbedder(enrich.EmbedderConfig{
+			URL:        cfg.Embeddings.URL,
+			APIKey:     cfg.Embeddings.APIKey,
+			Model:      cfg.Embeddings.Model,
+			Dimensions: cfg.Embeddings.Dimensions,
+			Timeout:    cfg.Embeddings.Timeout,
+		})
+		pipeline.Add(embedder)
+		log.WithFields(log.Fields{
+			"url":        cfg.Embeddings.URL,
+			"dimensions": cfg.Embeddings.Dimensions,
+		}).Info("Message embeddings enabled")
+	}
+
+	// Replay writes on a secondary engine in another location; it is
+	// connected in the background so an unreachable site never blocks startup
+	var replicator *replication.Replicator
+	if cfg.Replication.Enabled {
+		secondary := cfg.Replication.Elasticsearch
+		replicator, err = replication.New(func() (engines.SearchEngine, error) {
+			es, err := engines.NewElasticsearch(
+				secondary.Host,
+				secondary.Username,
+				secondary.Password,
+				secondary.Index,
+				secondary.Shards,
+				secondary.Replicas,
+				engines.PartitionConfig{
+					Period:    secondary.Partition,
+					ILMPolicy: secondary.ILMPolicy,
+				},
+				engines.AnalysisConfig{
+					ChineseFolding: secondary.ChineseFolding,
+				},
+			)
+			if err == nil && cfg.Embeddings.Enabled {
+				err = es.EnableEmbeddings(cfg.Embeddings.Dimensions)
+			}
+			if err == nil {
+				err = enableEncryption(cfg, es)
+			}
+			if err != nil {
+				return nil, err
+			}
+			return es, nil
+		}, storage.NewDocument(state, "replication.json"), replication.Config{
+			QueueSize:  cfg.Replication.QueueSize,
+			BatchSize:  cfg.Replication.BatchSize,
+			MaxBackoff: cfg.Replication.MaxBackoff,
+		})
+		if err != nil {
+			log.WithError(err).Fatal("Failed to load replication state")
+		}
+		engine = replicator.Wrap(engine)
+		replicator.Start()
+	}
+
+	// Spool indexed messages and upload them to object storage for cold
+	// storage independent of the search backend
+	var archiver *archive.Archiver
+	if cfg.Archive.Enabled {
+		bucket, err := newBucket(cfg.Archive.S3)
+		if err != nil {
+			log.WithError(err).Fatal("Failed to initialize archive bucket")
+		}
+		archiver, err = archive.New(bucket, archive.Config{
+			Interval: cfg.Archive.Interval,
+			Prefix:   cfg.Archive.S3.Prefix,
+			SpoolDir: filepath.Join(cfg.Storage.DataDir, "archive"),
+		})
+		if err != nil {
+			log.WithError(err).Fatal("Failed to load archive spool")
+		}
+		engine = archiver.Wrap(engine)
+		archiver.Start()
+	}
+
+	// Report indexed, updated and deleted messages to an external sink, so
+	// other systems can react without polling
+	var eventBus *events.Bus
+	if cfg.Events.Enabled {
+		sink, err := eventSink(cfg.Events)
+		if err != nil {
+			log.WithError(err).Fatal("Failed to initialize event sink")
+		}
+		eventBus, err = events.New(sink, events.Config{
+			Types:          cfg.Events.Types,
+			QueueSize:      cfg.Events.QueueSize,
+			BatchSize:      cfg.Events.BatchSize,
+			MaxAttempts:    cfg.Events.MaxAttempts,
+			MaxBackoff:     cfg.Events.MaxBackoff,
+			DeadLetterPath: filepath.Join(cfg.Storage.DataDir, "events_dead_letter.jsonl"),
+		})
+		if err != nil {
+			log.WithError(err).Fatal("Failed to load event dead-letter log")
+		}
+		engine = eventBus.Wrap(engine)
+		eventBus.Start()
+	}
+
+	// Extensions compiled in via build tags or loaded from the plugins
+	// directory hook into indexing, search and deletes through the engine
+	hooks, err := extensions.Load(extensions.Config{
+		PluginsDir: cfg.Extensions.PluginsDir,
+		Disabled:   cfg.Extensions.Disabled,
+		Settings:   cfg.Extensions.Settings,
+	}, pipeline)
+	if err != nil {
+		log.WithError(err).Fatal("Failed to load extensions")
+	}
+	engine = extensions.Wrap(engine, hooks)
+
+	// Refuse deleting messages of chats under legal hold on every delete
+	// path, so held chats are kept until an admin releases them
+	var legalHolds *legalhold.Registry
+	if cfg.LegalHold.Enabled {
+		legalHolds, err = legalhold.New(storage.NewDocument(state, "legal_holds.json"), auditLog)
+		if err != nil {
+			log.WithError(err).Fatal("Failed to load legal holds")
+		}
+		engine, err = legalHolds.Wrap(engine)
+		if err != nil {
+			log.WithError(err).Fatal("Failed to apply legal holds")
+		}
+	}
+
+	// Outermost, so engine spans include the time spent in the wrappers
+	if cfg.Tracing.Enabled {
+		engine = tracing.Wrap(engine)
+	}
+
+	// Tenants keep their messages in indices of their own, connected on
+	// first use with the settings of the shared index
+	var tenantRegistry *tenants.Registry
+	if cfg.Tenants.Enabled {
+		tenantRegistry, err = tenants.New(storage.NewDocument(state, "tenants.json"), cfg.Elasticsearch.Index, func(index string) (tenants.Engine, error) {
+			return newElasticsearch(cfg, index, dict)
+		})
+		if err != nil {
+			log.WithError(err).Fatal("Failed to load tenants")
+		}
+		defer tenantRegistry.Close()
+	}
+
 	// Create API handler
-	apiHandler := handlers.NewAPIHandler(engine, startTime)
+	apiHandler := handlers.NewAPIHandler(engine, jobManager, pipeline, startTime)
+	apiHandler.SetReadiness(cfg.Health.Timeout, cfg.Health.QueueThreshold)
+	if embedder != nil {
+		apiHandler.SetEmbedder(embedder)
+		apiHandler.SetHybridOptions(handlers.HybridOptions{
+			LexicalWeight:  cfg.Embeddings.LexicalWeight,
+			SemanticWeight: cfg.Embeddings.SemanticWeight,
+			Rerank:         cfg.Rerank.Enabled,
+			RerankTopK:     cfg.Rerank.TopK,
+		})
+	}
+	if cfg.Translation.Enabled {
+		apiHandler.SetTranslator(enrich.NewTranslator(enrich.TranslatorConfig{
+			URL:       cfg.Translation.URL,
+			APIKey:    cfg.Translation.APIKey,
+			Targets:   cfg.Translation.Targets,
+			Timeout:   cfg.Translation.Timeout,
+			CacheSize: cfg.Translation.CacheSize,
+		}))
+		log.WithFields(log.Fields{
+			"url":     cfg.Translation.URL,
+			"targets": cfg.Translation.Targets,
+		}).Info("Search query translation enabled")
+	}
+	apiHandler.SetLocation(location)
+	apiHandler.SetDeleteThreshold(cfg.Guardrails.DeleteThreshold)
+	apiHandler.SetClearEnabled(cfg.Guardrails.ClearEnabled)
+
+	// Keep deleted messages restorable until they expire
+	var recycleBin *recyclebin.Bin
+	if cfg.RecycleBin.Enabled {
+		recycleBin = recyclebin.New(engine, recyclebin.Config{
+			TTL:           cfg.RecycleBin.TTL,
+			PurgeInterval: cfg.RecycleBin.PurgeInterval,
+		})
+		recycleBin.Start()
+		apiHandler.SetRecycleBin(recycleBin)
+	}
+	apiHandler.SetSoftDelete(cfg.SoftDelete.Enabled)
+	apiHandler.SetMaxBatchSize(cfg.Ingest.MaxBatchSize)
+	apiHandler.SetAuditLog(auditLog)
+	apiHandler.SetReplicator(replicator)
+	apiHandler.SetEvents(eventBus)
+	apiHandler.SetArchiver(archiver)
+	apiHandler.SetLegalHolds(legalHolds)
+	if dict != nil {
+		apiHandler.SetDictionary(dict)
+	}
+	if tenantRegistry != nil {
+		apiHandler.SetTenants(tenantRegistry)
+	}
+	if cfg.PublicStats.Enabled {
+		apiHandler.SetPublicStats(publicstats.New(engine, publicstats.Config{
+			Chats:    cfg.PublicStats.Chats,
+			MinUsers: cfg.PublicStats.MinUsers,
+			Epsilon:  cfg.PublicStats.Epsilon,
+			MaxDays:  cfg.PublicStats.MaxDays,
+			Secret:   cfg.PublicStats.Secret,
+			CacheTTL: cfg.PublicStats.CacheTTL,
+		}))
+	}
+	if cfg.TimeTravel.Enabled {
+		apiHandler.SetSnapshotSearch(cfg.TimeTravel.Repository)
+	}
+
+	// Slow down and ban key-guessing scans against the authenticated API
+	var authGuard *authguard.Guard
+	if cfg.AuthGuard.Enabled && (cfg.Auth.UseJWT || cfg.Auth.Enabled) {
+		authGuard = authguard.New(authguard.Config{
+			FreeFailures:   cfg.AuthGuard.FreeFailures,
+			Window:         cfg.AuthGuard.Window,
+			BaseDelay:      cfg.AuthGuard.BaseDelay,
+			MaxDelay:       cfg.AuthGuard.MaxDelay,
+			BanAfter:       cfg.AuthGuard.BanAfter,
+			BanDuration:    cfg.AuthGuard.BanDuration,
+			MaxBanDuration: cfg.AuthGuard.MaxBanDuration,
+
+			TrustProxyHeaders: len(cfg.Server.TrustedProxies) > 0,
+		}, auditLog)
+		apiHandler.SetAuthGuard(authGuard)
+	}
+
+	// Shed expensive search work under load instead of timing out everything
+	if cfg.Degradation.Enabled {
+		apiHandler.SetDegradation(degrade.NewGovernor(degrade.Config{
+			LatencyThreshold: cfg.Degradation.LatencyThreshold,
+			MaxInFlight:      cfg.Degradation.MaxInFlight,
+			Hold:             cfg.Degradation.Hold,
+		}), cfg.Degradation.PageSize)
+	}
+
+	// Optional write-behind queue for single upserts
+	var ingestQueue *ingest.Queue
+	if cfg.Ingest.Async {
+		ingestQueue = ingest.NewQueue(engine, ingest.Config{
+			Capacity:      cfg.Ingest.QueueSize,
+			FlushSize:     cfg.Ingest.FlushSize,
+			FlushInterval: cfg.Ingest.FlushInterval,
+		})
+		ingestQueue.Start()
+		apiHandler.SetIngestQueue(ingestQueue)
+	}
+
+	// Transcription and OCR read media after messages are indexed
+	var mediaWorker *enrich.MediaWorker
+	if cfg.Transcription.Enabled || cfg.OCR.Enabled {
+		mediaWorker = enrich.NewMediaWorker(pipeline, enrich.MediaWorkerConfig{
+			Workers:   cfg.Media.Workers,
+			QueueSize: cfg.Media.QueueSize,
+		})
+		mediaWorker.Start()
+		apiHandler.SetMediaWorker(mediaWorker)
+	}
+
+	// Non-HTTP ingestion consumers share the batch indexing path
+	indexMessages := func(messages []models.Message) (int, error) {
+		result, err := apiHandler.IndexMessages(messages, usage.DefaultTenant)
+		return result.IndexedCount, err
+	}
+
+	var kafkaConsumer *ingest.KafkaConsumer
+	if cfg.Ingest.Kafka.Enabled {
+		kafkaConsumer = ingest.NewKafkaConsumer(ingest.KafkaConfig{
+			Brokers:      cfg.Ingest.Kafka.Brokers,
+			Topic:        cfg.Ingest.Kafka.Topic,
+			GroupID:      cfg.Ingest.Kafka.GroupID,
+			BatchSize:    cfg.Ingest.Kafka.BatchSize,
+			BatchTimeout: cfg.Ingest.Kafka.BatchTimeout,
+		}, indexMessages)
+		kafkaConsumer.Start()
+	}
+
+	var natsConsumer *ingest.NATSConsumer
+	if cfg.Ingest.NATS.Enabled {
+		natsConsumer, err = ingest.NewNATSConsumer(ingest.NATSConfig{
+			URL:          cfg.Ingest.NATS.URL,
+			Stream:       cfg.Ingest.NATS.Stream,
+			Subject:      cfg.Ingest.NATS.Subject,
+			Durable:      cfg.Ingest.NATS.Durable,
+			BatchSize:    cfg.Ingest.NATS.BatchSize,
+			BatchTimeout: cfg.Ingest.NATS.BatchTimeout,
+			AckWait:      cfg.Ingest.NATS.AckWait,
+		}, indexMessages)
+		if err != nil {
+			log.WithError(err).Fatal("Failed to initialize NATS JetStream consumer")
+		}
+		natsConsumer.Start()
+	}
+
+	var redisConsumer *ingest.RedisConsumer
+	if cfg.Ingest.Redis.Enabled {
+		redisConsumer, err = ingest.NewRedisConsumer(ingest.RedisConfig{
+			Addr:         cfg.Ingest.Redis.Addr,
+			Password:     cfg.Ingest.Redis.Password,
+			DB:           cfg.Ingest.Redis.DB,
+			Stream:       cfg.Ingest.Redis.Stream,
+			Group:        cfg.Ingest.Redis.Group,
+			Consumer:     cfg.Ingest.Redis.Consumer,
+			Field:        cfg.Ingest.Redis.Field,
+			BatchSize:    cfg.Ingest.Redis.BatchSize,
+			BatchTimeout: cfg.Ingest.Redis.BatchTimeout,
+			ClaimIdle:    cfg.Ingest.Redis.ClaimIdle,
+		}, indexMessages)
+		if err != nil {
+			log.WithError(err).Fatal("Failed to initialize Redis Streams consumer")
+		}
+		redisConsumer.Start()
+	}
+
+	var telegramClient *ingest.TelegramClient
+	if cfg.Ingest.Telegram.Enabled {
+		sessionFile := cfg.Ingest.Telegram.SessionFile
+		if sessionFile == "" {
+			sessionFile = filepath.Join(cfg.Storage.DataDir, "telegram.session")
+		}
+		telegramClient = ingest.NewTelegramClient(ingest.TelegramConfig{
+			AppID:       cfg.Ingest.Telegram.AppID,
+			AppHash:     cfg.Ingest.Telegram.AppHash,
+			Phone:       cfg.Ingest.Telegram.Phone,
+			Password:    cfg.Ingest.Telegram.Password,
+			SessionFile: sessionFile,
+			Chats:       cfg.Ingest.Telegram.Chats,
+
+			BackfillDelay: cfg.Ingest.Telegram.BackfillDelay,
+		}, ingest.TelegramSink{
+			Index: indexMessages,
+			Edit:  apiHandler.ApplyEdit,
+			Delete: func(chatID, messageID int64) error {
+				return engine.SoftDeleteMessage(context.Background(), chatID, messageID)
+			},
+		})
+		telegramClient.Start()
+		apiHandler.SetTelegramClient(telegramClient)
+	}
+
+	// Initialize usage export if enabled
+	var usageRecorder *usage.Recorder
+	if cfg.Usage.Enabled {
+		usageRecorder = usage.NewRecorder(usage.Config{
+			Interval: cfg.Usage.Interval,
+			Sink:     cfg.Usage.Sink,
+			URL:      cfg.Usage.URL,
+			Timeout:  cfg.Usage.Timeout,
+		}, func(tenant string) (int64, error) {
+			// Tenants with an index of their own are attributed its size
+			if tenantRegistry != nil {
+				if _, ok := tenantRegistry.Get(tenant); ok {
+					tenantEngine, err := tenantRegistry.Engine(tenant)
+					if err != nil {
+						return 0, err
+					}
+					stats, err := tenantEngine.Stats(context.Background())
+					if err != nil {
+						return 0, err
+					}
+					return stats.IndexSizeBytes, nil
+				}
+			}
+
+			// The others share one index, so its storage is attributed to the default tenant
+			if tenant != usage.DefaultTenant {
+				return 0, nil
+			}
+			stats, err := engine.Stats(context.Background())
+			if err != nil {
+				return 0, err
+			}
+			return stats.IndexSizeBytes, nil
+		})
+		usageRecorder.Start()
+		apiHandler.SetUsageRecorder(usageRecorder)
+	}
+
+	// Initialize capture rules if enabled
+	if cfg.Capture.Enabled {
+		captureRules, err := capture.NewStore(storage.NewDocument(state, "capture_rules.json"))
+		if err != nil {
+			log.WithError(err).Fatal("Failed to load capture rules")
+		}
+		apiHandler.SetCaptureRules(captureRules, cfg.Capture.Enforce)
+		if jwtAuth != nil && jwtAuth.CanSign() {
+			apiHandler.SetConfigSigner(jwtAuth)
+		}
+	}
+
+	// Initialize keyword subscriptions if enabled
+	var subscriptionManager *subscriptions.Manager
+	if cfg.Subscriptions.Enabled {
+		subscriptionManager, err = subscriptions.NewManager(subscriptions.Config{
+			QueueSize:  cfg.Subscriptions.QueueSize,
+			MaxPerUser: cfg.Subscriptions.MaxPerUser,
+			WebhookURL: cfg.Subscriptions.WebhookURL,
+		}, storage.NewDocument(state, "subscriptions.json"))
+		if err != nil {
+			log.WithError(err).Fatal("Failed to load keyword subscriptions")
+		}
+		subscriptionManager.StartWebhook()
+		apiHandler.SetSubscriptions(subscriptionManager)
+	}
+
+	// Initialize search profiles if enabled
+	if cfg.Profiles.Enabled {
+		searchProfiles, err := profiles.NewStore(storage.NewDocument(state, "profiles.json"))
+		if err != nil {
+			log.WithError(err).Fatal("Failed to load search profiles")
+		}
+		apiHandler.SetProfiles(searchProfiles)
+	}
+
+	// Tag, search and digest users' Saved Messages
+	if cfg.SavedMessages.Enabled {
+		savedStore, err := saved.New(storage.NewDocument(state, "saved_tags.json"), cfg.SavedMessages.MaxTags)
+		if err != nil {
+			log.WithError(err).Fatal("Failed to load saved message tags")
+		}
+		apiHandler.SetSaved(savedStore)
+	}
+
+	// Purge messages past their chat's retention
+	var retentionManager *retention.Manager
+	if cfg.Retention.Enabled {
+		chats := make(map[int64]int, len(cfg.Retention.Chats))
+		for _, chat := range cfg.Retention.Chats {
+			chats[chat.ChatID] = chat.Days
+		}
+		retentionManager, err = retention.New(engine, storage.NewDocument(state, "retention.json"), retention.Config{
+			DefaultDays:   cfg.Retention.DefaultDays,
+			Chats:         chats,
+			PurgeInterval: cfg.Retention.PurgeInterval,
+		})
+		if err != nil {
+			log.WithError(err).Fatal("Failed to load retention policies")
+		}
+		retentionManager.Start()
+		apiHandler.SetRetention(retentionManager)
+	}
+
+	// Run recurring maintenance as background jobs
+	var taskScheduler *scheduler.Scheduler
+	if cfg.Scheduler.Enabled {
+		taskScheduler = scheduler.New(jobManager, location)
+		for _, task := range cfg.Scheduler.Tasks {
+			if err := taskScheduler.Add(scheduledTask(task, engine, retentionManager)); err != nil {
+				log.WithError(err).Fatal("Failed to schedule task")
+			}
+		}
+		taskScheduler.Start()
+		apiHandler.SetScheduler(taskScheduler, location)
+	}
+
+	// Back up and restore the index via the API
+	if cfg.Backup.Enabled {
+		var bucket *s3.Client
+		if cfg.Backup.S3.Enabled {
+			bucket, err = newBucket(cfg.Backup.S3)
+			if err != nil {
+				log.WithError(err).Fatal("Failed to initialize backup bucket")
+			}
+		}
+		apiHandler.SetBackup(backup.NewService(engine, backup.ServiceConfig{
+			Type:           cfg.Backup.Type,
+			Engine:         cfg.SearchEngine.Type,
+			Index:          cfg.Elasticsearch.Index,
+			Path:           cfg.Backup.Path,
+			SegmentSize:    cfg.Backup.SegmentSize,
+			Repository:     cfg.Backup.Repository,
+			S3Prefix:       cfg.Backup.S3.Prefix,
+			State:          state,
+			DropEmbeddings: !cfg.Embeddings.Enabled,
+		}, bucket))
+	}
 
 	// Setup Gin router
 	if cfg.Logging.Level != "debug" {
@@ -89,11 +774,51 @@ func main() {
 	}
 
 	router := gin.New()
+	if len(cfg.Server.TrustedProxies) > 0 {
+		if err := router.SetTrustedProxies(cfg.Server.TrustedProxies); err != nil {
+			log.WithError(err).Fatal("Invalid server trusted_proxies")
+		}
+	} else if cfg.IPFilter.Enabled {
+		// Trusting every proxy would let any client choose the address the
+		// filter checks, so only the connection's address counts
+		router.SetTrustedProxies(nil)
+	}
+	if len(cfg.Server.RemoteIPHeaders) > 0 {
+		router.RemoteIPHeaders = cfg.Server.RemoteIPHeaders
+	}
 
 	// Global middleware
+	if cfg.Tracing.Enabled {
+		router.Use(middleware.Tracing())
+	}
+	// Outside the middleware that rewrite responses, to log the sizes sent
+	router.Use(middleware.RequestLogger(middleware.RequestLoggerConfig{
+		SlowThreshold: cfg.Logging.SlowRequest,
+		SampleRate:    cfg.Logging.RequestSampleRate,
+	}))
+	router.Use(middleware.Localize(cfg.I18n.Language()))
+	router.Use(middleware.FieldNaming(middleware.FieldNamingConfig{
+		DefaultCase: cfg.Response.FieldCase,
+		IssuerCase:  cfg.Response.IssuerFieldCase,
+		Aliases:     cfg.Response.Aliases,
+	}))
+	// Inside field naming, so request_id in error bodies is renamed too
+	router.Use(middleware.RequestID())
 	router.Use(middleware.Recovery())
 	router.Use(middleware.CORS())
-	router.Use(middleware.RequestLogger())
+
+	// Lock the engine down to known hosts, e.g. the bot and ingestion hosts
+	if cfg.IPFilter.Enabled {
+		ipFilter, err := middleware.NewIPFilter(cfg.IPFilter.Allow, cfg.IPFilter.Deny)
+		if err != nil {
+			log.WithError(err).Fatal("Invalid ip_filter")
+		}
+		router.Use(ipFilter.Middleware())
+		log.WithFields(log.Fields{
+			"allow": len(cfg.IPFilter.Allow),
+			"deny":  len(cfg.IPFilter.Deny),
+		}).Info("IP filter enabled")
+	}
 
 	// Public endpoints (no auth required)
 	// Root endpoint
@@ -106,76 +831,316 @@ func main() {
 		})
 	})
 
-	// Health check endpoint (no auth required for monitoring)
-	router.GET("/health", func(c *gin.Context) {
+	// Health check endpoint (no auth required for monitoring); kept for
+	// existing probes as the readiness check, /health/live and
+	// /health/ready tell liveness and readiness apart
+	router.GET("/health", apiHandler.Ready)
+
+	// Kubernetes probes: liveness never checks dependencies, so an outage of
+	// Elasticsearch takes instances out of rotation without restarting them
+	router.GET("/health/live", apiHandler.Live)
+	router.GET("/health/ready", apiHandler.Ready)
+
+	// Liveness probe; also answered during startup when server.early_livez is set
+	router.GET("/livez", func(c *gin.Context) {
 		c.JSON(http.StatusOK, gin.H{
-			"status": "healthy",
+			"status": "alive",
+			"engine": "connected",
 		})
 	})
 
+	// Our public keys, so other services can verify our tokens
+	if jwtAuth != nil && cfg.Auth.ServeJWKS {
+		router.GET("/.well-known/jwks.json", jwtAuth.JWKSHandler())
+	}
+
+	// Telegram cannot send API keys, so the Bot API webhook authenticates with
+	// its secret token instead and is registered outside the protected group
+	if cfg.Ingest.BotWebhook.Enabled {
+		apiHandler.SetTelegramWebhookSecret(cfg.Ingest.BotWebhook.SecretToken)
+		router.POST("/api/v1/telegram/webhook", middleware.Timeout(cfg.Timeouts.Ingest), apiHandler.TelegramWebhook)
+		log.Info("Telegram Bot API webhook enabled at /api/v1/telegram/webhook")
+	}
+
 	// Protected API routes with authentication
 	v1 := router.Group("/api/v1")
 
-	// Apply auth middleware to API routes only
+	// Apply auth middleware to API routes only, behind brute-force protection
+	v1.Use(authGuard.Middleware())
+	var sessionStore *sessions.Store
+	var authenticate gin.HandlerFunc
+
+	// API keys are compared by hash in constant time and can be rotated
+	// without a restart via SIGHUP or POST /api/v1/auth/keys/reload
+	var keyRing *middleware.KeyRing
+	if cfg.Auth.Enabled || len(cfg.Auth.APIKeys) > 0 {
+		keyRing = middleware.NewKeyRing(apiKeys(cfg.Auth), scopedKeys(cfg.Auth), loadAPIKeys(configPath))
+		reloadAPIKeysOnSIGHUP(keyRing)
+		apiHandler.SetAPIKeys(keyRing)
+	}
+
 	if cfg.Auth.UseJWT && jwtAuth != nil {
 		// Use JWT auth for all API routes
-		allowedIssuers := []string{"bot", "userbot", "search"}
-		v1.Use(jwtAuth.Middleware(allowedIssuers))
+		authenticate = jwtAuth.Middleware(cfg.Auth.AllowedIssuers)
+
+		// With API keys configured as well, both are accepted, e.g. JWTs
+		// for the ingestion service and a key for the bot; routes.auth
+		// decides which route group takes which
+		if cfg.Auth.Enabled {
+			authenticate = middleware.APIKeyOrJWT(keyRing, middleware.APIKeyAuth(cfg.Auth.Enabled, keyRing), authenticate)
+			log.Info("Accepting both JWTs and API keys")
+		}
 	} else if cfg.Auth.Enabled {
 		// Fall back to legacy API key auth
-		v1.Use(middleware.APIKeyAuth(cfg.Auth.Enabled, cfg.Auth.APIKey))
+		authenticate = middleware.APIKeyAuth(cfg.Auth.Enabled, keyRing)
 	} else {
 		log.Warn("Authentication is DISABLED - this is not recommended for production")
 	}
+	if authenticate != nil {
+		// Tenants authenticate with API keys of their own
+		if tenantRegistry != nil {
+			authenticate = middleware.TenantKeyAuth(tenantRegistry, authenticate)
+		}
 
-	{
-		// Message operations
-		v1.POST("/upsert", apiHandler.Upsert)
-		v1.POST("/upsert/batch", apiHandler.UpsertBatch)
-		v1.POST("/search", apiHandler.Search)
-		v1.POST("/messages/soft-delete", apiHandler.SoftDeleteMessage)
-		v1.DELETE("/messages", apiHandler.DeleteMessages)
-		v1.DELETE("/users/:user_id", apiHandler.DeleteUser)
-		v1.DELETE("/clear", apiHandler.Clear)
+		// Further API keys limited to the messages of some chats, e.g. for
+		// the admin of one group; reloaded along with the other keys
+		if keyRing != nil {
+			authenticate = middleware.ScopedKeyAuth(keyRing, "/api/v1", authenticate)
+			if len(cfg.Auth.APIKeys) > 0 {
+				log.WithField("keys", len(cfg.Auth.APIKeys)).Info("Chat-scoped API keys enabled")
+			}
+		}
 
-		// Maintenance operations
-		v1.POST("/dedup", apiHandler.Dedup)
-		v1.DELETE("/commands", apiHandler.CleanCommands)
+		// The web dashboard exchanges its credential for a session cookie once,
+		// so the key or token never has to be kept in browser storage
+		if cfg.Sessions.Enabled {
+			sessionStore = sessions.NewStore(sessions.Config{
+				TTL:        cfg.Sessions.TTL,
+				CookieName: cfg.Sessions.CookieName,
+				Secure:     cfg.Sessions.SecureCookie,
+			})
+			apiHandler.SetSessions(sessionStore)
+			authenticate = middleware.SessionAuth(sessionStore, authenticate)
+		}
+
+		// Large downloads can be fetched by wget or a browser through a
+		// time-limited signed URL instead of a URL embedding the key
+		if cfg.SignedURLs.Enabled {
+			urlSigner := signedurl.New(signedurl.Config{
+				Secret:     cfg.SignedURLs.Secret,
+				DefaultTTL: cfg.SignedURLs.DefaultTTL,
+				MaxTTL:     cfg.SignedURLs.MaxTTL,
+				Routes:     cfg.SignedURLs.Routes,
+			})
+			apiHandler.SetURLSigner(urlSigner, cfg.SignedURLs.BaseURL)
+			authenticate = middleware.SignedURLAuth(urlSigner, "/api/v1", authenticate)
+		}
+		v1.Use(authenticate)
+	}
+	if len(cfg.Routes.Operations) > 0 {
+		v1.Use(middleware.AllowOperations("/api/v1", cfg.Routes.Operations))
+	}
+	if tenantRegistry != nil {
+		v1.Use(middleware.TenantScope(tenantRegistry, "/api/v1"))
+	}
+
+	// Request rate limits per credential, per client address and overall;
+	// after authentication so requests are attributed to their key
+	var rateLimiter *middleware.RateLimiter
+	if cfg.RateLimit.Enabled {
+		rateLimiter = middleware.NewRateLimiter(middleware.RateLimiterConfig{
+			Global: middleware.RateLimit{Rate: cfg.RateLimit.Global.Rate, Burst: cfg.RateLimit.Global.Burst},
+			PerKey: middleware.RateLimit{Rate: cfg.RateLimit.PerKey.Rate, Burst: cfg.RateLimit.PerKey.Burst},
+			PerIP:  middleware.RateLimit{Rate: cfg.RateLimit.PerIP.Rate, Burst: cfg.RateLimit.PerIP.Burst},
+		})
+		v1.Use(rateLimiter.Middleware())
+	}
+
+	// Per-route handler deadlines; streaming and long-poll routes manage their own
+	searchTimeout := middleware.Timeout(cfg.Timeouts.Search)
+	ingestTimeout := middleware.Timeout(cfg.Timeouts.Ingest)
+	adminTimeout := middleware.Timeout(cfg.Timeouts.Admin)
+	defaultTimeout := middleware.Timeout(cfg.Timeouts.Default)
+	streaming := middleware.Deadline(0)
+
+	// Concurrency limits for expensive operations; excess requests queue briefly, then get 429
+	searchLimit := middleware.NewLimiter("search", cfg.Concurrency.Search, cfg.Concurrency.QueueTimeout).Middleware()
+	deleteLimit := middleware.NewLimiter("delete", cfg.Concurrency.DeleteByQuery, cfg.Concurrency.QueueTimeout).Middleware()
+
+	// Stats of published chats are noised for community dashboards and served
+	// without authentication, outside the protected group
+	if cfg.PublicStats.Enabled {
+		public := router.Group("/api/v1/public", rateLimiter.Middleware())
+		public.GET("/stats/chats/:chat_id/activity", searchLimit, searchTimeout, apiHandler.PublicChatActivity)
+		public.GET("/stats/chats/:chat_id/trending", searchLimit, searchTimeout, apiHandler.PublicTrendingTerms)
+		log.WithField("chats", len(cfg.PublicStats.Chats)).Info("Public stats enabled at /api/v1/public/stats")
+	}
+
+	// Dashboard login and logout; any authenticated caller may hold a session
+	if sessionStore != nil {
+		v1.POST("/auth/session", defaultTimeout, apiHandler.CreateSession)
+		v1.GET("/auth/session", defaultTimeout, apiHandler.GetSession)
+		v1.DELETE("/auth/session", defaultTimeout, apiHandler.DeleteSession)
+	}
+	if cfg.SignedURLs.Enabled {
+		v1.POST("/signed-urls", defaultTimeout, apiHandler.SignURL)
+	}
+
+	// Routes are split into read, write and admin groups that are served only
+	// when enabled; admin routes are off unless admin.enabled
+	if cfg.Routes.Read {
+		read := v1.Group("", middleware.RequireAuthMethod(middleware.RoleRead, cfg.Routes.Auth), middleware.RequireRole(middleware.RoleRead, cfg.Routes.Roles))
+
+		// Search and messages
+		read.POST("/search", searchLimit, searchTimeout, apiHandler.Search)
+		read.GET("/messages/:id", searchTimeout, apiHandler.GetMessage)
+		read.GET("/messages/:id/context", searchLimit, searchTimeout, apiHandler.MessageContext)
+		read.GET("/chats", searchLimit, searchTimeout, apiHandler.ListChats)
+		read.GET("/chats/:chat_id/top", searchLimit, searchTimeout, apiHandler.TopMessages)
+		read.GET("/trash", defaultTimeout, apiHandler.ListTrash)
+
+		// Capture rules (polled by capture clients)
+		read.GET("/capture/rules", defaultTimeout, apiHandler.GetCaptureRules)
+		read.GET("/capture/config", defaultTimeout, apiHandler.GetCaptureConfig)
+		read.GET("/capture/evaluate", defaultTimeout, apiHandler.EvaluateCaptureRules)
+
+		// Profiles, subscriptions and jobs
+		read.GET("/profile", defaultTimeout, apiHandler.GetProfile)
+		read.GET("/subscriptions", defaultTimeout, apiHandler.ListSubscriptions)
+		read.GET("/subscriptions/events", streaming, apiHandler.PollSubscriptionEvents)
+		read.GET("/jobs", defaultTimeout, apiHandler.ListJobs)
+		read.GET("/jobs/:id", defaultTimeout, apiHandler.GetJob)
 
 		// Health and stats
-		v1.GET("/ping", apiHandler.Ping)
-		v1.GET("/stats", apiHandler.Stats)
-		v1.GET("/status", apiHandler.Status)
-		v1.GET("/health/system", apiHandler.SystemInfo)
-		v1.POST("/stats/user", apiHandler.UserStats)
+		read.GET("/ping", defaultTimeout, apiHandler.Ping)
+		read.GET("/stats", defaultTimeout, apiHandler.Stats)
+		read.GET("/status", defaultTimeout, apiHandler.Status)
+		read.GET("/health/system", defaultTimeout, apiHandler.SystemInfo)
+		read.POST("/stats/user", searchLimit, searchTimeout, apiHandler.UserStats)
+
+		// Saved Messages
+		read.POST("/saved/:user_id/search", searchLimit, searchTimeout, apiHandler.SearchSaved)
+		read.GET("/saved/:user_id/stats", searchLimit, searchTimeout, apiHandler.SavedStats)
+		read.GET("/saved/:user_id/digest", searchLimit, searchTimeout, apiHandler.SavedDigest)
 	}
 
-	// Create HTTP/2 handler with h2c (HTTP/2 Cleartext) support
-	// This allows HTTP/2 over plain HTTP connections without TLS
-	h2s := &http2.Server{}
-	h2cHandler := h2c.NewHandler(router, h2s)
+	if cfg.Routes.Write {
+		write := v1.Group("", middleware.RequireAuthMethod(middleware.RoleWrite, cfg.Routes.Auth), middleware.RequireRole(middleware.RoleWrite, cfg.Routes.Roles))
 
-	// Create HTTP server with HTTP/2 support
-	srv := &http.Server{
-		Addr:         fmt.Sprintf("%s:%d", cfg.Server.Host, cfg.Server.Port),
-		Handler:      h2cHandler,
-		ReadTimeout:  cfg.Server.ReadTimeout,
-		WriteTimeout: cfg.Server.WriteTimeout,
+		// Ingest and Telegram-side changes
+		write.POST("/upsert", ingestTimeout, apiHandler.Upsert)
+		write.POST("/upsert/batch", ingestTimeout, apiHandler.UpsertBatch)
+		write.POST("/import/telegram-export", ingestTimeout, apiHandler.ImportTelegramExport)
+		write.POST("/messages/soft-delete", ingestTimeout, apiHandler.SoftDeleteMessage)
+		write.PATCH("/messages/:id", ingestTimeout, apiHandler.UpdateMessage)
+		write.DELETE("/messages/:id", adminTimeout, apiHandler.DeleteMessage)
+
+		// Caller-owned settings
+		write.PUT("/profile", defaultTimeout, apiHandler.PutProfile)
+		write.DELETE("/profile", defaultTimeout, apiHandler.DeleteProfile)
+		write.POST("/subscriptions", defaultTimeout, apiHandler.CreateSubscription)
+		write.DELETE("/subscriptions/:id", defaultTimeout, apiHandler.DeleteSubscription)
+		write.PUT("/saved/:user_id/messages/:message_id/tags", defaultTimeout, apiHandler.PutSavedTags)
 	}
 
-	// Start server in goroutine
-	go func() {
-		log.WithFields(log.Fields{
-			"host":     cfg.Server.Host,
-			"port":     cfg.Server.Port,
-			"engine":   cfg.SearchEngine.Type,
-			"http2":    true,
-		}).Info("Starting SearchGram Search Engine with HTTP/2 support")
+	if cfg.Admin.Enabled {
+		admin := v1.Group("", middleware.RequireAuthMethod(middleware.RoleAdmin, cfg.Routes.Auth), middleware.RequireRole(middleware.RoleAdmin, cfg.Routes.Roles))
 
-		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			log.WithError(err).Fatal("Failed to start server")
+		// Service tokens, e.g. for an ingestion worker without the private key
+		if cfg.Auth.TokenEndpoint && jwtAuth != nil {
+			apiHandler.SetTokenMinter(jwtAuth, cfg.Auth.AllowedIssuers, time.Duration(cfg.Auth.MaxTokenTTL)*time.Second)
+			admin.POST("/auth/token", defaultTimeout, apiHandler.MintToken)
 		}
-	}()
+		admin.POST("/auth/keys/reload", defaultTimeout, apiHandler.ReloadAPIKeys)
+
+		// Bulk deletes and restores
+		admin.DELETE("/messages", deleteLimit, adminTimeout, apiHandler.DeleteMessages)
+		admin.POST("/messages/delete-by-query", deleteLimit, adminTimeout, apiHandler.DeleteByQuery)
+		admin.POST("/messages/deleted/restore", adminTimeout, apiHandler.RestoreDeleted)
+		admin.POST("/messages/deleted/purge", deleteLimit, adminTimeout, apiHandler.PurgeDeleted)
+		admin.DELETE("/users/:user_id", deleteLimit, adminTimeout, apiHandler.DeleteUser)
+		admin.GET("/users/:user_id/export", streaming, apiHandler.ExportUser)
+		admin.DELETE("/clear", adminTimeout, apiHandler.Clear)
+		admin.POST("/trash/batches/:batch/restore", adminTimeout, apiHandler.RestoreTrashBatch)
+		admin.POST("/trash/messages/:id/restore", adminTimeout, apiHandler.RestoreTrashMessage)
+
+		// Maintenance operations
+		admin.POST("/dedup", adminTimeout, apiHandler.Dedup)
+		admin.POST("/admin/reindex", adminTimeout, apiHandler.Reindex)
+		admin.GET("/index/versions", defaultTimeout, apiHandler.ListIndexVersions)
+		admin.POST("/index/rollback", adminTimeout, apiHandler.RollbackIndex)
+		admin.DELETE("/commands", adminTimeout, apiHandler.CleanCommands)
+		admin.POST("/backfill", adminTimeout, apiHandler.Backfill)
+		admin.DELETE("/jobs/:id", adminTimeout, apiHandler.CancelJob)
+
+		// Capture rule changes
+		admin.PUT("/capture/rules", adminTimeout, apiHandler.ReplaceCaptureRules)
+		admin.POST("/capture/rules", adminTimeout, apiHandler.AddCaptureRule)
+		admin.DELETE("/capture/rules/:id", adminTimeout, apiHandler.DeleteCaptureRule)
+
+		// Retention policies
+		admin.GET("/retention", defaultTimeout, apiHandler.ListRetention)
+		admin.PUT("/retention/chats/:chat_id", defaultTimeout, apiHandler.PutRetention)
+		admin.DELETE("/retention/chats/:chat_id", defaultTimeout, apiHandler.DeleteRetention)
+		admin.POST("/retention/purge", adminTimeout, apiHandler.PurgeRetention)
+
+		// Scheduled maintenance
+		admin.GET("/schedule", defaultTimeout, apiHandler.ListSchedule)
+
+		// Replication to the secondary engine
+		admin.GET("/replication", defaultTimeout, apiHandler.ReplicationStatus)
+		admin.POST("/replication/resync", adminTimeout, apiHandler.ResyncReplication)
+
+		// Lifecycle event delivery
+		admin.GET("/events", defaultTimeout, apiHandler.EventsStatus)
+		admin.POST("/events/dead-letters/redeliver", defaultTimeout, apiHandler.RedeliverEvents)
+
+		// Configuration created via the API, for moving to another host
+		admin.GET("/state", defaultTimeout, apiHandler.ExportState)
+		admin.PUT("/state", defaultTimeout, apiHandler.ImportState)
+
+		// Backups, e.g. before upgrades; progress is reported by /jobs
+		admin.POST("/backup", adminTimeout, apiHandler.Backup)
+		admin.POST("/restore", adminTimeout, apiHandler.Restore)
+
+		// Searching snapshots as they were taken, e.g. after a purge
+		admin.GET("/snapshots", defaultTimeout, apiHandler.ListSnapshots)
+		admin.POST("/snapshots/:name/mount", adminTimeout, apiHandler.MountSnapshot)
+		admin.DELETE("/snapshots/:name/mount", defaultTimeout, apiHandler.UnmountSnapshot)
+		admin.POST("/search/snapshot", searchLimit, searchTimeout, apiHandler.SearchSnapshot)
+
+		// Legal holds keeping chats from being deleted
+		admin.GET("/legal-holds", defaultTimeout, apiHandler.ListLegalHolds)
+		admin.PUT("/legal-holds/:chat_id", defaultTimeout, apiHandler.PlaceLegalHold)
+		admin.DELETE("/legal-holds/:chat_id", defaultTimeout, apiHandler.ReleaseLegalHold)
+
+		// Dictionary of domain terms
+		admin.GET("/dictionary", defaultTimeout, apiHandler.GetDictionary)
+		admin.POST("/dictionary/reload", defaultTimeout, apiHandler.ReloadDictionary)
+
+		// Tenants and their API keys
+		admin.GET("/tenants", defaultTimeout, apiHandler.ListTenants)
+		admin.POST("/tenants", adminTimeout, apiHandler.CreateTenant)
+		admin.GET("/tenants/:tenant", defaultTimeout, apiHandler.GetTenant)
+		admin.PATCH("/tenants/:tenant", defaultTimeout, apiHandler.UpdateTenant)
+		admin.DELETE("/tenants/:tenant", adminTimeout, apiHandler.DeleteTenant)
+
+		// Checking the audit trail for tampering
+		admin.GET("/audit/verify", adminTimeout, apiHandler.VerifyAudit)
+
+		// Data about all callers
+		admin.GET("/profiles", defaultTimeout, apiHandler.ListProfiles)
+		admin.GET("/usage", defaultTimeout, apiHandler.Usage)
+	} else {
+		log.Info("Admin routes (deletes, clear, maintenance) are disabled, set admin.enabled to serve them")
+	}
+
+	// Hand traffic to the router; start serving now unless already listening
+	gate.Ready(router)
+	if srv == nil {
+		srv = newServer(cfg, gate)
+		startServer(srv, cfg)
+	}
 
 	// Wait for interrupt signal to gracefully shutdown
 	quit := make(chan os.Signal, 1)
@@ -192,5 +1157,210 @@ func main() {
 		log.WithError(err).Error("Server forced to shutdown")
 	}
 
+	// Stop consuming before draining the queue
+	kafkaConsumer.Stop()
+	natsConsumer.Stop()
+	redisConsumer.Stop()
+	telegramClient.Stop()
+
+	// Index everything still buffered in the ingest queue
+	ingestQueue.Stop(ctx)
+
+	// Finish the media being read; queued messages stay without media text
+	mediaWorker.Stop(ctx)
+
+	// Start no more scheduled tasks
+	taskScheduler.Stop()
+
+	// Stop background jobs; unfinished ones are recorded as interrupted
+	jobManager.Shutdown(ctx)
+
+	// Stop pushing subscription events
+	subscriptionManager.StopWebhook()
+
+	// Flush the final usage period
+	usageRecorder.Stop()
+
+	// Stop purging the recycle bin and expired messages
+	recycleBin.Stop()
+	retentionManager.Stop()
+
+	// Replicate what the shutdown left queued, then stop
+	replicator.Stop(ctx)
+
+	// Deliver the events the shutdown left queued; the rest is dead-lettered
+	eventBus.Stop(ctx)
+
+	// Upload what is spooled; the rest waits for the next start
+	archiver.Stop(ctx)
+
+	// Last, so the spans of the shutdown itself are exported too
+	tracer.Stop(ctx)
+
 	log.Info("Server exited")
 }
+
+// newElasticsearch connects to a message index on the configured cluster,
+// with embeddings, reranking, the dictionary, keyword expansion, text
+// encryption and tracing as configured
+func newElasticsearch(cfg *config.Config, index string, dict *dictionary.Dictionary) (*engines.ElasticsearchEngine, error) {
+	var options []elastic.ClientOptionFunc
+	if cfg.Tracing.Enabled {
+		options = append(options, elastic.SetHttpClient(tracing.HTTPClient()))
+	}
+	es, err := engines.NewElasticsearch(
+		cfg.Elasticsearch.Host,
+		cfg.Elasticsearch.Username,
+		cfg.Elasticsearch.Password,
+		index,
+		cfg.Elasticsearch.Shards,
+		cfg.Elasticsearch.Replicas,
+		engines.PartitionConfig{
+			Period:    cfg.Elasticsearch.Partition,
+			ILMPolicy: cfg.Elasticsearch.ILMPolicy,
+		},
+		engines.AnalysisConfig{
+			ChineseFolding: cfg.Elasticsearch.ChineseFolding,
+		},
+		options...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Embeddings.Enabled {
+		if err := es.EnableEmbeddings(cfg.Embeddings.Dimensions); err != nil {
+			es.Close()
+			return nil, err
+		}
+	}
+	if cfg.Rerank.Enabled {
+		es.SetReranker(enrich.NewReranker(enrich.RerankerConfig{
+			URL:     cfg.Rerank.URL,
+			APIKey:  cfg.Rerank.APIKey,
+			Model:   cfg.Rerank.Model,
+			Timeout: cfg.Rerank.Timeout,
+		}))
+	}
+	if dict != nil {
+		es.SetDictionary(dict)
+	}
+	expander := translit.New(translit.Config{
+		Layouts:         cfg.Expansion.KeyboardLayouts,
+		Transliteration: cfg.Expansion.Transliteration,
+	})
+	if expander.Enabled() {
+		es.SetKeywordExpander(expander)
+	}
+	if err := enableEncryption(cfg, es); err != nil {
+		es.Close()
+		return nil, err
+	}
+	return es, nil
+}
+
+// enableEncryption makes es encrypt message text before indexing when
+// configured
+func enableEncryption(cfg *config.Config, es *engines.ElasticsearchEngine) error {
+	if !cfg.Encryption.Enabled {
+		return nil
+	}
+	sealer, err := fieldcrypt.New(cfg.Encryption.Sealer())
+	if err != nil {
+		return fmt.Errorf("failed to set up encryption: %w", err)
+	}
+	es.SetTextSealer(sealer)
+	return nil
+}
+
+// newBucket creates a client for a configured S3-compatible bucket
+func newBucket(cfg config.S3Config) (*s3.Client, error) {
+	return s3.New(s3.Config{
+		Endpoint:  cfg.Endpoint,
+		Region:    cfg.Region,
+		Bucket:    cfg.Bucket,
+		AccessKey: cfg.AccessKey,
+		SecretKey: cfg.SecretKey,
+		PathStyle: cfg.PathStyle,
+		Timeout:   cfg.Timeout,
+	})
+}
+
+// stateBackend creates the configured storage for the engine's own state
+func stateBackend(cfg *config.Config) (storage.Backend, error) {
+	if cfg.Storage.Backend == storage.BackendElasticsearch {
+		return storage.NewElasticsearch(cfg.Elasticsearch.Host, cfg.Elasticsearch.Username, cfg.Elasticsearch.Password, cfg.Storage.Index)
+	}
+	return storage.NewDir(cfg.Storage.DataDir), nil
+}
+
+// eventSink creates the configured sink for lifecycle events
+func eventSink(cfg config.EventsConfig) (events.Sink, error) {
+	switch cfg.Sink {
+	case events.SinkNATS:
+		return events.NewNATS(cfg.NATS.URL, cfg.NATS.Subject)
+	case events.SinkKafka:
+		return events.NewKafka(cfg.Kafka.Brokers, cfg.Kafka.Topic), nil
+	default:
+		return &events.Webhook{
+			URL:     cfg.Webhook.URL,
+			Secret:  cfg.Webhook.Secret,
+			Timeout: cfg.Webhook.Timeout,
+		}, nil
+	}
+}
+
+// newServer creates the HTTP server. With server.tls it serves HTTPS,
+// negotiating HTTP/2 via ALPN; otherwise it supports HTTP/2 cleartext (h2c),
+// which allows HTTP/2 over plain HTTP connections for local deployments.
+func newServer(cfg *config.Config, handler http.Handler) *http.Server {
+	h2s := &http2.Server{IdleTimeout: cfg.Server.IdleTimeout}
+
+	// Read/write timeouts are defaults; routes with a deadline class replace
+	// them and streaming routes clear them (see middleware.Deadline)
+	srv := &http.Server{
+		Addr:              fmt.Sprintf("%s:%d", cfg.Server.Host, cfg.Server.Port),
+		Handler:           h2c.NewHandler(handler, h2s),
+		ReadTimeout:       cfg.Server.ReadTimeout,
+		WriteTimeout:      cfg.Server.WriteTimeout,
+		ReadHeaderTimeout: cfg.Server.ReadHeaderTimeout,
+		IdleTimeout:       cfg.Server.IdleTimeout,
+	}
+
+	if cfg.Server.TLS.Enabled {
+		tlsConfig, err := serverTLSConfig(cfg.Server.TLS)
+		if err != nil {
+			log.WithError(err).Fatal("Failed to configure server TLS")
+		}
+		srv.Handler = handler
+		srv.TLSConfig = tlsConfig
+		if err := http2.ConfigureServer(srv, h2s); err != nil {
+			log.WithError(err).Fatal("Failed to configure HTTP/2")
+		}
+	}
+	return srv
+}
+
+// startServer starts serving in a goroutine
+func startServer(srv *http.Server, cfg *config.Config) {
+	go func() {
+		log.WithFields(log.Fields{
+			"host":   cfg.Server.Host,
+			"port":   cfg.Server.Port,
+			"engine": cfg.SearchEngine.Type,
+			"http2":  true,
+			"tls":    cfg.Server.TLS.Enabled,
+			"mtls":   cfg.Server.TLS.Enabled && cfg.Server.TLS.RequireClientCert,
+		}).Info("Starting SearchGram Search Engine with HTTP/2 support")
+
+		var err error
+		if srv.TLSConfig != nil {
+			// The certificate is already loaded into TLSConfig
+			err = srv.ListenAndServeTLS("", "")
+		} else {
+			err = srv.ListenAndServe()
+		}
+		if err != nil && err != http.ErrServerClosed {
+			log.WithError(err).Fatal("Failed to start server")
+		}
+	}()
+}
@@ -0,0 +1,88 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+	"github.com/zhishengyuan/searchgram-engine/i18n"
+)
+
+const (
+	// APIKeyNameKey holds the name of the scoped API key a request carries
+	APIKeyNameKey = "api_key_name"
+
+	// ChatScopeKey holds the chat IDs ([]int64) a request is limited to
+	ChatScopeKey = "chat_scope"
+)
+
+// ScopedKey is an API key limited to the messages of some chats
+type ScopedKey struct {
+	Name    string
+	Hash    string // Hex SHA-256 of the key
+	ChatIDs []int64
+	Role    string // Credential role, further limiting the routes it may call
+}
+
+// scopedOperations are the routes whose requests can be limited to chats.
+// Everything else is refused to scoped keys.
+var scopedOperations = []operation{
+	{method: http.MethodPost, path: "/search"},
+	{method: http.MethodGet, path: "/messages/:id"},
+	{method: http.MethodGet, path: "/messages/:id/context"},
+	{method: http.MethodGet, path: "/chats"},
+	{method: http.MethodGet, path: "/chats/:chat_id/top"},
+	{method: http.MethodGet, path: "/ping"},
+	{method: http.MethodDelete, path: "/messages"},
+	{method: http.MethodDelete, path: "/messages/:id"},
+	{method: http.MethodPost, path: "/messages/delete-by-query"},
+	{method: http.MethodPost, path: "/messages/soft-delete"},
+}
+
+// ScopedKeyAuth accepts the scoped API keys of keys in place of the API key
+// or JWT checked by next. Requests carrying one may only use the routes that
+// can be limited to its chats, matched by their template below prefix; the
+// handlers limit them. Other credentials go through next unchanged.
+func ScopedKeyAuth(keys *KeyRing, prefix string, next gin.HandlerFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		provided := c.GetHeader("X-API-Key")
+		if provided == "" {
+			provided = strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+		}
+
+		key, ok := keys.MatchScoped(provided)
+		if !ok {
+			next(c)
+			return
+		}
+
+		route := strings.TrimPrefix(c.FullPath(), prefix)
+		allowed := false
+		for _, op := range scopedOperations {
+			if op.matches(c.Request.Method, route) {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			Log(c).WithFields(log.Fields{
+				"api_key": key.Name,
+				"route":   route,
+				"method":  c.Request.Method,
+			}).Warn("Operation not available to chat-scoped API key")
+
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+				"error":   "Forbidden",
+				"message": i18n.Tc(c, "API key %s may not call %s %s", key.Name, c.Request.Method, route),
+			})
+			return
+		}
+
+		c.Set(APIKeyNameKey, key.Name)
+		c.Set(ChatScopeKey, key.ChatIDs)
+		c.Set(CredentialRoleKey, key.Role)
+		c.Set(AuthMethodKey, AuthMethodAPIKey)
+		c.Next()
+	}
+}
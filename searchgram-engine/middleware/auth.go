@@ -1,15 +1,20 @@
 package middleware
 
 import (
+	"io"
+	"math/rand"
 	"net/http"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	log "github.com/sirupsen/logrus"
+	"github.com/zhishengyuan/searchgram-engine/i18n"
 )
 
-// APIKeyAuth middleware validates API key if authentication is enabled
-func APIKeyAuth(enabled bool, apiKey string) gin.HandlerFunc {
+// APIKeyAuth middleware validates API keys if authentication is enabled.
+// Requests carrying one of keys get its credential role and count as API
+// key callers.
+func APIKeyAuth(enabled bool, keys *KeyRing) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		if !enabled {
 			c.Next()
@@ -26,8 +31,9 @@ func APIKeyAuth(enabled bool, apiKey string) gin.HandlerFunc {
 		}
 
 		// Validate API key
-		if providedKey != apiKey {
-			log.WithFields(log.Fields{
+		key, ok := keys.Match(providedKey)
+		if !ok {
+			Log(c).WithFields(log.Fields{
 				"ip":     c.ClientIP(),
 				"path":   c.Request.URL.Path,
 				"method": c.Request.Method,
@@ -35,47 +41,97 @@ func APIKeyAuth(enabled bool, apiKey string) gin.HandlerFunc {
 
 			c.JSON(http.StatusUnauthorized, gin.H{
 				"error":   "Unauthorized",
-				"message": "Invalid or missing API key",
+				"message": i18n.Tc(c, "Invalid or missing API key"),
 			})
 			c.Abort()
 			return
 		}
 
+		if key.Name != "" {
+			c.Set(APIKeyNameKey, key.Name)
+		}
+		c.Set(CredentialRoleKey, key.Role)
+		c.Set(AuthMethodKey, AuthMethodAPIKey)
 		c.Next()
 	}
 }
 
-// RequestLogger logs all incoming requests
-func RequestLogger() gin.HandlerFunc {
+// RequestLoggerConfig selects which requests are logged
+type RequestLoggerConfig struct {
+	SlowThreshold time.Duration // Requests taking longer are logged as warnings; 0 disables
+	SampleRate    float64       // Share of successful, fast requests logged (0-1)
+}
+
+// RequestLogger logs requests with their latency, sizes and route. Errors
+// and slow requests are always logged, the rest at the sample rate. Register
+// it before the middleware that rewrite responses, so the logged response
+// size is what the caller received.
+func RequestLogger(cfg RequestLoggerConfig) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		// Start timer
 		start := time.Now()
+		body := &countingReader{ReadCloser: c.Request.Body}
+		if c.Request.Body != nil {
+			c.Request.Body = body
+		}
 
-		// Process request
 		c.Next()
 
-		// Calculate latency
 		latency := time.Since(start)
+		status := c.Writer.Status()
+		slow := cfg.SlowThreshold > 0 && latency >= cfg.SlowThreshold
+		if status < http.StatusBadRequest && !slow && rand.Float64() >= cfg.SampleRate {
+			return
+		}
+
+		// Chunked bodies have no length up front; count what was read
+		requestBytes := c.Request.ContentLength
+		if requestBytes < 0 {
+			requestBytes = body.n
+		}
+		responseBytes := c.Writer.Size()
+		if responseBytes < 0 {
+			responseBytes = 0
+		}
 
-		// Log request
-		log.WithFields(log.Fields{
-			"status":     c.Writer.Status(),
-			"method":     c.Request.Method,
-			"path":       c.Request.URL.Path,
-			"ip":         c.ClientIP(),
-			"latency_ms": latency.Milliseconds(),
-			"user_agent": c.Request.UserAgent(),
-		}).Info("HTTP request")
+		entry := Log(c).WithFields(log.Fields{
+			"status":         status,
+			"method":         c.Request.Method,
+			"path":           c.Request.URL.Path,
+			"route":          c.FullPath(),
+			"ip":             c.ClientIP(),
+			"latency_ms":     float64(latency.Microseconds()) / 1000,
+			"request_bytes":  requestBytes,
+			"response_bytes": responseBytes,
+			"user_agent":     c.Request.UserAgent(),
+		})
+		if slow {
+			entry.Warn("Slow HTTP request")
+			return
+		}
+		entry.Info("HTTP request")
 	}
 }
 
+// countingReader counts the bytes read from a request body
+type countingReader struct {
+	io.ReadCloser
+	n int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	r.n += int64(n)
+	return n, err
+}
+
 // CORS middleware enables Cross-Origin Resource Sharing
 func CORS() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
 		c.Writer.Header().Set("Access-Control-Allow-Credentials", "true")
-		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, X-API-Key, accept, origin, Cache-Control, X-Requested-With")
-		c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS, GET, PUT, DELETE")
+		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, X-API-Key, accept, origin, Cache-Control, X-Requested-With, Accept-Language, X-Field-Case, X-Request-ID, traceparent, tracestate")
+		c.Writer.Header().Set("Access-Control-Expose-Headers", "X-Request-ID")
+		c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS, GET, PUT, PATCH, DELETE")
 
 		if c.Request.Method == "OPTIONS" {
 			c.AbortWithStatus(http.StatusNoContent)
@@ -89,7 +145,7 @@ func CORS() gin.HandlerFunc {
 // Recovery middleware recovers from panics
 func Recovery() gin.HandlerFunc {
 	return gin.CustomRecovery(func(c *gin.Context, recovered interface{}) {
-		log.WithFields(log.Fields{
+		Log(c).WithFields(log.Fields{
 			"error":  recovered,
 			"path":   c.Request.URL.Path,
 			"method": c.Request.Method,
@@ -97,7 +153,7 @@ func Recovery() gin.HandlerFunc {
 
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error":   "Internal Server Error",
-			"message": "An unexpected error occurred",
+			"message": i18n.Tc(c, "An unexpected error occurred"),
 		})
 	})
 }
@@ -0,0 +1,84 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+	"github.com/zhishengyuan/searchgram-engine/i18n"
+)
+
+// Kinds of credentials a route group can require
+const (
+	AuthMethodJWT    = "jwt"     // Signed tokens, e.g. for the ingestion service
+	AuthMethodAPIKey = "api_key" // The shared, chat-scoped or tenant API keys, e.g. for the bot
+)
+
+// AuthMethodKey holds the kind of credential a request authenticated with;
+// JWT callers are recognized by their claims instead
+const AuthMethodKey = "auth_method"
+
+// ValidAuthMethod reports whether method is a kind of credential
+func ValidAuthMethod(method string) bool {
+	return method == AuthMethodJWT || method == AuthMethodAPIKey
+}
+
+// AuthMethod returns the kind of credential a request authenticated with,
+// or "" when authentication is disabled. Dashboard sessions and signed URLs
+// count as the credential that created them.
+func AuthMethod(c *gin.Context) string {
+	if method := c.GetString(AuthMethodKey); method != "" {
+		return method
+	}
+	if _, ok := c.Get("jwt_claims"); ok {
+		return AuthMethodJWT
+	}
+	return ""
+}
+
+// APIKeyOrJWT accepts both the API keys checked by apiKeyAuth and the JWTs
+// checked by jwtAuth, so e.g. the bot can keep its key while the ingestion
+// service moves to tokens. Requests sending X-API-Key or one of keys as
+// bearer token go to apiKeyAuth, everything else to jwtAuth.
+func APIKeyOrJWT(keys *KeyRing, apiKeyAuth, jwtAuth gin.HandlerFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		_, isKey := keys.Match(strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer "))
+		if c.GetHeader("X-API-Key") != "" || isKey {
+			apiKeyAuth(c)
+			return
+		}
+		jwtAuth(c)
+	}
+}
+
+// RequireAuthMethod restricts a route group to the kinds of credentials
+// listed for it in methods (group -> kinds); others get 403. Groups without
+// an entry accept every configured credential.
+func RequireAuthMethod(group string, methods map[string][]string) gin.HandlerFunc {
+	allowed := make(map[string]bool)
+	for _, method := range methods[group] {
+		allowed[method] = true
+	}
+
+	return func(c *gin.Context) {
+		method := AuthMethod(c)
+		if len(allowed) == 0 || allowed[method] {
+			c.Next()
+			return
+		}
+
+		Log(c).WithFields(log.Fields{
+			"auth_method": method,
+			"role":        group,
+			"path":        c.Request.URL.Path,
+			"method":      c.Request.Method,
+		}).Warn("Credential kind not accepted for route group")
+
+		c.JSON(http.StatusForbidden, gin.H{
+			"error":   "Forbidden",
+			"message": i18n.Tc(c, "%s routes require %s credentials", group, strings.Join(methods[group], " or ")),
+		})
+		c.Abort()
+	}
+}
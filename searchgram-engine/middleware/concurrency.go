@@ -0,0 +1,95 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+	"github.com/zhishengyuan/searchgram-engine/i18n"
+)
+
+// Limiter bounds how many requests of one operation class run at once, so a
+// burst of expensive requests cannot exhaust the Elasticsearch thread pools.
+// A nil Limiter allows everything.
+type Limiter struct {
+	name  string
+	slots chan struct{}
+	wait  time.Duration
+}
+
+// NewLimiter creates a limiter allowing max concurrent requests. Excess
+// requests queue for up to wait before being rejected with 429. A max of 0
+// disables the limit and returns nil.
+func NewLimiter(name string, max int, wait time.Duration) *Limiter {
+	if max <= 0 {
+		return nil
+	}
+	return &Limiter{
+		name:  name,
+		slots: make(chan struct{}, max),
+		wait:  wait,
+	}
+}
+
+// Middleware enforces the limit on a route
+func (l *Limiter) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if l == nil {
+			c.Next()
+			return
+		}
+
+		if !l.acquire(c) {
+			Log(c).WithFields(log.Fields{
+				"limit":  l.name,
+				"max":    cap(l.slots),
+				"path":   c.Request.URL.Path,
+				"method": c.Request.Method,
+			}).Warn("Concurrency limit reached, rejecting request")
+
+			retryAfter := int(l.wait.Seconds())
+			if retryAfter < 1 {
+				retryAfter = 1
+			}
+			c.Header("Retry-After", strconv.Itoa(retryAfter))
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{
+				"error":   "Too Many Requests",
+				"message": i18n.Tc(c, "Too many concurrent %s requests, retry later", l.name),
+			})
+			return
+		}
+		defer l.release()
+
+		c.Next()
+	}
+}
+
+// acquire takes a slot, waiting up to l.wait or until the client goes away
+func (l *Limiter) acquire(c *gin.Context) bool {
+	select {
+	case l.slots <- struct{}{}:
+		return true
+	default:
+	}
+	if l.wait <= 0 {
+		return false
+	}
+
+	timer := time.NewTimer(l.wait)
+	defer timer.Stop()
+
+	select {
+	case l.slots <- struct{}{}:
+		return true
+	case <-timer.C:
+		return false
+	case <-c.Request.Context().Done():
+		return false
+	}
+}
+
+func (l *Limiter) release() {
+	<-l.slots
+}
@@ -0,0 +1,39 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// deadlineGrace is added to route deadlines so a 504 can still be written
+// after the handler deadline expires
+const deadlineGrace = 5 * time.Second
+
+// Deadline replaces the server-wide read/write timeouts for a route. Long-lived
+// streaming routes use a zero duration, which removes the connection deadlines
+// entirely; they are then bounded by their own logic and client disconnects.
+func Deadline(d time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		setConnDeadlines(c, d)
+		c.Next()
+	}
+}
+
+// setConnDeadlines moves the connection read and write deadlines to d from now
+// (zero clears them)
+func setConnDeadlines(c *gin.Context, d time.Duration) {
+	var deadline time.Time
+	if d > 0 {
+		deadline = time.Now().Add(d)
+	}
+
+	rc := http.NewResponseController(c.Writer)
+	for _, set := range []func(time.Time) error{rc.SetReadDeadline, rc.SetWriteDeadline} {
+		if err := set(deadline); err != nil && !errors.Is(err, http.ErrNotSupported) {
+			Log(c).WithError(err).WithField("path", c.Request.URL.Path).Debug("Failed to adjust connection deadline")
+		}
+	}
+}
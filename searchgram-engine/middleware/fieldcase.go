@@ -0,0 +1,186 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Response field naming styles
+const (
+	SnakeCase = "snake"
+	CamelCase = "camel"
+)
+
+// fieldCaseHeader lets a request pick the response field naming style
+const fieldCaseHeader = "X-Field-Case"
+
+// opaqueFields hold client-supplied JSON whose keys are never renamed
+var opaqueFields = map[string]bool{
+	"raw_message": true,
+}
+
+// FieldNamingConfig selects how JSON response fields are named. Models keep
+// their snake_case struct tags; renaming happens on the encoded response.
+type FieldNamingConfig struct {
+	DefaultCase string            // snake (default) or camel
+	IssuerCase  map[string]string // Per JWT issuer (API key) style, keyed by lowercased issuer
+	Aliases     map[string]string // snake_case field name -> emitted name, overriding the style
+}
+
+// FieldNaming rewrites JSON response field names for clients written against
+// other SearchGram forks. The style comes from the field_case query parameter,
+// the X-Field-Case header, the caller's JWT issuer, or the default, in that
+// order. Snake case without aliases passes responses through untouched.
+func FieldNaming(cfg FieldNamingConfig) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		w := &fieldCaseWriter{ResponseWriter: c.Writer, ctx: c, cfg: cfg}
+		c.Writer = w
+		c.Next()
+		c.Writer = w.ResponseWriter
+
+		if w.convert {
+			w.flushConverted()
+		}
+	}
+}
+
+// fieldCaseWriter buffers JSON responses that need renaming. The decision is
+// made on the first write, once authentication has identified the caller.
+type fieldCaseWriter struct {
+	gin.ResponseWriter
+	ctx     *gin.Context
+	cfg     FieldNamingConfig
+	decided bool
+	convert bool
+	style   string
+	buf     bytes.Buffer
+}
+
+func (w *fieldCaseWriter) Write(data []byte) (int, error) {
+	w.decide()
+	if w.convert {
+		return w.buf.Write(data)
+	}
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *fieldCaseWriter) WriteString(s string) (int, error) {
+	w.decide()
+	if w.convert {
+		return w.buf.WriteString(s)
+	}
+	return w.ResponseWriter.WriteString(s)
+}
+
+// Flush is deferred until the converted body is complete
+func (w *fieldCaseWriter) Flush() {
+	if !w.convert {
+		w.ResponseWriter.Flush()
+	}
+}
+
+// decide picks the naming style for this response
+func (w *fieldCaseWriter) decide() {
+	if w.decided {
+		return
+	}
+	w.decided = true
+
+	if !strings.HasPrefix(w.Header().Get("Content-Type"), "application/json") {
+		return
+	}
+	w.style = w.requestedStyle()
+	w.convert = w.style == CamelCase || len(w.cfg.Aliases) > 0
+}
+
+// requestedStyle resolves the style for the current request
+func (w *fieldCaseWriter) requestedStyle() string {
+	for _, style := range []string{w.ctx.Query("field_case"), w.ctx.GetHeader(fieldCaseHeader)} {
+		if style == SnakeCase || style == CamelCase {
+			return style
+		}
+	}
+	if issuer := w.ctx.GetString("jwt_issuer"); issuer != "" {
+		if style, ok := w.cfg.IssuerCase[strings.ToLower(issuer)]; ok {
+			return style
+		}
+	}
+	return w.cfg.DefaultCase
+}
+
+// flushConverted renames the buffered body's fields and writes it out.
+// Bodies that are not valid JSON are written unchanged.
+func (w *fieldCaseWriter) flushConverted() {
+	body := w.buf.Bytes()
+
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	var value interface{}
+	if err := dec.Decode(&value); err == nil {
+		if converted, err := json.Marshal(w.rename(value)); err == nil {
+			body = converted
+		} else {
+			Log(w.ctx).WithError(err).Warn("Failed to re-encode response with renamed fields")
+		}
+	}
+
+	w.Header().Del("Content-Length")
+	w.ResponseWriter.Write(body)
+}
+
+// rename applies aliases and the naming style to every object key
+func (w *fieldCaseWriter) rename(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for key, field := range v {
+			if !opaqueFields[key] {
+				field = w.rename(field)
+			}
+			out[w.fieldName(key)] = field
+		}
+		return out
+	case []interface{}:
+		for i := range v {
+			v[i] = w.rename(v[i])
+		}
+		return v
+	}
+	return value
+}
+
+// fieldName returns the emitted name for a snake_case field
+func (w *fieldCaseWriter) fieldName(key string) string {
+	if alias, ok := w.cfg.Aliases[key]; ok {
+		return alias
+	}
+	if w.style == CamelCase {
+		return snakeToCamel(key)
+	}
+	return key
+}
+
+// snakeToCamel converts "total_hits" to "totalHits"
+func snakeToCamel(s string) string {
+	if !strings.Contains(s, "_") {
+		return s
+	}
+
+	var b strings.Builder
+	upper := false
+	for i, r := range s {
+		switch {
+		case r == '_' && i > 0:
+			upper = true
+		case upper:
+			b.WriteString(strings.ToUpper(string(r)))
+			upper = false
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
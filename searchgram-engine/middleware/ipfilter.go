@@ -0,0 +1,106 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"net/netip"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+	"github.com/zhishengyuan/searchgram-engine/i18n"
+)
+
+// IPFilter admits requests by client address: addresses in a deny prefix
+// are refused, and with allow prefixes only addresses in one of them are
+// admitted. The address is gin's ClientIP, so it honors forwarded headers
+// only from trusted proxies.
+type IPFilter struct {
+	allow []netip.Prefix
+	deny  []netip.Prefix
+}
+
+// NewIPFilter parses allow and deny lists of CIDR prefixes or single
+// addresses
+func NewIPFilter(allow, deny []string) (*IPFilter, error) {
+	f := &IPFilter{}
+	var err error
+	if f.allow, err = ParsePrefixes(allow); err != nil {
+		return nil, fmt.Errorf("invalid allow entry: %w", err)
+	}
+	if f.deny, err = ParsePrefixes(deny); err != nil {
+		return nil, fmt.Errorf("invalid deny entry: %w", err)
+	}
+	return f, nil
+}
+
+// ParsePrefixes parses CIDR prefixes; a single address is a prefix of
+// its full length
+func ParsePrefixes(entries []string) ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(entries))
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if strings.Contains(entry, "/") {
+			prefix, err := netip.ParsePrefix(entry)
+			if err != nil {
+				return nil, err
+			}
+			prefixes = append(prefixes, prefix.Masked())
+			continue
+		}
+		addr, err := netip.ParseAddr(entry)
+		if err != nil {
+			return nil, err
+		}
+		prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+	}
+	return prefixes, nil
+}
+
+// Allowed reports whether a client address may make requests. Addresses
+// that do not parse are only allowed without an allow list.
+func (f *IPFilter) Allowed(ip string) bool {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return len(f.allow) == 0
+	}
+	// IPv4 clients of a dual-stack listener appear as ::ffff:a.b.c.d
+	addr = addr.Unmap()
+
+	if contains(f.deny, addr) {
+		return false
+	}
+	return len(f.allow) == 0 || contains(f.allow, addr)
+}
+
+// contains reports whether any prefix holds addr
+func contains(prefixes []netip.Prefix, addr netip.Addr) bool {
+	for _, prefix := range prefixes {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// Middleware refuses requests from addresses that are not allowed with 403
+func (f *IPFilter) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ip := c.ClientIP()
+		if f.Allowed(ip) {
+			c.Next()
+			return
+		}
+
+		Log(c).WithFields(log.Fields{
+			"ip":     ip,
+			"path":   c.Request.URL.Path,
+			"method": c.Request.Method,
+		}).Warn("Request from address outside the IP filter")
+
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+			"error":   "Forbidden",
+			"message": i18n.Tc(c, "Requests from %s are not allowed", ip),
+		})
+	}
+}
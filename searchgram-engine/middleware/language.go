@@ -0,0 +1,17 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/zhishengyuan/searchgram-engine/i18n"
+)
+
+// Localize picks the response language from Accept-Language, falling back to
+// defaultLanguage, and announces it in Content-Language
+func Localize(defaultLanguage string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		lang := i18n.Negotiate(c.GetHeader("Accept-Language"), defaultLanguage)
+		i18n.SetLanguage(c, lang)
+		c.Header("Content-Language", lang)
+		c.Next()
+	}
+}
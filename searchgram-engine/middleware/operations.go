@@ -0,0 +1,104 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+	"github.com/zhishengyuan/searchgram-engine/i18n"
+)
+
+// operation is one allowlist entry: a route template relative to the API
+// prefix, optionally limited to one method
+type operation struct {
+	method string // Empty matches any method
+	path   string
+}
+
+// ParseOperation parses an allowlist entry such as "POST /upsert",
+// "/messages/:id" or "GET /export/*". A trailing "/*" matches the path and
+// everything below it.
+func ParseOperation(entry string) (method, path string, ok bool) {
+	fields := strings.Fields(entry)
+	switch len(fields) {
+	case 1:
+		path = fields[0]
+	case 2:
+		method, path = strings.ToUpper(fields[0]), fields[1]
+		switch method {
+		case http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		default:
+			return "", "", false
+		}
+	default:
+		return "", "", false
+	}
+	if !strings.HasPrefix(path, "/") {
+		return "", "", false
+	}
+	return method, path, true
+}
+
+// matches reports whether the operation covers a matched route
+func (o operation) matches(method, route string) bool {
+	if o.method != "" && o.method != method {
+		return false
+	}
+	if prefix, wildcard := strings.CutSuffix(o.path, "/*"); wildcard {
+		return route == prefix || strings.HasPrefix(route, prefix+"/")
+	}
+	return route == o.path
+}
+
+// AllowOperations restricts the credentials listed in operations (JWT
+// issuer or scoped API key name, matched case-insensitively -> allowlist
+// entries) to the routes on their allowlist, so a credential can be locked
+// down to e.g. write-only ingest. Routes are matched by their template below
+// prefix ("/messages/:id"). Credentials without an entry, and callers using
+// the shared API key, are only subject to RequireRole. Invalid entries are
+// rejected by config validation and ignored here.
+func AllowOperations(prefix string, operations map[string][]string) gin.HandlerFunc {
+	allowlists := make(map[string][]operation, len(operations))
+	for credential, entries := range operations {
+		list := make([]operation, 0, len(entries))
+		for _, entry := range entries {
+			if method, path, ok := ParseOperation(entry); ok {
+				list = append(list, operation{method: method, path: path})
+			}
+		}
+		allowlists[strings.ToLower(credential)] = list
+	}
+
+	return func(c *gin.Context) {
+		credential, field := c.GetString("jwt_issuer"), "issuer"
+		if credential == "" {
+			credential, field = c.GetString(APIKeyNameKey), "api_key_name"
+		}
+		allowlist, restricted := allowlists[strings.ToLower(credential)]
+		if credential == "" || !restricted {
+			c.Next()
+			return
+		}
+
+		route := strings.TrimPrefix(c.FullPath(), prefix)
+		for _, op := range allowlist {
+			if op.matches(c.Request.Method, route) {
+				c.Next()
+				return
+			}
+		}
+
+		Log(c).WithFields(log.Fields{
+			field:    credential,
+			"route":  route,
+			"method": c.Request.Method,
+		}).Warn("Operation not on credential allowlist")
+
+		c.JSON(http.StatusForbidden, gin.H{
+			"error":   "Forbidden",
+			"message": i18n.Tc(c, "%s may not call %s %s", credential, c.Request.Method, route),
+		})
+		c.Abort()
+	}
+}
@@ -0,0 +1,240 @@
+package middleware
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+	"github.com/zhishengyuan/searchgram-engine/i18n"
+	"github.com/zhishengyuan/searchgram-engine/jwt"
+	"github.com/zhishengyuan/searchgram-engine/tenants"
+)
+
+// rateLimitSweep is how often buckets that have refilled are dropped, so
+// one-off callers do not accumulate
+const rateLimitSweep = time.Minute
+
+// RateLimit configures a token bucket: Rate requests per second on
+// average, Burst at once. A zero Rate disables the bucket.
+type RateLimit struct {
+	Rate  float64
+	Burst int
+}
+
+// RateLimiterConfig selects the buckets a request draws from
+type RateLimiterConfig struct {
+	Global RateLimit // Shared by all requests
+	PerKey RateLimit // One per credential (API key, JWT subject or issuer, tenant)
+	PerIP  RateLimit // One per client address
+}
+
+// RateLimiter rejects requests with 429 once a bucket they draw from is
+// empty, so a misbehaving client cannot flood Elasticsearch. Responses
+// carry the RateLimit-Limit, RateLimit-Remaining and RateLimit-Reset
+// headers of the bucket closest to running out. A nil RateLimiter allows
+// everything.
+type RateLimiter struct {
+	global rateLimit
+	perKey rateLimit
+	perIP  rateLimit
+
+	mu        sync.Mutex
+	buckets   map[string]*bucket // "global", "key:<caller>" or "ip:<address>"
+	lastSweep time.Time
+}
+
+// rateLimit is a RateLimit with the burst defaulted
+type rateLimit struct {
+	rate  float64
+	burst float64
+}
+
+// bucket holds the tokens of one bucket as of updated
+type bucket struct {
+	limit   rateLimit
+	tokens  float64
+	updated time.Time
+}
+
+// NewRateLimiter creates a rate limiter; it returns nil when every bucket
+// is disabled
+func NewRateLimiter(cfg RateLimiterConfig) *RateLimiter {
+	r := &RateLimiter{
+		global:    newRateLimit(cfg.Global),
+		perKey:    newRateLimit(cfg.PerKey),
+		perIP:     newRateLimit(cfg.PerIP),
+		buckets:   make(map[string]*bucket),
+		lastSweep: time.Now(),
+	}
+	if r.global.rate == 0 && r.perKey.rate == 0 && r.perIP.rate == 0 {
+		return nil
+	}
+	return r
+}
+
+// newRateLimit defaults the burst of a bucket to its rate per second
+func newRateLimit(limit RateLimit) rateLimit {
+	if limit.Rate <= 0 {
+		return rateLimit{}
+	}
+	burst := float64(limit.Burst)
+	if burst < 1 {
+		burst = math.Max(1, math.Ceil(limit.Rate))
+	}
+	return rateLimit{rate: limit.Rate, burst: burst}
+}
+
+// Middleware enforces the limits; place it after authentication so
+// requests are attributed to their credential
+func (r *RateLimiter) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if r == nil {
+			c.Next()
+			return
+		}
+
+		keys := make(map[string]rateLimit, 3)
+		if r.global.rate > 0 {
+			keys["global"] = r.global
+		}
+		if r.perKey.rate > 0 {
+			if caller := rateLimitCaller(c); caller != "" {
+				keys["key:"+caller] = r.perKey
+			}
+		}
+		if r.perIP.rate > 0 {
+			keys["ip:"+c.ClientIP()] = r.perIP
+		}
+
+		allowed, state := r.take(keys, time.Now())
+		c.Header("RateLimit-Limit", strconv.Itoa(int(state.limit.burst)))
+		c.Header("RateLimit-Remaining", strconv.Itoa(int(state.tokens)))
+		c.Header("RateLimit-Reset", strconv.Itoa(seconds(state.untilFull())))
+		if !allowed {
+			retryAfter := seconds(state.untilToken())
+			Log(c).WithFields(log.Fields{
+				"bucket": state.key,
+				"path":   c.Request.URL.Path,
+				"method": c.Request.Method,
+			}).Warn("Rate limit exceeded, rejecting request")
+
+			c.Header("Retry-After", strconv.Itoa(retryAfter))
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{
+				"error":   "Too Many Requests",
+				"message": i18n.Tc(c, "Rate limit exceeded, retry in %d seconds", retryAfter),
+			})
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// bucketState is a bucket as seen by one request
+type bucketState struct {
+	key    string
+	limit  rateLimit
+	tokens float64
+}
+
+// untilFull returns how long the bucket takes to refill completely
+func (s bucketState) untilFull() time.Duration {
+	return time.Duration((s.limit.burst - s.tokens) / s.limit.rate * float64(time.Second))
+}
+
+// untilToken returns how long until the bucket holds a whole token
+func (s bucketState) untilToken() time.Duration {
+	return time.Duration((1 - s.tokens) / s.limit.rate * float64(time.Second))
+}
+
+// take draws a token from every bucket in keys when all hold one, and
+// otherwise from none. It returns the state of the emptiest bucket, the
+// one that refused the request if any.
+func (r *RateLimiter) take(keys map[string]rateLimit, now time.Time) (bool, bucketState) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if now.Sub(r.lastSweep) >= rateLimitSweep {
+		r.sweep(now)
+	}
+
+	buckets := make(map[string]*bucket, len(keys))
+	var tightest bucketState
+	for key, limit := range keys {
+		b, ok := r.buckets[key]
+		if !ok || b.limit != limit {
+			b = &bucket{limit: limit, tokens: limit.burst, updated: now}
+			r.buckets[key] = b
+		}
+		b.refill(now)
+		buckets[key] = b
+
+		if tightest.key == "" || b.tokens < tightest.tokens {
+			tightest = bucketState{key: key, limit: limit, tokens: b.tokens}
+		}
+	}
+	if tightest.key == "" {
+		return true, tightest
+	}
+	if tightest.tokens < 1 {
+		return false, tightest
+	}
+
+	for _, b := range buckets {
+		b.tokens--
+	}
+	tightest.tokens--
+	return true, tightest
+}
+
+// refill adds the tokens accrued since the last update
+func (b *bucket) refill(now time.Time) {
+	elapsed := now.Sub(b.updated).Seconds()
+	if elapsed > 0 {
+		b.tokens = math.Min(b.limit.burst, b.tokens+elapsed*b.limit.rate)
+		b.updated = now
+	}
+}
+
+// sweep drops buckets that have refilled completely (caller holds mu)
+func (r *RateLimiter) sweep(now time.Time) {
+	for key, b := range r.buckets {
+		b.refill(now)
+		if b.tokens >= b.limit.burst {
+			delete(r.buckets, key)
+		}
+	}
+	r.lastSweep = now
+}
+
+// rateLimitCaller identifies the credential of a request, or "" when it
+// carries none
+func rateLimitCaller(c *gin.Context) string {
+	if value, ok := c.Get("jwt_claims"); ok {
+		if claims, ok := value.(*jwt.Claims); ok && claims.Subject != "" {
+			return "jwt:" + claims.Subject
+		}
+	}
+	if tenant := c.GetString(tenants.ContextKey); tenant != "" {
+		return "tenant:" + tenant
+	}
+	if issuer := c.GetString("jwt_issuer"); issuer != "" {
+		return "jwt:" + issuer
+	}
+	if name := c.GetString(APIKeyNameKey); name != "" {
+		return "api_key:" + name
+	}
+	if AuthMethod(c) == AuthMethodAPIKey {
+		return "api_key"
+	}
+	return ""
+}
+
+// seconds rounds a duration up to whole seconds
+func seconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
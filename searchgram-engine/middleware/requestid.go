@@ -0,0 +1,139 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	log "github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// RequestIDHeader carries the ID of a request in and out
+const RequestIDHeader = "X-Request-ID"
+
+// RequestIDKey holds the ID of the current request
+const RequestIDKey = "request_id"
+
+// maxRequestIDLength bounds IDs taken from callers
+const maxRequestIDLength = 128
+
+// RequestID gives every request an ID: the caller's X-Request-ID when it is
+// a plausible one, a new UUID otherwise. The ID is echoed in the
+// X-Request-ID response header, added as request_id to JSON error bodies
+// and to the log lines of Log(c), so a caller reporting a failure can be
+// matched with the engine's logs.
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(RequestIDHeader)
+		if !validRequestID(id) {
+			id = uuid.NewString()
+		}
+		c.Set(RequestIDKey, id)
+		c.Header(RequestIDHeader, id)
+		trace.SpanFromContext(c.Request.Context()).SetAttributes(attribute.String("request.id", id))
+
+		w := &requestIDWriter{ResponseWriter: c.Writer, id: id}
+		c.Writer = w
+		c.Next()
+		c.Writer = w.ResponseWriter
+
+		if w.inject {
+			w.flushWithID()
+		}
+	}
+}
+
+// validRequestID accepts IDs of printable ASCII other than quotes and
+// backslashes, which are safe to log and to embed in JSON as they are
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] <= ' ' || id[i] > '~' || id[i] == '"' || id[i] == '\\' {
+			return false
+		}
+	}
+	return true
+}
+
+// Log returns a log entry carrying the ID of the request, for the log lines
+// of handlers and middleware
+func Log(c *gin.Context) *log.Entry {
+	if id := c.GetString(RequestIDKey); id != "" {
+		return log.WithField(RequestIDKey, id)
+	}
+	return log.NewEntry(log.StandardLogger())
+}
+
+// requestIDWriter buffers JSON error responses to add the request ID. The
+// decision is made on the first write, once the status is known; other
+// responses, streams included, pass through.
+type requestIDWriter struct {
+	gin.ResponseWriter
+	id      string
+	decided bool
+	inject  bool
+	buf     bytes.Buffer
+}
+
+func (w *requestIDWriter) Write(data []byte) (int, error) {
+	w.decide()
+	if w.inject {
+		return w.buf.Write(data)
+	}
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *requestIDWriter) WriteString(s string) (int, error) {
+	w.decide()
+	if w.inject {
+		return w.buf.WriteString(s)
+	}
+	return w.ResponseWriter.WriteString(s)
+}
+
+// Flush is deferred until the error body is complete
+func (w *requestIDWriter) Flush() {
+	if !w.inject {
+		w.ResponseWriter.Flush()
+	}
+}
+
+// decide buffers the response if it is a JSON error
+func (w *requestIDWriter) decide() {
+	if w.decided {
+		return
+	}
+	w.decided = true
+	w.inject = w.Status() >= http.StatusBadRequest &&
+		strings.HasPrefix(w.Header().Get("Content-Type"), "application/json")
+}
+
+// flushWithID adds request_id to the buffered body and writes it out.
+// Bodies that are not JSON objects, or already carry the ID, are written
+// unchanged.
+func (w *requestIDWriter) flushWithID() {
+	body := bytes.TrimSpace(w.buf.Bytes())
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(body, &fields); err == nil && fields != nil {
+		if _, ok := fields[RequestIDKey]; !ok {
+			// Append the field rather than re-encode, to keep the order of
+			// the others
+			field := `"` + RequestIDKey + `":"` + w.id + `"}`
+			if len(fields) > 0 {
+				field = "," + field
+			}
+			body = append(body[:len(body)-1:len(body)-1], field...)
+		}
+	}
+
+	w.Header().Del("Content-Length")
+	w.ResponseWriter.Write(body)
+}
@@ -0,0 +1,121 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+	"github.com/zhishengyuan/searchgram-engine/i18n"
+	"github.com/zhishengyuan/searchgram-engine/jwt"
+)
+
+// Route groups a caller can be granted
+const (
+	RoleRead  = "read"  // Search, fetch, listings and stats
+	RoleWrite = "write" // Ingest, edits and caller-owned settings
+	RoleAdmin = "admin" // Bulk deletes, clear, restores and maintenance
+)
+
+// Roles a credential can carry, each granting the route groups of the ones
+// before it
+const (
+	CredentialReader = "reader" // Read routes only
+	CredentialWriter = "writer" // Read and write routes
+	CredentialAdmin  = "admin"  // Every route group
+)
+
+// CredentialRoleKey holds the role of the API key, session or signed URL a
+// request carries; JWT callers get theirs from the role claim
+const CredentialRoleKey = "credential_role"
+
+// credentialGroups are the route groups each credential role grants
+var credentialGroups = map[string][]string{
+	CredentialReader: {RoleRead},
+	CredentialWriter: {RoleRead, RoleWrite},
+	CredentialAdmin:  {RoleRead, RoleWrite, RoleAdmin},
+}
+
+// ValidCredentialRole reports whether role is a credential role
+func ValidCredentialRole(role string) bool {
+	_, ok := credentialGroups[role]
+	return ok
+}
+
+// CredentialRole returns the role of the credential a request carries, or
+// "" for credentials without one, which are not restricted by role
+func CredentialRole(c *gin.Context) string {
+	if role := c.GetString(CredentialRoleKey); role != "" {
+		return role
+	}
+	if value, ok := c.Get("jwt_claims"); ok {
+		if claims, ok := value.(*jwt.Claims); ok {
+			return claims.Role
+		}
+	}
+	return ""
+}
+
+// grants reports whether a credential role grants a route group. Unknown
+// roles grant nothing.
+func grants(credential, group string) bool {
+	for _, granted := range credentialGroups[credential] {
+		if granted == group {
+			return true
+		}
+	}
+	return false
+}
+
+// RequireRole restricts a route group to the credentials whose role grants
+// it and to the JWT issuers granted it in roles (lowercased issuer ->
+// groups); others get 403. Credentials without a role are only subject to
+// the matrix. An empty matrix grants every group to every issuer; callers
+// using an API key carry no issuer and are not restricted by it.
+func RequireRole(role string, roles map[string][]string) gin.HandlerFunc {
+	granted := make(map[string]bool, len(roles))
+	for issuer, groups := range roles {
+		for _, group := range groups {
+			if group == role {
+				granted[strings.ToLower(issuer)] = true
+			}
+		}
+	}
+
+	return func(c *gin.Context) {
+		if credential := CredentialRole(c); credential != "" && !grants(credential, role) {
+			Log(c).WithFields(log.Fields{
+				"credential_role": credential,
+				"role":            role,
+				"path":            c.Request.URL.Path,
+				"method":          c.Request.Method,
+			}).Warn("Route group not granted to credential role")
+
+			c.JSON(http.StatusForbidden, gin.H{
+				"error":   "Forbidden",
+				"message": i18n.Tc(c, "Credentials with role %s may not use %s routes", credential, role),
+			})
+			c.Abort()
+			return
+		}
+
+		issuer := c.GetString("jwt_issuer")
+		if len(roles) == 0 || issuer == "" || granted[strings.ToLower(issuer)] {
+			c.Next()
+			return
+		}
+
+		Log(c).WithFields(log.Fields{
+			"issuer": issuer,
+			"role":   role,
+			"path":   c.Request.URL.Path,
+			"method": c.Request.Method,
+		}).Warn("Route group not granted to issuer")
+
+		c.JSON(http.StatusForbidden, gin.H{
+			"error":   "Forbidden",
+			"message": i18n.Tc(c, "Issuer %s may not use %s routes", issuer, role),
+		})
+		c.Abort()
+	}
+}
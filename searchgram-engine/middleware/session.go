@@ -0,0 +1,58 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+	"github.com/zhishengyuan/searchgram-engine/i18n"
+	"github.com/zhishengyuan/searchgram-engine/sessions"
+)
+
+// SessionAuth accepts a dashboard session cookie in place of the API key or
+// JWT checked by next. Requests that carry credentials headers, or no live
+// session, go through next unchanged. Cookie-authenticated requests get the
+// login's issuer, role and claims, and unsafe methods must echo the session's CSRF
+// token in X-CSRF-Token, since browsers attach the cookie on their own.
+func SessionAuth(store *sessions.Store, next gin.HandlerFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetHeader("Authorization") != "" || c.GetHeader("X-API-Key") != "" {
+			next(c)
+			return
+		}
+
+		session, ok := store.FromRequest(c)
+		if !ok {
+			next(c)
+			return
+		}
+
+		switch c.Request.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+		default:
+			if !session.ValidCSRF(c.GetHeader("X-CSRF-Token")) {
+				Log(c).WithFields(log.Fields{
+					"ip":     c.ClientIP(),
+					"path":   c.Request.URL.Path,
+					"method": c.Request.Method,
+				}).Warn("Rejected session request without CSRF token")
+
+				c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+					"error":   "Forbidden",
+					"message": i18n.Tc(c, "Missing or invalid CSRF token"),
+				})
+				return
+			}
+		}
+
+		c.Set(sessions.ContextKey, session)
+		c.Set(CredentialRoleKey, session.Role)
+		if session.Claims != nil {
+			c.Set("jwt_claims", session.Claims)
+			c.Set("jwt_issuer", session.Issuer)
+		} else {
+			c.Set(AuthMethodKey, AuthMethodAPIKey)
+		}
+		c.Next()
+	}
+}
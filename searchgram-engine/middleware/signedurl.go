@@ -0,0 +1,61 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+	"github.com/zhishengyuan/searchgram-engine/i18n"
+	"github.com/zhishengyuan/searchgram-engine/signedurl"
+)
+
+// SignedURLAuth accepts a signed URL in place of the API key or JWT checked
+// by next, so downloads can be handed to wget or a browser without the
+// credential. Only GET requests to signable routes below prefix qualify;
+// anything else, and requests without a signature, go through next. A
+// valid URL runs with the issuer and credential role that signed it.
+func SignedURLAuth(signer *signedurl.Signer, prefix string, next gin.HandlerFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		query := c.Request.URL.Query()
+		if !query.Has(signedurl.ParamSignature) {
+			next(c)
+			return
+		}
+
+		path := c.Request.URL.Path
+		route, ok := strings.CutPrefix(path, prefix)
+		if c.Request.Method != http.MethodGet || !ok || !signer.Signable(route) {
+			next(c)
+			return
+		}
+
+		issuer, role, err := signer.Verify(path, query)
+		if err != nil {
+			Log(c).WithError(err).WithFields(log.Fields{
+				"ip":   c.ClientIP(),
+				"path": path,
+			}).Warn("Rejected signed URL")
+
+			message := i18n.Tc(c, "Invalid signed URL")
+			if errors.Is(err, signedurl.ErrExpired) {
+				message = i18n.Tc(c, "Signed URL has expired")
+			}
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+				"error":   "Unauthorized",
+				"message": message,
+			})
+			return
+		}
+
+		if issuer != "" {
+			c.Set("jwt_issuer", issuer)
+			c.Set(AuthMethodKey, AuthMethodJWT)
+		} else {
+			c.Set(AuthMethodKey, AuthMethodAPIKey)
+		}
+		c.Set(CredentialRoleKey, role)
+		c.Next()
+	}
+}
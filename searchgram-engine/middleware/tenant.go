@@ -0,0 +1,119 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+	"github.com/zhishengyuan/searchgram-engine/i18n"
+	"github.com/zhishengyuan/searchgram-engine/jwt"
+	"github.com/zhishengyuan/searchgram-engine/tenants"
+)
+
+// tenantOperations are the routes that act on the tenant's own index.
+// Everything else works on the shared index or instance-wide state and is
+// refused to tenants.
+var tenantOperations = []operation{
+	{method: http.MethodPost, path: "/search"},
+	{method: http.MethodGet, path: "/messages/:id"},
+	{method: http.MethodGet, path: "/messages/:id/context"},
+	{method: http.MethodGet, path: "/chats"},
+	{method: http.MethodGet, path: "/chats/:chat_id/top"},
+	{method: http.MethodGet, path: "/ping"},
+	{method: http.MethodGet, path: "/stats"},
+	{method: http.MethodPost, path: "/stats/user"},
+	{method: http.MethodPost, path: "/upsert"},
+	{method: http.MethodPost, path: "/upsert/batch"},
+	{method: http.MethodPost, path: "/messages/soft-delete"},
+	{method: http.MethodPatch, path: "/messages/:id"},
+	{method: http.MethodDelete, path: "/messages/:id"},
+	{path: "/profile"},
+}
+
+// TenantKeyAuth accepts the API key of a tenant in place of the API key or
+// JWT checked by next, and marks the request as acting for that tenant.
+// Other credentials go through next unchanged.
+func TenantKeyAuth(registry *tenants.Registry, next gin.HandlerFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader("X-API-Key")
+		if key == "" {
+			key = strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+		}
+
+		tenant, ok := registry.Authenticate(key)
+		if !ok {
+			next(c)
+			return
+		}
+		c.Set(tenants.ContextKey, tenant)
+		c.Set(AuthMethodKey, AuthMethodAPIKey)
+		c.Next()
+	}
+}
+
+// TenantScope routes the requests of tenants to their own index: callers
+// using a tenant API key, and JWT callers whose token carries a tenant
+// claim. Tenants may only use the routes acting on their index, matched by
+// their template below prefix; unknown tenants get 403. Other callers use
+// the shared index as before.
+func TenantScope(registry *tenants.Registry, prefix string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		tenant := c.GetString(tenants.ContextKey)
+		if tenant == "" {
+			if value, ok := c.Get("jwt_claims"); ok {
+				if claims, ok := value.(*jwt.Claims); ok {
+					tenant = claims.Tenant
+				}
+			}
+		}
+		if tenant == "" {
+			c.Next()
+			return
+		}
+
+		route := strings.TrimPrefix(c.FullPath(), prefix)
+		allowed := false
+		for _, op := range tenantOperations {
+			if op.matches(c.Request.Method, route) {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			Log(c).WithFields(log.Fields{
+				"tenant": tenant,
+				"route":  route,
+				"method": c.Request.Method,
+			}).Warn("Operation not available to tenants")
+
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+				"error":   "Forbidden",
+				"message": i18n.Tc(c, "Tenants may not call %s %s", c.Request.Method, route),
+			})
+			return
+		}
+
+		engine, err := registry.Engine(tenant)
+		if err == tenants.ErrNotFound {
+			Log(c).WithField("tenant", tenant).Warn("Request for unknown tenant")
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+				"error":   "Forbidden",
+				"message": i18n.Tc(c, "Unknown tenant %s", tenant),
+			})
+			return
+		}
+		if err != nil {
+			Log(c).WithError(err).WithField("tenant", tenant).Error("Failed to connect to tenant index")
+			c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{
+				"error":   "Service Unavailable",
+				"message": i18n.Tc(c, "Search engine is not available"),
+			})
+			return
+		}
+
+		c.Set(tenants.ContextKey, tenant)
+		c.Set(tenants.EngineKey, engine)
+		c.Next()
+	}
+}
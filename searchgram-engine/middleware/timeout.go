@@ -0,0 +1,183 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+	"github.com/zhishengyuan/searchgram-engine/i18n"
+)
+
+// Timeout enforces a handler deadline. The request context is cancelled at
+// the deadline and, if the handler has not finished, the client receives a
+// 504 with a structured body while anything the handler writes afterwards is
+// discarded. The connection deadlines are moved past the route deadline so
+// the server-wide write timeout cannot cut the handler or its 504 short. The
+// response is buffered, so streaming routes must not use it; a zero timeout
+// disables the middleware.
+func Timeout(timeout time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if timeout <= 0 {
+			c.Next()
+			return
+		}
+
+		setConnDeadlines(c, timeout+deadlineGrace)
+
+		ctx, cancel := context.WithTimeout(c.Request.Context(), timeout)
+		defer cancel()
+		c.Request = c.Request.WithContext(ctx)
+
+		original := c.Writer
+		tw := &timeoutWriter{
+			ResponseWriter: original,
+			header:         original.Header().Clone(),
+		}
+		c.Writer = tw
+		defer func() { c.Writer = original }()
+
+		done := make(chan struct{})
+		panicked := make(chan interface{}, 1)
+		go func() {
+			defer func() {
+				if p := recover(); p != nil {
+					panicked <- p
+				}
+				close(done)
+			}()
+			c.Next()
+		}()
+
+		select {
+		case <-done:
+			select {
+			case p := <-panicked:
+				// Re-raise on the request goroutine so Recovery handles it
+				c.Writer = original
+				panic(p)
+			default:
+			}
+			tw.commit()
+
+		case <-ctx.Done():
+			tw.expire()
+
+			Log(c).WithFields(log.Fields{
+				"path":       c.Request.URL.Path,
+				"method":     c.Request.Method,
+				"timeout_ms": timeout.Milliseconds(),
+			}).Warn("Handler exceeded route deadline")
+
+			body, _ := json.Marshal(gin.H{
+				"error":      "Gateway Timeout",
+				"message":    i18n.Tc(c, "Request exceeded the %s deadline for this route", timeout),
+				"timeout_ms": timeout.Milliseconds(),
+			})
+			original.Header().Set("Content-Type", "application/json; charset=utf-8")
+			original.WriteHeader(http.StatusGatewayTimeout)
+			original.Write(body)
+			original.Flush()
+
+			// Keep the gin context alive until the handler lets go of it
+			<-done
+			c.Abort()
+		}
+	}
+}
+
+// timeoutWriter buffers a response so it can be replaced by a 504
+type timeoutWriter struct {
+	gin.ResponseWriter
+
+	mu       sync.Mutex
+	header   http.Header
+	body     bytes.Buffer
+	status   int
+	timedOut bool
+}
+
+func (w *timeoutWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *timeoutWriter) WriteHeader(code int) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.timedOut || w.status != 0 {
+		return
+	}
+	w.status = code
+}
+
+func (w *timeoutWriter) WriteHeaderNow() {}
+
+func (w *timeoutWriter) Write(data []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.timedOut {
+		return 0, http.ErrHandlerTimeout
+	}
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.body.Write(data)
+}
+
+func (w *timeoutWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+func (w *timeoutWriter) Status() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.status == 0 {
+		return http.StatusOK
+	}
+	return w.status
+}
+
+func (w *timeoutWriter) Size() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.status == 0 {
+		return -1
+	}
+	return w.body.Len()
+}
+
+func (w *timeoutWriter) Written() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.status != 0
+}
+
+// Flush is a no-op; the response is sent when the handler finishes
+func (w *timeoutWriter) Flush() {}
+
+// expire discards all further writes
+func (w *timeoutWriter) expire() {
+	w.mu.Lock()
+	w.timedOut = true
+	w.mu.Unlock()
+}
+
+// commit sends the buffered response to the client
+func (w *timeoutWriter) commit() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	dst := w.ResponseWriter.Header()
+	for key, values := range w.header {
+		dst[key] = values
+	}
+	if w.status == 0 {
+		return
+	}
+	w.ResponseWriter.WriteHeader(w.status)
+	w.ResponseWriter.Write(w.body.Bytes())
+}
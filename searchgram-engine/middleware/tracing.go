@@ -0,0 +1,57 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracerName names the tracer of request spans
+const tracerName = "github.com/zhishengyuan/searchgram-engine/middleware"
+
+// Tracing records a server span per request, continuing the trace of a
+// caller that sends W3C traceparent headers, and makes it the parent of
+// the spans handlers and the engine record through the request context
+func Tracing() gin.HandlerFunc {
+	tracer := otel.Tracer(tracerName)
+	return func(c *gin.Context) {
+		ctx := otel.GetTextMapPropagator().Extract(c.Request.Context(), propagation.HeaderCarrier(c.Request.Header))
+
+		// Name spans by route, not path, so IDs in paths don't make every
+		// request a span name of its own
+		route := c.FullPath()
+		name := c.Request.Method + " " + route
+		if route == "" {
+			name = c.Request.Method
+		}
+		ctx, span := tracer.Start(ctx, name,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				semconv.HTTPRequestMethodKey.String(c.Request.Method),
+				semconv.HTTPRoute(route),
+				semconv.URLPath(c.Request.URL.Path),
+				semconv.ClientAddress(c.ClientIP()),
+				semconv.UserAgentOriginal(c.Request.UserAgent()),
+			),
+		)
+		defer span.End()
+
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+
+		status := c.Writer.Status()
+		span.SetAttributes(semconv.HTTPResponseStatusCode(status))
+		if status >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, fmt.Sprintf("HTTP %d", status))
+		}
+		if len(c.Errors) > 0 {
+			span.RecordError(c.Errors.Last())
+		}
+	}
+}
@@ -0,0 +1,13 @@
+package models
+
+// ArchiveStats describes archiving of indexed messages to object storage
+type ArchiveStats struct {
+	Bucket       string `json:"bucket"`
+	Prefix       string `json:"prefix"`
+	Pending      int64  `json:"pending"`                  // Messages spooled for the next upload
+	Archived     int64  `json:"archived"`                 // Messages uploaded since startup
+	Uploads      int64  `json:"uploads"`                  // Objects uploaded since startup
+	LastUploadAt int64  `json:"last_upload_at,omitempty"` // Unix time an object was last uploaded
+	LastObject   string `json:"last_object,omitempty"`    // Key of the last object uploaded
+	LastError    string `json:"last_error,omitempty"`     // Most recent failure, cleared on success
+}
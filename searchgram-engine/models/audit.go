@@ -0,0 +1,24 @@
+package models
+
+// AuditVerification reports a check of the audit trail's hash chain
+type AuditVerification struct {
+	Valid     bool   `json:"valid"`
+	Entries   int64  `json:"entries"`             // Chained entries checked
+	Unchained int64  `json:"unchained"`           // Entries written before hash chaining, not checked
+	LastSeq   int64  `json:"last_seq"`            // Seq of the last valid entry
+	LastHash  string `json:"last_hash,omitempty"` // Hash of the last valid entry
+	BrokenAt  int64  `json:"broken_at,omitempty"` // Seq of the first entry failing the check
+	Reason    string `json:"reason,omitempty"`    // Why the chain breaks there
+
+	Index *AuditIndexVerification `json:"index,omitempty"` // Check of the copy in the audit index
+}
+
+// AuditIndexVerification reports a check of the audit index against the
+// audit file
+type AuditIndexVerification struct {
+	Valid    bool   `json:"valid"`
+	Entries  int64  `json:"entries"`             // Entries in the index
+	Missing  int64  `json:"missing"`             // File entries not copied yet, e.g. while the index was unreachable
+	BrokenAt int64  `json:"broken_at,omitempty"` // Seq of the first entry differing from the file
+	Reason   string `json:"reason,omitempty"`
+}
@@ -0,0 +1,76 @@
+package models
+
+// BackupFormat identifies logical backups in their manifest
+const (
+	BackupFormat  = "searchgram-backup"
+	BackupVersion = 1
+)
+
+// BackupManifest describes a logical backup directory. Messages are split
+// into gzip-compressed NDJSON segments; chats and users are registries
+// derived from the messages, state holds the saved searches.
+type BackupManifest struct {
+	Format    string          `json:"format"`     // Always searchgram-backup
+	Version   int             `json:"version"`    // Layout version
+	CreatedAt int64           `json:"created_at"` // Unix time the backup started
+	Engine    string          `json:"engine"`     // Engine the backup was taken from, e.g. elasticsearch
+	Index     string          `json:"index"`      // Index the backup was taken from
+	Messages  int64           `json:"messages"`   // Messages in all segments, soft-deleted ones included
+	Segments  []BackupSegment `json:"segments"`
+	Chats     BackupSegment   `json:"chats"` // ChatSummary per line
+	Users     BackupSegment   `json:"users"` // UserSummary per line
+	State     []string        `json:"state"` // Files below state/, restored to storage.data_dir
+}
+
+// BackupSegment is one compressed NDJSON file of a backup
+type BackupSegment struct {
+	File   string `json:"file"`   // Path relative to the backup directory
+	Lines  int64  `json:"lines"`  // Records in the file
+	SHA256 string `json:"sha256"` // Checksum of the compressed file
+}
+
+// UserSummary describes a message sender as of their latest message
+type UserSummary struct {
+	UserID        int64  `json:"user_id"`
+	SenderName    string `json:"sender_name,omitempty"`
+	Username      string `json:"username,omitempty"`
+	MessageCount  int64  `json:"message_count"`   // Stored messages, excluding soft-deleted ones
+	LastMessageAt int64  `json:"last_message_at"` // Unix timestamp of the latest message
+}
+
+// RestoreResult reports a completed restore
+type RestoreResult struct {
+	RestoredCount int64    `json:"restored_count"`     // Messages indexed
+	FailedCount   int64    `json:"failed_count"`       // Messages the engine refused
+	Failures      []string `json:"failures,omitempty"` // Refusal reasons (at most 100)
+	State         []string `json:"state,omitempty"`    // State files restored
+	Snapshot      string   `json:"snapshot,omitempty"` // Snapshot restored, for snapshot backups
+}
+
+// Backup types
+const (
+	BackupTypeNDJSON   = "ndjson"   // Logical backup any engine can restore
+	BackupTypeSnapshot = "snapshot" // Engine-native snapshot
+)
+
+// BackupProgress is reported by running backup and restore jobs
+type BackupProgress struct {
+	Phase    string `json:"phase"`    // exporting, uploading, downloading, restoring or snapshotting
+	Messages int64  `json:"messages"` // Messages exported or restored so far
+	Files    int    `json:"files"`    // Files uploaded or downloaded so far
+}
+
+// BackupResult reports a completed backup
+type BackupResult struct {
+	Type     string          `json:"type"`               // ndjson or snapshot
+	Name     string          `json:"name"`               // Pass to POST /api/v1/restore
+	Location string          `json:"location"`           // Directory, s3:// URL or snapshot repository
+	Manifest *BackupManifest `json:"manifest,omitempty"` // ndjson backups
+	Snapshot *SnapshotResult `json:"snapshot,omitempty"` // snapshot backups
+}
+
+// RestoreRequest selects the backup to restore
+type RestoreRequest struct {
+	Name    string `json:"name" binding:"required"` // Name from the backup job's result
+	Replace bool   `json:"replace"`                 // Required when the index holds messages; they are deleted first
+}
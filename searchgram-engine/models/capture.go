@@ -0,0 +1,37 @@
+package models
+
+// CapturePolicy describes what gets indexed for a chat
+type CapturePolicy struct {
+	Capture       bool     `json:"capture"`                  // Whether messages are indexed at all
+	ContentTypes  []string `json:"content_types,omitempty"`  // Allowed content types (empty = all)
+	SampleRate    float64  `json:"sample_rate,omitempty"`    // Fraction of messages to index (0 or 1 = all)
+	RetentionDays int      `json:"retention_days,omitempty"` // Days to keep messages (0 = forever)
+}
+
+// CaptureRule applies a policy to chats matching its selectors.
+// When both selectors are set, a chat must match both.
+type CaptureRule struct {
+	ID          string        `json:"id"`
+	Description string        `json:"description,omitempty"`
+	ChatIDs     []int64       `json:"chat_ids,omitempty"`   // Chats this rule applies to
+	ChatTypes   []string      `json:"chat_types,omitempty"` // Chat types this rule applies to (PRIVATE, GROUP, ...)
+	Policy      CapturePolicy `json:"policy"`
+}
+
+// CaptureRuleSet is the full declarative capture configuration.
+// Rules are evaluated in order; the first match wins, otherwise Default applies.
+type CaptureRuleSet struct {
+	Version   int64         `json:"version"`    // Incremented on every change
+	UpdatedAt int64         `json:"updated_at"` // Unix timestamp of last change
+	Default   CapturePolicy `json:"default"`
+	Rules     []CaptureRule `json:"rules"`
+}
+
+// CaptureDecision is the resolved policy for a chat
+type CaptureDecision struct {
+	ChatID   int64         `json:"chat_id"`
+	ChatType string        `json:"chat_type,omitempty"`
+	RuleID   string        `json:"rule_id,omitempty"` // Empty when the default policy applies
+	Policy   CapturePolicy `json:"policy"`
+	Version  int64         `json:"version"`
+}
@@ -0,0 +1,216 @@
+package models
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// dateOnlyLayout is the YYYY-MM-DD form accepted by date filters
+const dateOnlyLayout = "2006-01-02"
+
+// DateBound is a date filter value. Clients may send a unix timestamp
+// (number or numeric string), an RFC3339 timestamp, a YYYY-MM-DD date or a
+// relative expression such as "now-7d"; the value must be resolved with
+// Resolve before Unix is meaningful.
+type DateBound struct {
+	raw      string
+	unix     int64
+	resolved bool
+}
+
+// NewDateBound creates an already resolved bound from a unix timestamp
+func NewDateBound(unix int64) *DateBound {
+	return &DateBound{unix: unix, resolved: true}
+}
+
+// UnmarshalJSON accepts a JSON number or string
+func (d *DateBound) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if len(data) > 0 && data[0] == '"' {
+		var s string
+		if err := json.Unmarshal(data, &s); err != nil {
+			return err
+		}
+		*d = DateBound{raw: strings.TrimSpace(s)}
+		return nil
+	}
+
+	var unix int64
+	if err := json.Unmarshal(data, &unix); err != nil {
+		return fmt.Errorf("date must be a unix timestamp, RFC3339 or YYYY-MM-DD: %s", data)
+	}
+	*d = DateBound{unix: unix, resolved: true}
+	return nil
+}
+
+// MarshalJSON encodes the resolved unix timestamp, or the raw input if unresolved
+func (d DateBound) MarshalJSON() ([]byte, error) {
+	if d.resolved {
+		return json.Marshal(d.unix)
+	}
+	return json.Marshal(d.raw)
+}
+
+// Unix returns the resolved bound in unix seconds
+func (d *DateBound) Unix() int64 {
+	return d.unix
+}
+
+// Resolve converts the raw input to unix seconds. Values without an explicit
+// offset are interpreted in loc. A plain date covers the whole day: it
+// resolves to local midnight, or to the last second of the day when endOfDay
+// is set, so "date_to": "2024-05-31" includes May 31st.
+func (d *DateBound) Resolve(loc *time.Location, endOfDay bool) error {
+	if d.resolved {
+		return nil
+	}
+	if loc == nil {
+		loc = time.UTC
+	}
+
+	if strings.HasPrefix(d.raw, "now") {
+		t, err := parseRelative(d.raw, time.Now().In(loc), endOfDay)
+		if err != nil {
+			return err
+		}
+		d.unix = t.Unix()
+		d.resolved = true
+		return nil
+	}
+
+	if unix, err := strconv.ParseInt(d.raw, 10, 64); err == nil {
+		d.unix = unix
+		d.resolved = true
+		return nil
+	}
+
+	if t, err := time.Parse(time.RFC3339, d.raw); err == nil {
+		d.unix = t.Unix()
+		d.resolved = true
+		return nil
+	}
+
+	// RFC3339 without offset, e.g. "2024-05-31T18:00:00"
+	if t, err := time.ParseInLocation("2006-01-02T15:04:05", d.raw, loc); err == nil {
+		d.unix = t.Unix()
+		d.resolved = true
+		return nil
+	}
+
+	if t, err := time.ParseInLocation(dateOnlyLayout, d.raw, loc); err == nil {
+		if endOfDay {
+			t = t.AddDate(0, 0, 1).Add(-time.Second)
+		}
+		d.unix = t.Unix()
+		d.resolved = true
+		return nil
+	}
+
+	return fmt.Errorf("invalid date %q: use a unix timestamp, RFC3339, YYYY-MM-DD or now-7d", d.raw)
+}
+
+// parseRelative evaluates expressions like "now", "now-7d", "now-1M+2h" or
+// "now-1d/d". Units are s, m, h, d, w, M (months) and y. A trailing "/unit"
+// rounds down to the start of that unit, or up to its last second when
+// roundUp is set, mirroring how plain dates cover a whole day.
+func parseRelative(expr string, now time.Time, roundUp bool) (time.Time, error) {
+	invalid := fmt.Errorf("invalid relative date %q: use e.g. now, now-7d, now-1M/d", expr)
+
+	t := now
+	rest := strings.TrimPrefix(expr, "now")
+	for rest != "" {
+		op := rest[0]
+		rest = rest[1:]
+
+		if op == '/' {
+			if len(rest) != 1 {
+				return time.Time{}, invalid
+			}
+			rounded, ok := roundDown(t, rest[0])
+			if !ok {
+				return time.Time{}, invalid
+			}
+			if roundUp {
+				next, _ := addUnit(rounded, 1, rest[0])
+				rounded = next.Add(-time.Second)
+			}
+			return rounded, nil
+		}
+		if op != '+' && op != '-' {
+			return time.Time{}, invalid
+		}
+
+		digits := 0
+		for digits < len(rest) && rest[digits] >= '0' && rest[digits] <= '9' {
+			digits++
+		}
+		if digits == 0 || digits == len(rest) {
+			return time.Time{}, invalid
+		}
+		n, err := strconv.Atoi(rest[:digits])
+		if err != nil {
+			return time.Time{}, invalid
+		}
+		if op == '-' {
+			n = -n
+		}
+
+		var ok bool
+		if t, ok = addUnit(t, n, rest[digits]); !ok {
+			return time.Time{}, invalid
+		}
+		rest = rest[digits+1:]
+	}
+
+	return t, nil
+}
+
+// addUnit adds n date-math units to t
+func addUnit(t time.Time, n int, unit byte) (time.Time, bool) {
+	switch unit {
+	case 's':
+		return t.Add(time.Duration(n) * time.Second), true
+	case 'm':
+		return t.Add(time.Duration(n) * time.Minute), true
+	case 'h':
+		return t.Add(time.Duration(n) * time.Hour), true
+	case 'd':
+		return t.AddDate(0, 0, n), true
+	case 'w':
+		return t.AddDate(0, 0, 7*n), true
+	case 'M':
+		return t.AddDate(0, n, 0), true
+	case 'y':
+		return t.AddDate(n, 0, 0), true
+	}
+	return t, false
+}
+
+// roundDown truncates t to the start of the unit in t's location (weeks start on Monday)
+func roundDown(t time.Time, unit byte) (time.Time, bool) {
+	y, mo, d := t.Date()
+	loc := t.Location()
+
+	switch unit {
+	case 's':
+		return t.Truncate(time.Second), true
+	case 'm':
+		return time.Date(y, mo, d, t.Hour(), t.Minute(), 0, 0, loc), true
+	case 'h':
+		return time.Date(y, mo, d, t.Hour(), 0, 0, 0, loc), true
+	case 'd':
+		return time.Date(y, mo, d, 0, 0, 0, 0, loc), true
+	case 'w':
+		offset := (int(t.Weekday()) + 6) % 7
+		return time.Date(y, mo, d-offset, 0, 0, 0, 0, loc), true
+	case 'M':
+		return time.Date(y, mo, 1, 0, 0, 0, 0, loc), true
+	case 'y':
+		return time.Date(y, 1, 1, 0, 0, 0, 0, loc), true
+	}
+	return t, false
+}
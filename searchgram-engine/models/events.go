@@ -0,0 +1,61 @@
+package models
+
+// Lifecycle event types
+const (
+	EventIndexed         = "indexed"          // A message was indexed or re-indexed
+	EventUpdated         = "updated"          // A stored message was edited
+	EventDeleted         = "deleted"          // Messages were deleted, soft-deleted or moved to the recycle bin
+	EventDedupRemoved    = "dedup_removed"    // Duplicate messages were removed
+	EventRetentionPruned = "retention_pruned" // Messages past their retention were purged
+)
+
+// EventTypes lists the lifecycle event types
+func EventTypes() []string {
+	return []string{EventIndexed, EventUpdated, EventDeleted, EventDedupRemoved, EventRetentionPruned}
+}
+
+// DeleteCauseRetention marks deletes made by the retention purge
+const DeleteCauseRetention = "retention"
+
+// LifecycleEvent reports a successful write to the index. Events about a
+// single message carry its ID; bulk deletes carry what selected the
+// messages and how many there were instead.
+type LifecycleEvent struct {
+	ID        string         `json:"id"` // Unique; redeliveries keep it, so consumers can drop duplicates
+	Type      string         `json:"type"`
+	Time      int64          `json:"time"`                // Unix time of the write
+	Operation string         `json:"operation,omitempty"` // Kind of delete: message, chat, user, query, trash, soft_delete, purge, commands or clear
+	MessageID string         `json:"message_id,omitempty"`
+	ChatID    *int64         `json:"chat_id,omitempty"`
+	UserID    *int64         `json:"user_id,omitempty"`
+	Count     int64          `json:"count,omitempty"`   // Messages removed by a bulk delete
+	Query     *SearchRequest `json:"query,omitempty"`   // Filters of a delete by query or retention purge
+	Message   *Message       `json:"message,omitempty"` // The message as indexed or updated
+}
+
+// DeadLetter is an event that could not be delivered
+type DeadLetter struct {
+	Event    LifecycleEvent `json:"event"`
+	Error    string         `json:"error"`
+	Attempts int            `json:"attempts"`
+	FailedAt int64          `json:"failed_at"`
+}
+
+// EventStats reports the delivery of lifecycle events
+type EventStats struct {
+	Sink            string `json:"sink"`
+	Pending         int    `json:"pending"`                     // Events waiting to be delivered
+	Capacity        int    `json:"capacity"`                    // Maximum buffered events
+	Emitted         int64  `json:"emitted"`                     // Events recorded since startup
+	Delivered       int64  `json:"delivered"`                   // Events delivered since startup
+	Retries         int64  `json:"retries"`                     // Failed deliveries that were retried
+	DeadLettered    int64  `json:"dead_lettered"`               // Events written to the dead-letter log since startup
+	DeadLetters     int    `json:"dead_letters"`                // Events in the dead-letter log now
+	LastDeliveredAt int64  `json:"last_delivered_at,omitempty"` // Unix time events were last delivered
+	LastError       string `json:"last_error,omitempty"`        // Most recent failure, cleared on success
+}
+
+// EventRedeliverResult reports dead letters queued for another delivery
+type EventRedeliverResult struct {
+	Queued int `json:"queued"`
+}
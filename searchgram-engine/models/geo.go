@@ -0,0 +1,37 @@
+package models
+
+import (
+	"strconv"
+	"strings"
+)
+
+// distanceUnits are the Elasticsearch distance units accepted in geo filters
+var distanceUnits = []string{"nmi", "km", "mi", "yd", "ft", "m"}
+
+// GeoPoint is a latitude/longitude pair, indexed as an Elasticsearch geo_point
+type GeoPoint struct {
+	Lat float64 `json:"lat" binding:"gte=-90,lte=90"`
+	Lon float64 `json:"lon" binding:"gte=-180,lte=180"`
+}
+
+// GeoNear restricts results to messages sent within Radius of a point
+type GeoNear struct {
+	Lat    *float64 `json:"lat" binding:"required,gte=-90,lte=90"`
+	Lon    *float64 `json:"lon" binding:"required,gte=-180,lte=180"`
+	Radius string   `json:"radius" binding:"required,distance"` // e.g. "500m", "2km" or "1mi"; plain numbers are meters
+}
+
+// ValidDistance reports whether s is a positive distance, optionally
+// followed by a unit (m, km, mi, yd, ft or nmi)
+func ValidDistance(s string) bool {
+	s = strings.TrimSpace(s)
+	for _, unit := range distanceUnits {
+		if strings.HasSuffix(s, unit) {
+			s = strings.TrimSuffix(s, unit)
+			break
+		}
+	}
+
+	value, err := strconv.ParseFloat(s, 64)
+	return err == nil && value > 0
+}
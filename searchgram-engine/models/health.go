@@ -0,0 +1,24 @@
+package models
+
+// Dependency states in a readiness report
+const (
+	HealthUp       = "up"       // Working normally
+	HealthDegraded = "degraded" // Working, but backing up; does not fail readiness
+	HealthDown     = "down"     // Not working; fails readiness
+)
+
+// HealthCheck describes one dependency of the engine
+type HealthCheck struct {
+	Status    string  `json:"status"`               // up, degraded or down
+	LatencyMs float64 `json:"latency_ms,omitempty"` // Time the check took, for remote dependencies
+	Pending   int     `json:"pending,omitempty"`    // Items waiting in a queue
+	Capacity  int     `json:"capacity,omitempty"`   // Maximum items the queue holds
+	Message   string  `json:"message,omitempty"`    // Why the dependency is not up
+}
+
+// ReadinessResponse reports whether the engine can serve traffic, with the
+// state of each dependency checked
+type ReadinessResponse struct {
+	Status string                 `json:"status"` // ready or not_ready
+	Checks map[string]HealthCheck `json:"checks"`
+}
@@ -0,0 +1,15 @@
+package models
+
+// LegalHold keeps every message of a chat from being deleted until it is
+// released
+type LegalHold struct {
+	ChatID int64  `json:"chat_id"`
+	Reason string `json:"reason"`            // e.g. a case or ticket number
+	HeldBy string `json:"held_by,omitempty"` // Caller that placed the hold
+	HeldAt int64  `json:"held_at"`           // Unix time the hold was placed
+}
+
+// LegalHoldRequest places a legal hold on a chat
+type LegalHoldRequest struct {
+	Reason string `json:"reason" binding:"required,max=500"`
+}
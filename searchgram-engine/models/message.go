@@ -1,5 +1,13 @@
 package models
 
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
 // Chat represents a Telegram chat
 type Chat struct {
 	ID       int64  `json:"id"`
@@ -24,6 +32,7 @@ type MessageEntity struct {
 	Length int    `json:"length,omitempty"` // Length in UTF-16 code units
 	UserID *int64 `json:"user_id,omitempty"` // User ID for text_mention type
 	User   *User  `json:"user,omitempty"`    // User object for text_mention type
+	URL    string `json:"url,omitempty"`     // Target URL for text_link type
 }
 
 // Message represents a Telegram message
@@ -56,6 +65,10 @@ type Message struct {
 	ForwardFromName  *string `json:"forward_from_name,omitempty"`   // Forwarded from name
 	ForwardTimestamp *int64  `json:"forward_timestamp,omitempty"`   // Forward date
 
+	// Reply and thread information
+	ReplyToMessageID int64 `json:"reply_to_message_id,omitempty"` // Message this one replies to (same chat)
+	ThreadID         int64 `json:"thread_id,omitempty"`           // Forum topic (ID of the topic's first message)
+
 	// Content information
 	ContentType    string  `json:"content_type"`               // "text", "sticker", "photo", "video", "document", "other"
 	Text           string  `json:"text,omitempty"`             // Message text
@@ -63,12 +76,67 @@ type Message struct {
 	StickerEmoji   *string `json:"sticker_emoji,omitempty"`    // Sticker emoji
 	StickerSetName *string `json:"sticker_set_name,omitempty"` // Sticker set name
 
+	// Media metadata (empty for text messages)
+	MediaType string `json:"media_type,omitempty"` // photo, video, document, audio, voice, video_note, animation, sticker
+	FileName  string `json:"file_name,omitempty"`  // Original file name (documents, audio, video)
+	MimeType  string `json:"mime_type,omitempty"`  // e.g. "application/pdf"
+	FileSize  int64  `json:"file_size,omitempty"`  // Size in bytes
+	MediaURL  string `json:"media_url,omitempty"`  // Where the file can be downloaded for enrichment (not indexed)
+
+	// Speech transcript of voice and video notes (set by clients or the transcription stage)
+	Transcript string `json:"transcript,omitempty"`
+
+	// Text read from images (set by clients or the OCR stage)
+	OCRText string `json:"ocr_text,omitempty"`
+
+	// ISO 639-1 language of the text, e.g. "zh" or "en" (set by clients or the language stage)
+	Lang string `json:"lang,omitempty"`
+
+	// Spam classification (set by clients or the spam stage)
+	IsSpam    bool    `json:"is_spam,omitempty"`
+	SpamScore float64 `json:"spam_score,omitempty"` // 0 (clean) to 1
+
+	// Dense vector of the searchable text (set by clients or the embedding stage)
+	Embedding []float32 `json:"embedding,omitempty"`
+
+	// Location (location, live location and venue messages)
+	Location *GeoPoint `json:"location,omitempty"`
+
+	// Poll (question and options are searchable)
+	IsPoll       bool     `json:"is_poll,omitempty"`       // Whether the message is a poll
+	PollQuestion string   `json:"poll_question,omitempty"` // Poll question
+	PollOptions  []string `json:"poll_options,omitempty"`  // Answer options in poll order
+
+	// Derived text statistics (computed at ingest)
+	TextLength int `json:"text_length"` // Characters in text + caption
+	WordCount  int `json:"word_count"`  // CJK-aware word count
+	HourOfDay  int `json:"hour_of_day"` // 0-23 in the configured timezone
+	Weekday    int `json:"weekday"`     // ISO weekday in the configured timezone (Monday=1 .. Sunday=7)
+
+	// Edit tracking
+	EditCount  int   `json:"edit_count,omitempty"`  // Number of edits applied via PATCH
+	LastEdited int64 `json:"last_edited,omitempty"` // Unix timestamp of the latest edit
+
+	// Engagement
+	ReactionsCount int `json:"reactions_count,omitempty"` // Total reactions across all emoji
+	Views          int `json:"views,omitempty"`           // View count (channels and channel-linked groups)
+	Forwards       int `json:"forwards,omitempty"`        // Times the message was forwarded
+
 	// Entities (unchanged)
 	Entities []MessageEntity `json:"entities,omitempty"` // Message entities (mentions, hashtags, etc.)
 
+	// Entity values (client-supplied or extracted at ingest)
+	URLs     []string `json:"urls,omitempty"`     // Links, including text link targets
+	Mentions []string `json:"mentions,omitempty"` // Lowercased "@username"
+	Hashtags []string `json:"hashtags,omitempty"` // Lowercased "#tag"
+
+	// Dictionary terms the message contains (tagged at ingest)
+	Terms []string `json:"terms,omitempty"`
+
 	// Soft-delete (unchanged)
-	IsDeleted bool  `json:"is_deleted"`         // Soft-delete flag
-	DeletedAt int64 `json:"deleted_at,omitempty"` // Deletion timestamp
+	IsDeleted bool   `json:"is_deleted"`           // Soft-delete flag
+	DeletedAt int64  `json:"deleted_at,omitempty"` // Deletion timestamp
+	DeletedBy string `json:"deleted_by,omitempty"` // DeletedByQuery for soft deletes by query, which can be restored and purged
 
 	// Backward compatibility (deprecated, will be removed later)
 	Chat     Chat `json:"chat"`      // Old nested chat object
@@ -78,17 +146,233 @@ type Message struct {
 	RawMessage map[string]interface{} `json:"raw_message,omitempty"` // Complete Pyrogram message JSON
 }
 
+// NormalizeHashtag lowercases a hashtag and ensures the leading "#"
+func NormalizeHashtag(tag string) string {
+	tag = strings.TrimPrefix(strings.TrimSpace(tag), "#")
+	if tag == "" {
+		return ""
+	}
+	return "#" + strings.ToLower(tag)
+}
+
+// NormalizeMention lowercases a username mention and ensures the leading "@"
+func NormalizeMention(mention string) string {
+	mention = strings.TrimPrefix(strings.TrimSpace(mention), "@")
+	if mention == "" {
+		return ""
+	}
+	return "@" + strings.ToLower(mention)
+}
+
+// NormalizeLanguage lowercases a language code and drops any region, so
+// "zh-CN" and "zh_TW" both become "zh"
+func NormalizeLanguage(lang string) string {
+	lang = strings.ToLower(strings.TrimSpace(lang))
+	if i := strings.IndexAny(lang, "-_"); i >= 0 {
+		lang = lang[:i]
+	}
+	return lang
+}
+
+// MessageDocumentID builds the composite document ID for a message
+func MessageDocumentID(chatID, messageID int64) string {
+	return fmt.Sprintf("%d-%d", chatID, messageID)
+}
+
+// DeletedByQuery marks messages soft-deleted by delete-by-query, apart from
+// those deleted in Telegram or with their chat or sender
+const DeletedByQuery = "query"
+
+// ParseMessageID splits a composite "{chat_id}-{message_id}" ID.
+// Chat IDs may be negative (e.g. "-100123-456"). Only IDs as built by
+// MessageDocumentID are accepted, so "007-5" or "+7-5" don't name a
+// document other than "7-5".
+func ParseMessageID(id string) (chatID int64, messageID int64, err error) {
+	sep := strings.LastIndex(id, "-")
+	if sep <= 0 {
+		return 0, 0, fmt.Errorf("invalid message ID %q: expected {chat_id}-{message_id}", id)
+	}
+
+	chatID, err = strconv.ParseInt(id[:sep], 10, 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid chat_id in message ID %q", id)
+	}
+	messageID, err = strconv.ParseInt(id[sep+1:], 10, 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid message_id in message ID %q", id)
+	}
+	if MessageDocumentID(chatID, messageID) != id {
+		return 0, 0, fmt.Errorf("invalid message ID %q: expected {chat_id}-{message_id} without leading zeros or signs", id)
+	}
+
+	return chatID, messageID, nil
+}
+
 // SearchRequest represents a search query
 type SearchRequest struct {
 	Keyword        string  `json:"keyword"`                 // Search keyword
 	ChatType       string  `json:"chat_type,omitempty"`     // Filter by chat type
 	Username       string  `json:"username,omitempty"`      // Filter by username
 	ChatID         *int64  `json:"chat_id,omitempty"`       // Filter by chat ID (for group searches)
+	SenderID       *int64  `json:"sender_id,omitempty"`     // Filter by sender (user or chat) ID
+	DateFrom       *DateBound `json:"date_from,omitempty"` // Only messages at or after this date
+	DateTo         *DateBound `json:"date_to,omitempty"`   // Only messages at or before this date (plain dates include the whole day)
+	Timezone       string     `json:"timezone,omitempty"`  // IANA timezone for date filters without offset (default: configured)
 	Page           int     `json:"page"`                    // Page number (1-based)
 	PageSize       int     `json:"page_size"`               // Results per page
 	ExactMatch     bool    `json:"exact_match"`             // Exact vs fuzzy matching
 	BlockedUsers   []int64 `json:"blocked_users,omitempty"` // User IDs to exclude
 	IncludeDeleted bool    `json:"include_deleted"`         // Include soft-deleted messages (owner only)
+	SortBy         string  `json:"sort_by,omitempty"`       // "timestamp" (default), "reactions", "views", "forwards" or "relevance"
+	BoostBy        string  `json:"boost_by,omitempty"`      // Rank by relevance weighted by "reactions", "views" or "forwards"
+	MinReactions   int     `json:"min_reactions,omitempty"` // Only messages with at least this many reactions
+	MinLength      int     `json:"min_length,omitempty"`    // Only messages with at least this many characters
+	MaxLength      int     `json:"max_length,omitempty"`    // Only messages with at most this many characters
+	MediaType      string  `json:"media_type,omitempty"`    // Filter by media type (photo, video, document, ...)
+	MimeType       string  `json:"mime_type,omitempty"`     // Filter by MIME type; "image/*" matches a whole family
+	ThreadID       *int64  `json:"thread_id,omitempty"`     // Filter by forum topic (requires chat_id to be meaningful)
+	Hashtag        string  `json:"hashtag,omitempty"`       // Only messages with this hashtag ("#tag" or "tag")
+	Mention        string  `json:"mention,omitempty"`       // Only messages mentioning this username ("@name" or "name")
+	HasLink        *bool   `json:"has_link,omitempty"`      // Only messages with (true) or without (false) links
+	Near           *GeoNear `json:"near,omitempty"`         // Only messages with a location within a radius of a point
+	IsPoll         *bool    `json:"is_poll,omitempty"`      // Only polls (true) or only non-polls (false)
+	ChatIDs        []int64  `json:"chat_ids,omitempty" binding:"max=1000"` // Only messages from these chats
+	TitleContains  string   `json:"title_contains,omitempty"` // Only chats whose title contains this phrase (resolved to chat_ids)
+	UserGroups     UserGroups `json:"user_groups,omitempty" binding:"max=100,dive,min=2,max=100"` // User IDs to treat as one person in sender_id and blocked_users
+
+	ExcludedChats []int64 `json:"excluded_chats,omitempty" binding:"max=1000"` // Never return messages from these chats
+	IgnoreProfile bool    `json:"ignore_profile,omitempty"`                    // Don't merge the caller's search profile
+
+	Lang string `json:"lang,omitempty"` // Only messages in this language (ISO 639-1, e.g. "zh" or "en")
+
+	ExcludeSpam bool `json:"exclude_spam,omitempty"` // Leave out messages classified as spam
+
+	Translate    bool     `json:"translate,omitempty"` // Also search the keyword translated into the configured languages
+	Translations []string `json:"-"`                   // Translated keywords, set by the handler
+
+	Semantic     bool      `json:"semantic,omitempty"`                                           // Rank by meaning using embeddings
+	SemanticMode string    `json:"semantic_mode,omitempty" binding:"omitempty,oneof=knn hybrid"` // "hybrid" (default, keyword + vector fused) or "knn" (vector only)
+	QueryVector  []float32 `json:"-"`                                                            // Embedding of the keyword, set by the handler
+
+	LexicalWeight  *float64 `json:"lexical_weight,omitempty" binding:"omitempty,min=0,max=10"`  // Hybrid: weight of the keyword ranking in the fusion (default: configured)
+	SemanticWeight *float64 `json:"semantic_weight,omitempty" binding:"omitempty,min=0,max=10"` // Hybrid: weight of the vector ranking in the fusion (default: configured)
+	RerankTopK     *int     `json:"rerank_top_k,omitempty" binding:"omitempty,min=0,max=200"`   // Hybrid: rerank this many fused results with the cross-encoder, 0 = off (default: configured)
+
+	Snapshot string `json:"snapshot,omitempty"` // Search this mounted snapshot instead of the live index (admin route only)
+
+	MergeWindow int `json:"merge_window,omitempty" binding:"omitempty,min=0,max=600"` // Merge each hit with the consecutive messages its sender sent within this many seconds of each other (0 = off)
+
+	MessageIDs []string `json:"-"` // Only these message documents, set by the handler
+	Cause      string   `json:"-"` // Who deletes by this query, e.g. DeleteCauseRetention for lifecycle events
+
+	Degraded bool `json:"-"` // Set under load: skip exact total counting
+	MaxHits  int  `json:"-"` // Set under load: return at most this many hits of the page (0 = page_size)
+
+	Ctx context.Context `json:"-"` // Context of the API request, set by the handler: carries its trace to the engine
+}
+
+// Context returns the values of the context the request is made in, so
+// engines can pass its trace on. Engine calls outlive the request as
+// before: its cancellation and deadline are left out.
+func (r *SearchRequest) Context() context.Context {
+	if r.Ctx != nil {
+		return context.WithoutCancel(r.Ctx)
+	}
+	return context.Background()
+}
+
+// HitLimit returns how many hits of the page to return. Pages keep their
+// page_size offsets when MaxHits is lower, so a page starts with the same
+// hits either way.
+func (r *SearchRequest) HitLimit() int {
+	if r.MaxHits > 0 && r.MaxHits < r.PageSize {
+		return r.MaxHits
+	}
+	return r.PageSize
+}
+
+// ResolveDates converts date filters to unix timestamps using the request
+// timezone, falling back to defaultLocation
+func (r *SearchRequest) ResolveDates(defaultLocation *time.Location) error {
+	if r.DateFrom == nil && r.DateTo == nil {
+		return nil
+	}
+
+	loc := defaultLocation
+	if r.Timezone != "" {
+		var err error
+		if loc, err = time.LoadLocation(r.Timezone); err != nil {
+			return fmt.Errorf("invalid timezone %q", r.Timezone)
+		}
+	}
+
+	if r.DateFrom != nil {
+		if err := r.DateFrom.Resolve(loc, false); err != nil {
+			return fmt.Errorf("date_from: %w", err)
+		}
+	}
+	if r.DateTo != nil {
+		if err := r.DateTo.Resolve(loc, true); err != nil {
+			return fmt.Errorf("date_to: %w", err)
+		}
+	}
+	if r.DateFrom != nil && r.DateTo != nil && r.DateFrom.Unix() > r.DateTo.Unix() {
+		return fmt.Errorf("date_from must not be after date_to")
+	}
+
+	return nil
+}
+
+// Supported SearchRequest.SemanticMode values
+const (
+	SemanticHybrid = "hybrid"
+	SemanticKNN    = "knn"
+)
+
+// Supported SearchRequest.SortBy values
+const (
+	SortByTimestamp = "timestamp"
+	SortByReactions = "reactions"
+	SortByViews     = "views"
+	SortByForwards  = "forwards"
+	SortByRelevance = "relevance"
+)
+
+// engagementFields maps engagement sort and boost names to message fields
+var engagementFields = map[string]string{
+	SortByReactions: "reactions_count",
+	SortByViews:     "views",
+	SortByForwards:  "forwards",
+}
+
+// ValidSortBy reports whether sortBy is a supported sort mode (empty means default)
+func ValidSortBy(sortBy string) bool {
+	switch sortBy {
+	case "", SortByTimestamp, SortByRelevance:
+		return true
+	}
+	_, ok := engagementFields[sortBy]
+	return ok
+}
+
+// EngagementField returns the message field for a boost_by value, or "" if
+// it is not an engagement metric
+func EngagementField(name string) string {
+	return engagementFields[name]
+}
+
+// SortOrder returns the full ordering contract for a sort mode: the primary
+// key followed by deterministic tie-breakers (timestamp, message_id, then the
+// composite id) so equal keys never shuffle between pages
+func SortOrder(sortBy string) []string {
+	switch sortBy {
+	case SortByReactions, SortByViews, SortByForwards:
+		return []string{engagementFields[sortBy] + ":desc", "timestamp:desc", "message_id:desc", "id:desc"}
+	case SortByRelevance:
+		return []string{"_score:desc", "timestamp:desc", "message_id:desc", "id:desc"}
+	default:
+		return []string{"timestamp:desc", "message_id:desc", "id:desc"}
+	}
 }
 
 // SearchResponse represents search results
@@ -99,18 +383,130 @@ type SearchResponse struct {
 	Page        int       `json:"page"`          // Current page
 	HitsPerPage int       `json:"hits_per_page"` // Results per page
 	TookMs      int64     `json:"took_ms"`       // Server-side timing in milliseconds
+	Sort        []string  `json:"sort"`          // Ordering applied, as field:direction (see SortOrder)
+
+	Translations []string `json:"translations,omitempty"` // Translated keywords that were searched too
+
+	Merged map[string]MergedHit `json:"merged,omitempty"` // Hit ID -> the run of messages it was merged with (merge_window)
+
+	Tags map[string][]string `json:"tags,omitempty"` // Hit ID -> tags of saved messages (saved_messages)
+}
+
+// MergedHit is a run of consecutive messages from one sender that a search
+// hit stands for, e.g. a thought typed as many short lines
+type MergedHit struct {
+	MessageIDs    []int64 `json:"message_ids"`    // The run's messages in order, the hit included
+	Text          string  `json:"text"`           // Their texts joined by newlines
+	FromTimestamp int64   `json:"from_timestamp"` // When the first message was sent
+	ToTimestamp   int64   `json:"to_timestamp"`   // When the last message was sent
 }
 
 // UpsertResponse represents the result of an upsert operation
 type UpsertResponse struct {
 	Success bool   `json:"success"`
 	ID      string `json:"id"`
+	Skipped bool   `json:"skipped,omitempty"` // Excluded by capture rules, not indexed
+	Queued  bool   `json:"queued,omitempty"`  // Accepted into the async ingest queue, not yet indexed
 }
 
 // DeleteResponse represents the result of a delete operation
 type DeleteResponse struct {
+	Success      bool   `json:"success"`
+	DeletedCount int64  `json:"deleted_count"`
+	TrashBatch   string `json:"trash_batch,omitempty"` // Recycle bin batch to restore from, if enabled
+}
+
+// DeleteByQueryRequest deletes messages matching search filters
+type DeleteByQueryRequest struct {
+	SearchRequest
+	DryRun bool `json:"dry_run"` // Only count matching messages
+	Force  bool `json:"force"`   // Delete even when more messages match than the guardrail allows
+}
+
+// DeleteGuardResponse refuses a delete that would affect more messages than
+// allowed without force
+type DeleteGuardResponse struct {
+	Error          string `json:"error"`
+	Message        string `json:"message"`
+	EstimatedCount int64  `json:"estimated_count"` // Messages the operation would affect
+	Threshold      int64  `json:"threshold"`       // Maximum allowed without force
+}
+
+// HasFilters reports whether at least one narrowing filter is set
+func (r *DeleteByQueryRequest) HasFilters() bool {
+	return r.Keyword != "" || r.ChatType != "" || r.Username != "" || r.ChatID != nil ||
+		r.SenderID != nil || r.DateFrom != nil || r.DateTo != nil ||
+		len(r.ChatIDs) > 0 || strings.TrimSpace(r.TitleContains) != ""
+}
+
+// MessageUpdateRequest represents a partial update of an indexed message.
+// Only fields that are present are changed.
+type MessageUpdateRequest struct {
+	Text     *string `json:"text,omitempty"`      // New message text
+	Caption  *string `json:"caption,omitempty"`   // New media caption
+	EditDate *int64  `json:"edit_date,omitempty"` // Telegram edit date (unix); defaults to now
+}
+
+// IsEmpty reports whether the update changes nothing
+func (r *MessageUpdateRequest) IsEmpty() bool {
+	return r.Text == nil && r.Caption == nil && r.EditDate == nil
+}
+
+// MessageUpdateResponse represents the result of a message update
+type MessageUpdateResponse struct {
+	Success bool     `json:"success"`
+	Message *Message `json:"message"`
+}
+
+// MessageContextResponse represents a message with its surrounding messages
+// from the same chat, each side sorted by message_id ascending
+type MessageContextResponse struct {
+	Message *Message  `json:"message"`
+	Before  []Message `json:"before"`
+	After   []Message `json:"after"`
+}
+
+// DeleteByQueryResponse represents the result of a delete-by-query operation
+type DeleteByQueryResponse struct {
 	Success      bool  `json:"success"`
-	DeletedCount int64 `json:"deleted_count"`
+	DryRun       bool  `json:"dry_run"`
+	MatchedCount int64  `json:"matched_count,omitempty"` // Set on dry runs
+	DeletedCount int64  `json:"deleted_count"`
+	TrashBatch   string `json:"trash_batch,omitempty"`  // Recycle bin batch to restore from, if enabled
+	SoftDeleted  bool   `json:"soft_deleted,omitempty"` // Messages were only marked deleted and can be restored
+}
+
+// DeletedFilter selects soft-deleted messages by search filters and by
+// when they were deleted
+type DeletedFilter struct {
+	SearchRequest
+	DeletedAfter  *int64 `json:"deleted_after,omitempty"`  // Only messages deleted at or after this Unix time
+	DeletedBefore *int64 `json:"deleted_before,omitempty"` // Only messages deleted before this Unix time
+}
+
+// DeletedMessagesRequest restores or purges soft-deleted messages
+type DeletedMessagesRequest struct {
+	DeletedFilter
+	DryRun bool `json:"dry_run"` // Only count matching messages
+	Force  bool `json:"force"`   // Purge even when more messages match than the guardrail allows
+}
+
+// DeletedMessagesResponse reports soft-deleted messages restored or purged
+type DeletedMessagesResponse struct {
+	Success       bool  `json:"success"`
+	DryRun        bool  `json:"dry_run"`
+	MatchedCount  int64 `json:"matched_count,omitempty"` // Set on dry runs
+	RestoredCount int64 `json:"restored_count,omitempty"`
+	PurgedCount   int64 `json:"purged_count,omitempty"`
+}
+
+// ClearConfirmationResponse asks to confirm a clear with a one-time token
+type ClearConfirmationResponse struct {
+	Error          string `json:"error"`
+	Message        string `json:"message"`
+	ConfirmToken   string `json:"confirm_token"`   // Send back as ?confirm= to clear
+	ExpiresAt      int64  `json:"expires_at"`      // Unix time the token stops working
+	EstimatedCount int64  `json:"estimated_count"` // Messages the clear would delete
 }
 
 // ClearResponse represents the result of a clear operation
@@ -136,6 +532,45 @@ type StatsResponse struct {
 	IndexSizeBytes     int64   `json:"index_size_bytes"`
 	RequestsTotal      int64   `json:"requests_total"`
 	RequestsPerMinute  float64 `json:"requests_per_minute"`
+
+	IngestQueue *IngestQueueStats `json:"ingest_queue,omitempty"` // Set when async ingestion is enabled
+
+	MediaEnrichment *MediaEnrichmentStats `json:"media_enrichment,omitempty"` // Set when transcription or OCR is enabled
+
+	AuthGuard *AuthGuardStats `json:"auth_guard,omitempty"` // Set when brute-force protection is enabled
+
+	Replication *ReplicationStats `json:"replication,omitempty"` // Set when replication to a secondary engine is enabled
+
+	Archive *ArchiveStats `json:"archive,omitempty"` // Set when archiving to object storage is enabled
+}
+
+// IngestQueueStats describes the async ingestion queue
+type IngestQueueStats struct {
+	Depth    int   `json:"depth"`    // Messages waiting to be indexed
+	Capacity int   `json:"capacity"` // Maximum buffered messages
+	Enqueued int64 `json:"enqueued"` // Messages accepted since startup
+	Flushed  int64 `json:"flushed"`  // Messages indexed since startup
+	Failed   int64 `json:"failed"`   // Messages rejected or dropped since startup
+}
+
+// MediaEnrichmentStats describes the background transcription and OCR of
+// indexed messages
+type MediaEnrichmentStats struct {
+	Pending  int   `json:"pending"`  // Messages waiting for their media to be read
+	Capacity int   `json:"capacity"` // Maximum waiting messages
+	Enriched int64 `json:"enriched"` // Messages updated with media text since startup
+	Failed   int64 `json:"failed"`   // Failed reads or updates since startup
+	Dropped  int64 `json:"dropped"`  // Messages left unread because the queue was full
+}
+
+// AuthGuardStats describes authentication brute-force protection
+type AuthGuardStats struct {
+	Failures   int64 `json:"failures"`    // Failed authentications since startup
+	Delayed    int64 `json:"delayed"`     // Requests slowed down after repeated failures
+	Rejected   int64 `json:"rejected"`    // Requests refused while their source was banned
+	Bans       int64 `json:"bans"`        // Bans issued since startup
+	ActiveBans int   `json:"active_bans"` // Sources banned right now
+	Tracked    int   `json:"tracked"`     // Sources with recent failures
 }
 
 // BatchUpsertRequest represents a batch upsert request
@@ -148,14 +583,43 @@ type BatchUpsertResponse struct {
 	Success      bool     `json:"success"`
 	IndexedCount int      `json:"indexed_count"`
 	FailedCount  int      `json:"failed_count"`
+	SkippedCount int      `json:"skipped_count,omitempty"` // Excluded by capture rules
+	Errors       []string `json:"errors,omitempty"`
+}
+
+// ImportResponse represents the result of importing a Telegram Desktop export
+type ImportResponse struct {
+	Success      bool     `json:"success"`
+	Chats        int      `json:"chats"`                   // Chats found in the export
+	Messages     int      `json:"messages"`                // Regular messages read
+	Ignored      int      `json:"ignored"`                 // Service messages (joins, pins, ...)
+	IndexedCount int      `json:"indexed_count"`
+	FailedCount  int      `json:"failed_count"`
+	SkippedCount int      `json:"skipped_count,omitempty"` // Excluded by capture rules
 	Errors       []string `json:"errors,omitempty"`
 }
 
 // ErrorResponse represents an error response
 type ErrorResponse struct {
+	Error   string       `json:"error"`
+	Message string       `json:"message,omitempty"`
+	Code    int          `json:"code,omitempty"`
+	Fields  []FieldError `json:"fields,omitempty"` // Per-field validation failures
+}
+
+// JobConflictResponse refuses to start a job while one of its type runs
+type JobConflictResponse struct {
 	Error   string `json:"error"`
-	Message string `json:"message,omitempty"`
-	Code    int    `json:"code,omitempty"`
+	Message string `json:"message"`
+	JobID   string `json:"job_id"` // The running job, see /api/v1/jobs/:id
+}
+
+// FieldError describes one invalid request field
+type FieldError struct {
+	Field      string      `json:"field"`             // JSON path, e.g. "messages.chat_id"; "(body)" for the whole body
+	Constraint string      `json:"constraint"`        // Violated rule: type, required, min=1, json, ...
+	Message    string      `json:"message"`           // Human-readable explanation
+	Example    interface{} `json:"example,omitempty"` // A valid value for the field
 }
 
 // DedupResponse represents the result of a deduplication operation
@@ -163,7 +627,46 @@ type DedupResponse struct {
 	Success           bool   `json:"success"`
 	DuplicatesFound   int64  `json:"duplicates_found"`
 	DuplicatesRemoved int64  `json:"duplicates_removed"`
+	PagesProcessed    int    `json:"pages_processed,omitempty"`
 	Message           string `json:"message,omitempty"`
+
+	DryRun bool         `json:"dry_run,omitempty"`
+	Report *DedupReport `json:"report,omitempty"` // Only for dry runs
+}
+
+// BackfillRequest represents a Telegram chat history backfill request
+type BackfillRequest struct {
+	ChatID   int64 `json:"chat_id" binding:"required"` // Bot API style chat ID (-100... for channels)
+	OffsetID int   `json:"offset_id"`                  // Resume below this message ID (0 = newest)
+	Limit    int   `json:"limit"`                      // Maximum messages to fetch (0 = full history)
+}
+
+// DedupRequest represents the optional body of a dedup request
+type DedupRequest struct {
+	DryRun bool `json:"dry_run"` // Report duplicates without deleting
+}
+
+// DedupReport describes the duplicates a dedup pass would remove
+type DedupReport struct {
+	DuplicateGroups int64             `json:"duplicate_groups"` // Messages stored more than once
+	ReclaimableDocs int64             `json:"reclaimable_docs"` // Documents a real pass would delete
+	Chats           []DedupChatReport `json:"chats"`            // Most affected chats first
+	Samples         []DedupSample     `json:"samples"`          // Example duplicate groups
+}
+
+// DedupChatReport summarizes duplicates within one chat
+type DedupChatReport struct {
+	ChatID          int64 `json:"chat_id"` // 0 for documents without chat_id
+	DuplicateGroups int64 `json:"duplicate_groups"`
+	ReclaimableDocs int64 `json:"reclaimable_docs"`
+}
+
+// DedupSample is one message stored more than once
+type DedupSample struct {
+	ID        string `json:"id"` // Composite ID of the message
+	ChatID    int64  `json:"chat_id"`
+	MessageID int64  `json:"message_id"`
+	Copies    int64  `json:"copies"`
 }
 
 // UserStatsRequest represents a user stats query
@@ -174,6 +677,7 @@ type UserStatsRequest struct {
 	ToTimestamp     int64  `json:"to_timestamp"`               // End of time window
 	IncludeMentions bool   `json:"include_mentions"`           // Whether to count mentions
 	IncludeDeleted  bool   `json:"include_deleted"`            // Include deleted messages (owner only)
+	UserGroups      UserGroups `json:"user_groups,omitempty" binding:"max=100,dive,min=2,max=100"` // User IDs to treat as one person
 }
 
 // UserStatsResponse represents user activity statistics
@@ -183,6 +687,9 @@ type UserStatsResponse struct {
 	UserRatio         float64 `json:"user_ratio"`          // user_count / group_total
 	MentionsOut       int64   `json:"mentions_out"`        // User mentioned others (outgoing)
 	MentionsIn        int64   `json:"mentions_in"`         // User was mentioned (incoming)
+	AvgTextLength     float64 `json:"avg_text_length"`     // Average characters per user message
+	AvgWordCount      float64 `json:"avg_word_count"`      // Average words per user message
+	UserIDs           []int64 `json:"user_ids,omitempty"`  // Accounts counted together when user_groups merged several
 }
 
 // CleanCommandsResponse represents the result of a clean commands operation
@@ -197,6 +704,22 @@ type GetMessageIDsRequest struct {
 	ChatID int64 `json:"chat_id"` // Chat ID to query
 }
 
+// ChatSummary describes an indexed chat as of its latest message
+type ChatSummary struct {
+	ChatID        int64  `json:"chat_id"`
+	ChatType      string `json:"chat_type,omitempty"`
+	ChatTitle     string `json:"chat_title,omitempty"`
+	ChatUsername  string `json:"chat_username,omitempty"`
+	MessageCount  int64  `json:"message_count"`   // Indexed messages, excluding soft-deleted ones
+	LastMessageAt int64  `json:"last_message_at"` // Unix timestamp of the latest message
+}
+
+// ChatListResponse lists indexed chats
+type ChatListResponse struct {
+	Chats []ChatSummary `json:"chats"`
+	Total int           `json:"total"`
+}
+
 // GetMessageIDsResponse represents the list of message IDs in the index
 type GetMessageIDsResponse struct {
 	ChatID     int64   `json:"chat_id"`      // Chat ID
@@ -0,0 +1,37 @@
+package models
+
+// SearchProfile holds the search defaults stored for one caller (an API key
+// or JWT subject), merged into every search the caller makes
+type SearchProfile struct {
+	Caller        string  `json:"caller"`
+	BlockedUsers  []int64 `json:"blocked_users,omitempty" binding:"max=10000"` // Always excluded senders
+	ExcludedChats []int64 `json:"excluded_chats,omitempty" binding:"max=1000"` // Never searched chats
+	PageSize      int     `json:"page_size,omitempty" binding:"omitempty,min=1,max=100"`
+	SortBy        string  `json:"sort_by,omitempty"` // Used when a search sets neither sort_by nor boost_by
+	UpdatedAt     int64   `json:"updated_at"`
+}
+
+// Apply merges the profile into a search request. Blocked users and excluded
+// chats are added to the request's own; page size and sort order only fill
+// in what the request leaves unset.
+func (p *SearchProfile) Apply(req *SearchRequest) {
+	req.BlockedUsers = appendMissing(req.BlockedUsers, p.BlockedUsers)
+	req.ExcludedChats = appendMissing(req.ExcludedChats, p.ExcludedChats)
+
+	if req.PageSize < 1 && p.PageSize > 0 {
+		req.PageSize = p.PageSize
+	}
+	if req.SortBy == "" && req.BoostBy == "" {
+		req.SortBy = p.SortBy
+	}
+}
+
+// appendMissing appends the IDs of extra not already in ids
+func appendMissing(ids, extra []int64) []int64 {
+	for _, id := range extra {
+		if !containsID(ids, id) {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
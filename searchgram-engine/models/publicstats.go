@@ -0,0 +1,59 @@
+package models
+
+// Activity intervals for ActivityRequest
+const (
+	ActivityIntervalDay  = "day"
+	ActivityIntervalWeek = "week"
+)
+
+// ActivityRequest selects the messages of a chat to aggregate over time
+type ActivityRequest struct {
+	ChatID   int64
+	From     int64  // Unix time, inclusive
+	To       int64  // Unix time, exclusive
+	Interval string // ActivityIntervalDay or ActivityIntervalWeek
+}
+
+// ActivityBucket counts the messages of a chat in one interval
+type ActivityBucket struct {
+	Start    int64 `json:"start"`              // Unix time the interval starts
+	Messages int64 `json:"messages,omitempty"` // Messages sent in the interval
+	Senders  int64 `json:"senders,omitempty"`  // Distinct users sending them
+
+	// Set on published stats when too few users were active to show the counts
+	Suppressed bool `json:"suppressed,omitempty"`
+}
+
+// ChatActivity holds a chat's message counts per interval
+type ChatActivity struct {
+	ChatID   int64            `json:"chat_id"`
+	Interval string           `json:"interval"`
+	From     int64            `json:"from"`
+	To       int64            `json:"to"`
+	Buckets  []ActivityBucket `json:"buckets"`
+}
+
+// TrendingRequest selects the messages of a chat to find trending terms in
+type TrendingRequest struct {
+	ChatID int64
+	From   int64 // Unix time, inclusive
+	To     int64 // Unix time, exclusive
+	Size   int   // Terms per kind
+}
+
+// TermCount counts the use of a term
+type TermCount struct {
+	Term     string `json:"term"`
+	Messages int64  `json:"messages"` // Messages using the term
+	Senders  int64  `json:"senders"`  // Distinct users using it
+}
+
+// TrendingTerms holds the terms used unusually often in a chat during a
+// period compared to its whole history
+type TrendingTerms struct {
+	ChatID   int64       `json:"chat_id"`
+	From     int64       `json:"from"`
+	To       int64       `json:"to"`
+	Hashtags []TermCount `json:"hashtags"`
+	Words    []TermCount `json:"words"`
+}
@@ -0,0 +1,51 @@
+package models
+
+// Reindex phases reported in ReindexProgress
+const (
+	ReindexPhaseCopy    = "copy"     // Copying all messages into the new index
+	ReindexPhaseCatchUp = "catch_up" // Copying messages written during the copy
+)
+
+// ReindexProgress reports a running reindex
+type ReindexProgress struct {
+	Phase  string `json:"phase"`
+	Source string `json:"source"`
+	Target string `json:"target"`
+	Total  int64  `json:"total"` // Messages the phase reads
+	Done   int64  `json:"done"`  // Messages the phase has read so far
+}
+
+// ReindexResult reports a reindex into a new index with the current
+// settings and mappings
+type ReindexResult struct {
+	Alias         string `json:"alias"`          // Name searches and writes use
+	Source        string `json:"source"`         // Indices the messages were copied from, comma-separated
+	Target        string `json:"target"`         // New indices the alias points to, comma-separated
+	Copied        int64  `json:"copied"`         // Messages copied
+	CaughtUp      int64  `json:"caught_up"`      // Messages written or edited during the copy and copied after it
+	SourceRemoved bool   `json:"source_removed"` // The source was named like the alias and had to be deleted
+	TookMs        int64  `json:"took_ms"`
+}
+
+// IndexVersion describes a copy of the message index created at startup or
+// by a reindex, or one monthly partition of it
+type IndexVersion struct {
+	Index     string `json:"index"`
+	Partition string `json:"partition,omitempty"` // Month the partition holds messages of, e.g. 2026.10
+	Active    bool   `json:"active"`              // The alias points to it
+	CreatedAt int64  `json:"created_at"`          // Unix time the index was created
+	Documents int64  `json:"documents"`
+	SizeBytes int64  `json:"size_bytes"` // Disk usage including replicas
+}
+
+// IndexRollbackRequest selects the version to switch the alias to
+type IndexRollbackRequest struct {
+	Index string `json:"index"` // Empty = the newest version older than the active one
+}
+
+// IndexRollback reports the alias switched to another version
+type IndexRollback struct {
+	Alias string `json:"alias"`
+	From  string `json:"from"` // Version or partitions the alias pointed to, comma-separated
+	To    string `json:"to"`   // Version the alias points to now
+}
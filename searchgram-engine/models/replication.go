@@ -0,0 +1,24 @@
+package models
+
+// ReplicationStats describes replication to the secondary engine
+type ReplicationStats struct {
+	Connected     bool    `json:"connected"`                 // Whether the secondary engine has been reached
+	InSync        bool    `json:"in_sync"`                   // False once writes were lost; a resync is needed
+	OutOfSync     string  `json:"out_of_sync,omitempty"`     // Why the secondary needs a resync
+	Resyncing     bool    `json:"resyncing"`                 // A resync is copying all messages right now
+	Pending       int     `json:"pending"`                   // Operations waiting to be applied
+	Capacity      int     `json:"capacity"`                  // Maximum buffered operations
+	LagSeconds    float64 `json:"lag_seconds"`               // Age of the oldest pending operation
+	Enqueued      int64   `json:"enqueued"`                  // Operations recorded since startup
+	Applied       int64   `json:"applied"`                   // Operations applied since startup
+	Dropped       int64   `json:"dropped"`                   // Operations lost to a full queue since startup
+	Retries       int64   `json:"retries"`                   // Failed attempts that were retried
+	LastAppliedAt int64   `json:"last_applied_at,omitempty"` // Unix time an operation was last applied
+	LastError     string  `json:"last_error,omitempty"`      // Most recent failure, cleared on success
+}
+
+// ReplicationResyncResult reports a completed resync
+type ReplicationResyncResult struct {
+	CopiedCount int64    `json:"copied_count"`       // Messages copied to the secondary
+	Failures    []string `json:"failures,omitempty"` // Messages the secondary refused (at most 100)
+}
@@ -0,0 +1,33 @@
+package models
+
+// Retention policy sources
+const (
+	RetentionSourceConfig = "config" // Set in the retention.chats configuration
+	RetentionSourceAPI    = "api"    // Set via PUT /api/v1/retention/chats/:chat_id
+)
+
+// RetentionPolicy keeps the messages of a chat for Days days
+type RetentionPolicy struct {
+	ChatID    int64  `json:"chat_id"`
+	Days      int    `json:"days"`                 // 0 = keep forever
+	Source    string `json:"source"`               // config or api
+	UpdatedAt int64  `json:"updated_at,omitempty"` // Unix time of the last API change
+}
+
+// RetentionPolicyRequest sets the retention of a chat
+type RetentionPolicyRequest struct {
+	Days *int `json:"days" binding:"required,min=0"` // 0 = keep forever
+}
+
+// RetentionPolicies lists the effective retention configuration
+type RetentionPolicies struct {
+	DefaultDays int               `json:"default_days"` // Applies to chats without a policy (0 = forever)
+	Policies    []RetentionPolicy `json:"policies"`
+}
+
+// RetentionPurgeResult reports what a retention purge removed
+type RetentionPurgeResult struct {
+	DeletedCount int64    `json:"deleted_count"`
+	Chats        int      `json:"chats"`            // Chat policies applied, not counting the default
+	Errors       []string `json:"errors,omitempty"` // Policies that failed; the others still ran
+}
@@ -0,0 +1,69 @@
+package models
+
+// SavedMessagesChatType is the chat type of a user's Saved Messages, the
+// private chat whose ID is the user's own
+const SavedMessagesChatType = "PRIVATE"
+
+// SavedTags are the tags a user gave one of their saved messages
+type SavedTags struct {
+	ID        string   `json:"id"`   // Composite message ID; the chat ID is the user's
+	Tags      []string `json:"tags"` // Lowercased, sorted
+	UpdatedAt int64    `json:"updated_at"`
+}
+
+// SavedTagsRequest replaces the tags of a saved message; an empty list
+// removes them
+type SavedTagsRequest struct {
+	Tags []string `json:"tags" binding:"max=100,dive,min=1,max=64"`
+}
+
+// SavedSearchRequest searches a user's Saved Messages: the body of
+// /search, whose chat filters are replaced, plus an optional tag
+type SavedSearchRequest struct {
+	SearchRequest
+	Tag string `json:"tag,omitempty"` // Only messages with this tag
+}
+
+// SavedTagCount counts the saved messages carrying a tag
+type SavedTagCount struct {
+	Tag      string `json:"tag"`
+	Messages int    `json:"messages"`
+}
+
+// SavedStats describes a user's Saved Messages
+type SavedStats struct {
+	UserID        int64           `json:"user_id"`
+	Messages      int64           `json:"messages"`                 // Stored messages, excluding soft-deleted ones
+	Tagged        int             `json:"tagged"`                   // Messages with at least one tag
+	Tags          []SavedTagCount `json:"tags"`                     // Most used first
+	LastSavedAt   int64           `json:"last_saved_at,omitempty"`  // Unix time of the newest message
+	RetentionDays *int            `json:"retention_days,omitempty"` // Days messages are kept, 0 = forever (unset when retention is disabled)
+}
+
+// SavedDigest summarises what a user saved during the last days, grouped
+// by tag
+type SavedDigest struct {
+	UserID    int64              `json:"user_id"`
+	Since     int64              `json:"since"`     // Unix time the digest starts at
+	Messages  int64              `json:"messages"`  // Messages saved since then
+	Truncated bool               `json:"truncated"` // Only the newest messages are listed
+	Groups    []SavedDigestGroup `json:"groups"`    // Largest first; untagged messages last
+}
+
+// SavedDigestGroup lists the digest's messages with one tag, or those
+// without any when Tag is empty
+type SavedDigestGroup struct {
+	Tag      string             `json:"tag"`
+	Messages []SavedDigestEntry `json:"messages"` // Newest first
+}
+
+// SavedDigestEntry is one saved message of a digest
+type SavedDigestEntry struct {
+	ID              string   `json:"id"`
+	MessageID       int64    `json:"message_id"`
+	Timestamp       int64    `json:"timestamp"`
+	Snippet         string   `json:"snippet"`                     // Start of the text, caption or transcript
+	ForwardFromName string   `json:"forward_from_name,omitempty"` // Where a forwarded message came from
+	URLs            []string `json:"urls,omitempty"`
+	Tags            []string `json:"tags,omitempty"`
+}
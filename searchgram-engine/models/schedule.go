@@ -0,0 +1,51 @@
+package models
+
+// ScheduledTask describes a recurring maintenance task
+type ScheduledTask struct {
+	Name     string `json:"name"`
+	Task     string `json:"task"`               // dedup, retention_purge, forcemerge, snapshot or export
+	Schedule string `json:"schedule"`           // Cron expression
+	NextRun  int64  `json:"next_run,omitempty"` // Unix time of the next run (unset if it never matches)
+	LastRun  int64  `json:"last_run,omitempty"` // Unix time the task last started a job
+	LastJob  string `json:"last_job,omitempty"` // Job started by the last run, see /api/v1/jobs/:id
+	Skipped  int64  `json:"skipped"`            // Runs skipped because a job of the same type was running
+}
+
+// ScheduleResponse lists the scheduled tasks
+type ScheduleResponse struct {
+	Timezone string          `json:"timezone"` // Location the schedules are evaluated in
+	Tasks    []ScheduledTask `json:"tasks"`
+}
+
+// ForceMergeResult reports a force merge of the message index
+type ForceMergeResult struct {
+	Index       string `json:"index"`
+	MaxSegments int    `json:"max_segments,omitempty"` // Target segments per shard (unset = Elasticsearch's choice)
+	Shards      int    `json:"shards"`                 // Shards merged successfully
+	Failed      int    `json:"failed"`                 // Shards that failed to merge
+	TookMs      int64  `json:"took_ms"`
+}
+
+// SnapshotResult reports a snapshot of the message index
+type SnapshotResult struct {
+	Repository string   `json:"repository"`
+	Snapshot   string   `json:"snapshot"`
+	State      string   `json:"state"` // SUCCESS, PARTIAL or FAILED
+	Indices    []string `json:"indices"`
+	DurationMs int64    `json:"duration_ms"`
+	Deleted    []string `json:"deleted,omitempty"` // Older snapshots removed to keep the configured number
+}
+
+// ExportResult reports a scheduled export of messages
+type ExportResult struct {
+	Name       string `json:"name"`            // Scheduled task that ran the export
+	Target     string `json:"target"`          // dir, s3 or webhook
+	File       string `json:"file"`            // <name>-<time>.ndjson.gz
+	Location   string `json:"location"`        // Path, s3:// URL or webhook URL the export went to
+	CreatedAt  int64  `json:"created_at"`      // Unix time the export started
+	Since      int64  `json:"since,omitempty"` // Oldest message time included (unset = all)
+	Messages   int64  `json:"messages"`
+	Chats      int    `json:"chats"`
+	Bytes      int64  `json:"bytes"` // Size of the compressed file
+	DurationMs int64  `json:"duration_ms"`
+}
@@ -0,0 +1,11 @@
+package models
+
+// SessionResponse describes a dashboard session. The session ID itself only
+// travels in the HttpOnly cookie; the CSRF token must be sent back in the
+// X-CSRF-Token header of every state-changing request.
+type SessionResponse struct {
+	CSRFToken string `json:"csrf_token"`
+	Issuer    string `json:"issuer,omitempty"` // JWT issuer of the login, empty for API key logins
+	Role      string `json:"role,omitempty"`   // Credential role of the login (reader, writer, admin)
+	ExpiresAt int64  `json:"expires_at"`       // Unix time the session ends
+}
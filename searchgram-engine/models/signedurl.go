@@ -0,0 +1,13 @@
+package models
+
+// SignURLRequest asks for a signed download URL
+type SignURLRequest struct {
+	Path       string `json:"path" binding:"required"`               // API path with query, e.g. /api/v1/usage?format=csv
+	TTLSeconds int    `json:"ttl_seconds" binding:"omitempty,min=0"` // Lifetime of the URL (0 = server default, capped by the server maximum)
+}
+
+// SignURLResponse carries a signed download URL
+type SignURLResponse struct {
+	URL       string `json:"url"`
+	ExpiresAt int64  `json:"expires_at"` // Unix time after which the URL is rejected
+}
@@ -0,0 +1,18 @@
+package models
+
+// SnapshotInfo describes a snapshot holding the message index
+type SnapshotInfo struct {
+	Snapshot  string `json:"snapshot"`
+	State     string `json:"state"`           // SUCCESS, PARTIAL, FAILED or IN_PROGRESS
+	StartTime int64  `json:"start_time"`      // Unix time the snapshot started, i.e. the point in time it shows
+	Mounted   bool   `json:"mounted"`         // Searchable with the snapshot search parameter
+	Index     string `json:"index,omitempty"` // Index the snapshot is mounted as
+}
+
+// SnapshotMount reports a snapshot mounted for searching
+type SnapshotMount struct {
+	Repository string `json:"repository"`
+	Snapshot   string `json:"snapshot"`
+	Index      string `json:"index"`   // Read-only index holding the snapshot's messages
+	TookMs     int64  `json:"took_ms"` // 0 when it was mounted already
+}
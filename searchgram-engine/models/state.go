@@ -0,0 +1,23 @@
+package models
+
+// StateBundleVersion is the layout version of state bundles
+const StateBundleVersion = 1
+
+// StateBundle holds the configuration users created via the API, so it can
+// be carried over to a rebuilt host. On import a null section is left
+// alone and an empty one clears what is stored.
+type StateBundle struct {
+	Version       int               `json:"version"`
+	ExportedAt    int64             `json:"exported_at"`
+	CaptureRules  *CaptureRuleSet   `json:"capture_rules"` // Null when capture rules are disabled
+	Subscriptions []Subscription    `json:"subscriptions"` // Keyword watches; null when disabled
+	Profiles      []SearchProfile   `json:"profiles"`      // Saved search defaults; null when disabled
+	Retention     []RetentionPolicy `json:"retention"`     // Policies set via the API; null when disabled
+	SavedTags     []SavedTags       `json:"saved_tags"`    // Tags of Saved Messages; null when disabled
+}
+
+// StateImportResult reports which sections of a bundle were imported
+type StateImportResult struct {
+	Imported []string `json:"imported"`          // Sections that replaced the stored state
+	Skipped  []string `json:"skipped,omitempty"` // Sections of features not enabled on this host
+}
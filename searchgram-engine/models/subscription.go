@@ -0,0 +1,30 @@
+package models
+
+// Subscription is a keyword watch registered by a bot on behalf of a Telegram user
+type Subscription struct {
+	ID        string  `json:"id"`
+	UserID    int64   `json:"user_id"`            // Telegram user to notify
+	Keyword   string  `json:"keyword"`            // Case-insensitive substring to match
+	ChatIDs   []int64 `json:"chat_ids,omitempty"` // Restrict to these chats (empty = all)
+	CreatedAt int64   `json:"created_at"`
+}
+
+// SubscriptionEvent is a notification queued when an indexed message matches a subscription
+type SubscriptionEvent struct {
+	Seq            int64  `json:"seq"` // Monotonic sequence for long-poll cursors
+	SubscriptionID string `json:"subscription_id"`
+	UserID         int64  `json:"user_id"`
+	Keyword        string `json:"keyword"`
+	MessageID      string `json:"message_id"` // Composite document ID
+	ChatID         int64  `json:"chat_id"`
+	ChatTitle      string `json:"chat_title,omitempty"`
+	SenderName     string `json:"sender_name,omitempty"`
+	Snippet        string `json:"snippet"`
+	Timestamp      int64  `json:"timestamp"`
+}
+
+// SubscriptionEventsResponse is returned by the long-poll endpoint
+type SubscriptionEventsResponse struct {
+	Events []SubscriptionEvent `json:"events"`
+	Next   int64               `json:"next"` // Pass as "after" on the next poll
+}
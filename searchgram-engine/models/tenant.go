@@ -0,0 +1,28 @@
+package models
+
+// Tenant is a caller whose messages are kept in an index of their own,
+// apart from the shared index and from other tenants
+type Tenant struct {
+	ID        string `json:"id"`
+	Name      string `json:"name,omitempty"`
+	Index     string `json:"index"`                // Index holding the tenant's messages
+	CreatedBy string `json:"created_by,omitempty"` // Caller that created the tenant
+	CreatedAt int64  `json:"created_at"`           // Unix time the tenant was created
+}
+
+// TenantRequest creates a tenant
+type TenantRequest struct {
+	ID   string `json:"id" binding:"required,max=32"`
+	Name string `json:"name" binding:"max=200"`
+}
+
+// TenantUpdateRequest renames a tenant
+type TenantUpdateRequest struct {
+	Name string `json:"name" binding:"max=200"`
+}
+
+// TenantCreated holds a new tenant and its API key, which is only shown once
+type TenantCreated struct {
+	Tenant
+	APIKey string `json:"api_key"`
+}
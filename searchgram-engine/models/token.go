@@ -0,0 +1,17 @@
+package models
+
+// MintTokenRequest asks for a JWT on behalf of a service
+type MintTokenRequest struct {
+	Issuer     string `json:"issuer" binding:"required"`             // Service the token identifies, e.g. "userbot"
+	Audience   string `json:"audience,omitempty"`                    // Empty = this engine's audience
+	Role       string `json:"role,omitempty"`                        // Credential role: reader, writer or admin (empty = not restricted by role)
+	TTLSeconds int    `json:"ttl_seconds" binding:"omitempty,min=0"` // Lifetime of the token (0 = server default, capped by the server maximum)
+}
+
+// MintTokenResponse carries a minted JWT
+type MintTokenResponse struct {
+	Token     string `json:"token"`
+	Issuer    string `json:"issuer"`
+	Role      string `json:"role,omitempty"`
+	ExpiresAt int64  `json:"expires_at"` // Unix time after which the token is rejected
+}
@@ -0,0 +1,41 @@
+package models
+
+// Recycle bin operations recorded on trashed messages
+const (
+	TrashOpDeleteChat    = "delete_chat"
+	TrashOpDeleteUser    = "delete_user"
+	TrashOpDeleteMessage = "delete_message"
+	TrashOpDeleteByQuery = "delete_by_query"
+)
+
+// TrashSelector selects the messages a delete moves to the recycle bin.
+// Exactly one field is set.
+type TrashSelector struct {
+	ChatID    *int64         // All messages of a chat
+	UserID    *int64         // All messages sent by a user
+	MessageID string         // A single message by composite ID
+	Query     *SearchRequest // Messages matching search filters
+}
+
+// TrashBatch describes messages moved to the recycle bin by one delete
+type TrashBatch struct {
+	ID        string `json:"id"`
+	Operation string `json:"operation"`        // Delete that trashed the messages, e.g. delete_user
+	Target    string `json:"target,omitempty"` // What was deleted, e.g. user_id=123
+	Count     int64  `json:"count"`            // Messages in the batch
+	TrashedAt int64  `json:"trashed_at"`       // Unix time of the delete
+	ExpiresAt int64  `json:"expires_at"`       // Unix time after which the batch is purged
+}
+
+// TrashListResponse lists recycle bin batches, most recent first
+type TrashListResponse struct {
+	Batches []TrashBatch `json:"batches"`
+	Total   int          `json:"total"`
+}
+
+// RestoreResponse represents the result of restoring from the recycle bin
+type RestoreResponse struct {
+	Success       bool  `json:"success"`
+	RestoredCount int64 `json:"restored_count"`
+	SkippedCount  int64 `json:"skipped_count"` // Messages not restored because they were indexed again since
+}
@@ -0,0 +1,25 @@
+package models
+
+// User export formats
+const (
+	UserExportNDJSON = "ndjson"
+	UserExportZip    = "zip"
+)
+
+// UserExportSummary describes a zip export of a user's messages; it is
+// written last, as export.json
+type UserExportSummary struct {
+	UserID     int64            `json:"user_id"`
+	ExportedAt int64            `json:"exported_at"` // Unix time the export started
+	Messages   int64            `json:"messages"`
+	Chats      []UserExportChat `json:"chats"`
+	Complete   bool             `json:"complete"`        // False when the export stopped early
+	Error      string           `json:"error,omitempty"` // Why it stopped early
+}
+
+// UserExportChat is one chat of a user export, stored as chats/<chat_id>.json
+type UserExportChat struct {
+	ChatID    int64  `json:"chat_id"`
+	ChatTitle string `json:"chat_title,omitempty"`
+	Messages  int64  `json:"messages"`
+}
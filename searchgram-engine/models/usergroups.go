@@ -0,0 +1,48 @@
+package models
+
+// UserGroups lists sets of user IDs that belong to one person, so a request
+// can treat all of that person's accounts as a single user
+type UserGroups [][]int64
+
+// Expand returns userID followed by every ID grouped with it, without duplicates
+func (g UserGroups) Expand(userID int64) []int64 {
+	return g.ExpandAll([]int64{userID})
+}
+
+// ExpandAll returns userIDs followed by every ID grouped with any of them,
+// without duplicates
+func (g UserGroups) ExpandAll(userIDs []int64) []int64 {
+	seen := make(map[int64]bool, len(userIDs))
+	expanded := make([]int64, 0, len(userIDs))
+	add := func(id int64) {
+		if !seen[id] {
+			seen[id] = true
+			expanded = append(expanded, id)
+		}
+	}
+
+	for _, id := range userIDs {
+		add(id)
+	}
+	for _, id := range userIDs {
+		for _, group := range g {
+			if containsID(group, id) {
+				for _, member := range group {
+					add(member)
+				}
+			}
+		}
+	}
+
+	return expanded
+}
+
+// containsID reports whether ids contains id
+func containsID(ids []int64, id int64) bool {
+	for _, candidate := range ids {
+		if candidate == id {
+			return true
+		}
+	}
+	return false
+}
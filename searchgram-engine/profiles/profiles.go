@@ -0,0 +1,193 @@
+package profiles
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/zhishengyuan/searchgram-engine/models"
+	"github.com/zhishengyuan/searchgram-engine/storage"
+)
+
+// ErrNotFound is returned when a caller has no stored profile
+var ErrNotFound = errors.New("search profile not found")
+
+// Store holds the search profile of each caller and persists them on change
+type Store struct {
+	mu       sync.RWMutex
+	profiles map[string]models.SearchProfile
+	file     *storage.Document
+}
+
+// NewStore loads search profiles from file
+func NewStore(file *storage.Document) (*Store, error) {
+	s := &Store{
+		profiles: make(map[string]models.SearchProfile),
+		file:     file,
+	}
+
+	var saved []models.SearchProfile
+	if err := file.Load(&saved); err != nil {
+		return nil, err
+	}
+	for _, profile := range saved {
+		s.profiles[profile.Caller] = profile
+	}
+
+	log.WithFields(log.Fields{
+		"path":     file.Path(),
+		"profiles": len(s.profiles),
+	}).Info("Search profiles loaded")
+
+	return s, nil
+}
+
+// Get returns the profile of a caller
+func (s *Store) Get(caller string) (models.SearchProfile, bool) {
+	if s == nil {
+		return models.SearchProfile{}, false
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	profile, ok := s.profiles[caller]
+	return copyProfile(profile), ok
+}
+
+// List returns all profiles ordered by caller
+func (s *Store) List() []models.SearchProfile {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	list := make([]models.SearchProfile, 0, len(s.profiles))
+	for _, profile := range s.profiles {
+		list = append(list, copyProfile(profile))
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Caller < list[j].Caller
+	})
+	return list
+}
+
+// Put stores the profile of a caller, replacing any previous one
+func (s *Store) Put(caller string, profile models.SearchProfile) (models.SearchProfile, error) {
+	if caller == "" {
+		return models.SearchProfile{}, fmt.Errorf("caller is required")
+	}
+	if !models.ValidSortBy(profile.SortBy) {
+		return models.SearchProfile{}, fmt.Errorf("unsupported sort_by: %s", profile.SortBy)
+	}
+
+	profile = copyProfile(profile)
+	profile.Caller = caller
+	profile.UpdatedAt = time.Now().Unix()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	previous, existed := s.profiles[caller]
+	s.profiles[caller] = profile
+	if err := s.save(); err != nil {
+		if existed {
+			s.profiles[caller] = previous
+		} else {
+			delete(s.profiles, caller)
+		}
+		return models.SearchProfile{}, err
+	}
+
+	log.WithField("caller", caller).Info("Search profile updated")
+
+	return copyProfile(profile), nil
+}
+
+// Delete removes the profile of a caller
+func (s *Store) Delete(caller string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	profile, ok := s.profiles[caller]
+	if !ok {
+		return ErrNotFound
+	}
+	delete(s.profiles, caller)
+
+	if err := s.save(); err != nil {
+		s.profiles[caller] = profile
+		return err
+	}
+	return nil
+}
+
+// Replace swaps in a complete set of profiles, e.g. from a state import
+func (s *Store) Replace(list []models.SearchProfile) error {
+	if err := Validate(list); err != nil {
+		return err
+	}
+
+	now := time.Now().Unix()
+	profiles := make(map[string]models.SearchProfile, len(list))
+	for _, profile := range list {
+		profile = copyProfile(profile)
+		if profile.UpdatedAt == 0 {
+			profile.UpdatedAt = now
+		}
+		profiles[profile.Caller] = profile
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	previous := s.profiles
+	s.profiles = profiles
+	if err := s.save(); err != nil {
+		s.profiles = previous
+		return err
+	}
+
+	log.WithField("profiles", len(profiles)).Info("Search profiles replaced")
+	return nil
+}
+
+// Validate checks profiles for missing or duplicate callers and
+// unsupported settings
+func Validate(list []models.SearchProfile) error {
+	seen := make(map[string]bool, len(list))
+	for _, profile := range list {
+		if profile.Caller == "" {
+			return fmt.Errorf("caller is required")
+		}
+		if seen[profile.Caller] {
+			return fmt.Errorf("duplicate profile for caller %s", profile.Caller)
+		}
+		seen[profile.Caller] = true
+
+		if !models.ValidSortBy(profile.SortBy) {
+			return fmt.Errorf("profile %s: unsupported sort_by: %s", profile.Caller, profile.SortBy)
+		}
+		if profile.PageSize < 0 || profile.PageSize > 100 {
+			return fmt.Errorf("profile %s: page_size must be between 1 and 100", profile.Caller)
+		}
+	}
+	return nil
+}
+
+// save persists all profiles (caller holds lock)
+func (s *Store) save() error {
+	list := make([]models.SearchProfile, 0, len(s.profiles))
+	for _, profile := range s.profiles {
+		list = append(list, profile)
+	}
+	return s.file.Save(list)
+}
+
+// copyProfile returns a deep copy so callers can't mutate stored state
+func copyProfile(profile models.SearchProfile) models.SearchProfile {
+	profile.BlockedUsers = append([]int64(nil), profile.BlockedUsers...)
+	profile.ExcludedChats = append([]int64(nil), profile.ExcludedChats...)
+	return profile
+}
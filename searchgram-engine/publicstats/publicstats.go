@@ -0,0 +1,226 @@
+package publicstats
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/zhishengyuan/searchgram-engine/models"
+)
+
+// overfetch is how many more terms are requested than published, since
+// terms used by too few users are dropped
+const overfetch = 3
+
+// Source aggregates the messages stats are published from
+type Source interface {
+	ChatActivity(ctx context.Context, req *models.ActivityRequest) (*models.ChatActivity, error)
+	TrendingTerms(ctx context.Context, req *models.TrendingRequest) (*models.TrendingTerms, error)
+}
+
+// Config holds public stats settings
+type Config struct {
+	Chats    []int64       // Chats whose stats are published
+	MinUsers int           // Counts covering fewer distinct users are withheld
+	Epsilon  float64       // Privacy budget per published count (0 = no noise)
+	MaxDays  int           // Longest period that can be requested
+	Secret   string        // Seeds the noise; changing it redraws all noise
+	CacheTTL time.Duration // How long a result is served before it is recomputed
+}
+
+// Publisher serves chat stats that can be published without revealing what
+// individual users did. Each count gets Laplace noise of scale 1/Epsilon,
+// and intervals and terms fewer than MinUsers distinct users account for
+// are withheld.
+//
+// The noise of a count is derived from the secret, what is counted and its
+// true value. Asking again for an unchanged count returns the same noise,
+// so it cannot be averaged away, while a changed count gets fresh noise, so
+// comparing two answers does not reveal the exact change either.
+type Publisher struct {
+	source Source
+	cfg    Config
+	chats  map[int64]bool
+
+	mu    sync.Mutex
+	cache map[string]cacheEntry
+}
+
+// cacheEntry is a published result and when it expires
+type cacheEntry struct {
+	value   interface{}
+	expires time.Time
+}
+
+// New creates a publisher for the stats of cfg.Chats
+func New(source Source, cfg Config) *Publisher {
+	if cfg.MinUsers < 1 {
+		cfg.MinUsers = 1
+	}
+	if cfg.MaxDays < 1 {
+		cfg.MaxDays = 90
+	}
+
+	chats := make(map[int64]bool, len(cfg.Chats))
+	for _, chatID := range cfg.Chats {
+		chats[chatID] = true
+	}
+	return &Publisher{
+		source: source,
+		cfg:    cfg,
+		chats:  chats,
+		cache:  make(map[string]cacheEntry),
+	}
+}
+
+// Published reports whether the stats of a chat are published
+func (p *Publisher) Published(chatID int64) bool {
+	return p.chats[chatID]
+}
+
+// MaxDays returns the longest period that can be requested
+func (p *Publisher) MaxDays() int {
+	return p.cfg.MaxDays
+}
+
+// period returns the Unix time range covering today and the days before
+func period(now time.Time, days int) (from, to int64) {
+	today := now.UTC().Truncate(24 * time.Hour)
+	return today.AddDate(0, 0, 1-days).Unix(), now.Unix()
+}
+
+// Activity returns the published message and sender counts of a chat per
+// interval over the last days
+func (p *Publisher) Activity(ctx context.Context, chatID int64, days int, interval string) (*models.ChatActivity, error) {
+	from, to := period(time.Now(), days)
+	key := fmt.Sprintf("activity:%d:%d:%s", chatID, from, interval)
+	if cached, ok := p.cached(key); ok {
+		return cached.(*models.ChatActivity), nil
+	}
+
+	activity, err := p.source.ChatActivity(ctx, &models.ActivityRequest{
+		ChatID:   chatID,
+		From:     from,
+		To:       to,
+		Interval: interval,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range activity.Buckets {
+		bucket := &activity.Buckets[i]
+		bucketKey := fmt.Sprintf("activity:%d:%s:%d", chatID, interval, bucket.Start)
+		bucket.Senders = p.noisy(bucket.Senders, bucketKey+":senders")
+		bucket.Messages = p.noisy(bucket.Messages, bucketKey+":messages")
+		if bucket.Senders < int64(p.cfg.MinUsers) {
+			*bucket = models.ActivityBucket{Start: bucket.Start, Suppressed: true}
+		}
+	}
+
+	p.store(key, activity)
+	return activity, nil
+}
+
+// Trending returns up to limit published hashtags and words each that a chat
+// used unusually often over the last days
+func (p *Publisher) Trending(ctx context.Context, chatID int64, days, limit int) (*models.TrendingTerms, error) {
+	from, to := period(time.Now(), days)
+	key := fmt.Sprintf("trending:%d:%d:%d", chatID, from, limit)
+	if cached, ok := p.cached(key); ok {
+		return cached.(*models.TrendingTerms), nil
+	}
+
+	trending, err := p.source.TrendingTerms(ctx, &models.TrendingRequest{
+		ChatID: chatID,
+		From:   from,
+		To:     to,
+		Size:   limit * overfetch,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	termsKey := fmt.Sprintf("trending:%d:%d", chatID, from)
+	trending.Hashtags = p.publishTerms(trending.Hashtags, termsKey+":hashtag", limit)
+	trending.Words = p.publishTerms(trending.Words, termsKey+":word", limit)
+
+	p.store(key, trending)
+	return trending, nil
+}
+
+// publishTerms adds noise to the counts of terms and drops the terms too
+// few users account for, keeping the order and at most limit terms
+func (p *Publisher) publishTerms(terms []models.TermCount, key string, limit int) []models.TermCount {
+	published := make([]models.TermCount, 0, limit)
+	for _, term := range terms {
+		if len(published) == limit {
+			break
+		}
+		termKey := key + ":" + term.Term
+		term.Senders = p.noisy(term.Senders, termKey+":senders")
+		if term.Senders < int64(p.cfg.MinUsers) {
+			continue
+		}
+		term.Messages = p.noisy(term.Messages, termKey+":messages")
+		published = append(published, term)
+	}
+	return published
+}
+
+// noisy adds Laplace noise to a count, derived from the secret, the key of
+// what is counted and the count itself
+func (p *Publisher) noisy(count int64, key string) int64 {
+	if p.cfg.Epsilon <= 0 {
+		return count
+	}
+
+	mac := hmac.New(sha256.New, []byte(p.cfg.Secret))
+	fmt.Fprintf(mac, "%s:%d", key, count)
+	sum := mac.Sum(nil)
+
+	// Uniform in (-0.5, 0.5), mapped through the inverse Laplace CDF
+	u := (float64(binary.BigEndian.Uint64(sum)>>11)+0.5)/(1<<53) - 0.5
+	noise := -math.Copysign(1/p.cfg.Epsilon, u) * math.Log(1-2*math.Abs(u))
+
+	noisy := int64(math.Round(float64(count) + noise))
+	if noisy < 0 {
+		return 0
+	}
+	return noisy
+}
+
+// cached returns an unexpired result stored under key
+func (p *Publisher) cached(key string) (interface{}, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	entry, ok := p.cache[key]
+	if !ok || time.Now().After(entry.expires) {
+		return nil, false
+	}
+	return entry.value, true
+}
+
+// store caches a result under key, dropping expired results
+func (p *Publisher) store(key string, value interface{}) {
+	if p.cfg.CacheTTL <= 0 {
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := time.Now()
+	for k, entry := range p.cache {
+		if now.After(entry.expires) {
+			delete(p.cache, k)
+		}
+	}
+	p.cache[key] = cacheEntry{value: value, expires: now.Add(p.cfg.CacheTTL)}
+}
@@ -0,0 +1,105 @@
+package recyclebin
+
+import (
+	"context"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/zhishengyuan/searchgram-engine/engines"
+	"github.com/zhishengyuan/searchgram-engine/models"
+)
+
+// Config holds recycle bin configuration
+type Config struct {
+	TTL           time.Duration // How long deleted messages stay restorable
+	PurgeInterval time.Duration // How often expired messages are purged
+}
+
+// Bin keeps deleted messages restorable in the engine's trash until they expire
+type Bin struct {
+	engine engines.SearchEngine
+	cfg    Config
+	stop   chan struct{}
+	done   chan struct{}
+}
+
+// New creates a recycle bin backed by the engine
+func New(engine engines.SearchEngine, cfg Config) *Bin {
+	if cfg.TTL <= 0 {
+		cfg.TTL = 7 * 24 * time.Hour
+	}
+	if cfg.PurgeInterval <= 0 {
+		cfg.PurgeInterval = time.Hour
+	}
+
+	return &Bin{
+		engine: engine,
+		cfg:    cfg,
+	}
+}
+
+// Move moves the selected messages to the trash as one batch.
+// operation and target describe the delete, e.g. delete_user and user_id=123.
+func (b *Bin) Move(ctx context.Context, selector models.TrashSelector, operation, target string) (*models.TrashBatch, error) {
+	return b.engine.MoveToTrash(ctx, selector, operation, target, b.cfg.TTL)
+}
+
+// List lists trash batches, most recently deleted first
+func (b *Bin) List(ctx context.Context) ([]models.TrashBatch, error) {
+	return b.engine.ListTrash(ctx)
+}
+
+// RestoreBatch restores every message of a batch
+func (b *Bin) RestoreBatch(ctx context.Context, batchID string) (int64, int64, error) {
+	return b.engine.RestoreTrashBatch(ctx, batchID)
+}
+
+// RestoreMessage restores a single message by composite ID
+func (b *Bin) RestoreMessage(ctx context.Context, id string) (int64, int64, error) {
+	return b.engine.RestoreTrashMessage(ctx, id)
+}
+
+// Start purges expired messages now and then every purge interval
+func (b *Bin) Start() {
+	b.stop = make(chan struct{})
+	b.done = make(chan struct{})
+
+	go func() {
+		defer close(b.done)
+
+		b.Purge()
+
+		ticker := time.NewTicker(b.cfg.PurgeInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				b.Purge()
+			case <-b.stop:
+				return
+			}
+		}
+	}()
+
+	log.WithFields(log.Fields{
+		"ttl":            b.cfg.TTL.String(),
+		"purge_interval": b.cfg.PurgeInterval.String(),
+	}).Info("Recycle bin enabled")
+}
+
+// Stop stops purging
+func (b *Bin) Stop() {
+	if b == nil || b.stop == nil {
+		return
+	}
+	close(b.stop)
+	<-b.done
+}
+
+// Purge permanently removes expired messages from the trash
+func (b *Bin) Purge() {
+	if _, _, err := b.engine.PurgeTrash(context.Background(), time.Now(), nil); err != nil {
+		log.WithError(err).Warn("Failed to purge recycle bin, will retry next interval")
+	}
+}
@@ -0,0 +1,308 @@
+package replication
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/zhishengyuan/searchgram-engine/engines"
+	"github.com/zhishengyuan/searchgram-engine/models"
+)
+
+// Engine records the successful writes of the primary engine for
+// replication, so every path that writes (API, ingest queue, consumers,
+// backfills, retention) reaches the secondary. Reads pass through.
+type Engine struct {
+	engines.SearchEngine
+	replicator *Replicator
+}
+
+// Upsert indexes a message
+func (e *Engine) Upsert(ctx context.Context, message *models.Message) error {
+	if err := e.SearchEngine.Upsert(ctx, message); err != nil {
+		return err
+	}
+	e.replicator.recordMessages([]models.Message{*message})
+	return nil
+}
+
+// UpsertBatch indexes messages; those the primary refused are not replicated
+func (e *Engine) UpsertBatch(ctx context.Context, messages []models.Message) (int, []string, error) {
+	indexed, failures, err := e.SearchEngine.UpsertBatch(ctx, messages)
+	if err != nil {
+		return indexed, failures, err
+	}
+
+	written := messages
+	if len(failures) > 0 {
+		written = make([]models.Message, 0, len(messages))
+		for _, message := range messages {
+			if !mentions(failures, message.ID) {
+				written = append(written, message)
+			}
+		}
+	}
+	e.replicator.recordMessages(written)
+	return indexed, failures, nil
+}
+
+// mentions reports whether any failure names the document
+// ("Document <id> failed ...")
+func mentions(failures []string, id string) bool {
+	for _, failure := range failures {
+		if strings.Contains(failure, "Document "+id+" ") {
+			return true
+		}
+	}
+	return false
+}
+
+// UpdateMessage edits a message; the edited message is replicated whole
+func (e *Engine) UpdateMessage(ctx context.Context, id string, fn func(message *models.Message)) (*models.Message, error) {
+	message, err := e.SearchEngine.UpdateMessage(ctx, id, fn)
+	if err == nil && message != nil {
+		e.replicator.recordMessages([]models.Message{*message})
+	}
+	return message, err
+}
+
+// Delete soft-deletes a chat's messages
+func (e *Engine) Delete(ctx context.Context, chatID int64) (int64, error) {
+	count, err := e.SearchEngine.Delete(ctx, chatID)
+	if err == nil {
+		e.replicator.recordApply(func(secondary engines.SearchEngine) error {
+			_, err := secondary.Delete(ctx, chatID)
+			return err
+		})
+	}
+	return count, err
+}
+
+// DeleteMessage removes a single message
+func (e *Engine) DeleteMessage(ctx context.Context, id string) (bool, error) {
+	deleted, err := e.SearchEngine.DeleteMessage(ctx, id)
+	if err == nil && deleted {
+		e.replicator.recordApply(func(secondary engines.SearchEngine) error {
+			_, err := secondary.DeleteMessage(ctx, id)
+			return err
+		})
+	}
+	return deleted, err
+}
+
+// DeleteByQuery removes matching messages; dry runs are not replicated
+func (e *Engine) DeleteByQuery(req *models.SearchRequest, dryRun bool) (int64, error) {
+	count, err := e.SearchEngine.DeleteByQuery(req, dryRun)
+	if err == nil && !dryRun {
+		query := *req
+		e.replicator.recordApply(func(secondary engines.SearchEngine) error {
+			_, err := secondary.DeleteByQuery(&query, false)
+			return err
+		})
+	}
+	return count, err
+}
+
+// SoftDeleteByQuery marks matching messages as deleted
+func (e *Engine) SoftDeleteByQuery(req *models.SearchRequest) (int64, error) {
+	count, err := e.SearchEngine.SoftDeleteByQuery(req)
+	if err == nil {
+		query := *req
+		e.replicator.recordApply(func(secondary engines.SearchEngine) error {
+			_, err := secondary.SoftDeleteByQuery(&query)
+			return err
+		})
+	}
+	return count, err
+}
+
+// RestoreDeleted clears the deleted mark; dry runs are not replicated
+func (e *Engine) RestoreDeleted(filter *models.DeletedFilter, dryRun bool) (int64, error) {
+	count, err := e.SearchEngine.RestoreDeleted(filter, dryRun)
+	if err == nil && !dryRun {
+		selected := *filter
+		e.replicator.recordApply(func(secondary engines.SearchEngine) error {
+			_, err := secondary.RestoreDeleted(&selected, false)
+			return err
+		})
+	}
+	return count, err
+}
+
+// PurgeDeleted removes soft-deleted messages; dry runs are not replicated
+func (e *Engine) PurgeDeleted(filter *models.DeletedFilter, dryRun bool) (int64, error) {
+	count, err := e.SearchEngine.PurgeDeleted(filter, dryRun)
+	if err == nil && !dryRun {
+		selected := *filter
+		e.replicator.recordApply(func(secondary engines.SearchEngine) error {
+			_, err := secondary.PurgeDeleted(&selected, false)
+			return err
+		})
+	}
+	return count, err
+}
+
+// DeleteUser removes a user's messages
+func (e *Engine) DeleteUser(ctx context.Context, userID int64) (int64, error) {
+	count, err := e.SearchEngine.DeleteUser(ctx, userID)
+	if err == nil {
+		e.replicator.recordApply(func(secondary engines.SearchEngine) error {
+			_, err := secondary.DeleteUser(ctx, userID)
+			return err
+		})
+	}
+	return count, err
+}
+
+// MoveToTrash moves messages to the recycle bin. The secondary keeps its
+// own recycle bin; its batch is remembered for restores.
+func (e *Engine) MoveToTrash(ctx context.Context, selector models.TrashSelector, operation, target string, ttl time.Duration) (*models.TrashBatch, error) {
+	batch, err := e.SearchEngine.MoveToTrash(ctx, selector, operation, target, ttl)
+	if err != nil || batch == nil {
+		return batch, err
+	}
+
+	if selector.Query != nil {
+		query := *selector.Query
+		selector.Query = &query
+	}
+	primaryID := batch.ID
+	e.replicator.recordApply(func(secondary engines.SearchEngine) error {
+		replica, err := secondary.MoveToTrash(context.Background(), selector, operation, target, ttl)
+		if err != nil {
+			return err
+		}
+		e.replicator.rememberTrashBatch(primaryID, replica.ID)
+		return nil
+	})
+	return batch, nil
+}
+
+// RestoreTrashBatch restores a recycle bin batch. Batches trashed before
+// the engine started or the last resync are unknown to the secondary, so
+// restoring them leaves it out of sync.
+func (e *Engine) RestoreTrashBatch(ctx context.Context, batchID string) (int64, int64, error) {
+	restored, skipped, err := e.SearchEngine.RestoreTrashBatch(ctx, batchID)
+	if err == nil && restored > 0 {
+		e.replicator.recordApply(func(secondary engines.SearchEngine) error {
+			replicaID, ok := e.replicator.trashBatch(batchID)
+			if !ok {
+				e.replicator.markOutOfSync(fmt.Sprintf("recycle bin batch %s was restored but is unknown to the secondary", batchID))
+				return nil
+			}
+			_, _, err := secondary.RestoreTrashBatch(context.Background(), replicaID)
+			return err
+		})
+	}
+	return restored, skipped, err
+}
+
+// RestoreTrashMessage restores a single message from the recycle bin
+func (e *Engine) RestoreTrashMessage(ctx context.Context, id string) (int64, int64, error) {
+	restored, skipped, err := e.SearchEngine.RestoreTrashMessage(ctx, id)
+	if err == nil && restored > 0 {
+		e.replicator.recordApply(func(secondary engines.SearchEngine) error {
+			_, _, err := secondary.RestoreTrashMessage(context.Background(), id)
+			return err
+		})
+	}
+	return restored, skipped, err
+}
+
+// PurgeTrash removes expired recycle bin messages
+func (e *Engine) PurgeTrash(ctx context.Context, now time.Time, keepChats []int64) (int64, []string, error) {
+	purged, kept, err := e.SearchEngine.PurgeTrash(ctx, now, keepChats)
+	if err == nil {
+		e.replicator.recordApply(func(secondary engines.SearchEngine) error {
+			_, _, err := secondary.PurgeTrash(context.Background(), now, keepChats)
+			return err
+		})
+	}
+	return purged, kept, err
+}
+
+// PauseLifecycle detaches or attaches the partition lifecycle policy
+func (e *Engine) PauseLifecycle(ctx context.Context, paused bool) (int, error) {
+	changed, err := e.SearchEngine.PauseLifecycle(ctx, paused)
+	if err == nil {
+		e.replicator.recordApply(func(secondary engines.SearchEngine) error {
+			_, err := secondary.PauseLifecycle(context.Background(), paused)
+			return err
+		})
+	}
+	return changed, err
+}
+
+// Clear removes all documents
+func (e *Engine) Clear(ctx context.Context) error {
+	err := e.SearchEngine.Clear(ctx)
+	if err == nil {
+		e.replicator.recordApply(func(secondary engines.SearchEngine) error {
+			return secondary.Clear(context.Background())
+		})
+	}
+	return err
+}
+
+// RestoreSnapshot replaces the index with a snapshot only the primary can
+// read, so the secondary needs a resync afterwards
+func (e *Engine) RestoreSnapshot(ctx context.Context, repository, snapshot string) error {
+	err := e.SearchEngine.RestoreSnapshot(ctx, repository, snapshot)
+	if err == nil {
+		e.replicator.markOutOfSync(fmt.Sprintf("snapshot %s was restored on the primary", snapshot))
+	}
+	return err
+}
+
+// RecreateIndex drops and recreates the index
+func (e *Engine) RecreateIndex(ctx context.Context) error {
+	err := e.SearchEngine.RecreateIndex(ctx)
+	if err == nil {
+		e.replicator.recordApply(func(secondary engines.SearchEngine) error {
+			return secondary.RecreateIndex(context.Background())
+		})
+	}
+	return err
+}
+
+// Dedup removes duplicate messages; dry runs are not replicated
+func (e *Engine) Dedup(ctx context.Context, dryRun bool, progress func(*models.DedupResponse)) (*models.DedupResponse, error) {
+	result, err := e.SearchEngine.Dedup(ctx, dryRun, progress)
+	if err == nil && !dryRun {
+		e.replicator.recordApply(func(secondary engines.SearchEngine) error {
+			_, err := secondary.Dedup(context.Background(), false, nil)
+			return err
+		})
+	}
+	return result, err
+}
+
+// SoftDeleteMessage marks a message as deleted. The marked message is
+// replicated whole, as the secondary may not have it yet.
+func (e *Engine) SoftDeleteMessage(ctx context.Context, chatID int64, messageID int64) error {
+	if err := e.SearchEngine.SoftDeleteMessage(ctx, chatID, messageID); err != nil {
+		return err
+	}
+
+	id := models.MessageDocumentID(chatID, messageID)
+	message, err := e.SearchEngine.GetMessage(ctx, id)
+	if err != nil || message == nil {
+		e.replicator.markOutOfSync(fmt.Sprintf("soft-deleted message %s could not be read back", id))
+		return nil
+	}
+	e.replicator.recordMessages([]models.Message{*message})
+	return nil
+}
+
+// CleanCommands removes bot command messages
+func (e *Engine) CleanCommands(ctx context.Context) (*models.CleanCommandsResponse, error) {
+	result, err := e.SearchEngine.CleanCommands(ctx)
+	if err == nil {
+		e.replicator.recordApply(func(secondary engines.SearchEngine) error {
+			_, err := secondary.CleanCommands(ctx)
+			return err
+		})
+	}
+	return result, err
+}
@@ -0,0 +1,477 @@
+package replication
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/zhishengyuan/searchgram-engine/engines"
+	"github.com/zhishengyuan/searchgram-engine/models"
+	"github.com/zhishengyuan/searchgram-engine/storage"
+)
+
+// ErrResyncRunning is returned when a resync is requested while one runs
+var ErrResyncRunning = errors.New("a resync is already running")
+
+// maxResyncFailures limits the failures a resync reports
+const maxResyncFailures = 100
+
+// Config holds replication configuration
+type Config struct {
+	QueueSize  int           // Maximum buffered operations; more are dropped and need a resync
+	BatchSize  int           // Maximum messages per bulk write to the secondary
+	MaxBackoff time.Duration // Longest pause between retries of a failing operation
+}
+
+// op is a successful write on the primary, replayed on the secondary
+type op struct {
+	at       time.Time
+	messages []models.Message                           // Messages to index, batched with adjacent upserts
+	apply    func(secondary engines.SearchEngine) error // Any other write
+}
+
+// state is what survives restarts: whether the secondary needs a resync
+type state struct {
+	OutOfSync string `json:"out_of_sync"`
+}
+
+// Replicator applies the writes of the primary engine to a secondary one in
+// the background, in the order they were made. A failing secondary is
+// retried with backoff while writes queue up; writes that do not fit the
+// queue, or are still queued at shutdown, are lost and leave the secondary
+// out of sync until a resync copies everything again.
+type Replicator struct {
+	connect func() (engines.SearchEngine, error)
+	file    *storage.Document
+	cfg     Config
+	primary engines.SearchEngine
+
+	mu            sync.Mutex
+	secondary     engines.SearchEngine
+	queue         []op
+	outOfSync     string
+	resyncing     bool
+	trashBatches  map[string]string // Primary recycle bin batch -> secondary batch
+	enqueued      int64
+	applied       int64
+	dropped       int64
+	retries       int64
+	lastAppliedAt time.Time
+	lastError     string
+
+	applyMu sync.Mutex // Held while applying; a resync holds it throughout
+
+	wake chan struct{}
+	stop chan struct{}
+	done chan struct{}
+}
+
+// New creates a replicator writing to the engine returned by connect, which
+// is retried until it succeeds. The out-of-sync state is kept in file; a
+// replicator without one starts out of sync, as nothing was copied yet.
+func New(connect func() (engines.SearchEngine, error), file *storage.Document, cfg Config) (*Replicator, error) {
+	if cfg.QueueSize <= 0 {
+		cfg.QueueSize = 100000
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 500
+	}
+	if cfg.MaxBackoff <= 0 {
+		cfg.MaxBackoff = time.Minute
+	}
+
+	saved := state{OutOfSync: "no initial copy has been made"}
+	if err := file.Load(&saved); err != nil {
+		return nil, err
+	}
+
+	return &Replicator{
+		connect:      connect,
+		file:         file,
+		cfg:          cfg,
+		outOfSync:    saved.OutOfSync,
+		trashBatches: make(map[string]string),
+		wake:         make(chan struct{}, 1),
+	}, nil
+}
+
+// Wrap returns primary with its writes recorded for replication
+func (r *Replicator) Wrap(primary engines.SearchEngine) engines.SearchEngine {
+	r.primary = primary
+	return &Engine{SearchEngine: primary, replicator: r}
+}
+
+// Stats returns replication counters and lag
+func (r *Replicator) Stats() *models.ReplicationStats {
+	if r == nil {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stats := &models.ReplicationStats{
+		Connected: r.secondary != nil,
+		InSync:    r.outOfSync == "",
+		OutOfSync: r.outOfSync,
+		Resyncing: r.resyncing,
+		Pending:   len(r.queue),
+		Capacity:  r.cfg.QueueSize,
+		Enqueued:  r.enqueued,
+		Applied:   r.applied,
+		Dropped:   r.dropped,
+		Retries:   r.retries,
+		LastError: r.lastError,
+	}
+	if len(r.queue) > 0 {
+		stats.LagSeconds = time.Since(r.queue[0].at).Seconds()
+	}
+	if !r.lastAppliedAt.IsZero() {
+		stats.LastAppliedAt = r.lastAppliedAt.Unix()
+	}
+	return stats
+}
+
+// Start begins applying recorded writes to the secondary
+func (r *Replicator) Start() {
+	r.stop = make(chan struct{})
+	r.done = make(chan struct{})
+
+	go r.run()
+
+	log.WithFields(log.Fields{
+		"queue_size": r.cfg.QueueSize,
+		"in_sync":    r.Stats().InSync,
+	}).Info("Replication to secondary engine enabled")
+}
+
+// Stop stops the background worker and applies what is still queued until
+// ctx expires. Writes left over are lost and mark the secondary out of sync.
+func (r *Replicator) Stop(ctx context.Context) {
+	if r == nil || r.stop == nil {
+		return
+	}
+	close(r.stop)
+	<-r.done
+
+	for ctx.Err() == nil {
+		applied, err := r.applyNext()
+		if err != nil || applied == 0 {
+			break
+		}
+	}
+
+	r.mu.Lock()
+	pending := len(r.queue)
+	r.mu.Unlock()
+	if pending > 0 {
+		log.WithField("pending", pending).Warn("Replication stopped with unapplied writes, a resync is needed")
+		r.markOutOfSync(fmt.Sprintf("%d writes were not replicated before shutdown", pending))
+	}
+
+	if r.secondary != nil {
+		r.secondary.Close()
+	}
+}
+
+// record queues a write for the secondary
+func (r *Replicator) record(o op) {
+	o.at = time.Now()
+
+	r.mu.Lock()
+	if len(r.queue) >= r.cfg.QueueSize {
+		r.dropped++
+		r.mu.Unlock()
+		r.markOutOfSync("the replication queue overflowed")
+		return
+	}
+	r.queue = append(r.queue, o)
+	r.enqueued++
+	r.mu.Unlock()
+
+	select {
+	case r.wake <- struct{}{}:
+	default:
+	}
+}
+
+// recordMessages queues messages to be indexed on the secondary
+func (r *Replicator) recordMessages(messages []models.Message) {
+	if len(messages) == 0 {
+		return
+	}
+	r.record(op{messages: append([]models.Message(nil), messages...)})
+}
+
+// recordApply queues any other write for the secondary
+func (r *Replicator) recordApply(apply func(secondary engines.SearchEngine) error) {
+	r.record(op{apply: apply})
+}
+
+// markOutOfSync records that the secondary misses writes until a resync
+func (r *Replicator) markOutOfSync(reason string) {
+	r.mu.Lock()
+	if r.outOfSync != "" {
+		r.mu.Unlock()
+		return
+	}
+	r.outOfSync = reason
+	r.mu.Unlock()
+
+	log.WithField("reason", reason).Warn("Secondary engine is out of sync, run a resync")
+	if err := r.file.Save(state{OutOfSync: reason}); err != nil {
+		log.WithError(err).Error("Failed to save replication state")
+	}
+}
+
+// run applies queued writes, retrying failures with exponential backoff
+func (r *Replicator) run() {
+	defer close(r.done)
+
+	backoff := time.Second
+	for {
+		applied, err := r.applyNext()
+		if err != nil {
+			r.mu.Lock()
+			r.retries++
+			r.lastError = err.Error()
+			r.mu.Unlock()
+			log.WithError(err).WithField("retry_in", backoff.String()).Warn("Replication to secondary engine failed")
+
+			select {
+			case <-time.After(backoff):
+			case <-r.stop:
+				return
+			}
+			backoff = min(backoff*2, r.cfg.MaxBackoff)
+			continue
+		}
+		backoff = time.Second
+
+		// Stop leaves the rest to be drained within its deadline
+		select {
+		case <-r.stop:
+			return
+		default:
+		}
+		if applied == 0 {
+			select {
+			case <-r.wake:
+			case <-r.stop:
+				return
+			}
+		}
+	}
+}
+
+// applyNext applies the next queued write, or the next run of upserts as
+// one bulk request, and returns how many operations it applied
+func (r *Replicator) applyNext() (int, error) {
+	r.applyMu.Lock()
+	defer r.applyMu.Unlock()
+
+	r.mu.Lock()
+	batch := r.nextBatch()
+	r.mu.Unlock()
+	if len(batch) == 0 {
+		return 0, nil
+	}
+
+	secondary, err := r.connected()
+	if err != nil {
+		return 0, err
+	}
+
+	if batch[0].apply != nil {
+		err = batch[0].apply(secondary)
+	} else {
+		err = r.applyMessages(secondary, batch)
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	r.mu.Lock()
+	r.queue = r.queue[len(batch):]
+	if len(r.queue) == 0 {
+		r.queue = nil
+	}
+	r.applied += int64(len(batch))
+	r.lastAppliedAt = time.Now()
+	r.lastError = ""
+	r.mu.Unlock()
+
+	return len(batch), nil
+}
+
+// nextBatch returns the head of the queue: a single write, or adjacent
+// upserts up to the batch size (caller holds mu)
+func (r *Replicator) nextBatch() []op {
+	if len(r.queue) == 0 {
+		return nil
+	}
+	if r.queue[0].apply != nil {
+		return r.queue[:1]
+	}
+
+	n, size := 0, 0
+	for n < len(r.queue) && r.queue[n].apply == nil {
+		if n > 0 && size+len(r.queue[n].messages) > r.cfg.BatchSize {
+			break
+		}
+		size += len(r.queue[n].messages)
+		n++
+	}
+	return r.queue[:n:n]
+}
+
+// applyMessages indexes the messages of a run of upserts on the secondary.
+// Messages the secondary refuses are logged; retrying would not help.
+func (r *Replicator) applyMessages(secondary engines.SearchEngine, batch []op) error {
+	var messages []models.Message
+	for _, o := range batch {
+		messages = append(messages, o.messages...)
+	}
+
+	_, failures, err := secondary.UpsertBatch(context.Background(), messages)
+	if err != nil {
+		return err
+	}
+	if len(failures) > 0 {
+		log.WithFields(log.Fields{
+			"failed": len(failures),
+			"first":  failures[0],
+		}).Warn("Secondary engine refused replicated messages")
+	}
+	return nil
+}
+
+// connected returns the secondary engine, connecting first if needed
+func (r *Replicator) connected() (engines.SearchEngine, error) {
+	r.mu.Lock()
+	secondary := r.secondary
+	r.mu.Unlock()
+	if secondary != nil {
+		return secondary, nil
+	}
+
+	secondary, err := r.connect()
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to secondary engine: %w", err)
+	}
+
+	r.mu.Lock()
+	r.secondary = secondary
+	r.mu.Unlock()
+
+	log.Info("Connected to secondary engine")
+	return secondary, nil
+}
+
+// Resync replaces the contents of the secondary with every message of the
+// primary. Writes queued before it starts are already on the primary and
+// are discarded; writes made meanwhile queue up and are applied afterwards.
+// progress, if non-nil, receives running totals after each page.
+func (r *Replicator) Resync(ctx context.Context, progress func(*models.ReplicationResyncResult)) (*models.ReplicationResyncResult, error) {
+	r.mu.Lock()
+	if r.resyncing {
+		r.mu.Unlock()
+		return nil, ErrResyncRunning
+	}
+	r.resyncing = true
+	r.mu.Unlock()
+
+	r.applyMu.Lock()
+	defer r.applyMu.Unlock()
+
+	r.mu.Lock()
+	discarded := len(r.queue)
+	r.queue = nil
+	r.trashBatches = make(map[string]string)
+	previous := r.outOfSync
+	r.outOfSync = "" // Writes lost from now on mark it again
+	r.mu.Unlock()
+
+	log.WithField("discarded", discarded).Info("Resyncing secondary engine...")
+
+	result, err := r.copyAll(ctx, progress)
+
+	r.mu.Lock()
+	r.resyncing = false
+	if err != nil && r.outOfSync == "" {
+		r.outOfSync = previous
+		if previous == "" {
+			r.outOfSync = "the last resync did not finish"
+		}
+	}
+	reason := r.outOfSync
+	r.mu.Unlock()
+
+	if saveErr := r.file.Save(state{OutOfSync: reason}); saveErr != nil {
+		log.WithError(saveErr).Error("Failed to save replication state")
+	}
+	if err != nil {
+		return result, err
+	}
+
+	log.WithField("copied", result.CopiedCount).Info("Secondary engine resynced")
+	return result, nil
+}
+
+// copyAll clears the secondary and copies every primary message to it
+func (r *Replicator) copyAll(ctx context.Context, progress func(*models.ReplicationResyncResult)) (*models.ReplicationResyncResult, error) {
+	result := &models.ReplicationResyncResult{}
+
+	secondary, err := r.connected()
+	if err != nil {
+		return result, err
+	}
+	if err := secondary.Clear(ctx); err != nil {
+		return result, fmt.Errorf("failed to clear secondary engine: %w", err)
+	}
+
+	err = r.primary.ScanMessages(ctx, func(messages []models.Message) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		for start := 0; start < len(messages); start += r.cfg.BatchSize {
+			end := min(start+r.cfg.BatchSize, len(messages))
+			indexed, failures, err := secondary.UpsertBatch(ctx, messages[start:end])
+			if err != nil {
+				return fmt.Errorf("failed to copy messages to secondary engine: %w", err)
+			}
+			result.CopiedCount += int64(indexed)
+			for _, failure := range failures {
+				if len(result.Failures) < maxResyncFailures {
+					result.Failures = append(result.Failures, failure)
+				}
+			}
+		}
+
+		if progress != nil {
+			snapshot := *result
+			snapshot.Failures = append([]string(nil), result.Failures...)
+			progress(&snapshot)
+		}
+		return nil
+	})
+	return result, err
+}
+
+// rememberTrashBatch maps a primary recycle bin batch to the secondary's
+func (r *Replicator) rememberTrashBatch(primaryID, secondaryID string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.trashBatches[primaryID] = secondaryID
+}
+
+// trashBatch returns the secondary batch of a primary recycle bin batch
+func (r *Replicator) trashBatch(primaryID string) (string, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	id, ok := r.trashBatches[primaryID]
+	return id, ok
+}
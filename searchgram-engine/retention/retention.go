@@ -0,0 +1,367 @@
+package retention
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/zhishengyuan/searchgram-engine/engines"
+	"github.com/zhishengyuan/searchgram-engine/models"
+	"github.com/zhishengyuan/searchgram-engine/storage"
+)
+
+// ErrNotFound is returned when a chat has no policy set via the API
+var ErrNotFound = errors.New("retention policy not found")
+
+// Config holds retention configuration
+type Config struct {
+	DefaultDays   int           // Days to keep messages of chats without a policy (0 = forever)
+	Chats         map[int64]int // Configured per-chat retention in days (0 = forever)
+	PurgeInterval time.Duration // How often expired messages are purged (0 = only on request)
+}
+
+// Manager holds per-chat retention policies and purges expired messages.
+// Policies set via the API are persisted and override configured ones.
+type Manager struct {
+	engine engines.SearchEngine
+	file   *storage.Document
+	cfg    Config
+
+	mu       sync.RWMutex
+	policies map[int64]models.RetentionPolicy
+
+	purgeMu sync.Mutex // One purge at a time
+	stop    chan struct{}
+	done    chan struct{}
+}
+
+// New loads the policies set via the API from file
+func New(engine engines.SearchEngine, file *storage.Document, cfg Config) (*Manager, error) {
+	m := &Manager{
+		engine:   engine,
+		file:     file,
+		cfg:      cfg,
+		policies: make(map[int64]models.RetentionPolicy),
+	}
+
+	var saved []models.RetentionPolicy
+	if err := file.Load(&saved); err != nil {
+		return nil, err
+	}
+	for _, policy := range saved {
+		policy.Source = models.RetentionSourceAPI
+		m.policies[policy.ChatID] = policy
+	}
+
+	log.WithFields(log.Fields{
+		"path":     file.Path(),
+		"policies": len(m.policies),
+	}).Info("Retention policies loaded")
+
+	return m, nil
+}
+
+// List returns the default retention and every chat policy ordered by chat ID
+func (m *Manager) List() models.RetentionPolicies {
+	policies := m.effective()
+
+	list := make([]models.RetentionPolicy, 0, len(policies))
+	for _, policy := range policies {
+		list = append(list, policy)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].ChatID < list[j].ChatID
+	})
+
+	return models.RetentionPolicies{
+		DefaultDays: m.cfg.DefaultDays,
+		Policies:    list,
+	}
+}
+
+// Days returns how many days the messages of a chat are kept (0 = forever)
+func (m *Manager) Days(chatID int64) int {
+	if policy, ok := m.effective()[chatID]; ok {
+		return policy.Days
+	}
+	return m.cfg.DefaultDays
+}
+
+// Overrides returns the policies set via the API ordered by chat ID
+func (m *Manager) Overrides() []models.RetentionPolicy {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	list := make([]models.RetentionPolicy, 0, len(m.policies))
+	for _, policy := range m.policies {
+		list = append(list, policy)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].ChatID < list[j].ChatID
+	})
+	return list
+}
+
+// Put sets the retention of a chat, overriding any configured policy
+func (m *Manager) Put(chatID int64, days int) (models.RetentionPolicy, error) {
+	if days < 0 {
+		return models.RetentionPolicy{}, fmt.Errorf("retention days must not be negative")
+	}
+
+	policy := models.RetentionPolicy{
+		ChatID:    chatID,
+		Days:      days,
+		Source:    models.RetentionSourceAPI,
+		UpdatedAt: time.Now().Unix(),
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	previous, existed := m.policies[chatID]
+	m.policies[chatID] = policy
+	if err := m.save(); err != nil {
+		if existed {
+			m.policies[chatID] = previous
+		} else {
+			delete(m.policies, chatID)
+		}
+		return models.RetentionPolicy{}, err
+	}
+
+	log.WithFields(log.Fields{
+		"chat_id": chatID,
+		"days":    days,
+	}).Info("Retention policy updated")
+
+	return policy, nil
+}
+
+// Delete removes the policy set via the API for a chat; the configured
+// policy or the default applies again
+func (m *Manager) Delete(chatID int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	policy, ok := m.policies[chatID]
+	if !ok {
+		return ErrNotFound
+	}
+	delete(m.policies, chatID)
+
+	if err := m.save(); err != nil {
+		m.policies[chatID] = policy
+		return err
+	}
+	return nil
+}
+
+// Replace swaps in a complete set of policies set via the API, e.g. from a
+// state import
+func (m *Manager) Replace(list []models.RetentionPolicy) error {
+	if err := Validate(list); err != nil {
+		return err
+	}
+
+	now := time.Now().Unix()
+	policies := make(map[int64]models.RetentionPolicy, len(list))
+	for _, policy := range list {
+		policy.Source = models.RetentionSourceAPI
+		if policy.UpdatedAt == 0 {
+			policy.UpdatedAt = now
+		}
+		policies[policy.ChatID] = policy
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	previous := m.policies
+	m.policies = policies
+	if err := m.save(); err != nil {
+		m.policies = previous
+		return err
+	}
+
+	log.WithField("policies", len(policies)).Info("Retention policies replaced")
+	return nil
+}
+
+// Validate checks policies for negative retention and duplicate chats
+func Validate(list []models.RetentionPolicy) error {
+	seen := make(map[int64]bool, len(list))
+	for _, policy := range list {
+		if policy.Days < 0 {
+			return fmt.Errorf("chat %d: retention days must not be negative", policy.ChatID)
+		}
+		if seen[policy.ChatID] {
+			return fmt.Errorf("duplicate retention policy for chat %d", policy.ChatID)
+		}
+		seen[policy.ChatID] = true
+	}
+	return nil
+}
+
+// Start purges expired messages now and then every purge interval. Without
+// an interval purges only run when requested, e.g. by the scheduler.
+func (m *Manager) Start() {
+	if m.cfg.PurgeInterval <= 0 {
+		log.WithField("default_days", m.cfg.DefaultDays).Info("Retention enabled, purging on request only")
+		return
+	}
+
+	m.stop = make(chan struct{})
+	m.done = make(chan struct{})
+
+	go func() {
+		defer close(m.done)
+
+		m.purgeScheduled()
+
+		ticker := time.NewTicker(m.cfg.PurgeInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				m.purgeScheduled()
+			case <-m.stop:
+				return
+			}
+		}
+	}()
+
+	log.WithFields(log.Fields{
+		"default_days":   m.cfg.DefaultDays,
+		"purge_interval": m.cfg.PurgeInterval.String(),
+	}).Info("Retention enabled")
+}
+
+// Stop stops purging
+func (m *Manager) Stop() {
+	if m == nil || m.stop == nil {
+		return
+	}
+	close(m.stop)
+	<-m.done
+}
+
+// purgeScheduled runs a purge from the ticker
+func (m *Manager) purgeScheduled() {
+	if _, err := m.Purge(context.Background()); err != nil {
+		log.WithError(err).Warn("Retention purge incomplete, will retry next interval")
+	}
+}
+
+// Purge permanently deletes messages older than their chat's retention,
+// soft-deleted ones included. Chats with a policy are purged one by one;
+// the default then applies to all other chats. A failing policy does not
+// stop the others.
+func (m *Manager) Purge(ctx context.Context) (*models.RetentionPurgeResult, error) {
+	m.purgeMu.Lock()
+	defer m.purgeMu.Unlock()
+
+	now := time.Now()
+	policies := m.effective()
+	result := &models.RetentionPurgeResult{}
+	var errs []error
+
+	chatIDs := make([]int64, 0, len(policies))
+	for chatID := range policies {
+		chatIDs = append(chatIDs, chatID)
+	}
+	sort.Slice(chatIDs, func(i, j int) bool { return chatIDs[i] < chatIDs[j] })
+
+	for _, chatID := range chatIDs {
+		days := policies[chatID].Days
+		if days == 0 {
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+
+		chatID := chatID
+		deleted, err := m.engine.DeleteByQuery(&models.SearchRequest{
+			ChatID:         &chatID,
+			DateTo:         cutoff(now, days),
+			IncludeDeleted: true,
+			Cause:          models.DeleteCauseRetention,
+		}, false)
+		if err != nil {
+			err = fmt.Errorf("chat %d: %w", chatID, err)
+			errs = append(errs, err)
+			result.Errors = append(result.Errors, err.Error())
+			continue
+		}
+		result.DeletedCount += deleted
+		result.Chats++
+	}
+
+	if m.cfg.DefaultDays > 0 {
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+
+		// Chats with a policy, including keep-forever ones, are exempt
+		deleted, err := m.engine.DeleteByQuery(&models.SearchRequest{
+			DateTo:         cutoff(now, m.cfg.DefaultDays),
+			ExcludedChats:  chatIDs,
+			IncludeDeleted: true,
+			Cause:          models.DeleteCauseRetention,
+		}, false)
+		if err != nil {
+			err = fmt.Errorf("default policy: %w", err)
+			errs = append(errs, err)
+			result.Errors = append(result.Errors, err.Error())
+		} else {
+			result.DeletedCount += deleted
+		}
+	}
+
+	log.WithFields(log.Fields{
+		"deleted": result.DeletedCount,
+		"chats":   result.Chats,
+		"errors":  len(errs),
+	}).Info("Retention purge finished")
+
+	return result, errors.Join(errs...)
+}
+
+// effective merges configured policies with those set via the API
+func (m *Manager) effective() map[int64]models.RetentionPolicy {
+	policies := make(map[int64]models.RetentionPolicy, len(m.cfg.Chats))
+	for chatID, days := range m.cfg.Chats {
+		policies[chatID] = models.RetentionPolicy{
+			ChatID: chatID,
+			Days:   days,
+			Source: models.RetentionSourceConfig,
+		}
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for chatID, policy := range m.policies {
+		policies[chatID] = policy
+	}
+	return policies
+}
+
+// save persists the policies set via the API (caller holds lock)
+func (m *Manager) save() error {
+	list := make([]models.RetentionPolicy, 0, len(m.policies))
+	for _, policy := range m.policies {
+		list = append(list, policy)
+	}
+	return m.file.Save(list)
+}
+
+// cutoff bounds messages sent more than days before now
+func cutoff(now time.Time, days int) *models.DateBound {
+	return models.NewDateBound(now.AddDate(0, 0, -days).Unix() - 1)
+}
@@ -0,0 +1,291 @@
+package s3
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ErrNotFound is returned when an object does not exist
+var ErrNotFound = errors.New("object not found")
+
+// Config holds the settings of an S3-compatible bucket (AWS S3, MinIO, ...)
+type Config struct {
+	Endpoint  string        // e.g. https://s3.amazonaws.com or http://minio:9000
+	Region    string        // Signing region, e.g. us-east-1
+	Bucket    string        // Bucket name
+	AccessKey string        // Access key ID
+	SecretKey string        // Secret access key
+	PathStyle bool          // Address the bucket as endpoint/bucket instead of bucket.endpoint (MinIO)
+	Timeout   time.Duration // Deadline per request (0 = none)
+}
+
+// Client stores and reads objects with signature version 4 requests
+type Client struct {
+	cfg      Config
+	endpoint *url.URL
+	http     *http.Client
+}
+
+// Object is an entry of a bucket listing
+type Object struct {
+	Key          string    `xml:"Key"`
+	Size         int64     `xml:"Size"`
+	LastModified time.Time `xml:"LastModified"`
+}
+
+// New creates a client for the configured bucket
+func New(cfg Config) (*Client, error) {
+	endpoint, err := url.Parse(cfg.Endpoint)
+	if err != nil || endpoint.Scheme == "" || endpoint.Host == "" {
+		return nil, fmt.Errorf("invalid S3 endpoint %q", cfg.Endpoint)
+	}
+	if cfg.Bucket == "" {
+		return nil, fmt.Errorf("S3 bucket is required")
+	}
+	if cfg.Region == "" {
+		cfg.Region = "us-east-1"
+	}
+
+	return &Client{
+		cfg:      cfg,
+		endpoint: endpoint,
+		http:     &http.Client{Timeout: cfg.Timeout},
+	}, nil
+}
+
+// Bucket returns the bucket name
+func (c *Client) Bucket() string {
+	return c.cfg.Bucket
+}
+
+// PutFile uploads a local file as key
+func (c *Client) PutFile(ctx context.Context, key, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	sum := sha256.New()
+	size, err := io.Copy(sum, f)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	resp, err := c.do(ctx, http.MethodPut, key, nil, f, size, hex.EncodeToString(sum.Sum(nil)))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// Get opens an object for reading; the caller closes it
+func (c *Client) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	resp, err := c.do(ctx, http.MethodGet, key, nil, nil, 0, emptyHash)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// List returns the objects below prefix. With a delimiter, keys that
+// continue past it are grouped into the returned common prefixes instead.
+func (c *Client) List(ctx context.Context, prefix, delimiter string) ([]Object, []string, error) {
+	var objects []Object
+	var prefixes []string
+	token := ""
+
+	for {
+		query := url.Values{"list-type": {"2"}, "prefix": {prefix}}
+		if delimiter != "" {
+			query.Set("delimiter", delimiter)
+		}
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+
+		resp, err := c.do(ctx, http.MethodGet, "", query, nil, 0, emptyHash)
+		if err != nil {
+			return nil, nil, err
+		}
+		var page struct {
+			Contents       []Object `xml:"Contents"`
+			CommonPrefixes []struct {
+				Prefix string `xml:"Prefix"`
+			} `xml:"CommonPrefixes"`
+			IsTruncated           bool   `xml:"IsTruncated"`
+			NextContinuationToken string `xml:"NextContinuationToken"`
+		}
+		err = xml.NewDecoder(resp.Body).Decode(&page)
+		resp.Body.Close()
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to decode bucket listing: %w", err)
+		}
+
+		objects = append(objects, page.Contents...)
+		for _, p := range page.CommonPrefixes {
+			prefixes = append(prefixes, p.Prefix)
+		}
+		if !page.IsTruncated || page.NextContinuationToken == "" {
+			return objects, prefixes, nil
+		}
+		token = page.NextContinuationToken
+	}
+}
+
+// emptyHash is the SHA-256 of an empty payload
+const emptyHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+// do sends a signed request for key (empty = the bucket itself) and turns
+// non-2xx responses into errors
+func (c *Client) do(ctx context.Context, method, key string, query url.Values, body io.Reader, size int64, payloadHash string) (*http.Response, error) {
+	u := *c.endpoint
+	path := strings.TrimSuffix(u.Path, "/")
+	if c.cfg.PathStyle {
+		path += "/" + c.cfg.Bucket
+	} else {
+		u.Host = c.cfg.Bucket + "." + u.Host
+	}
+	path += "/" + key
+	u.Path = path
+	u.RawPath = encodePath(path)
+	u.RawQuery = encodeQuery(query)
+
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.ContentLength = size
+	}
+	c.sign(req, payloadHash, time.Now().UTC())
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("S3 %s %s failed: %w", method, key, err)
+	}
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return resp, nil
+	}
+	defer resp.Body.Close()
+
+	var s3err struct {
+		Code    string `xml:"Code"`
+		Message string `xml:"Message"`
+	}
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	xml.Unmarshal(data, &s3err)
+
+	if resp.StatusCode == http.StatusNotFound && s3err.Code != "NoSuchBucket" {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, key)
+	}
+	if s3err.Code != "" {
+		return nil, fmt.Errorf("S3 %s %s failed with %s: %s", method, key, s3err.Code, s3err.Message)
+	}
+	return nil, fmt.Errorf("S3 %s %s failed with status %d", method, key, resp.StatusCode)
+}
+
+// sign adds AWS signature version 4 headers to req
+func (c *Client) sign(req *http.Request, payloadHash string, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+
+	req.Header.Set("x-amz-date", amzDate)
+	req.Header.Set("x-amz-content-sha256", payloadHash)
+
+	headers := map[string]string{
+		"host":                 req.URL.Host,
+		"x-amz-content-sha256": payloadHash,
+		"x-amz-date":           amzDate,
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + c.cfg.Region + "/s3/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hashHex(canonicalRequest)
+
+	key := hmacSHA256([]byte("AWS4"+c.cfg.SecretKey), date)
+	key = hmacSHA256(key, c.cfg.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		c.cfg.AccessKey, scope, signedHeaders, signature))
+}
+
+// encodePath escapes each path segment as signature version 4 expects
+func encodePath(path string) string {
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		segments[i] = escape(segment)
+	}
+	return strings.Join(segments, "/")
+}
+
+// encodeQuery builds a canonical query string: sorted, strictly escaped
+func encodeQuery(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	parts := make([]string, 0, len(keys))
+	for _, key := range keys {
+		for _, value := range query[key] {
+			parts = append(parts, escape(key)+"="+escape(value))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// escape percent-encodes everything but unreserved characters
+func escape(s string) string {
+	return strings.ReplaceAll(url.QueryEscape(s), "+", "%20")
+}
+
+func hashHex(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,324 @@
+package saved
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/zhishengyuan/searchgram-engine/models"
+	"github.com/zhishengyuan/searchgram-engine/storage"
+)
+
+// DefaultMaxTags is the number of tags a saved message may carry
+const DefaultMaxTags = 20
+
+// IsSavedMessage reports whether message was sent to its sender's Saved
+// Messages: a private chat whose ID is the user's own
+func IsSavedMessage(message *models.Message) bool {
+	chatType := message.ChatType
+	if chatType == "" {
+		chatType = message.Chat.Type
+	}
+	return strings.EqualFold(chatType, models.SavedMessagesChatType) && message.ChatID == message.SenderID
+}
+
+// Store holds the tags users gave their saved messages, persisted across
+// restarts. Tags are kept apart from the index, so re-ingesting a message
+// does not drop them.
+type Store struct {
+	file    *storage.Document
+	maxTags int
+
+	mu   sync.RWMutex
+	tags map[string]models.SavedTags // Message ID -> tags
+}
+
+// New loads the tags from file
+func New(file *storage.Document, maxTags int) (*Store, error) {
+	if maxTags <= 0 {
+		maxTags = DefaultMaxTags
+	}
+	s := &Store{
+		file:    file,
+		maxTags: maxTags,
+		tags:    make(map[string]models.SavedTags),
+	}
+
+	var saved []models.SavedTags
+	if err := file.Load(&saved); err != nil {
+		return nil, err
+	}
+	for _, tags := range saved {
+		s.tags[tags.ID] = tags
+	}
+
+	log.WithFields(log.Fields{
+		"path":     file.Path(),
+		"messages": len(s.tags),
+	}).Info("Saved message tags loaded")
+
+	return s, nil
+}
+
+// MaxTags returns the number of tags a message may carry
+func (s *Store) MaxTags() int {
+	return s.maxTags
+}
+
+// Set replaces the tags of a message; no tags removes them
+func (s *Store) Set(id string, tags []string) (models.SavedTags, error) {
+	tags = Normalize(tags)
+	if len(tags) > s.maxTags {
+		return models.SavedTags{}, fmt.Errorf("a message may carry at most %d tags", s.maxTags)
+	}
+	entry := models.SavedTags{
+		ID:        id,
+		Tags:      tags,
+		UpdatedAt: time.Now().Unix(),
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	previous, existed := s.tags[id]
+	if len(tags) == 0 {
+		delete(s.tags, id)
+	} else {
+		s.tags[id] = entry
+	}
+	if err := s.save(); err != nil {
+		if existed {
+			s.tags[id] = previous
+		} else {
+			delete(s.tags, id)
+		}
+		return models.SavedTags{}, err
+	}
+
+	log.WithFields(log.Fields{
+		"id":   id,
+		"tags": len(tags),
+	}).Info("Saved message tags updated")
+
+	return entry, nil
+}
+
+// Remove drops the tags of permanently deleted messages
+func (s *Store) Remove(ids ...string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	removed := make(map[string]models.SavedTags)
+	for _, id := range ids {
+		if entry, ok := s.tags[id]; ok {
+			removed[id] = entry
+			delete(s.tags, id)
+		}
+	}
+	if len(removed) == 0 {
+		return nil
+	}
+	if err := s.save(); err != nil {
+		for id, entry := range removed {
+			s.tags[id] = entry
+		}
+		return err
+	}
+
+	log.WithField("messages", len(removed)).Info("Saved message tags removed")
+	return nil
+}
+
+// List returns the tags of every message ordered by message ID
+func (s *Store) List() []models.SavedTags {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.sorted()
+}
+
+// Replace swaps in a complete set of tags, e.g. from a restored backup or
+// a state import
+func (s *Store) Replace(list []models.SavedTags) error {
+	if err := s.Validate(list); err != nil {
+		return err
+	}
+
+	tags := make(map[string]models.SavedTags, len(list))
+	for _, entry := range list {
+		entry.Tags = Normalize(entry.Tags)
+		if len(entry.Tags) > 0 {
+			tags[entry.ID] = entry
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	previous := s.tags
+	s.tags = tags
+	if err := s.save(); err != nil {
+		s.tags = previous
+		return err
+	}
+
+	log.WithField("messages", len(tags)).Info("Saved message tags replaced")
+	return nil
+}
+
+// Validate checks tags for invalid message IDs, duplicates and too many
+// tags on a message
+func (s *Store) Validate(list []models.SavedTags) error {
+	seen := make(map[string]bool, len(list))
+	for _, entry := range list {
+		if _, _, err := models.ParseMessageID(entry.ID); err != nil {
+			return err
+		}
+		if seen[entry.ID] {
+			return fmt.Errorf("duplicate tags for message %s", entry.ID)
+		}
+		seen[entry.ID] = true
+		if len(Normalize(entry.Tags)) > s.maxTags {
+			return fmt.Errorf("message %s: a message may carry at most %d tags", entry.ID, s.maxTags)
+		}
+	}
+	return nil
+}
+
+// WithTag returns the IDs of a user's messages carrying tag
+func (s *Store) WithTag(userID int64, tag string) []string {
+	tag = normalizeTag(tag)
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var ids []string
+	for id, entry := range s.tags {
+		if ownedBy(id, userID) && containsTag(entry.Tags, tag) {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// Tagged returns the IDs of a user's messages carrying any tag
+func (s *Store) Tagged(userID int64) []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var ids []string
+	for id := range s.tags {
+		if ownedBy(id, userID) {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// Counts returns how many of a user's messages carry each tag, most used
+// first, and how many carry any tag. Only messages in live are counted, so
+// tags of deleted and trashed messages are left out.
+func (s *Store) Counts(userID int64, live map[string]bool) ([]models.SavedTagCount, int) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	counts := make(map[string]int)
+	tagged := 0
+	for id, entry := range s.tags {
+		if !ownedBy(id, userID) || !live[id] {
+			continue
+		}
+		tagged++
+		for _, tag := range entry.Tags {
+			counts[tag]++
+		}
+	}
+
+	list := make([]models.SavedTagCount, 0, len(counts))
+	for tag, messages := range counts {
+		list = append(list, models.SavedTagCount{Tag: tag, Messages: messages})
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Messages != list[j].Messages {
+			return list[i].Messages > list[j].Messages
+		}
+		return list[i].Tag < list[j].Tag
+	})
+	return list, tagged
+}
+
+// Annotate returns the tags of the given messages by ID, or nil when none
+// is tagged
+func (s *Store) Annotate(messages []models.Message) map[string][]string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var tags map[string][]string
+	for i := range messages {
+		entry, ok := s.tags[messages[i].ID]
+		if !ok {
+			continue
+		}
+		if tags == nil {
+			tags = make(map[string][]string)
+		}
+		tags[messages[i].ID] = append([]string(nil), entry.Tags...)
+	}
+	return tags
+}
+
+// Normalize lowercases and trims tags, dropping a leading "#", empty tags
+// and duplicates, and sorts them
+func Normalize(tags []string) []string {
+	seen := make(map[string]bool, len(tags))
+	normalized := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		tag = normalizeTag(tag)
+		if tag == "" || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		normalized = append(normalized, tag)
+	}
+	sort.Strings(normalized)
+	return normalized
+}
+
+// normalizeTag lowercases and trims a tag and drops a leading "#"
+func normalizeTag(tag string) string {
+	return strings.ToLower(strings.TrimPrefix(strings.TrimSpace(tag), "#"))
+}
+
+// ownedBy reports whether a message ID belongs to a user's Saved Messages
+func ownedBy(id string, userID int64) bool {
+	chatID, _, err := models.ParseMessageID(id)
+	return err == nil && chatID == userID
+}
+
+// containsTag reports whether sorted tags holds tag
+func containsTag(tags []string, tag string) bool {
+	i := sort.SearchStrings(tags, tag)
+	return i < len(tags) && tags[i] == tag
+}
+
+// sorted returns the tags ordered by message ID (caller holds lock)
+func (s *Store) sorted() []models.SavedTags {
+	list := make([]models.SavedTags, 0, len(s.tags))
+	for _, entry := range s.tags {
+		entry.Tags = append([]string(nil), entry.Tags...)
+		list = append(list, entry)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].ID < list[j].ID
+	})
+	return list
+}
+
+// save persists the tags (caller holds lock)
+func (s *Store) save() error {
+	return s.file.Save(s.sorted())
+}
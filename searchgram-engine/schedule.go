@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/zhishengyuan/searchgram-engine/config"
+	"github.com/zhishengyuan/searchgram-engine/engines"
+	"github.com/zhishengyuan/searchgram-engine/export"
+	"github.com/zhishengyuan/searchgram-engine/models"
+	"github.com/zhishengyuan/searchgram-engine/retention"
+	"github.com/zhishengyuan/searchgram-engine/scheduler"
+)
+
+// scheduledTask turns a configured task into the job it runs. Tasks are
+// validated with the configuration; retention is non-nil when needed.
+func scheduledTask(task config.ScheduledTaskConfig, engine engines.SearchEngine, retentionManager *retention.Manager) scheduler.Task {
+	run := func(ctx context.Context, update func(progress interface{})) (interface{}, error) {
+		switch task.Task {
+		case scheduler.TaskDedup:
+			return engine.Dedup(ctx, task.DryRun, func(progress *models.DedupResponse) {
+				update(progress)
+			})
+		case scheduler.TaskRetentionPurge:
+			return retentionManager.Purge(ctx)
+		case scheduler.TaskForceMerge:
+			return engine.ForceMerge(ctx, task.MaxSegments)
+		case scheduler.TaskExport:
+			target, err := exportTarget(task.Delivery)
+			if err != nil {
+				return nil, err
+			}
+			return export.Run(ctx, engine, export.Options{
+				Name:    task.ScheduledName(),
+				ChatIDs: task.ChatIDs,
+				Days:    task.Days,
+			}, target)
+		default:
+			return engine.Snapshot(ctx, task.Repository, task.Keep)
+		}
+	}
+
+	return scheduler.Task{
+		Name:     task.ScheduledName(),
+		Kind:     task.Task,
+		Schedule: task.Schedule,
+		Run: func(ctx context.Context, update func(progress interface{})) (interface{}, error) {
+			log.WithFields(log.Fields{
+				"name": task.ScheduledName(),
+				"task": task.Task,
+			}).Info("Running scheduled task...")
+			return run(ctx, update)
+		},
+	}
+}
+
+// exportTarget returns where a scheduled export is delivered
+func exportTarget(delivery config.ExportDeliveryConfig) (export.Target, error) {
+	switch delivery.Type {
+	case export.TargetS3:
+		bucket, err := newBucket(delivery.S3)
+		if err != nil {
+			return nil, err
+		}
+		return export.Bucket{Client: bucket, Prefix: delivery.S3.Prefix}, nil
+	case export.TargetWebhook:
+		return export.Webhook{URL: delivery.URL, Secret: delivery.Secret}, nil
+	default:
+		return export.Dir{Path: delivery.Path}, nil
+	}
+}
@@ -0,0 +1,177 @@
+package scheduler
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule is a parsed cron expression: minute, hour, day of month, month
+// and day of week, each a bit set of the values it matches
+type Schedule struct {
+	minute, hour, dom, month, dow uint64
+
+	// When both day fields are restricted a day matching either is run,
+	// as in cron; a field starting with "*" does not restrict days
+	domAny, dowAny bool
+}
+
+// cronField describes the value range of one cron field
+type cronField struct {
+	name     string
+	min, max int
+}
+
+var cronFields = []cronField{
+	{"minute", 0, 59},
+	{"hour", 0, 23},
+	{"day of month", 1, 31},
+	{"month", 1, 12},
+	{"day of week", 0, 7}, // 0 and 7 are Sunday
+}
+
+// cronMacros are the supported shorthands
+var cronMacros = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// Parse parses a five-field cron expression ("30 3 * * *") or a macro such
+// as @daily. Fields accept *, values, ranges (1-5), lists (1,3) and steps
+// (*/15, 0-30/10).
+func Parse(spec string) (*Schedule, error) {
+	expr := strings.TrimSpace(spec)
+	if macro, ok := cronMacros[strings.ToLower(expr)]; ok {
+		expr = macro
+	}
+
+	fields := strings.Fields(expr)
+	if len(fields) != len(cronFields) {
+		return nil, fmt.Errorf("invalid schedule %q: expected 5 fields", spec)
+	}
+
+	var bits [5]uint64
+	for i, field := range fields {
+		set, err := parseField(field, cronFields[i])
+		if err != nil {
+			return nil, fmt.Errorf("invalid schedule %q: %w", spec, err)
+		}
+		bits[i] = set
+	}
+
+	// Sunday may be written as 7
+	if bits[4]&(1<<7) != 0 {
+		bits[4] |= 1
+	}
+
+	return &Schedule{
+		minute: bits[0],
+		hour:   bits[1],
+		dom:    bits[2],
+		month:  bits[3],
+		dow:    bits[4],
+		domAny: strings.HasPrefix(fields[2], "*"),
+		dowAny: strings.HasPrefix(fields[4], "*"),
+	}, nil
+}
+
+// parseField parses one comma-separated cron field into a bit set
+func parseField(field string, spec cronField) (uint64, error) {
+	var set uint64
+	for _, part := range strings.Split(field, ",") {
+		rangePart, step := part, 1
+		if i := strings.Index(part, "/"); i >= 0 {
+			n, err := strconv.Atoi(part[i+1:])
+			if err != nil || n < 1 {
+				return 0, fmt.Errorf("invalid step in %s field %q", spec.name, part)
+			}
+			rangePart, step = part[:i], n
+		}
+
+		low, high := spec.min, spec.max
+		if rangePart != "*" {
+			var err error
+			bounds := strings.SplitN(rangePart, "-", 2)
+			if low, err = strconv.Atoi(bounds[0]); err != nil {
+				return 0, fmt.Errorf("invalid %s field %q", spec.name, part)
+			}
+			high = low
+			if len(bounds) == 2 {
+				if high, err = strconv.Atoi(bounds[1]); err != nil {
+					return 0, fmt.Errorf("invalid %s field %q", spec.name, part)
+				}
+			} else if step > 1 {
+				high = spec.max // "5/15" runs from 5 to the end
+			}
+		}
+		if low < spec.min || high > spec.max || low > high {
+			return 0, fmt.Errorf("%s field %q is out of range %d-%d", spec.name, part, spec.min, spec.max)
+		}
+
+		for v := low; v <= high; v += step {
+			set |= 1 << uint(v)
+		}
+	}
+	return set, nil
+}
+
+// Next returns the first time after t the schedule matches, in t's
+// location, or the zero time if it never does (e.g. February 30th)
+func (s *Schedule) Next(t time.Time) time.Time {
+	loc := t.Location()
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(5, 0, 0)
+
+	for t.Before(limit) {
+		if s.month&(1<<uint(t.Month())) == 0 {
+			t = advance(t, time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, loc))
+			continue
+		}
+		if !s.matchesDay(t) {
+			t = advance(t, time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, loc))
+			continue
+		}
+		if s.hour&(1<<uint(t.Hour())) == 0 {
+			// Counted in elapsed minutes, as wall clock hours may be skipped
+			t = t.Add(time.Duration(60-t.Minute()) * time.Minute)
+			continue
+		}
+		if s.minute&(1<<uint(t.Minute())) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
+
+// advance returns next, or an hour after t when a daylight saving gap made
+// the wall clock time of next resolve to before t
+func advance(t, next time.Time) time.Time {
+	if next.After(t) {
+		return next
+	}
+	return t.Add(time.Hour)
+}
+
+// matchesDay applies cron's day of month / day of week rule
+func (s *Schedule) matchesDay(t time.Time) bool {
+	dom := s.dom&(1<<uint(t.Day())) != 0
+	dow := s.dow&(1<<uint(t.Weekday())) != 0
+	switch {
+	case s.domAny && s.dowAny:
+		return true
+	case s.domAny:
+		return dow
+	case s.dowAny:
+		return dom
+	default:
+		return dom || dow
+	}
+}
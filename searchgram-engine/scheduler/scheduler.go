@@ -0,0 +1,211 @@
+package scheduler
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/zhishengyuan/searchgram-engine/jobs"
+	"github.com/zhishengyuan/searchgram-engine/models"
+)
+
+// Scheduled task kinds; each runs as a background job of the same type
+const (
+	TaskDedup          = "dedup"
+	TaskRetentionPurge = "retention_purge"
+	TaskForceMerge     = "forcemerge"
+	TaskSnapshot       = "snapshot"
+	TaskExport         = "export"
+)
+
+// Tasks lists the task kinds that can be scheduled
+func Tasks() []string {
+	return []string{TaskDedup, TaskRetentionPurge, TaskForceMerge, TaskSnapshot, TaskExport}
+}
+
+// maxWait bounds how long the scheduler sleeps, so clock changes are noticed
+const maxWait = time.Minute
+
+// Task is a recurring job
+type Task struct {
+	Name     string // Unique name, e.g. nightly-dedup
+	Kind     string // Job type it runs as, e.g. dedup
+	Schedule string // Cron expression
+	Run      jobs.Func
+}
+
+// entry is a task with its parsed schedule and run history
+type entry struct {
+	task     Task
+	schedule *Schedule
+	next     time.Time
+	lastRun  time.Time
+	lastJob  string
+	skipped  int64
+}
+
+// Scheduler starts tasks as background jobs when their schedule is due. A
+// task whose previous run (or a manually started job of the same type) is
+// still running is skipped until its next time.
+type Scheduler struct {
+	jobs     *jobs.Manager
+	location *time.Location
+
+	mu      sync.Mutex
+	entries []*entry
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// New creates a scheduler evaluating schedules in location
+func New(jobManager *jobs.Manager, location *time.Location) *Scheduler {
+	if location == nil {
+		location = time.UTC
+	}
+	return &Scheduler{
+		jobs:     jobManager,
+		location: location,
+	}
+}
+
+// Add registers a task
+func (s *Scheduler) Add(task Task) error {
+	schedule, err := Parse(task.Schedule)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, e := range s.entries {
+		if e.task.Name == task.Name {
+			return fmt.Errorf("scheduled task %s is defined more than once", task.Name)
+		}
+	}
+	s.entries = append(s.entries, &entry{
+		task:     task,
+		schedule: schedule,
+		next:     schedule.Next(time.Now().In(s.location)),
+	})
+	return nil
+}
+
+// List returns the tasks with their next and last runs, soonest first
+func (s *Scheduler) List() []models.ScheduledTask {
+	if s == nil {
+		return []models.ScheduledTask{}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	list := make([]models.ScheduledTask, 0, len(s.entries))
+	for _, e := range s.entries {
+		task := models.ScheduledTask{
+			Name:     e.task.Name,
+			Task:     e.task.Kind,
+			Schedule: e.task.Schedule,
+			LastJob:  e.lastJob,
+			Skipped:  e.skipped,
+		}
+		if !e.next.IsZero() {
+			task.NextRun = e.next.Unix()
+		}
+		if !e.lastRun.IsZero() {
+			task.LastRun = e.lastRun.Unix()
+		}
+		list = append(list, task)
+	}
+	sort.SliceStable(list, func(i, j int) bool {
+		return list[i].NextRun < list[j].NextRun
+	})
+	return list
+}
+
+// Start runs due tasks until Stop
+func (s *Scheduler) Start() {
+	s.stop = make(chan struct{})
+	s.done = make(chan struct{})
+
+	go func() {
+		defer close(s.done)
+
+		for {
+			timer := time.NewTimer(s.wait(time.Now()))
+			select {
+			case <-timer.C:
+				s.runDue(time.Now())
+			case <-s.stop:
+				timer.Stop()
+				return
+			}
+		}
+	}()
+
+	for _, task := range s.List() {
+		log.WithFields(log.Fields{
+			"name":     task.Name,
+			"task":     task.Task,
+			"schedule": task.Schedule,
+			"next_run": time.Unix(task.NextRun, 0).In(s.location).Format(time.RFC3339),
+		}).Info("Scheduled task registered")
+	}
+}
+
+// Stop stops starting tasks; running jobs are left to the job manager
+func (s *Scheduler) Stop() {
+	if s == nil || s.stop == nil {
+		return
+	}
+	close(s.stop)
+	<-s.done
+}
+
+// wait returns how long to sleep until the next due task
+func (s *Scheduler) wait(now time.Time) time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	wait := maxWait
+	for _, e := range s.entries {
+		if e.next.IsZero() {
+			continue
+		}
+		if until := e.next.Sub(now); until < wait {
+			wait = max(until, 0)
+		}
+	}
+	return wait
+}
+
+// runDue starts every task whose time has come
+func (s *Scheduler) runDue(now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, e := range s.entries {
+		if e.next.IsZero() || now.Before(e.next) {
+			continue
+		}
+		e.next = e.schedule.Next(now.In(s.location))
+
+		job, started := s.jobs.StartExclusive(e.task.Kind, e.task.Run)
+		fields := log.Fields{
+			"name": e.task.Name,
+			"task": e.task.Kind,
+			"job":  job.ID,
+		}
+		if !started {
+			e.skipped++
+			log.WithFields(fields).Warn("Scheduled task skipped, a job of its type is still running")
+			continue
+		}
+		e.lastRun = now
+		e.lastJob = job.ID
+		log.WithFields(fields).Info("Scheduled task started")
+	}
+}
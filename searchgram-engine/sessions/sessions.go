@@ -0,0 +1,171 @@
+package sessions
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/zhishengyuan/searchgram-engine/jwt"
+)
+
+// ContextKey is where the session of a cookie-authenticated request is kept
+const ContextKey = "session"
+
+// Config holds session cookie settings
+type Config struct {
+	TTL        time.Duration // Lifetime of a session; it is not extended by use
+	CookieName string        // Name of the HttpOnly session cookie
+	Secure     bool          // Only send the cookie over HTTPS
+}
+
+// Session is a short-lived login created from an API key or JWT. The
+// browser holds only the session ID (in an HttpOnly cookie) and the CSRF
+// token, never the credential itself.
+type Session struct {
+	ID        string
+	CSRFToken string
+	Issuer    string      // JWT issuer of the login, empty for API key logins
+	Role      string      // Credential role of the login, empty when not restricted by role
+	Claims    *jwt.Claims // JWT claims of the login, nil for API key logins
+	ExpiresAt time.Time
+}
+
+// Store keeps sessions in memory; a restart signs every browser out
+type Store struct {
+	cfg Config
+
+	mu       sync.Mutex
+	sessions map[string]*Session
+}
+
+// NewStore creates a session store
+func NewStore(cfg Config) *Store {
+	if cfg.TTL <= 0 {
+		cfg.TTL = 2 * time.Hour
+	}
+	if cfg.CookieName == "" {
+		cfg.CookieName = "sg_session"
+	}
+	return &Store{
+		cfg:      cfg,
+		sessions: make(map[string]*Session),
+	}
+}
+
+// Create starts a session for the given identity
+func (s *Store) Create(issuer, role string, claims *jwt.Claims) (*Session, error) {
+	id, err := randomToken()
+	if err != nil {
+		return nil, err
+	}
+	csrf, err := randomToken()
+	if err != nil {
+		return nil, err
+	}
+
+	session := &Session{
+		ID:        id,
+		CSRFToken: csrf,
+		Issuer:    issuer,
+		Role:      role,
+		Claims:    claims,
+		ExpiresAt: time.Now().Add(s.cfg.TTL),
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.sweep()
+	s.sessions[id] = session
+	return session, nil
+}
+
+// Get returns a live session by ID
+func (s *Store) Get(id string) (*Session, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	session, ok := s.sessions[id]
+	if !ok {
+		return nil, false
+	}
+	if time.Now().After(session.ExpiresAt) {
+		delete(s.sessions, id)
+		return nil, false
+	}
+	return session, true
+}
+
+// Delete ends a session
+func (s *Store) Delete(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.sessions, id)
+}
+
+// FromRequest returns the live session named by the request cookie
+func (s *Store) FromRequest(c *gin.Context) (*Session, bool) {
+	id, err := c.Cookie(s.cfg.CookieName)
+	if err != nil || id == "" {
+		return nil, false
+	}
+	return s.Get(id)
+}
+
+// SetCookie sends the session cookie. It is HttpOnly so page scripts cannot
+// read it, and SameSite=Strict so other sites cannot make the browser send it.
+func (s *Store) SetCookie(c *gin.Context, session *Session) {
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name:     s.cfg.CookieName,
+		Value:    session.ID,
+		Path:     "/api/",
+		Expires:  session.ExpiresAt,
+		MaxAge:   int(time.Until(session.ExpiresAt).Seconds()),
+		Secure:   s.cfg.Secure,
+		HttpOnly: true,
+		SameSite: http.SameSiteStrictMode,
+	})
+}
+
+// ClearCookie removes the session cookie from the browser
+func (s *Store) ClearCookie(c *gin.Context) {
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name:     s.cfg.CookieName,
+		Value:    "",
+		Path:     "/api/",
+		MaxAge:   -1,
+		Secure:   s.cfg.Secure,
+		HttpOnly: true,
+		SameSite: http.SameSiteStrictMode,
+	})
+}
+
+// ValidCSRF reports whether token matches the session's CSRF token
+func (session *Session) ValidCSRF(token string) bool {
+	return token != "" && subtle.ConstantTimeCompare([]byte(token), []byte(session.CSRFToken)) == 1
+}
+
+// sweep drops expired sessions (caller holds lock)
+func (s *Store) sweep() {
+	now := time.Now()
+	for id, session := range s.sessions {
+		if now.After(session.ExpiresAt) {
+			delete(s.sessions, id)
+		}
+	}
+}
+
+// randomToken returns 32 random bytes, base64url-encoded
+func randomToken() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate session token: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// maxStartupBackoff caps the delay between engine connection attempts
+const maxStartupBackoff = 30 * time.Second
+
+// connectWithRetry calls connect until it succeeds, maxWait elapses or ctx
+// is cancelled, doubling the delay between attempts. A zero maxWait makes a
+// single attempt.
+func connectWithRetry(ctx context.Context, name string, maxWait, backoff time.Duration, connect func() error) error {
+	deadline := time.Now().Add(maxWait)
+	if backoff <= 0 {
+		backoff = time.Second
+	}
+
+	for attempt := 1; ; attempt++ {
+		err := connect()
+		if err == nil {
+			if attempt > 1 {
+				log.WithField("attempts", attempt).Infof("Connected to %s", name)
+			}
+			return nil
+		}
+
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			return fmt.Errorf("giving up on %s after %d attempts: %w", name, attempt, err)
+		}
+
+		wait := backoff
+		if wait > remaining {
+			wait = remaining
+		}
+		log.WithError(err).WithFields(log.Fields{
+			"attempt":     attempt,
+			"retry_in":    wait.String(),
+			"gives_up_in": remaining.Round(time.Second).String(),
+		}).Warnf("%s not reachable yet, retrying", name)
+
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return fmt.Errorf("interrupted while connecting to %s: %w", name, err)
+		}
+
+		backoff *= 2
+		if backoff > maxStartupBackoff {
+			backoff = maxStartupBackoff
+		}
+	}
+}
+
+// startupGate serves liveness while the engine is still connecting and
+// hands all traffic to the real handler once Ready is called
+type startupGate struct {
+	handler atomic.Pointer[http.Handler]
+}
+
+// Ready switches the gate to the fully initialized handler
+func (g *startupGate) Ready(handler http.Handler) {
+	g.handler.Store(&handler)
+}
+
+// ServeHTTP answers /livez and /health/live with 200 and everything else,
+// readiness probes included, with 503 until ready
+func (g *startupGate) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if handler := g.handler.Load(); handler != nil {
+		(*handler).ServeHTTP(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if r.URL.Path == "/livez" || r.URL.Path == "/health/live" {
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprint(w, `{"status":"alive","engine":"connecting"}`)
+		return
+	}
+
+	w.Header().Set("Retry-After", "5")
+	w.WriteHeader(http.StatusServiceUnavailable)
+	fmt.Fprint(w, `{"error":"Service Unavailable","message":"Search engine is still connecting"}`)
+}
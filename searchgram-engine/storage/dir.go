@@ -0,0 +1,55 @@
+package storage
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Dir keeps every key as a file of that name in a directory
+type Dir struct {
+	path string
+}
+
+// NewDir creates a backend storing files in path, which is created on the
+// first write
+func NewDir(path string) *Dir {
+	return &Dir{path: path}
+}
+
+// Name implements Backend
+func (d *Dir) Name() string {
+	return BackendFile
+}
+
+// Location implements Backend
+func (d *Dir) Location(key string) string {
+	return filepath.Join(d.path, key)
+}
+
+// Get implements Backend
+func (d *Dir) Get(key string) ([]byte, error) {
+	data, err := os.ReadFile(d.Location(key))
+	if os.IsNotExist(err) {
+		return nil, ErrNotFound
+	}
+	return data, err
+}
+
+// Put implements Backend by atomically replacing the file
+func (d *Dir) Put(key string, value []byte) error {
+	path := d.Location(key)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("failed to create directory for %s: %w", path, err)
+	}
+
+	// Write to a temp file first so a crash never leaves a truncated document
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, value, 0o600); err != nil {
+		return fmt.Errorf("failed to write %s: %w", tmp, err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("failed to replace %s: %w", path, err)
+	}
+	return nil
+}
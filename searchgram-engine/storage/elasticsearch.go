@@ -0,0 +1,138 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/olivere/elastic/v7"
+)
+
+// requestTimeout bounds a single read or write of the state index
+const requestTimeout = 30 * time.Second
+
+// stateMapping keeps values out of the inverted index: they are only ever
+// fetched by key
+const stateMapping = `{
+	"settings": {"number_of_shards": 1, "auto_expand_replicas": "0-1"},
+	"mappings": {
+		"dynamic": false,
+		"properties": {
+			"value":      {"type": "binary"},
+			"updated_at": {"type": "date", "format": "epoch_second"}
+		}
+	}
+}`
+
+// stateDoc is a value as stored in the state index
+type stateDoc struct {
+	Value     []byte `json:"value"`
+	UpdatedAt int64  `json:"updated_at"`
+}
+
+// Elasticsearch keeps every key as a document of a dedicated index, so
+// state lives next to the messages and is covered by the cluster's own
+// replication and snapshots instead of the local disk
+type Elasticsearch struct {
+	client *elastic.Client
+	index  string
+
+	mu      sync.Mutex
+	created bool // The index is known to exist
+}
+
+// NewElasticsearch connects to the cluster at host; index is created on the
+// first write
+func NewElasticsearch(host, username, password, index string) (*Elasticsearch, error) {
+	options := []elastic.ClientOptionFunc{
+		elastic.SetURL(host),
+		elastic.SetSniff(false),
+		elastic.SetHealthcheck(false),
+	}
+	if username != "" && password != "" {
+		options = append(options, elastic.SetBasicAuth(username, password))
+	}
+
+	client, err := elastic.NewClient(options...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Elasticsearch client: %w", err)
+	}
+	return &Elasticsearch{client: client, index: index}, nil
+}
+
+// Name implements Backend
+func (e *Elasticsearch) Name() string {
+	return BackendElasticsearch
+}
+
+// Location implements Backend
+func (e *Elasticsearch) Location(key string) string {
+	return e.index + "/" + key
+}
+
+// Get implements Backend; gets are real-time, so a value is visible as
+// soon as Put returns
+func (e *Elasticsearch) Get(key string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+
+	result, err := e.client.Get().Index(e.index).Id(key).Do(ctx)
+	if elastic.IsNotFound(err) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !result.Found {
+		return nil, ErrNotFound
+	}
+
+	var doc stateDoc
+	if err := json.Unmarshal(result.Source, &doc); err != nil {
+		return nil, err
+	}
+	return doc.Value, nil
+}
+
+// Put implements Backend
+func (e *Elasticsearch) Put(key string, value []byte) error {
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+
+	if err := e.ensureIndex(ctx); err != nil {
+		return err
+	}
+	_, err := e.client.Index().
+		Index(e.index).
+		Id(key).
+		BodyJson(stateDoc{Value: value, UpdatedAt: time.Now().Unix()}).
+		Do(ctx)
+	return err
+}
+
+// ensureIndex creates the state index with its mapping unless it exists
+func (e *Elasticsearch) ensureIndex(ctx context.Context) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.created {
+		return nil
+	}
+
+	exists, err := e.client.IndexExists(e.index).Do(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to check state index %s: %w", e.index, err)
+	}
+	if !exists {
+		_, err := e.client.CreateIndex(e.index).BodyString(stateMapping).Do(ctx)
+		var esErr *elastic.Error
+		// Another instance may have created it meanwhile
+		if err != nil && !(errors.As(err, &esErr) && esErr.Details != nil && esErr.Details.Type == "resource_already_exists_exception") {
+			return fmt.Errorf("failed to create state index %s: %w", e.index, err)
+		}
+	}
+	e.created = true
+	return nil
+}
@@ -0,0 +1,86 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// Backend types
+const (
+	BackendFile          = "file"
+	BackendElasticsearch = "elasticsearch"
+)
+
+// Backends lists the supported backends
+func Backends() []string {
+	return []string{BackendFile, BackendElasticsearch}
+}
+
+// ErrNotFound is returned by Backend.Get for keys that were never stored
+var ErrNotFound = errors.New("state not found")
+
+// Backend keeps the engine's own state (jobs, tenants, saved searches,
+// capture rules, checkpoints, ...) as values under flat keys such as
+// "jobs.json". Subsystems do not use it directly but through a Document.
+type Backend interface {
+	Name() string
+	Get(key string) ([]byte, error)
+	// Put replaces the value of key; a value is never left half-written
+	Put(key string, value []byte) error
+	// Location describes where key is kept, for logs
+	Location(key string) string
+}
+
+// Document persists a single JSON document under a key of a backend.
+// Loads and saves of one document are serialized.
+type Document struct {
+	mu      sync.Mutex
+	backend Backend
+	key     string
+}
+
+// NewDocument creates a document stored under key
+func NewDocument(backend Backend, key string) *Document {
+	return &Document{backend: backend, key: key}
+}
+
+// Path returns where the document is kept
+func (d *Document) Path() string {
+	return d.backend.Location(d.key)
+}
+
+// Load decodes the document into v. A missing document is not an error and leaves v untouched.
+func (d *Document) Load(v interface{}) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	data, err := d.backend.Get(d.key)
+	if errors.Is(err, ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", d.Path(), err)
+	}
+
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("failed to decode %s: %w", d.Path(), err)
+	}
+	return nil
+}
+
+// Save encodes v and replaces the document
+func (d *Document) Save(v interface{}) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode %s: %w", d.Path(), err)
+	}
+	if err := d.backend.Put(d.key, data); err != nil {
+		return fmt.Errorf("failed to write %s: %w", d.Path(), err)
+	}
+	return nil
+}
@@ -0,0 +1,487 @@
+package subscriptions
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	log "github.com/sirupsen/logrus"
+	"github.com/zhishengyuan/searchgram-engine/models"
+	"github.com/zhishengyuan/searchgram-engine/storage"
+)
+
+const (
+	snippetRunes   = 200
+	recentCapacity = 10000
+)
+
+var (
+	// ErrNotFound is returned when a subscription ID does not exist
+	ErrNotFound = errors.New("subscription not found")
+
+	// ErrLimitReached is returned when a user has too many subscriptions
+	ErrLimitReached = errors.New("subscription limit reached for user")
+)
+
+// Config holds subscription manager settings
+type Config struct {
+	QueueSize   int           // Maximum buffered events
+	MaxPerUser  int           // Maximum subscriptions per Telegram user
+	WebhookURL  string        // Optional webhook receiving event batches
+	WebhookWait time.Duration // Delay between webhook delivery attempts
+}
+
+// Manager stores subscriptions, matches indexed messages and queues events
+type Manager struct {
+	cfg  Config
+	file *storage.Document
+
+	mu     sync.RWMutex
+	subs   map[string]*models.Subscription
+	keys   map[string]string // subscription ID -> lowercased keyword
+	events []models.SubscriptionEvent
+	seq    int64
+	notify chan struct{} // closed and replaced whenever events are queued
+
+	// recent suppresses repeat notifications when a message is re-upserted
+	recent      map[string]struct{}
+	recentOrder []string
+
+	client *http.Client
+	stop   chan struct{}
+	done   chan struct{}
+}
+
+// NewManager loads subscriptions from file
+func NewManager(cfg Config, file *storage.Document) (*Manager, error) {
+	if cfg.QueueSize <= 0 {
+		cfg.QueueSize = 10000
+	}
+	if cfg.MaxPerUser <= 0 {
+		cfg.MaxPerUser = 50
+	}
+	if cfg.WebhookWait <= 0 {
+		cfg.WebhookWait = 5 * time.Second
+	}
+
+	m := &Manager{
+		cfg:    cfg,
+		file:   file,
+		subs:   make(map[string]*models.Subscription),
+		keys:   make(map[string]string),
+		notify: make(chan struct{}),
+		recent: make(map[string]struct{}),
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+
+	var saved []*models.Subscription
+	if err := file.Load(&saved); err != nil {
+		return nil, err
+	}
+	for _, sub := range saved {
+		m.subs[sub.ID] = sub
+		m.keys[sub.ID] = strings.ToLower(sub.Keyword)
+	}
+
+	log.WithField("subscriptions", len(m.subs)).Info("Keyword subscriptions loaded")
+
+	return m, nil
+}
+
+// Add registers a new subscription
+func (m *Manager) Add(sub models.Subscription) (*models.Subscription, error) {
+	sub.Keyword = strings.TrimSpace(sub.Keyword)
+	if sub.UserID == 0 {
+		return nil, fmt.Errorf("user_id is required")
+	}
+	if sub.Keyword == "" {
+		return nil, fmt.Errorf("keyword is required")
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	count := 0
+	for _, existing := range m.subs {
+		if existing.UserID != sub.UserID {
+			continue
+		}
+		count++
+		if strings.EqualFold(existing.Keyword, sub.Keyword) && equalChats(existing.ChatIDs, sub.ChatIDs) {
+			// Idempotent: re-registering the same watch returns the existing one
+			copied := *existing
+			return &copied, nil
+		}
+	}
+	if count >= m.cfg.MaxPerUser {
+		return nil, ErrLimitReached
+	}
+
+	sub.ID = uuid.New().String()
+	sub.CreatedAt = time.Now().Unix()
+	m.subs[sub.ID] = &sub
+	m.keys[sub.ID] = strings.ToLower(sub.Keyword)
+
+	if err := m.save(); err != nil {
+		delete(m.subs, sub.ID)
+		delete(m.keys, sub.ID)
+		return nil, err
+	}
+
+	copied := sub
+	return &copied, nil
+}
+
+// Delete removes a subscription
+func (m *Manager) Delete(id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	sub, ok := m.subs[id]
+	if !ok {
+		return ErrNotFound
+	}
+	delete(m.subs, id)
+	delete(m.keys, id)
+
+	if err := m.save(); err != nil {
+		m.subs[id] = sub
+		m.keys[id] = strings.ToLower(sub.Keyword)
+		return err
+	}
+	return nil
+}
+
+// List returns subscriptions, optionally only those of one user (userID 0 = all)
+func (m *Manager) List(userID int64) []models.Subscription {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	list := make([]models.Subscription, 0)
+	for _, sub := range m.subs {
+		if userID == 0 || sub.UserID == userID {
+			list = append(list, *sub)
+		}
+	}
+	return list
+}
+
+// Replace swaps in a complete set of subscriptions, e.g. from a state
+// import. Subscriptions without an ID get a new one.
+func (m *Manager) Replace(list []models.Subscription) error {
+	if err := m.Validate(list); err != nil {
+		return err
+	}
+
+	now := time.Now().Unix()
+	subs := make(map[string]*models.Subscription, len(list))
+	keys := make(map[string]string, len(list))
+	for _, sub := range list {
+		sub := sub
+		sub.Keyword = strings.TrimSpace(sub.Keyword)
+		sub.ChatIDs = append([]int64(nil), sub.ChatIDs...)
+		if sub.ID == "" {
+			sub.ID = uuid.New().String()
+		}
+		if sub.CreatedAt == 0 {
+			sub.CreatedAt = now
+		}
+		subs[sub.ID] = &sub
+		keys[sub.ID] = strings.ToLower(sub.Keyword)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	previousSubs, previousKeys := m.subs, m.keys
+	m.subs, m.keys = subs, keys
+	if err := m.save(); err != nil {
+		m.subs, m.keys = previousSubs, previousKeys
+		return err
+	}
+
+	log.WithField("subscriptions", len(subs)).Info("Keyword subscriptions replaced")
+	return nil
+}
+
+// Validate checks subscriptions for missing fields, duplicate IDs and users
+// over the subscription limit
+func (m *Manager) Validate(list []models.Subscription) error {
+	ids := make(map[string]bool, len(list))
+	perUser := make(map[int64]int)
+	for _, sub := range list {
+		if sub.UserID == 0 {
+			return fmt.Errorf("user_id is required")
+		}
+		if strings.TrimSpace(sub.Keyword) == "" {
+			return fmt.Errorf("keyword is required")
+		}
+		if sub.ID != "" {
+			if ids[sub.ID] {
+				return fmt.Errorf("duplicate subscription ID: %s", sub.ID)
+			}
+			ids[sub.ID] = true
+		}
+
+		perUser[sub.UserID]++
+		if perUser[sub.UserID] > m.cfg.MaxPerUser {
+			return fmt.Errorf("user %d has more than %d subscriptions", sub.UserID, m.cfg.MaxPerUser)
+		}
+	}
+	return nil
+}
+
+// save persists all subscriptions (caller holds lock)
+func (m *Manager) save() error {
+	list := make([]*models.Subscription, 0, len(m.subs))
+	for _, sub := range m.subs {
+		list = append(list, sub)
+	}
+	return m.file.Save(list)
+}
+
+// Match evaluates an indexed message against all subscriptions and queues events
+func (m *Manager) Match(message *models.Message) {
+	if m == nil || message.IsDeleted {
+		return
+	}
+
+	content := message.Text
+	if message.Caption != nil {
+		content += "\n" + *message.Caption
+	}
+	if message.IsPoll {
+		content += "\n" + message.PollQuestion + "\n" + strings.Join(message.PollOptions, "\n")
+	}
+	if message.Transcript != "" {
+		content += "\n" + message.Transcript
+	}
+	if message.OCRText != "" {
+		content += "\n" + message.OCRText
+	}
+	if content == "" {
+		return
+	}
+	lower := strings.ToLower(content)
+
+	chatID := message.ChatID
+	if chatID == 0 {
+		chatID = message.Chat.ID
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	queued := false
+	for id, sub := range m.subs {
+		if !strings.Contains(lower, m.keys[id]) {
+			continue
+		}
+		// Don't notify users about their own messages
+		if message.SenderType == "user" && message.SenderID == sub.UserID {
+			continue
+		}
+		if len(sub.ChatIDs) > 0 && !containsChat(sub.ChatIDs, chatID) {
+			continue
+		}
+		if m.seen(id + "|" + message.ID) {
+			continue
+		}
+
+		m.seq++
+		m.events = append(m.events, models.SubscriptionEvent{
+			Seq:            m.seq,
+			SubscriptionID: id,
+			UserID:         sub.UserID,
+			Keyword:        sub.Keyword,
+			MessageID:      message.ID,
+			ChatID:         chatID,
+			ChatTitle:      message.ChatTitle,
+			SenderName:     message.SenderName,
+			Snippet:        snippet(content),
+			Timestamp:      message.Timestamp,
+		})
+		queued = true
+	}
+
+	if !queued {
+		return
+	}
+
+	// Drop the oldest events when the queue is full
+	if over := len(m.events) - m.cfg.QueueSize; over > 0 {
+		m.events = append([]models.SubscriptionEvent(nil), m.events[over:]...)
+		log.WithField("dropped", over).Warn("Subscription event queue full, dropped oldest events")
+	}
+
+	close(m.notify)
+	m.notify = make(chan struct{})
+}
+
+// seen records a subscription/message pair and reports whether it was already notified (caller holds lock)
+func (m *Manager) seen(key string) bool {
+	if _, ok := m.recent[key]; ok {
+		return true
+	}
+	m.recent[key] = struct{}{}
+	m.recentOrder = append(m.recentOrder, key)
+	if len(m.recentOrder) > recentCapacity {
+		delete(m.recent, m.recentOrder[0])
+		m.recentOrder = m.recentOrder[1:]
+	}
+	return false
+}
+
+// Poll returns events after the given sequence number, waiting up to timeout
+// for new events when none are available yet
+func (m *Manager) Poll(ctx context.Context, after int64, limit int, timeout time.Duration) models.SubscriptionEventsResponse {
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+
+	for {
+		m.mu.RLock()
+		events := m.after(after, limit)
+		notify := m.notify
+		m.mu.RUnlock()
+
+		if len(events) > 0 {
+			return models.SubscriptionEventsResponse{
+				Events: events,
+				Next:   events[len(events)-1].Seq,
+			}
+		}
+
+		select {
+		case <-notify:
+		case <-deadline.C:
+			return models.SubscriptionEventsResponse{Events: []models.SubscriptionEvent{}, Next: after}
+		case <-ctx.Done():
+			return models.SubscriptionEventsResponse{Events: []models.SubscriptionEvent{}, Next: after}
+		}
+	}
+}
+
+// after returns up to limit events with Seq > after (caller holds lock)
+func (m *Manager) after(after int64, limit int) []models.SubscriptionEvent {
+	var out []models.SubscriptionEvent
+	for _, event := range m.events {
+		if event.Seq <= after {
+			continue
+		}
+		out = append(out, event)
+		if len(out) >= limit {
+			break
+		}
+	}
+	return out
+}
+
+// StartWebhook delivers queued events to the configured webhook
+func (m *Manager) StartWebhook() {
+	if m.cfg.WebhookURL == "" {
+		return
+	}
+
+	m.stop = make(chan struct{})
+	m.done = make(chan struct{})
+
+	go func() {
+		defer close(m.done)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go func() {
+			<-m.stop
+			cancel()
+		}()
+
+		var cursor int64
+		for ctx.Err() == nil {
+			batch := m.Poll(ctx, cursor, 100, 30*time.Second)
+			if len(batch.Events) == 0 {
+				continue
+			}
+
+			// Retry until delivered so the webhook sees every event at least once
+			for ctx.Err() == nil {
+				if err := m.deliver(batch.Events); err != nil {
+					log.WithError(err).Warn("Failed to deliver subscription events, retrying")
+					select {
+					case <-time.After(m.cfg.WebhookWait):
+					case <-ctx.Done():
+					}
+					continue
+				}
+				cursor = batch.Next
+				break
+			}
+		}
+	}()
+
+	log.WithField("url", m.cfg.WebhookURL).Info("Subscription webhook delivery started")
+}
+
+// StopWebhook stops webhook delivery
+func (m *Manager) StopWebhook() {
+	if m == nil || m.stop == nil {
+		return
+	}
+	close(m.stop)
+	<-m.done
+}
+
+// deliver posts a batch of events to the webhook
+func (m *Manager) deliver(events []models.SubscriptionEvent) error {
+	body, err := json.Marshal(map[string]interface{}{"events": events})
+	if err != nil {
+		return err
+	}
+
+	resp, err := m.client.Post(m.cfg.WebhookURL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// snippet truncates content for notifications
+func snippet(content string) string {
+	runes := []rune(content)
+	if len(runes) <= snippetRunes {
+		return content
+	}
+	return string(runes[:snippetRunes]) + "…"
+}
+
+func containsChat(chatIDs []int64, chatID int64) bool {
+	for _, id := range chatIDs {
+		if id == chatID {
+			return true
+		}
+	}
+	return false
+}
+
+func equalChats(a, b []int64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
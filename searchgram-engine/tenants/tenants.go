@@ -0,0 +1,335 @@
+package tenants
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/zhishengyuan/searchgram-engine/engines"
+	"github.com/zhishengyuan/searchgram-engine/models"
+	"github.com/zhishengyuan/searchgram-engine/storage"
+	"github.com/zhishengyuan/searchgram-engine/usage"
+)
+
+const (
+	// ContextKey holds the ID of the tenant a request acts for
+	ContextKey = "tenant"
+
+	// EngineKey holds the search engine of the tenant a request acts for
+	EngineKey = "tenant_engine"
+
+	// keyPrefix tells tenant API keys apart from other credentials
+	keyPrefix = "sgt_"
+)
+
+var (
+	// ErrExists is returned when creating a tenant whose ID is taken
+	ErrExists = errors.New("tenant already exists")
+
+	// ErrNotFound is returned for tenants that do not exist
+	ErrNotFound = errors.New("tenant not found")
+
+	// ErrInvalidID is returned for tenant IDs that cannot name an index
+	ErrInvalidID = errors.New("tenant IDs are 1-32 lowercase letters, digits and underscores, starting with a letter, other than trash, audit, snapshot and default")
+)
+
+// idPattern keeps tenant indices apart from the versions and partitions of
+// the shared index, whose names contain dashes or dots
+var idPattern = regexp.MustCompile(`^[a-z][a-z0-9_]{0,31}$`)
+
+// reservedIDs would name indices the shared index derives from its name,
+// or the tenant usage and profiles record for callers without one
+var reservedIDs = map[string]bool{"trash": true, "audit": true, "snapshot": true, usage.DefaultTenant: true}
+
+// Engine is the search engine of one tenant
+type Engine interface {
+	engines.SearchEngine
+
+	// DropIndex deletes all indices of the tenant
+	DropIndex(ctx context.Context) error
+}
+
+// Factory connects to the index of a tenant, creating it if needed
+type Factory func(index string) (Engine, error)
+
+// record is a tenant as persisted, with the hash of its API key
+type record struct {
+	models.Tenant
+	KeyHash string `json:"key_hash"`
+}
+
+// Registry holds the tenants, persisted across restarts, and connects to
+// their indices on first use
+type Registry struct {
+	file    *storage.Document
+	index   string // Shared index tenant indices are named after
+	factory Factory
+
+	mu      sync.RWMutex
+	tenants map[string]record
+	engines map[string]Engine
+}
+
+// New loads the tenants from file. Tenant indices are named
+// <index>-<tenant ID>.
+func New(file *storage.Document, index string, factory Factory) (*Registry, error) {
+	r := &Registry{
+		file:    file,
+		index:   index,
+		factory: factory,
+		tenants: make(map[string]record),
+		engines: make(map[string]Engine),
+	}
+
+	var saved []record
+	if err := file.Load(&saved); err != nil {
+		return nil, err
+	}
+	for _, tenant := range saved {
+		r.tenants[tenant.ID] = tenant
+	}
+
+	log.WithFields(log.Fields{
+		"path":    file.Path(),
+		"tenants": len(r.tenants),
+	}).Info("Tenants loaded")
+
+	return r, nil
+}
+
+// ValidID reports whether id can name a tenant
+func ValidID(id string) bool {
+	return idPattern.MatchString(id) && !reservedIDs[id]
+}
+
+// List returns all tenants ordered by ID
+func (r *Registry) List() []models.Tenant {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	list := make([]models.Tenant, 0, len(r.tenants))
+	for _, tenant := range r.tenants {
+		list = append(list, tenant.Tenant)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].ID < list[j].ID
+	})
+	return list
+}
+
+// Get returns a tenant
+func (r *Registry) Get(id string) (models.Tenant, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	tenant, ok := r.tenants[id]
+	return tenant.Tenant, ok
+}
+
+// Authenticate returns the tenant an API key belongs to
+func (r *Registry) Authenticate(key string) (string, bool) {
+	if len(key) <= len(keyPrefix) || key[:len(keyPrefix)] != keyPrefix {
+		return "", false
+	}
+	hash := hashKey(key)
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for id, tenant := range r.tenants {
+		if subtle.ConstantTimeCompare([]byte(tenant.KeyHash), []byte(hash)) == 1 {
+			return id, true
+		}
+	}
+	return "", false
+}
+
+// Engine returns the search engine of a tenant, connecting on first use
+func (r *Registry) Engine(id string) (Engine, error) {
+	r.mu.RLock()
+	engine, ok := r.engines[id]
+	_, exists := r.tenants[id]
+	r.mu.RUnlock()
+	if ok {
+		return engine, nil
+	}
+	if !exists {
+		return nil, ErrNotFound
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.connect(id)
+}
+
+// connect returns the engine of a tenant, connecting unless connected
+// (caller holds lock)
+func (r *Registry) connect(id string) (Engine, error) {
+	if engine, ok := r.engines[id]; ok {
+		return engine, nil
+	}
+
+	engine, err := r.factory(r.indexOf(id))
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to index of tenant %s: %w", id, err)
+	}
+	r.engines[id] = engine
+	return engine, nil
+}
+
+// Create adds a tenant, creating its index, and returns it with its API key
+func (r *Registry) Create(id, name, createdBy string) (*models.TenantCreated, error) {
+	if !ValidID(id) {
+		return nil, ErrInvalidID
+	}
+	key, err := newKey()
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.tenants[id]; ok {
+		return nil, ErrExists
+	}
+
+	tenant := record{
+		Tenant: models.Tenant{
+			ID:        id,
+			Name:      name,
+			Index:     r.indexOf(id),
+			CreatedBy: createdBy,
+			CreatedAt: time.Now().Unix(),
+		},
+		KeyHash: hashKey(key),
+	}
+	if _, err := r.connect(id); err != nil {
+		return nil, err
+	}
+
+	r.tenants[id] = tenant
+	if err := r.save(); err != nil {
+		delete(r.tenants, id)
+		return nil, err
+	}
+
+	log.WithFields(log.Fields{
+		"tenant": id,
+		"index":  tenant.Index,
+	}).Info("Tenant created")
+
+	return &models.TenantCreated{Tenant: tenant.Tenant, APIKey: key}, nil
+}
+
+// Update renames a tenant
+func (r *Registry) Update(id, name string) (models.Tenant, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	tenant, ok := r.tenants[id]
+	if !ok {
+		return models.Tenant{}, ErrNotFound
+	}
+
+	previous := tenant
+	tenant.Name = name
+	r.tenants[id] = tenant
+	if err := r.save(); err != nil {
+		r.tenants[id] = previous
+		return models.Tenant{}, err
+	}
+	return tenant.Tenant, nil
+}
+
+// Delete removes a tenant, revoking its API key. With purge its indices are
+// deleted as well; otherwise they are kept for an admin to remove.
+func (r *Registry) Delete(ctx context.Context, id string, purge bool) (models.Tenant, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	tenant, ok := r.tenants[id]
+	if !ok {
+		return models.Tenant{}, ErrNotFound
+	}
+
+	if purge {
+		engine, err := r.connect(id)
+		if err != nil {
+			return models.Tenant{}, err
+		}
+		if err := engine.DropIndex(ctx); err != nil {
+			return models.Tenant{}, err
+		}
+	}
+
+	delete(r.tenants, id)
+	if err := r.save(); err != nil {
+		r.tenants[id] = tenant
+		return models.Tenant{}, err
+	}
+	if engine, ok := r.engines[id]; ok {
+		engine.Close()
+		delete(r.engines, id)
+	}
+
+	log.WithFields(log.Fields{
+		"tenant": id,
+		"purged": purge,
+	}).Info("Tenant deleted")
+
+	return tenant.Tenant, nil
+}
+
+// Close disconnects from the indices of all tenants
+func (r *Registry) Close() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for id, engine := range r.engines {
+		engine.Close()
+		delete(r.engines, id)
+	}
+}
+
+// indexOf returns the index holding the messages of a tenant
+func (r *Registry) indexOf(id string) string {
+	return r.index + "-" + id
+}
+
+// save persists all tenants (caller holds lock)
+func (r *Registry) save() error {
+	list := make([]record, 0, len(r.tenants))
+	for _, tenant := range r.tenants {
+		list = append(list, tenant)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].ID < list[j].ID
+	})
+	return r.file.Save(list)
+}
+
+// newKey returns a random tenant API key
+func newKey() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate API key: %w", err)
+	}
+	return keyPrefix + base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// hashKey returns the hex SHA-256 of an API key, which is all that is stored
+func hashKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
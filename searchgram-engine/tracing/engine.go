@@ -0,0 +1,387 @@
+package tracing
+
+import (
+	"context"
+	"time"
+
+	"github.com/zhishengyuan/searchgram-engine/engines"
+	"github.com/zhishengyuan/searchgram-engine/models"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Engine records a span per engine call as a child of the span in the
+// call's context (for searches and deletes by query, SearchRequest.Ctx),
+// which passes it on to Elasticsearch. Ping and Close are not recorded, so
+// health probes don't flood the collector.
+type Engine struct {
+	engines.SearchEngine
+}
+
+// Wrap returns engine with its calls traced
+func Wrap(engine engines.SearchEngine) engines.SearchEngine {
+	return &Engine{SearchEngine: engine}
+}
+
+// start starts the span of an engine call as a child of the span in ctx
+func start(ctx context.Context, method string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return tracer().Start(ctx, "engine."+method, trace.WithAttributes(attrs...))
+}
+
+// end records a failed call on its span and ends it
+func end(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// traced returns a copy of req whose context carries span ctx
+func traced(ctx context.Context, req *models.SearchRequest) *models.SearchRequest {
+	copied := *req
+	copied.Ctx = ctx
+	return &copied
+}
+
+// Upsert indexes a message
+func (e *Engine) Upsert(ctx context.Context, message *models.Message) error {
+	ctx, span := start(ctx, "Upsert", attribute.String("message.id", message.ID))
+	err := e.SearchEngine.Upsert(ctx, message)
+	end(span, err)
+	return err
+}
+
+// UpsertBatch indexes messages
+func (e *Engine) UpsertBatch(ctx context.Context, messages []models.Message) (int, []string, error) {
+	ctx, span := start(ctx, "UpsertBatch", attribute.Int("messages", len(messages)))
+	indexed, failures, err := e.SearchEngine.UpsertBatch(ctx, messages)
+	span.SetAttributes(attribute.Int("indexed", indexed))
+	end(span, err)
+	return indexed, failures, err
+}
+
+// GetMessage retrieves a message
+func (e *Engine) GetMessage(ctx context.Context, id string) (*models.Message, error) {
+	ctx, span := start(ctx, "GetMessage", attribute.String("message.id", id))
+	message, err := e.SearchEngine.GetMessage(ctx, id)
+	end(span, err)
+	return message, err
+}
+
+// GetMessageContext retrieves the messages around a message
+func (e *Engine) GetMessageContext(ctx context.Context, chatID, messageID int64, before, after int) ([]models.Message, []models.Message, error) {
+	ctx, span := start(ctx, "GetMessageContext", attribute.Int64("chat.id", chatID), attribute.Int64("message.id", messageID))
+	preceding, following, err := e.SearchEngine.GetMessageContext(ctx, chatID, messageID, before, after)
+	end(span, err)
+	return preceding, following, err
+}
+
+// UpdateMessage rewrites a message
+func (e *Engine) UpdateMessage(ctx context.Context, id string, fn func(message *models.Message)) (*models.Message, error) {
+	ctx, span := start(ctx, "UpdateMessage", attribute.String("message.id", id))
+	message, err := e.SearchEngine.UpdateMessage(ctx, id, fn)
+	end(span, err)
+	return message, err
+}
+
+// Search runs a search
+func (e *Engine) Search(req *models.SearchRequest) (*models.SearchResponse, error) {
+	ctx, span := start(req.Context(), "Search",
+		attribute.Bool("search.keyword", req.Keyword != ""),
+		attribute.Bool("search.semantic", req.Semantic),
+		attribute.Int("search.page", req.Page),
+		attribute.Int("search.page_size", req.PageSize),
+	)
+	result, err := e.SearchEngine.Search(traced(ctx, req))
+	if err == nil {
+		span.SetAttributes(attribute.Int64("search.total_hits", result.TotalHits))
+	}
+	end(span, err)
+	return result, err
+}
+
+// Delete deletes a chat's messages
+func (e *Engine) Delete(ctx context.Context, chatID int64) (int64, error) {
+	ctx, span := start(ctx, "Delete", attribute.Int64("chat.id", chatID))
+	count, err := e.SearchEngine.Delete(ctx, chatID)
+	end(span, err)
+	return count, err
+}
+
+// DeleteMessage deletes a message
+func (e *Engine) DeleteMessage(ctx context.Context, id string) (bool, error) {
+	ctx, span := start(ctx, "DeleteMessage", attribute.String("message.id", id))
+	deleted, err := e.SearchEngine.DeleteMessage(ctx, id)
+	end(span, err)
+	return deleted, err
+}
+
+// DeleteByQuery deletes matching messages
+func (e *Engine) DeleteByQuery(req *models.SearchRequest, dryRun bool) (int64, error) {
+	ctx, span := start(req.Context(), "DeleteByQuery", attribute.Bool("dry_run", dryRun))
+	count, err := e.SearchEngine.DeleteByQuery(traced(ctx, req), dryRun)
+	end(span, err)
+	return count, err
+}
+
+// SoftDeleteByQuery marks matching messages as deleted
+func (e *Engine) SoftDeleteByQuery(req *models.SearchRequest) (int64, error) {
+	ctx, span := start(req.Context(), "SoftDeleteByQuery")
+	count, err := e.SearchEngine.SoftDeleteByQuery(traced(ctx, req))
+	end(span, err)
+	return count, err
+}
+
+// RestoreDeleted restores soft-deleted messages
+func (e *Engine) RestoreDeleted(filter *models.DeletedFilter, dryRun bool) (int64, error) {
+	ctx, span := start(filter.Context(), "RestoreDeleted", attribute.Bool("dry_run", dryRun))
+	copied := *filter
+	copied.SearchRequest = *traced(ctx, &filter.SearchRequest)
+	count, err := e.SearchEngine.RestoreDeleted(&copied, dryRun)
+	end(span, err)
+	return count, err
+}
+
+// PurgeDeleted removes soft-deleted messages
+func (e *Engine) PurgeDeleted(filter *models.DeletedFilter, dryRun bool) (int64, error) {
+	ctx, span := start(filter.Context(), "PurgeDeleted", attribute.Bool("dry_run", dryRun))
+	copied := *filter
+	copied.SearchRequest = *traced(ctx, &filter.SearchRequest)
+	count, err := e.SearchEngine.PurgeDeleted(&copied, dryRun)
+	end(span, err)
+	return count, err
+}
+
+// DeleteUser deletes a sender's messages
+func (e *Engine) DeleteUser(ctx context.Context, userID int64) (int64, error) {
+	ctx, span := start(ctx, "DeleteUser", attribute.Int64("user.id", userID))
+	count, err := e.SearchEngine.DeleteUser(ctx, userID)
+	end(span, err)
+	return count, err
+}
+
+// MoveToTrash moves messages to the recycle bin
+func (e *Engine) MoveToTrash(ctx context.Context, selector models.TrashSelector, operation, target string, ttl time.Duration) (*models.TrashBatch, error) {
+	ctx, span := start(ctx, "MoveToTrash", attribute.String("trash.operation", operation))
+	batch, err := e.SearchEngine.MoveToTrash(ctx, selector, operation, target, ttl)
+	end(span, err)
+	return batch, err
+}
+
+// ListTrash lists recycle bin batches
+func (e *Engine) ListTrash(ctx context.Context) ([]models.TrashBatch, error) {
+	ctx, span := start(ctx, "ListTrash")
+	batches, err := e.SearchEngine.ListTrash(ctx)
+	end(span, err)
+	return batches, err
+}
+
+// RestoreTrashBatch restores a recycle bin batch
+func (e *Engine) RestoreTrashBatch(ctx context.Context, batchID string) (int64, int64, error) {
+	ctx, span := start(ctx, "RestoreTrashBatch", attribute.String("trash.batch", batchID))
+	restored, skipped, err := e.SearchEngine.RestoreTrashBatch(ctx, batchID)
+	end(span, err)
+	return restored, skipped, err
+}
+
+// RestoreTrashMessage restores a message from the recycle bin
+func (e *Engine) RestoreTrashMessage(ctx context.Context, id string) (int64, int64, error) {
+	ctx, span := start(ctx, "RestoreTrashMessage", attribute.String("message.id", id))
+	restored, skipped, err := e.SearchEngine.RestoreTrashMessage(ctx, id)
+	end(span, err)
+	return restored, skipped, err
+}
+
+// PurgeTrash removes expired recycle bin messages
+func (e *Engine) PurgeTrash(ctx context.Context, now time.Time, keepChats []int64) (int64, []string, error) {
+	ctx, span := start(ctx, "PurgeTrash")
+	count, kept, err := e.SearchEngine.PurgeTrash(ctx, now, keepChats)
+	end(span, err)
+	return count, kept, err
+}
+
+// Clear removes all messages
+func (e *Engine) Clear(ctx context.Context) error {
+	ctx, span := start(ctx, "Clear")
+	err := e.SearchEngine.Clear(ctx)
+	end(span, err)
+	return err
+}
+
+// RecreateIndex recreates the index empty
+func (e *Engine) RecreateIndex(ctx context.Context) error {
+	ctx, span := start(ctx, "RecreateIndex")
+	err := e.SearchEngine.RecreateIndex(ctx)
+	end(span, err)
+	return err
+}
+
+// Stats returns index statistics
+func (e *Engine) Stats(ctx context.Context) (*models.StatsResponse, error) {
+	ctx, span := start(ctx, "Stats")
+	stats, err := e.SearchEngine.Stats(ctx)
+	end(span, err)
+	return stats, err
+}
+
+// Dedup removes duplicate messages
+func (e *Engine) Dedup(ctx context.Context, dryRun bool, progress func(*models.DedupResponse)) (*models.DedupResponse, error) {
+	ctx, span := start(ctx, "Dedup", attribute.Bool("dry_run", dryRun))
+	result, err := e.SearchEngine.Dedup(ctx, dryRun, progress)
+	end(span, err)
+	return result, err
+}
+
+// GetUserStats returns a user's activity in a chat
+func (e *Engine) GetUserStats(ctx context.Context, req *models.UserStatsRequest) (*models.UserStatsResponse, error) {
+	ctx, span := start(ctx, "GetUserStats")
+	stats, err := e.SearchEngine.GetUserStats(ctx, req)
+	end(span, err)
+	return stats, err
+}
+
+// SoftDeleteMessage marks a message as deleted
+func (e *Engine) SoftDeleteMessage(ctx context.Context, chatID int64, messageID int64) error {
+	ctx, span := start(ctx, "SoftDeleteMessage", attribute.Int64("chat.id", chatID), attribute.Int64("message.id", messageID))
+	err := e.SearchEngine.SoftDeleteMessage(ctx, chatID, messageID)
+	end(span, err)
+	return err
+}
+
+// CleanCommands removes bot commands
+func (e *Engine) CleanCommands(ctx context.Context) (*models.CleanCommandsResponse, error) {
+	ctx, span := start(ctx, "CleanCommands")
+	result, err := e.SearchEngine.CleanCommands(ctx)
+	end(span, err)
+	return result, err
+}
+
+// ListChats lists indexed chats
+func (e *Engine) ListChats(ctx context.Context, titleContains string, limit int) ([]models.ChatSummary, error) {
+	ctx, span := start(ctx, "ListChats", attribute.Int("limit", limit))
+	chats, err := e.SearchEngine.ListChats(ctx, titleContains, limit)
+	end(span, err)
+	return chats, err
+}
+
+// ChatActivity counts a chat's messages per interval
+func (e *Engine) ChatActivity(ctx context.Context, req *models.ActivityRequest) (*models.ChatActivity, error) {
+	ctx, span := start(ctx, "ChatActivity", attribute.Int64("chat.id", req.ChatID))
+	activity, err := e.SearchEngine.ChatActivity(ctx, req)
+	end(span, err)
+	return activity, err
+}
+
+// TrendingTerms finds a chat's trending terms
+func (e *Engine) TrendingTerms(ctx context.Context, req *models.TrendingRequest) (*models.TrendingTerms, error) {
+	ctx, span := start(ctx, "TrendingTerms", attribute.Int64("chat.id", req.ChatID))
+	terms, err := e.SearchEngine.TrendingTerms(ctx, req)
+	end(span, err)
+	return terms, err
+}
+
+// GetMessageIDs lists a chat's message IDs
+func (e *Engine) GetMessageIDs(ctx context.Context, chatID int64) (*models.GetMessageIDsResponse, error) {
+	ctx, span := start(ctx, "GetMessageIDs", attribute.Int64("chat.id", chatID))
+	ids, err := e.SearchEngine.GetMessageIDs(ctx, chatID)
+	end(span, err)
+	return ids, err
+}
+
+// ScanMessages passes every message to fn
+func (e *Engine) ScanMessages(ctx context.Context, fn func(messages []models.Message) error) error {
+	ctx, span := start(ctx, "ScanMessages")
+	err := e.SearchEngine.ScanMessages(ctx, fn)
+	end(span, err)
+	return err
+}
+
+// ScanUserMessages passes a user's messages to fn
+func (e *Engine) ScanUserMessages(ctx context.Context, userID int64, fn func(messages []models.Message) error) error {
+	ctx, span := start(ctx, "ScanUserMessages", attribute.Int64("user.id", userID))
+	err := e.SearchEngine.ScanUserMessages(ctx, userID, fn)
+	end(span, err)
+	return err
+}
+
+// ForceMerge merges index segments
+func (e *Engine) ForceMerge(ctx context.Context, maxSegments int) (*models.ForceMergeResult, error) {
+	ctx, span := start(ctx, "ForceMerge")
+	result, err := e.SearchEngine.ForceMerge(ctx, maxSegments)
+	end(span, err)
+	return result, err
+}
+
+// Snapshot snapshots the index
+func (e *Engine) Snapshot(ctx context.Context, repository string, keep int) (*models.SnapshotResult, error) {
+	ctx, span := start(ctx, "Snapshot", attribute.String("snapshot.repository", repository))
+	result, err := e.SearchEngine.Snapshot(ctx, repository, keep)
+	end(span, err)
+	return result, err
+}
+
+// RestoreSnapshot restores the index from a snapshot
+func (e *Engine) RestoreSnapshot(ctx context.Context, repository, snapshot string) error {
+	ctx, span := start(ctx, "RestoreSnapshot", attribute.String("snapshot.repository", repository), attribute.String("snapshot.name", snapshot))
+	err := e.SearchEngine.RestoreSnapshot(ctx, repository, snapshot)
+	end(span, err)
+	return err
+}
+
+// Reindex copies messages into a new index
+func (e *Engine) Reindex(ctx context.Context, progress func(*models.ReindexProgress)) (*models.ReindexResult, error) {
+	ctx, span := start(ctx, "Reindex")
+	result, err := e.SearchEngine.Reindex(ctx, progress)
+	end(span, err)
+	return result, err
+}
+
+// ListIndexVersions lists index versions
+func (e *Engine) ListIndexVersions(ctx context.Context) ([]models.IndexVersion, error) {
+	ctx, span := start(ctx, "ListIndexVersions")
+	versions, err := e.SearchEngine.ListIndexVersions(ctx)
+	end(span, err)
+	return versions, err
+}
+
+// RollbackIndex switches to another index version
+func (e *Engine) RollbackIndex(ctx context.Context, index string) (*models.IndexRollback, error) {
+	ctx, span := start(ctx, "RollbackIndex", attribute.String("index", index))
+	result, err := e.SearchEngine.RollbackIndex(ctx, index)
+	end(span, err)
+	return result, err
+}
+
+// PauseLifecycle detaches or attaches the partition lifecycle policy
+func (e *Engine) PauseLifecycle(ctx context.Context, paused bool) (int, error) {
+	ctx, span := start(ctx, "PauseLifecycle", attribute.Bool("paused", paused))
+	changed, err := e.SearchEngine.PauseLifecycle(ctx, paused)
+	end(span, err)
+	return changed, err
+}
+
+// ListSnapshots lists snapshots of the index
+func (e *Engine) ListSnapshots(ctx context.Context, repository string) ([]models.SnapshotInfo, error) {
+	ctx, span := start(ctx, "ListSnapshots", attribute.String("snapshot.repository", repository))
+	snapshots, err := e.SearchEngine.ListSnapshots(ctx, repository)
+	end(span, err)
+	return snapshots, err
+}
+
+// MountSnapshot mounts a snapshot for searches
+func (e *Engine) MountSnapshot(ctx context.Context, repository, snapshot string) (*models.SnapshotMount, error) {
+	ctx, span := start(ctx, "MountSnapshot", attribute.String("snapshot.repository", repository), attribute.String("snapshot.name", snapshot))
+	mount, err := e.SearchEngine.MountSnapshot(ctx, repository, snapshot)
+	end(span, err)
+	return mount, err
+}
+
+// UnmountSnapshot removes a mounted snapshot
+func (e *Engine) UnmountSnapshot(ctx context.Context, snapshot string) error {
+	ctx, span := start(ctx, "UnmountSnapshot", attribute.String("snapshot.name", snapshot))
+	err := e.SearchEngine.UnmountSnapshot(ctx, snapshot)
+	end(span, err)
+	return err
+}
@@ -0,0 +1,84 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// instrumentationName names the tracer of every span made here
+const instrumentationName = "github.com/zhishengyuan/searchgram-engine"
+
+// Config holds the collector spans are exported to
+type Config struct {
+	Endpoint    string            // OTLP/HTTP traces URL, e.g. http://localhost:4318/v1/traces
+	Headers     map[string]string // Sent with every export, e.g. an API key of a hosted backend
+	ServiceName string            // service.name of the spans
+	SampleRatio float64           // Share of traces started here that are recorded (0-1)
+	Timeout     time.Duration     // Deadline per export
+}
+
+// Provider records the spans of sampled traces and exports them in batches
+type Provider struct {
+	sdk *sdktrace.TracerProvider
+}
+
+// New makes a provider the global one, so the middleware, the engine
+// wrapper and the Elasticsearch transport record spans, and makes incoming
+// and outgoing requests carry W3C trace context
+func New(cfg Config) (*Provider, error) {
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(
+		semconv.SchemaURL,
+		semconv.ServiceName(cfg.ServiceName),
+	))
+	if err != nil {
+		return nil, fmt.Errorf("failed to describe service: %w", err)
+	}
+
+	exporter, err := otlptracehttp.New(context.Background(),
+		otlptracehttp.WithEndpointURL(cfg.Endpoint),
+		otlptracehttp.WithHeaders(cfg.Headers),
+		otlptracehttp.WithTimeout(cfg.Timeout),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create span exporter: %w", err)
+	}
+
+	sdk := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		// Requests arriving with a sampled trace are recorded regardless
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(cfg.SampleRatio))),
+	)
+	otel.SetTracerProvider(sdk)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	otel.SetErrorHandler(otel.ErrorHandlerFunc(func(err error) {
+		log.WithError(err).Warn("Tracing error")
+	}))
+	return &Provider{sdk: sdk}, nil
+}
+
+// Stop exports the spans still buffered
+func (p *Provider) Stop(ctx context.Context) {
+	if p == nil {
+		return
+	}
+	if err := p.sdk.Shutdown(ctx); err != nil {
+		log.WithError(err).Warn("Failed to export the remaining spans")
+	}
+}
+
+// tracer returns the tracer of the global provider, a no-op one unless New
+// was called
+func tracer() trace.Tracer {
+	return otel.Tracer(instrumentationName)
+}
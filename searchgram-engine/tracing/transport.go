@@ -0,0 +1,71 @@
+package tracing
+
+import (
+	"net/http"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// transport records a client span per request to Elasticsearch and passes
+// the trace on in the traceparent header, so Elasticsearch's own tracing,
+// where enabled, joins it
+type transport struct {
+	base http.RoundTripper
+}
+
+// HTTPClient returns a client for Elasticsearch requests whose spans are
+// children of the span in the request context
+func HTTPClient() *http.Client {
+	return &http.Client{Transport: &transport{base: http.DefaultTransport}}
+}
+
+// endpoint names the API a request path calls, e.g. _search or _bulk,
+// without the index and document IDs in it
+func endpoint(path string) string {
+	for _, segment := range strings.Split(path, "/") {
+		if strings.HasPrefix(segment, "_") {
+			return segment
+		}
+	}
+	return "index"
+}
+
+// RoundTrip implements http.RoundTripper. Requests outside a trace, such as
+// the client's health checks, are not recorded.
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !trace.SpanContextFromContext(req.Context()).IsValid() {
+		return t.base.RoundTrip(req)
+	}
+
+	ctx, span := tracer().Start(req.Context(), "elasticsearch "+req.Method+" "+endpoint(req.URL.Path),
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.DBSystemElasticsearch,
+			semconv.HTTPRequestMethodKey.String(req.Method),
+			semconv.URLPath(req.URL.Path),
+			semconv.ServerAddress(req.URL.Hostname()),
+		),
+	)
+	defer span.End()
+
+	// RoundTrippers must not modify the request they are given
+	req = req.Clone(ctx)
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+	span.SetAttributes(semconv.HTTPResponseStatusCode(resp.StatusCode))
+	if resp.StatusCode >= http.StatusInternalServerError {
+		span.SetStatus(codes.Error, resp.Status)
+	}
+	return resp, nil
+}
@@ -0,0 +1,62 @@
+package translit
+
+import (
+	"strings"
+	"unicode"
+)
+
+// layout maps the keys of a QWERTY keyboard to the Cyrillic letters they
+// type in another layout
+type layout struct {
+	cyrillic map[rune]rune // QWERTY key -> Cyrillic letter
+	latin    map[rune]rune // Cyrillic letter -> QWERTY key
+}
+
+// newLayout builds a layout from the QWERTY keys and the Cyrillic letters
+// they type, in the same order
+func newLayout(keys, letters string) layout {
+	l := layout{cyrillic: make(map[rune]rune), latin: make(map[rune]rune)}
+	cyrillic := []rune(letters)
+	for i, key := range []rune(keys) {
+		l.cyrillic[key] = cyrillic[i]
+		l.latin[cyrillic[i]] = key
+	}
+	return l
+}
+
+const qwertyKeys = "qwertyuiop[]asdfghjkl;'zxcvbnm,.`"
+
+// layouts are the supported Cyrillic layouts by language
+var layouts = map[string]layout{
+	Russian:   newLayout(qwertyKeys, "йцукенгшщзхъфывапролджэячсмитьбюё"),
+	Ukrainian: newLayout(qwertyKeys[:len(qwertyKeys)-1], "йцукенгшщзхїфівапролджєячсмитьбю"),
+}
+
+// toCyrillic retypes a Latin keyword on the Cyrillic layout. It fails when
+// a letter has no key on it.
+func (l layout) toCyrillic(s string) (string, bool) {
+	return l.retype(s, l.cyrillic)
+}
+
+// toLatin retypes a Cyrillic keyword on QWERTY. It fails when a letter has
+// no key on the layout.
+func (l layout) toLatin(s string) (string, bool) {
+	return l.retype(s, l.latin)
+}
+
+// retype maps every key in s; digits and spaces are kept, other
+// characters are kept unless keys maps them
+func (l layout) retype(s string, keys map[rune]rune) (string, bool) {
+	var b strings.Builder
+	for _, r := range s {
+		if mapped, ok := keys[r]; ok {
+			b.WriteRune(mapped)
+			continue
+		}
+		if unicode.IsLetter(r) {
+			return "", false
+		}
+		b.WriteRune(r)
+	}
+	return b.String(), true
+}
@@ -0,0 +1,131 @@
+package translit
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Languages whose keyboard layouts and transliterations are supported
+const (
+	Russian   = "ru"
+	Ukrainian = "uk"
+)
+
+// ValidLanguage reports whether lang is a supported language
+func ValidLanguage(lang string) bool {
+	return lang == Russian || lang == Ukrainian
+}
+
+// Config selects the input slips an Expander undoes
+type Config struct {
+	// Layouts are the Cyrillic keyboard layouts whose slips with QWERTY are
+	// undone, e.g. "ghbdtn" typed for "привет" and "руддщ" for "hello"
+	Layouts []string
+
+	// Transliteration are the languages whose Latin spelling and Cyrillic
+	// script are searched for each other, e.g. "privet" and "привет"
+	Transliteration []string
+}
+
+// Expander turns a keyword into the spellings it may have been meant as,
+// for multilingual groups where people type on the wrong keyboard layout
+// or write Russian and Ukrainian in Latin letters
+type Expander struct {
+	layouts         []layout
+	transliteration []language
+}
+
+// New creates an Expander for cfg; unsupported languages are ignored
+func New(cfg Config) *Expander {
+	x := &Expander{}
+	for _, lang := range cfg.Layouts {
+		if l, ok := layouts[lang]; ok {
+			x.layouts = append(x.layouts, l)
+		}
+	}
+	for _, lang := range cfg.Transliteration {
+		if l, ok := languages[lang]; ok {
+			x.transliteration = append(x.transliteration, l)
+		}
+	}
+	return x
+}
+
+// Enabled reports whether the Expander undoes any slip
+func (x *Expander) Enabled() bool {
+	return len(x.layouts) > 0 || len(x.transliteration) > 0
+}
+
+// Expand returns the spellings keyword may have been meant as, lowercased
+// and without keyword itself. Keywords mixing Latin and Cyrillic letters,
+// or containing other scripts, are not expanded.
+func (x *Expander) Expand(keyword string) []string {
+	keyword = strings.ToLower(strings.TrimSpace(keyword))
+	script := scriptOf(keyword)
+	if script == otherScript {
+		return nil
+	}
+
+	var expansions []string
+	seen := map[string]bool{keyword: true}
+	add := func(s string, ok bool) {
+		if ok && s != "" && !seen[s] {
+			seen[s] = true
+			expansions = append(expansions, s)
+		}
+	}
+
+	for _, l := range x.layouts {
+		if script == latinScript {
+			add(l.toCyrillic(keyword))
+		} else {
+			add(l.toLatin(keyword))
+		}
+	}
+	// Letters of another language, such as і in Russian, are left as they
+	// are; such half-converted spellings are dropped
+	for _, l := range x.transliteration {
+		if script == latinScript {
+			s := l.toCyrillic(keyword)
+			add(s, scriptOf(s) == cyrillicScript)
+		} else {
+			s := l.toLatin(keyword)
+			add(s, scriptOf(s) == latinScript)
+		}
+	}
+	return expansions
+}
+
+// script is the alphabet the letters of a keyword are written in
+type script int
+
+const (
+	otherScript script = iota
+	latinScript
+	cyrillicScript
+)
+
+// scriptOf returns the alphabet of the letters in s, or otherScript when
+// it has none or mixes alphabets
+func scriptOf(s string) script {
+	found := otherScript
+	for _, r := range s {
+		if !unicode.IsLetter(r) {
+			continue
+		}
+		current := otherScript
+		switch {
+		case r < unicode.MaxASCII:
+			current = latinScript
+		case unicode.Is(unicode.Cyrillic, r):
+			current = cyrillicScript
+		default:
+			return otherScript
+		}
+		if found != otherScript && found != current {
+			return otherScript
+		}
+		found = current
+	}
+	return found
+}
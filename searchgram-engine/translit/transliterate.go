@@ -0,0 +1,126 @@
+package translit
+
+import (
+	"strings"
+)
+
+// rule replaces a Latin spelling with a Cyrillic letter
+type rule struct {
+	latin    string
+	cyrillic string
+}
+
+// language holds the transliteration of one language between Latin
+// spelling and Cyrillic script
+type language struct {
+	rules       []rule          // Latin -> Cyrillic, longest spellings first
+	latin       map[rune]string // Cyrillic -> Latin
+	vowel       map[rune]bool   // Latin vowels, after which y is a consonant
+	yAfterVowel string          // Cyrillic letter for y following a vowel
+}
+
+// languages are the supported transliterations by language. They follow
+// the spellings people commonly type rather than one standard, so "g" is
+// read as г and "kh" and "h" both as х in Russian.
+var languages = map[string]language{
+	Russian: {
+		rules: []rule{
+			{"shch", "щ"}, {"sch", "щ"},
+			{"zh", "ж"}, {"kh", "х"}, {"ts", "ц"}, {"ch", "ч"}, {"sh", "ш"},
+			{"yu", "ю"}, {"ya", "я"}, {"yo", "ё"}, {"ye", "е"},
+			{"a", "а"}, {"b", "б"}, {"c", "ц"}, {"d", "д"}, {"e", "е"},
+			{"f", "ф"}, {"g", "г"}, {"h", "х"}, {"i", "и"}, {"j", "й"},
+			{"k", "к"}, {"l", "л"}, {"m", "м"}, {"n", "н"}, {"o", "о"},
+			{"p", "п"}, {"q", "к"}, {"r", "р"}, {"s", "с"}, {"t", "т"},
+			{"u", "у"}, {"v", "в"}, {"w", "в"}, {"x", "кс"}, {"y", "ы"},
+			{"z", "з"}, {"'", "ь"},
+		},
+		latin: map[rune]string{
+			'а': "a", 'б': "b", 'в': "v", 'г': "g", 'д': "d", 'е': "e",
+			'ё': "yo", 'ж': "zh", 'з': "z", 'и': "i", 'й': "y", 'к': "k",
+			'л': "l", 'м': "m", 'н': "n", 'о': "o", 'п': "p", 'р': "r",
+			'с': "s", 'т': "t", 'у': "u", 'ф': "f", 'х': "kh", 'ц': "ts",
+			'ч': "ch", 'ш': "sh", 'щ': "shch", 'ъ': "", 'ы': "y", 'ь': "",
+			'э': "e", 'ю': "yu", 'я': "ya",
+		},
+		vowel:       vowels("aeiou"),
+		yAfterVowel: "й",
+	},
+	Ukrainian: {
+		rules: []rule{
+			{"shch", "щ"},
+			{"zh", "ж"}, {"kh", "х"}, {"ts", "ц"}, {"ch", "ч"}, {"sh", "ш"},
+			{"yu", "ю"}, {"ya", "я"}, {"yi", "ї"}, {"ye", "є"},
+			{"a", "а"}, {"b", "б"}, {"c", "ц"}, {"d", "д"}, {"e", "е"},
+			{"f", "ф"}, {"g", "г"}, {"h", "г"}, {"i", "і"}, {"j", "й"},
+			{"k", "к"}, {"l", "л"}, {"m", "м"}, {"n", "н"}, {"o", "о"},
+			{"p", "п"}, {"q", "к"}, {"r", "р"}, {"s", "с"}, {"t", "т"},
+			{"u", "у"}, {"v", "в"}, {"w", "в"}, {"x", "кс"}, {"y", "и"},
+			{"z", "з"}, {"'", "ь"},
+		},
+		latin: map[rune]string{
+			'а': "a", 'б': "b", 'в': "v", 'г': "h", 'ґ': "g", 'д': "d",
+			'е': "e", 'є': "ye", 'ж': "zh", 'з': "z", 'и': "y", 'і': "i",
+			'ї': "yi", 'й': "y", 'к': "k", 'л': "l", 'м': "m", 'н': "n",
+			'о': "o", 'п': "p", 'р': "r", 'с': "s", 'т': "t", 'у': "u",
+			'ф': "f", 'х': "kh", 'ц': "ts", 'ч': "ch", 'ш': "sh", 'щ': "shch",
+			'ь': "", 'ю': "yu", 'я': "ya",
+		},
+		vowel:       vowels("aeiou"),
+		yAfterVowel: "й",
+	},
+}
+
+// vowels returns the set of the given letters
+func vowels(letters string) map[rune]bool {
+	set := make(map[rune]bool, len(letters))
+	for _, r := range letters {
+		set[r] = true
+	}
+	return set
+}
+
+// toCyrillic spells a lowercase Latin keyword in Cyrillic, applying the
+// longest matching spelling at each position. A lone y after a vowel, as
+// in "sergey", is read as й.
+func (l language) toCyrillic(s string) string {
+	var b strings.Builder
+	var previous rune
+	for i := 0; i < len(s); {
+		matched := false
+		for _, r := range l.rules {
+			if !strings.HasPrefix(s[i:], r.latin) {
+				continue
+			}
+			if r.latin == "y" && l.vowel[previous] {
+				b.WriteString(l.yAfterVowel)
+			} else {
+				b.WriteString(r.cyrillic)
+			}
+			previous = rune(r.latin[len(r.latin)-1])
+			i += len(r.latin)
+			matched = true
+			break
+		}
+		if !matched {
+			r := []rune(s[i:])[0]
+			b.WriteRune(r)
+			previous = r
+			i += len(string(r))
+		}
+	}
+	return b.String()
+}
+
+// toLatin spells a lowercase Cyrillic keyword in Latin letters
+func (l language) toLatin(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if latin, ok := l.latin[r]; ok {
+			b.WriteString(latin)
+		} else {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
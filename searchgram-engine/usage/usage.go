@@ -0,0 +1,301 @@
+package usage
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// DefaultTenant is used for callers that carry no identity (legacy API key or no auth)
+const DefaultTenant = "default"
+
+// Record represents the usage of a single tenant over one reporting period
+type Record struct {
+	Tenant           string    `json:"tenant"`
+	PeriodStart      time.Time `json:"period_start"`
+	PeriodEnd        time.Time `json:"period_end"`
+	Searches         int64     `json:"searches"`
+	DocumentsIndexed int64     `json:"documents_indexed"`
+	StorageBytes     int64     `json:"storage_bytes"`
+}
+
+// StorageFunc reports the storage used by a tenant in bytes
+type StorageFunc func(tenant string) (int64, error)
+
+// Config holds usage exporter configuration
+type Config struct {
+	Interval time.Duration // How often records are emitted
+	Sink     string        // "webhook" (JSON) or "csv"
+	URL      string        // Destination URL for the records
+	Timeout  time.Duration // HTTP timeout for a single delivery
+}
+
+type counters struct {
+	searches int64
+	indexed  int64
+}
+
+// Recorder accumulates per-tenant usage and periodically exports it
+type Recorder struct {
+	mu          sync.Mutex
+	tenants     map[string]*counters
+	periodStart time.Time
+
+	cfg     Config
+	storage StorageFunc
+	client  *http.Client
+	stop    chan struct{}
+	done    chan struct{}
+}
+
+// NewRecorder creates a new usage recorder
+func NewRecorder(cfg Config, storage StorageFunc) *Recorder {
+	if cfg.Interval <= 0 {
+		cfg.Interval = time.Hour
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 10 * time.Second
+	}
+	if cfg.Sink == "" {
+		cfg.Sink = "webhook"
+	}
+
+	return &Recorder{
+		tenants:     make(map[string]*counters),
+		periodStart: time.Now().UTC(),
+		cfg:         cfg,
+		storage:     storage,
+		client:      &http.Client{Timeout: cfg.Timeout},
+	}
+}
+
+// RecordSearch counts one search for the tenant
+func (r *Recorder) RecordSearch(tenant string) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	r.tenant(tenant).searches++
+	r.mu.Unlock()
+}
+
+// RecordIndexed counts indexed documents for the tenant
+func (r *Recorder) RecordIndexed(tenant string, count int) {
+	if r == nil || count <= 0 {
+		return
+	}
+	r.mu.Lock()
+	r.tenant(tenant).indexed += int64(count)
+	r.mu.Unlock()
+}
+
+// tenant returns the counters for a tenant, creating them if needed (caller holds lock)
+func (r *Recorder) tenant(tenant string) *counters {
+	if tenant == "" {
+		tenant = DefaultTenant
+	}
+	c, ok := r.tenants[tenant]
+	if !ok {
+		c = &counters{}
+		r.tenants[tenant] = c
+	}
+	return c
+}
+
+// Snapshot returns the records for the current period without resetting counters
+func (r *Recorder) Snapshot() []Record {
+	r.mu.Lock()
+	records := r.collect(time.Now().UTC())
+	r.mu.Unlock()
+
+	r.fillStorage(records)
+	return records
+}
+
+// rotate returns the records for the current period and starts a new one
+func (r *Recorder) rotate() []Record {
+	r.mu.Lock()
+	now := time.Now().UTC()
+	records := r.collect(now)
+	r.tenants = make(map[string]*counters)
+	r.periodStart = now
+	r.mu.Unlock()
+
+	r.fillStorage(records)
+	return records
+}
+
+// restore adds the counters of undelivered records back into the current period
+func (r *Recorder) restore(records []Record) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, rec := range records {
+		c := r.tenant(rec.Tenant)
+		c.searches += rec.Searches
+		c.indexed += rec.DocumentsIndexed
+		if rec.PeriodStart.Before(r.periodStart) {
+			r.periodStart = rec.PeriodStart
+		}
+	}
+}
+
+// collect builds records from the counters (caller holds lock)
+func (r *Recorder) collect(now time.Time) []Record {
+	// Always report the default tenant so storage is emitted even for idle periods
+	r.tenant(DefaultTenant)
+
+	records := make([]Record, 0, len(r.tenants))
+	for tenant, c := range r.tenants {
+		records = append(records, Record{
+			Tenant:           tenant,
+			PeriodStart:      r.periodStart,
+			PeriodEnd:        now,
+			Searches:         c.searches,
+			DocumentsIndexed: c.indexed,
+		})
+	}
+
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].Tenant < records[j].Tenant
+	})
+	return records
+}
+
+// fillStorage populates storage bytes for each record
+func (r *Recorder) fillStorage(records []Record) {
+	if r.storage == nil {
+		return
+	}
+	for i := range records {
+		size, err := r.storage(records[i].Tenant)
+		if err != nil {
+			log.WithError(err).WithField("tenant", records[i].Tenant).Warn("Failed to measure tenant storage")
+			continue
+		}
+		records[i].StorageBytes = size
+	}
+}
+
+// Start begins periodic export of usage records
+func (r *Recorder) Start() {
+	if r.cfg.URL == "" {
+		log.Warn("Usage export enabled but no URL configured, records are only available via the API")
+		return
+	}
+
+	r.stop = make(chan struct{})
+	r.done = make(chan struct{})
+
+	go func() {
+		defer close(r.done)
+
+		ticker := time.NewTicker(r.cfg.Interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				r.Flush()
+			case <-r.stop:
+				r.Flush()
+				return
+			}
+		}
+	}()
+
+	log.WithFields(log.Fields{
+		"interval": r.cfg.Interval.String(),
+		"sink":     r.cfg.Sink,
+	}).Info("Usage exporter started")
+}
+
+// Stop stops the exporter, flushing the current period
+func (r *Recorder) Stop() {
+	if r == nil || r.stop == nil {
+		return
+	}
+	close(r.stop)
+	<-r.done
+}
+
+// Flush exports the current period's records; undelivered records are kept for the next attempt
+func (r *Recorder) Flush() {
+	records := r.rotate()
+	if err := r.send(records); err != nil {
+		log.WithError(err).Warn("Failed to export usage records, will retry next period")
+		r.restore(records)
+		return
+	}
+
+	log.WithField("tenants", len(records)).Info("Exported usage records")
+}
+
+// send delivers records to the configured sink
+func (r *Recorder) send(records []Record) error {
+	var (
+		body        []byte
+		contentType string
+		err         error
+	)
+
+	switch r.cfg.Sink {
+	case "csv":
+		body, err = EncodeCSV(records)
+		contentType = "text/csv"
+	case "webhook":
+		body, err = json.Marshal(map[string]interface{}{"records": records})
+		contentType = "application/json"
+	default:
+		return fmt.Errorf("unsupported usage sink: %s", r.cfg.Sink)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to encode usage records: %w", err)
+	}
+
+	resp, err := r.client.Post(r.cfg.URL, contentType, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to deliver usage records: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("usage sink returned status %d", resp.StatusCode)
+	}
+
+	return nil
+}
+
+// EncodeCSV encodes records as CSV with a header row
+func EncodeCSV(records []Record) ([]byte, error) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+
+	if err := w.Write([]string{"tenant", "period_start", "period_end", "searches", "documents_indexed", "storage_bytes"}); err != nil {
+		return nil, err
+	}
+	for _, rec := range records {
+		row := []string{
+			rec.Tenant,
+			rec.PeriodStart.Format(time.RFC3339),
+			rec.PeriodEnd.Format(time.RFC3339),
+			strconv.FormatInt(rec.Searches, 10),
+			strconv.FormatInt(rec.DocumentsIndexed, 10),
+			strconv.FormatInt(rec.StorageBytes, 10),
+		}
+		if err := w.Write(row); err != nil {
+			return nil, err
+		}
+	}
+
+	w.Flush()
+	return buf.Bytes(), w.Error()
+}
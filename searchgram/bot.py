@@ -668,6 +668,9 @@ def parse_search_results(data: "dict"):
 
     for hit in hits:
         text = hit.get("text") or hit.get("caption")
+        if not text and hit.get("is_poll"):
+            # Polls have no text; show the question and options instead
+            text = "📊 " + " / ".join([hit.get("poll_question") or ""] + (hit.get("poll_options") or []))
         if not text:
             # maybe sticker of media without caption
             continue
@@ -699,17 +702,23 @@ def parse_search_results(data: "dict"):
             # For channels/groups without username and no from_user, use chat link
             deep_link = f"tg://privatepost?channel={chat_id}&post={message_id}"
         text_link = f"https://t.me/{username}/{message_id}" if username else f"https://t.me/c/{chat_id}/{message_id}"
+        # Link the message this one replies to
+        reply_to = hit.get("reply_to_message_id")
+        reply_suffix = ""
+        if reply_to:
+            reply_link = f"https://t.me/{username}/{reply_to}" if username else f"https://t.me/c/{chat_id}/{reply_to}"
+            reply_suffix = f" [↩️ in reply to]({reply_link})"
 
         if outgoing:
-            result += f"{from_username} -> [{chat_username}]({deep_link}) on {date}: \n`{text}` [👀]({text_link})\n\n"
+            result += f"{from_username} -> [{chat_username}]({deep_link}) on {date}: \n`{text}` [👀]({text_link}){reply_suffix}\n\n"
         else:
             # For incoming messages, show: sender -> chat (or "-> me" for private chats)
             if from_username and from_username != chat_username:
                 # Group/channel message: show sender -> chat
-                result += f"{from_username} -> [{chat_username}]({deep_link}) on {date}: \n`{text}` [👀]({text_link})\n\n"
+                result += f"{from_username} -> [{chat_username}]({deep_link}) on {date}: \n`{text}` [👀]({text_link}){reply_suffix}\n\n"
             else:
                 # Private message: show sender -> me
-                result += f"[{chat_username}]({deep_link}) -> me on {date}: \n`{text}` [👀]({text_link})\n\n"
+                result += f"[{chat_username}]({deep_link}) -> me on {date}: \n`{text}` [👀]({text_link}){reply_suffix}\n\n"
     return result
 
 
@@ -161,6 +161,16 @@ class BufferedSearchEngine:
                 logging.debug(f"Buffer size threshold reached ({self.batch_size}), flushing")
                 self._flush_buffer_unsafe()
 
+    def update_message(self, message: types.Message) -> None:
+        """
+        Apply an edit, flushing first so a buffered original cannot overwrite it.
+
+        Args:
+            message: Edited Pyrogram message object
+        """
+        self.flush()
+        self.engine.update_message(message)
+
     def flush(self) -> None:
         """
         Manually flush all buffered messages.
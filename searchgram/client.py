@@ -122,7 +122,7 @@ def message_edit_handler(client: "Client", message: "types.Message"):
         return
 
     logging.info("Editing old message: %s-%s", message.chat.id, message.id)
-    tgdb.upsert(message)
+    tgdb.update_message(message)
     stats["edited"] += 1
 
 
@@ -6,9 +6,11 @@
 
 __author__ = "Benny <benny.think@gmail.com>"
 
+import base64
 import json
 import logging
-from typing import Any, Dict, List, Optional
+import time
+from typing import Any, Dict, List, Optional, Tuple
 
 import httpx
 
@@ -32,6 +34,7 @@ class HTTPSearchEngine(BasicSearchEngine):
         timeout: int = 30,
         max_retries: int = 3,
         jwt_auth: Optional[JWTAuth] = None,
+        language: Optional[str] = None,
     ):
         """
         Initialize HTTP/2 search engine client with connection pooling.
@@ -41,6 +44,7 @@ class HTTPSearchEngine(BasicSearchEngine):
             timeout: Request timeout in seconds
             max_retries: Maximum number of retry attempts
             jwt_auth: JWT auth instance for authentication (required)
+            language: Preferred language for error messages (e.g., "zh-CN")
         """
         self.base_url = base_url.rstrip('/')
         self.timeout = timeout
@@ -52,6 +56,8 @@ class HTTPSearchEngine(BasicSearchEngine):
             'Content-Type': 'application/json',
             'Accept': 'application/json',
         }
+        if language:
+            headers['Accept-Language'] = language
 
         # JWT authentication is required
         if self.jwt_auth:
@@ -112,7 +118,8 @@ class HTTPSearchEngine(BasicSearchEngine):
             logging.error(f"Failed to connect to search service: {e}")
             raise ConnectionError(f"Cannot connect to search service at {self.base_url}: {e}")
 
-    def _make_request(self, method: str, endpoint: str, timeout: Optional[int] = None, **kwargs) -> Dict[str, Any]:
+    def _make_request(self, method: str, endpoint: str, timeout: Optional[int] = None,
+                      accept_status: Tuple[int, ...] = (), **kwargs) -> Dict[str, Any]:
         """
         Make an HTTP/2 request to the Go service with automatic retries.
 
@@ -120,6 +127,7 @@ class HTTPSearchEngine(BasicSearchEngine):
             method: HTTP method (GET, POST, DELETE, etc.)
             endpoint: API endpoint (e.g., "/api/v1/search")
             timeout: Optional custom timeout in seconds (overrides default)
+            accept_status: Error statuses whose JSON body is returned instead of raised
             **kwargs: Additional arguments for httpx
 
         Returns:
@@ -153,6 +161,8 @@ class HTTPSearchEngine(BasicSearchEngine):
                 timeout=request_timeout,
                 **kwargs
             )
+            if response.status_code in accept_status:
+                return response.json()
             response.raise_for_status()
             return response.json()
 
@@ -239,7 +249,15 @@ class HTTPSearchEngine(BasicSearchEngine):
         mode: str = None,
         blocked_users: List[int] = None,
         chat_id: int = None,
-        include_deleted: bool = False
+        include_deleted: bool = False,
+        thread_id: int = None,
+        near: Dict[str, Any] = None,
+        user_groups: List[List[int]] = None,
+        title_contains: str = None,
+        semantic: bool = False,
+        lang: str = None,
+        translate: bool = False,
+        exclude_spam: bool = False
     ) -> Dict[str, Any]:
         """
         Search for messages.
@@ -253,6 +271,20 @@ class HTTPSearchEngine(BasicSearchEngine):
             blocked_users: List of user IDs to exclude
             chat_id: Optional chat ID to filter results (for group-specific searches)
             include_deleted: Include soft-deleted messages (owner only, default: False)
+            thread_id: Optional forum topic ID to filter results (used with chat_id)
+            near: Optional {"lat", "lon", "radius"} to only return messages sent
+                within radius (e.g. "2km") of a point
+            user_groups: Optional lists of user IDs that belong to one person;
+                blocking one account blocks all accounts in its group
+            title_contains: Optional phrase; only search chats whose title
+                contains it (e.g. "工作" for all work groups)
+            semantic: Rank by meaning as well as keywords (hybrid semantic search,
+                requires embeddings on the service)
+            lang: Optional ISO 639-1 code; only return messages in that
+                language (e.g. "zh" or "en")
+            translate: Also search the keyword translated into the service's
+                configured languages (requires query translation on the service)
+            exclude_spam: Leave out messages the service classified as spam
 
         Returns:
             Search results dict with hits, totalHits, totalPages, page, hitsPerPage
@@ -278,6 +310,30 @@ class HTTPSearchEngine(BasicSearchEngine):
         if chat_id:
             payload["chat_id"] = chat_id
 
+        if thread_id:
+            payload["thread_id"] = thread_id
+
+        if near:
+            payload["near"] = near
+
+        if user_groups:
+            payload["user_groups"] = user_groups
+
+        if title_contains:
+            payload["title_contains"] = title_contains
+
+        if semantic:
+            payload["semantic"] = True
+
+        if lang:
+            payload["lang"] = lang
+
+        if translate:
+            payload["translate"] = True
+
+        if exclude_spam:
+            payload["exclude_spam"] = True
+
         # Make request
         result = self._make_request("POST", "/api/v1/search", json=payload)
 
@@ -315,56 +371,203 @@ class HTTPSearchEngine(BasicSearchEngine):
             "total_documents": result.get("total_documents", 0),
         }
 
-    def clear_db(self) -> None:
-        """Clear all documents from the search index."""
-        self._make_request("DELETE", "/api/v1/clear")
-        logging.info("Database cleared")
+    def clear_db(self) -> Dict[str, Any]:
+        """
+        Clear all documents from the search index.
+
+        The service answers a clear with 428 and a one-time confirmation
+        token, and only starts clearing once the token is sent back. The
+        clear then runs as a background job. While one is running, that job
+        is returned instead.
 
-    def delete(self, chat_id: int) -> int:
+        Returns:
+            The clear job, whose progress is at /api/v1/jobs/{id}
+        """
+        confirmation = self._make_request("DELETE", "/api/v1/clear", accept_status=(428, 409))
+        if "job_id" in confirmation:
+            logging.info(f"Clear job already running: {confirmation['job_id']}")
+            return self._make_request("GET", f"/api/v1/jobs/{confirmation['job_id']}")
+        token = confirmation.get("confirm_token")
+        if not token:
+            raise Exception("Search service error: clear was not offered a confirmation token")
+
+        job = self._make_request("DELETE", "/api/v1/clear", params={"confirm": token})
+        logging.info(
+            f"Clearing {confirmation.get('estimated_count', 0)} messages in background job {job.get('id')}"
+        )
+        return job
+
+    def delete(self, chat_id: int, force: bool = False) -> int:
         """
         Delete all messages from a specific chat.
 
         Args:
             chat_id: Chat ID to delete messages from
+            force: Delete even above the service's delete guardrail threshold
 
         Returns:
             Number of deleted documents
         """
         result = self._make_request(
             "DELETE",
-            f"/api/v1/messages?chat_id={chat_id}"
+            f"/api/v1/messages?chat_id={chat_id}" + ("&force=true" if force else "")
         )
         deleted_count = result.get("deleted_count", 0)
         logging.info(f"Deleted {deleted_count} messages from chat {chat_id}")
         return deleted_count
 
-    def delete_user(self, user_id: int) -> int:
+    def delete_user(self, user_id: int, force: bool = False) -> int:
         """
         Delete all messages from a specific user (for privacy opt-out).
 
         Args:
             user_id: User ID to delete messages from
+            force: Delete even above the service's delete guardrail threshold
 
         Returns:
             Number of deleted documents
         """
         result = self._make_request(
             "DELETE",
-            f"/api/v1/users/{user_id}"
+            f"/api/v1/users/{user_id}" + ("?force=true" if force else "")
         )
         deleted_count = result.get("deleted_count", 0)
         logging.info(f"Deleted {deleted_count} messages from user {user_id}")
         return deleted_count
 
-    def dedup(self) -> Dict[str, Any]:
+    def get_profile(self) -> Dict[str, Any]:
+        """
+        Get this client's search profile.
+
+        Only available when the service runs with search profiles enabled;
+        raises if no profile is stored.
+
+        Returns:
+            Profile dict with blocked_users, excluded_chats, page_size and sort_by
+        """
+        return self._make_request("GET", "/api/v1/profile")
+
+    def set_profile(
+        self,
+        blocked_users: List[int] = None,
+        excluded_chats: List[int] = None,
+        page_size: int = None,
+        sort_by: str = None
+    ) -> Dict[str, Any]:
+        """
+        Replace this client's search profile, merged by the service into
+        every search this client makes.
+
+        Args:
+            blocked_users: User IDs always excluded from results
+            excluded_chats: Chat IDs never searched
+            page_size: Results per page when a search doesn't set one
+            sort_by: Sort order when a search doesn't set one
+
+        Returns:
+            The stored profile
+        """
+        payload = {}
+        if blocked_users:
+            payload["blocked_users"] = blocked_users
+        if excluded_chats:
+            payload["excluded_chats"] = excluded_chats
+        if page_size:
+            payload["page_size"] = page_size
+        if sort_by:
+            payload["sort_by"] = sort_by
+        return self._make_request("PUT", "/api/v1/profile", json=payload)
+
+    def get_capture_config(self, etag: str = None) -> Optional[Tuple[Dict[str, Any], str]]:
+        """
+        Poll the signed capture config (chat allowlists, drop rules, sampling).
+
+        The Ed25519 signature is verified with the JWT public key, so a
+        tampered or unsigned document is never applied.
+
+        Args:
+            etag: ETag of the config currently applied
+
+        Returns:
+            (config, etag) when the config changed, None when it did not
+        """
+        headers = {}
+        if etag:
+            headers["If-None-Match"] = etag
+        if self.jwt_auth:
+            headers["Authorization"] = f"Bearer {self.jwt_auth.generate_token()}"
+
+        response = self.client.get(f"{self.base_url}/api/v1/capture/config", headers=headers)
+        if response.status_code == 304:
+            return None
+        response.raise_for_status()
+
+        signature = base64.b64decode(response.headers.get("X-Config-Signature", ""))
+        if not self.jwt_auth or not self.jwt_auth.verify_signature(response.content, signature):
+            raise Exception("Capture config signature verification failed")
+
+        return response.json(), response.headers.get("ETag", "")
+
+    def list_chats(self, title_contains: str = None, limit: int = 100) -> List[Dict[str, Any]]:
+        """
+        List indexed chats, most active first.
+
+        Args:
+            title_contains: Optional phrase the chat title must contain
+            limit: Maximum number of chats (max 1000)
+
+        Returns:
+            List of chats with chat_id, chat_type, chat_title, chat_username,
+            message_count and last_message_at
+        """
+        params = {"limit": limit}
+        if title_contains:
+            params["title_contains"] = title_contains
+        result = self._make_request("GET", "/api/v1/chats", params=params)
+        return result.get("chats", [])
+
+    def list_trash(self) -> List[Dict[str, Any]]:
+        """
+        List recycle bin batches, most recently deleted first.
+
+        Only available when the service runs with the recycle bin enabled.
+
+        Returns:
+            List of batches with id, operation, target, count, trashed_at
+            and expires_at
+        """
+        result = self._make_request("GET", "/api/v1/trash")
+        return result.get("batches", [])
+
+    def restore_trash(self, batch_id: str) -> int:
+        """
+        Restore every message removed by one delete, e.g. an accidental delete_user.
+
+        Args:
+            batch_id: Recycle bin batch, as returned in trash_batch by the delete
+
+        Returns:
+            Number of restored messages
+        """
+        result = self._make_request("POST", f"/api/v1/trash/batches/{batch_id}/restore")
+        restored_count = result.get("restored_count", 0)
+        logging.info(f"Restored {restored_count} messages from trash batch {batch_id}")
+        return restored_count
+
+    def dedup(self, poll_interval: int = 5, max_wait: int = 3600, dry_run: bool = False) -> Dict[str, Any]:
         """
         Remove duplicate messages from the search index.
 
         Finds messages with the same chat_id + message_id combination
         and keeps only the latest version (by timestamp).
 
-        Note: This operation can take several minutes for large databases.
-              Uses a 10-minute timeout to allow completion.
+        The search service runs deduplication as a background job; this
+        method starts (or joins) the job and polls until it finishes.
+
+        Args:
+            poll_interval: Seconds between job status polls
+            max_wait: Maximum seconds to wait for the job to finish
+            dry_run: Only report duplicates (result includes a "report") without deleting
 
         Returns:
             Dictionary with deduplication results:
@@ -375,9 +578,27 @@ class HTTPSearchEngine(BasicSearchEngine):
         """
         logging.info("Starting deduplication (this may take several minutes)...")
 
-        # Use 10-minute timeout for dedup operations (they can be slow on large DBs)
-        result = self._make_request("POST", "/api/v1/dedup", timeout=600)
+        job = self._make_request("POST", "/api/v1/dedup", json={"dry_run": dry_run}, accept_status=(409,))
+        if "job_id" in job:
+            # A deduplication is already running; wait for that one instead
+            job_id = job["job_id"]
+            logging.info(f"Deduplication job already running: {job_id}")
+            job = self._make_request("GET", f"/api/v1/jobs/{job_id}")
+        else:
+            job_id = job["id"]
+            logging.info(f"Deduplication job started: {job_id}")
+
+        deadline = time.time() + max_wait
+        while job.get("status") == "running":
+            if time.time() > deadline:
+                raise Exception(f"Deduplication job {job_id} did not finish within {max_wait}s")
+            time.sleep(poll_interval)
+            job = self._make_request("GET", f"/api/v1/jobs/{job_id}")
+
+        if job.get("status") != "succeeded":
+            raise Exception(f"Deduplication failed: {job.get('error', 'unknown error')}")
 
+        result = job.get("result") or {}
         duplicates_found = result.get("duplicates_found", 0)
         duplicates_removed = result.get("duplicates_removed", 0)
         logging.info(
@@ -386,6 +607,31 @@ class HTTPSearchEngine(BasicSearchEngine):
         )
         return result
 
+    def update_message(self, message: "types.Message") -> None:
+        """
+        Apply an edit to an already indexed message.
+
+        Falls back to a full upsert when the message is not indexed yet.
+
+        Args:
+            message: Edited Pyrogram message object
+        """
+        doc_id = f"{message.chat.id}-{message.id}"
+        payload = {}
+        if message.text is not None:
+            payload["text"] = message.text
+        if message.caption is not None:
+            payload["caption"] = message.caption
+        if message.edit_date:
+            payload["edit_date"] = int(message.edit_date.timestamp())
+
+        try:
+            self._make_request("PATCH", f"/api/v1/messages/{doc_id}", json=payload)
+            logging.debug(f"Updated edited message: {doc_id}")
+        except Exception as e:
+            logging.warning(f"Failed to update message {doc_id}, falling back to upsert: {e}")
+            self.upsert(message)
+
     def soft_delete_message(self, chat_id: int, message_id: int) -> None:
         """
         Soft-delete a specific message (mark as deleted without removing).
@@ -417,7 +663,8 @@ class HTTPSearchEngine(BasicSearchEngine):
         from_timestamp: int,
         to_timestamp: int,
         include_mentions: bool = False,
-        include_deleted: bool = False
+        include_deleted: bool = False,
+        user_groups: List[List[int]] = None
     ) -> Dict[str, Any]:
         """
         Get activity statistics for a user in a group.
@@ -429,6 +676,8 @@ class HTTPSearchEngine(BasicSearchEngine):
             to_timestamp: End of time window (Unix timestamp)
             include_mentions: Whether to count mentions
             include_deleted: Include deleted messages (owner only)
+            user_groups: Optional lists of user IDs that belong to one person;
+                the user's other accounts are counted as the same user
 
         Returns:
             Dictionary with stats:
@@ -446,6 +695,8 @@ class HTTPSearchEngine(BasicSearchEngine):
             "include_mentions": include_mentions,
             "include_deleted": include_deleted,
         }
+        if user_groups:
+            payload["user_groups"] = user_groups
 
         result = self._make_request("POST", "/api/v1/stats/user", json=payload)
 
@@ -512,6 +763,7 @@ def SearchEngine(*args, **kwargs) -> HTTPSearchEngine:
     base_url = config.get("services.search.base_url", "http://127.0.0.1:8080")
     timeout = config.get_int("search_engine.http.timeout", 30)
     max_retries = config.get_int("search_engine.http.max_retries", 3)
+    language = config.get("search_engine.http.language")
 
     # Initialize JWT auth if configured (required)
     jwt_auth = None
@@ -549,4 +801,5 @@ def SearchEngine(*args, **kwargs) -> HTTPSearchEngine:
         timeout=timeout,
         max_retries=max_retries,
         jwt_auth=jwt_auth,
+        language=language,
     )
@@ -183,6 +183,27 @@ class JWTAuth:
         pem_str = key_data.replace('\\n', '\n')
         return pem_str.encode('utf-8')
 
+    def verify_signature(self, data: bytes, signature: bytes) -> bool:
+        """
+        Verify an Ed25519 signature made with the service's private key,
+        e.g. the signed capture config.
+
+        Args:
+            data: Signed bytes
+            signature: Raw signature
+
+        Returns:
+            True if the signature is valid
+        """
+        if not self.public_key:
+            raise ValueError("Public key not loaded, cannot verify signatures")
+
+        try:
+            self.public_key.verify(signature, data)
+            return True
+        except Exception:
+            return False
+
     def generate_token(
         self,
         target_audience: Optional[str] = None,
@@ -181,6 +181,96 @@ class MessageConverter:
                 "forward_timestamp": forward_timestamp,
             }
 
+    @staticmethod
+    def _resolve_reply(message: types.Message) -> Dict[str, Any]:
+        """
+        Resolve reply and forum topic information from message.
+
+        Returns:
+        - reply_to_message_id: ID of the replied-to message or None
+        - thread_id: Forum topic ID (the topic's first message) or None
+        """
+        reply_to = getattr(message, 'reply_to_message_id', None)
+        top_id = getattr(message, 'reply_to_top_message_id', None)
+
+        thread_id = None
+        if getattr(message, 'is_topic_message', False):
+            thread_id = getattr(message, 'message_thread_id', None) or top_id or reply_to
+        elif getattr(message.chat, 'is_forum', False):
+            thread_id = top_id or reply_to
+
+        # Messages posted in a topic "reply" to its first message
+        if thread_id and reply_to == thread_id and not top_id:
+            reply_to = None
+
+        return {
+            "reply_to_message_id": reply_to,
+            "thread_id": thread_id,
+        }
+
+    @staticmethod
+    def _resolve_engagement(message: types.Message) -> Dict[str, Any]:
+        """
+        Resolve engagement counters from message.
+
+        Returns:
+        - reactions_count: Total reactions across all emoji
+        - views: View count or None
+        - forwards: Forward count or None
+        """
+        reactions_count = 0
+        reactions = getattr(message, 'reactions', None)
+        for reaction in getattr(reactions, 'reactions', None) or []:
+            reactions_count += getattr(reaction, 'count', 0) or 0
+
+        return {
+            "reactions_count": reactions_count,
+            "views": getattr(message, 'views', None),
+            "forwards": getattr(message, 'forwards', None),
+        }
+
+    @staticmethod
+    def _resolve_location(message: types.Message) -> Dict[str, Any]:
+        """
+        Resolve the position of location, live location and venue messages.
+
+        Returns:
+        - location: {"lat", "lon"} or None
+        """
+        location = getattr(message, 'location', None)
+        if location is None:
+            venue = getattr(message, 'venue', None)
+            location = getattr(venue, 'location', None)
+        if location is None:
+            return {"location": None}
+
+        return {
+            "location": {
+                "lat": location.latitude,
+                "lon": location.longitude,
+            },
+        }
+
+    @staticmethod
+    def _resolve_poll(message: types.Message) -> Dict[str, Any]:
+        """
+        Resolve poll question and options from message.
+
+        Returns:
+        - is_poll: Whether the message is a poll
+        - poll_question: Poll question or None
+        - poll_options: Option texts in poll order
+        """
+        poll = getattr(message, 'poll', None)
+        if poll is None:
+            return {"is_poll": False, "poll_question": None, "poll_options": []}
+
+        return {
+            "is_poll": True,
+            "poll_question": getattr(poll, 'question', None),
+            "poll_options": [getattr(option, 'text', '') or '' for option in getattr(poll, 'options', None) or []],
+        }
+
     @staticmethod
     def _resolve_content(message: types.Message) -> Dict[str, Any]:
         """
@@ -268,6 +358,49 @@ class MessageConverter:
                 "sticker_set_name": None,
             }
 
+    # Pyrogram media attributes in precedence order, with their media_type
+    _MEDIA_ATTRIBUTES = (
+        ("sticker", "sticker"),
+        ("photo", "photo"),
+        ("animation", "animation"),
+        ("video_note", "video_note"),
+        ("video", "video"),
+        ("voice", "voice"),
+        ("audio", "audio"),
+        ("document", "document"),
+    )
+
+    @staticmethod
+    def _resolve_media(message: types.Message) -> Dict[str, Any]:
+        """
+        Resolve media metadata from message.
+
+        Returns:
+        - media_type: "photo", "video", "document", "audio", "voice", "video_note", "animation", "sticker" or None
+        - file_name: Original file name or None
+        - mime_type: MIME type or None
+        - file_size: Size in bytes or None
+        """
+        for attribute, media_type in MessageConverter._MEDIA_ATTRIBUTES:
+            media = getattr(message, attribute, None)
+            if media:
+                mime_type = getattr(media, 'mime_type', None)
+                if media_type == "photo":
+                    mime_type = "image/jpeg"
+                return {
+                    "media_type": media_type,
+                    "file_name": getattr(media, 'file_name', None),
+                    "mime_type": mime_type,
+                    "file_size": getattr(media, 'file_size', None),
+                }
+
+        return {
+            "media_type": None,
+            "file_name": None,
+            "mime_type": None,
+            "file_size": None,
+        }
+
     @staticmethod
     def _extract_entities(message: types.Message) -> list:
         """
@@ -284,6 +417,9 @@ class MessageConverter:
                     "offset": entity.offset,
                     "length": entity.length,
                 }
+                # For text links, include the target URL
+                if getattr(entity, 'url', None):
+                    entity_dict["url"] = entity.url
                 # For text mentions, include user information
                 if hasattr(entity, 'user') and entity.user:
                     entity_dict["user_id"] = entity.user.id
@@ -310,7 +446,12 @@ class MessageConverter:
         # Resolve sender, forward, and content information
         sender_info = MessageConverter._resolve_sender(message)
         forward_info = MessageConverter._resolve_forward(message)
+        reply_info = MessageConverter._resolve_reply(message)
         content_info = MessageConverter._resolve_content(message)
+        media_info = MessageConverter._resolve_media(message)
+        engagement_info = MessageConverter._resolve_engagement(message)
+        location_info = MessageConverter._resolve_location(message)
+        poll_info = MessageConverter._resolve_poll(message)
 
         # Extract entities
         entities = MessageConverter._extract_entities(message)
@@ -338,9 +479,24 @@ class MessageConverter:
             # Forward information
             **forward_info,
 
+            # Reply and thread information
+            **reply_info,
+
             # Content information
             **content_info,
 
+            # Media metadata
+            **media_info,
+
+            # Engagement
+            **engagement_info,
+
+            # Location
+            **location_info,
+
+            # Poll
+            **poll_info,
+
             # Entities
             "entities": entities,
 